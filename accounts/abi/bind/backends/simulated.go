@@ -745,6 +745,10 @@ func (m callmsg) Value() *big.Int         { return m.CallMsg.Value }
 func (m callmsg) Fee() *big.Int           { return m.CallMsg.Fee }
 func (m callmsg) Data() []byte            { return m.CallMsg.Data }
 
+func (m callmsg) AccessList() types.AccessList { return m.CallMsg.AccessList }
+
+func (m callmsg) SponsorPolicy() *types.SponsorPolicy { return nil }
+
 // filterBackend implements filters.Backend to support filtering for logs without
 // taking bloom-bits acceleration structures into account.
 type filterBackend struct {
@@ -278,7 +278,7 @@ func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *b
 		return nil, ErrLocked
 	}
 	// Depending on the presence of the chain ID, sign with EIP155 or homestead
-	return types.SignTx(tx, types.NewTIP1Signer(chainID), unlockedKey.PrivateKey)
+	return types.SignTx(tx, types.NewTIP13Signer(chainID), unlockedKey.PrivateKey)
 }
 
 func (ks *KeyStore) SignTx_Payment(a accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
@@ -291,7 +291,7 @@ func (ks *KeyStore) SignTx_Payment(a accounts.Account, tx *types.Transaction, ch
 		return nil, ErrLocked
 	}
 	// Depending on the presence of the chain ID, sign with EIP155 or homestead
-	return types.SignTx_Payment(tx, types.NewTIP1Signer(chainID), unlockedKey.PrivateKey)
+	return types.SignTx_Payment(tx, types.NewTIP13Signer(chainID), unlockedKey.PrivateKey)
 }
 
 // SignHashWithPassphrase signs hash if the private key matching the given address
@@ -316,7 +316,7 @@ func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string,
 	defer zeroKey(key.PrivateKey)
 
 	// Depending on the presence of the chain ID, sign with EIP155 or homestead
-	return types.SignTx(tx, types.NewTIP1Signer(chainID), key.PrivateKey)
+	return types.SignTx(tx, types.NewTIP13Signer(chainID), key.PrivateKey)
 }
 
 // Unlock unlocks the given account indefinitely.
@@ -0,0 +1,120 @@
+// Copyright 2015 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// bftsigner holds a committee key and signs the tbft votes and proposals and
+// the PbftSigns of a getrue node started with --bftsigner. Only the nodes
+// with one of the allowed node keys are served. It never signs a vote,
+// proposal or PbftSign conflicting with one it has signed before.
+package main
+
+import (
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"truechain/discovery/cmd/utils"
+	"truechain/discovery/common"
+	"truechain/discovery/consensus/tbft/help"
+	"truechain/discovery/consensus/tbft/privval"
+	"truechain/discovery/crypto"
+	"truechain/discovery/log"
+)
+
+func main() {
+	var (
+		listenAddr = flag.String("addr", "unix://bftsigner.ipc", "listen address (tcp://host:port or unix:///path)")
+		keyFile    = flag.String("bftkey", "", "committee private key filename")
+		keyHex     = flag.String("bftkeyhex", "", "committee private key as hex (for testing)")
+		stateDir   = flag.String("statedir", "bftsigner", "directory of the last signed states")
+		allowList  = flag.String("allow", "", "comma separated hex public keys of the node keys allowed to connect")
+		verbosity  = flag.Int("verbosity", int(log.LvlInfo), "log verbosity (0-9)")
+		vmodule    = flag.String("vmodule", "", "log verbosity pattern")
+
+		key     *ecdsa.PrivateKey
+		allowed []*ecdsa.PublicKey
+		err     error
+	)
+	flag.Parse()
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(*verbosity))
+	glogger.Vmodule(*vmodule)
+	log.Root().SetHandler(glogger)
+
+	switch {
+	case *keyFile == "" && *keyHex == "":
+		utils.Fatalf("Use -bftkey or -bftkeyhex to specify a private key")
+	case *keyFile != "" && *keyHex != "":
+		utils.Fatalf("Options -bftkey and -bftkeyhex are mutually exclusive")
+	case *keyFile != "":
+		if key, err = crypto.LoadECDSA(*keyFile); err != nil {
+			utils.Fatalf("-bftkey: %v", err)
+		}
+	case *keyHex != "":
+		if key, err = crypto.HexToECDSA(*keyHex); err != nil {
+			utils.Fatalf("-bftkeyhex: %v", err)
+		}
+	}
+	for _, pubHex := range strings.Split(*allowList, ",") {
+		if pubHex = strings.TrimSpace(pubHex); pubHex == "" {
+			continue
+		}
+		pub, err := crypto.UnmarshalPubkey(common.FromHex(pubHex))
+		if err != nil {
+			utils.Fatalf("-allow: %s: %v", pubHex, err)
+		}
+		allowed = append(allowed, pub)
+	}
+	if len(allowed) == 0 {
+		utils.Fatalf("Use -allow to specify the node keys allowed to connect")
+	}
+	if *stateDir == "" {
+		utils.Fatalf("-statedir: the last signed states must be kept")
+	}
+	if err := help.EnsureDir(*stateDir, 0700); err != nil {
+		utils.Fatalf("-statedir: %v", err)
+	}
+
+	protocol, address := help.ProtocolAndAddress(*listenAddr)
+	if protocol == "unix" {
+		// remove the socket left by a previous run
+		os.Remove(address)
+	}
+	l, err := net.Listen(protocol, address)
+	if err != nil {
+		utils.Fatalf("-addr: %v", err)
+	}
+	server, err := privval.NewSignerServer(key, *stateDir, allowed)
+	if err != nil {
+		utils.Fatalf("-statedir: %v", err)
+	}
+	log.Info("Remote signer started", "addr", *listenAddr, "publickey", fmt.Sprintf("%x", crypto.FromECDSAPub(&key.PublicKey)))
+
+	go func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+		<-sigc
+		log.Info("Remote signer shutting down")
+		server.Close()
+	}()
+	if err := server.Serve(l); err != nil {
+		log.Debug("Remote signer listener closed", "err", err)
+	}
+}
@@ -60,11 +60,14 @@ var (
 		utils.TxPoolGlobalSlotsFlag,
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
+		utils.TxPoolSenderSlotsFlag,
+		utils.TxPoolStakingSlotsFlag,
 		utils.TxPoolLifetimeFlag,
 
 		utils.SnailPoolJournalFlag,
 		utils.SnailPoolRejournalFlag,
 		utils.SnailPoolFruitCountFlag,
+		utils.SnailPoolDroppedCountFlag,
 		utils.SyncModeFlag,
 
 		utils.SingleNodeFlag,
@@ -76,10 +79,12 @@ var (
 		utils.BFTIPFlag,
 		utils.BftKeyFileFlag,
 		utils.BftKeyHexFlag,
+		utils.BftRemoteSignerFlag,
 
 		utils.GCModeFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.LightCommitteeFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
@@ -96,6 +101,8 @@ var (
 		utils.MineFruitFlag,
 		utils.MiningEnabledFlag,
 		utils.MiningRemoteEnableFlag,
+		utils.StratumAddrFlag,
+		utils.StratumDifficultyFlag,
 		utils.GasTargetFlag,
 		utils.GasLimitFlag,
 
@@ -108,6 +115,7 @@ var (
 		utils.TestnetFlag,
 		utils.DevnetFlag,
 		utils.VMEnableDebugFlag,
+		utils.ExecutionModeFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.RPCVirtualHostsFlag,
@@ -136,6 +144,8 @@ var (
 	}
 
 	metricsFlags = []cli.Flag{
+		utils.MetricsHTTPFlag,
+		utils.MetricsPortFlag,
 		utils.MetricsEnableInfluxDBFlag,
 		utils.MetricsInfluxDBEndpointFlag,
 		utils.MetricsInfluxDBDatabaseFlag,
@@ -174,6 +184,10 @@ func init() {
 		licenseCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See signstatecmd.go
+		signStateCommand,
+		// See snapshotcmd.go
+		snapshotCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
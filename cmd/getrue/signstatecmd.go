@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/urfave/cli.v1"
+	"truechain/discovery/cmd/utils"
+	ttypes "truechain/discovery/consensus/tbft/types"
+	"truechain/discovery/console"
+	"truechain/discovery/log"
+	"truechain/discovery/params"
+)
+
+var (
+	signStateCommand = cli.Command{
+		Name:     "signstate",
+		Usage:    "Manage the last signed state of the committee validator",
+		Category: "MISCELLANEOUS COMMANDS",
+		Description: `
+The committee validator persists the height, round and step it signed last in
+every committee under <DATADIR>/.tbft/data/priv_validator_state and refuses to
+sign a conflicting proposal or vote for them, even across restarts.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Print the last signed state of the committees",
+				ArgsUsage: "[<committeeID>]",
+				Action:    utils.MigrateFlags(signStateInspect),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+				},
+				Description: `
+Print the last signed state of the given committee, or of all committees
+if no committee ID is given.`,
+			},
+			{
+				Name:      "reset",
+				Usage:     "Remove the last signed state of a committee",
+				ArgsUsage: "<committeeID>",
+				Action:    utils.MigrateFlags(signStateReset),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+				},
+				Description: `
+Remove the last signed state of the given committee. This is a dangerous
+action: the validator may sign a conflicting vote for a height it already
+voted on. Only use it when the node is stopped and the committee has moved
+past the last signed height.`,
+			},
+		},
+	}
+)
+
+// signStateConfig returns the consensus config of the pbft server of the node.
+func signStateConfig(ctx *cli.Context) *params.ConsensusConfig {
+	stack, _ := makeConfigNode(ctx)
+	cfg := params.DefaultConsensusConfig()
+	cfg.RootDir = stack.ResolvePath(params.DefaultTBFTDir)
+	return cfg
+}
+
+func parseCommitteeID(arg string) uint64 {
+	cid, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		utils.Fatalf("Invalid committee ID %q: %v", arg, err)
+	}
+	return cid
+}
+
+func signStateInspect(ctx *cli.Context) error {
+	cfg := signStateConfig(ctx)
+
+	var cids []uint64
+	if ctx.NArg() > 0 {
+		cids = append(cids, parseCommitteeID(ctx.Args().First()))
+	} else {
+		files, err := ioutil.ReadDir(cfg.PrivValidatorStateDir())
+		if err != nil && !os.IsNotExist(err) {
+			utils.Fatalf("Failed to read %s: %v", cfg.PrivValidatorStateDir(), err)
+		}
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || filepath.Ext(name) != ".json" {
+				continue
+			}
+			if cid, err := strconv.ParseUint(strings.TrimSuffix(name, ".json"), 10, 64); err == nil {
+				cids = append(cids, cid)
+			}
+		}
+		sort.Slice(cids, func(i, j int) bool { return cids[i] < cids[j] })
+	}
+	if len(cids) == 0 {
+		fmt.Println("No signed state found in", cfg.PrivValidatorStateDir())
+		return nil
+	}
+	for _, cid := range cids {
+		file := cfg.CommitteePrivValidatorStateFile(cid)
+		state, err := ttypes.LoadPrivValidatorState(file)
+		if os.IsNotExist(err) {
+			fmt.Printf("Committee %d: nothing signed\n", cid)
+			continue
+		}
+		if err != nil {
+			utils.Fatalf("Failed to load signed state of committee %d: %v", cid, err)
+		}
+		fmt.Printf("Committee %d: height=%d round=%d step=%d signature=%x\n", cid, state.Height, state.Round, state.Step, state.Signature)
+	}
+	return nil
+}
+
+func signStateReset(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires the committee ID as argument.")
+	}
+	cid := parseCommitteeID(ctx.Args().First())
+	file := signStateConfig(ctx).CommitteePrivValidatorStateFile(cid)
+
+	state, err := ttypes.LoadPrivValidatorState(file)
+	if os.IsNotExist(err) {
+		log.Info("Signed state doesn't exist, skipping", "committee", cid, "path", file)
+		return nil
+	}
+	if err != nil {
+		utils.Fatalf("Failed to load signed state of committee %d: %v", cid, err)
+	}
+	fmt.Println(file, state)
+	confirm, err := console.Stdin.PromptConfirm("Remove the signed state? The validator may double sign below this height")
+	switch {
+	case err != nil:
+		utils.Fatalf("%v", err)
+	case !confirm:
+		log.Warn("Signed state reset aborted")
+	default:
+		if err := os.Remove(file); err != nil {
+			utils.Fatalf("Failed to remove signed state: %v", err)
+		}
+		log.Info("Signed state successfully reset", "committee", cid)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+	"truechain/discovery/cmd/utils"
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/state/pruner"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/log"
+)
+
+var (
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "Manage the state of the fast chain",
+		Category: "BLOCKCHAIN COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:   "prune-state",
+				Usage:  "Delete the stale state trie nodes",
+				Action: utils.MigrateFlags(pruneState),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.CacheDatabaseFlag,
+					utils.PruneBlocksFlag,
+				},
+				Description: `
+getrue snapshot prune-state [--prune.blocks <N>]
+
+Delete the state trie nodes and contract codes not reachable from the states
+of the latest N fast blocks on disk and the genesis state, the impawn state of
+the staking address included. The reachable nodes are marked in <DATADIR>/prunestate first, the
+rest is then swept from the chain database, and the states kept are verified.
+
+The node must be stopped. An interrupted pruning is resumed by running the
+command again.`,
+			},
+		},
+	}
+)
+
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	markPath := stack.ResolvePath("prunestate")
+	markDb, err := etruedb.NewLDBDatabase(markPath, 256, 256)
+	if err != nil {
+		utils.Fatalf("Failed to open prune mark database: %v", err)
+	}
+	p, err := pruner.New(chainDb, markDb)
+	if err != nil {
+		utils.Fatalf("Failed to create pruner: %v", err)
+	}
+	// Keep the states of the latest blocks, unless resuming
+	var roots []common.Hash
+	if p.Resumed() == nil {
+		head := rawdb.ReadHeadBlockHash(chainDb)
+		number := rawdb.ReadHeaderNumber(chainDb, head)
+		if number == nil {
+			utils.Fatalf("Head block missing")
+		}
+		blocks := ctx.GlobalUint64(utils.PruneBlocksFlag.Name)
+		for i := uint64(0); i < blocks && i <= *number; i++ {
+			hash := rawdb.ReadCanonicalHash(chainDb, *number-i)
+			if header := rawdb.ReadHeader(chainDb, hash, *number-i); header != nil {
+				roots = append(roots, header.Root)
+			}
+		}
+		log.Info("Pruning state", "head", *number, "blocks", blocks)
+	}
+	start := time.Now()
+	if err := p.Prune(roots); err != nil {
+		utils.Fatalf("Failed to prune state: %v", err)
+	}
+	markDb.Close()
+	if err := os.RemoveAll(markPath); err != nil {
+		log.Warn("Failed to remove prune mark database", "path", markPath, "err", err)
+	}
+	log.Info("State pruned", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
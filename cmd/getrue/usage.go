@@ -64,6 +64,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
+			utils.LightCommitteeFlag,
 			utils.LightKDFFlag,
 		},
 	},
@@ -100,6 +101,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.BFTStandbyPortFlag,
 			utils.BftKeyFileFlag,
 			utils.BftKeyHexFlag,
+			utils.BftRemoteSignerFlag,
 		},
 	},
 
@@ -115,6 +117,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TxPoolGlobalSlotsFlag,
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
+			utils.TxPoolSenderSlotsFlag,
+			utils.TxPoolStakingSlotsFlag,
 			utils.TxPoolLifetimeFlag,
 		},
 	},
@@ -176,6 +180,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MiningEnabledFlag,
 			utils.MineFruitFlag,
 			utils.MiningRemoteEnableFlag,
+			utils.StratumAddrFlag,
+			utils.StratumDifficultyFlag,
 			utils.MinerThreadsFlag,
 			utils.CoinbaseFlag,
 			utils.GasTargetFlag,
@@ -195,6 +201,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "VIRTUAL MACHINE",
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
+			utils.ExecutionModeFlag,
 		},
 	},
 	{
@@ -208,6 +215,8 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "METRICS AND STATS",
 		Flags: []cli.Flag{
 			utils.MetricsEnabledFlag,
+			utils.MetricsHTTPFlag,
+			utils.MetricsPortFlag,
 			utils.MetricsEnableInfluxDBFlag,
 			utils.MetricsInfluxDBEndpointFlag,
 			utils.MetricsInfluxDBDatabaseFlag,
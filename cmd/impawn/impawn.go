@@ -35,6 +35,7 @@ var (
 
 var (
 	abiStaking, _ = abi.JSON(strings.NewReader(vm.TIP10StakeABIJSON))
+	abiProfile, _ = abi.JSON(strings.NewReader(vm.StakeProfileABIJSON))
 	priKey        *ecdsa.PrivateKey
 	from          common.Address
 	trueValue     uint64
@@ -47,6 +48,7 @@ const (
 	datadirDefaultKeyStore = "keystore"
 	ImpawnAmount           = 20000
 	SnailRewardInterval    = 14
+	rewardsPageSize        = 1000
 )
 
 func impawn(ctx *cli.Context) error {
@@ -307,7 +309,11 @@ func queryTx(conn *etrueclient.Client, txHash common.Hash, contract bool, pendin
 }
 
 func packInput(abiMethod string, params ...interface{}) []byte {
-	input, err := abiStaking.Pack(abiMethod, params...)
+	abiContract := abiStaking
+	if _, ok := abiProfile.Methods[abiMethod]; ok {
+		abiContract = abiProfile
+	}
+	input, err := abiContract.Pack(abiMethod, params...)
 	if err != nil {
 		printError(abiMethod, " error ", err)
 	}
@@ -41,6 +41,11 @@ var (
 		Usage: "Transfer address",
 		Value: "",
 	}
+	TargetAddressFlag = cli.StringFlag{
+		Name:  "target",
+		Usage: "The validator address redelegate or delegate to",
+		Value: "",
+	}
 	TxHashFlag = cli.StringFlag{
 		Name:  "txhash",
 		Usage: "Input transaction hash",
@@ -60,6 +65,44 @@ var (
 		Name:  "snailnumber",
 		Usage: "Query reward use snail number,please current snail number -14",
 	}
+	FromSnailFlag = cli.Uint64Flag{
+		Name:  "from",
+		Usage: "The first snail number of the rewards exported",
+	}
+	ToSnailFlag = cli.Uint64Flag{
+		Name:  "to",
+		Usage: "The last snail number of the rewards exported (default: latest)",
+	}
+	CSVFlag = cli.StringFlag{
+		Name:  "csv",
+		Usage: "Export the rewards to the csv file instead of printing them",
+		Value: "",
+	}
+	MonikerFlag = cli.StringFlag{
+		Name:  "moniker",
+		Usage: "Validator display name",
+		Value: "",
+	}
+	WebsiteFlag = cli.StringFlag{
+		Name:  "website",
+		Usage: "Validator website",
+		Value: "",
+	}
+	ContactFlag = cli.StringFlag{
+		Name:  "contact",
+		Usage: "Validator security contact, only its hash is stored",
+		Value: "",
+	}
+	MaxFeeFlag = cli.Uint64Flag{
+		Name:  "maxfee",
+		Usage: "The highest fee the validator may ever set",
+		Value: 0,
+	}
+	MaxFeeChangeFlag = cli.Uint64Flag{
+		Name:  "maxfeechange",
+		Usage: "The highest fee change the validator may make in one epoch",
+		Value: 0,
+	}
 	ImpawnFlags = []cli.Flag{
 		KeyFlag,
 		KeyStoreFlag,
@@ -69,6 +112,11 @@ var (
 		FeeFlag,
 		PubKeyKeyFlag,
 		BFTKeyKeyFlag,
+		MonikerFlag,
+		WebsiteFlag,
+		ContactFlag,
+		MaxFeeFlag,
+		MaxFeeChangeFlag,
 	}
 )
 
@@ -86,10 +134,16 @@ func init() {
 		TrueValueFlag,
 		FeeFlag,
 		AddressFlag,
+		TargetAddressFlag,
 		TxHashFlag,
 		PubKeyKeyFlag,
 		SnailNumberFlag,
 		BFTKeyKeyFlag,
+		MonikerFlag,
+		WebsiteFlag,
+		ContactFlag,
+		MaxFeeFlag,
+		MaxFeeChangeFlag,
 	}
 	app.Action = utils.MigrateFlags(impawn)
 	app.CommandNotFound = func(ctx *cli.Context, cmd string) {
@@ -101,6 +155,8 @@ func init() {
 		AppendCommand,
 		UpdateFeeCommand,
 		UpdatePKCommand,
+		SetProfileCommand,
+		SetFeePolicyCommand,
 		cancelCommand,
 		withdrawCommand,
 		queryStakingCommand,
@@ -108,6 +164,8 @@ func init() {
 		delegateCommand,
 		queryTxCommand,
 		queryRewardCommand,
+		rewardsCommand,
+		simulateCommand,
 	}
 	cli.CommandHelpTemplate = utils.OriginCommandHelpTemplate
 	sort.Sort(cli.CommandsByName(app.Commands))
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/csv"
 	"fmt"
 	"gopkg.in/urfave/cli.v1"
 	"math/big"
+	"os"
+	"strconv"
+	"time"
 	"truechain/discovery/cmd/utils"
 	"truechain/discovery/common"
 	"truechain/discovery/core/types"
+	"truechain/discovery/crypto"
 )
 
 var AppendCommand = cli.Command{
@@ -80,6 +86,62 @@ func UpdatePKImpawn(ctx *cli.Context) error {
 	return nil
 }
 
+var SetProfileCommand = cli.Command{
+	Name:   "setprofile",
+	Usage:  "Set the validator moniker, website and security contact",
+	Action: utils.MigrateFlags(SetProfileImpawn),
+	Flags:  ImpawnFlags,
+}
+
+func SetProfileImpawn(ctx *cli.Context) error {
+	loadPrivate(ctx)
+
+	conn, url := dialConn(ctx)
+	printBaseInfo(conn, url)
+
+	moniker := ctx.GlobalString(MonikerFlag.Name)
+	website := ctx.GlobalString(WebsiteFlag.Name)
+	var contactHash common.Hash
+	if contact := ctx.GlobalString(ContactFlag.Name); contact != "" {
+		contactHash = crypto.Keccak256Hash([]byte(contact))
+	}
+	fmt.Println("Moniker", moniker, " Website ", website, " ContactHash ", contactHash.Hex())
+
+	input := packInput("setProfile", moniker, website, contactHash)
+	txHash := sendContractTransaction(conn, from, types.StakingAddress, new(big.Int).SetInt64(0), priKey, input)
+
+	getResult(conn, txHash, true, false)
+	return nil
+}
+
+var SetFeePolicyCommand = cli.Command{
+	Name:   "setfeepolicy",
+	Usage:  "Limit the validator fee and the fee change per epoch, can only be set once",
+	Action: utils.MigrateFlags(SetFeePolicyImpawn),
+	Flags:  ImpawnFlags,
+}
+
+func SetFeePolicyImpawn(ctx *cli.Context) error {
+	loadPrivate(ctx)
+
+	conn, url := dialConn(ctx)
+	printBaseInfo(conn, url)
+
+	maxFee := new(big.Int).SetUint64(ctx.GlobalUint64(MaxFeeFlag.Name))
+	maxFeeChange := new(big.Int).SetUint64(ctx.GlobalUint64(MaxFeeChangeFlag.Name))
+	checkFee(maxFee)
+	if maxFeeChange.Cmp(maxFee) > 0 {
+		printError("Max fee change must not bigger than max fee")
+	}
+	fmt.Println("MaxFee", maxFee, " MaxFeeChange ", maxFeeChange)
+
+	input := packInput("setFeePolicy", maxFee, maxFeeChange)
+	txHash := sendContractTransaction(conn, from, types.StakingAddress, new(big.Int).SetInt64(0), priKey, input)
+
+	getResult(conn, txHash, true, false)
+	return nil
+}
+
 var cancelCommand = cli.Command{
 	Name:   "cancel",
 	Usage:  "Call this staking will cancelled at the next epoch",
@@ -166,6 +228,143 @@ func queryRewardImpawn(ctx *cli.Context) error {
 	return nil
 }
 
+var rewardsCommand = cli.Command{
+	Name:   "rewards",
+	Usage:  "Query or export the staking reward history, split by validator",
+	Action: utils.MigrateFlags(rewardsImpawn),
+	Flags:  append(ImpawnFlags, AddressFlag, FromSnailFlag, ToSnailFlag, CSVFlag),
+}
+
+func rewardsImpawn(ctx *cli.Context) error {
+	var account common.Address
+	if address := ctx.GlobalString(AddressFlag.Name); address != "" {
+		if !common.IsHexAddress(address) {
+			printError("Must input correct address")
+		}
+		account = common.HexToAddress(address)
+	} else {
+		loadPrivate(ctx)
+		account = from
+	}
+	conn, url := dialConn(ctx)
+	printBaseInfo(conn, url)
+
+	first := new(big.Int).SetUint64(ctx.GlobalUint64(FromSnailFlag.Name))
+	var last *big.Int
+	if ctx.GlobalIsSet(ToSnailFlag.Name) {
+		last = new(big.Int).SetUint64(ctx.GlobalUint64(ToSnailFlag.Name))
+	}
+	var rewards []*types.RewardLedgerEntry
+	for {
+		history, err := conn.GetRewardHistory(context.Background(), account, first, last, len(rewards), rewardsPageSize)
+		if err != nil {
+			printError("get reward history error", err)
+		}
+		rewards = append(rewards, history.Rewards...)
+		if len(history.Rewards) == 0 || len(rewards) >= history.Total {
+			break
+		}
+	}
+
+	if path := ctx.GlobalString(CSVFlag.Name); path != "" {
+		exportRewards(path, rewards)
+		fmt.Println("Export", len(rewards), "rewards of", account.Hex(), "to", path)
+		return nil
+	}
+	total := big.NewInt(0)
+	for _, r := range rewards {
+		for _, v := range r.Items {
+			fmt.Println("Snail number", r.SnailNumber, "validator", v.Validator.Hex(), "reward", types.ToTrue(v.Amount), "true", "staking", types.ToTrue(v.Staking), "true")
+			total.Add(total, v.Amount)
+		}
+	}
+	fmt.Println("Rewards", len(rewards), "total", types.ToTrue(total), "true")
+	return nil
+}
+
+var simulateCommand = cli.Command{
+	Name:   "simulate",
+	Usage:  "Simulate the next committee election with the value appended or delegated to the target",
+	Action: utils.MigrateFlags(simulateImpawn),
+	Flags:  append(ImpawnFlags, AddressFlag, TargetAddressFlag),
+}
+
+func simulateImpawn(ctx *cli.Context) error {
+	var account common.Address
+	if address := ctx.GlobalString(AddressFlag.Name); address != "" {
+		if !common.IsHexAddress(address) {
+			printError("Must input correct address")
+		}
+		account = common.HexToAddress(address)
+	} else {
+		loadPrivate(ctx)
+		account = from
+	}
+	conn, url := dialConn(ctx)
+	printBaseInfo(conn, url)
+
+	target := account
+	var stakes []*types.ElectionStake
+	if value := trueToWei(ctx, true); value.Sign() > 0 {
+		stake := &types.ElectionStake{Address: account, Amount: value}
+		if address := ctx.GlobalString(TargetAddressFlag.Name); address != "" {
+			if !common.IsHexAddress(address) {
+				printError("Must input correct target address")
+			}
+			target = common.HexToAddress(address)
+			stake.Validator = &target
+		}
+		stakes = append(stakes, stake)
+	}
+	res, err := conn.SimulateElection(context.Background(), stakes, target, nil)
+	if err != nil {
+		printError("simulate election error", err)
+	}
+
+	fmt.Println("Election epoch", res.EpochID, "staking counted at", res.Height, "candidates", res.Candidates)
+	for _, c := range res.Committee {
+		fmt.Println("Rank", c.Rank, "address", c.Address.Hex(), "staking", types.ToTrue(c.ValidStaking), "true")
+	}
+	fmt.Println("Min stake to take a seat", types.ToTrue(res.MinStake), "true")
+	if c := res.Target; c != nil {
+		switch {
+		case c.Excluded:
+			fmt.Println("Address", c.Address.Hex(), "is excluded from the election for slashing")
+		case c.Elected:
+			fmt.Println("Address", c.Address.Hex(), "elected rank", c.Rank, "staking", types.ToTrue(c.ValidStaking), "true")
+		default:
+			fmt.Println("Address", c.Address.Hex(), "not elected rank", c.Rank, "staking", types.ToTrue(c.ValidStaking), "true", "needs", types.ToTrue(c.Shortfall), "true more")
+		}
+	}
+	return nil
+}
+
+func exportRewards(path string, rewards []*types.RewardLedgerEntry) {
+	f, err := os.Create(path)
+	if err != nil {
+		printError("create csv file error", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"snail_number", "time", "validator", "amount_wei", "staking_wei"})
+	for _, r := range rewards {
+		for _, v := range r.Items {
+			w.Write([]string{
+				strconv.FormatUint(r.SnailNumber, 10),
+				time.Unix(int64(r.Time), 0).UTC().Format(time.RFC3339),
+				v.Validator.Hex(),
+				v.Amount.String(),
+				v.Staking.String(),
+			})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		printError("write csv file error", err)
+	}
+}
+
 var sendCommand = cli.Command{
 	Name:   "send",
 	Usage:  "Send general transaction",
@@ -218,6 +417,7 @@ var delegateCommand = cli.Command{
 		depositDCommand,
 		cancelDCommand,
 		withdrawDCommand,
+		redelegateDCommand,
 	},
 }
 
@@ -285,6 +485,34 @@ func withdrawDImpawn(ctx *cli.Context) error {
 	return nil
 }
 
+var redelegateDCommand = cli.Command{
+	Name:   "redelegate",
+	Usage:  "Move the delegate to another validator address at the next epoch",
+	Action: utils.MigrateFlags(redelegateDImpawn),
+	Flags:  append(ImpawnFlags, AddressFlag, TargetAddressFlag),
+}
+
+func redelegateDImpawn(ctx *cli.Context) error {
+	loadPrivate(ctx)
+	conn, url := dialConn(ctx)
+	printBaseInfo(conn, url)
+
+	value := trueToWei(ctx, false)
+
+	address := ctx.GlobalString(AddressFlag.Name)
+	to := ctx.GlobalString(TargetAddressFlag.Name)
+	if !common.IsHexAddress(address) || !common.IsHexAddress(to) {
+		printError("Must input correct address")
+	}
+	holder = common.HexToAddress(address)
+
+	input := packInput("redelegate", holder, common.HexToAddress(to), value)
+	txHash := sendContractTransaction(conn, from, types.StakingAddress, new(big.Int).SetInt64(0), priKey, input)
+
+	getResult(conn, txHash, true, true)
+	return nil
+}
+
 var queryTxCommand = cli.Command{
 	Name:   "querytx",
 	Usage:  "Query tx hash, get transaction result",
@@ -56,6 +56,7 @@ import (
 	"truechain/discovery/les"
 	"truechain/discovery/log"
 	"truechain/discovery/metrics"
+	"truechain/discovery/metrics/exp"
 	"truechain/discovery/metrics/influxdb"
 	"truechain/discovery/node"
 	"truechain/discovery/p2p"
@@ -204,6 +205,10 @@ var (
 		Name:  "bftkeyhex",
 		Usage: "committee generate bft_privatekey as hex (for testing)",
 	}
+	BftRemoteSignerFlag = cli.StringFlag{
+		Name:  "bftsigner",
+		Usage: "committee remote signer address (tcp://host:port or unix:///path) signing the votes",
+	}
 
 	defaultSyncMode = etrue.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
@@ -220,6 +225,11 @@ var (
 		Name:  "stategc",
 		Usage: "Delete block body and receipt",
 	}
+	PruneBlocksFlag = cli.Uint64Flag{
+		Name:  "prune.blocks",
+		Usage: "Number of the latest fast blocks whose state is kept when pruning",
+		Value: 128,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -230,6 +240,10 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: etrue.DefaultConfig.LightPeers,
 	}
+	LightCommitteeFlag = cli.BoolFlag{
+		Name:  "lightcommittee",
+		Usage: "Verify the light client fast headers against the committee signs instead of trusted checkpoints",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -299,6 +313,16 @@ var (
 		Usage: "Maximum number of non-executable transaction slots for all accounts",
 		Value: etrue.DefaultConfig.TxPool.GlobalQueue,
 	}
+	TxPoolSenderSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.senderslots",
+		Usage: "Maximum number of transaction slots, executable or not, permitted per account",
+		Value: etrue.DefaultConfig.TxPool.SenderSlots,
+	}
+	TxPoolStakingSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.stakingslots",
+		Usage: "Number of transaction slots reserved for calls to the staking contract (0 = no lane)",
+		Value: etrue.DefaultConfig.TxPool.StakingSlots,
+	}
 	TxPoolLifetimeFlag = cli.DurationFlag{
 		Name:  "txpool.lifetime",
 		Usage: "Maximum amount of time non-executable transaction are queued",
@@ -320,6 +344,11 @@ var (
 		Usage: "Maximum amount of fruits in fruitPending",
 		Value: snailchain.DefaultSnailPoolConfig.FruitCount,
 	}
+	SnailPoolDroppedCountFlag = cli.Uint64Flag{
+		Name:  "fruitpool.droppedcount",
+		Usage: "Number of the latest dropped fruits to keep the drop reasons of",
+		Value: snailchain.DefaultSnailPoolConfig.DroppedCount,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -361,6 +390,14 @@ var (
 		Usage: "Number of CPU threads to use for mining",
 		Value: runtime.NumCPU() - 1,
 	}
+	StratumAddrFlag = cli.StringFlag{
+		Name:  "stratum.addr",
+		Usage: "Stratum server listening address for the remote miners (implies --remote)",
+	}
+	StratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "stratum.difficulty",
+		Usage: "Share difficulty of the stratum server (0 = fruit difficulty)",
+	}
 
 	GasTargetFlag = cli.Uint64Flag{
 		Name:  "gastarget",
@@ -409,6 +446,12 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	defaultExecutionMode = etrue.DefaultConfig.ExecutionMode
+	ExecutionModeFlag    = TextMarshalerFlag{
+		Name:  "vm.execution",
+		Usage: `Block execution mode ("parallel", "sequential" or "shadow" to cross check both)`,
+		Value: &defaultExecutionMode,
+	}
 	// Logging and debug settings
 	EtrueStatsURLFlag = cli.StringFlag{
 		Name:  "etruestats",
@@ -574,6 +617,20 @@ var (
 		Name:  metrics.MetricsEnabledFlag,
 		Usage: "Enable metrics collection and reporting",
 	}
+	// MetricsHTTPFlag defines the endpoint for a stand-alone metrics HTTP endpoint.
+	// Since the pprof service enables sensitive/vulnerable behavior, this allows a user
+	// to enable a public-OK metrics endpoint without having to worry about ALSO exposing
+	// other profiling behavior or information.
+	MetricsHTTPFlag = cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "Enable stand-alone metrics HTTP server listening interface",
+		Value: "",
+	}
+	MetricsPortFlag = cli.IntFlag{
+		Name:  "metrics.port",
+		Usage: "Metrics HTTP server listening port",
+		Value: 6061,
+	}
 	MetricsEnableInfluxDBFlag = cli.BoolFlag{
 		Name:  "metrics.influxdb",
 		Usage: "Enable metrics export/push to an external InfluxDB database",
@@ -1044,6 +1101,12 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolGlobalQueueFlag.Name) {
 		cfg.GlobalQueue = ctx.GlobalUint64(TxPoolGlobalQueueFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolSenderSlotsFlag.Name) {
+		cfg.SenderSlots = ctx.GlobalUint64(TxPoolSenderSlotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolStakingSlotsFlag.Name) {
+		cfg.StakingSlots = ctx.GlobalUint64(TxPoolStakingSlotsFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
@@ -1063,6 +1126,9 @@ func setSnailPool(ctx *cli.Context, cfg *snailchain.SnailPoolConfig) {
 	if ctx.GlobalIsSet(SnailPoolFruitCountFlag.Name) {
 		cfg.FruitCount = ctx.GlobalUint64(SnailPoolFruitCountFlag.Name)
 	}
+	if ctx.GlobalIsSet(SnailPoolDroppedCountFlag.Name) {
+		cfg.DroppedCount = ctx.GlobalUint64(SnailPoolDroppedCountFlag.Name)
+	}
 
 }
 
@@ -1130,6 +1196,9 @@ func SetTruechainConfig(ctx *cli.Context, stack *node.Node, cfg *etrue.Config) {
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightCommitteeFlag.Name) {
+		cfg.LightCommittee = ctx.GlobalBool(LightCommitteeFlag.Name)
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1143,6 +1212,13 @@ func SetTruechainConfig(ctx *cli.Context, stack *node.Node, cfg *etrue.Config) {
 	if ctx.GlobalBool(MiningRemoteEnableFlag.Name) {
 		cfg.RemoteMine = true
 	}
+	if ctx.GlobalIsSet(StratumAddrFlag.Name) {
+		cfg.StratumAddr = ctx.GlobalString(StratumAddrFlag.Name)
+		cfg.RemoteMine = true
+	}
+	if ctx.GlobalIsSet(StratumDifficultyFlag.Name) {
+		cfg.StratumDifficulty = ctx.GlobalUint64(StratumDifficultyFlag.Name)
+	}
 	if ctx.GlobalBool(SingleNodeFlag.Name) {
 		cfg.NodeType = true
 	}
@@ -1155,6 +1231,10 @@ func SetTruechainConfig(ctx *cli.Context, stack *node.Node, cfg *etrue.Config) {
 	if ctx.GlobalIsSet(BFTStandbyPortFlag.Name) {
 		cfg.StandbyPort = int(ctx.GlobalUint64(BFTStandbyPortFlag.Name))
 	}
+	if ctx.GlobalIsSet(BftRemoteSignerFlag.Name) {
+		cfg.RemoteSigner = ctx.GlobalString(BftRemoteSignerFlag.Name)
+		cfg.RemoteSignerKey = stack.Config().NodeKey()
+	}
 
 	//set PrivateKey by config,file or hex
 	setBftCommitteeKey(ctx, cfg)
@@ -1229,6 +1309,9 @@ func SetTruechainConfig(ctx *cli.Context, stack *node.Node, cfg *etrue.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExecutionModeFlag.Name) {
+		cfg.ExecutionMode = *GlobalTextMarshaler(ctx, ExecutionModeFlag.Name).(*vm.ExecutionMode)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1333,6 +1416,11 @@ func SetupMetrics(ctx *cli.Context) {
 				"host": hosttag,
 			})
 		}
+		if ctx.GlobalIsSet(MetricsHTTPFlag.Name) {
+			address := fmt.Sprintf("%s:%d", ctx.GlobalString(MetricsHTTPFlag.Name), ctx.GlobalInt(MetricsPortFlag.Name))
+			log.Info("Enabling stand-alone metrics HTTP endpoint", "address", address)
+			exp.Setup(address)
+		}
 	}
 }
 
@@ -1405,6 +1493,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (fchain *core.BlockChain, sch
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
+	if ctx.GlobalIsSet(ExecutionModeFlag.Name) {
+		vmcfg.ExecutionMode = *GlobalTextMarshaler(ctx, ExecutionModeFlag.Name).(*vm.ExecutionMode)
+	}
 
 	fchain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg)
 	schain, err = snailchain.NewSnailBlockChain(chainDb, config, engine, fchain)
@@ -129,6 +129,10 @@ type Engine interface {
 
 	VerifySwitchInfo(fastnumber *big.Int, info []*types.CommitteeMember) error
 
+	// VerifyEvidences checks the equivocation evidences included in the fast
+	// block of the given number.
+	VerifyEvidences(fastnumber *big.Int, evidences []*types.DuplicateVoteEvidence) error
+
 	// Prepare initializes the consensus fields of a block header according to the
 	// rules of a particular engine. The changes are executed inline.
 	Prepare(chain ChainReader, header *types.Header) error
@@ -140,7 +144,7 @@ type Engine interface {
 	// Note: The block header and state database might be updated to reflect any
 	// consensus rules that happen at finalization (e.g. block rewards).
 	Finalize(chain ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction,
-		receipts []*types.Receipt, feeAmount *big.Int, newBlock bool) (*types.Block, *types.ChainReward, error)
+		receipts []*types.Receipt, evidences []*types.DuplicateVoteEvidence, feeAmount *big.Int, newBlock bool) (*types.Block, *types.ChainReward, error)
 	FinalizeSnail(chain SnailChainReader, header *types.SnailHeader,
 		uncles []*types.SnailHeader, fruits []*types.SnailBlock, signs []*types.PbftSign) (*types.SnailBlock, error)
 
@@ -72,4 +72,28 @@ var (
 
 	//ErrFruitTime is returned if the fruit's time less than fastblock's time
 	ErrFruitTime = errors.New("invalid fruit time")
+
+	// ErrInvalidEvidence is returned if a fast block carries evidences
+	// before the staking fork.
+	ErrInvalidEvidence = errors.New("invalid evidence")
+
+	// ErrTooManyEvidences is returned if a fast block carries more evidences
+	// than allowed.
+	ErrTooManyEvidences = errors.New("too many evidences")
+
+	// ErrEvidenceTooOld is returned if the offence of an evidence is older
+	// than MaxEvidenceAge.
+	ErrEvidenceTooOld = errors.New("evidence too old")
+
+	// ErrEvidenceFuture is returned if the offence of an evidence is not
+	// below the fast block including it.
+	ErrEvidenceFuture = errors.New("evidence from the future")
+
+	// ErrEvidenceMember is returned if the offender of an evidence was not a
+	// committee member at the height of the offence.
+	ErrEvidenceMember = errors.New("evidence offender not a committee member")
+
+	// ErrDuplicateEvidence is returned if a fast block carries the same
+	// offence twice.
+	ErrDuplicateEvidence = errors.New("duplicate evidence")
 )
@@ -541,6 +541,47 @@ func (m *Minerva) VerifySwitchInfo(fastnumber *big.Int, info []*types.CommitteeM
 
 }
 
+// VerifyEvidences checks the double sign evidences included in the fast block,
+// every evidence must be valid and prove an offence of a committee member in
+// the recent MaxEvidenceAge blocks.
+func (m *Minerva) VerifyEvidences(fastnumber *big.Int, evidences []*types.DuplicateVoteEvidence) error {
+	if len(evidences) > params.MaxEvidencesPerBlock {
+		return consensus.ErrTooManyEvidences
+	}
+	offences := make(map[string]bool)
+	for _, ev := range evidences {
+		pubkey, height, err := ev.Verify()
+		if err != nil {
+			log.Warn("VerifyEvidences invalid evidence", "number", fastnumber, "err", err)
+			return err
+		}
+		if height >= fastnumber.Uint64() {
+			return consensus.ErrEvidenceFuture
+		}
+		if fastnumber.Uint64()-height > params.MaxEvidenceAge {
+			return consensus.ErrEvidenceTooOld
+		}
+		key := fmt.Sprintf("%x-%d", pubkey, height)
+		if offences[key] {
+			return consensus.ErrDuplicateEvidence
+		}
+		offences[key] = true
+
+		member := false
+		for _, v := range m.election.GetCommittee(new(big.Int).SetUint64(height)) {
+			if bytes.Equal(v.Publickey, pubkey) {
+				member = true
+				break
+			}
+		}
+		if !member {
+			log.Warn("VerifyEvidences offender not a member", "number", fastnumber, "height", height, "member", hex.EncodeToString(pubkey))
+			return consensus.ErrEvidenceMember
+		}
+	}
+	return nil
+}
+
 //VerifyFreshness the fruit have fresh is 17 blocks
 func (m *Minerva) VerifyFreshness(chain consensus.SnailChainReader, fruit *types.SnailHeader, headerNumber *big.Int, canonical bool) error {
 	// check freshness
@@ -748,6 +789,28 @@ func (m *Minerva) VerifySnailSeal(chain consensus.SnailChainReader, header *type
 	return nil
 }
 
+// VerifySnailDigest recomputes the PoW of the header and checks its mix digest,
+// the result is returned to be compared with the targets of the work, e.g.
+// the share target of a mining pool.
+func (m *Minerva) VerifySnailDigest(header *types.SnailHeader) ([]byte, error) {
+	// If we're running a fake PoW, every seal meets the targets
+	if m.config.PowMode == ModeFake || m.config.PowMode == ModeFullFake {
+		return make([]byte, 32), nil
+	}
+	if m.shared != nil {
+		return m.shared.VerifySnailDigest(header)
+	}
+	dataset := m.getDataset(header.Number.Uint64())
+	if dataset == nil {
+		return nil, errors.New("get dataset is nil")
+	}
+	digest, result := truehashLight(dataset.dataset, header.HashNoNonce().Bytes(), header.Nonce.Uint64())
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		return nil, errInvalidMixDigest
+	}
+	return result, nil
+}
+
 // VerifySnailSeal2 implements consensus.Engine, checking whether the given block satisfies
 // the PoW difficulty requirements.
 func (m *Minerva) VerifySnailSeal2(hight *big.Int, nonce string, headNoNoncehash string, ftarg *big.Int, btarg *big.Int, haveFruits bool) (bool, bool, []byte) {
@@ -853,7 +916,7 @@ func (m *Minerva) PrepareSnailWithParent(fastchain consensus.ChainReader, chain
 // Finalize implements consensus.Engine, accumulating the block fruit and uncle rewards,
 // setting the final state and assembling the block.
 func (m *Minerva) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB,
-	txs []*types.Transaction, receipts []*types.Receipt, feeAmount *big.Int, newBlock bool) (*types.Block, *types.ChainReward, error) {
+	txs []*types.Transaction, receipts []*types.Receipt, evidences []*types.DuplicateVoteEvidence, feeAmount *big.Int, newBlock bool) (*types.Block, *types.ChainReward, error) {
 
 	consensus.OnceInitImpawnState(chain.Config(), state, new(big.Int).Set(header.Number))
 	if chain.Config().TIP10.FastNumber.Uint64() == header.Number.Uint64() {
@@ -901,6 +964,9 @@ func (m *Minerva) Finalize(chain consensus.ChainReader, header *types.Header, st
 	if err := m.finalizeFastGas(state, header.Number, header.Hash(), feeAmount); err != nil {
 		return nil, nil, err
 	}
+	if err := m.finalizeEvidences(chain, state, header.Number, evidences); err != nil {
+		return nil, nil, err
+	}
 
 	if err := m.finalizeValidators(chain, state, header.Number); err != nil {
 		return nil, nil, err
@@ -908,7 +974,10 @@ func (m *Minerva) Finalize(chain consensus.ChainReader, header *types.Header, st
 	header.Root = state.IntermediateRoot(true)
 
 	if newBlock {
-		return types.NewBlock(header, txs, receipts, nil, nil), infos, nil
+		header.EvidenceHash = types.CalcEvidenceHash(evidences)
+		block := types.NewBlock(header, txs, receipts, nil, nil)
+		block.SetEvidences(evidences)
+		return block, infos, nil
 	}
 	return nil, nil, nil
 }
@@ -946,6 +1015,37 @@ func (m *Minerva) finalizeFastGas(state *state.StateDB, fastNumber *big.Int, fas
 	return nil
 }
 
+// finalizeEvidences slashes the offenders of the double sign evidences, the
+// slashed amount is burned from the locked balance of the stakers.
+func (m *Minerva) finalizeEvidences(chain consensus.ChainReader, state *state.StateDB, fastNumber *big.Int, evidences []*types.DuplicateVoteEvidence) error {
+	if len(evidences) == 0 {
+		return nil
+	}
+	if !chain.Config().IsTIPSlash(fastNumber) {
+		return consensus.ErrInvalidEvidence
+	}
+	i := vm.NewImpawnImpl()
+	if err := i.Load(state, types.StakingAddress); err != nil {
+		return err
+	}
+	for _, ev := range evidences {
+		pubkey, height, err := ev.Verify()
+		if err != nil {
+			return err
+		}
+		amounts, err := i.Slash(height, pubkey)
+		if err != nil {
+			return err
+		}
+		for addr, amount := range amounts {
+			state.SubBalance(addr, amount)
+			state.SetPOSLocked(addr, new(big.Int).Sub(state.GetPOSLocked(addr), amount))
+			LogPrint("double sign slash", addr, amount)
+		}
+	}
+	return i.Save(state, types.StakingAddress)
+}
+
 // gas allocation
 func (m *Minerva) finalizeValidators(chain consensus.ChainReader, state *state.StateDB, fastNumber *big.Int) error {
 
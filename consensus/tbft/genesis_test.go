@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -100,17 +101,20 @@ func GetCommittee(cnt int) []map[string]interface{} {
 }
 
 func TestOutGenesisJson(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbft-genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
 
-	b, _ := ioutil.ReadFile("E:\\truechain\\src\\github.com\\truechain\\truechain-engineering-code\\cmd\\getrue\\genesis.json")
 	out := make(map[string]interface{})
-	json.Unmarshal(b, &out)
 	for i := 1; i <= 52; i++ {
 		out["committee"] = GetCommittee(i)
 		bytes, _ := json.Marshal(out)
 		bytes = []byte(strings.Replace(string(bytes), "9e+22", "90000000000000000000000", -1))
-		e := ioutil.WriteFile("E:\\truechain\\src\\github.com\\truechain\\truechain-engineering-code\\cmd\\getrue\\"+fmt.Sprintf("genesis_%s.json", strconv.Itoa(i)), bytes, os.ModePerm)
-		if e != nil {
-			fmt.Println(string(bytes))
+		name := filepath.Join(dir, fmt.Sprintf("genesis_%s.json", strconv.Itoa(i)))
+		if err := ioutil.WriteFile(name, bytes, 0644); err != nil {
+			t.Fatal(err)
 		}
 	}
 }
@@ -63,10 +63,19 @@ func (n *nodeInfo) toString() string {
 }
 
 func newNodeService(p2pcfg *cfg.P2PConfig, cscfg *cfg.ConsensusConfig, state *ttypes.StateAgentImpl,
-	store *ttypes.BlockStore, cid uint64) *service {
+	store *ttypes.BlockStore, cid uint64) (*service, error) {
+	sw, err := tp2p.NewSwitch(p2pcfg, state)
+	if err != nil {
+		return nil, err
+	}
+	var options []CSOption
+	// every committee keeps its own WAL under the node datadir
+	if cscfg.RootDir != "" {
+		options = append(options, WithWALFile(cscfg.CommitteeWalFile(cid)))
+	}
 	return &service{
-		sw:             tp2p.NewSwitch(p2pcfg, state),
-		consensusState: NewConsensusState(cscfg, state, store),
+		sw:             sw,
+		consensusState: NewConsensusState(cscfg, state, store, options...),
 		// nodeTable:      make(map[p2p.ID]*nodeInfo),
 		lock:       new(sync.Mutex),
 		updateChan: make(chan bool, 2),
@@ -76,7 +85,7 @@ func newNodeService(p2pcfg *cfg.P2PConfig, cscfg *cfg.ConsensusConfig, state *tt
 		addrBook:  pex.NewAddrBook(p2pcfg.AddrBookFile(), p2pcfg.AddrBookStrict),
 		healthMgr: ttypes.NewHealthMgr(cid),
 		singleCon: 0,
-	}
+	}, nil
 }
 
 func (s *service) nodesHaveSelf() bool {
@@ -123,7 +132,31 @@ func (s *service) start(cid *big.Int, node *Node) error {
 		log.New("p2p", "self"))
 	s.sw.AddListener(l)
 
-	privValidator := ttypes.NewPrivValidator(*node.priv)
+	var privValidator ttypes.PrivValidator
+	if node.signer != nil {
+		// the remote signer keeps the last signed state itself
+		pv, err := node.signer.PrivValidator(cid.Uint64())
+		if err != nil {
+			return err
+		}
+		if !pv.GetPubKey().Equals(node.nodekey.PubKey()) {
+			return fmt.Errorf("signer key %v isn't the committee key", pv.GetPubKey())
+		}
+		privValidator = pv
+	} else if cscfg := node.config.Consensus; cscfg.RootDir != "" {
+		// keep the last signed state of every committee under the node datadir,
+		// so a restart can't make us sign a conflicting vote
+		if err := help.EnsureDir(cscfg.PrivValidatorStateDir(), 0700); err != nil {
+			return err
+		}
+		pv, err := ttypes.NewPrivValidatorWithState(*node.priv, cscfg.CommitteePrivValidatorStateFile(cid.Uint64()))
+		if err != nil {
+			return err
+		}
+		privValidator = pv
+	} else {
+		privValidator = ttypes.NewPrivValidator(*node.priv)
+	}
 	s.consensusState.SetPrivValidator(privValidator)
 	s.sa.SetPrivValidator(privValidator)
 	// Start the switch (the P2P server).
@@ -321,7 +354,8 @@ type Node struct {
 	// configt
 	config *cfg.TbftConfig
 	Agent  types.PbftAgentProxy
-	priv   *ecdsa.PrivateKey // local node's validator key
+	priv   *ecdsa.PrivateKey // local node's validator key, nil if signer is set
+	signer ttypes.Signer     // holds the validator key instead of priv if set
 
 	// services
 	services   map[uint64]*service
@@ -332,7 +366,8 @@ type Node struct {
 	servicePre uint64
 }
 
-// NewNode returns a new, ready to go, truechain Node.
+// NewNode returns a new, ready to go, truechain Node. The validator key priv
+// may be nil if the node is given a signer holding it.
 func NewNode(config *cfg.TbftConfig, chainID string, priv *ecdsa.PrivateKey,
 	agent types.PbftAgentProxy) (*Node, error) {
 
@@ -352,16 +387,35 @@ func NewNode(config *cfg.TbftConfig, chainID string, priv *ecdsa.PrivateKey,
 		Agent:    agent,
 		lock:     new(sync.Mutex),
 		services: make(map[uint64]*service),
-		nodekey: tp2p.NodeKey{
-			PrivKey: tcrypto.PrivKeyTrue(*priv),
-		},
+	}
+	if priv != nil {
+		node.nodekey = tp2p.NodeKey{PrivKey: tcrypto.PrivKeyTrue(*priv)}
 	}
 	node.BaseService = *help.NewBaseService("Node", node)
 	return node, nil
 }
 
+// SetSigner makes the committees sign their proposals, votes and connection
+// handshakes with signer, the node then runs without a local validator key.
+// It must be called before the node is started.
+func (n *Node) SetSigner(signer ttypes.Signer) {
+	n.signer = signer
+}
+
 // OnStart starts the Node. It implements help.Service.
 func (n *Node) OnStart() error {
+	if n.signer != nil {
+		key, err := n.signer.HandshakeKey()
+		if err != nil {
+			return err
+		}
+		if n.priv != nil && !key.PubKey().Equals(tcrypto.PubKeyTrue(n.priv.PublicKey)) {
+			return fmt.Errorf("signer key %v isn't the committee key", key.PubKey())
+		}
+		n.nodekey = tp2p.NodeKey{PrivKey: key}
+	} else if n.priv == nil {
+		return errors.New("no validator key nor signer")
+	}
 	n.nodeinfo = n.makeNodeInfo()
 	help.BeginWatchMgr()
 	return nil
@@ -479,7 +533,10 @@ func (n *Node) PutCommittee(committeeInfo *types.CommitteeInfo) error {
 	}
 
 	store := ttypes.NewBlockStore()
-	service := newNodeService(n.config.P2P, n.config.Consensus, state, store, cid)
+	service, err := newNodeService(n.config.P2P, n.config.Consensus, state, store, cid)
+	if err != nil {
+		return err
+	}
 
 	if len(committeeInfo.Members) < cfg.MinimumCommitteeNumber {
 		return fmt.Errorf("members len is error :want big to %d get %d", cfg.MinimumCommitteeNumber, len(committeeInfo.Members))
@@ -0,0 +1,303 @@
+package privval
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	tcrypto "truechain/discovery/consensus/tbft/crypto"
+	"truechain/discovery/consensus/tbft/help"
+	tmconn "truechain/discovery/consensus/tbft/tp2p/conn"
+	ttypes "truechain/discovery/consensus/tbft/types"
+	ctypes "truechain/discovery/core/types"
+	"truechain/discovery/log"
+)
+
+const (
+	defaultDialTimeout = 3 * time.Second
+	defaultCallTimeout = 5 * time.Second
+
+	// maxRemoteSignerMsgSize is the max size of a message to and from the remote signer
+	maxRemoteSignerMsgSize = 1024 * 10
+)
+
+var (
+	// ErrUnexpectedResponse is returned when the remote signer answers with the wrong message
+	ErrUnexpectedResponse = errors.New("remote signer: unexpected response")
+
+	// ErrSignerKeyChanged is returned when the remote signer proves another key than before
+	ErrSignerKeyChanged = errors.New("remote signer: committee key changed")
+
+	// errHandshakeOnly is returned when the remote node key is used to sign anything but a handshake
+	errHandshakeOnly = errors.New("remote signer: the node key only signs the connection handshakes")
+)
+
+// SignerClient dials the remote signer which holds the committee key, it
+// hands out the PrivValidator of every committee, signs the PbftSign of the
+// fast blocks and the tbft connection handshakes. The connection is a secret
+// connection authenticated by the node key, the signer proves the committee
+// key. A broken connection is dialed again on the next request.
+type SignerClient struct {
+	protocol string
+	address  string
+	key      *ecdsa.PrivateKey
+
+	mtx    sync.Mutex
+	conn   net.Conn
+	pubKey tcrypto.PubKey
+}
+
+// NewSignerClient returns a client of the remote signer listening on addr,
+// either tcp://host:port or unix:///path/to/socket. The signer must allow
+// the node key.
+func NewSignerClient(addr string, key *ecdsa.PrivateKey) *SignerClient {
+	protocol, address := help.ProtocolAndAddress(addr)
+	return &SignerClient{
+		protocol: protocol,
+		address:  address,
+		key:      key,
+	}
+}
+
+// Close closes the connection to the remote signer.
+func (sc *SignerClient) Close() error {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	if sc.conn == nil {
+		return nil
+	}
+	err := sc.conn.Close()
+	sc.conn = nil
+	return err
+}
+
+// PubKey returns the committee key proven by the remote signer.
+func (sc *SignerClient) PubKey() (tcrypto.PubKey, error) {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+	if sc.pubKey == nil {
+		if err := sc.dial(); err != nil {
+			return nil, err
+		}
+	}
+	return sc.pubKey, nil
+}
+
+// PrivValidator returns the PrivValidator signing for the committee cid.
+// Implements ttypes.Signer.
+func (sc *SignerClient) PrivValidator(cid uint64) (ttypes.PrivValidator, error) {
+	pubKey, err := sc.PubKey()
+	if err != nil {
+		return nil, err
+	}
+	return &remotePrivValidator{
+		client: sc,
+		cid:    cid,
+		pubKey: pubKey,
+	}, nil
+}
+
+// HandshakeKey returns the committee key of the remote signer as the node key
+// of the tbft connections. It signs the handshakes only.
+// Implements ttypes.Signer.
+func (sc *SignerClient) HandshakeKey() (tcrypto.PrivKey, error) {
+	pubKey, err := sc.PubKey()
+	if err != nil {
+		return nil, err
+	}
+	return &remoteNodeKey{client: sc, pubKey: pubKey}, nil
+}
+
+// SignPbftSign sets the signature of the PbftSign of a fast block.
+func (sc *SignerClient) SignPbftSign(sign *ctypes.PbftSign) error {
+	if sign.FastHeight == nil || !sign.FastHeight.IsUint64() {
+		return fmt.Errorf("remote signer: invalid fast height %v", sign.FastHeight)
+	}
+	res, err := sc.call(&SignPbftSignRequest{
+		FastHeight: sign.FastHeight.Uint64(),
+		FastHash:   sign.FastHash.Bytes(),
+		Result:     sign.Result,
+	})
+	if err != nil {
+		return err
+	}
+	resp, ok := res.(*SignedPbftSignResponse)
+	if !ok {
+		return ErrUnexpectedResponse
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	sign.Sign = resp.Signature
+	return nil
+}
+
+// call sends the request and waits for the response. The request is sent once
+// more on a fresh connection if a reused one turns out to be broken, signing is
+// idempotent on the signer side as the same sign bytes get the last signature.
+func (sc *SignerClient) call(req RemoteSignerMsg) (RemoteSignerMsg, error) {
+	sc.mtx.Lock()
+	defer sc.mtx.Unlock()
+
+	reused := sc.conn != nil
+	res, err := sc.roundTrip(req)
+	if err != nil && reused {
+		log.Debug("Remote signer connection broken, redial", "addr", sc.address, "err", err)
+		res, err = sc.roundTrip(req)
+	}
+	return res, err
+}
+
+// dial connects to the remote signer and checks it still holds the same key.
+func (sc *SignerClient) dial() error {
+	conn, err := net.DialTimeout(sc.protocol, sc.address, defaultDialTimeout)
+	if err != nil {
+		return fmt.Errorf("remote signer: dial %v: %v", sc.address, err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(defaultCallTimeout)); err != nil {
+		conn.Close()
+		return fmt.Errorf("remote signer: %v", err)
+	}
+	secret, err := tmconn.MakeSecretConnection(conn, tcrypto.PrivKeyTrue(*sc.key))
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("remote signer: handshake %v: %v", sc.address, err)
+	}
+	if sc.pubKey != nil && !sc.pubKey.Equals(secret.RemotePubKey()) {
+		conn.Close()
+		return ErrSignerKeyChanged
+	}
+	sc.conn, sc.pubKey = secret, secret.RemotePubKey()
+	return nil
+}
+
+func (sc *SignerClient) roundTrip(req RemoteSignerMsg) (RemoteSignerMsg, error) {
+	if sc.conn == nil {
+		if err := sc.dial(); err != nil {
+			return nil, err
+		}
+	}
+	fail := func(err error) (RemoteSignerMsg, error) {
+		sc.conn.Close()
+		sc.conn = nil
+		return nil, fmt.Errorf("remote signer: %v", err)
+	}
+	if err := sc.conn.SetDeadline(time.Now().Add(defaultCallTimeout)); err != nil {
+		return fail(err)
+	}
+	if _, err := cdc.MarshalBinaryWriter(sc.conn, req); err != nil {
+		return fail(err)
+	}
+	var res RemoteSignerMsg
+	if _, err := cdc.UnmarshalBinaryReader(sc.conn, &res, maxRemoteSignerMsgSize); err != nil {
+		return fail(err)
+	}
+	return res, nil
+}
+
+// remotePrivValidator signs the proposals and votes of a committee with the
+// remote signer, which also guards them against double signing.
+type remotePrivValidator struct {
+	client *SignerClient
+	cid    uint64
+	pubKey tcrypto.PubKey
+}
+
+func (pv *remotePrivValidator) GetAddress() help.Address {
+	return pv.pubKey.Address()
+}
+
+func (pv *remotePrivValidator) GetPubKey() tcrypto.PubKey {
+	return pv.pubKey
+}
+
+// SignVote implements PrivValidator.
+func (pv *remotePrivValidator) SignVote(chainID string, vote *ttypes.Vote) error {
+	res, err := pv.client.call(&SignVoteRequest{CommitteeID: pv.cid, ChainID: chainID, Vote: vote})
+	if err != nil {
+		return err
+	}
+	resp, ok := res.(*SignedVoteResponse)
+	if !ok {
+		return ErrUnexpectedResponse
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.Vote == nil || !pv.pubKey.VerifyBytes(vote.SignBytes(chainID), resp.Vote.Signature) {
+		return errors.New("remote signer: invalid vote signature")
+	}
+	vote.Signature = resp.Vote.Signature
+	return nil
+}
+
+// SignProposal implements PrivValidator.
+func (pv *remotePrivValidator) SignProposal(chainID string, proposal *ttypes.Proposal) error {
+	res, err := pv.client.call(&SignProposalRequest{CommitteeID: pv.cid, ChainID: chainID, Proposal: proposal})
+	if err != nil {
+		return err
+	}
+	resp, ok := res.(*SignedProposalResponse)
+	if !ok {
+		return ErrUnexpectedResponse
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.Proposal == nil || !pv.pubKey.VerifyBytes(proposal.SignBytes(chainID), resp.Proposal.Signature) {
+		return errors.New("remote signer: invalid proposal signature")
+	}
+	proposal.Signature = resp.Proposal.Signature
+	return nil
+}
+
+// remoteNodeKey is the committee key held by the remote signer used as the
+// node key of the tbft connections.
+type remoteNodeKey struct {
+	client *SignerClient
+	pubKey tcrypto.PubKey
+}
+
+func (k *remoteNodeKey) Bytes() []byte { return k.pubKey.Bytes() }
+
+func (k *remoteNodeKey) PubKey() tcrypto.PubKey { return k.pubKey }
+
+// Sign refuses to sign, the remote signer only signs the handshakes.
+func (k *remoteNodeKey) Sign(msg []byte) ([]byte, error) { return nil, errHandshakeOnly }
+
+func (k *remoteNodeKey) Equals(other tcrypto.PrivKey) bool {
+	o, ok := other.(*remoteNodeKey)
+	return ok && k.pubKey.Equals(o.pubKey)
+}
+
+// SignSecretChallenge implements tmconn.HandshakeSigner.
+func (k *remoteNodeKey) SignSecretChallenge(dhSecret *[32]byte, locIsLeast bool) ([]byte, error) {
+	return k.signHandshake(&SignSecretChallengeRequest{DHSecret: dhSecret[:], LocIsLeast: locIsLeast},
+		tmconn.SecretChallenge(dhSecret, locIsLeast)[:])
+}
+
+// SignNoiseStaticKey implements tmconn.HandshakeSigner.
+func (k *remoteNodeKey) SignNoiseStaticKey(static *[32]byte) ([]byte, error) {
+	return k.signHandshake(&SignNoiseStaticKeyRequest{StaticKey: static[:]}, tmconn.NoiseStaticKeyHash(static)[:])
+}
+
+func (k *remoteNodeKey) signHandshake(req RemoteSignerMsg, hash []byte) ([]byte, error) {
+	res, err := k.client.call(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, ok := res.(*SignedHandshakeResponse)
+	if !ok {
+		return nil, ErrUnexpectedResponse
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if !k.pubKey.VerifyBytes(hash, resp.Signature) {
+		return nil, errors.New("remote signer: invalid handshake signature")
+	}
+	return resp.Signature, nil
+}
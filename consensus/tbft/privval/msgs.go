@@ -0,0 +1,101 @@
+package privval
+
+import (
+	"fmt"
+
+	"github.com/tendermint/go-amino"
+	ttypes "truechain/discovery/consensus/tbft/types"
+)
+
+var cdc = amino.NewCodec()
+
+func init() {
+	RegisterRemoteSignerMsg(cdc)
+	ttypes.RegisterBlockAmino(cdc)
+}
+
+// RemoteSignerMsg is sent between the node and the remote signer.
+type RemoteSignerMsg interface{}
+
+// RegisterRemoteSignerMsg registers the remote signer messages in the given codec.
+func RegisterRemoteSignerMsg(cdc *amino.Codec) {
+	cdc.RegisterInterface((*RemoteSignerMsg)(nil), nil)
+	cdc.RegisterConcrete(&SignVoteRequest{}, "true/remotesigner/SignVoteRequest", nil)
+	cdc.RegisterConcrete(&SignedVoteResponse{}, "true/remotesigner/SignedVoteResponse", nil)
+	cdc.RegisterConcrete(&SignProposalRequest{}, "true/remotesigner/SignProposalRequest", nil)
+	cdc.RegisterConcrete(&SignedProposalResponse{}, "true/remotesigner/SignedProposalResponse", nil)
+	cdc.RegisterConcrete(&SignPbftSignRequest{}, "true/remotesigner/SignPbftSignRequest", nil)
+	cdc.RegisterConcrete(&SignedPbftSignResponse{}, "true/remotesigner/SignedPbftSignResponse", nil)
+	cdc.RegisterConcrete(&SignSecretChallengeRequest{}, "true/remotesigner/SignSecretChallengeRequest", nil)
+	cdc.RegisterConcrete(&SignNoiseStaticKeyRequest{}, "true/remotesigner/SignNoiseStaticKeyRequest", nil)
+	cdc.RegisterConcrete(&SignedHandshakeResponse{}, "true/remotesigner/SignedHandshakeResponse", nil)
+}
+
+// SignVoteRequest is a request to sign a vote of the committee.
+type SignVoteRequest struct {
+	CommitteeID uint64
+	ChainID     string
+	Vote        *ttypes.Vote
+}
+
+// SignedVoteResponse is the vote signed by the remote signer.
+type SignedVoteResponse struct {
+	Vote  *ttypes.Vote
+	Error *RemoteSignerError
+}
+
+// SignProposalRequest is a request to sign a proposal of the committee.
+type SignProposalRequest struct {
+	CommitteeID uint64
+	ChainID     string
+	Proposal    *ttypes.Proposal
+}
+
+// SignedProposalResponse is the proposal signed by the remote signer.
+type SignedProposalResponse struct {
+	Proposal *ttypes.Proposal
+	Error    *RemoteSignerError
+}
+
+// SignPbftSignRequest is a request to sign the PbftSign of a fast block.
+// The fields mirror types.PbftSign, whose big.Int height doesn't go over amino.
+type SignPbftSignRequest struct {
+	FastHeight uint64
+	FastHash   []byte
+	Result     uint32
+}
+
+// SignedPbftSignResponse is the signature of the PbftSign.
+type SignedPbftSignResponse struct {
+	Signature []byte
+	Error     *RemoteSignerError
+}
+
+// SignSecretChallengeRequest is a request to sign the challenge of a tbft
+// secret connection of the node, which is derived from the DH secret.
+type SignSecretChallengeRequest struct {
+	DHSecret   []byte
+	LocIsLeast bool
+}
+
+// SignNoiseStaticKeyRequest is a request to sign the static key of a tbft
+// noise connection of the node.
+type SignNoiseStaticKeyRequest struct {
+	StaticKey []byte
+}
+
+// SignedHandshakeResponse is the handshake signature of the committee key.
+type SignedHandshakeResponse struct {
+	Signature []byte
+	Error     *RemoteSignerError
+}
+
+// RemoteSignerError is returned by the remote signer when it refuses or fails
+// to sign, e.g. for a conflicting vote.
+type RemoteSignerError struct {
+	Description string
+}
+
+func (e *RemoteSignerError) Error() string {
+	return fmt.Sprintf("remote signer error: %s", e.Description)
+}
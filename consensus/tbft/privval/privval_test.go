@@ -0,0 +1,229 @@
+package privval
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"truechain/discovery/common"
+	tcrypto "truechain/discovery/consensus/tbft/crypto"
+	tmconn "truechain/discovery/consensus/tbft/tp2p/conn"
+	ttypes "truechain/discovery/consensus/tbft/types"
+	ctypes "truechain/discovery/core/types"
+	"truechain/discovery/crypto"
+)
+
+var nodeKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+
+func startSigner(t *testing.T, dir, addr string) (*SignerServer, string) {
+	key, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := NewSignerServer(key, dir, []*ecdsa.PublicKey{&nodeKey.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ss.Serve(l)
+	return ss, l.Addr().String()
+}
+
+func TestRemoteSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "privval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ss, addr := startSigner(t, dir, "127.0.0.1:0")
+	client := NewSignerClient("tcp://"+addr, nodeKey)
+	defer client.Close()
+
+	pv, err := client.PrivValidator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pv.GetAddress(), crypto.PubkeyToAddress(ss.priv.PublicKey).Bytes()) {
+		t.Fatalf("address mismatch: have %x", pv.GetAddress())
+	}
+
+	now := time.Now().UTC()
+	vote := &ttypes.Vote{Height: 10, Round: 1, Type: ttypes.VoteTypePrevote, Timestamp: now,
+		BlockID: ttypes.BlockID{Hash: []byte{1}, PartsHeader: ttypes.PartSetHeader{Total: 1, Hash: []byte{2}}}}
+	if err := pv.SignVote("1", vote); err != nil {
+		t.Fatal(err)
+	}
+	if !pv.GetPubKey().VerifyBytes(vote.SignBytes("1"), vote.Signature) {
+		t.Fatal("invalid vote signature")
+	}
+	// the same vote is signed again, a conflicting one is refused
+	again := vote.Copy()
+	again.Signature = nil
+	if err := pv.SignVote("1", again); err != nil || !bytes.Equal(again.Signature, vote.Signature) {
+		t.Fatalf("same vote not signed again: %v", err)
+	}
+	conflict := &ttypes.Vote{Height: 10, Round: 1, Type: ttypes.VoteTypePrevote, Timestamp: now,
+		BlockID: ttypes.BlockID{Hash: []byte{3}, PartsHeader: ttypes.PartSetHeader{Total: 1, Hash: []byte{2}}}}
+	if err := pv.SignVote("1", conflict); err == nil {
+		t.Fatal("conflicting vote signed")
+	}
+	// other committees are guarded on their own
+	pv2, err := client.PrivValidator(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pv2.SignVote("1", conflict); err != nil {
+		t.Fatal(err)
+	}
+
+	proposal := &ttypes.Proposal{Height: 11, Round: 0, Timestamp: now, BlockPartsHeader: ttypes.PartSetHeader{Total: 1, Hash: []byte{4}}}
+	if err := pv.SignProposal("1", proposal); err != nil {
+		t.Fatal(err)
+	}
+	if !pv.GetPubKey().VerifyBytes(proposal.SignBytes("1"), proposal.Signature) {
+		t.Fatal("invalid proposal signature")
+	}
+
+	sign := &ctypes.PbftSign{FastHeight: big.NewInt(100), FastHash: common.HexToHash("0x01"), Result: ctypes.VoteAgree}
+	if err := client.SignPbftSign(sign); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypto.SigToPub(sign.HashWithNoSign().Bytes(), sign.Sign)
+	if err != nil || crypto.PubkeyToAddress(*pub) != crypto.PubkeyToAddress(ss.priv.PublicKey) {
+		t.Fatalf("invalid PbftSign signature: %v", err)
+	}
+
+	// a restarted signer still refuses to sign below the last signed height
+	// and hands out the last signature again, the client redials
+	ss.Close()
+	ss, _ = startSigner(t, dir, addr)
+	defer ss.Close()
+	if err := pv.SignVote("1", conflict); err == nil {
+		t.Fatal("conflicting vote signed after restart")
+	}
+	resigned := *proposal
+	resigned.Signature = nil
+	if err := pv.SignProposal("1", &resigned); err != nil || !bytes.Equal(resigned.Signature, proposal.Signature) {
+		t.Fatalf("same proposal not signed again after restart: %v", err)
+	}
+}
+
+func TestRemoteSignerAuth(t *testing.T) {
+	ss, addr := startSigner(t, "", "127.0.0.1:0")
+	defer ss.Close()
+
+	other, _ := crypto.GenerateKey()
+	client := NewSignerClient("tcp://"+addr, other)
+	defer client.Close()
+	sign := &ctypes.PbftSign{FastHeight: big.NewInt(1), FastHash: common.HexToHash("0x01"), Result: ctypes.VoteAgree}
+	if err := client.SignPbftSign(sign); err == nil {
+		t.Fatal("PbftSign signed for a node which isn't allowed")
+	}
+
+	allowed := NewSignerClient("tcp://"+addr, nodeKey)
+	defer allowed.Close()
+	pub, err := allowed.PubKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Equals(tcrypto.PubKeyTrue(ss.priv.PublicKey)) {
+		t.Fatalf("signer proved another key: %v", pub)
+	}
+}
+
+func TestRemoteSignerPbftSign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "privval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ss, addr := startSigner(t, dir, "127.0.0.1:0")
+	client := NewSignerClient("tcp://"+addr, nodeKey)
+	defer client.Close()
+
+	makeSign := func(height int64, hash string, result uint32) *ctypes.PbftSign {
+		return &ctypes.PbftSign{FastHeight: big.NewInt(height), FastHash: common.HexToHash(hash), Result: result}
+	}
+	first := makeSign(100, "0x01", ctypes.VoteAgree)
+	if err := client.SignPbftSign(first); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sign *ctypes.PbftSign
+		ok   bool
+	}{
+		{makeSign(100, "0x01", ctypes.VoteAgree), true},         // the same sign is handed out again
+		{makeSign(100, "0x02", ctypes.VoteAgree), false},        // another block of the same height
+		{makeSign(100, "0x01", ctypes.VoteAgreeAgainst), false}, // another result for the same block
+		{makeSign(99, "0x03", ctypes.VoteAgree), false},         // below the last signed height
+		{makeSign(101, "0x04", ctypes.VoteAgree), true},
+	}
+	for i, tt := range tests {
+		if err := client.SignPbftSign(tt.sign); (err == nil) != tt.ok {
+			t.Errorf("test %d: signed %v, want %v: %v", i, err == nil, tt.ok, err)
+		}
+	}
+
+	// a restarted signer keeps the guard
+	ss.Close()
+	ss, _ = startSigner(t, dir, addr)
+	defer ss.Close()
+	if err := client.SignPbftSign(makeSign(100, "0x01", ctypes.VoteAgree)); err == nil {
+		t.Fatal("PbftSign below the last signed height signed after restart")
+	}
+	again := makeSign(101, "0x04", ctypes.VoteAgree)
+	if err := client.SignPbftSign(again); err != nil || !bytes.Equal(again.Sign, tests[4].sign.Sign) {
+		t.Fatalf("same PbftSign not signed again after restart: %v", err)
+	}
+}
+
+func TestRemoteSignerHandshake(t *testing.T) {
+	ss, addr := startSigner(t, "", "127.0.0.1:0")
+	defer ss.Close()
+	client := NewSignerClient("tcp://"+addr, nodeKey)
+	defer client.Close()
+
+	key, err := client.HandshakeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.Sign(common.HexToHash("0x01").Bytes()); err == nil {
+		t.Fatal("remote node key signed an arbitrary hash")
+	}
+	peerKey, _ := crypto.GenerateKey()
+
+	// the committee key proven by the signer authenticates the connection
+	for _, transports := range []tmconn.TransportVersion{tmconn.TransportSecret, tmconn.TransportNoise} {
+		a, b := net.Pipe()
+		errc := make(chan error, 1)
+		go func() {
+			c, _, err := tmconn.UpgradeConnection(b, tcrypto.PrivKeyTrue(*peerKey), []tmconn.TransportVersion{transports}, false)
+			if err == nil && !c.RemotePubKey().Equals(tcrypto.PubKeyTrue(ss.priv.PublicKey)) {
+				err = ErrUnexpectedResponse
+			}
+			errc <- err
+		}()
+		c, _, err := tmconn.UpgradeConnection(a, key, []tmconn.TransportVersion{transports}, true)
+		if err != nil {
+			t.Fatalf("transport %v: %v", transports, err)
+		}
+		if err := <-errc; err != nil {
+			t.Fatalf("transport %v: remote side: %v", transports, err)
+		}
+		if !c.RemotePubKey().Equals(tcrypto.PubKeyTrue(peerKey.PublicKey)) {
+			t.Fatalf("transport %v: remote key mismatch", transports)
+		}
+		a.Close()
+		b.Close()
+	}
+}
@@ -0,0 +1,288 @@
+package privval
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"truechain/discovery/common"
+	tcrypto "truechain/discovery/consensus/tbft/crypto"
+	tmconn "truechain/discovery/consensus/tbft/tp2p/conn"
+	ttypes "truechain/discovery/consensus/tbft/types"
+	ctypes "truechain/discovery/core/types"
+	"truechain/discovery/crypto"
+	"truechain/discovery/log"
+)
+
+const (
+	// handshakeTimeout bounds the secret connection handshake of a node
+	handshakeTimeout = 5 * time.Second
+
+	// pbftSignStateFile keeps the last signed PbftSign under the state dir
+	pbftSignStateFile = "pbftsign.json"
+)
+
+var (
+	errPbftSignHeight   = errors.New("fast height below the last signed PbftSign")
+	errPbftSignConflict = errors.New("conflicting PbftSign for the last signed fast height")
+)
+
+// SignerServer holds the committee key and serves the signing requests of the
+// allowed nodes. The connections are authenticated by a secret connection,
+// the signer proves the committee key and the node one of the allowed keys.
+// The last signed height/round/step of every committee is kept in its own
+// PrivValidator, which refuses to sign a conflicting proposal or vote, the
+// last signed PbftSign guards the fast blocks the same way. If stateDir isn't
+// empty the last signed states are persisted there, so a restarted signer
+// keeps the guard.
+type SignerServer struct {
+	priv     *ecdsa.PrivateKey
+	stateDir string
+	allowed  map[string]bool // addresses of the node keys allowed to connect
+
+	mtx      sync.Mutex
+	pvs      map[uint64]ttypes.PrivValidator
+	lastSign *ttypes.PrivValidatorState // last signed PbftSign
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSignerServer returns a remote signer for the key priv serving the nodes
+// with one of the allowed keys.
+func NewSignerServer(priv *ecdsa.PrivateKey, stateDir string, allowed []*ecdsa.PublicKey) (*SignerServer, error) {
+	ss := &SignerServer{
+		priv:     priv,
+		stateDir: stateDir,
+		allowed:  make(map[string]bool),
+		pvs:      make(map[uint64]ttypes.PrivValidator),
+		lastSign: new(ttypes.PrivValidatorState),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	for _, pub := range allowed {
+		ss.allowed[string(tcrypto.PubKeyTrue(*pub).Address())] = true
+	}
+	if stateDir != "" {
+		state, err := ttypes.LoadPrivValidatorState(filepath.Join(stateDir, pbftSignStateFile))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if state != nil {
+			ss.lastSign = state
+		}
+	}
+	return ss, nil
+}
+
+// Serve accepts the connections of the nodes on the listener, it blocks until
+// the listener is closed.
+func (ss *SignerServer) Serve(l net.Listener) error {
+	ss.mtx.Lock()
+	ss.listener = l
+	ss.mtx.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		ss.mtx.Lock()
+		ss.conns[conn] = struct{}{}
+		ss.mtx.Unlock()
+
+		ss.wg.Add(1)
+		go ss.handleConn(conn)
+	}
+}
+
+// Close stops the listener and closes the connections of the nodes.
+func (ss *SignerServer) Close() error {
+	ss.mtx.Lock()
+	var err error
+	if ss.listener != nil {
+		err = ss.listener.Close()
+	}
+	for conn := range ss.conns {
+		conn.Close()
+	}
+	ss.mtx.Unlock()
+	ss.wg.Wait()
+	return err
+}
+
+func (ss *SignerServer) handleConn(conn net.Conn) {
+	defer ss.wg.Done()
+	defer func() {
+		ss.mtx.Lock()
+		delete(ss.conns, conn)
+		ss.mtx.Unlock()
+		conn.Close()
+	}()
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return
+	}
+	sc, err := tmconn.MakeSecretConnection(conn, tcrypto.PrivKeyTrue(*ss.priv))
+	if err != nil {
+		log.Warn("Remote signer handshake failed", "remote", conn.RemoteAddr(), "err", err)
+		return
+	}
+	if !ss.allowed[string(sc.RemotePubKey().Address())] {
+		log.Warn("Remote signer refused node", "remote", conn.RemoteAddr(), "address", sc.RemotePubKey().Address())
+		return
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return
+	}
+	log.Info("Remote signer connection accepted", "remote", conn.RemoteAddr(), "address", sc.RemotePubKey().Address())
+	for {
+		var req RemoteSignerMsg
+		if _, err := cdc.UnmarshalBinaryReader(sc, &req, maxRemoteSignerMsgSize); err != nil {
+			log.Debug("Remote signer connection closed", "remote", conn.RemoteAddr(), "err", err)
+			return
+		}
+		res := ss.handleRequest(req)
+		if res == nil {
+			log.Warn("Remote signer unknown request", "remote", conn.RemoteAddr(), "req", fmt.Sprintf("%T", req))
+			return
+		}
+		if _, err := cdc.MarshalBinaryWriter(sc, res); err != nil {
+			log.Debug("Remote signer write failed", "remote", conn.RemoteAddr(), "err", err)
+			return
+		}
+	}
+}
+
+func (ss *SignerServer) handleRequest(req RemoteSignerMsg) RemoteSignerMsg {
+	switch r := req.(type) {
+	case *SignVoteRequest:
+		if r.Vote == nil || !ttypes.IsVoteTypeValid(r.Vote.Type) {
+			return &SignedVoteResponse{Error: &RemoteSignerError{Description: "invalid vote"}}
+		}
+		pv, err := ss.privValidator(r.CommitteeID)
+		if err == nil {
+			err = pv.SignVote(r.ChainID, r.Vote)
+		}
+		if err != nil {
+			log.Warn("Remote signer refused vote", "cid", r.CommitteeID, "vote", r.Vote, "err", err)
+			return &SignedVoteResponse{Error: &RemoteSignerError{Description: err.Error()}}
+		}
+		return &SignedVoteResponse{Vote: r.Vote}
+
+	case *SignProposalRequest:
+		if r.Proposal == nil {
+			return &SignedProposalResponse{Error: &RemoteSignerError{Description: "invalid proposal"}}
+		}
+		pv, err := ss.privValidator(r.CommitteeID)
+		if err == nil {
+			err = pv.SignProposal(r.ChainID, r.Proposal)
+		}
+		if err != nil {
+			log.Warn("Remote signer refused proposal", "cid", r.CommitteeID, "proposal", r.Proposal, "err", err)
+			return &SignedProposalResponse{Error: &RemoteSignerError{Description: err.Error()}}
+		}
+		return &SignedProposalResponse{Proposal: r.Proposal}
+
+	case *SignPbftSignRequest:
+		sign := &ctypes.PbftSign{
+			FastHeight: new(big.Int).SetUint64(r.FastHeight),
+			FastHash:   common.BytesToHash(r.FastHash),
+			Result:     r.Result,
+		}
+		sig, err := ss.signPbftSign(sign)
+		if err != nil {
+			log.Warn("Remote signer refused PbftSign", "height", r.FastHeight, "hash", sign.FastHash, "err", err)
+			return &SignedPbftSignResponse{Error: &RemoteSignerError{Description: err.Error()}}
+		}
+		return &SignedPbftSignResponse{Signature: sig}
+
+	case *SignSecretChallengeRequest:
+		if len(r.DHSecret) != 32 {
+			return &SignedHandshakeResponse{Error: &RemoteSignerError{Description: "invalid secret"}}
+		}
+		var dhSecret [32]byte
+		copy(dhSecret[:], r.DHSecret)
+		// the challenge is derived here, the node can't have an arbitrary hash signed
+		challenge := tmconn.SecretChallenge(&dhSecret, r.LocIsLeast)
+		return ss.signHandshake(challenge[:])
+
+	case *SignNoiseStaticKeyRequest:
+		if len(r.StaticKey) != 32 {
+			return &SignedHandshakeResponse{Error: &RemoteSignerError{Description: "invalid static key"}}
+		}
+		var static [32]byte
+		copy(static[:], r.StaticKey)
+		return ss.signHandshake(tmconn.NoiseStaticKeyHash(&static)[:])
+
+	default:
+		return nil
+	}
+}
+
+func (ss *SignerServer) signHandshake(hash []byte) RemoteSignerMsg {
+	sig, err := tcrypto.PrivKeyTrue(*ss.priv).Sign(hash)
+	if err != nil {
+		return &SignedHandshakeResponse{Error: &RemoteSignerError{Description: err.Error()}}
+	}
+	return &SignedHandshakeResponse{Signature: sig}
+}
+
+// signPbftSign signs the PbftSign unless a PbftSign of a higher fast height or
+// a different one of the same height was signed before. The same PbftSign is
+// handed out again, a fast block is signed again when it's handed over between
+// committee members.
+func (ss *SignerServer) signPbftSign(sign *ctypes.PbftSign) ([]byte, error) {
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+
+	height, signBytes := sign.FastHeight.Uint64(), sign.HashWithNoSign().Bytes()
+	last := ss.lastSign
+	switch {
+	case height < last.Height:
+		return nil, errPbftSignHeight
+	case height == last.Height && last.SignBytes != nil:
+		if !bytes.Equal(signBytes, last.SignBytes) {
+			return nil, errPbftSignConflict
+		}
+		return last.Signature, nil
+	}
+	sig, err := crypto.Sign(signBytes, ss.priv)
+	if err != nil {
+		return nil, err
+	}
+	state := &ttypes.PrivValidatorState{Height: height, Signature: sig, SignBytes: signBytes}
+	if ss.stateDir != "" {
+		// the signature must not be handed out if persisting fails
+		if err := state.Save(filepath.Join(ss.stateDir, pbftSignStateFile)); err != nil {
+			return nil, fmt.Errorf("error saving PbftSign state: %v", err)
+		}
+	}
+	ss.lastSign = state
+	return sig, nil
+}
+
+// privValidator returns the PrivValidator of the committee, restoring its last
+// signed state the first time it's used.
+func (ss *SignerServer) privValidator(cid uint64) (ttypes.PrivValidator, error) {
+	ss.mtx.Lock()
+	defer ss.mtx.Unlock()
+	if pv, ok := ss.pvs[cid]; ok {
+		return pv, nil
+	}
+	pv := ttypes.NewPrivValidator(*ss.priv)
+	if ss.stateDir != "" {
+		var err error
+		pv, err = ttypes.NewPrivValidatorWithState(*ss.priv, filepath.Join(ss.stateDir, strconv.FormatUint(cid, 10)+".json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	ss.pvs[cid] = pv
+	return pv, nil
+}
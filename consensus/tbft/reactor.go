@@ -10,6 +10,7 @@ import (
 	"truechain/discovery/consensus/tbft/help"
 	"truechain/discovery/consensus/tbft/tp2p"
 	ttypes "truechain/discovery/consensus/tbft/types"
+	"truechain/discovery/core/types"
 	"truechain/discovery/log"
 )
 
@@ -267,6 +268,8 @@ func (conR *ConsensusReactor) Receive(chID byte, src tp2p.Peer, msgBytes []byte)
 				conR.Switch.MarkPeerAsGood(src)
 			}
 			cs.peerMsgQueue <- msgInfo{msg, string(src.ID())}
+		case *EvidenceMessage:
+			conR.conS.peerMsgQueue <- msgInfo{msg, string(src.ID())}
 		default:
 			// don't punish (leave room for soft upgrades)
 			log.Debug(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
@@ -340,6 +343,12 @@ func (conR *ConsensusReactor) subscribeToBroadcastEvents() {
 		func(data ttypes.EventData) {
 			conR.broadcastHasVoteMessage(data.(*ttypes.Vote))
 		})
+
+	conR.conS.evsw.AddListenerForEvent(subscriber, ttypes.EventEvidence,
+		func(data ttypes.EventData) {
+			msg := &EvidenceMessage{data.(*types.DuplicateVoteEvidence)}
+			conR.Switch.Broadcast(VoteChannel, cdc.MustMarshalBinaryBare(msg))
+		})
 }
 
 func (conR *ConsensusReactor) unsubscribeFromBroadcastEvents() {
@@ -1289,6 +1298,7 @@ func RegisterConsensusMessages(cdc *amino.Codec) {
 	cdc.RegisterConcrete(&VoteSetMaj23Message{}, "true/VoteSetMaj23", nil)
 	cdc.RegisterConcrete(&VoteSetBitsMessage{}, "true/VoteSetBits", nil)
 	cdc.RegisterConcrete(&ValidatorUpdateMessage{}, "true/ValidatorSet", nil)
+	cdc.RegisterConcrete(&EvidenceMessage{}, "true/Evidence", nil)
 }
 
 func decodeMsg(bz []byte) (msg ConsensusMessage, err error) {
@@ -1400,6 +1410,18 @@ func (m *VoteMessage) String() string {
 
 //-------------------------------------
 
+// EvidenceMessage is sent when a validator signed conflicting votes.
+type EvidenceMessage struct {
+	Evidence *types.DuplicateVoteEvidence
+}
+
+// String returns a string representation.
+func (m *EvidenceMessage) String() string {
+	return fmt.Sprintf("[Evidence %v]", m.Evidence)
+}
+
+//-------------------------------------
+
 // HasVoteMessage is sent to indicate that a particular vote has been received.
 type HasVoteMessage struct {
 	Height uint64
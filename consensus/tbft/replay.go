@@ -0,0 +1,109 @@
+package tbft
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"truechain/discovery/log"
+)
+
+//-----------------------------------------
+// Recover from failure during consensus
+// by replaying messages from the WAL
+//-----------------------------------------
+
+// Apply a single message to the consensus state as if it were
+// received in receiveRoutine. EndHeightMessages are ignored.
+// NOTE: receiveRoutine should not be running.
+func (cs *ConsensusState) readReplayMessage(msg *TimedWALMessage) error {
+	// Skip meta messages which exist for demarcating boundaries.
+	if _, ok := msg.Msg.(EndHeightMessage); ok {
+		return nil
+	}
+
+	switch m := msg.Msg.(type) {
+	case msgInfo:
+		peerID := m.PeerID
+		if peerID == "" {
+			peerID = "local"
+		}
+		log.Trace("Replay: msgInfo", "peer", peerID, "type", reflect.TypeOf(m.Msg))
+		cs.handleMsg(m)
+	case timeoutInfo:
+		log.Trace("Replay: Timeout", "height", m.Height, "round", m.Round, "step", m.Step, "dur", m.Duration)
+		cs.handleTimeout(m, cs.RoundState)
+	default:
+		return fmt.Errorf("replay: Unknown TimedWALMessage type: %v", reflect.TypeOf(msg.Msg))
+	}
+	return nil
+}
+
+// Replay only those messages since the last block.  `timeoutRoutine` should
+// run concurrently to read off tickChan.
+func (cs *ConsensusState) catchupReplay(csHeight uint64) error {
+
+	// Set replayMode to true so we don't sign anything twice.
+	cs.replayMode = true
+	defer func() { cs.replayMode = false }()
+
+	// Ensure that #ENDHEIGHT for this height doesn't exist.
+	// NOTE: This is just a sanity check. The agent may not have inserted
+	// the block yet, it will be synced from the other members.
+	gr, found, err := cs.wal.SearchForEndHeight(csHeight, &WALSearchOptions{IgnoreDataCorruptionErrors: true})
+	if err != nil {
+		return err
+	}
+	if gr != nil {
+		if err := gr.Close(); err != nil {
+			return err
+		}
+	}
+	if found {
+		log.Warn("WAL already contains #ENDHEIGHT, skip replay", "height", csHeight)
+		return nil
+	}
+
+	// Search for last height marker.
+	//
+	// Ignore data corruption errors in previous heights because we only care about last height
+	gr, found, err = cs.wal.SearchForEndHeight(csHeight-1, &WALSearchOptions{IgnoreDataCorruptionErrors: true})
+	if err == io.EOF {
+		log.Error("Replay: wal.group.Search returned EOF", "#ENDHEIGHT", csHeight-1)
+	} else if err != nil {
+		return err
+	}
+	if !found {
+		// the committee starts here or the height was synced from the chain,
+		// there is nothing of this height to replay
+		log.Info("Replay: WAL does not contain the last height, nothing to replay", "#ENDHEIGHT", csHeight-1)
+		cs.wal.WriteSync(EndHeightMessage{csHeight - 1})
+		return nil
+	}
+	defer gr.Close() // nolint: errcheck
+
+	log.Info("Catchup by replaying consensus messages", "height", csHeight)
+
+	var msg *TimedWALMessage
+	dec := NewWALDecoder(gr)
+
+	for {
+		msg, err = dec.Decode()
+		if err == io.EOF {
+			break
+		} else if IsDataCorruptionError(err) {
+			log.Error("data has been corrupted in last height of consensus WAL", "err", err, "height", csHeight)
+			return err
+		} else if err != nil {
+			return err
+		}
+
+		// NOTE: nothing is signed in replayMode, our own proposal and
+		// votes of this height are part of the replayed messages
+		if err := cs.readReplayMessage(msg); err != nil {
+			return err
+		}
+	}
+	log.Info("Replay: Done", "height", cs.Height, "round", cs.Round, "step", cs.Step)
+	return nil
+}
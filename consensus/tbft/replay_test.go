@@ -0,0 +1,101 @@
+package tbft
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tcrypto "truechain/discovery/consensus/tbft/crypto"
+	ttypes "truechain/discovery/consensus/tbft/types"
+	config "truechain/discovery/params"
+)
+
+// newReplayState builds a ConsensusState of 4 validators waiting for height.
+func newReplayState(t *testing.T, height uint64) (*ConsensusState, []ttypes.PrivValidator, *ttypes.ValidatorSet) {
+	IDCacheInit()
+	IDCache["ReplayAgent"] = new(big.Int).SetUint64(height)
+
+	privs := make([]ttypes.PrivValidator, 0, 4)
+	vals := make([]*ttypes.Validator, 0, 4)
+	for i := 0; i < 4; i++ {
+		priv := getPrivateKey(i)
+		privs = append(privs, ttypes.NewPrivValidator(*priv))
+		vals = append(vals, ttypes.NewValidator(tcrypto.PubKeyTrue(*GetPubKey(priv)), 1))
+	}
+	vset := ttypes.NewValidatorSet(vals)
+	state := ttypes.NewStateAgent(NewPbftAgent("ReplayAgent"), "9999", vset, 1, 1)
+	state.SetPrivValidator(privs[0])
+
+	cs := NewConsensusState(config.TestConsensusConfig(), state, ttypes.NewBlockStore())
+	cs.SetPrivValidator(privs[0])
+	if cs.Height != height {
+		t.Fatalf("consensus height mismatch: have %d, want %d", cs.Height, height)
+	}
+	return cs, privs, vset
+}
+
+func TestCatchupReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbft-replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cs, privs, vset := newReplayState(t, 3)
+	wal, err := NewWAL(filepath.Join(dir, "wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Stop()
+
+	// height 2 was finished, the node crashed after two prevotes of height 3
+	for _, priv := range privs[:3] {
+		vote, err := signVote(priv, vset, 2, "9999", 0, ttypes.VoteTypePrevote, nil, ttypes.PartSetHeader{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wal.Write(msgInfo{Msg: &VoteMessage{vote}, PeerID: "peer"})
+	}
+	wal.WriteSync(EndHeightMessage{2})
+	for _, priv := range privs[1:3] {
+		vote, err := signVote(priv, vset, 3, "9999", 0, ttypes.VoteTypePrevote, nil, ttypes.PartSetHeader{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wal.WriteSync(msgInfo{Msg: &VoteMessage{vote}, PeerID: "peer"})
+	}
+	cs.SetWAL(wal)
+
+	if err := cs.catchupReplay(cs.Height); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if cs.replayMode {
+		t.Fatal("replay mode left on")
+	}
+	if cs.Height != 3 || cs.Round != 0 {
+		t.Fatalf("replayed round mismatch: have %d/%d, want 3/0", cs.Height, cs.Round)
+	}
+	prevotes := cs.Votes.Prevotes(0)
+	for i, priv := range privs {
+		have := prevotes.GetByAddress(priv.GetAddress()) != nil
+		if want := i == 1 || i == 2; have != want {
+			t.Errorf("validator %d prevote replayed: have %v, want %v", i, have, want)
+		}
+	}
+
+	// once the height is finished there is nothing to replay anymore
+	wal.WriteSync(EndHeightMessage{3})
+	cs2, _, _ := newReplayState(t, 3)
+	cs2.SetWAL(wal)
+	if err := cs2.catchupReplay(cs2.Height); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if prevotes := cs2.Votes.Prevotes(0); prevotes.HasTwoThirdsAny() || prevotes.GetByIndex(1) != nil {
+		t.Fatal("finished height was replayed")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"truechain/discovery/consensus/tbft/metrics"
 	ttypes "truechain/discovery/consensus/tbft/types"
 	"truechain/discovery/core/types"
+	"truechain/discovery/crypto"
 	"truechain/discovery/log"
 	cfg "truechain/discovery/params"
 )
@@ -29,6 +30,8 @@ var (
 	ErrAddingVote = errors.New("error adding vote")
 	//ErrVoteHeightMismatch is Error vote height mismatch
 	ErrVoteHeightMismatch = errors.New("error vote height mismatch")
+	//ErrEvidenceNotValidator is Error evidence offender not a validator
+	ErrEvidenceNotValidator = errors.New("error evidence offender not a validator")
 )
 
 //-----------------------------------------------------------------------------
@@ -88,6 +91,13 @@ type ConsensusState struct {
 	// and to notify external subscribers, eg. through a websocket
 	eventBus *ttypes.EventBus
 
+	// a Write-Ahead Log ensures we can recover from any kind of crash
+	// and helps us avoid signing conflicting votes
+	wal          WAL
+	walFile      string
+	replayMode   bool // set while the WAL is replayed on start
+	doWALCatchup bool // determines if we even try to do the catchup
+
 	// for tests where we want to limit the number of transitions the state makes
 	nSteps int
 
@@ -128,6 +138,8 @@ func NewConsensusState(
 		state:            state,
 		evsw:             ttypes.NewEventSwitch(),
 		svs:              make([]*ttypes.SwitchValidator, 0, 0),
+		wal:              nilWAL{},
+		doWALCatchup:     true,
 	}
 	// set function defaults (may be overwritten before calling Start)
 	cs.decideProposal = cs.defaultDecideProposal
@@ -147,6 +159,12 @@ func NewConsensusState(
 	return cs
 }
 
+// WithWALFile is an option that records the consensus messages of the
+// ConsensusState into the WAL at walFile, replaying them on start.
+func WithWALFile(walFile string) CSOption {
+	return func(cs *ConsensusState) { cs.walFile = walFile }
+}
+
 //----------------------------------------
 // Public interface
 
@@ -236,6 +254,26 @@ func (cs *ConsensusState) OnStart() error {
 		return err
 	}
 	cs.updateToState(cs.state)
+
+	// we may set the WAL in testing before calling Start,
+	// so only OpenWAL if its still the nilWAL
+	if _, ok := cs.wal.(nilWAL); ok && cs.walFile != "" {
+		wal, err := cs.OpenWAL(cs.walFile)
+		if err != nil {
+			log.Error("Error loading ConsensusState wal", "err", err)
+			return err
+		}
+		cs.wal = wal
+	}
+
+	if cs.doWALCatchup {
+		if err := cs.catchupReplay(cs.Height); err != nil {
+			log.Error("Error on catchup replay. Proceeding to start ConsensusState anyway", "err", err)
+			// NOTE: if we ever do return an error here,
+			// make sure to stop the timeoutTicker
+		}
+	}
+
 	// now start the receiveRoutine
 	go cs.receiveRoutine(0)
 
@@ -267,6 +305,7 @@ func (cs *ConsensusState) OnStop() {
 	help.CheckAndPrintError(cs.evsw.Stop())
 	help.CheckAndPrintError(cs.timeoutTicker.Stop())
 	help.CheckAndPrintError(cs.timeoutTask.Stop())
+	// WAL is stopped in receiveRoutine.
 	log.Info("End ConsensusState finish")
 }
 
@@ -277,6 +316,26 @@ func (cs *ConsensusState) Wait() {
 	<-cs.done
 }
 
+// OpenWAL opens a file to log all consensus messages and timeouts for deterministic accountability
+func (cs *ConsensusState) OpenWAL(walFile string) (WAL, error) {
+	wal, err := NewWAL(walFile)
+	if err != nil {
+		log.Error("Failed to open WAL for consensus state", "wal", walFile, "err", err)
+		return nil, err
+	}
+	if err := wal.Start(); err != nil {
+		return nil, err
+	}
+	return wal, nil
+}
+
+// SetWAL sets the WAL, it may be useful to overwrite for testing.
+func (cs *ConsensusState) SetWAL(wal WAL) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+	cs.wal = wal
+}
+
 //------------------------------------------------------------
 // Public interface for passing messages into the consensus state, possibly causing a state transition.
 // If peerID == "", the msg is considered internal.
@@ -379,8 +438,8 @@ func (cs *ConsensusState) updateStateForSync() {
 	newH := cs.state.GetLastBlockHeight() + 1
 	if oldH != newH {
 		cs.updateToState(cs.state)
-		log.Debug("Reset privValidator", "height", cs.Height)
-		cs.state.PrivReset()
+		// the height was synced from the chain, new messages are replayed from here
+		cs.wal.WriteSync(EndHeightMessage{cs.Height - 1})
 		sleepDuration := time.Duration(1) * time.Millisecond
 		cs.timeoutTicker.ScheduleTimeout(timeoutInfo{sleepDuration, cs.Height, uint(0), ttypes.RoundStepNewHeight, 1})
 	}
@@ -496,22 +555,28 @@ func (cs *ConsensusState) newStep() {
 func (cs *ConsensusState) validatorUpdate(msg *ValidatorUpdateMessage) {
 	log.Trace("ValidatorUpdate", "uHeight", msg.uHeight, "eHeight", msg.eHeight, "cHeight", cs.Height, "Round", cs.Round)
 	round, oldHeight := cs.Round, cs.Height
+	changed := !sameValidators(cs.Validators, msg.vset)
 	//clear svs
 	if len(cs.svs) > 0 {
 		cs.svs = append(cs.svs[:0], cs.svs[1:]...)
 	}
 	help.CheckAndPrintError(cs.state.UpdateValidator(msg.vset, true))
 	cs.updateToState(cs.state)
-	cs.state.PrivReset()
 	cs.state.SetEndHeight(msg.eHeight)
 	cs.state.SetBeginHeight(msg.uHeight)
 	newHeight := cs.Height
+	if newHeight != oldHeight {
+		cs.wal.WriteSync(EndHeightMessage{newHeight - 1})
+	}
 
-	if newHeight == oldHeight && round > 0 {
+	// a changed committee never goes back to a round of this height we may
+	// have signed in already, the privValidator refuses to sign a conflicting
+	// vote for it. An unchanged committee stays in its round.
+	if newHeight != oldHeight {
+		round = 0
+	} else if changed {
 		log.Trace("ValidatorUpdate,has same height in current consensus", "oldHeight", oldHeight, "newHeight", newHeight)
 		round = round + 1
-	} else {
-		round = 0
 	}
 	var d = cs.taskTimeOut
 	cs.timeoutTask.ScheduleTimeout(timeoutInfo{d, newHeight, round, ttypes.RoundStepBlockSync, 0})
@@ -519,6 +584,23 @@ func (cs *ConsensusState) validatorUpdate(msg *ValidatorUpdateMessage) {
 	cs.enterNewRound(newHeight, int(round))
 }
 
+// sameValidators reports whether both sets hold the same validators with the
+// same voting power, the accum of the proposer rotation is ignored.
+func sameValidators(a, b *ttypes.ValidatorSet) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Size() != b.Size() {
+		return false
+	}
+	for i := range a.Validators {
+		if !bytes.Equal(a.Validators[i].Hash(), b.Validators[i].Hash()) {
+			return false
+		}
+	}
+	return true
+}
+
 //-----------------------------------------
 // the main go routines
 
@@ -532,6 +614,11 @@ func (cs *ConsensusState) receiveRoutine(maxSteps int) {
 		// NOTE: the internalMsgQueue may have signed messages from our
 		// priv_val that haven't hit the WAL, but its ok because
 		// priv_val tracks LastSig
+
+		// close wal now that we're done writing to it
+		help.CheckAndPrintError(cs.wal.Stop())
+		cs.wal.Wait()
+
 		log.Debug("Exit receiveRoutine")
 		close(cs.done)
 	}
@@ -564,13 +651,20 @@ func (cs *ConsensusState) receiveRoutine(maxSteps int) {
 
 		select {
 		case mi = <-cs.peerMsgQueue:
+			cs.wal.Write(mi)
 			// handles proposals, block parts, votes
 			// may generate internal events (votes, complete proposals, 2/3 majorities)
 			cs.handleMsg(mi)
 		case mi = <-cs.internalMsgQueue:
+			// committee updates are rebuilt from the agent on restart,
+			// everything else we signed ourselves must hit the disk first
+			if _, ok := mi.Msg.(*ValidatorUpdateMessage); !ok {
+				cs.wal.WriteSync(mi) // NOTE: fsync
+			}
 			// handles proposals, block parts, votes
 			cs.handleMsg(mi)
 		case ti := <-cs.timeoutTicker.Chan(): // tockChan:
+			cs.wal.Write(ti)
 			// if the timeout is relevant to the rs
 			// go to the next step
 			cs.handleTimeout(ti, rs)
@@ -609,6 +703,10 @@ func (cs *ConsensusState) handleMsg(mi msgInfo) {
 			log.Trace("Received block part from wrong round", "height", cs.Height, "csRound", cs.Round, "blockRound", msg.Round)
 			err = nil
 		}
+	case *EvidenceMessage:
+		if msg.Evidence != nil {
+			cs.addEvidence(msg.Evidence)
+		}
 	case *VoteMessage:
 		// attempt to add the vote and dupeout the validator if its a duplicate signature
 		// if the vote gives us a 2/3-any or 2/3-one, we transition
@@ -767,6 +865,10 @@ func (cs *ConsensusState) tryEnterProposal(height uint64, round int, wait uint)
 	var blockParts *ttypes.PartSet
 	var err error
 
+	// our proposal of the round, if any, is replayed from the WAL
+	if cs.replayMode {
+		doing = false
+	}
 	if doing {
 		// get block
 		block, blockParts, err = cs.createProposalBlock(round)
@@ -1309,6 +1411,12 @@ func (cs *ConsensusState) finalizeCommit(height uint64) {
 		log.Debug("Error on ApplyBlock. Did the application crash? Please restart getrue", "err", err)
 		return
 	}
+
+	// Write EndHeightMessage{} for this height, implying that the block has
+	// been handed over to the agent and we don't need to replay this height
+	// again. A crash before this point replays the precommits of the height
+	// and commits the block once more.
+	cs.wal.WriteSync(EndHeightMessage{height})
 	// Save to blockStore.
 	if cs.blockStore.MaxBlockHeight() < block.NumberU64() {
 		// NOTE: the seenCommit is local justification to commit this block,
@@ -1446,17 +1554,23 @@ func (cs *ConsensusState) tryAddVote(vote *ttypes.Vote, peerID string) error {
 	_, err := cs.addVote(vote, peerID)
 	if err != nil {
 		// If the vote height is off, we'll just ignore it,
-		// But if it's a conflicting sig, add it to the cs.evpool.
+		// But if it's a conflicting sig, hand the evidence to the agent.
 		// If it's otherwise invalid, punish peer.
 		if err == ErrVoteHeightMismatch {
 			return err
 		}
-		if err == ttypes.ErrVoteConflictingVotes {
+		if conflict, ok := err.(*ttypes.ConflictingVoteError); ok {
 			if bytes.Equal(vote.ValidatorAddress, cs.privValidator.GetAddress()) {
 				log.Debug("Found conflicting vote from ourselves. Did you unsafe_reset a validator?", "height", vote.Height, "round", vote.Round, "type", vote.Type)
 				return err
 			}
 			log.Debug("Found conflicting vote.", "height", vote.Height, "round", vote.Round, "type", vote.Type)
+			ev, evErr := ttypes.NewDuplicateVoteEvidence(cs.state.GetChainID(), conflict.VoteA, conflict.VoteB)
+			if evErr != nil {
+				log.Warn("Failed to make the evidence of conflicting votes", "height", vote.Height, "err", evErr)
+				return err
+			}
+			cs.addEvidence(ev)
 			return err
 		}
 		// Probably an invalid signature / Bad peer.
@@ -1469,6 +1583,30 @@ func (cs *ConsensusState) tryAddVote(vote *ttypes.Vote, peerID string) error {
 	return nil
 }
 
+// addEvidence hands the evidence of a validator of the committee to the agent
+// and gossips it if it's new.
+func (cs *ConsensusState) addEvidence(ev *types.DuplicateVoteEvidence) error {
+	pubkey, height, err := ev.Verify()
+	if err != nil {
+		return err
+	}
+	pk, err := crypto.UnmarshalPubkey(pubkey)
+	if err != nil {
+		return err
+	}
+	addr := crypto.PubkeyToAddress(*pk)
+	if !cs.Validators.HasAddress(addr[:]) {
+		return ErrEvidenceNotValidator
+	}
+	if err := cs.state.AddEvidence(ev); err != nil {
+		log.Debug("Evidence not added", "height", height, "offender", addr, "err", err)
+		return err
+	}
+	log.Info("Add double sign evidence", "height", height, "offender", addr, "hash", ev.Hash())
+	cs.evsw.FireEvent(ttypes.EventEvidence, ev)
+	return nil
+}
+
 //-----------------------------------------------------------------------------
 
 func (cs *ConsensusState) addVote(vote *ttypes.Vote, peerID string) (added bool, err error) {
@@ -1645,6 +1783,11 @@ func (cs *ConsensusState) signAddVote(typeB byte, hash []byte, header ttypes.Par
 	if cs.privValidator == nil || !cs.Validators.HasAddress(cs.privValidator.GetAddress()) {
 		return nil
 	}
+	// our own votes are replayed from the WAL. A vote that never hit the WAL
+	// was never broadcast, so it is safe to skip it here.
+	if cs.replayMode {
+		return nil
+	}
 
 	vote, err := cs.signVote(typeB, hash, header)
 	if err == nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 	"truechain/discovery/consensus/tbft/help"
+	ttypes "truechain/discovery/consensus/tbft/types"
 )
 
 type Test struct {
@@ -32,3 +33,28 @@ func TestChanDate(t *testing.T) {
 	test.A = 2
 	fmt.Println(<-c)
 }
+
+func TestValidatorUpdateRound(t *testing.T) {
+	tests := []struct {
+		name    string
+		changed bool
+		round   uint
+	}{
+		{"unchanged committee keeps the round", false, 2},
+		{"changed committee starts a new round", true, 3},
+	}
+	for _, tt := range tests {
+		cs, _, vset := newReplayState(t, 3)
+		cs.privValidator = nil
+		cs.Round = 2
+
+		update := vset.Copy()
+		if tt.changed {
+			update = ttypes.NewValidatorSet(vset.Copy().Validators[1:])
+		}
+		cs.validatorUpdate(&ValidatorUpdateMessage{vset: update, uHeight: 1})
+		if cs.Height != 3 || cs.Round != tt.round {
+			t.Errorf("%s: have %d/%d, want 3/%d", tt.name, cs.Height, cs.Round, tt.round)
+		}
+	}
+}
@@ -109,3 +109,18 @@ func TestVoteRlpCommitteeMember(t *testing.T) {
 	c, e := rlp.EncodeToBytes(si)
 	fmt.Println(c, e)
 }
+
+func TestEvidenceMessage(t *testing.T) {
+	msg := &EvidenceMessage{Evidence: &types2.DuplicateVoteEvidence{
+		VoteA: &types2.EvidenceVote{Data: []byte(`{"@type":"vote"}`), Sign: []byte{1, 2, 3}},
+		VoteB: &types2.EvidenceVote{Data: []byte(`{"@type":"vote","round":"1"}`), Sign: []byte{4, 5, 6}},
+	}}
+	decoded, err := decodeMsg(cdc.MustMarshalBinaryBare(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, ok := decoded.(*EvidenceMessage)
+	if !ok || out.Evidence.Hash() != msg.Evidence.Hash() {
+		t.Fatalf("evidence mismatch: have %v", decoded)
+	}
+}
@@ -93,6 +93,10 @@ func (pap *PbftAgentProxyImp) GetSeedMember() []*types.CommitteeMember {
 	return nil
 }
 
+func (pap *PbftAgentProxyImp) AddEvidence(ev *types.DuplicateVoteEvidence) error {
+	return nil
+}
+
 func (pap *PbftAgentProxyImp) GenerateSignWithVote(fb *types.Block, vote uint32) (*types.PbftSign, error) {
 	voteSign := &types.PbftSign{
 		Result:     vote,
@@ -0,0 +1,513 @@
+package conn
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"io"
+	"net"
+	"time"
+	"truechain/discovery/consensus/tbft/crypto"
+	"truechain/discovery/consensus/tbft/crypto/ed25519"
+	tcrypyo "truechain/discovery/crypto"
+)
+
+// Noise_XX_25519_ChaChaPoly_SHA256 is exactly 32 bytes, it is used as the
+// initial handshake hash without hashing.
+const noiseProtocolName = "Noise_XX_25519_ChaChaPoly_SHA256"
+
+// 2 bytes length prefix, a noise message is at most 65535 bytes
+const noiseLenSize = 2
+const noiseMaxMsgSize = 65535
+const noiseMaxDataSize = noiseMaxMsgSize - aeadSizeOverhead
+
+// The node key signs the noise static key prefixed with it
+const noiseStaticKeyPrefix = "tp2p-noise-static-key:"
+
+// Identity key types carried in the handshake payload
+const (
+	noiseKeySecp256k1 = 1
+	noiseKeyEd25519   = 2
+)
+
+var (
+	errNoiseDecrypt     = errors.New("Failed to decrypt NoiseConnection")
+	errNoiseMsgTooLarge = errors.New("noise message is greater than noiseMaxMsgSize")
+	errNoiseShortMsg    = errors.New("noise handshake message too short")
+	errNoiseIdentity    = errors.New("noise identity verification failed")
+)
+
+// NoiseConnection implements net.conn.
+// It is an implementation of the Noise_XX_25519_ChaChaPoly_SHA256 handshake,
+// the static keys are ephemeral curve25519 keys signed with the node keys
+// (secp256k1 or ed25519) in the encrypted handshake payloads, so both sides
+// authenticate with their node keys like with SecretConnection.
+type NoiseConnection struct {
+	conn       io.ReadWriteCloser
+	recvBuffer []byte
+	recvCipher *noiseCipher
+	sendCipher *noiseCipher
+	remPubKey  crypto.PubKey
+}
+
+// MakeNoiseConnection performs the noise XX handshake as the initiator or the
+// responder and returns a new authenticated NoiseConnection. The prologue
+// must be the same on both sides, it is mixed in the handshake hash.
+// Returns nil if there is an error in handshake.
+// Caller should call conn.Close()
+func MakeNoiseConnection(conn io.ReadWriteCloser, locPrivKey crypto.PrivKey, initiator bool, prologue []byte) (*NoiseConnection, error) {
+	hs := newNoiseHandshake(locPrivKey, prologue)
+	var remPubKey crypto.PubKey
+	if initiator {
+		// -> e
+		if err := writeNoiseMsg(conn, hs.writeEphemeral()); err != nil {
+			return nil, err
+		}
+		// <- e, ee, s, es
+		msg, err := readNoiseMsg(conn)
+		if err != nil {
+			return nil, err
+		}
+		if remPubKey, err = hs.readResponse(msg); err != nil {
+			return nil, err
+		}
+		// -> s, se
+		if msg, err = hs.writeFinal(); err != nil {
+			return nil, err
+		}
+		if err := writeNoiseMsg(conn, msg); err != nil {
+			return nil, err
+		}
+	} else {
+		// -> e
+		msg, err := readNoiseMsg(conn)
+		if err != nil {
+			return nil, err
+		}
+		if err := hs.readEphemeral(msg); err != nil {
+			return nil, err
+		}
+		// <- e, ee, s, es
+		if msg, err = hs.writeResponse(); err != nil {
+			return nil, err
+		}
+		if err := writeNoiseMsg(conn, msg); err != nil {
+			return nil, err
+		}
+		// -> s, se
+		if msg, err = readNoiseMsg(conn); err != nil {
+			return nil, err
+		}
+		if remPubKey, err = hs.readFinal(msg); err != nil {
+			return nil, err
+		}
+	}
+	// The initiator sends with the first cipher, the responder with the second.
+	c1, c2 := hs.split()
+	nc := &NoiseConnection{
+		conn:       conn,
+		recvCipher: c2,
+		sendCipher: c1,
+		remPubKey:  remPubKey,
+	}
+	if !initiator {
+		nc.recvCipher, nc.sendCipher = c1, c2
+	}
+	return nc, nil
+}
+
+// RemotePubKey returns authenticated remote pubkey
+func (nc *NoiseConnection) RemotePubKey() crypto.PubKey {
+	return nc.remPubKey
+}
+
+// Writes a noise message for every noiseMaxDataSize bytes of data.
+func (nc *NoiseConnection) Write(data []byte) (n int, err error) {
+	for 0 < len(data) {
+		var chunk []byte
+		if noiseMaxDataSize < len(data) {
+			chunk = data[:noiseMaxDataSize]
+			data = data[noiseMaxDataSize:]
+		} else {
+			chunk = data
+			data = nil
+		}
+		sealed, err := nc.sendCipher.encrypt(nil, chunk)
+		if err != nil {
+			return n, err
+		}
+		if err = writeNoiseMsg(nc.conn, sealed); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+	}
+	return
+}
+
+// CONTRACT: data smaller than noiseMaxDataSize is read atomically.
+func (nc *NoiseConnection) Read(data []byte) (n int, err error) {
+	if 0 < len(nc.recvBuffer) {
+		n = copy(data, nc.recvBuffer)
+		nc.recvBuffer = nc.recvBuffer[n:]
+		return
+	}
+	sealed, err := readNoiseMsg(nc.conn)
+	if err != nil {
+		return
+	}
+	chunk, err := nc.recvCipher.decrypt(nil, sealed)
+	if err != nil {
+		return 0, errNoiseDecrypt
+	}
+	n = copy(data, chunk)
+	nc.recvBuffer = chunk[n:]
+	return
+}
+
+// Implements net.Conn
+// nolint
+func (nc *NoiseConnection) Close() error                  { return nc.conn.Close() }
+func (nc *NoiseConnection) LocalAddr() net.Addr           { return nc.conn.(net.Conn).LocalAddr() }
+func (nc *NoiseConnection) RemoteAddr() net.Addr          { return nc.conn.(net.Conn).RemoteAddr() }
+func (nc *NoiseConnection) SetDeadline(t time.Time) error { return nc.conn.(net.Conn).SetDeadline(t) }
+func (nc *NoiseConnection) SetReadDeadline(t time.Time) error {
+	return nc.conn.(net.Conn).SetReadDeadline(t)
+}
+func (nc *NoiseConnection) SetWriteDeadline(t time.Time) error {
+	return nc.conn.(net.Conn).SetWriteDeadline(t)
+}
+
+func writeNoiseMsg(w io.Writer, msg []byte) error {
+	if len(msg) > noiseMaxMsgSize {
+		return errNoiseMsgTooLarge
+	}
+	frame := make([]byte, noiseLenSize+len(msg))
+	binary.BigEndian.PutUint16(frame, uint16(len(msg)))
+	copy(frame[noiseLenSize:], msg)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readNoiseMsg(r io.Reader) ([]byte, error) {
+	var size [noiseLenSize]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+//--------------------------------------------------------------------------------
+
+// noiseCipher is the CipherState of the noise specification.
+type noiseCipher struct {
+	key   [aeadKeySize]byte
+	nonce uint64
+}
+
+func (c *noiseCipher) nonceBytes() []byte {
+	// 32 bits of zeros followed by the little-endian encoding of the nonce
+	var nonce [aeadNonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], c.nonce)
+	return nonce[:]
+}
+
+func (c *noiseCipher) encrypt(ad, plaintext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(c.key[:])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nil, c.nonceBytes(), plaintext, ad)
+	c.nonce++
+	return ciphertext, nil
+}
+
+func (c *noiseCipher) decrypt(ad, ciphertext []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.New(c.key[:])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, c.nonceBytes(), ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+	c.nonce++
+	return plaintext, nil
+}
+
+// noiseHandshake is the HandshakeState of the XX pattern, with its
+// SymmetricState.
+type noiseHandshake struct {
+	ck     [32]byte
+	h      [32]byte
+	cipher *noiseCipher // nil until the first key is mixed
+
+	locPrivKey crypto.PrivKey
+	sPub       *[32]byte // local static
+	sPriv      *[32]byte
+	ePub       *[32]byte // local ephemeral
+	ePriv      *[32]byte
+	rs         *[32]byte // remote static
+	re         *[32]byte // remote ephemeral
+}
+
+func newNoiseHandshake(locPrivKey crypto.PrivKey, prologue []byte) *noiseHandshake {
+	hs := &noiseHandshake{locPrivKey: locPrivKey}
+	copy(hs.h[:], noiseProtocolName)
+	hs.ck = hs.h
+	hs.mixHash(prologue)
+
+	// The static key is only bound to the node key by the signed payload.
+	hs.sPub, hs.sPriv = genEphKeys()
+	hs.ePub, hs.ePriv = genEphKeys()
+	return hs
+}
+
+func (hs *noiseHandshake) mixHash(data []byte) {
+	h := sha256.New()
+	h.Write(hs.h[:])
+	h.Write(data)
+	h.Sum(hs.h[:0])
+}
+
+func (hs *noiseHandshake) mixKey(ikm *[32]byte) {
+	var key [32]byte
+	hs.ck, key = noiseHKDF(hs.ck[:], ikm[:])
+	hs.cipher = &noiseCipher{key: key}
+}
+
+func (hs *noiseHandshake) mixDH(priv, pub *[32]byte) error {
+	// X25519 rejects the low order points giving an all zero shared secret.
+	secret, err := curve25519.X25519(priv[:], pub[:])
+	if err != nil {
+		return err
+	}
+	var ikm [32]byte
+	copy(ikm[:], secret)
+	hs.mixKey(&ikm)
+	return nil
+}
+
+func (hs *noiseHandshake) encryptAndHash(plaintext []byte) []byte {
+	ciphertext := plaintext
+	if hs.cipher != nil {
+		// The key and nonce are valid, encryption can't fail.
+		ciphertext, _ = hs.cipher.encrypt(hs.h[:], plaintext)
+	}
+	hs.mixHash(ciphertext)
+	return ciphertext
+}
+
+func (hs *noiseHandshake) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext := ciphertext
+	if hs.cipher != nil {
+		var err error
+		if plaintext, err = hs.cipher.decrypt(hs.h[:], ciphertext); err != nil {
+			return nil, errNoiseDecrypt
+		}
+	}
+	hs.mixHash(ciphertext)
+	return plaintext, nil
+}
+
+func (hs *noiseHandshake) split() (c1, c2 *noiseCipher) {
+	k1, k2 := noiseHKDF(hs.ck[:], nil)
+	return &noiseCipher{key: k1}, &noiseCipher{key: k2}
+}
+
+// -> e
+func (hs *noiseHandshake) writeEphemeral() []byte {
+	hs.mixHash(hs.ePub[:])
+	return append(hs.ePub[:], hs.encryptAndHash(nil)...)
+}
+
+// -> e
+func (hs *noiseHandshake) readEphemeral(msg []byte) error {
+	if len(msg) != 32 {
+		return errNoiseShortMsg
+	}
+	hs.re = new([32]byte)
+	copy(hs.re[:], msg)
+	hs.mixHash(hs.re[:])
+	_, err := hs.decryptAndHash(msg[32:])
+	return err
+}
+
+// <- e, ee, s, es
+func (hs *noiseHandshake) writeResponse() ([]byte, error) {
+	msg := append([]byte{}, hs.ePub[:]...)
+	hs.mixHash(hs.ePub[:])
+	if err := hs.mixDH(hs.ePriv, hs.re); err != nil {
+		return nil, err
+	}
+	msg = append(msg, hs.encryptAndHash(hs.sPub[:])...)
+	if err := hs.mixDH(hs.sPriv, hs.re); err != nil {
+		return nil, err
+	}
+	payload, err := hs.signPayload()
+	if err != nil {
+		return nil, err
+	}
+	return append(msg, hs.encryptAndHash(payload)...), nil
+}
+
+// <- e, ee, s, es
+func (hs *noiseHandshake) readResponse(msg []byte) (crypto.PubKey, error) {
+	if len(msg) < 32+32+aeadSizeOverhead {
+		return nil, errNoiseShortMsg
+	}
+	hs.re = new([32]byte)
+	copy(hs.re[:], msg[:32])
+	hs.mixHash(hs.re[:])
+	if err := hs.mixDH(hs.ePriv, hs.re); err != nil {
+		return nil, err
+	}
+	rs, err := hs.decryptAndHash(msg[32 : 32+32+aeadSizeOverhead])
+	if err != nil {
+		return nil, err
+	}
+	hs.rs = new([32]byte)
+	copy(hs.rs[:], rs)
+	if err := hs.mixDH(hs.ePriv, hs.rs); err != nil {
+		return nil, err
+	}
+	payload, err := hs.decryptAndHash(msg[32+32+aeadSizeOverhead:])
+	if err != nil {
+		return nil, err
+	}
+	return hs.verifyPayload(payload)
+}
+
+// -> s, se
+func (hs *noiseHandshake) writeFinal() ([]byte, error) {
+	msg := hs.encryptAndHash(hs.sPub[:])
+	if err := hs.mixDH(hs.sPriv, hs.re); err != nil {
+		return nil, err
+	}
+	payload, err := hs.signPayload()
+	if err != nil {
+		return nil, err
+	}
+	return append(msg, hs.encryptAndHash(payload)...), nil
+}
+
+// -> s, se
+func (hs *noiseHandshake) readFinal(msg []byte) (crypto.PubKey, error) {
+	if len(msg) < 32+aeadSizeOverhead {
+		return nil, errNoiseShortMsg
+	}
+	rs, err := hs.decryptAndHash(msg[:32+aeadSizeOverhead])
+	if err != nil {
+		return nil, err
+	}
+	hs.rs = new([32]byte)
+	copy(hs.rs[:], rs)
+	if err := hs.mixDH(hs.ePriv, hs.rs); err != nil {
+		return nil, err
+	}
+	payload, err := hs.decryptAndHash(msg[32+aeadSizeOverhead:])
+	if err != nil {
+		return nil, err
+	}
+	return hs.verifyPayload(payload)
+}
+
+// signPayload returns the node key and its signature of the local static key.
+func (hs *noiseHandshake) signPayload() ([]byte, error) {
+	key, err := marshalNoiseIdentity(hs.locPrivKey.PubKey())
+	if err != nil {
+		return nil, err
+	}
+	var sig []byte
+	if signer, ok := hs.locPrivKey.(HandshakeSigner); ok {
+		sig, err = signer.SignNoiseStaticKey(hs.sPub)
+	} else {
+		sig, err = hs.locPrivKey.Sign(NoiseStaticKeyHash(hs.sPub)[:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cdc.MarshalBinaryBare(authSigMessage{key, sig})
+}
+
+// verifyPayload returns the remote node key which signed the remote static key.
+func (hs *noiseHandshake) verifyPayload(payload []byte) (crypto.PubKey, error) {
+	var msg authSigMessage
+	if err := cdc.UnmarshalBinaryBare(payload, &msg); err != nil {
+		return nil, err
+	}
+	remPubKey, err := unmarshalNoiseIdentity(msg.Key)
+	if err != nil {
+		return nil, err
+	}
+	if !remPubKey.VerifyBytes(NoiseStaticKeyHash(hs.rs)[:], msg.Sig) {
+		return nil, errNoiseIdentity
+	}
+	return remPubKey, nil
+}
+
+// NoiseStaticKeyHash returns the hash of a noise static key which is signed
+// by the node key holding it.
+func NoiseStaticKeyHash(static *[32]byte) *[32]byte {
+	var hash [32]byte
+	copy(hash[:], crypto.Sha256(append([]byte(noiseStaticKeyPrefix), static[:]...)))
+	return &hash
+}
+
+func marshalNoiseIdentity(pubKey crypto.PubKey) ([]byte, error) {
+	switch key := pubKey.(type) {
+	case crypto.PubKeyTrue:
+		return append([]byte{noiseKeySecp256k1}, key.Bytes()...), nil
+	case *crypto.PubKeyTrue:
+		return append([]byte{noiseKeySecp256k1}, key.Bytes()...), nil
+	case ed25519.PubKeyEd25519:
+		return append([]byte{noiseKeyEd25519}, key[:]...), nil
+	}
+	return nil, errors.New("unsupported noise identity key")
+}
+
+func unmarshalNoiseIdentity(data []byte) (crypto.PubKey, error) {
+	if len(data) == 0 {
+		return nil, errNoiseIdentity
+	}
+	switch data[0] {
+	case noiseKeySecp256k1:
+		key, err := tcrypyo.UnmarshalPubkey(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		return crypto.PubKeyTrue(*key), nil
+	case noiseKeyEd25519:
+		var key ed25519.PubKeyEd25519
+		if len(data[1:]) != len(key) {
+			return nil, errNoiseIdentity
+		}
+		copy(key[:], data[1:])
+		return key, nil
+	}
+	return nil, errNoiseIdentity
+}
+
+// noiseHKDF derives two keys from the chaining key, it is the HKDF function of
+// the noise specification with HMAC-SHA256.
+func noiseHKDF(ck, ikm []byte) (out1, out2 [32]byte) {
+	mac := hmac.New(sha256.New, ck)
+	mac.Write(ikm)
+	tempKey := mac.Sum(nil)
+
+	mac = hmac.New(sha256.New, tempKey)
+	mac.Write([]byte{0x01})
+	mac.Sum(out1[:0])
+
+	mac = hmac.New(sha256.New, tempKey)
+	mac.Write(out1[:])
+	mac.Write([]byte{0x02})
+	mac.Sum(out2[:0])
+	return
+}
@@ -26,6 +26,17 @@ const aeadSizeOverhead = 16 // overhead of poly 1305 authentication tag
 const aeadKeySize = chacha20poly1305.KeySize
 const aeadNonceSize = chacha20poly1305.NonceSize
 
+// HandshakeSigner is implemented by a node key which is held outside of the
+// node, e.g. by a remote signer. It signs the handshake challenges of the
+// connections from the handshake secrets, so the holder of the key derives the
+// signed hash itself and never signs an arbitrary hash.
+type HandshakeSigner interface {
+	// SignSecretChallenge signs the challenge of a SecretConnection
+	SignSecretChallenge(dhSecret *[32]byte, locIsLeast bool) ([]byte, error)
+	// SignNoiseStaticKey signs the static key of a NoiseConnection
+	SignNoiseStaticKey(static *[32]byte) ([]byte, error)
+}
+
 // SecretConnection implements net.conn.
 // It is an implementation of the STS protocol.
 // Note we do not (yet) assume that a remote peer's pubkey
@@ -85,7 +96,10 @@ func MakeSecretConnection(conn io.ReadWriteCloser, locPrivKey crypto.PrivKey) (*
 	}
 
 	// Sign the challenge bytes for authentication.
-	locSignature := signChallenge(challenge, locPrivKey)
+	locSignature, err := signChallenge(challenge, dhSecret, locIsLeast, locPrivKey)
+	if err != nil {
+		return nil, err
+	}
 
 	// Share (in secret) each other's pubkey & challenge signature
 	authSigMsg, err := shareAuthSignature(sc, locPubKey, locSignature)
@@ -291,13 +305,18 @@ func sort32(foo, bar *[32]byte) (lo, hi *[32]byte) {
 	return
 }
 
-func signChallenge(challenge *[32]byte, locPrivKey crypto.PrivKey) (signature []byte) {
-	signature, err := locPrivKey.Sign(challenge[:])
-	// TODO(ismail): let signChallenge return an error instead
-	if err != nil {
-		panic(err)
+func signChallenge(challenge, dhSecret *[32]byte, locIsLeast bool, locPrivKey crypto.PrivKey) ([]byte, error) {
+	if hs, ok := locPrivKey.(HandshakeSigner); ok {
+		return hs.SignSecretChallenge(dhSecret, locIsLeast)
 	}
-	return
+	return locPrivKey.Sign(challenge[:])
+}
+
+// SecretChallenge returns the challenge of a SecretConnection which is signed
+// by the side holding dhSecret.
+func SecretChallenge(dhSecret *[32]byte, locIsLeast bool) *[32]byte {
+	_, _, challenge := deriveSecretAndChallenge(dhSecret, locIsLeast)
+	return challenge
 }
 
 type authSigMessage struct {
@@ -0,0 +1,222 @@
+package conn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"truechain/discovery/consensus/tbft/crypto"
+	"truechain/discovery/consensus/tbft/help"
+)
+
+// TransportVersion identifies the encryption of a tp2p connection, the higher
+// version is the better one.
+type TransportVersion byte
+
+const (
+	// TransportSecret is the station-to-station SecretConnection
+	TransportSecret TransportVersion = 1
+	// TransportNoise is the Noise_XX NoiseConnection
+	TransportNoise TransportVersion = 2
+)
+
+// The magic starting the transport hello
+var transportMagic = []byte("TP2P")
+
+// The transport hello is the magic, the number of versions and the versions
+const maxTransportVersions = 16
+const maxTransportHelloSize = 4 + 1 + maxTransportVersions
+
+var (
+	// ErrNoCommonTransport is returned when the peers have no transport
+	// version in common.
+	ErrNoCommonTransport = errors.New("no common transport version")
+
+	// ErrTransportNotNegotiated is returned when the remote peer makes the
+	// legacy secret connection instead of negotiating the transport. The
+	// connection is unusable then, the peer has to be dialed again.
+	ErrTransportNotNegotiated = errors.New("transport not negotiated by remote peer")
+
+	errTransportHello = errors.New("invalid transport hello")
+)
+
+var transportNames = map[string]TransportVersion{
+	"secret": TransportSecret,
+	"noise":  TransportNoise,
+}
+
+// String implements fmt.Stringer.
+func (v TransportVersion) String() string {
+	for name, version := range transportNames {
+		if version == v {
+			return name
+		}
+	}
+	return fmt.Sprintf("transport(%d)", byte(v))
+}
+
+// ParseTransports parses the transport names ("noise", "secret") of the
+// configuration.
+func ParseTransports(names []string) ([]TransportVersion, error) {
+	versions := make([]TransportVersion, 0, len(names))
+	for _, name := range names {
+		version, ok := transportNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transport %q", name)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// AuthenticatedConn is an encrypted connection authenticated with the node key
+// of the remote peer.
+type AuthenticatedConn interface {
+	net.Conn
+	RemotePubKey() crypto.PubKey
+}
+
+// UpgradeConnection negotiates the best transport version supported by both
+// peers and performs its handshake. The outbound peer is the noise initiator.
+// Without any version the legacy SecretConnection is made without negotiation,
+// to talk with the peers which don't negotiate.
+// Caller should call conn.Close() if there is an error.
+func UpgradeConnection(conn io.ReadWriteCloser, locPrivKey crypto.PrivKey, versions []TransportVersion, outbound bool) (AuthenticatedConn, TransportVersion, error) {
+	if len(versions) == 0 {
+		sc, err := MakeSecretConnection(conn, locPrivKey)
+		if err != nil {
+			return nil, TransportSecret, err
+		}
+		return sc, TransportSecret, nil
+	}
+	locHello, err := encodeTransportHello(versions)
+	if err != nil {
+		return nil, 0, err
+	}
+	remHello, err := shareTransportHello(conn, locHello)
+	if err != nil {
+		return nil, 0, err
+	}
+	remVersions, err := decodeTransportHello(remHello)
+	if err != nil {
+		return nil, 0, err
+	}
+	version, err := selectTransport(versions, remVersions)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch version {
+	case TransportNoise:
+		// Bind the hellos to the handshake, a downgraded negotiation fails it.
+		var prologue []byte
+		if outbound {
+			prologue = append(append(prologue, locHello...), remHello...)
+		} else {
+			prologue = append(append(prologue, remHello...), locHello...)
+		}
+		nc, err := MakeNoiseConnection(conn, locPrivKey, outbound, prologue)
+		if err != nil {
+			return nil, version, err
+		}
+		return nc, version, nil
+	default:
+		sc, err := MakeSecretConnection(conn, locPrivKey)
+		if err != nil {
+			return nil, version, err
+		}
+		return sc, version, nil
+	}
+}
+
+// selectTransport returns the highest version in both lists.
+func selectTransport(local, remote []TransportVersion) (TransportVersion, error) {
+	var best TransportVersion
+	for _, l := range local {
+		for _, r := range remote {
+			if l == r && l > best {
+				best = l
+			}
+		}
+	}
+	if best == 0 {
+		return 0, ErrNoCommonTransport
+	}
+	return best, nil
+}
+
+func encodeTransportHello(versions []TransportVersion) ([]byte, error) {
+	if len(versions) > maxTransportVersions {
+		return nil, errTransportHello
+	}
+	hello := append([]byte{}, transportMagic...)
+	hello = append(hello, byte(len(versions)))
+	for _, version := range versions {
+		hello = append(hello, byte(version))
+	}
+	return hello, nil
+}
+
+func decodeTransportHello(hello []byte) ([]TransportVersion, error) {
+	if len(hello) < len(transportMagic)+1 || !bytes.Equal(hello[:len(transportMagic)], transportMagic) {
+		return nil, errTransportHello
+	}
+	count := int(hello[len(transportMagic)])
+	data := hello[len(transportMagic)+1:]
+	if count > maxTransportVersions || count != len(data) {
+		return nil, errTransportHello
+	}
+	versions := make([]TransportVersion, count)
+	for i, version := range data {
+		versions[i] = TransportVersion(version)
+	}
+	return versions, nil
+}
+
+// readTransportHello reads the hello of the remote peer, the magic and the
+// number of versions first.
+func readTransportHello(r io.Reader) ([]byte, error) {
+	head := make([]byte, len(transportMagic)+1)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head[:len(transportMagic)], transportMagic) {
+		return nil, ErrTransportNotNegotiated
+	}
+	if int(head[len(transportMagic)]) > maxTransportVersions {
+		return nil, errTransportHello
+	}
+	hello := make([]byte, len(head)+int(head[len(transportMagic)]))
+	copy(hello, head)
+	if _, err := io.ReadFull(r, hello[len(head):]); err != nil {
+		return nil, err
+	}
+	return hello, nil
+}
+
+func shareTransportHello(conn io.ReadWriter, locHello []byte) ([]byte, error) {
+
+	// Send our hello and receive theirs in tandem.
+	var trs, _ = help.Parallel(
+		func(_ int) (val interface{}, err error, abort bool) {
+			if _, err1 := conn.Write(locHello); err1 != nil {
+				return nil, err1, true // abort
+			}
+			return nil, nil, false
+		},
+		func(_ int) (val interface{}, err error, abort bool) {
+			remHello, err2 := readTransportHello(conn)
+			if err2 != nil {
+				return nil, err2, true // abort
+			}
+			return remHello, nil, false
+		},
+	)
+
+	// If error:
+	if trs.FirstError() != nil {
+		return nil, trs.FirstError()
+	}
+	return trs.FirstValue().([]byte), nil
+}
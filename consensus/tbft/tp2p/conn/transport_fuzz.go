@@ -0,0 +1,32 @@
+// +build gofuzz
+
+package conn
+
+import (
+	"bytes"
+	"io/ioutil"
+	"truechain/discovery/consensus/tbft/crypto"
+)
+
+var fuzzKey = crypto.GenPrivKey()
+
+type fuzzReadWriter struct {
+	*bytes.Reader
+}
+
+func (fuzzReadWriter) Write(data []byte) (int, error) { return ioutil.Discard.Write(data) }
+func (fuzzReadWriter) Close() error                   { return nil }
+
+// Fuzz feeds the data as the messages of the remote peer to the transport
+// negotiation and the handshake, the first byte picks the local role.
+func Fuzz(data []byte) int {
+	if len(data) == 0 {
+		return -1
+	}
+	outbound := data[0]%2 == 0
+	conn := fuzzReadWriter{bytes.NewReader(data[1:])}
+	if _, _, err := UpgradeConnection(conn, fuzzKey, []TransportVersion{TransportNoise, TransportSecret}, outbound); err != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,246 @@
+package conn
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+	"truechain/discovery/consensus/tbft/crypto"
+	"truechain/discovery/consensus/tbft/crypto/ed25519"
+	"truechain/discovery/consensus/tbft/help"
+)
+
+type upgradeResult struct {
+	conn    AuthenticatedConn
+	version TransportVersion
+	err     error
+}
+
+// upgradePipe upgrades both ends of an in-memory pipe, the first one is the
+// outbound peer.
+func upgradePipe(t *testing.T, outKey, inKey crypto.PrivKey, outVersions, inVersions []TransportVersion) (out, in upgradeResult) {
+	outConn, inConn := net.Pipe()
+	trs, ok := help.Parallel(
+		func(_ int) (val interface{}, err error, abort bool) {
+			c, v, err := UpgradeConnection(outConn, outKey, outVersions, true)
+			if err != nil {
+				outConn.Close()
+			}
+			return upgradeResult{c, v, err}, nil, false
+		},
+		func(_ int) (val interface{}, err error, abort bool) {
+			c, v, err := UpgradeConnection(inConn, inKey, inVersions, false)
+			if err != nil {
+				inConn.Close()
+			}
+			return upgradeResult{c, v, err}, nil, false
+		},
+	)
+	if !ok {
+		t.Fatal("upgrade panicked")
+	}
+	outResult, _ := trs.LatestResult(0)
+	inResult, _ := trs.LatestResult(1)
+	return outResult.Value.(upgradeResult), inResult.Value.(upgradeResult)
+}
+
+func TestUpgradeConnection(t *testing.T) {
+	var (
+		all    = []TransportVersion{TransportNoise, TransportSecret}
+		noise  = []TransportVersion{TransportNoise}
+		secret = []TransportVersion{TransportSecret}
+	)
+	tests := []struct {
+		out, in []TransportVersion
+		version TransportVersion
+		err     error // of both peers, or of the negotiating one with a legacy peer
+	}{
+		{all, all, TransportNoise, nil},
+		{noise, all, TransportNoise, nil},
+		{all, secret, TransportSecret, nil},
+		{[]TransportVersion{TransportSecret, TransportNoise}, all, TransportNoise, nil},
+		{nil, nil, TransportSecret, nil},
+		{noise, secret, 0, ErrNoCommonTransport},
+		{all, nil, 0, ErrTransportNotNegotiated},
+		{nil, all, 0, ErrTransportNotNegotiated},
+	}
+	for i, tt := range tests {
+		outKey, inKey := crypto.GenPrivKey(), crypto.GenPrivKey()
+		out, in := upgradePipe(t, outKey, inKey, tt.out, tt.in)
+		switch {
+		case tt.err != nil && (tt.out == nil || tt.in == nil):
+			// The legacy peer fails on the hello of the negotiating one
+			negotiating, legacy := out, in
+			if tt.out == nil {
+				negotiating, legacy = in, out
+			}
+			if negotiating.err != tt.err || legacy.err == nil {
+				t.Errorf("test %d: errors mismatch: have %v/%v, want %v", i, negotiating.err, legacy.err, tt.err)
+			}
+			continue
+		case tt.err != nil:
+			if out.err != tt.err || in.err != tt.err {
+				t.Errorf("test %d: errors mismatch: have %v/%v, want %v", i, out.err, in.err, tt.err)
+			}
+			continue
+		}
+		if out.err != nil || in.err != nil {
+			t.Fatalf("test %d: upgrade failed: %v/%v", i, out.err, in.err)
+		}
+		if out.version != tt.version || in.version != tt.version {
+			t.Errorf("test %d: version mismatch: have %v/%v, want %v", i, out.version, in.version, tt.version)
+		}
+		if !sameKey(out.conn.RemotePubKey(), inKey) || !sameKey(in.conn.RemotePubKey(), outKey) {
+			t.Errorf("test %d: remote key mismatch", i)
+		}
+		checkTransfer(t, out.conn, in.conn)
+		out.conn.Close()
+		in.conn.Close()
+	}
+}
+
+func TestNoiseConnectionKeys(t *testing.T) {
+	keys := []crypto.PrivKey{crypto.GenPrivKey(), ed25519.GenPrivKey()}
+	for _, outKey := range keys {
+		for _, inKey := range keys {
+			out, in := upgradePipe(t, outKey, inKey, []TransportVersion{TransportNoise}, []TransportVersion{TransportNoise})
+			if out.err != nil || in.err != nil {
+				t.Fatalf("upgrade failed: %v/%v", out.err, in.err)
+			}
+			if !sameKey(out.conn.RemotePubKey(), inKey) || !sameKey(in.conn.RemotePubKey(), outKey) {
+				t.Errorf("remote key mismatch")
+			}
+			checkTransfer(t, in.conn, out.conn)
+			out.conn.Close()
+			in.conn.Close()
+		}
+	}
+}
+
+func sameKey(pubKey crypto.PubKey, privKey crypto.PrivKey) bool {
+	return bytes.Equal(pubKey.Bytes(), privKey.PubKey().Bytes())
+}
+
+// checkTransfer sends small and multi message data both ways.
+func checkTransfer(t *testing.T, a, b net.Conn) {
+	small := []byte("hello")
+	large := make([]byte, 3*noiseMaxMsgSize+17)
+	rand.Read(large)
+
+	for _, data := range [][]byte{small, large} {
+		for _, pair := range [][2]net.Conn{{a, b}, {b, a}} {
+			w, r := pair[0], pair[1]
+			errc := make(chan error, 1)
+			go func() {
+				_, err := w.Write(data)
+				errc <- err
+			}()
+			read := make([]byte, len(data))
+			if _, err := io.ReadFull(r, read); err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if err := <-errc; err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			if !bytes.Equal(read, data) {
+				t.Fatalf("data mismatch, %d bytes", len(data))
+			}
+		}
+	}
+}
+
+// tamperConn flips a bit of the n-th byte written through it.
+type tamperConn struct {
+	net.Conn
+	n, written int
+}
+
+func (c *tamperConn) Write(data []byte) (int, error) {
+	if c.written <= c.n && c.n < c.written+len(data) {
+		data = append([]byte{}, data...)
+		data[c.n-c.written] ^= 0x01
+	}
+	c.written += len(data)
+	return c.Conn.Write(data)
+}
+
+func TestNoiseHandshakeTampered(t *testing.T) {
+	// The hello magic and versions, the ephemeral key and the final message
+	// of the outbound peer are tampered
+	for _, n := range []int{2, 5, 10, 50} {
+		outConn, inConn := net.Pipe()
+		outConn.SetDeadline(time.Now().Add(5 * time.Second))
+		inConn.SetDeadline(time.Now().Add(5 * time.Second))
+		trs, _ := help.Parallel(
+			func(_ int) (val interface{}, err error, abort bool) {
+				_, _, err = UpgradeConnection(&tamperConn{Conn: outConn, n: n}, crypto.GenPrivKey(), []TransportVersion{TransportNoise, TransportSecret}, true)
+				outConn.Close()
+				return nil, err, false
+			},
+			func(_ int) (val interface{}, err error, abort bool) {
+				_, _, err = UpgradeConnection(inConn, crypto.GenPrivKey(), []TransportVersion{TransportNoise, TransportSecret}, false)
+				inConn.Close()
+				return nil, err, false
+			},
+		)
+		if trs.FirstError() == nil {
+			t.Errorf("byte %d: tampered handshake succeeded", n)
+		}
+	}
+}
+
+type fuzzConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (fuzzConn) Close() error { return nil }
+
+func TestTransportRandomInput(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	key := crypto.GenPrivKey()
+	hello, _ := encodeTransportHello([]TransportVersion{TransportNoise})
+	for i := 0; i < 1000; i++ {
+		data := make([]byte, rng.Intn(256))
+		rng.Read(data)
+		if i%2 == 0 {
+			// Get past the negotiation
+			data = append(append([]byte{}, hello...), data...)
+		}
+		conn := fuzzConn{bytes.NewReader(data), ioutil.Discard}
+		if _, _, err := UpgradeConnection(conn, key, []TransportVersion{TransportNoise}, i%4 < 2); err == nil {
+			t.Fatalf("random input %x accepted", data)
+		}
+	}
+}
+
+func TestSelectTransport(t *testing.T) {
+	tests := []struct {
+		local, remote []TransportVersion
+		version       TransportVersion
+	}{
+		{[]TransportVersion{TransportSecret, TransportNoise}, []TransportVersion{TransportNoise, TransportSecret}, TransportNoise},
+		{[]TransportVersion{TransportSecret}, []TransportVersion{TransportNoise, TransportSecret, 7}, TransportSecret},
+		{[]TransportVersion{7, TransportNoise}, []TransportVersion{7}, 7},
+		{[]TransportVersion{TransportSecret}, nil, 0},
+	}
+	for i, tt := range tests {
+		version, _ := selectTransport(tt.local, tt.remote)
+		if version != tt.version {
+			t.Errorf("test %d: have %v, want %v", i, version, tt.version)
+		}
+	}
+}
+
+func TestParseTransports(t *testing.T) {
+	versions, err := ParseTransports([]string{"noise", "secret"})
+	if err != nil || len(versions) != 2 || versions[0] != TransportNoise || versions[1] != TransportSecret {
+		t.Fatalf("have %v %v", versions, err)
+	}
+	if _, err := ParseTransports([]string{"tls"}); err == nil {
+		t.Fatal("unknown transport accepted")
+	}
+}
@@ -47,12 +47,13 @@ type peerConn struct {
 	conn         net.Conn // source connection
 	ip           net.IP
 	originalAddr *NetAddress // nil for inbound connections
+	transport    tmconn.TransportVersion
 }
 
-// ID only exists for SecretConnection.
-// NOTE: Will panic if conn is not *SecretConnection.
+// ID only exists for authenticated connections.
+// NOTE: Will panic if conn is not a tmconn.AuthenticatedConn.
 func (pc peerConn) ID() ID {
-	return PubKeyToID(pc.conn.(*tmconn.SecretConnection).RemotePubKey())
+	return PubKeyToID(pc.conn.(tmconn.AuthenticatedConn).RemotePubKey())
 }
 
 // Return the IP from the connection RemoteAddr
@@ -137,13 +138,15 @@ func newOutboundPeerConn(
 	config *params.P2PConfig,
 	persistent bool,
 	ourNodePrivKey crypto.PrivKey,
+	transports []tmconn.TransportVersion,
+	dial func(*NetAddress, *params.P2PConfig) (net.Conn, error),
 ) (peerConn, error) {
 	conn, err := dial(addr, config)
 	if err != nil {
 		return peerConn{}, errors.New(fmt.Sprint(err, "dail Error creating peer"))
 	}
 
-	pc, err := newPeerConn(conn, config, true, persistent, ourNodePrivKey, addr)
+	pc, err := newPeerConn(conn, config, true, persistent, ourNodePrivKey, transports, addr)
 	if err != nil {
 		testlog.AddLog("newPeerConnError", err.Error())
 		if cerr := conn.Close(); cerr != nil {
@@ -167,11 +170,12 @@ func newInboundPeerConn(
 	conn net.Conn,
 	config *params.P2PConfig,
 	ourNodePrivKey crypto.PrivKey,
+	transports []tmconn.TransportVersion,
 ) (peerConn, error) {
 
 	// TODO: issue PoW challenge
 
-	return newPeerConn(conn, config, false, false, ourNodePrivKey, nil)
+	return newPeerConn(conn, config, false, false, ourNodePrivKey, transports, nil)
 }
 
 func newPeerConn(
@@ -179,6 +183,7 @@ func newPeerConn(
 	cfg *params.P2PConfig,
 	outbound, persistent bool,
 	ourNodePrivKey crypto.PrivKey,
+	transports []tmconn.TransportVersion,
 	originalAddr *NetAddress,
 ) (pc peerConn, err error) {
 	conn := rawConn
@@ -196,8 +201,11 @@ func newPeerConn(
 			err, "Error setting deadline while encrypting connection"))
 	}
 
-	// Encrypt connection
-	conn, err = tmconn.MakeSecretConnection(conn, ourNodePrivKey)
+	// Encrypt connection with the best transport supported by both peers
+	conn, transport, err := tmconn.UpgradeConnection(conn, ourNodePrivKey, transports, outbound)
+	if err == tmconn.ErrTransportNotNegotiated {
+		return pc, err // the dialer may fall back to the legacy connection
+	}
 	if err != nil {
 		return pc, errors.New(fmt.Sprint(err, "Error creating peer"))
 	}
@@ -209,6 +217,7 @@ func newPeerConn(
 		persistent:   persistent,
 		conn:         conn,
 		originalAddr: originalAddr,
+		transport:    transport,
 	}, nil
 }
 
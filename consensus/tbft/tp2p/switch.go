@@ -77,13 +77,21 @@ type Switch struct {
 	hasPeer          help.PeerInValidators
 
 	mConfig conn.MConnConfig
+
+	transports []conn.TransportVersion // negotiated with the peers, legacy if empty
+	dial       func(*NetAddress, *config.P2PConfig) (net.Conn, error)
 }
 
 // SwitchOption sets an optional parameter on the Switch.
 type SwitchOption func(*Switch)
 
-// NewSwitch creates a new Switch with the given config.
-func NewSwitch(cfg *config.P2PConfig, hasPeer help.PeerInValidators, options ...SwitchOption) *Switch {
+// NewSwitch creates a new Switch with the given config. It returns an error if
+// the transports of the config are unknown.
+func NewSwitch(cfg *config.P2PConfig, hasPeer help.PeerInValidators, options ...SwitchOption) (*Switch, error) {
+	transports, err := conn.ParseTransports(cfg.Transports)
+	if err != nil {
+		return nil, err
+	}
 	sw := &Switch{
 		config:       cfg,
 		reactors:     make(map[string]Reactor),
@@ -104,6 +112,9 @@ func NewSwitch(cfg *config.P2PConfig, hasPeer help.PeerInValidators, options ...
 
 	sw.mConfig = mConfig
 
+	sw.transports = transports
+	sw.dial = dial
+
 	sw.BaseService = *help.NewBaseService("P2P Switch", sw)
 
 	for _, option := range options {
@@ -112,7 +123,7 @@ func NewSwitch(cfg *config.P2PConfig, hasPeer help.PeerInValidators, options ...
 
 	go sw.ReadPeerList()
 
-	return sw
+	return sw, nil
 }
 
 func (sw *Switch) ReadPeerList() {
@@ -567,7 +578,7 @@ func (sw *Switch) addInboundPeerWithConfig(
 	config *config.P2PConfig,
 ) error {
 	testlog.AddLog("addPeer in", conn.RemoteAddr().String())
-	peerConn, err := newInboundPeerConn(conn, config, sw.nodeKey.PrivKey)
+	peerConn, err := newInboundPeerConn(conn, config, sw.nodeKey.PrivKey, sw.transports)
 	if err != nil {
 		testlog.AddLog("newPeerConnError", err.Error())
 		help.CheckAndPrintError(conn.Close()) // peer is nil
@@ -594,12 +605,7 @@ func (sw *Switch) addOutboundPeerWithConfig(
 	persistent bool,
 ) error {
 	log.Info("Dialing peer out", "address", addr)
-	peerConn, err := newOutboundPeerConn(
-		addr,
-		config,
-		persistent,
-		sw.nodeKey.PrivKey,
-	)
+	peerConn, err := sw.dialPeerConn(addr, config, persistent)
 	testlog.AddLog("newOutboundPeerConnError", err)
 	if err != nil {
 		if persistent {
@@ -617,8 +623,29 @@ func (sw *Switch) addOutboundPeerWithConfig(
 	return nil
 }
 
+// dialPeerConn dials the peer and negotiates the transport. The peers which
+// don't negotiate are dialed once more with the legacy SecretConnection, if it's
+// one of our transports, so that the committee can switch over node by node.
+func (sw *Switch) dialPeerConn(addr *NetAddress, config *config.P2PConfig, persistent bool) (peerConn, error) {
+	pc, err := newOutboundPeerConn(addr, config, persistent, sw.nodeKey.PrivKey, sw.transports, sw.dial)
+	if err == conn.ErrTransportNotNegotiated && hasTransport(sw.transports, conn.TransportSecret) {
+		log.Debug("Peer doesn't negotiate the transport, dialing the secret connection", "address", addr)
+		pc, err = newOutboundPeerConn(addr, config, persistent, sw.nodeKey.PrivKey, nil, sw.dial)
+	}
+	return pc, err
+}
+
+func hasTransport(versions []conn.TransportVersion, version conn.TransportVersion) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
 // addPeer performs the truechain P2P handshake with a peer
-// that already has a SecretConnection. If all goes well,
+// that already has an authenticated connection. If all goes well,
 // it starts the peer and adds it to the switch.
 // NOTE: This performs a blocking handshake before the peer is added.
 // NOTE: If error is returned, caller is responsible for calling
@@ -696,7 +723,7 @@ func (sw *Switch) addPeer(pc peerConn) error {
 	peer := newPeer(pc, sw.mConfig, peerNodeInfo, sw.reactorsByCh, sw.chDescs, sw.StopPeerForError)
 	//peer.SetLogger(sw.Logger.With("peer", addr))
 
-	log.Info("Successful handshake with peer", "peerNodeInfo", peerNodeInfo)
+	log.Info("Successful handshake with peer", "peerNodeInfo", peerNodeInfo, "transport", pc.transport)
 
 	// All good. Start peer
 	if sw.IsRunning() {
@@ -0,0 +1,70 @@
+package tp2p
+
+import (
+	"net"
+	"testing"
+
+	"truechain/discovery/consensus/tbft/crypto"
+	"truechain/discovery/consensus/tbft/tp2p/conn"
+	config "truechain/discovery/params"
+)
+
+// legacySwitch makes a switch dialing a peer which only speaks the legacy
+// SecretConnection over in-memory pipes, and counts the dials.
+func legacySwitch(transports []conn.TransportVersion, dials *int) (*Switch, *NetAddress) {
+	legacyKey := crypto.GenPrivKey()
+	addr := NewNetAddressIPPort(net.IP{127, 0, 0, 1}, 30310)
+	addr.ID = PubKeyToID(legacyKey.PubKey())
+
+	sw := &Switch{
+		nodeKey:    &NodeKey{PrivKey: crypto.GenPrivKey()},
+		transports: transports,
+		dial: func(*NetAddress, *config.P2PConfig) (net.Conn, error) {
+			*dials++
+			local, remote := net.Pipe()
+			go func() {
+				if _, err := conn.MakeSecretConnection(remote, legacyKey); err != nil {
+					remote.Close()
+				}
+			}()
+			return local, nil
+		},
+	}
+	return sw, addr
+}
+
+// Tests that a negotiating peer dials a legacy one again with the secret
+// connection, if it's one of its transports.
+func TestDialLegacyPeer(t *testing.T) {
+	var dials int
+	sw, addr := legacySwitch([]conn.TransportVersion{conn.TransportNoise, conn.TransportSecret}, &dials)
+
+	pc, err := sw.dialPeerConn(addr, config.TestP2PConfig(), false)
+	if err != nil {
+		t.Fatalf("failed to dial legacy peer: %v", err)
+	}
+	defer pc.CloseConn()
+
+	if dials != 2 {
+		t.Errorf("dials mismatch: have %d, want %d", dials, 2)
+	}
+	if pc.transport != conn.TransportSecret {
+		t.Errorf("transport mismatch: have %v, want %v", pc.transport, conn.TransportSecret)
+	}
+	if pc.ID() != addr.ID {
+		t.Errorf("peer ID mismatch: have %v, want %v", pc.ID(), addr.ID)
+	}
+}
+
+// Tests that a peer without the secret connection doesn't fall back to it.
+func TestDialLegacyPeerNoSecret(t *testing.T) {
+	var dials int
+	sw, addr := legacySwitch([]conn.TransportVersion{conn.TransportNoise}, &dials)
+
+	if _, err := sw.dialPeerConn(addr, config.TestP2PConfig(), false); err != conn.ErrTransportNotNegotiated {
+		t.Fatalf("error mismatch: have %v, want %v", err, conn.ErrTransportNotNegotiated)
+	}
+	if dials != 1 {
+		t.Errorf("dials mismatch: have %d, want %d", dials, 1)
+	}
+}
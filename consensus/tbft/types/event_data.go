@@ -20,6 +20,7 @@ const (
 	EventUnlock      = "Unlock"
 	EventVote        = "Vote"
 	EventMsgNotFound = "MessageUnsubscribe"
+	EventEvidence    = "Evidence"
 )
 
 //EventDataRoundState NOTE: This goes into the replay WAL
@@ -0,0 +1,31 @@
+package types
+
+import (
+	ctypes "truechain/discovery/core/types"
+)
+
+// NewDuplicateVoteEvidence returns the evidence of the conflicting votes
+// signed by the same validator, it carries the canonical encoding of the
+// votes so the signatures can be verified without the committee state.
+func NewDuplicateVoteEvidence(chainID string, voteA, voteB *Vote) (*ctypes.DuplicateVoteEvidence, error) {
+	evA, err := evidenceVote(chainID, voteA)
+	if err != nil {
+		return nil, err
+	}
+	evB, err := evidenceVote(chainID, voteB)
+	if err != nil {
+		return nil, err
+	}
+	return ctypes.NewDuplicateVoteEvidence(evA, evB)
+}
+
+func evidenceVote(chainID string, vote *Vote) (*ctypes.EvidenceVote, error) {
+	bz, err := cdc.MarshalJSON(CanonicalVote(chainID, vote))
+	if err != nil {
+		return nil, err
+	}
+	return &ctypes.EvidenceVote{
+		Data: bz,
+		Sign: append([]byte{}, vote.Signature...),
+	}, nil
+}
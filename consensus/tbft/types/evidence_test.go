@@ -0,0 +1,65 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"truechain/discovery/crypto"
+)
+
+func TestDuplicateVoteEvidence(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	pv := NewPrivValidator(*key)
+
+	now := time.Now().UTC()
+	voteA := &Vote{Height: 10, Round: 1, Type: VoteTypePrecommit, Timestamp: now,
+		BlockID: BlockID{Hash: []byte{2}, PartsHeader: PartSetHeader{Total: 1, Hash: []byte{3}}}}
+	voteB := &Vote{Height: 10, Round: 1, Type: VoteTypePrecommit, Timestamp: now,
+		BlockID: BlockID{Hash: []byte{1}, PartsHeader: PartSetHeader{Total: 1, Hash: []byte{3}}}}
+	if err := pv.SignVote("1", voteA); err != nil {
+		t.Fatal(err)
+	}
+	// the validator refuses a conflicting vote, sign it with a fresh one
+	if err := NewPrivValidator(*key).SignVote("1", voteB); err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := NewDuplicateVoteEvidence("1", voteA, voteB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey, height, err := ev.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pubkey, crypto.FromECDSAPub(&key.PublicKey)) || height != 10 {
+		t.Fatalf("offence mismatch: have %x %d", pubkey, height)
+	}
+	// the order of the votes doesn't change the evidence
+	ev2, err := NewDuplicateVoteEvidence("1", voteB, voteA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Hash() != ev2.Hash() {
+		t.Fatal("evidence hash depends on the vote order")
+	}
+
+	// votes of different rounds don't conflict
+	voteC := &Vote{Height: 10, Round: 2, Type: VoteTypePrecommit, Timestamp: now, BlockID: BlockID{Hash: []byte{4}}}
+	if err := NewPrivValidator(*key).SignVote("1", voteC); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDuplicateVoteEvidence("1", voteA, voteC); err == nil {
+		t.Fatal("accepted votes of different rounds")
+	}
+	// votes of different validators don't conflict
+	other, _ := crypto.GenerateKey()
+	voteD := &Vote{Height: 10, Round: 1, Type: VoteTypePrecommit, Timestamp: now, BlockID: BlockID{Hash: []byte{5}}}
+	if err := NewPrivValidator(*other).SignVote("1", voteD); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDuplicateVoteEvidence("1", voteA, voteD); err == nil {
+		t.Fatal("accepted votes of different validators")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"os"
 	"sync"
 	"time"
 	"truechain/discovery/consensus/tbft/metrics"
@@ -49,6 +51,14 @@ type PrivValidator interface {
 	SignProposal(chainID string, proposal *Proposal) error
 }
 
+// Signer hands out the PrivValidator of every committee, it lets the
+// validator key be held outside of the node, e.g. by a remote signer.
+type Signer interface {
+	PrivValidator(cid uint64) (PrivValidator, error)
+	// HandshakeKey returns the validator key authenticating the tbft connections
+	HandshakeKey() (tcrypto.PrivKey, error)
+}
+
 type privValidator struct {
 	PrivKey       tcrypto.PrivKey
 	LastHeight    uint64        `json:"last_height"`
@@ -57,7 +67,46 @@ type privValidator struct {
 	LastSignature []byte        `json:"last_signature,omitempty"` // so we dont lose signatures XXX Why would we lose signatures?
 	LastSignBytes help.HexBytes `json:"last_signbytes,omitempty"` // so we dont lose signatures XXX Why would we lose signatures?
 
-	mtx sync.Mutex
+	stateFile string // persist the last signed state here if not empty
+	mtx       sync.Mutex
+}
+
+// PrivValidatorState is the last signed height/round/step of a validator,
+// persisted per committee so a restarted node never signs a conflicting
+// proposal or vote.
+type PrivValidatorState struct {
+	Height    uint64        `json:"height"`
+	Round     uint          `json:"round"`
+	Step      uint8         `json:"step"`
+	Signature []byte        `json:"signature,omitempty"`
+	SignBytes help.HexBytes `json:"signbytes,omitempty"`
+}
+
+// LoadPrivValidatorState reads the last signed state from stateFile.
+func LoadPrivValidatorState(stateFile string) (*PrivValidatorState, error) {
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	state := new(PrivValidatorState)
+	if err := cdc.UnmarshalJSON(data, state); err != nil {
+		return nil, fmt.Errorf("error reading PrivValidatorState from %v: %v", stateFile, err)
+	}
+	return state, nil
+}
+
+// Save atomically writes the state to stateFile.
+func (s *PrivValidatorState) Save(stateFile string) error {
+	data, err := cdc.MarshalJSONIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return help.WriteFileAtomic(stateFile, data, 0600)
+}
+
+// String returns a string representation of the PrivValidatorState.
+func (s *PrivValidatorState) String() string {
+	return fmt.Sprintf("PrivValidatorState{H:%v R:%v S:%v Sig:%X}", s.Height, s.Round, s.Step, help.Fingerprint(s.Signature))
 }
 
 //KeepBlockSign is block's sign
@@ -75,24 +124,56 @@ func NewPrivValidator(priv ecdsa.PrivateKey) PrivValidator {
 	}
 }
 
-func (Validator *privValidator) Reset() {
-	var sig []byte
-	Validator.LastHeight = 0
-	Validator.LastRound = 0
-	Validator.LastStep = 0
-	Validator.LastSignature = sig
-	Validator.LastSignBytes = nil
+//NewPrivValidatorWithState return new private Validator which persists the last
+//signed state to stateFile, restoring it first if the file exists.
+func NewPrivValidatorWithState(priv ecdsa.PrivateKey, stateFile string) (PrivValidator, error) {
+	Validator := &privValidator{
+		PrivKey:   tcrypto.PrivKeyTrue(priv),
+		LastStep:  stepNone,
+		stateFile: stateFile,
+	}
+	state, err := LoadPrivValidatorState(stateFile)
+	if os.IsNotExist(err) {
+		return Validator, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if state.SignBytes != nil && state.Signature == nil {
+		return nil, fmt.Errorf("privValidator state in %v has signbytes but no signature", stateFile)
+	}
+	Validator.LastHeight = state.Height
+	Validator.LastRound = state.Round
+	Validator.LastStep = state.Step
+	Validator.LastSignature = state.Signature
+	Validator.LastSignBytes = state.SignBytes
+	log.Info("Loaded privValidator state", "file", stateFile, "state", state)
+	return Validator, nil
 }
 
-// Persist height/round/step and signature
+// Persist height/round/step and signature, the signature must not be
+// handed out if persisting fails.
 func (Validator *privValidator) saveSigned(height uint64, round int, step uint8,
-	signBytes []byte, sig []byte) {
-
+	signBytes []byte, sig []byte) error {
+
+	if Validator.stateFile != "" {
+		state := &PrivValidatorState{
+			Height:    height,
+			Round:     uint(round),
+			Step:      step,
+			Signature: sig,
+			SignBytes: signBytes,
+		}
+		if err := state.Save(Validator.stateFile); err != nil {
+			return fmt.Errorf("error saving privValidator state: %v", err)
+		}
+	}
 	Validator.LastHeight = height
 	Validator.LastRound = uint(round)
 	Validator.LastStep = step
 	Validator.LastSignature = sig
 	Validator.LastSignBytes = signBytes
+	return nil
 }
 
 func (Validator *privValidator) GetAddress() help.Address {
@@ -114,7 +195,7 @@ func (Validator *privValidator) SignVote(chainID string, vote *Vote) error {
 }
 
 // signVote checks if the vote is good to sign and sets the vote signature.
-// It may need to set the timestamp as well if the vote is otherwise the same as
+// It hands out the last signature again if the vote is the same as
 // a previously signed vote (ie. we crashed after signing but before the vote hit the WAL).
 func (Validator *privValidator) signVote(chainID string, vote *Vote) error {
 	height, round, step := vote.Height, vote.Round, voteToStep(vote)
@@ -128,14 +209,11 @@ func (Validator *privValidator) signVote(chainID string, vote *Vote) error {
 	// We might crash before writing to the wal,
 	// causing us to try to re-sign for the same HRS.
 	// If signbytes are the same, use the last signature.
-	// If they only differ by timestamp, use last timestamp and signature
-	// Otherwise, return error
+	// The signbytes are a hash of the canonical vote, so a vote
+	// differing in anything (even the timestamp) is refused.
 	if sameHRS {
 		if bytes.Equal(signBytes, Validator.LastSignBytes) {
 			vote.Signature = Validator.LastSignature
-		} else if timestamp, ok := checkVotesOnlyDifferByTimestamp(Validator.LastSignBytes, signBytes); ok {
-			vote.Timestamp = timestamp
-			vote.Signature = Validator.LastSignature
 		} else {
 			err = fmt.Errorf("conflicting data")
 		}
@@ -147,7 +225,9 @@ func (Validator *privValidator) signVote(chainID string, vote *Vote) error {
 	if err != nil {
 		return err
 	}
-	Validator.saveSigned(height, int(round), step, signBytes, sig)
+	if err := Validator.saveSigned(height, int(round), step, signBytes, sig); err != nil {
+		return err
+	}
 	vote.Signature = sig
 	return nil
 }
@@ -164,7 +244,7 @@ func (Validator *privValidator) SignProposal(chainID string, proposal *Proposal)
 }
 
 // signProposal checks if the proposal is good to sign and sets the proposal signature.
-// It may need to set the timestamp as well if the proposal is otherwise the same as
+// It hands out the last signature again if the proposal is the same as
 // a previously signed proposal ie. we crashed after signing but before the proposal hit the WAL).
 func (Validator *privValidator) signProposal(chainID string, proposal *Proposal) error {
 	height, round, step := proposal.Height, int(proposal.Round), stepPropose
@@ -178,14 +258,10 @@ func (Validator *privValidator) signProposal(chainID string, proposal *Proposal)
 	// We might crash before writing to the wal,
 	// causing us to try to re-sign for the same HRS.
 	// If signbytes are the same, use the last signature.
-	// If they only differ by timestamp, use last timestamp and signature
 	// Otherwise, return error
 	if sameHRS {
 		if bytes.Equal(signBytes, Validator.LastSignBytes) {
 			proposal.Signature = Validator.LastSignature
-		} else if timestamp, ok := checkProposalsOnlyDifferByTimestamp(Validator.LastSignBytes, signBytes); ok {
-			proposal.Timestamp = timestamp
-			proposal.Signature = Validator.LastSignature
 		} else {
 			err = fmt.Errorf("conflicting data")
 		}
@@ -197,7 +273,9 @@ func (Validator *privValidator) signProposal(chainID string, proposal *Proposal)
 	if err != nil {
 		return err
 	}
-	Validator.saveSigned(height, round, step, signBytes, sig)
+	if err := Validator.saveSigned(height, round, step, signBytes, sig); err != nil {
+		return err
+	}
 	proposal.Signature = sig
 	return nil
 }
@@ -230,58 +308,6 @@ func (Validator *privValidator) checkHRS(height uint64, round int, step uint8) (
 	return false, nil
 }
 
-// returns the timestamp from the lastSignBytes.
-// returns true if the only difference in the votes is their timestamp.
-func checkVotesOnlyDifferByTimestamp(lastSignBytes, newSignBytes []byte) (time.Time, bool) {
-	var lastVote, newVote CanonicalJSONVote
-	if err := cdc.UnmarshalJSON(lastSignBytes, &lastVote); err != nil {
-		panic(fmt.Sprintf("LastSignBytes cannot be unmarshalled into vote: %v", err))
-	}
-	if err := cdc.UnmarshalJSON(newSignBytes, &newVote); err != nil {
-		panic(fmt.Sprintf("signBytes cannot be unmarshalled into vote: %v", err))
-	}
-
-	lastTime, err := time.Parse(TimeFormat, lastVote.Timestamp)
-	if err != nil {
-		panic(err)
-	}
-
-	// set the times to the same value and check equality
-	now := CanonicalTime(time.Now())
-	lastVote.Timestamp = now
-	newVote.Timestamp = now
-	lastVoteBytes, _ := cdc.MarshalJSON(lastVote)
-	newVoteBytes, _ := cdc.MarshalJSON(newVote)
-
-	return lastTime, bytes.Equal(newVoteBytes, lastVoteBytes)
-}
-
-// returns the timestamp from the lastSignBytes.
-// returns true if the only difference in the proposals is their timestamp
-func checkProposalsOnlyDifferByTimestamp(lastSignBytes, newSignBytes []byte) (time.Time, bool) {
-	var lastProposal, newProposal CanonicalJSONProposal
-	if err := cdc.UnmarshalJSON(lastSignBytes, &lastProposal); err != nil {
-		panic(fmt.Sprintf("LastSignBytes cannot be unmarshalled into proposal: %v", err))
-	}
-	if err := cdc.UnmarshalJSON(newSignBytes, &newProposal); err != nil {
-		panic(fmt.Sprintf("signBytes cannot be unmarshalled into proposal: %v", err))
-	}
-
-	lastTime, err := time.Parse(TimeFormat, lastProposal.Timestamp)
-	if err != nil {
-		panic(err)
-	}
-
-	// set the times to the same value and check equality
-	now := CanonicalTime(time.Now())
-	lastProposal.Timestamp = now
-	newProposal.Timestamp = now
-	lastProposalBytes, _ := cdc.MarshalJSON(lastProposal)
-	newProposalBytes, _ := cdc.MarshalJSON(newProposal)
-
-	return lastTime, bytes.Equal(newProposalBytes, lastProposalBytes)
-}
-
 //----------------------------------------
 // Misc.
 
@@ -319,17 +345,17 @@ type StateAgent interface {
 	MakePartSet(partSize uint, block *ctypes.Block) (*PartSet, error)
 	ValidateBlock(block *ctypes.Block, result bool) (*KeepBlockSign, error)
 	ConsensusCommit(block *ctypes.Block) error
+	AddEvidence(ev *ctypes.DuplicateVoteEvidence) error
 
 	GetAddress() help.Address
 	GetPubKey() tcrypto.PubKey
 	SignVote(chainID string, vote *Vote) error
 	SignProposal(chainID string, proposal *Proposal) error
-	PrivReset()
 }
 
 //StateAgentImpl agent state struct
 type StateAgentImpl struct {
-	Priv        *privValidator
+	Priv        PrivValidator
 	Agent       ctypes.PbftAgentProxy
 	Validators  *ValidatorSet
 	ids         map[string]interface{}
@@ -393,11 +419,6 @@ func MakeBlockFromPartSet(reader *PartSet) (*ctypes.Block, error) {
 	return nil, errors.New("not complete")
 }
 
-//PrivReset reset PrivValidator
-func (state *StateAgentImpl) PrivReset() {
-	state.Priv.Reset()
-}
-
 // HasPeerID judge the peerid whether in validators
 func (state *StateAgentImpl) HasPeerID(id string) error {
 	if state.ids == nil {
@@ -434,8 +455,7 @@ func (state *StateAgentImpl) GetChainID() string {
 
 //SetPrivValidator set state a new PrivValidator
 func (state *StateAgentImpl) SetPrivValidator(priv PrivValidator) {
-	pp := (priv).(*privValidator)
-	state.Priv = pp
+	state.Priv = priv
 }
 
 //UpdateValidator set new Validators when committee member was changed
@@ -498,6 +518,11 @@ func (state *StateAgentImpl) ConsensusCommit(block *ctypes.Block) error {
 	return nil
 }
 
+//AddEvidence hands the double sign evidence to the agent to be included in a block
+func (state *StateAgentImpl) AddEvidence(ev *ctypes.DuplicateVoteEvidence) error {
+	return state.Agent.AddEvidence(ev)
+}
+
 //ValidateBlock get a verify block if nil return new
 func (state *StateAgentImpl) ValidateBlock(block *ctypes.Block, result bool) (*KeepBlockSign, error) {
 	if block == nil {
@@ -564,7 +589,7 @@ func (state *StateAgentImpl) SignVote(chainID string, vote *Vote) error {
 
 //SignProposal sign of proposal msg
 func (state *StateAgentImpl) SignProposal(chainID string, proposal *Proposal) error {
-	return state.Priv.signProposal(chainID, proposal)
+	return state.Priv.SignProposal(chainID, proposal)
 }
 
 //Broadcast is agent Broadcast block
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"truechain/discovery/crypto"
+)
+
+func TestPrivValidatorStateSurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbft-privval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "1.json")
+
+	key, _ := crypto.GenerateKey()
+	pv, err := NewPrivValidatorWithState(*key, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vote := &Vote{Height: 10, Round: 1, Type: VoteTypePrevote, Timestamp: time.Now().UTC(),
+		BlockID: BlockID{Hash: []byte{1}}}
+	if err := pv.SignVote("1", vote); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted validator must not sign a conflicting vote
+	pv, err = NewPrivValidatorWithState(*key, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflict := &Vote{Height: 10, Round: 1, Type: VoteTypePrevote, Timestamp: time.Now().UTC(),
+		BlockID: BlockID{Hash: []byte{2}}}
+	if err := pv.SignVote("1", conflict); err == nil {
+		t.Fatal("signed a conflicting vote after restart")
+	}
+	// signing the same vote again hands out the same signature
+	same := *vote
+	same.Signature = nil
+	if err := pv.SignVote("1", &same); err != nil {
+		t.Fatal(err)
+	}
+	if string(same.Signature) != string(vote.Signature) {
+		t.Fatal("signature mismatch for the same vote")
+	}
+	// regressions are refused
+	if err := pv.SignProposal("1", NewProposal(9, 0, PartSetHeader{}, 0, BlockID{})); err == nil {
+		t.Fatal("signed a proposal below the last signed height")
+	}
+	state, err := LoadPrivValidatorState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Height != 10 || state.Round != 1 || state.Step != stepPrevote {
+		t.Fatalf("wrong persisted state: %v", state)
+	}
+}
@@ -28,6 +28,17 @@ var (
 	ErrVoteNil = errors.New("nil vote")
 )
 
+// ConflictingVoteError is returned when a validator signed two votes for
+// different blocks at the same height, round and type.
+type ConflictingVoteError struct {
+	VoteA *Vote
+	VoteB *Vote
+}
+
+func (err *ConflictingVoteError) Error() string {
+	return ErrVoteConflictingVotes.Error()
+}
+
 // Types of votes
 // TODO Make a new type "VoteType"
 const (
@@ -134,7 +134,7 @@ func (voteSet *VoteSet) Size() uint {
 //		UnexpectedStep | InvalidIndex | InvalidAddress |
 //		InvalidSignature | InvalidBlockHash | ConflictingVotes ]
 // Duplicate votes return added=false, err=nil.
-// Conflicting votes return added=*, err=ErrVoteConflictingVotes.
+// Conflicting votes return added=*, err=*ConflictingVoteError.
 // NOTE: vote should not be mutated after adding.
 // NOTE: VoteSet must not be nil
 // NOTE: Vote must not be nil
@@ -203,7 +203,7 @@ func (voteSet *VoteSet) addVote(vote *Vote) (added bool, err error) {
 	// Add vote and get conflicting vote if any
 	added, conflicting := voteSet.addVerifiedVote(vote, blockKey, val.VotingPower)
 	if conflicting != nil {
-		return added, ErrVoteConflictingVotes
+		return added, &ConflictingVoteError{VoteA: conflicting, VoteB: vote}
 	}
 	if !added {
 		help.PanicSanity("Expected to add non-conflicting vote")
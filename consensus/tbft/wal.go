@@ -0,0 +1,327 @@
+package tbft
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/tendermint/go-amino"
+	"truechain/discovery/consensus/tbft/help"
+	"truechain/discovery/consensus/tbft/help/autofile"
+	"truechain/discovery/log"
+)
+
+const (
+	// must be greater than params.BlockGossipPartSizeBytes + a few bytes
+	maxMsgSizeBytes = 1024 * 1024 // 1MB
+)
+
+//--------------------------------------------------------
+// types and functions for savings consensus messages
+
+//TimedWALMessage wraps WALMessage and adds Time for debugging purposes.
+type TimedWALMessage struct {
+	Time time.Time  `json:"time"`
+	Msg  WALMessage `json:"msg"`
+}
+
+// EndHeightMessage marks the end of the given height inside WAL.
+type EndHeightMessage struct {
+	Height uint64 `json:"height"`
+}
+
+//WALMessage is the message recorded in the WAL
+type WALMessage interface{}
+
+//RegisterWALMessages register all messages which may be recorded in the WAL
+func RegisterWALMessages(cdc *amino.Codec) {
+	cdc.RegisterInterface((*WALMessage)(nil), nil)
+	cdc.RegisterConcrete(msgInfo{}, "true/wal/MsgInfo", nil)
+	cdc.RegisterConcrete(timeoutInfo{}, "true/wal/TimeoutInfo", nil)
+	cdc.RegisterConcrete(EndHeightMessage{}, "true/wal/EndHeightMessage", nil)
+}
+
+//--------------------------------------------------------
+// Simple write-ahead logger
+
+// WAL is an interface for any write-ahead logger.
+type WAL interface {
+	Write(WALMessage)
+	WriteSync(WALMessage)
+	Group() *autofile.Group
+	SearchForEndHeight(height uint64, options *WALSearchOptions) (gr *autofile.GroupReader, found bool, err error)
+
+	Start() error
+	Stop() error
+	Wait()
+}
+
+// Write ahead logger writes msgs to disk before they are processed.
+// Can be used for crash-recovery and deterministic replay
+type baseWAL struct {
+	help.BaseService
+
+	group *autofile.Group
+
+	enc *WALEncoder
+}
+
+//NewWAL return a WAL recorded in the autofile group at walFile
+func NewWAL(walFile string, groupOptions ...func(*autofile.Group)) (*baseWAL, error) {
+	err := help.EnsureDir(filepath.Dir(walFile), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to ensure WAL directory is in place: %v", err)
+	}
+
+	group, err := autofile.OpenGroup(walFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, option := range groupOptions {
+		option(group)
+	}
+	wal := &baseWAL{
+		group: group,
+		enc:   NewWALEncoder(group),
+	}
+	wal.BaseService = *help.NewBaseService("baseWAL", wal)
+	return wal, nil
+}
+
+func (wal *baseWAL) Group() *autofile.Group {
+	return wal.group
+}
+
+func (wal *baseWAL) OnStart() error {
+	size, err := wal.group.Head.Size()
+	if err != nil {
+		return err
+	} else if size == 0 {
+		wal.WriteSync(EndHeightMessage{0})
+	}
+	err = wal.group.Start()
+	return err
+}
+
+func (wal *baseWAL) OnStop() {
+	help.CheckAndPrintError(wal.group.Stop())
+	wal.group.Close()
+}
+
+// Write is called for each receive on the peerMsgQueue and the timeoutTicker.
+// NOTE: does not call fsync()
+func (wal *baseWAL) Write(msg WALMessage) {
+	if wal == nil {
+		return
+	}
+
+	// Write the wal message
+	if err := wal.enc.Encode(&TimedWALMessage{time.Now(), msg}); err != nil {
+		panic(fmt.Sprintf("Error writing msg to consensus wal: %v \n\nMessage: %v", err, msg))
+	}
+}
+
+// WriteSync is called when we receive a msg from ourselves
+// so that we write to disk before sending signed messages.
+// NOTE: calls fsync()
+func (wal *baseWAL) WriteSync(msg WALMessage) {
+	if wal == nil {
+		return
+	}
+
+	wal.Write(msg)
+	if err := wal.group.Flush(); err != nil {
+		panic(fmt.Sprintf("Error flushing consensus wal buf to file. Error: %v \n", err))
+	}
+}
+
+// WALSearchOptions are optional arguments to SearchForEndHeight.
+type WALSearchOptions struct {
+	// IgnoreDataCorruptionErrors set to true will result in skipping data corruption errors.
+	IgnoreDataCorruptionErrors bool
+}
+
+// SearchForEndHeight searches for the EndHeightMessage with the given height
+// and returns an autofile.GroupReader, whenever it was found or not and an
+// error.
+// Group reader will be nil if found equals false.
+//
+// CONTRACT: caller must close group reader.
+func (wal *baseWAL) SearchForEndHeight(height uint64, options *WALSearchOptions) (gr *autofile.GroupReader, found bool, err error) {
+	var msg *TimedWALMessage
+	lastHeightFound := uint64(0)
+
+	// NOTE: starting from the last file in the group because we're usually
+	// searching for the last height. See catchupReplay
+	min, max := wal.group.MinIndex(), wal.group.MaxIndex()
+	log.Trace("Searching for height", "height", height, "min", min, "max", max)
+	for index := max; index >= min; index-- {
+		gr, err = wal.group.NewReader(index)
+		if err != nil {
+			return nil, false, err
+		}
+
+		dec := NewWALDecoder(gr)
+		for {
+			msg, err = dec.Decode()
+			if err == io.EOF {
+				// OPTIMISATION: no need to look for height in older files if we've seen h < height
+				if lastHeightFound > 0 && lastHeightFound < height {
+					gr.Close()
+					return nil, false, nil
+				}
+				// check next file
+				break
+			}
+			if options.IgnoreDataCorruptionErrors && IsDataCorruptionError(err) {
+				log.Debug("Corrupted entry. Skipping...", "err", err)
+				// do nothing
+				continue
+			} else if err != nil {
+				gr.Close()
+				return nil, false, err
+			}
+
+			if m, ok := msg.Msg.(EndHeightMessage); ok {
+				lastHeightFound = m.Height
+				if m.Height == height { // found
+					log.Trace("Found", "height", height, "index", index)
+					return gr, true, nil
+				}
+			}
+		}
+		gr.Close()
+	}
+
+	return nil, false, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// A WALEncoder writes custom-encoded WAL messages to an output stream.
+//
+// Format: 4 bytes CRC sum + 4 bytes length + arbitrary-length value (go-amino encoded)
+type WALEncoder struct {
+	wr io.Writer
+}
+
+// NewWALEncoder returns a new encoder that writes to wr.
+func NewWALEncoder(wr io.Writer) *WALEncoder {
+	return &WALEncoder{wr}
+}
+
+// Encode writes the custom encoding of v to the stream.
+func (enc *WALEncoder) Encode(v *TimedWALMessage) error {
+	data := cdc.MustMarshalBinaryBare(v)
+
+	crc := crc32.Checksum(data, crc32c)
+	length := uint32(len(data))
+	totalLength := 8 + int(length)
+
+	msg := make([]byte, totalLength)
+	binary.BigEndian.PutUint32(msg[0:4], crc)
+	binary.BigEndian.PutUint32(msg[4:8], length)
+	copy(msg[8:], data)
+
+	_, err := enc.wr.Write(msg)
+
+	return err
+}
+
+///////////////////////////////////////////////////////////////////////////////
+
+// IsDataCorruptionError returns true if data has been corrupted inside WAL.
+func IsDataCorruptionError(err error) bool {
+	_, ok := err.(DataCorruptionError)
+	return ok
+}
+
+// DataCorruptionError is an error that occures if data on disk was corrupted.
+type DataCorruptionError struct {
+	cause error
+}
+
+func (e DataCorruptionError) Error() string {
+	return fmt.Sprintf("DataCorruptionError[%v]", e.cause)
+}
+
+//Cause return the cause of the DataCorruptionError
+func (e DataCorruptionError) Cause() error {
+	return e.cause
+}
+
+// A WALDecoder reads and decodes custom-encoded WAL messages from an input
+// stream. See WALEncoder for the format used.
+//
+// It will also compare the checksums and make sure data size is equal to the
+// length from the header. If that is not the case, error will be returned.
+type WALDecoder struct {
+	rd io.Reader
+}
+
+// NewWALDecoder returns a new decoder that reads from rd.
+func NewWALDecoder(rd io.Reader) *WALDecoder {
+	return &WALDecoder{rd}
+}
+
+// Decode reads the next custom-encoded value from its reader and returns it.
+func (dec *WALDecoder) Decode() (*TimedWALMessage, error) {
+	b := make([]byte, 4)
+
+	_, err := io.ReadFull(dec.rd, b)
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum: %v", err)
+	}
+	crc := binary.BigEndian.Uint32(b)
+
+	b = make([]byte, 4)
+	_, err = io.ReadFull(dec.rd, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read length: %v", err)
+	}
+	length := binary.BigEndian.Uint32(b)
+
+	if length > maxMsgSizeBytes {
+		return nil, DataCorruptionError{fmt.Errorf("length %d exceeded maximum possible value of %d bytes", length, maxMsgSizeBytes)}
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(dec.rd, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %v", err)
+	}
+
+	// check checksum before decoding data
+	actualCRC := crc32.Checksum(data, crc32c)
+	if actualCRC != crc {
+		return nil, DataCorruptionError{fmt.Errorf("checksums do not match: (read: %v, actual: %v)", crc, actualCRC)}
+	}
+
+	var res = new(TimedWALMessage) // nolint: gosimple
+	err = cdc.UnmarshalBinaryBare(data, res)
+	if err != nil {
+		return nil, DataCorruptionError{fmt.Errorf("failed to decode data: %v", err)}
+	}
+
+	return res, err
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type nilWAL struct{}
+
+func (nilWAL) Write(m WALMessage)     {}
+func (nilWAL) WriteSync(m WALMessage) {}
+func (nilWAL) Group() *autofile.Group { return nil }
+func (nilWAL) SearchForEndHeight(height uint64, options *WALSearchOptions) (gr *autofile.GroupReader, found bool, err error) {
+	return nil, false, nil
+}
+func (nilWAL) Start() error { return nil }
+func (nilWAL) Stop() error  { return nil }
+func (nilWAL) Wait()        {}
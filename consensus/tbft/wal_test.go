@@ -0,0 +1,119 @@
+package tbft
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ttypes "truechain/discovery/consensus/tbft/types"
+)
+
+func TestWALEncoderDecoder(t *testing.T) {
+	now := time.Now()
+	msgs := []TimedWALMessage{
+		{Time: now, Msg: EndHeightMessage{10}},
+		{Time: now, Msg: timeoutInfo{Duration: time.Second, Height: 11, Round: 1, Step: ttypes.RoundStepPropose, Wait: 1}},
+		{Time: now, Msg: msgInfo{Msg: &VoteMessage{&ttypes.Vote{Height: 11, Round: 1, Type: ttypes.VoteTypePrevote}}, PeerID: "peer"}},
+	}
+
+	b := new(bytes.Buffer)
+	for _, msg := range msgs {
+		b.Reset()
+
+		enc := NewWALEncoder(b)
+		if err := enc.Encode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		dec := NewWALDecoder(b)
+		decoded, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Time.Equal(decoded.Time) {
+			t.Fatalf("time mismatch: have %v, want %v", decoded.Time, msg.Time)
+		}
+		switch m := msg.Msg.(type) {
+		case EndHeightMessage:
+			if d, ok := decoded.Msg.(EndHeightMessage); !ok || d.Height != m.Height {
+				t.Fatalf("end height mismatch: have %v, want %v", decoded.Msg, m)
+			}
+		case timeoutInfo:
+			if d, ok := decoded.Msg.(timeoutInfo); !ok || d != m {
+				t.Fatalf("timeout mismatch: have %v, want %v", decoded.Msg, m)
+			}
+		case msgInfo:
+			d, ok := decoded.Msg.(msgInfo)
+			if !ok || d.PeerID != m.PeerID {
+				t.Fatalf("msgInfo mismatch: have %v, want %v", decoded.Msg, m)
+			}
+			vote, ok := d.Msg.(*VoteMessage)
+			if !ok || vote.Vote.Height != 11 || vote.Vote.Round != 1 || vote.Vote.Type != ttypes.VoteTypePrevote {
+				t.Fatalf("vote mismatch: have %v", d.Msg)
+			}
+		}
+	}
+}
+
+func TestWALDecoderCorruption(t *testing.T) {
+	b := new(bytes.Buffer)
+	if err := NewWALEncoder(b).Encode(&TimedWALMessage{Time: time.Now(), Msg: EndHeightMessage{1}}); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	_, err := NewWALDecoder(bytes.NewReader(data)).Decode()
+	if !IsDataCorruptionError(err) {
+		t.Fatalf("expected data corruption error, got %v", err)
+	}
+}
+
+func TestWALSearchForEndHeight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tbft-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wal, err := NewWAL(filepath.Join(dir, "wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for h := uint64(1); h <= 3; h++ {
+		wal.Write(msgInfo{Msg: &VoteMessage{&ttypes.Vote{Height: h, Type: ttypes.VoteTypePrecommit}}})
+		wal.WriteSync(EndHeightMessage{h})
+	}
+	wal.Write(timeoutInfo{Height: 4, Step: ttypes.RoundStepPropose})
+	wal.WriteSync(timeoutInfo{Height: 4, Step: ttypes.RoundStepPrevoteWait})
+
+	gr, found, err := wal.SearchForEndHeight(3, &WALSearchOptions{})
+	if err != nil || !found {
+		t.Fatalf("end height not found: %v", err)
+	}
+	dec := NewWALDecoder(gr)
+	var steps []ttypes.RoundStepType
+	for {
+		msg, err := dec.Decode()
+		if err != nil {
+			break
+		}
+		if ti, ok := msg.Msg.(timeoutInfo); ok {
+			steps = append(steps, ti.Step)
+		}
+	}
+	gr.Close()
+	if len(steps) != 2 || steps[0] != ttypes.RoundStepPropose || steps[1] != ttypes.RoundStepPrevoteWait {
+		t.Fatalf("replayed messages mismatch: %v", steps)
+	}
+
+	if gr, found, _ := wal.SearchForEndHeight(5, &WALSearchOptions{}); found || gr != nil {
+		t.Fatal("found end height which was never written")
+	}
+	wal.Stop()
+}
@@ -8,17 +8,21 @@ import (
 
 const (
 	associatedAddressCacheLimit = 10240
+	txAccessSetCacheLimit       = 40960
 )
 
 type AssociatedAddressMngr struct {
-	lruCache *lru.Cache
+	lruCache   *lru.Cache
+	accessSets *lru.Cache // the accounts and storage slots touched by the transactions executed
 }
 
 func NewAssociatedAddressMngr() *AssociatedAddressMngr {
 	lruCache, _ := lru.New(associatedAddressCacheLimit)
+	accessSets, _ := lru.New(txAccessSetCacheLimit)
 
 	return &AssociatedAddressMngr{
-		lruCache: lruCache,
+		lruCache:   lruCache,
+		accessSets: accessSets,
 	}
 }
 
@@ -43,3 +47,22 @@ func (aam *AssociatedAddressMngr) UpdateAssociatedAddresses(associatedAddrs map[
 		aam.lruCache.Add(addr, associatedAddr)
 	}
 }
+
+// LoadTxAccessSets returns the access sets of the transactions executed before.
+func (aam *AssociatedAddressMngr) LoadTxAccessSets(hashes []common.Hash) map[common.Hash]*state.TouchedAddressObject {
+	result := make(map[common.Hash]*state.TouchedAddressObject)
+
+	for _, hash := range hashes {
+		if obj, exist := aam.accessSets.Get(hash); exist {
+			result[hash] = obj.(*state.TouchedAddressObject)
+		}
+	}
+
+	return result
+}
+
+// UpdateTxAccessSet records the accounts and storage slots touched by the
+// transaction, the set mustn't be modified afterwards.
+func (aam *AssociatedAddressMngr) UpdateTxAccessSet(hash common.Hash, accessSet *state.TouchedAddressObject) {
+	aam.accessSets.Add(hash, accessSet)
+}
@@ -90,6 +90,24 @@ func (fv *BlockValidator) ValidateBody(block *types.Block, validateSign bool) er
 		return fmt.Errorf("SwitchInfos root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
 
+	// The header commits to the evidences only if the block carries any
+	evidences := block.Evidences()
+	if (len(evidences) == 0 && len(header.EvidenceHash) != 0) || len(header.EvidenceHash) > 1 {
+		return fmt.Errorf("invalid evidence roots: have %d, evidences %d", len(header.EvidenceHash), len(evidences))
+	}
+	if hash := types.DeriveSha(types.DuplicateVoteEvidences(evidences)); hash != header.EvidenceRoot() {
+		return fmt.Errorf("evidence root hash mismatch: have %x, want %x", hash, header.EvidenceRoot())
+	}
+	if len(evidences) > 0 {
+		if !fv.config.IsTIPSlash(block.Number()) {
+			return consensus.ErrInvalidEvidence
+		}
+		if err := fv.bc.engine.VerifyEvidences(block.Number(), evidences); err != nil {
+			log.Info("Fast VerifyEvidences Err", "number", block.NumberU64(), "evidences", len(evidences), "err", err)
+			return err
+		}
+	}
+
 	if validateSign {
 		if err := fv.bc.engine.VerifySigns(block.Number(), block.Hash(), block.Signs()); err != nil {
 			log.Info("Fast VerifySigns Err", "number", block.NumberU64(), "signs", block.Signs())
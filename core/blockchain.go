@@ -33,6 +33,7 @@ import (
 	"truechain/discovery/consensus"
 	"truechain/discovery/core/rawdb"
 	"truechain/discovery/core/state"
+	"truechain/discovery/core/state/snapshot"
 	"truechain/discovery/core/types"
 	"truechain/discovery/core/vm"
 	"truechain/discovery/crypto"
@@ -79,6 +80,7 @@ type CacheConfig struct {
 	HeightGcState  atomic.Value  // height  mark delete body and receipt
 	Deleted        bool          // Whether to delete body and receipt
 	Disabled       bool          // Whether to disable trie write caching (archive node)
+	NoSnapshot     bool          // Whether to disable the flat state snapshot
 	TrieCleanLimit int           // Memory allowance (MB) to use for caching trie nodes in memory
 	TrieNodeLimit  int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieTimeLimit  time.Duration // Time limit after which to flush the current in-memory trie to disk
@@ -126,6 +128,7 @@ type BlockChain struct {
 	currentReward    atomic.Value // Current head of the currentReward
 
 	stateCache       state.Database // State database to reuse between imports (contains state cache)
+	snaps            *snapshot.Tree // Flat snapshot of the head state, nil if disabled
 	bodyCache        *lru.Cache     // Cache for the most recent block bodies
 	signCache        *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache     *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
@@ -216,6 +219,11 @@ func NewBlockChain(db etruedb.Database, cacheConfig *CacheConfig,
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	if !cacheConfig.NoSnapshot {
+		if bc.snaps, err = snapshot.New(db, bc.stateCache.TrieDB(), bc.CurrentBlock().Root()); err != nil {
+			log.Warn("State snapshot disabled", "err", err)
+		}
+	}
 	// Check the current state of the block hashes and make sure that we do not have any of the bad blocks in our chain
 	for hash := range BadHashes {
 		if header := bc.GetHeaderByHash(hash); header != nil {
@@ -545,6 +553,11 @@ func (bc *BlockChain) StateCache() state.Database {
 	return bc.stateCache
 }
 
+// Snapshot returns the flat snapshot of the head state, nil if disabled.
+func (bc *BlockChain) Snapshot() *snapshot.Tree {
+	return bc.snaps
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -816,6 +829,9 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	if bc.snaps != nil {
+		bc.snaps.Stop()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -1085,6 +1101,11 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return NonStatTy, err
 	}
+	if bc.snaps != nil {
+		if parent := bc.GetHeaderByHash(block.ParentHash()); parent != nil {
+			bc.snaps.Update(parent.Root, root)
+		}
+	}
 	triedb := bc.stateCache.TrieDB()
 
 	balanceC := &types.BlockBalance{Balance: types.ToBalanceInfos(state.BalancesChange())}
@@ -1861,6 +1882,11 @@ func (bc *BlockChain) stateGcBodyAndReceipt(gcNumber uint64) {
 func (bc *BlockChain) SetCommitteeInfo(hash common.Hash, number uint64, infos []*types.CommitteeMember) {
 }
 
+// SetSigns keeps the committee signs of a header for light client, a full node
+// verifies them with its block bodies.
+func (bc *BlockChain) SetSigns(hash common.Hash, number uint64, signs []*types.PbftSign) {
+}
+
 // SubscribeBlockProcessingEvent registers a subscription of bool where true means
 // block processing has started while false means it has stopped.
 func (bc *BlockChain) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscription {
@@ -1882,6 +1908,7 @@ func (bc *BlockChain) GetRewardInfos(number uint64) *types.ChainReward {
 func (bc *BlockChain) WriteRewardInfos(infos *types.ChainReward) error {
 	number := infos.Height
 	rawdb.WriteRewardInfo(bc.db, number, infos)
+	rawdb.WriteRewardLedger(bc.db, infos)
 	return nil
 }
 
@@ -221,7 +221,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 		}
 
 		if b.engine != nil {
-			block, _, err := b.engine.Finalize(chainreader, b.header, statedb, b.txs, b.receipts, b.feeAmout, true)
+			block, _, err := b.engine.Finalize(chainreader, b.header, statedb, b.txs, b.receipts, nil, b.feeAmout, true)
 			if err != nil {
 				fmt.Println(" err ", err.Error())
 			}
@@ -273,7 +273,7 @@ func GenerateChainWithReward(config *params.ChainConfig, parent *types.Block, re
 		}
 
 		if b.engine != nil {
-			block, _, _ := b.engine.Finalize(chainreader, b.header, statedb, b.txs, b.receipts, new(big.Int), true)
+			block, _, _ := b.engine.Finalize(chainreader, b.header, statedb, b.txs, b.receipts, nil, new(big.Int), true)
 
 			sign, err := b.engine.GetElection().GenerateFakeSigns(block)
 			block.SetSign(sign)
@@ -84,9 +84,11 @@ func (e *ExecutionGroup) AddFeeAmount(feeAmount *big.Int) {
 
 func (e *ExecutionGroup) reuseTxResults(txsToReuse []*txInfo, conflictGroups map[int]*ExecutionGroup) {
 	stateObjsFromOtherGroup := make(map[int]map[common.Address]struct{})
+	slotsFromOtherGroup := make(map[int]map[common.Address]map[common.Hash]struct{})
 
 	for gId, _ := range conflictGroups {
 		stateObjsFromOtherGroup[gId] = make(map[common.Address]struct{})
+		slotsFromOtherGroup[gId] = make(map[common.Address]map[common.Hash]struct{})
 	}
 
 	for _, txInfo := range txsToReuse {
@@ -95,14 +97,38 @@ func (e *ExecutionGroup) reuseTxResults(txsToReuse []*txInfo, conflictGroups map
 		result := txInfo.result
 
 		appendStateObjToReuse(stateObjsFromOtherGroup[oldGroupId], result.touchedAddresses)
+		appendWrittenSlots(slotsFromOtherGroup[oldGroupId], result.touchedAddresses)
 		e.statedb.CopyJournalLogPreImageFromOtherDB(conflictGroups[oldGroupId].statedb, txHash)
 		e.AddUsedGas(result.usedGas)
 		e.AddFeeAmount(result.feeAmount)
 		txInfo.groupId = e.id
 	}
 
+	// Copy the accounts written first, the slots written by the other groups are merged into them
+	copied := make(map[common.Address]struct{})
 	for gId, stateObjsMap := range stateObjsFromOtherGroup {
-		e.statedb.CopyStateObjFromOtherDB(conflictGroups[gId].statedb, stateObjsMap)
+		accounts := make(map[common.Address]struct{})
+		for addr := range stateObjsMap {
+			if slotsFromOtherGroup[gId][addr] == nil {
+				accounts[addr] = struct{}{}
+				copied[addr] = struct{}{}
+			}
+		}
+		e.statedb.CopyStateObjFromOtherDB(conflictGroups[gId].statedb, accounts)
+	}
+	for gId, stateObjsMap := range stateObjsFromOtherGroup {
+		for addr := range stateObjsMap {
+			slots := slotsFromOtherGroup[gId][addr]
+			if slots == nil {
+				continue
+			}
+			if _, ok := copied[addr]; ok {
+				e.statedb.MergeStorageFromOtherDB(conflictGroups[gId].statedb, addr, slots)
+			} else {
+				e.statedb.CopyStateObjFromOtherDB(conflictGroups[gId].statedb, map[common.Address]struct{}{addr: {}})
+				copied[addr] = struct{}{}
+			}
+		}
 	}
 }
 
@@ -115,3 +141,30 @@ func appendStateObjToReuse(stateObjsToReuse map[common.Address]struct{}, touched
 		}
 	}
 }
+
+// appendWrittenSlots collects the storage slots written of the accounts whose
+// balance, nonce and code are untouched, the others are mapped to nil.
+func appendWrittenSlots(writtenSlots map[common.Address]map[common.Hash]struct{}, touchedAddr *state.TouchedAddressObject) {
+	for addr, op := range touchedAddr.AccountOp() {
+		if !op {
+			continue
+		}
+		slots, exist := writtenSlots[addr]
+		if !touchedAddr.StorageOnly(addr) {
+			writtenSlots[addr] = nil
+			continue
+		}
+		if exist && slots == nil {
+			continue
+		}
+		if slots == nil {
+			slots = make(map[common.Hash]struct{})
+			writtenSlots[addr] = slots
+		}
+		for slot, sop := range touchedAddr.StorageOp(addr) {
+			if sop {
+				slots[slot] = struct{}{}
+			}
+		}
+	}
+}
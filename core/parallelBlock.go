@@ -12,12 +12,18 @@ import (
 	"truechain/discovery/core/state"
 	"truechain/discovery/core/types"
 	"truechain/discovery/core/vm"
+	"truechain/discovery/metrics"
 	"truechain/discovery/params"
 )
 
 var (
 	associatedAddressMngr = NewAssociatedAddressMngr()
 	cpuNum                = runtime.NumCPU()
+
+	parallelGroupsHistogram       = metrics.NewRegisteredHistogram("chain/parallel/groups", nil, metrics.NewExpDecaySample(1028, 0.015))
+	parallelConflictRateHistogram = metrics.NewRegisteredHistogram("chain/parallel/conflictrate", nil, metrics.NewExpDecaySample(1028, 0.015))
+	parallelConflictMeter         = metrics.NewRegisteredMeter("chain/parallel/conflicts", nil)
+	parallelRegroupMeter          = metrics.NewRegisteredMeter("chain/parallel/regroups", nil)
 )
 
 type ParallelBlock struct {
@@ -25,18 +31,24 @@ type ParallelBlock struct {
 	txInfos              []*txInfo
 	executionGroups      map[int]*ExecutionGroup
 	associatedAddressMap map[common.Address]*state.TouchedAddressObject
+	txAccessSets         map[common.Hash]*state.TouchedAddressObject
 	nextGroupId          int
 	statedb              *state.StateDB
 	config               *params.ChainConfig
 	context              ChainContext
 	vmConfig             vm.Config
 	feeAmount            *big.Int
+	slotLevel            bool // the conflicts are detected by storage slot
+
+	groups    int // the execution groups of the first grouping
+	regroups  int // the times the conflicting groups are regrouped
+	conflicts int // the transactions conflicting with another group
 }
 
 type grouper struct {
 	executionGroupMap      map[int]*ExecutionGroup
-	groupWrittenAccountMap map[int]map[common.Address]struct{}
-	addrToGroupMap         map[common.Address]int
+	groupWrittenAccountMap map[int]map[state.TouchedKey]struct{}
+	addrToGroupMap         map[state.TouchedKey]int
 	groupId                int
 	regroup                bool
 	maxGroupCount          int
@@ -61,6 +73,7 @@ type addressRlpDataPair struct {
 	address  common.Address
 	stateObj interface{}
 	rlpData  []byte
+	slots    map[common.Hash]struct{} // the storage slots written, nil if the account is written
 }
 
 func newGrouper(totalTxToGroup int, startGroupId int, regroup bool) *grouper {
@@ -69,8 +82,8 @@ func newGrouper(totalTxToGroup int, startGroupId int, regroup bool) *grouper {
 	avgTxCountInGroup := (totalTxToGroup + maxGroupCount - 1) / (maxGroupCount)
 	return &grouper{
 		executionGroupMap:      make(map[int]*ExecutionGroup),
-		groupWrittenAccountMap: make(map[int]map[common.Address]struct{}),
-		addrToGroupMap:         make(map[common.Address]int),
+		groupWrittenAccountMap: make(map[int]map[state.TouchedKey]struct{}),
+		addrToGroupMap:         make(map[state.TouchedKey]int),
 		groupId:                startGroupId,
 		regroup:                regroup,
 		maxGroupCount:          maxGroupCount,
@@ -81,18 +94,18 @@ func newGrouper(totalTxToGroup int, startGroupId int, regroup bool) *grouper {
 
 func (gp *grouper) groupNewTxInfo(txInfo *txInfo, pb *ParallelBlock) {
 	groupsToMerge := make(map[int]struct{})
-	groupWrittenAccount := make(map[common.Address]struct{})
+	groupWrittenAccount := make(map[state.TouchedKey]struct{})
 	firstGroup := true
 	var tmpExecutionGroup *ExecutionGroup
 	trxTouchedAddress := pb.getTrxTouchedAddress(txInfo, gp.regroup)
 
-	for addr, op := range trxTouchedAddress.AccountOp() {
-		if gId, ok := gp.addrToGroupMap[addr]; ok {
+	for key, op := range trxTouchedAddress.KeyOp(pb.slotLevel) {
+		if gId, ok := gp.addrToGroupMap[key]; ok {
 			groupsToMerge[gId] = struct{}{}
 		}
 		if op {
-			groupWrittenAccount[addr] = struct{}{}
-			gp.addrToGroupMap[addr] = gp.groupId
+			groupWrittenAccount[key] = struct{}{}
+			gp.addrToGroupMap[key] = gp.groupId
 		}
 	}
 
@@ -159,6 +172,7 @@ func NewParallelBlock(block *types.Block, statedb *state.StateDB, config *params
 		context:         bc,
 		vmConfig:        cfg,
 		feeAmount:       feeAmount,
+		slotLevel:       !cfg.AddressConflicts,
 	}
 }
 
@@ -279,9 +293,18 @@ func (pb *ParallelBlock) getTrxTouchedAddress(txInfo *txInfo, regroup bool) *sta
 			return result.touchedAddresses
 		}
 	}
+	msg := txInfo.msg
+
+	// The access set recorded by an earlier execution of the transaction
+	if accessSet, ok := pb.txAccessSets[txInfo.hash]; ok {
+		touchedAddressObj := accessSet.Copy()
+		if to := msg.To(); to != nil && msg.Value().Sign() > 0 {
+			touchedAddressObj.AddAccountOp(*to, true)
+		}
+		return touchedAddressObj
+	}
 
 	touchedAddressObj := state.NewTouchedAddressObject()
-	msg := txInfo.msg
 
 	if msg.Payment() != params.EmptyAddress {
 		touchedAddressObj.AddAccountOp(msg.Payment(), true)
@@ -307,7 +330,7 @@ type conflictInfo struct {
 func (pb *ParallelBlock) checkGroupsConflict(ch0 chan *txInfo, ch1 chan *conflictInfo) {
 	var conflictGroups []map[int]struct{}
 	conflictTxs := make(map[common.Hash]struct{})
-	addrGroupIdsMap := make(map[common.Address]map[int]struct{})
+	addrGroupIdsMap := make(map[state.TouchedKey]map[int]struct{})
 	txInfos := make([]*txInfo, len(pb.txInfos))
 	nextIndex := 0
 
@@ -321,8 +344,8 @@ func (pb *ParallelBlock) checkGroupsConflict(ch0 chan *txInfo, ch1 chan *conflic
 				curTrxGroup := txInfo.groupId
 				touchedAddressObj = pb.getTrxTouchedAddress(txInfo, true)
 
-				for addr, op := range touchedAddressObj.AccountOp() {
-					if groupIds, ok := addrGroupIdsMap[addr]; ok {
+				for key, op := range touchedAddressObj.KeyOp(pb.slotLevel) {
+					if groupIds, ok := addrGroupIdsMap[key]; ok {
 						if _, ok := groupIds[curTrxGroup]; !ok {
 							groupIds[curTrxGroup] = struct{}{}
 							conflictTxs[trxHash] = struct{}{}
@@ -330,7 +353,7 @@ func (pb *ParallelBlock) checkGroupsConflict(ch0 chan *txInfo, ch1 chan *conflic
 					} else if op {
 						groupSet := make(map[int]struct{})
 						groupSet[curTrxGroup] = struct{}{}
-						addrGroupIdsMap[addr] = groupSet
+						addrGroupIdsMap[key] = groupSet
 					}
 				}
 			}
@@ -432,9 +455,11 @@ func (pb *ParallelBlock) execute(group *ExecutionGroup, ch chan *txInfo, chForUp
 		return
 	}
 	stateObjsToReuse := make(map[common.Address]struct{})
+	writtenSlots := make(map[common.Address]map[common.Hash]struct{})
 	for _, txInfo := range group.txInfos {
 		if result := txInfo.result; result != nil {
 			appendStateObjToReuse(stateObjsToReuse, result.touchedAddresses)
+			appendWrittenSlots(writtenSlots, result.touchedAddresses)
 		}
 	}
 
@@ -449,6 +474,7 @@ func (pb *ParallelBlock) execute(group *ExecutionGroup, ch chan *txInfo, chForUp
 					address:  addr,
 					stateObj: stateObj,
 					rlpData:  data,
+					slots:    writtenSlots[addr],
 				}
 			}
 		}
@@ -495,6 +521,8 @@ func (pb *ParallelBlock) executeInParallelAndCheckConflict() (types.Receipts, []
 		if len(pb.executionGroups) != 1 {
 			result := <-conflictInfoCh
 			if len(result.conflictGroups) != 0 {
+				pb.regroups++
+				pb.conflicts += len(result.conflictTxs)
 				for _, ch := range chsForExist {
 					close(ch)
 				}
@@ -556,6 +584,7 @@ func (pb *ParallelBlock) prepare() error {
 	txCount := pb.block.Transactions().Len()
 	contractAddrs := make([]common.Address, 0, txCount)
 	chForError := make(chan error, txCount)
+	pb.txAccessSets = associatedAddressMngr.LoadTxAccessSets(txHashes(pb.block.Transactions()))
 	wg := sync.WaitGroup{}
 
 	for ti, tx := range pb.block.Transactions() {
@@ -592,6 +621,7 @@ func (pb *ParallelBlock) prepareAndGroup() error {
 	chForTxInfo := make(chan *txInfo, txCount)
 	chForGroup := make(chan bool)
 
+	pb.txAccessSets = associatedAddressMngr.LoadTxAccessSets(txHashes(pb.block.Transactions()))
 	go pb.group(chForTxInfo, chForGroup)
 
 	for ti, tx := range pb.block.Transactions() {
@@ -619,6 +649,7 @@ func (pb *ParallelBlock) processAssociatedAddressOfContract(ch chan *txInfo) {
 	associatedAddrs := make(map[common.Address]*state.TouchedAddressObject)
 
 	for txInfo := range ch {
+		associatedAddressMngr.UpdateTxAccessSet(txInfo.hash, txInfo.result.touchedAddresses)
 		if to := txInfo.tx.To(); to != nil {
 			touchedAddr := txInfo.result.touchedAddresses.Copy()
 			msg := txInfo.msg
@@ -626,7 +657,9 @@ func (pb *ParallelBlock) processAssociatedAddressOfContract(ch chan *txInfo) {
 			touchedAddr.RemoveAccount(msg.From())
 			touchedAddr.RemoveAccount(msg.Payment())
 			touchedAddr.RemoveAccountsInArgs()
-			if len(touchedAddr.AccountOp()) > 1 {
+			// The slots differ from one call to another, only the accounts are associated
+			touchedAddr.RemoveStorage()
+			if len(touchedAddr.AccountOp()) > 1 || touchedAddr.StorageOnly(*to) {
 				associatedAddrs[*to] = touchedAddr
 			}
 		}
@@ -638,8 +671,34 @@ func (pb *ParallelBlock) processAssociatedAddressOfContract(ch chan *txInfo) {
 func (pb *ParallelBlock) updateStateDB(ch chan *addressRlpDataPair, chForFinish chan *state.StateDB) {
 	if len(pb.executionGroups) != 1 {
 		db := pb.statedb.Copy()
+		// The storage slots written by the groups which left the account untouched
+		storageMerged := make(map[common.Address][]*addressRlpDataPair)
+		deleted := make(map[common.Address]struct{})
 		for addrData := range ch {
-			db.UpdateDBTrie(addrData.address, addrData.stateObj, addrData.rlpData)
+			addr := addrData.address
+			merged, exist := storageMerged[addr]
+			if _, ok := deleted[addr]; ok {
+				// The account is destructed by another group after the slots are written
+				continue
+			}
+			if exist && addrData.slots != nil {
+				db.MergeDBStorage(addr, addrData.stateObj, addrData.slots)
+			} else {
+				// The account written by a group is the base of the slots of the others
+				db.UpdateDBTrie(addr, addrData.stateObj, addrData.rlpData)
+				if addrData.rlpData == nil {
+					deleted[addr] = struct{}{}
+					continue
+				}
+				for _, other := range merged {
+					db.MergeDBStorage(addr, other.stateObj, other.slots)
+				}
+			}
+			if addrData.slots != nil {
+				storageMerged[addr] = append(merged, addrData)
+			} else if !exist {
+				storageMerged[addr] = nil
+			}
 		}
 		chForFinish <- db
 	} else {
@@ -651,11 +710,31 @@ func (pb *ParallelBlock) Process() (types.Receipts, []*types.Log, uint64, error)
 	if pb.block.Transactions().Len() == 0 {
 		return nil, nil, 0, nil
 	}
+	if pb.slotLevel {
+		// The group states are copied from it and record the slots too
+		pb.statedb.SetTrackSlots(true)
+		defer pb.statedb.SetTrackSlots(false)
+	}
 	if err := pb.prepareAndGroup(); err != nil {
 		return nil, nil, 0, err
 	}
+	pb.groups = len(pb.executionGroups)
+
+	receipts, logs, usedGas, err := pb.executeInParallelAndCheckConflict()
 
-	return pb.executeInParallelAndCheckConflict()
+	parallelGroupsHistogram.Update(int64(pb.groups))
+	parallelConflictRateHistogram.Update(int64(pb.conflicts * 100 / len(pb.txInfos)))
+	parallelConflictMeter.Mark(int64(pb.conflicts))
+	parallelRegroupMeter.Mark(int64(pb.regroups))
+	return receipts, logs, usedGas, err
+}
+
+func txHashes(txs types.Transactions) []common.Hash {
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	return hashes
 }
 
 func sortTxInfosByIndex(txInfos []*txInfo) []*txInfo {
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	ethash "truechain/discovery/consensus/minerva"
+	"truechain/discovery/core/state"
+	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
+	"truechain/discovery/crypto"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/params"
+)
+
+// tokenCode moves the amount in the second word of the input from the balance
+// of the caller to the one of the address in the first word, the balances are
+// stored in the slots keyed by the addresses.
+var tokenCode = common.Hex2Bytes("33546020359003335560003554602035016000355500")
+
+// newTokenTransferBlock generates a block of token transfers, each between
+// its own sender and recipient.
+func newTokenTransferBlock(txCount int) (*StateProcessor, etruedb.Database, *types.Block, *types.Block) {
+	var (
+		db     = etruedb.NewMemDatabase()
+		token  = common.HexToAddress("0x0100")
+		engine = ethash.NewFaker()
+		keys   = make([]*ecdsa.PrivateKey, txCount)
+		alloc  = types.GenesisAlloc{token: {Code: tokenCode, Storage: map[common.Hash]common.Hash{}, Balance: big.NewInt(0)}}
+	)
+	for i := range keys {
+		key, _ := crypto.GenerateKey()
+		keys[i] = key
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		alloc[addr] = types.GenesisAccount{Balance: big.NewInt(params.Ether)}
+		alloc[token].Storage[common.BytesToHash(addr.Bytes())] = common.BigToHash(big.NewInt(1000))
+	}
+	gspec := &Genesis{Config: params.TestChainConfig, GasLimit: 100000000, Alloc: alloc}
+	genesis := gspec.MustFastCommit(db)
+	signer := types.NewTIP1Signer(gspec.Config.ChainID)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, block *BlockGen) {
+		for j, key := range keys {
+			to := common.BigToAddress(big.NewInt(int64(0x1000 + j)))
+			data := append(common.LeftPadBytes(to.Bytes(), 32), common.LeftPadBytes([]byte{1}, 32)...)
+			tx, err := types.SignTx(types.NewTransaction(0, token, big.NewInt(0), 100000, nil, data), signer, key)
+			if err != nil {
+				panic(err)
+			}
+			block.AddTx(tx)
+		}
+	})
+	return NewStateProcessor(gspec.Config, nil, engine), db, genesis, blocks[0]
+}
+
+// executeTokenTransfers executes the block on the state of the genesis and
+// returns the intermediate root.
+func executeTokenTransfers(t testing.TB, processor *StateProcessor, db etruedb.Database, genesis, block *types.Block, cfg vm.Config) (*BlockExecution, common.Hash) {
+	statedb, err := state.New(genesis.Root(), state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	res, err := processor.Execute(block, statedb, cfg)
+	if err != nil {
+		t.Fatalf("failed to execute block: %v", err)
+	}
+	return res, statedb.IntermediateRoot(true)
+}
+
+// Tests that the transfers of a token are executed in parallel if the conflicts
+// are detected by storage slot, and the state is the same as the sequential one.
+func TestParallelTokenTransfers(t *testing.T) {
+	processor, db, genesis, block := newTokenTransferBlock(64)
+
+	_, want := executeTokenTransfers(t, processor, db, genesis, block, vm.Config{ExecutionMode: vm.SequentialExecution})
+
+	res, root := executeTokenTransfers(t, processor, db, genesis, block, vm.Config{AddressConflicts: true})
+	if root != want {
+		t.Errorf("account conflicts: root mismatch: have %x, want %x", root, want)
+	}
+	if res.Groups != 1 {
+		t.Errorf("account conflicts: groups mismatch: have %d, want 1", res.Groups)
+	}
+	res, root = executeTokenTransfers(t, processor, db, genesis, block, vm.Config{})
+	if root != want {
+		t.Errorf("slot conflicts: root mismatch: have %x, want %x", root, want)
+	}
+	if res.Groups < 2 || res.Conflicts != 0 {
+		t.Errorf("slot conflicts: have %d groups and %d conflicts, want parallel groups", res.Groups, res.Conflicts)
+	}
+}
+
+func benchmarkTokenTransfers(b *testing.B, cfg vm.Config, cold bool) {
+	processor, db, genesis, block := newTokenTransferBlock(512)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if cold {
+			// Drop the access sets recorded, the transfers are grouped by prediction
+			associatedAddressMngr = NewAssociatedAddressMngr()
+		}
+		executeTokenTransfers(b, processor, db, genesis, block, cfg)
+	}
+}
+
+func BenchmarkTokenTransfersSequential(b *testing.B) {
+	benchmarkTokenTransfers(b, vm.Config{ExecutionMode: vm.SequentialExecution}, false)
+}
+
+func BenchmarkTokenTransfersAccountConflicts(b *testing.B) {
+	benchmarkTokenTransfers(b, vm.Config{AddressConflicts: true}, false)
+}
+
+func BenchmarkTokenTransfersSlotConflicts(b *testing.B) {
+	benchmarkTokenTransfers(b, vm.Config{}, false)
+}
+
+func BenchmarkTokenTransfersSlotConflictsCold(b *testing.B) {
+	benchmarkTokenTransfers(b, vm.Config{}, true)
+}
@@ -19,6 +19,7 @@ package rawdb
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
 	"math/big"
 	"truechain/discovery/common"
 	"truechain/discovery/core/types"
@@ -396,7 +397,7 @@ func ReadBlock(db DatabaseReader, hash common.Hash, number uint64) *types.Block
 	if body == nil {
 		return nil
 	}
-	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Signs, body.Infos)
+	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Signs, body.Infos, body.Evidences)
 }
 
 // ReadSnapBlock retrieves an snap block corresponding to the hash, assembling it
@@ -547,6 +548,92 @@ func DeleteRewardInfo(db DatabaseDeleter, snailHeight uint64) {
 	}
 }
 
+// ReadRewardLedgerCount retrieves the number of the reward ledger entries of the address.
+func ReadRewardLedgerCount(db DatabaseReader, addr common.Address) uint64 {
+	data, _ := db.Get(rewardLedgerCountKey(addr))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// ReadRewardLedgerEntry retrieves the seq'th reward ledger entry of the address.
+func ReadRewardLedgerEntry(db DatabaseReader, addr common.Address, seq uint64) *types.RewardLedgerEntry {
+	data, _ := db.Get(rewardLedgerKey(addr, seq))
+	if len(data) == 0 {
+		return nil
+	}
+	entry := new(types.RewardLedgerEntry)
+	if err := rlp.Decode(bytes.NewReader(data), entry); err != nil {
+		log.Error("Invalid reward ledger entry RLP", "address", addr, "seq", seq, "err", err)
+		return nil
+	}
+	return entry
+}
+
+// WriteRewardLedger appends the committee rewards of the snail block to the
+// reward ledgers of the addresses rewarded. The entries of the same or the
+// higher snail blocks written before a rewind are replaced.
+func WriteRewardLedger(db DatabaseReadWriter, reward *types.ChainReward) {
+	for addr, entry := range reward.LedgerEntries() {
+		seq := ReadRewardLedgerCount(db, addr)
+		for seq > 0 {
+			last := ReadRewardLedgerEntry(db, addr, seq-1)
+			if last != nil && last.SnailNumber < entry.SnailNumber {
+				break
+			}
+			seq--
+		}
+		data, err := rlp.EncodeToBytes(entry)
+		if err != nil {
+			log.Crit("Failed to RLP encode reward ledger entry", "err", err)
+		}
+		if err := db.Put(rewardLedgerKey(addr, seq), data); err != nil {
+			log.Crit("Failed to store reward ledger entry", "err", err)
+		}
+		if err := db.Put(rewardLedgerCountKey(addr), encodeBlockNumber(seq+1)); err != nil {
+			log.Crit("Failed to store reward ledger count", "err", err)
+		}
+	}
+}
+
+// searchRewardLedger returns the first seq of the ledger entries of the
+// address at or above the snail number.
+func searchRewardLedger(db DatabaseReader, addr common.Address, count, number uint64) uint64 {
+	lo, hi := uint64(0), count
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if entry := ReadRewardLedgerEntry(db, addr, mid); entry != nil && entry.SnailNumber < number {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// ReadRewardLedger retrieves at most limit reward ledger entries of the
+// address between the snail blocks from and to, skipping the first offset
+// ones, and the number of all the entries in the range.
+func ReadRewardLedger(db DatabaseReader, addr common.Address, from, to uint64, offset, limit int) ([]*types.RewardLedgerEntry, int) {
+	count := ReadRewardLedgerCount(db, addr)
+	if count == 0 || from > to {
+		return nil, 0
+	}
+	begin := searchRewardLedger(db, addr, count, from)
+	end := count
+	if to < math.MaxUint64 {
+		end = searchRewardLedger(db, addr, count, to+1)
+	}
+	var entries []*types.RewardLedgerEntry
+	for seq := begin + uint64(offset); seq < end && len(entries) < limit; seq++ {
+		if entry := ReadRewardLedgerEntry(db, addr, seq); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, int(end - begin)
+}
+
 func WriteBalanceInfo(db DatabaseWriter, height uint64, infos *types.BlockBalance) {
 	data, err := rlp.EncodeToBytes(infos)
 	if err != nil {
@@ -0,0 +1,101 @@
+package rawdb
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/types"
+	"truechain/discovery/etruedb"
+)
+
+var (
+	ledgerValidator = common.HexToAddress("0x01")
+	ledgerDelegator = common.HexToAddress("0x02")
+)
+
+// newLedgerReward rewards the validator and its delegator in the snail block.
+func newLedgerReward(number uint64) *types.ChainReward {
+	committee := []*types.SARewardInfos{{Items: []*types.RewardInfo{
+		{Address: ledgerValidator, Amount: big.NewInt(int64(number) * 10), Staking: big.NewInt(100)},
+		{Address: ledgerDelegator, Amount: big.NewInt(int64(number)), Staking: big.NewInt(10)},
+	}}}
+	return types.NewChainReward(number, number*100, nil, nil, nil, committee)
+}
+
+func TestRewardLedger(t *testing.T) {
+	db := etruedb.NewMemDatabase()
+	for _, number := range []uint64{1, 2, 3, 5, 8} {
+		WriteRewardLedger(db, newLedgerReward(number))
+	}
+	// rewinding to the snail block 5 replaces the entries of 5 and 8
+	WriteRewardLedger(db, newLedgerReward(5))
+
+	tests := []struct {
+		addr          common.Address
+		from, to      uint64
+		offset, limit int
+		want          []uint64
+		total         int
+	}{
+		{ledgerDelegator, 0, math.MaxUint64, 0, 10, []uint64{1, 2, 3, 5}, 4},
+		{ledgerDelegator, 2, 4, 0, 10, []uint64{2, 3}, 2},
+		{ledgerDelegator, 4, 4, 0, 10, nil, 0},
+		{ledgerDelegator, 0, math.MaxUint64, 1, 2, []uint64{2, 3}, 4},
+		{ledgerDelegator, 0, math.MaxUint64, 4, 2, nil, 4},
+		{ledgerDelegator, 5, 1, 0, 10, nil, 0},
+		{ledgerValidator, 3, math.MaxUint64, 0, 10, []uint64{3, 5}, 2},
+		{common.HexToAddress("0x03"), 0, math.MaxUint64, 0, 10, nil, 0},
+	}
+	for i, tt := range tests {
+		entries, total := ReadRewardLedger(db, tt.addr, tt.from, tt.to, tt.offset, tt.limit)
+		if total != tt.total {
+			t.Errorf("test %d: total mismatch: have %d, want %d", i, total, tt.total)
+		}
+		if len(entries) != len(tt.want) {
+			t.Errorf("test %d: entries mismatch: have %d, want %d", i, len(entries), len(tt.want))
+			continue
+		}
+		for j, entry := range entries {
+			if entry.SnailNumber != tt.want[j] || entry.Time != tt.want[j]*100 {
+				t.Errorf("test %d: entry %d mismatch: have %d/%d, want %d", i, j, entry.SnailNumber, entry.Time, tt.want[j])
+			}
+			if len(entry.Items) != 1 || entry.Items[0].Validator != ledgerValidator {
+				t.Errorf("test %d: entry %d has wrong items: %v", i, j, entry.Items)
+			}
+		}
+	}
+	if count := ReadRewardLedgerCount(db, ledgerDelegator); count != 4 {
+		t.Errorf("ledger count mismatch after rewind: have %d, want 4", count)
+	}
+}
+
+func TestRewardLedgerEntries(t *testing.T) {
+	reward := newLedgerReward(7)
+	reward.CommitteeBase[0].Items = append(reward.CommitteeBase[0].Items,
+		&types.RewardInfo{Address: common.HexToAddress("0x04"), Amount: big.NewInt(0)},
+		&types.RewardInfo{Address: common.HexToAddress("0x05")},
+	)
+	entries := reward.LedgerEntries()
+	if len(entries) != 2 {
+		t.Fatalf("ledger entries mismatch: have %d, want 2", len(entries))
+	}
+	tests := []struct {
+		addr            common.Address
+		amount, staking int64
+	}{
+		{ledgerValidator, 70, 100},
+		{ledgerDelegator, 7, 10},
+	}
+	for i, tt := range tests {
+		entry := entries[tt.addr]
+		if entry == nil || len(entry.Items) != 1 {
+			t.Fatalf("test %d: missing entry", i)
+		}
+		item := entry.Items[0]
+		if item.Amount.Int64() != tt.amount || item.Staking.Int64() != tt.staking || item.Validator != ledgerValidator {
+			t.Errorf("test %d: item mismatch: %+v", i, item)
+		}
+	}
+}
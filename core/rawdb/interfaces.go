@@ -31,3 +31,9 @@ type DatabaseWriter interface {
 type DatabaseDeleter interface {
 	Delete(key []byte) error
 }
+
+// DatabaseReadWriter wraps the Get and Put methods of a backing data store.
+type DatabaseReadWriter interface {
+	DatabaseReader
+	DatabaseWriter
+}
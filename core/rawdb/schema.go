@@ -45,6 +45,12 @@ var (
 	// stateGcBodyReceiptKey tracks the number of body and receipt entries delete during state sync.
 	stateGcBodyReceiptKey = []byte("LastState")
 
+	// snapshotRootKey tracks the state root of the flat state snapshot.
+	snapshotRootKey = []byte("SnapshotRoot")
+
+	// snapshotGeneratorKey tracks the progress of the flat state snapshot generation.
+	snapshotGeneratorKey = []byte("SnapshotGenerator")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -65,6 +71,11 @@ var (
 	rewardInfoPrefix  = []byte("sri")
 	balanceInfoPrefix = []byte("srb")
 
+	rewardLedgerPrefix = []byte("srl") // rewardLedgerPrefix + address (+ seq (uint64 big endian)) -> reward ledger count (entry)
+
+	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -94,6 +105,31 @@ func balanceInfoKey(number uint64) []byte {
 	return append(balanceInfoPrefix, encodeBlockNumber(number)...)
 }
 
+// rewardLedgerCountKey = rewardLedgerPrefix + address
+func rewardLedgerCountKey(addr common.Address) []byte {
+	return append(append([]byte{}, rewardLedgerPrefix...), addr.Bytes()...)
+}
+
+// rewardLedgerKey = rewardLedgerPrefix + address + seq (uint64 big endian)
+func rewardLedgerKey(addr common.Address, seq uint64) []byte {
+	return append(rewardLedgerCountKey(addr), encodeBlockNumber(seq)...)
+}
+
+// accountSnapshotKey = SnapshotAccountPrefix + hash
+func accountSnapshotKey(hash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotAccountPrefix...), hash.Bytes()...)
+}
+
+// storageSnapshotKey = SnapshotStoragePrefix + account hash + storage hash
+func storageSnapshotKey(accountHash, storageHash common.Hash) []byte {
+	return append(storageSnapshotsKey(accountHash), storageHash.Bytes()...)
+}
+
+// storageSnapshotsKey = SnapshotStoragePrefix + account hash
+func storageSnapshotsKey(accountHash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...)
+}
+
 // headerKey = headerPrefix + num (uint64 big endian) + hash
 func headerKey(number uint64, hash common.Hash) []byte {
 	return append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
@@ -68,23 +68,51 @@ var (
 	allSendCounter      = metrics.NewRegisteredCounter("fruitpool/send/count", nil)
 	allSendTimesCounter = metrics.NewRegisteredCounter("fruitpool/send/times", nil)
 
+	// Metrics for the dropped fruits by reason
+	droppedStaleCounter     = metrics.NewRegisteredCounter("fruitpool/dropped/stale", nil)
+	droppedDuplicateCounter = metrics.NewRegisteredCounter("fruitpool/dropped/duplicate", nil)
+	droppedTooOldCounter    = metrics.NewRegisteredCounter("fruitpool/dropped/tooold", nil)
+	droppedInvalidCounter   = metrics.NewRegisteredCounter("fruitpool/dropped/invalid", nil)
+	droppedFullCounter      = metrics.NewRegisteredCounter("fruitpool/dropped/full", nil)
+
 	evictionInterval    = time.Minute     // Time interval to check for evictable fruits
 	statsReportInterval = 8 * time.Second // Time interval to report fruits pool stats
 )
 
+// Reasons a fruit is dropped from the pool for.
+const (
+	DropStale     = "stale"     // Not fresh for the next snail block any more
+	DropDuplicate = "duplicate" // Lost to another fruit of the same fast block
+	DropTooOld    = "tooold"    // Not past the fruits of the current snail block
+	DropInvalid   = "invalid"   // Failed the fruit validation
+	DropFull      = "full"      // Arrived with the pool full
+)
+
+// DroppedFruit records a fruit dropped from the pool and the reason why.
+type DroppedFruit struct {
+	Hash       common.Hash `json:"hash"`
+	FastHash   common.Hash `json:"fastHash"`
+	FastNumber *big.Int    `json:"fastNumber"`
+	Reason     string      `json:"reason"`
+	Err        string      `json:"error,omitempty"` // Error behind the drop, if any
+	Time       time.Time   `json:"time"`
+}
+
 // SnailPoolConfig are the configuration parameters of the fruit pool.
 type SnailPoolConfig struct {
-	Journal    string        // Journal of local fruits to survive node restarts
-	Rejournal  time.Duration // Time interval to regenerate the local fruit journal
-	FruitCount uint64
+	Journal      string        // Journal of local fruits to survive node restarts
+	Rejournal    time.Duration // Time interval to regenerate the local fruit journal
+	FruitCount   uint64        // Maximum number of fruits in the pool
+	DroppedCount uint64        // Number of the latest dropped fruits to keep the reasons of
 }
 
 // DefaultSnailPoolConfig contains the default configurations for the fruit
 // pool.
 var DefaultSnailPoolConfig = SnailPoolConfig{
-	Journal:    "fruits.rlp",
-	Rejournal:  time.Hour,
-	FruitCount: 8192,
+	Journal:      "fruits.rlp",
+	Rejournal:    time.Hour,
+	FruitCount:   8192,
+	DroppedCount: 1024,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -95,6 +123,14 @@ func (config *SnailPoolConfig) sanitize() SnailPoolConfig {
 		log.Warn("Sanitizing invalid snailpool journal time", "provided", conf.Rejournal, "updated", time.Second)
 		conf.Rejournal = time.Second
 	}
+	if conf.FruitCount < 1 {
+		log.Warn("Sanitizing invalid snailpool fruit count", "provided", conf.FruitCount, "updated", DefaultSnailPoolConfig.FruitCount)
+		conf.FruitCount = DefaultSnailPoolConfig.FruitCount
+	}
+	if conf.DroppedCount < 1 {
+		log.Warn("Sanitizing invalid snailpool dropped count", "provided", conf.DroppedCount, "updated", DefaultSnailPoolConfig.DroppedCount)
+		conf.DroppedCount = DefaultSnailPoolConfig.DroppedCount
+	}
 	return conf
 }
 
@@ -128,12 +164,14 @@ type SnailPool struct {
 
 	engine consensus.Engine // Consensus engine used for validating
 
-	muFruit sync.RWMutex
-	muKnown sync.RWMutex
+	muFruit   sync.RWMutex
+	muKnown   sync.RWMutex
+	muDropped sync.RWMutex
 
 	allFruits    map[common.Hash]*types.SnailBlock
 	fruitPending map[common.Hash]*types.SnailBlock
 	knownFruits  *utils.OrderedMap // map of fruits hashes knowed by pool
+	dropped      []*DroppedFruit   // Latest dropped fruits, oldest first
 
 	newFruitCh chan []*types.SnailBlock
 
@@ -187,7 +225,7 @@ func (pool *SnailPool) Start() {
 	// If journaling is enabled, load fruit from disk
 	if pool.config.Journal != "" {
 		pool.journal = newSnailJournal(pool.config.Journal)
-		if err := pool.journal.load(pool.AddLocals); err != nil {
+		if err := pool.journal.load(pool.addJournaled); err != nil {
 			log.Warn("Failed to load fruit journal", "err", err)
 		}
 		if err := pool.journal.rotate(pool.local()); err != nil {
@@ -204,6 +242,7 @@ func (pool *SnailPool) updateFruit(fruit *types.SnailBlock) bool {
 
 	if err := pool.validator.ValidateFruit(fruit, new(big.Int).Add(pool.chain.CurrentBlock().Number(), big.NewInt(1)), true); err != nil {
 		log.Info("update fruit validation error ", "fruit ", fruit.Hash(), "number", fruit.FastNumber(), " err: ", err)
+		pool.drop(fruit, DropInvalid, err)
 		allReplaceCounter.Inc(1)
 		fruitpendingReplaceCounter.Inc(1)
 		delete(pool.allFruits, fruit.FastHash())
@@ -229,6 +268,7 @@ func (pool *SnailPool) compareFruit(f1, f2 *types.SnailBlock) int {
 
 func (pool *SnailPool) appendFruit(fruit *types.SnailBlock, append bool) (error, bool) {
 	if uint64(len(pool.allFruits)) >= pool.config.FruitCount {
+		pool.drop(fruit, DropFull, core.ErrExceedNumber)
 		return core.ErrExceedNumber, false
 	}
 	pool.allFruits[fruit.FastHash()] = fruit
@@ -243,10 +283,12 @@ func (pool *SnailPool) appendFruit(fruit *types.SnailBlock, append bool) (error,
 	return nil, false
 }
 
-func (pool *SnailPool) addFruits(fruits []*types.SnailBlock) {
+func (pool *SnailPool) addFruits(fruits []*types.SnailBlock) []error {
 	var promoted []*types.SnailBlock
-	for _, fruit := range fruits {
-		_, send := pool.addFruit(fruit)
+	errs := make([]error, len(fruits))
+	for i, fruit := range fruits {
+		var send bool
+		errs[i], send = pool.addFruit(fruit)
 		if send {
 			promoted = append(promoted, fruit)
 		}
@@ -256,6 +298,7 @@ func (pool *SnailPool) addFruits(fruits []*types.SnailBlock) {
 		allSendTimesCounter.Inc(1)
 		go pool.fruitFeed.Send(types.NewFruitsEvent{Fruits: promoted})
 	}
+	return errs
 }
 
 // addFruit
@@ -266,6 +309,7 @@ func (pool *SnailPool) addFruit(fruit *types.SnailBlock) (error, bool) {
 		fruits := headSnailBlock.Fruits()
 		if fruits != nil && fruits[len(fruits)-1].FastNumber().Cmp(fruit.FastNumber()) >= 0 {
 			log.Debug("addFruit failed", "fruit's fastnumber", fruit.FastNumber(), "current snailblock's max fastnumber", fruits[len(fruits)-1].FastNumber())
+			pool.drop(fruit, DropTooOld, consensus.ErrTooOldBlock)
 			return consensus.ErrTooOldBlock, false
 		}
 	}
@@ -284,6 +328,7 @@ func (pool *SnailPool) addFruit(fruit *types.SnailBlock) (error, bool) {
 	fb := pool.fastchain.GetBlock(fruit.FastHash(), fruit.FastNumber().Uint64())
 	if fb == nil {
 		log.Debug("addFruit get block failed.", "number", fruit.FastNumber(), "hash", fruit.Hash(), "fHash", fruit.FastHash())
+		pool.drop(fruit, DropInvalid, ErrNotExist)
 		return ErrNotExist, false
 	}
 
@@ -292,11 +337,13 @@ func (pool *SnailPool) addFruit(fruit *types.SnailBlock) (error, bool) {
 	if f, ok := pool.allFruits[fruit.FastHash()]; ok {
 		if err := pool.validator.ValidateFruit(fruit, new(big.Int).Add(pool.chain.CurrentBlock().Number(), big.NewInt(1)), true); err != nil {
 			log.Trace("addFruit validation fruit error ", "fruit ", fruit.Hash(), "number", fruit.FastNumber(), " err: ", err)
+			pool.drop(fruit, DropInvalid, err)
 			return err, false
 		}
 
 		if rst := fruit.Difficulty().Cmp(f.Difficulty()); rst < 0 {
 			log.Trace("addFruit fruit failed,difficulty is lower", "give Difficulty", fruit.Difficulty(), "having Difficulty", f.Difficulty())
+			pool.drop(fruit, DropDuplicate, nil)
 			return nil, false
 		} else if rst == 0 {
 			/*if fruit.Hash().Big().Cmp(f.Hash().Big()) >= 0 {
@@ -305,18 +352,22 @@ func (pool *SnailPool) addFruit(fruit *types.SnailBlock) (error, bool) {
 			}*/
 			if mrand.Float64() < 0.5 {
 				log.Trace("addFruit fruit failed,Hash is big", "give Hash", fruit.Hash(), "having Hash", f.Hash())
+				pool.drop(fruit, DropDuplicate, nil)
 				return nil, false
 			}
-			return pool.appendFruit(fruit, true)
-		} else {
-			return pool.appendFruit(fruit, true)
 		}
+		err, send := pool.appendFruit(fruit, true)
+		if err == nil && f.Hash() != fruit.Hash() {
+			pool.drop(f, DropDuplicate, nil)
+		}
+		return err, send
 	} else {
 		if err := pool.validator.ValidateFruit(fruit, new(big.Int).Add(pool.chain.CurrentBlock().Number(), big.NewInt(1)), true); err != nil {
 			if err == types.ErrSnailHeightNotYet {
 				return pool.appendFruit(fruit, false)
 			}
 			log.Trace("addFruit validation fruit error ", "fruit ", fruit.Hash(), "number", fruit.FastNumber(), " err: ", err)
+			pool.drop(fruit, DropInvalid, err)
 			return err, false
 		}
 
@@ -325,6 +376,62 @@ func (pool *SnailPool) addFruit(fruit *types.SnailBlock) (error, bool) {
 
 }
 
+// drop records a fruit dropped from the pool for the reason, along with the error
+// behind it if any.
+func (pool *SnailPool) drop(fruit *types.SnailBlock, reason string, err error) {
+	switch reason {
+	case DropStale:
+		droppedStaleCounter.Inc(1)
+	case DropDuplicate:
+		droppedDuplicateCounter.Inc(1)
+	case DropTooOld:
+		droppedTooOldCounter.Inc(1)
+	case DropInvalid:
+		droppedInvalidCounter.Inc(1)
+	case DropFull:
+		droppedFullCounter.Inc(1)
+	}
+	dropped := &DroppedFruit{
+		Hash:       fruit.Hash(),
+		FastHash:   fruit.FastHash(),
+		FastNumber: fruit.FastNumber(),
+		Reason:     reason,
+		Time:       time.Now(),
+	}
+	if err != nil {
+		dropped.Err = err.Error()
+	}
+	pool.muDropped.Lock()
+	defer pool.muDropped.Unlock()
+
+	if uint64(len(pool.dropped)) >= pool.config.DroppedCount {
+		pool.dropped = pool.dropped[uint64(len(pool.dropped))-pool.config.DroppedCount+1:]
+	}
+	pool.dropped = append(pool.dropped, dropped)
+}
+
+// dropReason returns the reason a fruit failing the validation with the error
+// is dropped for.
+func dropReason(err error) string {
+	switch err {
+	case consensus.ErrTooOldBlock:
+		return DropTooOld
+	case core.ErrExceedNumber:
+		return DropFull
+	}
+	return DropInvalid
+}
+
+// Dropped retrieves the latest fruits dropped from the pool, oldest first.
+func (pool *SnailPool) Dropped() []*DroppedFruit {
+	pool.muDropped.RLock()
+	defer pool.muDropped.RUnlock()
+
+	dropped := make([]*DroppedFruit, len(pool.dropped))
+	copy(dropped, pool.dropped)
+	return dropped
+}
+
 // journalFruit adds the specified fruit to the local disk journal
 func (pool *SnailPool) journalFruit(fruit *types.SnailBlock) {
 	// Only journal if it's enabled
@@ -557,6 +664,7 @@ func (pool *SnailPool) removeUnfreshFruit() {
 		if err != nil {
 			if err != types.ErrSnailHeightNotYet {
 				log.Debug(" removeUnfreshFruit del fruit", "fb number", fruit.FastNumber())
+				pool.drop(fruit, DropStale, err)
 				fruitPendingDiscardCounter.Inc(1)
 				delete(pool.fruitPending, fruit.FastHash())
 				allDiscardCounter.Inc(1)
@@ -571,6 +679,9 @@ func (pool *SnailPool) RemovePendingFruitByFastHash(fasthash common.Hash) {
 	pool.muFruit.Lock()
 	defer pool.muFruit.Unlock()
 
+	if fruit := pool.allFruits[fasthash]; fruit != nil {
+		pool.drop(fruit, DropStale, nil)
+	}
 	fruitPendingDiscardCounter.Inc(1)
 	delete(pool.fruitPending, fasthash)
 	allDiscardCounter.Inc(1)
@@ -586,7 +697,11 @@ func (pool *SnailPool) Stop() {
 	pool.chainHeadSub.Unsubscribe()
 	pool.wg.Wait()
 
+	// Journal the whole pool, so that all the fruits survive the restart
 	if pool.journal != nil {
+		if err := pool.journal.rotate(pool.local()); err != nil {
+			log.Warn("Failed to rotate fruit journal", "err", err)
+		}
 		pool.journal.close()
 	}
 	log.Info("Snail pool stopped")
@@ -607,6 +722,7 @@ func (pool *SnailPool) AddRemoteFruits(fruits []*types.SnailBlock, local bool) [
 		}
 		if err := pool.validateFruit(fruit); err != nil {
 			log.Debug("AddRemoteFruits validate fruit failed", "err fruit fb num", fruit.FastNumber(), "err", err)
+			pool.drop(fruit, dropReason(err), err)
 			errs[i] = err
 			continue
 		}
@@ -638,6 +754,7 @@ func (pool *SnailPool) addLocalFruits(fruits []*types.SnailBlock) []error {
 		log.Trace("addLocalFruits", "number", fruit.FastNumber(), "diff", fruit.FruitDifficulty(), "pointer", fruit.PointNumber())
 		if err := pool.validateFruit(fruit); err != nil {
 			log.Debug("addLocalFruits validate fruit failed", "err fruit fb num", fruit.FastNumber(), "err", err)
+			pool.drop(fruit, dropReason(err), err)
 			errs[i] = err
 			continue
 		}
@@ -649,6 +766,33 @@ func (pool *SnailPool) addLocalFruits(fruits []*types.SnailBlock) []error {
 	return errs
 }
 
+// addJournaled adds a batch of fruits loaded from the journal if they are valid.
+// Unlike the other fruits they're added right away, so that they are in the pool
+// by the time the journal is rotated.
+func (pool *SnailPool) addJournaled(fruits []*types.SnailBlock) []error {
+	errs := make([]error, len(fruits))
+	addFruits := make([]*types.SnailBlock, 0, len(fruits))
+	index := make([]int, 0, len(fruits))
+	for i, fruit := range fruits {
+		if err := pool.validateFruit(fruit); err != nil {
+			log.Debug("addJournaled validate fruit failed", "err fruit fb num", fruit.FastNumber(), "err", err)
+			pool.drop(fruit, dropReason(err), err)
+			errs[i] = err
+			continue
+		}
+		pool.muKnown.Lock()
+		pool.knownFruits.Set(fruit.Hash(), nil)
+		pool.muKnown.Unlock()
+
+		addFruits = append(addFruits, types.CopyFruit(fruit))
+		index = append(index, i)
+	}
+	for i, err := range pool.addFruits(addFruits) {
+		errs[index[i]] = err
+	}
+	return errs
+}
+
 // AddLocals enqueues a batch of fruits into the pool if they are valid,
 // marking the senders as a local ones in the mean time, ensuring they go around
 // the local pricing constraints.
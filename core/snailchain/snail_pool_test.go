@@ -398,6 +398,122 @@ func TestFruitReplacement(t *testing.T) {
 
 }
 
+// Tests that the fruits losing to a better fruit of the same fast block are
+// recorded as dropped, and that only the latest drops are kept.
+func TestFruitDroppedReasons(t *testing.T) {
+	poolinit()
+	t.Parallel()
+
+	config := testSnailPoolConfig
+	config.DroppedCount = 2
+
+	pool := NewSnailPool(config, fastchainpool, snailblockchain, enginepool)
+	defer pool.Stop()
+
+	var (
+		ft0 = fruit(181, big.NewInt(1000))
+		ft2 = fruit(181, big.NewInt(1789570))
+	)
+	pool.addFruits([]*types.SnailBlock{ft0})
+	pool.addFruits([]*types.SnailBlock{ft2})
+
+	dropped := pool.Dropped()
+	if len(dropped) != 1 {
+		t.Fatalf("dropped fruit count mismatch: have %d, want %d", len(dropped), 1)
+	}
+	if dropped[0].Hash != ft0.Hash() || dropped[0].Reason != DropDuplicate {
+		t.Errorf("dropped fruit mismatch: have %x/%s, want %x/%s", dropped[0].Hash, dropped[0].Reason, ft0.Hash(), DropDuplicate)
+	}
+	// Drop a few more fruits and ensure the oldest records go first
+	pool.drop(ft2, DropStale, nil)
+	pool.drop(ft0, DropInvalid, ErrNotExist)
+
+	dropped = pool.Dropped()
+	if len(dropped) != 2 {
+		t.Fatalf("dropped fruit count mismatch: have %d, want %d", len(dropped), 2)
+	}
+	if dropped[0].Reason != DropStale || dropped[1].Reason != DropInvalid {
+		t.Errorf("dropped fruit reasons mismatch: have %s/%s, want %s/%s", dropped[0].Reason, dropped[1].Reason, DropStale, DropInvalid)
+	}
+	if dropped[1].Err != ErrNotExist.Error() {
+		t.Errorf("dropped fruit error mismatch: have %q, want %q", dropped[1].Err, ErrNotExist.Error())
+	}
+}
+
+// Tests that the fruits failing the validation are dropped for the reason of
+// their error.
+func TestFruitDropReason(t *testing.T) {
+	tests := []struct {
+		err    error
+		reason string
+	}{
+		{consensus.ErrTooOldBlock, DropTooOld},
+		{core.ErrExceedNumber, DropFull},
+		{ErrNotExist, DropInvalid},
+	}
+	for i, tt := range tests {
+		if reason := dropReason(tt.err); reason != tt.reason {
+			t.Errorf("test %d: drop reason mismatch: have %s, want %s", i, reason, tt.reason)
+		}
+	}
+}
+
+// Tests that all the fruits in the pool are journaled when it stops, and are
+// restored into the pool when it starts again.
+func TestFruitJournalRestore(t *testing.T) {
+	poolinit()
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	file.Close()
+	os.Remove(journal)
+
+	config := testSnailPoolConfig
+	config.Journal = journal
+
+	pool := NewSnailPool(config, fastchainpool, snailblockchain, enginepool)
+	pool.Start()
+
+	var (
+		ft1 = fruit(181, big.NewInt(1789570))
+		ft2 = fruit(182, big.NewInt(1789570))
+	)
+	for _, err := range pool.addFruits([]*types.SnailBlock{ft1, ft2}) {
+		if err != nil {
+			t.Fatalf("failed to add fruit: %v", err)
+		}
+	}
+	pool.Stop()
+
+	// Restart the pool and ensure the fruits are back in right away
+	pool = NewSnailPool(config, fastchainpool, snailblockchain, enginepool)
+	pool.Start()
+	defer pool.Stop()
+
+	pending, unverified := pool.Stats()
+	if pending != 2 {
+		t.Fatalf("pending fruits mismatched: have %d, want %d", pending, 2)
+	}
+	if unverified != 0 {
+		t.Fatalf("unverified fruits mismatched: have %d, want %d", unverified, 0)
+	}
+	for _, ft := range []*types.SnailBlock{ft1, ft2} {
+		if pool.fruitPending[ft.FastHash()] == nil {
+			t.Errorf("fruit of fast block %d not restored", ft.FastNumber())
+		}
+	}
+	if err := validateSnailPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that local fruits are journaled to disk, but remote fruits
 // get discarded between restarts.
 func TestFruitJournaling(t *testing.T) { poolinit(); testFruitJournaling(t) }
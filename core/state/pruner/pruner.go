@@ -0,0 +1,360 @@
+// Package pruner implements the offline pruning of the state tries, deleting
+// the trie nodes and contract codes not reachable from the states kept.
+package pruner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/state/snapshot"
+	"truechain/discovery/core/types"
+	"truechain/discovery/crypto"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/log"
+	"truechain/discovery/rlp"
+	"truechain/discovery/trie"
+)
+
+var (
+	// Keys of the pruning progress in the mark database, the marks themselves
+	// are the bare hashes of the nodes.
+	rootsKey  = []byte("PruneRoots")
+	markedKey = []byte("PruneMarked")
+	sweptKey  = []byte("PruneSwept")
+
+	sweepDone = []byte("done")
+
+	// ErrNoState is returned if none of the states to keep is on disk.
+	ErrNoState = errors.New("no state to keep found")
+
+	// logInterval is the interval of the progress reports.
+	logInterval = 8 * time.Second
+)
+
+// Pruner deletes the state trie nodes and codes not reachable from the states
+// of a set of roots. The reachable ones are marked in a separate database,
+// which also records the progress to resume an interrupted run.
+type Pruner struct {
+	db     etruedb.Database // Chain database to prune
+	markdb etruedb.Database // Marks of the reachable nodes and the progress
+}
+
+// New creates a pruner of the chain database, marking in the mark database.
+func New(db, markdb etruedb.Database) (*Pruner, error) {
+	for _, d := range []etruedb.Database{db, markdb} {
+		if _, ok := d.(etruedb.Iteratee); !ok {
+			return nil, snapshot.ErrNotIteratee
+		}
+	}
+	return &Pruner{db: db, markdb: markdb}, nil
+}
+
+// Resumed returns the roots of an interrupted run, nil if none.
+func (p *Pruner) Resumed() []common.Hash {
+	blob, _ := p.markdb.Get(rootsKey)
+	if len(blob) == 0 {
+		return nil
+	}
+	var roots []common.Hash
+	if err := rlp.DecodeBytes(blob, &roots); err != nil {
+		return nil
+	}
+	return roots
+}
+
+// Prune keeps the states of the roots and the genesis state, and deletes the
+// rest, marking, sweeping and then verifying that the kept states are whole.
+// The roots of an interrupted run are pruned instead, if any.
+func (p *Pruner) Prune(roots []common.Hash) error {
+	if resumed := p.Resumed(); resumed != nil {
+		log.Info("Resuming state pruning", "roots", len(resumed))
+		roots = resumed
+	} else {
+		if root, ok := genesisRoot(p.db); ok {
+			roots = append(roots[:len(roots):len(roots)], root)
+		}
+		var (
+			kept []common.Hash
+			seen = make(map[common.Hash]struct{})
+		)
+		for _, root := range roots {
+			if _, ok := seen[root]; ok {
+				continue
+			}
+			seen[root] = struct{}{}
+			if ok, _ := p.db.Has(root[:]); ok {
+				kept = append(kept, root)
+			} else {
+				log.Warn("State to keep missing, skipping", "root", root)
+			}
+		}
+		if len(kept) == 0 {
+			return ErrNoState
+		}
+		blob, _ := rlp.EncodeToBytes(kept)
+		if err := p.markdb.Put(rootsKey, blob); err != nil {
+			return err
+		}
+		roots = kept
+	}
+	if err := p.mark(roots); err != nil {
+		return err
+	}
+	if err := p.sweep(); err != nil {
+		return err
+	}
+	return p.verify(roots)
+}
+
+// genesisRoot returns the state root of the genesis block, which is kept by the
+// pruning as the chain is reset to it.
+func genesisRoot(db etruedb.Database) (common.Hash, bool) {
+	hash := rawdb.ReadCanonicalHash(db, 0)
+	if hash == (common.Hash{}) {
+		return common.Hash{}, false
+	}
+	header := rawdb.ReadHeader(db, hash, 0)
+	if header == nil {
+		return common.Hash{}, false
+	}
+	return header.Root, true
+}
+
+// mark marks the nodes reachable from the roots, a root at a time. The marks
+// hold the index of the root they were made for: the nodes are marked before
+// the ones below them, so only the marks of the roots done are trusted to skip
+// the nodes below when resuming.
+func (p *Pruner) mark(roots []common.Hash) error {
+	var (
+		done    uint64
+		start   = time.Now()
+		logged  = time.Now()
+		batch   = p.markdb.NewBatch()
+		nodes   int
+		skipped int
+	)
+	if blob, _ := p.markdb.Get(markedKey); len(blob) == 8 {
+		done = binary.BigEndian.Uint64(blob)
+	}
+	for ; done < uint64(len(roots)); done++ {
+		var index [8]byte
+		binary.BigEndian.PutUint64(index[:], done)
+
+		visit := func(hash common.Hash) (bool, error) {
+			if blob, _ := p.markdb.Get(hash[:]); len(blob) == 8 {
+				if bytes.Compare(blob, index[:]) < 0 {
+					skipped++
+					return false, nil
+				}
+			} else {
+				batch.Put(hash[:], index[:])
+				nodes++
+			}
+			if batch.ValueSize() > etruedb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					return false, err
+				}
+				batch.Reset()
+			}
+			if time.Since(logged) > logInterval {
+				log.Info("Marking state trie nodes", "roots", fmt.Sprintf("%d/%d", done, len(roots)), "nodes", nodes, "skipped", skipped, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+			return true, nil
+		}
+		if err := walkState(p.db, roots[done], visit); err != nil {
+			return err
+		}
+		var blob [8]byte
+		binary.BigEndian.PutUint64(blob[:], done+1)
+		batch.Put(markedKey, blob[:])
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+	}
+	log.Info("Marked state trie nodes", "roots", len(roots), "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// sweep deletes the unmarked trie nodes and codes, told apart from the other
+// entries of the database by being keyed by the hash of their value.
+func (p *Pruner) sweep() error {
+	swept, _ := p.markdb.Get(sweptKey)
+	if bytes.Equal(swept, sweepDone) {
+		return nil
+	}
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		batch   = p.db.NewBatch()
+		scanned int
+		deleted int
+		size    common.StorageSize
+	)
+	if len(swept) > 0 {
+		log.Info("Resuming state sweeping", "at", common.BytesToHash(swept))
+	}
+	it := p.db.(etruedb.Iteratee).NewIteratorWithStart(nil, swept)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		scanned++
+		if len(key) != common.HashLength || bytes.Equal(key, swept) {
+			continue
+		}
+		if ok, _ := p.markdb.Has(key); ok {
+			continue
+		}
+		if !bytes.Equal(crypto.Keccak256(it.Value()), key) {
+			continue
+		}
+		batch.Delete(common.CopyBytes(key))
+		deleted++
+		size += common.StorageSize(len(key) + len(it.Value()))
+
+		if batch.ValueSize() > etruedb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+			// Record the progress only once the deletions up to it are written
+			if err := p.markdb.Put(sweptKey, common.CopyBytes(key)); err != nil {
+				return err
+			}
+		}
+		if time.Since(logged) > logInterval {
+			log.Info("Sweeping stale trie nodes", "at", common.BytesToHash(key), "scanned", scanned, "deleted", deleted, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if err := p.markdb.Put(sweptKey, sweepDone); err != nil {
+		return err
+	}
+	log.Info("Swept stale trie nodes", "scanned", scanned, "deleted", deleted, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	if ldb, ok := p.db.(*etruedb.LDBDatabase); ok {
+		log.Info("Compacting database")
+		if err := ldb.LDB().CompactRange(util.Range{}); err != nil {
+			return err
+		}
+		log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+// verify checks that the states of the roots are whole after the sweep, all
+// the marked nodes must be left.
+func (p *Pruner) verify(roots []common.Hash) error {
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		verified int
+	)
+	for _, root := range roots {
+		if _, err := trie.New(root, trie.NewDatabase(p.db)); err != nil {
+			return err
+		}
+	}
+	it := p.markdb.(etruedb.Iteratee).NewIteratorWithStart(nil, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != common.HashLength {
+			continue
+		}
+		if ok, _ := p.db.Has(key); !ok {
+			return fmt.Errorf("missing trie node %x", key)
+		}
+		verified++
+		if time.Since(logged) > logInterval {
+			log.Info("Verifying pruned state", "nodes", verified, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	log.Info("Verified pruned state", "roots", len(roots), "nodes", verified, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// walkState calls visit with the hashes of the nodes of the account trie of the
+// root, of the storage tries and of the contract codes, the impawn state kept in
+// the storage of the staking address included. The nodes below a node aren't
+// walked if visit returns false for it.
+func walkState(db etruedb.Database, root common.Hash, visit func(common.Hash) (bool, error)) error {
+	triedb := trie.NewDatabase(db)
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		stakingHash = crypto.Keccak256Hash(types.StakingAddress.Bytes())
+		storages    = make(map[common.Hash]struct{}) // Storage tries walked, shared by accounts
+	)
+	it := accTrie.NodeIterator(nil)
+	for descend := true; it.Next(descend); {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if descend, err = visit(hash); err != nil {
+				return err
+			}
+		}
+		if !it.Leaf() {
+			continue
+		}
+		account, err := snapshot.DecodeAccount(it.LeafBlob())
+		if err != nil {
+			return err
+		}
+		if _, ok := storages[account.Root]; !ok && account.HasStorage() {
+			if common.BytesToHash(it.LeafKey()) == stakingHash {
+				log.Debug("Walking impawn state", "root", root, "storage", account.Root)
+			}
+			if err := walkTrie(triedb, account.Root, visit); err != nil {
+				return err
+			}
+			storages[account.Root] = struct{}{}
+		}
+		if account.HasCode() {
+			hash := common.BytesToHash(account.CodeHash)
+			if _, err := visit(hash); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
+
+// walkTrie calls visit with the hashes of the nodes of the trie of the root.
+func walkTrie(triedb *trie.Database, root common.Hash, visit func(common.Hash) (bool, error)) error {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	it := tr.NodeIterator(nil)
+	for descend := true; it.Next(descend); {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			if descend, err = visit(hash); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
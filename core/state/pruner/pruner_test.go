@@ -0,0 +1,158 @@
+package pruner
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/state"
+	"truechain/discovery/core/types"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/rlp"
+)
+
+// makeStates commits a chain of states, each changing balances, storage and
+// the impawn state of the previous one, and returns their roots.
+func makeStates(t *testing.T, db etruedb.Database, count int) []common.Hash {
+	var (
+		sdb   = state.NewDatabase(db)
+		root  common.Hash
+		roots []common.Hash
+	)
+	for n := 0; n < count; n++ {
+		statedb, err := state.New(root, sdb)
+		if err != nil {
+			t.Fatalf("failed to open state: %v", err)
+		}
+		for i := 0; i < 50; i++ {
+			addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+			statedb.AddBalance(addr, big.NewInt(int64(n+1)))
+			if i%5 == 0 {
+				statedb.SetCode(addr, []byte{byte(n), byte(i), 0x00})
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(n))), common.BigToHash(big.NewInt(int64(i+1))))
+			}
+		}
+		statedb.SetPOSState(types.StakingAddress, common.BigToHash(big.NewInt(int64(n))), []byte{0xc0, byte(n)})
+		if root, err = statedb.Commit(true); err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to write state: %v", err)
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// checkState checks that the state of the root is whole.
+func checkState(t *testing.T, db etruedb.Database, root common.Hash, n int) {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open state %x: %v", root, err)
+	}
+	for i := 0; i < 50; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		if i%5 == 0 && len(statedb.GetCode(addr)) == 0 {
+			t.Fatalf("code of %x missing", addr)
+		}
+	}
+	if have := statedb.GetPOSState(types.StakingAddress, common.BigToHash(big.NewInt(int64(n)))); len(have) != 2 || have[1] != byte(n) {
+		t.Fatalf("impawn state mismatch: have %x", have)
+	}
+	if err := walkState(db, root, func(common.Hash) (bool, error) { return true, nil }); err != nil {
+		t.Fatalf("state %x not whole: %v", root, err)
+	}
+}
+
+// Tests that pruning keeps the states of the roots, the other entries of the
+// database, and deletes the stale trie nodes.
+func TestPrune(t *testing.T) {
+	var (
+		db     = etruedb.NewMemDatabase()
+		markdb = etruedb.NewMemDatabase()
+		roots  = makeStates(t, db, 10)
+	)
+	db.Put([]byte("LastBlock"), common.Hash{1}.Bytes())
+	db.Put(common.Hash{2}.Bytes(), []byte("not a trie node"))
+	before := db.Len()
+
+	pruner, err := New(db, markdb)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	if err := pruner.Prune(roots[8:]); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if db.Len() >= before {
+		t.Fatalf("nothing pruned: %d entries left of %d", db.Len(), before)
+	}
+	checkState(t, db, roots[8], 8)
+	checkState(t, db, roots[9], 9)
+	if _, err := state.New(roots[0], state.NewDatabase(db)); err == nil {
+		t.Errorf("stale state left")
+	}
+	for _, key := range [][]byte{[]byte("LastBlock"), common.Hash{2}.Bytes()} {
+		if ok, _ := db.Has(key); !ok {
+			t.Errorf("entry %q deleted", key)
+		}
+	}
+}
+
+// Tests that the genesis state is kept along with the states of the roots.
+func TestPruneKeepsGenesis(t *testing.T) {
+	var (
+		db     = etruedb.NewMemDatabase()
+		markdb = etruedb.NewMemDatabase()
+		roots  = makeStates(t, db, 6)
+	)
+	genesis := &types.Header{Number: big.NewInt(0), Root: roots[0]}
+	rawdb.WriteHeader(db, genesis)
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+
+	pruner, _ := New(db, markdb)
+	if err := pruner.Prune(roots[5:]); err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	checkState(t, db, roots[0], 0)
+	checkState(t, db, roots[5], 5)
+	if _, err := state.New(roots[2], state.NewDatabase(db)); err == nil {
+		t.Errorf("stale state left")
+	}
+	if kept := pruner.Resumed(); len(kept) != 2 || kept[1] != roots[0] {
+		t.Errorf("kept roots mismatch: have %x", kept)
+	}
+}
+
+// Tests that an interrupted pruning is resumed, the nodes marked by the walk
+// interrupted not skipping the ones below them.
+func TestPruneResume(t *testing.T) {
+	var (
+		db     = etruedb.NewMemDatabase()
+		markdb = etruedb.NewMemDatabase()
+		roots  = makeStates(t, db, 4)
+	)
+	pruner, _ := New(db, markdb)
+
+	// Mark the first root, and the root node only of the second one
+	blob, _ := rlp.EncodeToBytes(roots[2:])
+	markdb.Put(rootsKey, blob)
+	if err := pruner.mark(roots[2:3]); err != nil {
+		t.Fatalf("failed to mark: %v", err)
+	}
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], 1)
+	markdb.Put(roots[3][:], index[:])
+
+	if err := pruner.Prune(nil); err != nil {
+		t.Fatalf("failed to resume pruning: %v", err)
+	}
+	checkState(t, db, roots[2], 2)
+	checkState(t, db, roots[3], 3)
+
+	// Pruning again once swept only verifies
+	if err := pruner.Prune(nil); err != nil {
+		t.Fatalf("failed to verify pruned state: %v", err)
+	}
+}
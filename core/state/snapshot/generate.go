@@ -0,0 +1,192 @@
+package snapshot
+
+import (
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/log"
+	"truechain/discovery/trie"
+)
+
+// generateAccounts is the number of accounts generated at once, the snapshot
+// is locked meanwhile.
+const generateAccounts = 1024
+
+// regenerate drops the snapshot and starts generating the one of the root from
+// its tries. The caller must hold the lock.
+func (t *Tree) regenerate(root common.Hash) {
+	t.gen++
+	t.root, t.marker = root, []byte{}
+
+	batch := t.diskdb.NewBatch()
+	rawdb.WriteSnapshotRoot(batch, root)
+	rawdb.WriteSnapshotGenerator(batch, t.marker)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write snapshot generator", "err", err)
+	}
+	go t.generate(t.gen, true)
+}
+
+// generate fills the snapshot in from the tries of its root, wiping the stale
+// entries first if requested. It returns once done or aborted by a newer run.
+func (t *Tree) generate(gen uint64, wipe bool) {
+	var (
+		start    = time.Now()
+		accounts int
+		slots    int
+	)
+	if wipe {
+		if err := wipeSnapshot(t.diskdb, func() bool { return t.aborted(gen) }); err != nil {
+			log.Error("Failed to wipe state snapshot", "err", err)
+			return
+		}
+	}
+	for {
+		done, err := t.generateRange(gen, &accounts, &slots)
+		if err != nil {
+			log.Error("Failed to generate state snapshot", "err", err)
+			return
+		}
+		if done {
+			break
+		}
+	}
+	if !t.aborted(gen) {
+		log.Info("Generated state snapshot", "root", t.Root(), "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+}
+
+// aborted reports whether the generation run was superseded.
+func (t *Tree) aborted(gen uint64) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.gen != gen
+}
+
+// generateRange generates the snapshot entries of the next accounts from the
+// tries of the current root, it returns true once the snapshot is complete.
+func (t *Tree) generateRange(gen uint64, accounts, slots *int) (bool, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.gen != gen {
+		return true, nil
+	}
+	accTrie, err := trie.New(t.root, t.triedb)
+	if err != nil {
+		return false, err
+	}
+	start, ok := nextKey(t.marker)
+	var (
+		batch = t.diskdb.NewBatch()
+		it    = trie.NewIterator(accTrie.NodeIterator(start))
+		more  bool
+	)
+	for count := 0; ok; count++ {
+		if count == generateAccounts {
+			more = true
+			break
+		}
+		if !it.Next() {
+			break
+		}
+		hash := common.BytesToHash(it.Key)
+		rawdb.WriteAccountSnapshot(batch, hash, it.Value)
+		*accounts++
+
+		account, err := DecodeAccount(it.Value)
+		if err != nil {
+			return false, err
+		}
+		if account.HasStorage() {
+			storageTrie, err := trie.New(account.Root, t.triedb)
+			if err != nil {
+				return false, err
+			}
+			sit := trie.NewIterator(storageTrie.NodeIterator(nil))
+			for sit.Next() {
+				rawdb.WriteStorageSnapshot(batch, hash, common.BytesToHash(sit.Key), sit.Value)
+				*slots++
+				if batch.ValueSize() > etruedb.IdealBatchSize {
+					if err := batch.Write(); err != nil {
+						return false, err
+					}
+					batch.Reset()
+				}
+			}
+			if sit.Err != nil {
+				return false, sit.Err
+			}
+		}
+		t.marker = hash.Bytes()
+	}
+	if it.Err != nil {
+		return false, it.Err
+	}
+	if more {
+		rawdb.WriteSnapshotGenerator(batch, t.marker)
+	} else {
+		t.marker = nil
+		rawdb.DeleteSnapshotGenerator(batch)
+	}
+	return !more, batch.Write()
+}
+
+// wipeSnapshot deletes all the snapshot entries, the trie nodes sharing the key
+// space are told apart by their length.
+func wipeSnapshot(db etruedb.Database, abort func() bool) error {
+	spaces := []struct {
+		prefix []byte
+		keylen int
+	}{
+		{rawdb.SnapshotAccountPrefix, len(rawdb.SnapshotAccountPrefix) + common.HashLength},
+		{rawdb.SnapshotStoragePrefix, len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength},
+	}
+	for _, space := range spaces {
+		it := db.(etruedb.Iteratee).NewIteratorWithStart(space.prefix, nil)
+		batch := db.NewBatch()
+		for it.Next() {
+			if key := it.Key(); len(key) == space.keylen {
+				batch.Delete(common.CopyBytes(key))
+			}
+			if batch.ValueSize() > etruedb.IdealBatchSize {
+				if abort() {
+					it.Release()
+					return nil
+				}
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextKey returns the key following the hash in the trie order, nil to start
+// at the beginning if the hash is empty. It returns false if nothing follows.
+func nextKey(hash []byte) ([]byte, bool) {
+	if len(hash) == 0 {
+		return nil, true
+	}
+	next := common.CopyBytes(hash)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,365 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/crypto"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/log"
+	"truechain/discovery/trie"
+)
+
+// commitLeaves is the number of leaves inserted into a rebuilt trie before it's
+// committed to disk, to bound the memory used.
+const commitLeaves = 16384
+
+var (
+	errRangeLength = errors.New("range keys and values mismatch")
+	errRangeProof  = errors.New("range proof missing")
+)
+
+// AccountRange returns the account trie leaves of the state of the root from
+// the origin to the limit, as many as fit the bytes, with the proofs of the
+// origin and the last leaf. The first leaf past the limit is included, so the
+// range proves there's none left up to the limit. The leaves are read from
+// the snapshot if it covers the state, from the trie otherwise.
+func AccountRange(snap *Tree, triedb *trie.Database, root, origin, limit common.Hash, bytes uint64) ([]common.Hash, [][]byte, [][]byte, error) {
+	tr, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keys, values, ok := snap.flatRange(root, rawdb.SnapshotAccountPrefix, origin, limit, bytes)
+	if !ok {
+		if keys, values, err = trieRange(tr, origin, limit, bytes); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	proof, err := proveRange(tr, origin, keys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return keys, values, proof, nil
+}
+
+// StorageRange returns the storage trie leaves of the account in the state of
+// the root from the origin, as many as fit the bytes. The proofs of the origin
+// and the last leaf are returned only if the range doesn't cover the whole trie.
+func StorageRange(snap *Tree, triedb *trie.Database, root, account, origin common.Hash, bytes uint64) ([]common.Hash, [][]byte, [][]byte, error) {
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	blob, err := accTrie.TryGet(account[:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if blob == nil {
+		return nil, nil, nil, nil
+	}
+	data, err := DecodeAccount(blob)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tr, err := trie.New(data.Root, triedb)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var (
+		last    = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		prefix  = rawdb.StorageSnapshotsPrefix(account)
+		partial bool
+	)
+	keys, values, ok := snap.flatRange(root, prefix, origin, last, bytes)
+	if !ok {
+		if keys, values, err = trieRange(tr, origin, last, bytes); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if origin != (common.Hash{}) {
+		partial = true
+	} else if len(keys) > 0 {
+		// The range is complete if no leaf follows the last one
+		next, more := nextKey(keys[len(keys)-1][:])
+		if more {
+			it := trie.NewIterator(tr.NodeIterator(next))
+			partial = it.Next()
+		}
+	}
+	if !partial {
+		return keys, values, nil, nil
+	}
+	proof, err := proveRange(tr, origin, keys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return keys, values, proof, nil
+}
+
+// flatRange reads a range of leaves from the snapshot up to the first one at or
+// past the limit, it returns false if the snapshot doesn't cover the state of
+// the root.
+func (t *Tree) flatRange(root common.Hash, prefix []byte, origin, limit common.Hash, size uint64) ([]common.Hash, [][]byte, bool) {
+	if t == nil {
+		return nil, nil, false
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.root != root || t.marker != nil {
+		return nil, nil, false
+	}
+	var (
+		keys   []common.Hash
+		values [][]byte
+		total  uint64
+		keylen = len(prefix) + common.HashLength
+	)
+	it := t.diskdb.(etruedb.Iteratee).NewIteratorWithStart(prefix, origin[:])
+	defer it.Release()
+
+	for total < size && it.Next() {
+		key := it.Key()
+		if len(key) != keylen {
+			continue
+		}
+		hash := common.BytesToHash(key[len(prefix):])
+		keys = append(keys, hash)
+		values = append(values, common.CopyBytes(it.Value()))
+		total += uint64(common.HashLength + len(it.Value()))
+
+		if bytes.Compare(hash[:], limit[:]) >= 0 {
+			break
+		}
+	}
+	if err := it.Error(); err != nil {
+		log.Warn("Failed to read snapshot range", "err", err)
+		return nil, nil, false
+	}
+	return keys, values, true
+}
+
+// trieRange reads a range of leaves from the trie up to the first one at or
+// past the limit.
+func trieRange(tr *trie.Trie, origin, limit common.Hash, size uint64) ([]common.Hash, [][]byte, error) {
+	var (
+		keys   []common.Hash
+		values [][]byte
+		total  uint64
+	)
+	it := trie.NewIterator(tr.NodeIterator(origin[:]))
+	for total < size && it.Next() {
+		hash := common.BytesToHash(it.Key)
+		if bytes.Compare(hash[:], origin[:]) < 0 {
+			continue
+		}
+		keys = append(keys, hash)
+		values = append(values, common.CopyBytes(it.Value))
+		total += uint64(common.HashLength + len(it.Value))
+
+		if bytes.Compare(hash[:], limit[:]) >= 0 {
+			break
+		}
+	}
+	return keys, values, it.Err
+}
+
+// proveRange returns the nodes proving the origin and the last key in the
+// trie, or the origin alone if there're no keys.
+func proveRange(tr *trie.Trie, origin common.Hash, keys []common.Hash) ([][]byte, error) {
+	proofDb := etruedb.NewMemDatabase()
+	edges := []common.Hash{origin}
+	if len(keys) > 0 {
+		edges = append(edges, keys[len(keys)-1])
+	}
+	for _, key := range edges {
+		if err := tr.Prove(key[:], 0, proofDb); err != nil {
+			return nil, err
+		}
+	}
+	proof := make([][]byte, 0, proofDb.Len())
+	for _, key := range proofDb.Keys() {
+		node, _ := proofDb.Get(key)
+		proof = append(proof, node)
+	}
+	return proof, nil
+}
+
+// VerifyRange checks a range of leaves served from the trie of the root: the
+// leaves are all the ones of the trie from the origin to the last key, proven
+// by the proofs of the origin and the last key. A range missing a leaf in the
+// middle or an empty range with leaves following the origin is rejected. It
+// returns whether the trie has more leaves right of the range.
+func VerifyRange(root, origin common.Hash, keys []common.Hash, values [][]byte, proof [][]byte) (bool, error) {
+	if len(keys) != len(values) {
+		return false, errRangeLength
+	}
+	if len(proof) == 0 {
+		return false, errRangeProof
+	}
+	proofDb := etruedb.NewMemDatabase()
+	for _, node := range proof {
+		proofDb.Put(crypto.Keccak256(node), node)
+	}
+	last := origin
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+	hashes := make([][]byte, len(keys))
+	for i := range keys {
+		hashes[i] = keys[i][:]
+	}
+	return trie.VerifyRangeProof(root, origin[:], last[:], hashes, values, proofDb)
+}
+
+// VerifyTrie checks that the leaves make up the whole trie of the root.
+func VerifyTrie(root common.Hash, keys []common.Hash, values [][]byte) error {
+	if len(keys) != len(values) {
+		return errRangeLength
+	}
+	tr, _ := trie.New(common.Hash{}, trie.NewDatabase(etruedb.NewMemDatabase()))
+	for i, key := range keys {
+		if err := tr.TryUpdate(key[:], values[i]); err != nil {
+			return err
+		}
+	}
+	if hash := tr.Hash(); hash != root {
+		return fmt.Errorf("trie root mismatch: have %x, want %x", hash, root)
+	}
+	return nil
+}
+
+// Wipe deletes the snapshot, for its entries to be filled in by a state sync.
+func Wipe(db etruedb.Database) error {
+	if _, ok := db.(etruedb.Iteratee); !ok {
+		return ErrNotIteratee
+	}
+	rawdb.DeleteSnapshotRoot(db)
+	rawdb.DeleteSnapshotGenerator(db)
+	return wipeSnapshot(db, func() bool { return false })
+}
+
+// CommitTrie rebuilds the account and storage tries of the state from the
+// snapshot entries filled in by a state sync, and writes them to disk. The
+// rebuilt roots must match the root and the storage roots of the accounts, the
+// snapshot is then marked as of the state.
+func CommitTrie(db etruedb.Database, root common.Hash) error {
+	iteratee, ok := db.(etruedb.Iteratee)
+	if !ok {
+		return ErrNotIteratee
+	}
+	var (
+		triedb   = trie.NewDatabase(db)
+		accounts = newTrieBuilder(triedb)
+		keylen   = len(rawdb.SnapshotAccountPrefix) + common.HashLength
+	)
+	it := iteratee.NewIteratorWithStart(rawdb.SnapshotAccountPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != keylen {
+			continue
+		}
+		hash := common.BytesToHash(key[len(rawdb.SnapshotAccountPrefix):])
+		account, err := DecodeAccount(it.Value())
+		if err != nil {
+			return err
+		}
+		if account.HasStorage() {
+			if err := commitStorageTrie(iteratee, triedb, hash, account.Root); err != nil {
+				return err
+			}
+		}
+		if err := accounts.add(hash, it.Value()); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	hash, err := accounts.commit()
+	if err != nil {
+		return err
+	}
+	if hash != root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", hash, root)
+	}
+	rawdb.WriteSnapshotRoot(db, root)
+	return nil
+}
+
+// commitStorageTrie rebuilds the storage trie of the account from the snapshot
+// entries and writes it to disk.
+func commitStorageTrie(db etruedb.Iteratee, triedb *trie.Database, account, root common.Hash) error {
+	var (
+		storage = newTrieBuilder(triedb)
+		prefix  = rawdb.StorageSnapshotsPrefix(account)
+	)
+	it := db.NewIteratorWithStart(prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+common.HashLength {
+			continue
+		}
+		if err := storage.add(common.BytesToHash(key[len(prefix):]), it.Value()); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	hash, err := storage.commit()
+	if err != nil {
+		return err
+	}
+	if hash != root {
+		return fmt.Errorf("storage root mismatch of account %x: have %x, want %x", account, hash, root)
+	}
+	return nil
+}
+
+// trieBuilder rebuilds a trie from its leaves, committing it to disk every so
+// often to bound the memory used.
+type trieBuilder struct {
+	triedb *trie.Database
+	tr     *trie.Trie
+	count  int
+}
+
+func newTrieBuilder(triedb *trie.Database) *trieBuilder {
+	tr, _ := trie.New(common.Hash{}, triedb)
+	return &trieBuilder{triedb: triedb, tr: tr}
+}
+
+func (b *trieBuilder) add(key common.Hash, value []byte) error {
+	if err := b.tr.TryUpdate(key[:], common.CopyBytes(value)); err != nil {
+		return err
+	}
+	if b.count++; b.count%commitLeaves == 0 {
+		root, err := b.commit()
+		if err != nil {
+			return err
+		}
+		if b.tr, err = trie.New(root, b.triedb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *trieBuilder) commit() (common.Hash, error) {
+	root, err := b.tr.Commit(nil)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.triedb.Commit(root, false); err != nil {
+		return common.Hash{}, err
+	}
+	return root, nil
+}
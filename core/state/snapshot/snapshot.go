@@ -0,0 +1,297 @@
+// Package snapshot implements a flat snapshot of the fast chain state, the
+// account and storage trie leaves keyed by their hashes, to read the state and
+// serve contiguous ranges of it without walking the tries.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/crypto"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/log"
+	"truechain/discovery/rlp"
+	"truechain/discovery/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	// ErrNotIteratee is returned if the database can't iterate over its content.
+	ErrNotIteratee = errors.New("database not iterable")
+
+	// ErrNotCovered is returned when reading an entry the snapshot doesn't cover
+	// yet, or a state other than the one the snapshot is of.
+	ErrNotCovered = errors.New("snapshot not covering entry")
+)
+
+// Account is the consensus representation of an account in the state trie, as
+// decoded from the snapshot entries.
+type Account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// DecodeAccount decodes an account trie leaf.
+func DecodeAccount(blob []byte) (*Account, error) {
+	account := new(Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// HasStorage reports whether the account has a storage trie.
+func (a *Account) HasStorage() bool {
+	return a.Root != emptyRoot && a.Root != (common.Hash{})
+}
+
+// HasCode reports whether the account has a contract code.
+func (a *Account) HasCode() bool {
+	return len(a.CodeHash) > 0 && common.BytesToHash(a.CodeHash) != emptyCode
+}
+
+// Tree is the flat snapshot of a state on disk. It follows the state of the
+// fast chain head through Update, and is generated in the background from the
+// tries if it's missing or of another state.
+type Tree struct {
+	diskdb etruedb.Database
+	triedb *trie.Database
+
+	lock   sync.RWMutex
+	root   common.Hash // Root of the state the snapshot is of
+	marker []byte      // Last account hash generated, nil if the snapshot is complete
+	gen    uint64      // Generation run, bumped to abort the running generator
+}
+
+// New opens the snapshot of the state of the root on disk, it's regenerated if
+// it's of another state, or its generation resumed if it was interrupted.
+func New(diskdb etruedb.Database, triedb *trie.Database, root common.Hash) (*Tree, error) {
+	if _, ok := diskdb.(etruedb.Iteratee); !ok {
+		return nil, ErrNotIteratee
+	}
+	t := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		root:   rawdb.ReadSnapshotRoot(diskdb),
+		marker: rawdb.ReadSnapshotGenerator(diskdb),
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	switch {
+	case t.root != root:
+		log.Info("Regenerating state snapshot", "root", root, "snapshot", t.root)
+		t.regenerate(root)
+	case t.marker != nil:
+		log.Info("Resuming state snapshot generation", "root", root, "at", common.BytesToHash(t.marker))
+		t.gen++
+		go t.generate(t.gen, false)
+	}
+	return t, nil
+}
+
+// Root returns the root of the state the snapshot is of.
+func (t *Tree) Root() common.Hash {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.root
+}
+
+// Generating reports whether the snapshot is being generated.
+func (t *Tree) Generating() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.marker != nil
+}
+
+// Stop aborts the generation of the snapshot, it's resumed when reopened.
+func (t *Tree) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.gen++
+}
+
+// covered reports whether the snapshot entries of the account are generated.
+func (t *Tree) covered(hash common.Hash) bool {
+	return t.marker == nil || (len(t.marker) > 0 && bytes.Compare(hash[:], t.marker) <= 0)
+}
+
+// Account returns the account trie leaf of the account hash in the state of
+// the root, nil if the account doesn't exist.
+func (t *Tree) Account(root, hash common.Hash) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.root != root || !t.covered(hash) {
+		return nil, ErrNotCovered
+	}
+	return rawdb.ReadAccountSnapshot(t.diskdb, hash), nil
+}
+
+// Storage returns the storage trie leaf of the slot hash of the account in the
+// state of the root, nil if the slot is empty.
+func (t *Tree) Storage(root, accountHash, storageHash common.Hash) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.root != root || !t.covered(accountHash) {
+		return nil, ErrNotCovered
+	}
+	return rawdb.ReadStorageSnapshot(t.diskdb, accountHash, storageHash), nil
+}
+
+// Update moves the snapshot from the state of the parent root to the one of the
+// root, applying the differences of the tries. If the snapshot isn't of the
+// parent state it's regenerated, unless the snapshot on disk is, e.g. when the
+// state was downloaded by a snapshot sync.
+func (t *Tree) Update(parent, root common.Hash) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.root != parent && rawdb.ReadSnapshotRoot(t.diskdb) == parent && rawdb.ReadSnapshotGenerator(t.diskdb) == nil {
+		log.Info("Adopting state snapshot on disk", "root", parent)
+		t.gen++
+		t.root, t.marker = parent, nil
+	}
+	if t.root != parent {
+		log.Warn("State snapshot not of parent state, regenerating", "parent", parent, "snapshot", t.root)
+		t.regenerate(root)
+		return nil
+	}
+	if parent == root {
+		return nil
+	}
+	batch := t.diskdb.NewBatch()
+	if err := t.applyDiff(batch, parent, root); err != nil {
+		log.Warn("Failed to update state snapshot, regenerating", "root", root, "err", err)
+		t.regenerate(root)
+		return err
+	}
+	t.root = root
+	rawdb.WriteSnapshotRoot(batch, root)
+	return batch.Write()
+}
+
+// applyDiff writes the account and storage leaves added, changed or removed
+// between the tries of the roots and covered by the snapshot.
+func (t *Tree) applyDiff(batch etruedb.Batch, parent, root common.Hash) error {
+	oldTr, err := trie.New(parent, t.triedb)
+	if err != nil {
+		return err
+	}
+	newTr, err := trie.New(root, t.triedb)
+	if err != nil {
+		return err
+	}
+	// Accounts created or changed, and their storage
+	diff, _ := trie.NewDifferenceIterator(oldTr.NodeIterator(nil), newTr.NodeIterator(nil))
+	it := trie.NewIterator(diff)
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		if !t.covered(hash) {
+			continue
+		}
+		rawdb.WriteAccountSnapshot(batch, hash, it.Value)
+
+		account, err := DecodeAccount(it.Value)
+		if err != nil {
+			return err
+		}
+		oldRoot := emptyRoot
+		if blob, err := oldTr.TryGet(hash[:]); err != nil {
+			return err
+		} else if blob != nil {
+			old, err := DecodeAccount(blob)
+			if err != nil {
+				return err
+			}
+			oldRoot = old.Root
+		}
+		if oldRoot != account.Root {
+			if err := t.applyStorageDiff(batch, hash, oldRoot, account.Root); err != nil {
+				return err
+			}
+		}
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	// Accounts removed
+	diff, _ = trie.NewDifferenceIterator(newTr.NodeIterator(nil), oldTr.NodeIterator(nil))
+	it = trie.NewIterator(diff)
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		if !t.covered(hash) {
+			continue
+		}
+		if blob, err := newTr.TryGet(hash[:]); err != nil {
+			return err
+		} else if blob != nil {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		if err := deleteStorage(t.diskdb, batch, hash); err != nil {
+			return err
+		}
+	}
+	return it.Err
+}
+
+// applyStorageDiff writes the storage leaves of the account changed between the
+// storage tries of the roots.
+func (t *Tree) applyStorageDiff(batch etruedb.Batch, accountHash, oldRoot, root common.Hash) error {
+	oldTr, err := trie.New(oldRoot, t.triedb)
+	if err != nil {
+		return err
+	}
+	newTr, err := trie.New(root, t.triedb)
+	if err != nil {
+		return err
+	}
+	diff, _ := trie.NewDifferenceIterator(oldTr.NodeIterator(nil), newTr.NodeIterator(nil))
+	it := trie.NewIterator(diff)
+	for it.Next() {
+		rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(it.Key), it.Value)
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	diff, _ = trie.NewDifferenceIterator(newTr.NodeIterator(nil), oldTr.NodeIterator(nil))
+	it = trie.NewIterator(diff)
+	for it.Next() {
+		if blob, err := newTr.TryGet(it.Key); err != nil {
+			return err
+		} else if blob == nil {
+			rawdb.DeleteStorageSnapshot(batch, accountHash, common.BytesToHash(it.Key))
+		}
+	}
+	return it.Err
+}
+
+// deleteStorage deletes the storage snapshot entries of the account.
+func deleteStorage(db etruedb.Database, batch etruedb.Batch, accountHash common.Hash) error {
+	prefix := rawdb.StorageSnapshotsPrefix(accountHash)
+	it := db.(etruedb.Iteratee).NewIteratorWithStart(prefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			batch.Delete(common.CopyBytes(key))
+		}
+	}
+	return it.Error()
+}
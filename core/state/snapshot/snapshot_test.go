@@ -0,0 +1,268 @@
+package snapshot
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/state"
+	"truechain/discovery/crypto"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/trie"
+)
+
+// makeTestState commits a state of accounts, some of them with storage and
+// code, and returns its root.
+func makeTestState(t *testing.T, sdb state.Database, root common.Hash, modify func(*state.StateDB)) common.Hash {
+	statedb, err := state.New(root, sdb)
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	modify(statedb)
+	root, err = statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	return root
+}
+
+func fillTestState(statedb *state.StateDB) {
+	for i := 0; i < 200; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.AddBalance(addr, big.NewInt(int64(i+1)))
+		if i%10 == 0 {
+			statedb.SetCode(addr, []byte{byte(i), 0x60, 0x00})
+			for j := 0; j < 50; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(i*j+1))))
+			}
+		}
+	}
+}
+
+// checkSnapshot checks that the snapshot entries are the leaves of the tries
+// of the root, no more and no less.
+func checkSnapshot(t *testing.T, db etruedb.Database, triedb *trie.Database, root common.Hash) {
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		t.Fatalf("failed to open account trie: %v", err)
+	}
+	var accounts, slots int
+	it := trie.NewIterator(accTrie.NodeIterator(nil))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		if have := rawdb.ReadAccountSnapshot(db, hash); string(have) != string(it.Value) {
+			t.Fatalf("account %x mismatch: have %x, want %x", hash, have, it.Value)
+		}
+		accounts++
+
+		account, _ := DecodeAccount(it.Value)
+		storageTrie, err := trie.New(account.Root, triedb)
+		if err != nil {
+			t.Fatalf("failed to open storage trie: %v", err)
+		}
+		sit := trie.NewIterator(storageTrie.NodeIterator(nil))
+		for sit.Next() {
+			slot := common.BytesToHash(sit.Key)
+			if have := rawdb.ReadStorageSnapshot(db, hash, slot); string(have) != string(sit.Value) {
+				t.Fatalf("slot %x of account %x mismatch: have %x, want %x", slot, hash, have, sit.Value)
+			}
+			slots++
+		}
+	}
+	var haveAccounts, haveSlots int
+	for _, key := range db.(*etruedb.MemDatabase).Keys() {
+		switch {
+		case len(key) == 1+common.HashLength && key[0] == rawdb.SnapshotAccountPrefix[0]:
+			haveAccounts++
+		case len(key) == 1+2*common.HashLength && key[0] == rawdb.SnapshotStoragePrefix[0]:
+			haveSlots++
+		}
+	}
+	if haveAccounts != accounts || haveSlots != slots {
+		t.Fatalf("snapshot entries mismatch: have %d accounts and %d slots, want %d and %d", haveAccounts, haveSlots, accounts, slots)
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != root {
+		t.Fatalf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+}
+
+func waitGeneration(t *testing.T, snap *Tree) {
+	for start := time.Now(); snap.Generating(); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("snapshot not generated")
+		}
+	}
+}
+
+// Tests that the snapshot is generated from the tries, and then follows the
+// changes of the state.
+func TestSnapshotGenerateAndUpdate(t *testing.T) {
+	var (
+		db   = etruedb.NewMemDatabase()
+		sdb  = state.NewDatabase(db)
+		root = makeTestState(t, sdb, common.Hash{}, fillTestState)
+	)
+	snap, err := New(db, sdb.TrieDB(), root)
+	if err != nil {
+		t.Fatalf("failed to open snapshot: %v", err)
+	}
+	waitGeneration(t, snap)
+	checkSnapshot(t, db, sdb.TrieDB(), root)
+
+	// Change balances and storage, delete and create accounts
+	next := makeTestState(t, sdb, root, func(statedb *state.StateDB) {
+		for i := 0; i < 200; i += 3 {
+			addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+			statedb.AddBalance(addr, big.NewInt(1))
+			statedb.SetState(addr, common.BigToHash(big.NewInt(1)), common.Hash{})
+			statedb.SetState(addr, common.BigToHash(big.NewInt(100)), common.BigToHash(big.NewInt(100)))
+		}
+		statedb.Suicide(common.BigToAddress(big.NewInt(11)))
+		statedb.Suicide(common.BigToAddress(big.NewInt(2)))
+		statedb.AddBalance(common.BigToAddress(big.NewInt(1000)), big.NewInt(1))
+	})
+	if err := snap.Update(root, next); err != nil {
+		t.Fatalf("failed to update snapshot: %v", err)
+	}
+	checkSnapshot(t, db, sdb.TrieDB(), next)
+
+	if blob, err := snap.Account(next, crypto.Keccak256Hash(common.BigToAddress(big.NewInt(2)).Bytes())); err != nil || blob != nil {
+		t.Errorf("deleted account in snapshot: %x, %v", blob, err)
+	}
+	if _, err := snap.Account(root, crypto.Keccak256Hash(common.BigToAddress(big.NewInt(1)).Bytes())); err != ErrNotCovered {
+		t.Errorf("stale state read error mismatch: have %v, want %v", err, ErrNotCovered)
+	}
+	// Updating from a state the snapshot isn't of regenerates it
+	last := makeTestState(t, sdb, next, func(statedb *state.StateDB) {
+		statedb.AddBalance(common.BigToAddress(big.NewInt(1)), big.NewInt(1))
+	})
+	snap.Update(root, last)
+	waitGeneration(t, snap)
+	checkSnapshot(t, db, sdb.TrieDB(), last)
+}
+
+// Tests that a state is rebuilt from the ranges served from the snapshot and
+// the tries, and that the ranges are checked against their proofs.
+func TestSnapshotRanges(t *testing.T) {
+	var (
+		db   = etruedb.NewMemDatabase()
+		sdb  = state.NewDatabase(db)
+		root = makeTestState(t, sdb, common.Hash{}, fillTestState)
+	)
+	snap, err := New(db, sdb.TrieDB(), root)
+	if err != nil {
+		t.Fatalf("failed to open snapshot: %v", err)
+	}
+	waitGeneration(t, snap)
+
+	for _, serve := range []*Tree{snap, nil} {
+		var (
+			dst    = etruedb.NewMemDatabase()
+			origin common.Hash
+			limit  = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		)
+		for done := false; !done; {
+			keys, values, proof, err := AccountRange(serve, sdb.TrieDB(), root, origin, limit, 1024)
+			if err != nil {
+				t.Fatalf("failed to serve account range: %v", err)
+			}
+			more, err := VerifyRange(root, origin, keys, values, proof)
+			if err != nil {
+				t.Fatalf("failed to verify account range: %v", err)
+			}
+			if len(keys) == 0 {
+				break
+			}
+			for i, key := range keys {
+				rawdb.WriteAccountSnapshot(dst, key, values[i])
+
+				account, _ := DecodeAccount(values[i])
+				if !account.HasStorage() {
+					continue
+				}
+				var slotOrigin common.Hash
+				for {
+					slots, blobs, proof, err := StorageRange(serve, sdb.TrieDB(), root, key, slotOrigin, 512)
+					if err != nil {
+						t.Fatalf("failed to serve storage range: %v", err)
+					}
+					if proof == nil {
+						if err := VerifyTrie(account.Root, slots, blobs); err != nil {
+							t.Fatalf("failed to verify storage: %v", err)
+						}
+					} else if _, err := VerifyRange(account.Root, slotOrigin, slots, blobs, proof); err != nil {
+						t.Fatalf("failed to verify storage range: %v", err)
+					}
+					for j, slot := range slots {
+						rawdb.WriteStorageSnapshot(dst, key, slot, blobs[j])
+					}
+					if proof == nil || len(slots) == 0 {
+						break
+					}
+					next, _ := nextKey(slots[len(slots)-1][:])
+					slotOrigin = common.BytesToHash(next)
+				}
+			}
+			next, _ := nextKey(keys[len(keys)-1][:])
+			origin, done = common.BytesToHash(next), !more
+		}
+		if err := CommitTrie(dst, root); err != nil {
+			t.Fatalf("failed to rebuild state: %v", err)
+		}
+		statedb, err := state.New(root, state.NewDatabase(dst))
+		if err != nil {
+			t.Fatalf("failed to open rebuilt state: %v", err)
+		}
+		addr := common.BigToAddress(big.NewInt(11))
+		if have, want := statedb.GetState(addr, common.BigToHash(big.NewInt(3))), common.BigToHash(big.NewInt(31)); have != want {
+			t.Errorf("rebuilt storage mismatch: have %x, want %x", have, want)
+		}
+	}
+	// The first leaf past the limit is served to prove the range
+	limit := common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	keys, values, proof, _ := AccountRange(snap, sdb.TrieDB(), root, common.Hash{}, limit, 1024)
+	if len(keys) < 4 {
+		t.Fatalf("account range too short: %d", len(keys))
+	}
+	for _, serve := range []*Tree{snap, nil} {
+		limited, _, _, err := AccountRange(serve, sdb.TrieDB(), root, common.Hash{}, decreaseHash(keys[2]), 1024)
+		if err != nil || len(limited) != 3 || limited[2] != keys[2] {
+			t.Errorf("limited range mismatch: have %x, %v, want up to %x", limited, err, keys[2])
+		}
+	}
+	// Tampered ranges are rejected
+	tests := []struct {
+		origin common.Hash
+		keys   []common.Hash
+		values [][]byte
+		ok     bool
+	}{
+		{common.Hash{}, keys, values, true},
+		{common.Hash{}, append(keys[:1:1], keys[2:]...), append(values[:1:1], values[2:]...), false}, // gap
+		{common.Hash{}, keys, append([][]byte{values[1]}, values[1:]...), false},                     // forged leaf
+		{keys[0], nil, nil, false}, // empty range
+	}
+	for i, tt := range tests {
+		if _, err := VerifyRange(root, tt.origin, tt.keys, tt.values, proof); (err == nil) != tt.ok {
+			t.Errorf("test %d: result mismatch: have %v, want ok %v", i, err, tt.ok)
+		}
+	}
+	if _, err := VerifyRange(root, common.Hash{}, keys, values, nil); err != errRangeProof {
+		t.Errorf("unproven range error mismatch: have %v, want %v", err, errRangeProof)
+	}
+	if _, err := VerifyRange(root, common.Hash{}, keys, values[1:], proof); err != errRangeLength {
+		t.Errorf("range length error mismatch: have %v, want %v", err, errRangeLength)
+	}
+}
+
+// decreaseHash returns the hash minus one.
+func decreaseHash(hash common.Hash) common.Hash {
+	for i := common.HashLength - 1; i >= 0; i-- {
+		if hash[i]--; hash[i] != 0xff {
+			break
+		}
+	}
+	return hash
+}
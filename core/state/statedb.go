@@ -46,6 +46,9 @@ var (
 
 	// Pos locked key
 	lockedPosition = common.BytesToHash([]byte{1})
+
+	// Sponsor spend key
+	sponsorPosition = common.BytesToHash([]byte{2})
 )
 
 type proofList [][]byte
@@ -60,6 +63,11 @@ func lockedKey(addr common.Address) (h common.Hash) {
 	return crypto.Keccak256Hash(base)
 }
 
+func sponsorSpendKey(payer common.Address, policy common.Hash) (h common.Hash) {
+	base := append(common.BytesToHash(payer[:]).Bytes(), policy.Bytes()...)
+	return crypto.Keccak256Hash(append(base, sponsorPosition.Bytes()...))
+}
+
 // StateDBs within the ethereum protocol are used to store anything
 // within the merkle trie. StateDBs take care of caching and storing
 // nested states. It's the general query interface to retrieve:
@@ -99,6 +107,12 @@ type StateDB struct {
 	nextRevisionId int
 
 	touchedAddress *TouchedAddressObject
+	trackSlots     bool                  // Record the storage slots touched, only for the parallel execution
+	trackChanges   bool                  // Record the accounts and storage slots changed, only for the state diff
+	changed        *TouchedAddressObject // Accounts and storage slots changed since the tracking started
+
+	// Per-transaction access list
+	accessList *accessList
 
 	lock sync.Mutex
 }
@@ -120,6 +134,8 @@ func New(root common.Hash, db Database) (*StateDB, error) {
 		journals:          make(map[common.Hash]*journal),
 		journal:           newJournal(),
 		touchedAddress:    NewTouchedAddressObject(),
+		changed:           NewTouchedAddressObject(),
+		accessList:        newAccessList(),
 	}, nil
 }
 
@@ -152,6 +168,8 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.preimages = make(map[common.Hash][]byte)
 	self.clearJournalAndRefund()
 	self.touchedAddress = NewTouchedAddressObject()
+	self.changed = NewTouchedAddressObject()
+	self.accessList = newAccessList()
 	return nil
 }
 
@@ -296,6 +314,9 @@ func (self *StateDB) GetCodeHash(addr common.Address) common.Hash {
 
 // GetState retrieves a value from the given account's storage trie.
 func (self *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
+	if self.trackSlots {
+		self.touchedAddress.AddStorageOp(addr, hash, false)
+	}
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetState(self.db, hash)
@@ -316,6 +337,12 @@ func (self *StateDB) GetPOSLocked(addr common.Address) *big.Int {
 	return self.GetState(types.StakingAddress, key).Big()
 }
 
+// GetSponsorSpend returns the gas fee the payer spent under the sponsor policy
+// of the hash.
+func (self *StateDB) GetSponsorSpend(payer common.Address, policy common.Hash) *big.Int {
+	return self.GetState(types.SponsorAddress, sponsorSpendKey(payer, policy)).Big()
+}
+
 // GetProof returns the MerkleProof for a given Account
 func (self *StateDB) GetProof(a common.Address) ([][]byte, error) {
 	var proof proofList
@@ -336,6 +363,9 @@ func (self *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byt
 
 // GetCommittedState retrieves a value from the given account's committed storage trie.
 func (self *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
+	if self.trackSlots {
+		self.touchedAddress.AddStorageOp(addr, hash, false)
+	}
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetCommittedState(self.db, hash)
@@ -430,9 +460,28 @@ func (self *StateDB) SetPOSState(addr common.Address, key common.Hash, value []b
 func (self *StateDB) SetPOSLocked(addr common.Address, value *big.Int) {
 	key := lockedKey(addr)
 	self.SetState(types.StakingAddress, key, common.BigToHash(value))
+	// The locked balance is kept in the staking contract, mark the account
+	if self.trackChanges {
+		self.changed.AddAccountOp(addr, true)
+	}
+}
+
+// AddSponsorSpend adds the gas fee the payer spent under the sponsor policy of
+// the hash, kept in the storage of the sponsor system account. The account gets
+// a nonce so it isn't deleted as empty.
+func (self *StateDB) AddSponsorSpend(payer common.Address, policy common.Hash, amount *big.Int) {
+	if self.GetNonce(types.SponsorAddress) == 0 {
+		self.SetNonce(types.SponsorAddress, 1)
+	}
+	key := sponsorSpendKey(payer, policy)
+	spent := self.GetState(types.SponsorAddress, key).Big()
+	self.SetState(types.SponsorAddress, key, common.BigToHash(spent.Add(spent, amount)))
 }
 
 func (self *StateDB) SetState(addr common.Address, key, value common.Hash) {
+	if self.trackSlots {
+		self.touchedAddress.AddStorageOp(addr, key, false)
+	}
 	stateObject := self.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetState(self.db, key, value)
@@ -623,6 +672,9 @@ func (self *StateDB) Copy() *StateDB {
 		journals:          make(map[common.Hash]*journal),
 		journal:           newJournal(),
 		touchedAddress:    NewTouchedAddressObject(),
+		trackSlots:        self.trackSlots,
+		trackChanges:      self.trackChanges,
+		changed:           NewTouchedAddressObject(),
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.journal.dirties {
@@ -655,6 +707,9 @@ func (self *StateDB) Copy() *StateDB {
 	for hash, preimage := range self.preimages {
 		state.preimages[hash] = preimage
 	}
+	// The access list isn't journalled in the copy, but the copy may be used to
+	// run the rest of the transaction, so keep it
+	state.accessList = self.accessList.Copy()
 	return state
 }
 
@@ -718,7 +773,15 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 	}
 
 	for _, v := range s.journal.entries {
-		if _, ok := v.(balanceChange); ok {
+		if addr := v.dirtied(); addr != nil && s.trackChanges {
+			s.changed.AddAccountOp(*addr, true)
+			if ch, ok := v.(storageChange); ok {
+				s.changed.AddStorageOp(*addr, ch.key, true)
+			}
+		}
+		// The copies don't record the balance changes, only the state the
+		// block is written from does
+		if _, ok := v.(balanceChange); ok && s.balancesChange != nil {
 			stateObject, exist := s.stateObjects[*v.dirtied()]
 			if !exist {
 				continue
@@ -922,16 +985,59 @@ func (s *StateDB) addTouchedAddress(address common.Address) {
 	s.touchedAddress.AddAccountOp(address, false)
 }
 
+// SetTrackSlots sets whether the storage slots touched by the transactions are
+// recorded in the touched addresses. It's only needed to detect the conflicts
+// of the parallel execution per storage slot, the copies inherit it.
+func (s *StateDB) SetTrackSlots(track bool) {
+	s.trackSlots = track
+}
+
+// SetTrackChanges sets whether the accounts and storage slots changed are
+// recorded when the changes are finalised, for the state diff. The copies
+// inherit it.
+func (s *StateDB) SetTrackChanges(track bool) {
+	s.trackChanges = track
+}
+
 func (s *StateDB) FinalizeTouchedAddress() *TouchedAddressObject {
 	result := s.touchedAddress
+	journal, ok := s.journals[s.thash]
+	if !ok {
+		// The transaction isn't prepared, the current journal is its own
+		journal = s.journal
+	}
 
 	for addr, op := range result.accountOp {
 		if op == false {
-			if _, ok := s.journals[s.thash].dirties[addr]; ok {
+			if _, ok := journal.dirties[addr]; ok {
 				result.SetAccountOp(addr, true)
 			}
 		}
 	}
+	// Split the accounts written in the storage only from the others
+	written := make(map[common.Address]bool)
+	for _, entry := range journal.entries {
+		if !s.trackSlots {
+			break
+		}
+		addr := entry.dirtied()
+		if addr == nil {
+			continue
+		}
+		if ch, ok := entry.(storageChange); ok {
+			result.AddStorageOp(*addr, ch.key, true)
+			if _, exist := written[*addr]; !exist {
+				written[*addr] = true
+			}
+		} else {
+			written[*addr] = false
+		}
+	}
+	for addr, storageOnly := range written {
+		if storageOnly && result.accountOp[addr] {
+			result.storageOnly[addr] = struct{}{}
+		}
+	}
 
 	s.touchedAddress = NewTouchedAddressObject()
 
@@ -976,6 +1082,28 @@ func (self *StateDB) CopyStateObjFromOtherDB(other *StateDB, stateObjAddrs map[c
 		}
 	}
 }
+
+// MergeStorageFromOtherDB copies the storage slots of the account from the
+// other db, the slots not listed are kept.
+func (self *StateDB) MergeStorageFromOtherDB(other *StateDB, addr common.Address, slots map[common.Hash]struct{}) {
+	if self == other {
+		return
+	}
+	src := other.stateObjects[addr]
+	dst := self.stateObjects[addr]
+	if src == nil || dst == nil {
+		log.Info("Unexpected nil object in MergeStorageFromOtherDB", "addr", addr.String())
+		return
+	}
+	// The object may be shared with the db it's copied from
+	dst = dst.deepCopy(self)
+	for slot := range slots {
+		dst.GetCommittedState(self.db, slot)
+		dst.setState(slot, src.GetState(other.db, slot))
+	}
+	self.setStateObject(dst)
+}
+
 func (self *StateDB) CopyJournalLogPreImageFromOtherDB(other *StateDB, txHash common.Hash) {
 	self.journals[txHash] = other.journals[txHash]
 	self.logs[txHash] = other.logs[txHash]
@@ -988,6 +1116,31 @@ func (self *StateDB) AddCallArg(arg []byte) {
 	}
 }
 
+// MergeDBStorage merges the storage slots of the object copied from another db
+// into the account and updates the trie, the slots not listed are kept.
+func (self *StateDB) MergeDBStorage(addr common.Address, obj interface{}, slots map[common.Hash]struct{}) {
+	src := obj.(*stateObject)
+	dst := self.stateObjects[addr]
+	if dst == nil {
+		log.Info("Unexpected nil object in MergeDBStorage", "addr", addr.String())
+		return
+	}
+	// The object may be shared with the db it's copied from
+	dst = dst.deepCopy(self)
+	for slot := range slots {
+		dst.GetCommittedState(self.db, slot)
+		dst.setState(slot, src.GetState(self.db, slot))
+	}
+	dst.updateRoot(self.db)
+	data, err := rlp.EncodeToBytes(dst)
+	if err != nil {
+		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
+	}
+	self.setError(self.trie.TryUpdate(addr[:], data))
+	self.stateObjects[addr] = dst
+	self.stateObjectsDirty[addr] = struct{}{}
+}
+
 func (self *StateDB) UpdateDBTrie(addr common.Address, obj interface{}, data []byte) {
 	if data == nil {
 		self.setError(self.trie.TryDelete(addr[:]))
@@ -998,3 +1151,62 @@ func (self *StateDB) UpdateDBTrie(addr common.Address, obj interface{}, data []b
 		self.stateObjectsDirty[addr] = struct{}{}
 	}
 }
+
+// PrepareAccessList clears the access list of the state and adds the sender,
+// the destination and the precompiles of a transaction to it, as they are
+// accessed by it from the start, along with the accounts and the storage slots
+// of the access list of the transaction.
+//
+// This method should only be called if the TIP13 upgrade is active.
+func (self *StateDB) PrepareAccessList(sender common.Address, dst *common.Address, precompiles []common.Address, list types.AccessList) {
+	self.accessList = newAccessList()
+	self.AddAddressToAccessList(sender)
+	if dst != nil {
+		self.AddAddressToAccessList(*dst)
+	}
+	for _, addr := range precompiles {
+		self.AddAddressToAccessList(addr)
+	}
+	for _, el := range list {
+		self.AddAddressToAccessList(el.Address)
+		for _, key := range el.StorageKeys {
+			self.AddSlotToAccessList(el.Address, key)
+		}
+	}
+}
+
+// AddAddressToAccessList adds the given address to the access list
+func (self *StateDB) AddAddressToAccessList(addr common.Address) {
+	if self.accessList.AddAddress(addr) {
+		self.journal.append(accessListAddAccountChange{self.accessList, &addr})
+	}
+}
+
+// AddSlotToAccessList adds the given (address, slot)-tuple to the access list
+func (self *StateDB) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	addrMod, slotMod := self.accessList.AddSlot(addr, slot)
+	if addrMod {
+		// In practice, this should not happen, since there is no way to enter the
+		// scope of 'address' without having the 'address' become already added
+		// to the access list (via call-variant, create, etc).
+		// Better safe than sorry, though
+		self.journal.append(accessListAddAccountChange{self.accessList, &addr})
+	}
+	if slotMod {
+		self.journal.append(accessListAddSlotChange{
+			list:    self.accessList,
+			address: &addr,
+			slot:    &slot,
+		})
+	}
+}
+
+// AddressInAccessList returns true if the given address is in the access list.
+func (self *StateDB) AddressInAccessList(addr common.Address) bool {
+	return self.accessList.ContainsAddress(addr)
+}
+
+// SlotInAccessList returns true if the given (address, slot)-tuple is in the access list.
+func (self *StateDB) SlotInAccessList(addr common.Address, slot common.Hash) (addressPresent bool, slotPresent bool) {
+	return self.accessList.Contains(addr, slot)
+}
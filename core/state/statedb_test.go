@@ -31,6 +31,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"truechain/discovery/common"
+	"truechain/discovery/core/types"
 	ethdb "truechain/discovery/etruedb"
 )
 
@@ -443,3 +444,335 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that the storage slots touched by a transaction are recorded, and the
+// account written in the storage only is keyed by slot.
+func TestTouchedStorageSlots(t *testing.T) {
+	sdb, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	token, sender := common.HexToAddress("aaaa"), common.HexToAddress("bbbb")
+	slot0, slot1 := common.HexToHash("01"), common.HexToHash("02")
+	sdb.SetCode(token, []byte{1})
+	sdb.SetBalance(sender, big.NewInt(42))
+	root, _ := sdb.Commit(false)
+	sdb.Reset(root)
+	sdb.SetTrackSlots(true)
+
+	sdb.Prepare(common.HexToHash("01"), common.Hash{}, 0)
+	sdb.SubBalance(sender, big.NewInt(1))
+	sdb.GetCode(token)
+	sdb.SetState(token, slot0, common.HexToHash("ff"))
+	sdb.GetState(token, slot1)
+	touched := sdb.FinalizeTouchedAddress()
+
+	if !touched.StorageOnly(token) || touched.StorageOnly(sender) {
+		t.Fatalf("storage only mismatch: token %v, sender %v", touched.StorageOnly(token), touched.StorageOnly(sender))
+	}
+	want := map[TouchedKey]bool{
+		{Address: sender}: true,
+		{Address: token}:  false,
+		{Address: token, Slot: slot0, Storage: true}: true,
+		{Address: token, Slot: slot1, Storage: true}: false,
+	}
+	if keys := touched.KeyOp(true); !reflect.DeepEqual(keys, want) {
+		t.Errorf("slot keys mismatch: have %v, want %v", keys, want)
+	}
+	want = map[TouchedKey]bool{{Address: sender}: true, {Address: token}: true}
+	if keys := touched.KeyOp(false); !reflect.DeepEqual(keys, want) {
+		t.Errorf("account keys mismatch: have %v, want %v", keys, want)
+	}
+}
+
+// Tests that the slots written to an account by different copies are merged.
+func TestMergeDBStorage(t *testing.T) {
+	sdb, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	token := common.HexToAddress("aaaa")
+	slot0, slot1 := common.HexToHash("01"), common.HexToHash("02")
+	sdb.SetCode(token, []byte{1})
+	sdb.SetState(token, slot0, common.HexToHash("01"))
+	sdb.SetState(token, slot1, common.HexToHash("01"))
+	root, _ := sdb.Commit(false)
+	sdb.Reset(root)
+
+	group0, group1, merged := sdb.Copy(), sdb.Copy(), sdb.Copy()
+	group0.SetState(token, slot0, common.HexToHash("02"))
+	group1.SetState(token, slot1, common.HexToHash("03"))
+
+	obj, data, _ := sdb.CopyStateObjRlpDataFromOtherDB(group0, token)
+	merged.UpdateDBTrie(token, obj, data)
+	obj, _, _ = sdb.CopyStateObjRlpDataFromOtherDB(group1, token)
+	merged.MergeDBStorage(token, obj, map[common.Hash]struct{}{slot1: {}})
+
+	if have := merged.GetState(token, slot0); have != common.HexToHash("02") {
+		t.Errorf("slot0 mismatch: have %x, want 02", have)
+	}
+	if have := merged.GetState(token, slot1); have != common.HexToHash("03") {
+		t.Errorf("slot1 mismatch: have %x, want 03", have)
+	}
+	sdb.SetState(token, slot0, common.HexToHash("02"))
+	sdb.SetState(token, slot1, common.HexToHash("03"))
+	if have, want := merged.IntermediateRoot(false), sdb.IntermediateRoot(false); have != want {
+		t.Errorf("root mismatch: have %x, want %x", have, want)
+	}
+}
+
+// Tests that the diff reports the accounts, the storage slots and the locked
+// balances changed, before and after the changes.
+func TestStateDiff(t *testing.T) {
+	sdb, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	token, sender, staker, idle, gone := common.HexToAddress("aaaa"), common.HexToAddress("bbbb"),
+		common.HexToAddress("cccc"), common.HexToAddress("dddd"), common.HexToAddress("eeee")
+	slot0, slot1 := common.HexToHash("01"), common.HexToHash("02")
+	sdb.SetCode(token, []byte{1})
+	sdb.SetState(token, slot0, common.HexToHash("01"))
+	sdb.SetBalance(sender, big.NewInt(42))
+	sdb.SetBalance(staker, big.NewInt(100))
+	sdb.SetBalance(idle, big.NewInt(7))
+	sdb.SetBalance(gone, big.NewInt(1))
+	sdb.SetBalance(types.StakingAddress, big.NewInt(1))
+	root, _ := sdb.Commit(false)
+	sdb.Reset(root)
+
+	pre := sdb.Copy()
+	sdb.SetTrackChanges(true)
+	sdb.SubBalance(sender, big.NewInt(2))
+	sdb.SetNonce(sender, 1)
+	sdb.SetState(token, slot0, common.HexToHash("02"))
+	sdb.SetState(token, slot1, common.HexToHash("03"))
+	sdb.SetPOSLocked(staker, big.NewInt(60))
+	sdb.AddBalance(idle, big.NewInt(0))
+	sdb.Suicide(gone)
+	snapshot := sdb.Snapshot()
+	sdb.SetBalance(idle, big.NewInt(8))
+	sdb.RevertToSnapshot(snapshot)
+	sdb.Finalise(true)
+
+	diffs := sdb.Diff(pre)
+	if len(diffs) != 5 {
+		t.Fatalf("diff count mismatch: have %d, want 5", len(diffs))
+	}
+	if diff := diffs[sender]; diff.Pre.Balance.ToInt().Int64() != 42 || diff.Post.Balance.ToInt().Int64() != 40 ||
+		diff.Pre.Nonce != 0 || diff.Post.Nonce != 1 || diff.Storage != nil {
+		t.Errorf("sender diff mismatch: pre %+v, post %+v", diff.Pre, diff.Post)
+	}
+	want := map[common.Hash]*DiffStorage{
+		slot0: {Pre: common.HexToHash("01"), Post: common.HexToHash("02")},
+		slot1: {Pre: common.Hash{}, Post: common.HexToHash("03")},
+	}
+	if diff := diffs[token]; !reflect.DeepEqual(diff.Storage, want) || diff.Pre.Code != nil || diff.Post.Code != nil {
+		t.Errorf("token diff mismatch: storage %v, code %x %x", diff.Storage, diff.Pre.Code, diff.Post.Code)
+	}
+	if diff := diffs[staker]; diff.Pre.Locked.ToInt().Sign() != 0 || diff.Post.Locked.ToInt().Int64() != 60 ||
+		diff.Post.Balance.ToInt().Int64() != 100 {
+		t.Errorf("staker diff mismatch: pre %+v, post %+v", diff.Pre, diff.Post)
+	}
+	if diff := diffs[types.StakingAddress]; len(diff.Storage) != 1 {
+		t.Errorf("staking storage mismatch: %v", diff.Storage)
+	}
+	if diff := diffs[gone]; diff.Pre == nil || diff.Post != nil {
+		t.Errorf("deleted diff mismatch: pre %+v, post %+v", diff.Pre, diff.Post)
+	}
+	if _, ok := diffs[idle]; ok {
+		t.Errorf("unchanged account reported")
+	}
+}
+
+// Tests that the changes are only recorded once tracked, and that the copies
+// don't record the balance changes.
+func TestTrackChanges(t *testing.T) {
+	sdb, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	addr, staker := common.HexToAddress("aaaa"), common.HexToAddress("bbbb")
+	sdb.SetBalance(types.StakingAddress, big.NewInt(1))
+
+	sdb.AddBalance(addr, big.NewInt(1))
+	sdb.SetState(addr, common.HexToHash("01"), common.HexToHash("01"))
+	sdb.SetPOSLocked(staker, big.NewInt(1))
+	sdb.Finalise(true)
+	if n := len(sdb.Changed().AccountOp()); n != 0 {
+		t.Errorf("untracked changes recorded: %d accounts", n)
+	}
+	if _, ok := sdb.BalancesChange()[addr]; !ok {
+		t.Errorf("balance change not recorded")
+	}
+	cpy := sdb.Copy()
+	cpy.SetTrackChanges(true)
+	cpy.AddBalance(addr, big.NewInt(1))
+	cpy.SetPOSLocked(staker, big.NewInt(2))
+	cpy.Finalise(true)
+	if n := len(cpy.Changed().AccountOp()); n != 3 {
+		t.Errorf("tracked changes mismatch: have %d accounts, want 3", n)
+	}
+	if cpy.BalancesChange() != nil {
+		t.Errorf("balance changes recorded on a copy")
+	}
+}
+
+// Tests that the sponsor spending is kept in the storage of the sponsor system
+// account, which survives the deletion of the empty accounts, and that the
+// staking contract storage is left alone.
+func TestSponsorSpend(t *testing.T) {
+	sdb, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	sdb.SetBalance(types.StakingAddress, big.NewInt(1))
+	payer, other := common.HexToAddress("aaaa"), common.HexToAddress("bbbb")
+	policy, otherPolicy := common.HexToHash("01"), common.HexToHash("02")
+
+	sdb.AddSponsorSpend(payer, policy, big.NewInt(10))
+	sdb.AddSponsorSpend(payer, policy, big.NewInt(5))
+	sdb.AddSponsorSpend(payer, otherPolicy, big.NewInt(7))
+	root, _ := sdb.Commit(true)
+	sdb, _ = New(root, sdb.Database())
+
+	for _, tt := range []struct {
+		payer  common.Address
+		policy common.Hash
+		spent  int64
+	}{
+		{payer, policy, 15},
+		{payer, otherPolicy, 7},
+		{other, policy, 0},
+	} {
+		if spent := sdb.GetSponsorSpend(tt.payer, tt.policy); spent.Int64() != tt.spent {
+			t.Errorf("spend of %x under %x: have %v, want %d", tt.payer, tt.policy, spent, tt.spent)
+		}
+		key := sponsorSpendKey(tt.payer, tt.policy)
+		if have := sdb.GetState(types.SponsorAddress, key).Big(); have.Int64() != tt.spent {
+			t.Errorf("sponsor storage of %x under %x: have %v, want %d", tt.payer, tt.policy, have, tt.spent)
+		}
+		if have := sdb.GetState(types.StakingAddress, key); have != (common.Hash{}) {
+			t.Errorf("sponsor spend of %x under %x in the staking storage: %x", tt.payer, tt.policy, have)
+		}
+	}
+	if !sdb.Exist(types.SponsorAddress) {
+		t.Errorf("sponsor account deleted as empty")
+	}
+}
+
+func TestStateDBAccessList(t *testing.T) {
+	// Some helpers
+	addr := func(a string) common.Address {
+		return common.HexToAddress(a)
+	}
+	slot := func(a string) common.Hash {
+		return common.HexToHash(a)
+	}
+
+	memDb := ethdb.NewMemDatabase()
+	db := NewDatabase(memDb)
+	state, _ := New(common.Hash{}, db)
+
+	verifyAddrs := func(astrings ...string) {
+		t.Helper()
+		// convert to common.Address form
+		var addresses []common.Address
+		var addressMap = make(map[common.Address]struct{})
+		for _, astring := range astrings {
+			address := addr(astring)
+			addresses = append(addresses, address)
+			addressMap[address] = struct{}{}
+		}
+		// Check that the given addresses are in the access list
+		for _, address := range addresses {
+			if !state.AddressInAccessList(address) {
+				t.Fatalf("expected %x to be in access list", address)
+			}
+		}
+		// Check that only the expected addresses are present in the acesslist
+		for address := range state.accessList.addresses {
+			if _, exist := addressMap[address]; !exist {
+				t.Fatalf("extra address %x in access list", address)
+			}
+		}
+	}
+	verifySlots := func(addrString string, slotStrings ...string) {
+		if !state.AddressInAccessList(addr(addrString)) {
+			t.Fatalf("scope missing address/slots %v", addrString)
+		}
+		var address = addr(addrString)
+		// convert to common.Hash form
+		var slots []common.Hash
+		var slotMap = make(map[common.Hash]struct{})
+		for _, slotString := range slotStrings {
+			s := slot(slotString)
+			slots = append(slots, s)
+			slotMap[s] = struct{}{}
+		}
+		// Check that the expected items are in the access list
+		for i, s := range slots {
+			if _, slotPresent := state.SlotInAccessList(address, s); !slotPresent {
+				t.Fatalf("input %d: scope missing slot %v (address %v)", i, s, addrString)
+			}
+		}
+		// Check that no extra elements are in the access list
+		index := state.accessList.addresses[address]
+		if index >= 0 {
+			stateSlots := state.accessList.slots[index]
+			for s := range stateSlots {
+				if _, slotPresent := slotMap[s]; !slotPresent {
+					t.Fatalf("scope has extra slot %v (address %v)", s, addrString)
+				}
+			}
+		}
+	}
+
+	state.AddAddressToAccessList(addr("aa"))          // 1
+	state.AddSlotToAccessList(addr("bb"), slot("01")) // 2,3
+	state.AddSlotToAccessList(addr("bb"), slot("02")) // 4
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01", "02")
+
+	// Make a copy
+	stateCopy1 := state.Copy()
+
+	// Add some more
+	snap := state.Snapshot()
+	state.AddAddressToAccessList(addr("cc"))
+	state.AddSlotToAccessList(addr("aa"), slot("01"))
+	state.AddSlotToAccessList(addr("bb"), slot("03"))
+	verifyAddrs("aa", "bb", "cc")
+	verifySlots("aa", "01")
+	verifySlots("bb", "01", "02", "03")
+
+	// Reverting the changes brings the access list back
+	state.RevertToSnapshot(snap)
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01", "02")
+	if _, slotPresent := state.SlotInAccessList(addr("aa"), slot("01")); slotPresent {
+		t.Fatalf("slot present, expected missing")
+	}
+
+	// The copy is independent of the changes
+	state = stateCopy1
+	verifyAddrs("aa", "bb")
+	verifySlots("bb", "01", "02")
+
+	// Preparing a transaction resets the list
+	state.PrepareAccessList(addr("dd"), &common.Address{0xee}, []common.Address{addr("01")}, types.AccessList{
+		{Address: addr("ff"), StorageKeys: []common.Hash{slot("03")}},
+	})
+	verifyAddrs("dd", "0xee00000000000000000000000000000000000000", "01", "ff")
+	verifySlots("ff", "03")
+}
+
+// Tests that the storage slots are only recorded if the tracking is turned on.
+func TestTrackSlots(t *testing.T) {
+	token := common.HexToAddress("aaaa")
+	slot := common.HexToHash("01")
+	tests := []struct {
+		track bool
+		want  int
+	}{
+		{false, 1}, // the account only
+		{true, 3},
+	}
+	for _, tt := range tests {
+		sdb, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+		sdb.SetTrackSlots(tt.track)
+
+		cpy := sdb.Copy()
+		cpy.Prepare(common.HexToHash("01"), common.Hash{}, 0)
+		cpy.SetState(token, slot, common.HexToHash("ff"))
+		cpy.GetCommittedState(token, common.HexToHash("02"))
+		if keys := cpy.FinalizeTouchedAddress().KeyOp(true); len(keys) != tt.want {
+			t.Errorf("track %v: keys mismatch: have %v, want %d", tt.track, keys, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package state
+
+import (
+	"math/big"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+)
+
+// DiffAccount is an account in the state before or after the changes, the code
+// is set only if it changed.
+type DiffAccount struct {
+	Balance  *hexutil.Big   `json:"balance"`
+	Locked   *hexutil.Big   `json:"locked"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	CodeHash common.Hash    `json:"codeHash"`
+	Code     hexutil.Bytes  `json:"code,omitempty"`
+}
+
+// DiffStorage is a storage slot before and after the changes.
+type DiffStorage struct {
+	Pre  common.Hash `json:"pre"`
+	Post common.Hash `json:"post"`
+}
+
+// AccountDiff is an account changed, Pre is nil if it was created and Post is
+// nil if it was deleted.
+type AccountDiff struct {
+	Pre     *DiffAccount                 `json:"pre"`
+	Post    *DiffAccount                 `json:"post"`
+	Storage map[common.Hash]*DiffStorage `json:"storage,omitempty"`
+}
+
+// Changed returns the accounts and the storage slots changed since the tracking
+// was turned on by SetTrackChanges, recorded when the changes are finalised.
+// The accounts whose locked balance is set are included, the ones touched
+// without any change may be too.
+func (self *StateDB) Changed() *TouchedAddressObject {
+	return self.changed
+}
+
+// Diff returns the accounts and the storage slots changed since pre, the state
+// it was copied from or opened on the same root, by the values before and
+// after the changes. The changes must be tracked since then. The accounts changed back to their values in pre aren't
+// reported.
+func (self *StateDB) Diff(pre *StateDB) map[common.Address]*AccountDiff {
+	diffs := make(map[common.Address]*AccountDiff)
+	for addr := range self.changed.AccountOp() {
+		diff := &AccountDiff{
+			Pre:  readDiffAccount(pre, addr),
+			Post: readDiffAccount(self, addr),
+		}
+		for slot := range self.changed.StorageOp(addr) {
+			before, after := pre.GetState(addr, slot), self.GetState(addr, slot)
+			if before == after {
+				continue
+			}
+			if diff.Storage == nil {
+				diff.Storage = make(map[common.Hash]*DiffStorage)
+			}
+			diff.Storage[slot] = &DiffStorage{Pre: before, Post: after}
+		}
+		switch {
+		case diff.Pre == nil && diff.Post == nil:
+			continue
+		case diff.Pre != nil && diff.Post != nil:
+			if diff.Pre.CodeHash == diff.Post.CodeHash {
+				diff.Pre.Code, diff.Post.Code = nil, nil
+				if diff.Storage == nil && sameDiffAccount(diff.Pre, diff.Post) {
+					continue
+				}
+			}
+		}
+		diffs[addr] = diff
+	}
+	return diffs
+}
+
+// readDiffAccount reads the account from the state, or returns nil if it
+// doesn't exist and has nothing locked.
+func readDiffAccount(statedb *StateDB, addr common.Address) *DiffAccount {
+	locked := statedb.GetPOSLocked(addr)
+	if !statedb.Exist(addr) && locked.Sign() == 0 {
+		return nil
+	}
+	return &DiffAccount{
+		Balance:  (*hexutil.Big)(new(big.Int).Set(statedb.GetBalance(addr))),
+		Locked:   (*hexutil.Big)(locked),
+		Nonce:    hexutil.Uint64(statedb.GetNonce(addr)),
+		CodeHash: statedb.GetCodeHash(addr),
+		Code:     statedb.GetCode(addr),
+	}
+}
+
+func sameDiffAccount(a, b *DiffAccount) bool {
+	return a.Balance.ToInt().Cmp(b.Balance.ToInt()) == 0 && a.Locked.ToInt().Cmp(b.Locked.ToInt()) == 0 &&
+		a.Nonce == b.Nonce && a.CodeHash == b.CodeHash
+}
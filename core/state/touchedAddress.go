@@ -4,12 +4,24 @@ import "truechain/discovery/common"
 
 type TouchedAddressObject struct {
 	accountOp    map[common.Address]bool
+	storageOp    map[common.Address]map[common.Hash]bool // storage slots touched, true if written
+	storageOnly  map[common.Address]struct{}             // accounts written in the storage only
 	accountInArg []common.Address
 }
 
+// TouchedKey is an account or a storage slot of it touched by a transaction,
+// the key of the conflicts between the execution groups.
+type TouchedKey struct {
+	Address common.Address
+	Slot    common.Hash
+	Storage bool
+}
+
 func NewTouchedAddressObject() *TouchedAddressObject {
 	return &TouchedAddressObject{
-		accountOp: make(map[common.Address]bool),
+		accountOp:   make(map[common.Address]bool),
+		storageOp:   make(map[common.Address]map[common.Hash]bool),
+		storageOnly: make(map[common.Address]struct{}),
 	}
 }
 
@@ -20,6 +32,7 @@ func (tao *TouchedAddressObject) AccountOp() map[common.Address]bool {
 func (tao *TouchedAddressObject) AddAccountOp(addr common.Address, op bool) {
 	if op {
 		tao.accountOp[addr] = op
+		delete(tao.storageOnly, addr)
 	} else {
 		if _, exist := tao.accountOp[addr]; !exist {
 			tao.accountOp[addr] = op
@@ -31,14 +44,71 @@ func (tao *TouchedAddressObject) SetAccountOp(addr common.Address, op bool) {
 	tao.accountOp[addr] = op
 }
 
+// StorageOp returns the storage slots of the account touched.
+func (tao *TouchedAddressObject) StorageOp(addr common.Address) map[common.Hash]bool {
+	return tao.storageOp[addr]
+}
+
+// AddStorageOp records a storage slot touched, a write overrides a read.
+func (tao *TouchedAddressObject) AddStorageOp(addr common.Address, slot common.Hash, op bool) {
+	slots, exist := tao.storageOp[addr]
+	if !exist {
+		slots = make(map[common.Hash]bool)
+		tao.storageOp[addr] = slots
+	}
+	if op || !slots[slot] {
+		slots[slot] = op
+	}
+}
+
+// StorageOnly reports whether the account is written in the storage only, its
+// balance, nonce and code are untouched.
+func (tao *TouchedAddressObject) StorageOnly(addr common.Address) bool {
+	_, ok := tao.storageOnly[addr]
+	return ok
+}
+
+func (tao *TouchedAddressObject) accountWritten(addr common.Address) bool {
+	return tao.accountOp[addr] && !tao.StorageOnly(addr)
+}
+
+// KeyOp returns the keys touched and true if written. If slotLevel is set the
+// storage slots are keyed apart from the account, whose key is then written
+// only if its balance, nonce or code is.
+func (tao *TouchedAddressObject) KeyOp(slotLevel bool) map[TouchedKey]bool {
+	keys := make(map[TouchedKey]bool, len(tao.accountOp))
+	for addr, op := range tao.accountOp {
+		if !slotLevel {
+			keys[TouchedKey{Address: addr}] = op
+			continue
+		}
+		keys[TouchedKey{Address: addr}] = tao.accountWritten(addr)
+		for slot, sop := range tao.storageOp[addr] {
+			keys[TouchedKey{Address: addr, Slot: slot, Storage: true}] = sop
+		}
+	}
+	return keys
+}
+
 // Merge 2 TouchedAddressObject, return true if first object changes
 func (tao *TouchedAddressObject) Merge(other *TouchedAddressObject) bool {
 	changed := false
 	for address, op := range other.accountOp {
+		written := tao.accountWritten(address) || other.accountWritten(address)
 		if origOp, exist := tao.accountOp[address]; !exist || (op == true && origOp == false) {
 			changed = true
 			tao.accountOp[address] = op
 		}
+		if tao.accountOp[address] && !written {
+			tao.storageOnly[address] = struct{}{}
+		} else {
+			delete(tao.storageOnly, address)
+		}
+	}
+	for address, slots := range other.storageOp {
+		for slot, op := range slots {
+			tao.AddStorageOp(address, slot, op)
+		}
 	}
 
 	return changed
@@ -46,6 +116,8 @@ func (tao *TouchedAddressObject) Merge(other *TouchedAddressObject) bool {
 
 func (tao *TouchedAddressObject) RemoveAccount(address common.Address) {
 	delete(tao.accountOp, address)
+	delete(tao.storageOp, address)
+	delete(tao.storageOnly, address)
 }
 
 func (tao *TouchedAddressObject) AddAccountInArg(address common.Address) {
@@ -54,16 +126,30 @@ func (tao *TouchedAddressObject) AddAccountInArg(address common.Address) {
 
 func (tao *TouchedAddressObject) RemoveAccountsInArgs() {
 	for _, address := range tao.accountInArg {
-		delete(tao.accountOp, address)
+		tao.RemoveAccount(address)
 	}
 }
 
+// RemoveStorage drops the storage slots touched, the accounts written in the
+// storage only are kept.
+func (tao *TouchedAddressObject) RemoveStorage() {
+	tao.storageOp = make(map[common.Address]map[common.Hash]bool)
+}
+
 func (tao *TouchedAddressObject) Copy() *TouchedAddressObject {
 	touchedAddress := NewTouchedAddressObject()
 
 	for address, op := range tao.accountOp {
 		touchedAddress.AddAccountOp(address, op)
 	}
+	for address, slots := range tao.storageOp {
+		for slot, op := range slots {
+			touchedAddress.AddStorageOp(address, slot, op)
+		}
+	}
+	for address := range tao.storageOnly {
+		touchedAddress.storageOnly[address] = struct{}{}
+	}
 
 	touchedAddress.accountInArg = tao.accountInArg
 
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"fmt"
 	"math"
 	"time"
 	"truechain/discovery/common"
@@ -36,8 +37,25 @@ import (
 var (
 	blockExecutionTxTimer = metrics.NewRegisteredTimer("chain/state/executiontx", nil)
 	blockFinalizeTimer    = metrics.NewRegisteredTimer("chain/state/finalize", nil)
+	shadowMismatchMeter   = metrics.NewRegisteredMeter("chain/state/shadow/mismatch", nil)
 )
 
+// BlockExecution is the outcome of the transactions of a block executed in
+// an execution mode, before the block is finalized.
+type BlockExecution struct {
+	Mode      vm.ExecutionMode
+	Receipts  types.Receipts
+	Logs      []*types.Log
+	UsedGas   uint64
+	FeeAmount *big.Int
+	Root      common.Hash // the intermediate state root, only computed to cross check the modes
+	Elapsed   time.Duration
+
+	Groups    int // the execution groups of the parallel execution
+	Regroups  int // the times the conflicting groups are regrouped
+	Conflicts int // the transactions conflicting with another group
+}
+
 // StateProcessor is a basic Processor, which takes care of transitioning
 // state from one point to another.
 //
@@ -62,23 +80,19 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // transactions failed to execute due to insufficient gas it will return an error.
 func (fp *StateProcessor) Process(block *types.Block, statedb *state.StateDB,
 	cfg vm.Config) (types.Receipts, []*types.Log, uint64, *types.ChainReward, error) {
-	var (
-		feeAmount = big.NewInt(0)
-		header    = block.Header()
-	)
-
+	header := block.Header()
 	start := time.Now()
 
-	parallelBlock := NewParallelBlock(block, statedb, fp.config, fp.bc, cfg, feeAmount)
-	receipts, allLogs, usedGas, err := parallelBlock.Process()
+	res, err := fp.Execute(block, statedb, cfg)
 	if err != nil {
 		return nil, nil, 0, nil, err
 	}
+	receipts, allLogs, usedGas := res.Receipts, res.Logs, res.UsedGas
 
 	t1 := time.Now()
 
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	_, infos, err := fp.engine.Finalize(fp.bc, header, statedb, block.Transactions(), receipts, feeAmount, false)
+	_, infos, err := fp.engine.Finalize(fp.bc, header, statedb, block.Transactions(), receipts, block.Evidences(), res.FeeAmount, false)
 	if err != nil {
 		return nil, nil, 0, nil, err
 	}
@@ -92,6 +106,146 @@ func (fp *StateProcessor) Process(block *types.Block, statedb *state.StateDB,
 	return receipts, allLogs, usedGas, infos, nil
 }
 
+// Execute applies the transactions of the block to the state in the execution
+// mode of the config, the block isn't finalized. In the shadow mode the block
+// is executed sequentially on a copy of the state too, the differences are
+// logged and the parallel result is returned.
+func (fp *StateProcessor) Execute(block *types.Block, statedb *state.StateDB, cfg vm.Config) (*BlockExecution, error) {
+	switch cfg.ExecutionMode {
+	case vm.SequentialExecution:
+		return fp.executeSequential(block, statedb, cfg)
+	case vm.ShadowExecution:
+		var (
+			shadow = statedb.Copy()
+			sres   *BlockExecution
+			serr   error
+			done   = make(chan struct{})
+		)
+		go func() {
+			defer close(done)
+			if sres, serr = fp.executeSequential(block, shadow, cfg); serr == nil {
+				sres.Root = shadow.IntermediateRoot(true)
+			}
+		}()
+		res, err := fp.executeParallel(block, statedb, cfg)
+		if err == nil {
+			res.Root = statedb.Copy().IntermediateRoot(true)
+		}
+		<-done
+
+		var diffs []string
+		if err != nil || serr != nil {
+			if (err == nil) != (serr == nil) {
+				diffs = append(diffs, fmt.Sprintf("error %v != %v", err, serr))
+			}
+		} else {
+			diffs = DiffExecutions(res, sres)
+		}
+		if len(diffs) > 0 {
+			shadowMismatchMeter.Mark(1)
+			log.Error("Parallel execution mismatch", "number", block.NumberU64(), "hash", block.Hash(), "diffs", len(diffs))
+			for _, diff := range diffs {
+				log.Error("Parallel execution mismatch", "number", block.NumberU64(), "diff", diff)
+			}
+		}
+		return res, err
+	default:
+		return fp.executeParallel(block, statedb, cfg)
+	}
+}
+
+func (fp *StateProcessor) executeParallel(block *types.Block, statedb *state.StateDB, cfg vm.Config) (*BlockExecution, error) {
+	start := time.Now()
+	feeAmount := big.NewInt(0)
+
+	parallelBlock := NewParallelBlock(block, statedb, fp.config, fp.bc, cfg, feeAmount)
+	receipts, allLogs, usedGas, err := parallelBlock.Process()
+	if err != nil {
+		return nil, err
+	}
+	return &BlockExecution{
+		Mode:      vm.ParallelExecution,
+		Receipts:  receipts,
+		Logs:      allLogs,
+		UsedGas:   usedGas,
+		FeeAmount: feeAmount,
+		Elapsed:   time.Since(start),
+		Groups:    parallelBlock.groups,
+		Regroups:  parallelBlock.regroups,
+		Conflicts: parallelBlock.conflicts,
+	}, nil
+}
+
+// executeSequential applies the transactions one by one, the same way as the
+// proposer does.
+func (fp *StateProcessor) executeSequential(block *types.Block, statedb *state.StateDB, cfg vm.Config) (*BlockExecution, error) {
+	var (
+		start     = time.Now()
+		header    = block.Header()
+		gp        = new(GasPool).AddGas(block.GasLimit())
+		feeAmount = big.NewInt(0)
+		usedGas   = uint64(0)
+		receipts  types.Receipts
+		allLogs   []*types.Log
+	)
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		receipt, err := ApplyTransaction(fp.config, fp.bc, gp, statedb, header, tx, &usedGas, feeAmount, cfg)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+	return &BlockExecution{
+		Mode:      vm.SequentialExecution,
+		Receipts:  receipts,
+		Logs:      allLogs,
+		UsedGas:   usedGas,
+		FeeAmount: feeAmount,
+		Elapsed:   time.Since(start),
+	}, nil
+}
+
+// DiffExecutions compares the results of two executions of a block, it returns
+// the differences found in the gas, the fee, the receipts and the state roots.
+func DiffExecutions(a, b *BlockExecution) []string {
+	var diffs []string
+	if a.UsedGas != b.UsedGas {
+		diffs = append(diffs, fmt.Sprintf("used gas %d != %d", a.UsedGas, b.UsedGas))
+	}
+	if a.FeeAmount.Cmp(b.FeeAmount) != 0 {
+		diffs = append(diffs, fmt.Sprintf("fee %v != %v", a.FeeAmount, b.FeeAmount))
+	}
+	if a.Root != b.Root {
+		diffs = append(diffs, fmt.Sprintf("state root %x != %x", a.Root, b.Root))
+	}
+	if len(a.Receipts) != len(b.Receipts) {
+		return append(diffs, fmt.Sprintf("receipts %d != %d", len(a.Receipts), len(b.Receipts)))
+	}
+	for i, ra := range a.Receipts {
+		rb := b.Receipts[i]
+		switch {
+		case ra.Status != rb.Status:
+			diffs = append(diffs, fmt.Sprintf("receipt %d status %d != %d", i, ra.Status, rb.Status))
+		case ra.GasUsed != rb.GasUsed:
+			diffs = append(diffs, fmt.Sprintf("receipt %d gas used %d != %d", i, ra.GasUsed, rb.GasUsed))
+		case ra.CumulativeGasUsed != rb.CumulativeGasUsed:
+			diffs = append(diffs, fmt.Sprintf("receipt %d cumulative gas used %d != %d", i, ra.CumulativeGasUsed, rb.CumulativeGasUsed))
+		case len(ra.Logs) != len(rb.Logs):
+			diffs = append(diffs, fmt.Sprintf("receipt %d logs %d != %d", i, len(ra.Logs), len(rb.Logs)))
+		case ra.Bloom != rb.Bloom:
+			diffs = append(diffs, fmt.Sprintf("receipt %d bloom mismatch", i))
+		case ra.ContractAddress != rb.ContractAddress:
+			diffs = append(diffs, fmt.Sprintf("receipt %d contract address %x != %x", i, ra.ContractAddress, rb.ContractAddress))
+		}
+	}
+	if ha, hb := types.DeriveSha(a.Receipts), types.DeriveSha(b.Receipts); ha != hb {
+		diffs = append(diffs, fmt.Sprintf("receipts root %x != %x", ha, hb))
+	}
+	return diffs
+}
+
 // ApplyTransaction attempts to apply a transaction to the given state database
 // and uses the input parameters for its environment. It returns the receipt
 // for the transaction, gas used and an error if the transaction failed,
@@ -131,6 +285,7 @@ func ApplyTransaction(config *params.ChainConfig, bc ChainContext, gp *GasPool,
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing wether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, result.Failed(), *usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = result.UsedGas
 	// if the transaction created a contract, store the creation address in the receipt.
@@ -156,7 +311,7 @@ func ReadTransaction(config *params.ChainConfig, bc ChainContext,
 
 	msg, err := tx.AsMessage(types.MakeSigner(config, header.Number))
 
-	msgCopy := types.NewMessage(msg.From(), msg.To(), msg.Payment(), 0, msg.Value(), msg.Fee(), msg.Gas(), msg.GasPrice(), msg.Data(), false)
+	msgCopy := types.NewMessage(msg.From(), msg.To(), msg.Payment(), 0, msg.Value(), msg.Fee(), msg.Gas(), msg.GasPrice(), msg.Data(), msg.AccessList(), false)
 
 	if err != nil {
 		return nil, 0, err
@@ -211,6 +366,7 @@ func ApplyTransactionMsg(config *params.ChainConfig, bc ChainContext, gp *GasPoo
 	// Create a new receipt for the transaction, storing the intermediate root and gas used by the tx
 	// based on the eip phase, we're passing wether the root touch-delete accounts.
 	receipt := types.NewReceipt(root, result.Failed(), *usedGas)
+	receipt.Type = tx.Type()
 	receipt.TxHash = tx.Hash()
 	receipt.GasUsed = result.UsedGas
 	// if the transaction created a contract, store the creation address in the receipt.
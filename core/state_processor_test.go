@@ -0,0 +1,73 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
+)
+
+// Tests that the execution modes produce the same state and receipts, and the
+// shadow mode hands out the parallel result.
+func TestExecutionModes(t *testing.T) {
+	processor, db, genesis, block := newTokenTransferBlock(16)
+	want, wantRoot := executeTokenTransfers(t, processor, db, genesis, block, vm.Config{ExecutionMode: vm.SequentialExecution})
+
+	tests := []struct {
+		mode     vm.ExecutionMode
+		wantMode vm.ExecutionMode
+	}{
+		{vm.SequentialExecution, vm.SequentialExecution},
+		{vm.ParallelExecution, vm.ParallelExecution},
+		{vm.ShadowExecution, vm.ParallelExecution},
+	}
+	for _, tt := range tests {
+		res, root := executeTokenTransfers(t, processor, db, genesis, block, vm.Config{ExecutionMode: tt.mode})
+		if res.Mode != tt.wantMode {
+			t.Errorf("%v: result mode mismatch: have %v, want %v", tt.mode, res.Mode, tt.wantMode)
+		}
+		if root != wantRoot {
+			t.Errorf("%v: root mismatch: have %x, want %x", tt.mode, root, wantRoot)
+		}
+		res.Root, want.Root = common.Hash{}, common.Hash{}
+		if diffs := DiffExecutions(res, want); len(diffs) != 0 {
+			t.Errorf("%v: execution differs from the sequential one: %v", tt.mode, diffs)
+		}
+	}
+}
+
+func TestDiffExecutions(t *testing.T) {
+	newExecution := func() *BlockExecution {
+		return &BlockExecution{
+			Receipts: types.Receipts{
+				{Status: types.ReceiptStatusSuccessful, GasUsed: 21000, CumulativeGasUsed: 21000},
+				{Status: types.ReceiptStatusSuccessful, GasUsed: 30000, CumulativeGasUsed: 51000},
+			},
+			UsedGas:   51000,
+			FeeAmount: big.NewInt(51000),
+		}
+	}
+	tests := []struct {
+		name   string
+		mutate func(e *BlockExecution)
+		diffs  int
+	}{
+		{"same", func(e *BlockExecution) {}, 0},
+		{"used gas", func(e *BlockExecution) { e.UsedGas++ }, 1},
+		{"fee", func(e *BlockExecution) { e.FeeAmount = big.NewInt(1) }, 1},
+		{"root", func(e *BlockExecution) { e.Root = common.HexToHash("0x01") }, 1},
+		{"status", func(e *BlockExecution) { e.Receipts[1].Status = types.ReceiptStatusFailed }, 2},
+		{"receipt gas", func(e *BlockExecution) { e.Receipts[0].GasUsed = 1 }, 1},
+		{"cumulative gas", func(e *BlockExecution) { e.Receipts[1].CumulativeGasUsed = 1 }, 2},
+		{"receipts", func(e *BlockExecution) { e.Receipts = e.Receipts[:1] }, 1},
+	}
+	for _, tt := range tests {
+		a, b := newExecution(), newExecution()
+		tt.mutate(b)
+		if diffs := DiffExecutions(a, b); len(diffs) != tt.diffs {
+			t.Errorf("%s: diffs mismatch: have %v, want %d", tt.name, diffs, tt.diffs)
+		}
+	}
+}
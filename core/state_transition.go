@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"truechain/discovery/common"
+	"truechain/discovery/core/types"
 	"truechain/discovery/core/vm"
 	"truechain/discovery/params"
 )
@@ -75,6 +76,8 @@ type Message interface {
 	Nonce() uint64
 	CheckNonce() bool
 	Data() []byte
+	AccessList() types.AccessList
+	SponsorPolicy() *types.SponsorPolicy
 }
 
 // ExecutionResult includes all output after executing given evm
@@ -112,8 +115,9 @@ func (result *ExecutionResult) Revert() []byte {
 	return common.CopyBytes(result.ReturnData)
 }
 
-// IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error) {
+// IntrinsicGas computes the 'intrinsic gas' for a message with the given data
+// and access list.
+func IntrinsicGas(data []byte, accessList types.AccessList, contractCreation, homestead bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if contractCreation && homestead {
@@ -142,6 +146,10 @@ func IntrinsicGas(data []byte, contractCreation, homestead bool) (uint64, error)
 		}
 		gas += z * params.TxDataZeroGas
 	}
+	if accessList != nil {
+		gas += uint64(len(accessList)) * params.TxAccessListAddressGas
+		gas += uint64(accessList.StorageKeys()) * params.TxAccessListStorageKeyGas
+	}
 	return gas, nil
 }
 
@@ -203,6 +211,11 @@ func (st *StateTransition) buyGas() error {
 
 func (st *StateTransition) buyGasForPayment() error {
 	mgval := new(big.Int).Mul(new(big.Int).SetUint64(st.msg.Gas()), st.gasPrice)
+	if policy := st.msg.SponsorPolicy(); policy != nil {
+		if err := checkSponsorPolicy(st.state, policy, st.msg.Payment(), st.msg.From(), st.msg.To(), st.gasPrice, mgval, st.evm.Context.BlockNumber); err != nil {
+			return err
+		}
+	}
 	if st.state.GetBalance(st.msg.Payment()).Cmp(mgval) < 0 {
 		return errInsufficientBalanceForPayerForGas
 	}
@@ -245,13 +258,18 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	contractCreation := msg.To() == nil
 
 	// Pay intrinsic gas
-	gas, err := IntrinsicGas(st.data, contractCreation, true)
+	gas, err := IntrinsicGas(st.data, msg.AccessList(), contractCreation, true)
 	if err != nil {
 		return nil, err
 	}
 	if err = st.useGas(gas); err != nil {
 		return nil, ErrIntrinsicGas
 	}
+	// The sender, the recipient and the precompiles are warm from the start of
+	// the transaction on the TIP13 access list gas
+	if rules := st.evm.ChainConfig().Rules(st.evm.Context.BlockNumber); rules.IsTIP13 {
+		st.state.PrepareAccessList(msg.From(), msg.To(), vm.ActivePrecompiles(rules), msg.AccessList())
+	}
 
 	var (
 		ret   []byte
@@ -266,6 +284,10 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	}
 
 	st.refundGas()
+	if policy := msg.SponsorPolicy(); policy != nil && msg.Payment() != params.EmptyAddress {
+		fee := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice)
+		st.state.AddSponsorSpend(msg.Payment(), policy.Hash(), fee)
+	}
 
 	return &ExecutionResult{
 		UsedGas:    st.gasUsed(),
@@ -294,6 +316,16 @@ func (st *StateTransition) refundGas() {
 	st.gp.AddGas(st.gas)
 }
 
+// checkSponsorPolicy returns an error if the sponsor policy of the payer doesn't
+// sponsor the gas fee of a transaction from the sender to the recipient in the
+// block of the number, including if it's above the total fee left.
+func checkSponsorPolicy(statedb vm.StateDB, policy *types.SponsorPolicy, payer, from common.Address, to *common.Address, gasPrice, gasFee, number *big.Int) error {
+	if err := policy.Check(from, to, gasPrice, number); err != nil {
+		return err
+	}
+	return policy.CheckFee(statedb.GetSponsorSpend(payer, policy.Hash()), gasFee)
+}
+
 // gasUsed returns the amount of gas used up by the state transition.
 func (st *StateTransition) gasUsed() uint64 {
 	return st.initialGas - st.gas
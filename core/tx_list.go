@@ -507,9 +507,35 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
 }
 
+// Cheapest retrieves the cheapest remote transaction accepted by the filter,
+// leaving it in the priced list.
+func (l *txPricedList) Cheapest(local *accountSet, filter func(*types.Transaction) bool) *types.Transaction {
+	save := make(types.Transactions, 0, 64) // Cheaper transactions not accepted
+
+	var cheapest *types.Transaction
+	for len(*l.items) > 0 {
+		// Discard stale transactions if found during the search
+		tx := heap.Pop(l.items).(*types.Transaction)
+		if l.all.Get(tx.Hash()) == nil {
+			l.stales--
+			continue
+		}
+		save = append(save, tx)
+		if !local.containsTx(tx) && filter(tx) {
+			cheapest = tx
+			break
+		}
+	}
+	for _, tx := range save {
+		heap.Push(l.items, tx)
+	}
+	return cheapest
+}
+
 // Discard finds a number of most underpriced transactions, removes them from the
-// priced list and returns them for further removal from the entire pool.
-func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions {
+// priced list and returns them for further removal from the entire pool. The
+// local transactions and the ones in a reserved lane are kept.
+func (l *txPricedList) Discard(count int, local *accountSet, reserved func(*types.Transaction) bool) types.Transactions {
 	drop := make(types.Transactions, 0, count) // Remote underpriced transactions to drop
 	save := make(types.Transactions, 0, 64)    // Local underpriced transactions to keep
 
@@ -520,8 +546,8 @@ func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions
 			l.stales--
 			continue
 		}
-		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
+		// Non stale transaction found, discard unless local or reserved
+		if local.containsTx(tx) || reserved(tx) {
 			save = append(save, tx)
 		} else {
 			drop = append(drop, tx)
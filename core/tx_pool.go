@@ -91,6 +91,14 @@ var (
 
 	ErrNegativeFee = errors.New("negative fee")
 
+	// ErrSenderQuotaExceeded is returned if a new transaction arrives from an
+	// account already holding all the slots permitted per account.
+	ErrSenderQuotaExceeded = errors.New("sender slot quota exceeded")
+
+	// ErrTxTypeNotSupported is returned if a transaction is of a type not
+	// accepted yet, the typed transactions before the TIP13 upgrade.
+	ErrTxTypeNotSupported = types.ErrTxTypeNotSupported
+
 	// ErrOversizedData is returned if the input data of a transaction is greater
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
@@ -119,6 +127,13 @@ var (
 	// General tx metrics
 	invalidTxCounter     = metrics.NewRegisteredCounter("txpool/invalid", nil)
 	underpricedTxCounter = metrics.NewRegisteredCounter("txpool/underpriced", nil)
+	quotaTxCounter       = metrics.NewRegisteredCounter("txpool/quota", nil) // Rejected due to the sender slot quota
+
+	// Metrics for the transaction lanes
+	stakingGauge           = metrics.NewRegisteredGauge("txpool/lane/staking", nil)
+	stakingEvictCounter    = metrics.NewRegisteredCounter("txpool/lane/staking/evict", nil)    // Evicted by a pricier call in a full lane
+	stakingOverflowCounter = metrics.NewRegisteredCounter("txpool/lane/staking/overflow", nil) // Priced in the normal lane due to a full lane
+	normalGauge            = metrics.NewRegisteredGauge("txpool/lane/normal", nil)
 
 	// Metrics for the send to handler
 	promotedSend = metrics.NewRegisteredCounter("txpool/send/promoted", nil)
@@ -160,6 +175,8 @@ type TxPoolConfig struct {
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
+	SenderSlots  uint64 // Maximum number of transaction slots, executable or not, permitted per account
+	StakingSlots uint64 // Number of slots reserved for calls to the staking contract, no lane if zero
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
 }
@@ -177,6 +194,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalSlots:  4096 * 5,
 	AccountQueue: 64 * 5,
 	GlobalQueue:  1024 * 5,
+	SenderSlots:  16*5 + 64*5,
+	StakingSlots: 1024,
 
 	Lifetime: 3 * time.Hour,
 }
@@ -213,6 +232,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool global queue", "provided", conf.GlobalQueue, "updated", DefaultTxPoolConfig.GlobalQueue)
 		conf.GlobalQueue = DefaultTxPoolConfig.GlobalQueue
 	}
+	if conf.SenderSlots < 1 {
+		log.Warn("Sanitizing invalid txpool sender slots", "provided", conf.SenderSlots, "updated", DefaultTxPoolConfig.SenderSlots)
+		conf.SenderSlots = DefaultTxPoolConfig.SenderSlots
+	}
 	if conf.Lifetime < 1 {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
@@ -239,6 +262,9 @@ type TxPool struct {
 	signer       types.Signer
 	mu           sync.RWMutex
 
+	tip13         bool     // Fork indicator whether the typed transactions are accepted
+	pendingNumber *big.Int // Number of the block the pending transactions go in
+
 	currentState  *state.StateDB      // Current state in the blockchain head
 	pendingState  *state.ManagedState // Pending state tracking virtual nonces
 	currentMaxGas uint64              // Current gas limit for transaction caps
@@ -269,7 +295,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		config:      config,
 		chainconfig: chainconfig,
 		chain:       chain,
-		signer:      types.NewTIP1Signer(chainconfig.ChainID),
+		signer:      types.NewTIP13Signer(chainconfig.ChainID),
 		pending:     make(map[common.Address]*txList),
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
@@ -361,10 +387,14 @@ func (pool *TxPool) loop() {
 		case <-report.C:
 			pool.mu.RLock()
 			pending, queued := pool.stats()
+			stakings := pool.all.Stakings()
 			stales := pool.priced.stales
 			DiscardCount := remoteTxsDiscardCount
 			pool.mu.RUnlock()
 
+			stakingGauge.Update(int64(stakings))
+			normalGauge.Update(int64(pending + queued - stakings))
+
 			if pending != prevPending || queued != prevQueued || stales != prevStales {
 				prevPending, prevQueued, prevStales, prevDiscardCount = pending, queued, stales, DiscardCount
 				log.Debug("Transaction pool status report", "executable", pending, "queued", queued, "stales", stales, "remoteTxsDiscardCount", prevDiscardCount)
@@ -474,6 +504,11 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	//pool.currentMaxGas = newHead.GasLimit
 	pool.currentMaxGas = pool.chain.CurrentBlock().Header().GasLimit
 
+	// Update all fork indicator by next pending block number.
+	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
+	pool.tip13 = pool.chainconfig.IsTIP13(next)
+	pool.pendingNumber = next
+
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
@@ -568,6 +603,24 @@ func (pool *TxPool) stats() (int, int) {
 	return pending, queued
 }
 
+// inStakingLane checks whether a transaction goes in the staking lane, a call to
+// the staking contract while slots are reserved for them.
+func (pool *TxPool) inStakingLane(tx *types.Transaction) bool {
+	return pool.config.StakingSlots > 0 && isStakingTx(tx)
+}
+
+// reserved retrieves the number of slots taken in the staking lane. These don't
+// count against the global slot limits.
+func (pool *TxPool) reserved() int {
+	if pool.config.StakingSlots == 0 {
+		return 0
+	}
+	if stakings := pool.all.Stakings(); uint64(stakings) < pool.config.StakingSlots {
+		return stakings
+	}
+	return int(pool.config.StakingSlots)
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -599,6 +652,33 @@ func (pool *TxPool) Pending() (map[common.Address]types.Transactions, error) {
 	return pending, nil
 }
 
+// PendingLanes retrieves all currently processable transactions like Pending,
+// split by lane. The staking lane holds the leading calls to the staking contract
+// of each account, the normal one the transactions following them. Committing
+// the staking lane first keeps the nonce order of every account.
+func (pool *TxPool) PendingLanes() (map[common.Address]types.Transactions, map[common.Address]types.Transactions, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	staking := make(map[common.Address]types.Transactions)
+	normal := make(map[common.Address]types.Transactions)
+	for addr, list := range pool.pending {
+		txs := list.Flatten()
+
+		lead := 0
+		for lead < len(txs) && pool.inStakingLane(txs[lead]) {
+			lead++
+		}
+		if lead > 0 {
+			staking[addr] = txs[:lead]
+		}
+		if lead < len(txs) {
+			normal[addr] = txs[lead:]
+		}
+	}
+	return staking, normal, nil
+}
+
 // local retrieves all currently known local transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -618,6 +698,10 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
+	// The typed transactions are only accepted once the TIP13 upgrade is active
+	if !pool.tip13 && tx.Type() != types.LegacyTxType {
+		return ErrTxTypeNotSupported
+	}
 	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
 	if tx.Size() > 32*1024 {
 		return ErrOversizedData
@@ -652,6 +736,12 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		return ErrInvalidPayer
 		//return fmt.Errorf("%v err is:%v", ErrInvalidPayer, err)
 	}
+	// Make sure the payer sponsors the transaction under its policy
+	if policy := tx.SponsorPolicy(); policy != nil && payer != params.EmptyAddress {
+		if err := checkSponsorPolicy(pool.currentState, policy, payer, from, tx.To(), tx.GasPrice(), tx.GasCost(), pool.pendingNumber); err != nil {
+			return err
+		}
+	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
 	if pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
@@ -682,7 +772,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 			//return fmt.Errorf("%v your balance:%d;tx.Cost():%d", ErrInsufficientFunds, pool.currentState.GetBalance(from), tx.Cost())
 		}
 	}
-	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, true)
+	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true)
 	if err != nil {
 		return err
 	}
@@ -714,8 +804,31 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		invalidTxCounter.Inc(1)
 		return false, err
 	}
+	// Keep remote accounts within their slot quota, bar replacing transactions
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	exempt := local || pool.locals.contains(from)
+	if !exempt && !pool.overlaps(from, tx) && uint64(pool.slots(from)) >= pool.config.SenderSlots {
+		log.Trace("Discarding quota-exceeding transaction", "hash", hash, "from", from)
+		quotaTxCounter.Inc(1)
+		return false, ErrSenderQuotaExceeded
+	}
+	// Calls to the staking contract go in their own lane, up to the reserved slots.
+	// A full lane evicts its cheapest remote call for a pricier one, otherwise the
+	// new call is priced in the normal lane.
+	lane := pool.inStakingLane(tx)
+	if lane && !exempt && !pool.overlaps(from, tx) && uint64(pool.all.Stakings()) >= pool.config.StakingSlots {
+		if cheapest := pool.priced.Cheapest(pool.locals, isStakingTx); cheapest != nil && cheapest.GasPrice().Cmp(tx.GasPrice()) < 0 {
+			log.Trace("Evicting underpriced staking transaction", "hash", cheapest.Hash(), "price", cheapest.GasPrice())
+			stakingEvictCounter.Inc(1)
+			pool.removeTx(cheapest.Hash(), false)
+		} else {
+			log.Trace("Pricing staking transaction in the normal lane", "hash", hash)
+			stakingOverflowCounter.Inc(1)
+			lane = false
+		}
+	}
 	// If the transaction pool is full, discard underpriced transactions
-	if !local && uint64(pool.all.Count()) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
+	if !local && !lane && uint64(pool.all.Count()-pool.reserved()) >= pool.config.GlobalSlots+pool.config.GlobalQueue {
 		start := time.Now()
 		// If the new transaction is underpriced, don't accept it
 		if pool.priced.Underpriced(tx, pool.locals) {
@@ -727,7 +840,19 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		log.Trace("deal with Underpriced", "proctime", proctime)
 		// New transaction is better than our worse ones, make room for it
 		start = time.Now()
-		drop := pool.priced.Discard(pool.all.Count()-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals)
+		// The staking calls over the lane slots are priced like the normal ones
+		overflow := pool.all.Stakings() - pool.reserved()
+		reserved := func(tx *types.Transaction) bool {
+			if !pool.inStakingLane(tx) {
+				return false
+			}
+			if overflow > 0 {
+				overflow--
+				return false
+			}
+			return true
+		}
+		drop := pool.priced.Discard(pool.all.Count()-pool.reserved()-int(pool.config.GlobalSlots+pool.config.GlobalQueue-1), pool.locals, reserved)
 		proctime = time.Since(start)
 		log.Trace("deal with Discard", "proctime", proctime)
 		start = time.Now()
@@ -740,7 +865,6 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 		log.Trace("deal with drop", "proctime", proctime, "drop.Len()", drop.Len())
 	}
 	// If the transaction is replacing an already pending one, do directly
-	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
 		inserted, old := list.Add(tx, pool.config.PriceBump)
@@ -780,6 +904,30 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	return replace, nil
 }
 
+// slots retrieves the number of transactions of an account, pending or queued.
+func (pool *TxPool) slots(addr common.Address) int {
+	slots := 0
+	if list := pool.pending[addr]; list != nil {
+		slots += list.Len()
+	}
+	if list := pool.queue[addr]; list != nil {
+		slots += list.Len()
+	}
+	return slots
+}
+
+// overlaps checks whether a transaction of an account with the same nonce is
+// already pending or queued, the transaction would replace it.
+func (pool *TxPool) overlaps(addr common.Address, tx *types.Transaction) bool {
+	if list := pool.pending[addr]; list != nil && list.Overlaps(tx) {
+		return true
+	}
+	if list := pool.queue[addr]; list != nil && list.Overlaps(tx) {
+		return true
+	}
+	return false
+}
+
 // enqueueTx inserts a new transaction into the non-executable transaction queue.
 //
 // Note, this method assumes the pool lock is held!
@@ -1100,7 +1248,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 	for _, list := range pool.pending {
 		pending += uint64(list.Len())
 	}
-	if pending > pool.config.GlobalSlots {
+	limit := pool.config.GlobalSlots + uint64(pool.reserved()) // the staking lane comes on top
+	if pending > limit {
 		pendingBeforeCap := pending
 		// Assemble a spam order to penalize large transactors first
 		spammers := prque.New()
@@ -1112,7 +1261,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		}
 		// Gradually drop transactions from offenders
 		offenders := []common.Address{}
-		for pending > pool.config.GlobalSlots && !spammers.Empty() {
+		for pending > limit && !spammers.Empty() {
 			// Retrieve the next offender if not local address
 			offender, _ := spammers.Pop()
 			offenders = append(offenders, offender.(common.Address))
@@ -1123,7 +1272,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				threshold := pool.pending[offender.(common.Address)].Len()
 
 				// Iteratively reduce all offenders until below limit or threshold reached
-				for pending > pool.config.GlobalSlots && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
+				for pending > limit && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
 					for i := 0; i < len(offenders)-1; i++ {
 						list := pool.pending[offenders[i]]
 						for _, tx := range list.Cap(list.Len() - 1) {
@@ -1144,8 +1293,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			}
 		}
 		// If still above threshold, reduce to limit or min allowance
-		if pending > pool.config.GlobalSlots && len(offenders) > 0 {
-			for pending > pool.config.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.config.AccountSlots {
+		if pending > limit && len(offenders) > 0 {
+			for pending > limit && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.config.AccountSlots {
 				for _, addr := range offenders {
 					list := pool.pending[addr]
 					for _, tx := range list.Cap(list.Len() - 1) {
@@ -1311,8 +1460,9 @@ func (as *accountSet) add(addr common.Address) {
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all  map[common.Hash]*types.Transaction
-	lock sync.RWMutex
+	all      map[common.Hash]*types.Transaction
+	stakings int // Number of transactions calling the staking contract
+	lock     sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
@@ -1350,12 +1500,25 @@ func (t *txLookup) Count() int {
 	return len(t.all)
 }
 
+// Stakings returns the current number of calls to the staking contract in the
+// lookup.
+func (t *txLookup) Stakings() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.stakings
+}
+
 // Add adds a transaction to the lookup.
 func (t *txLookup) Add(tx *types.Transaction) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	t.all[tx.Hash()] = tx
+	hash := tx.Hash()
+	if _, ok := t.all[hash]; !ok && isStakingTx(tx) {
+		t.stakings++
+	}
+	t.all[hash] = tx
 }
 
 // Remove removes a transaction from the lookup.
@@ -1363,5 +1526,13 @@ func (t *txLookup) Remove(hash common.Hash) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
+	if tx, ok := t.all[hash]; ok && isStakingTx(tx) {
+		t.stakings--
+	}
 	delete(t.all, hash)
 }
+
+// isStakingTx checks whether a transaction calls the staking contract.
+func isStakingTx(tx *types.Transaction) bool {
+	return tx.To() != nil && *tx.To() == types.StakingAddress
+}
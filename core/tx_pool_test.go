@@ -83,6 +83,16 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 	return tx
 }
 
+func chainTransaction(nonce uint64, to common.Address, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	rawTx := types.NewTransaction(nonce, to, big.NewInt(100), 100000, gasprice, nil)
+	tx, _ := types.SignTx(rawTx, types.NewTIP1Signer(params.TestChainConfig.ChainID), key)
+	return tx
+}
+
+func stakingTransaction(nonce uint64, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	return chainTransaction(nonce, types.StakingAddress, gasprice, key)
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(etruedb.NewMemDatabase()))
 	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
@@ -333,6 +343,100 @@ func TestTransactionNegativeValue(t *testing.T) {
 	}
 }
 
+// Tests that the typed transactions are rejected until the TIP13 upgrade is
+// active, and accepted after it.
+func TestTransactionTypedAfterTIP13(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.Address{0x01}
+
+	for _, config := range []*params.ChainConfig{params.TestChainConfig, params.SingleNodeChainConfig} {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(etruedb.NewMemDatabase()))
+		blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+		pool := NewTxPool(testTxPoolConfig, config, blockchain)
+
+		tx, _ := types.SignTx(types.NewTx(&types.AccessListTx{
+			ChainID:    config.ChainID,
+			Gas:        100000,
+			GasPrice:   big.NewInt(1000000),
+			To:         &to,
+			Value:      big.NewInt(100),
+			AccessList: types.AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}},
+		}), types.NewTIP13Signer(config.ChainID), key)
+		pool.currentState.AddBalance(from, big.NewInt(1000000000000))
+
+		err := pool.AddRemote(tx)
+		if config.IsTIP13(big.NewInt(1)) {
+			if err != nil {
+				t.Errorf("typed transaction rejected after TIP13: %v", err)
+			}
+		} else if err != ErrTxTypeNotSupported {
+			t.Errorf("expected %v before TIP13, got %v", ErrTxTypeNotSupported, err)
+		}
+		pool.Stop()
+	}
+}
+
+// Tests that the payment transactions are rejected if the sponsor policy of the
+// payer doesn't cover them.
+func TestTransactionSponsorPolicy(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	payerKey, _ := crypto.GenerateKey()
+	from, payer := crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(payerKey.PublicKey)
+	to := common.Address{0x01}
+
+	config := params.SingleNodeChainConfig
+	signer := types.NewTIP13Signer(config.ChainID)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(etruedb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+	pool := NewTxPool(testTxPoolConfig, config, blockchain)
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(from, big.NewInt(1000000000000))
+	pool.currentState.AddBalance(payer, big.NewInt(1000000000000))
+
+	sponsored := func(nonce uint64, gasPrice int64, policy *types.SponsorPolicy) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTx(&types.PaymentTx{
+			ChainID:  config.ChainID,
+			Nonce:    nonce,
+			Gas:      21000,
+			GasPrice: big.NewInt(gasPrice),
+			To:       &to,
+			Value:    big.NewInt(100),
+			Payer:    payer,
+		}), signer, key)
+		tx, _ = tx.WithSponsorPolicy(policy)
+		tx, _ = types.SignTx_Payment(tx, signer, payerKey)
+		return tx
+	}
+	policy := &types.SponsorPolicy{
+		MaxGasPrice: big.NewInt(2000000),
+		MaxTotalFee: big.NewInt(21000 * 3000000),
+		Recipients:  []common.Address{to},
+	}
+	if err := pool.AddRemote(sponsored(0, 3000000, policy)); err != types.ErrSponsorGasPriceTooHigh {
+		t.Errorf("expected %v, got %v", types.ErrSponsorGasPriceTooHigh, err)
+	}
+	policy.MaxTotalFee = big.NewInt(21000*1000000 - 1)
+	if err := pool.AddRemote(sponsored(0, 1000000, policy)); err != types.ErrSponsorFeeCapExceeded {
+		t.Errorf("expected %v, got %v", types.ErrSponsorFeeCapExceeded, err)
+	}
+	policy.MaxTotalFee = nil
+	policy.Recipients = []common.Address{{0x02}}
+	if err := pool.AddRemote(sponsored(0, 1000000, policy)); err != types.ErrSponsorRecipientNotAllowed {
+		t.Errorf("expected %v, got %v", types.ErrSponsorRecipientNotAllowed, err)
+	}
+	policy.Recipients = nil
+	policy.Expiry = 1
+	if err := pool.AddRemote(sponsored(0, 1000000, policy)); err != nil {
+		t.Errorf("sponsored transaction rejected: %v", err)
+	}
+}
+
 func TestTransactionChainFork(t *testing.T) {
 	t.Parallel()
 
@@ -1527,6 +1631,193 @@ func TestTransactionPoolStableUnderpricing(t *testing.T) {
 	}
 }
 
+// Tests that the calls to the staking contract go in their reserved lane, which
+// a full pool doesn't keep them out of and the underpriced discards leave alone.
+func TestTransactionStakingLane(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the lane enforcement with
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(etruedb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+	config.StakingSlots = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(10000000000000))
+	}
+	// Fill up the pool with normal transactions
+	for i := 0; i < 2; i++ {
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			if err := pool.AddRemote(chainTransaction(nonce, common.Address{}, big.NewInt(2000000), keys[i])); err != nil {
+				t.Fatalf("failed to add transaction %d of account %d: %v", nonce, i, err)
+			}
+		}
+	}
+	// Ensure a cheap normal transaction is rejected but the staking calls get in
+	if err := pool.AddRemote(chainTransaction(0, common.Address{}, big.NewInt(1000000), keys[2])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	staking := types.Transactions{stakingTransaction(0, big.NewInt(1000000), keys[2]), stakingTransaction(1, big.NewInt(1000000), keys[2])}
+	for i, tx := range staking {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add staking transaction %d: %v", i, err)
+		}
+	}
+	// Ensure a staking call over the full lane is priced in the normal one
+	if err := pool.AddRemote(stakingTransaction(2, big.NewInt(1000000), keys[2])); err != ErrUnderpriced {
+		t.Fatalf("adding staking transaction over the lane error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Ensure pricier normal transactions don't push the staking calls out
+	if err := pool.AddRemote(chainTransaction(2, common.Address{}, big.NewInt(3000000), keys[1])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	for i, tx := range staking {
+		if pool.Get(tx.Hash()) == nil {
+			t.Errorf("staking transaction %d dropped", i)
+		}
+	}
+	if count := pool.all.Count(); count != 6 {
+		t.Fatalf("transaction count mismatch: have %d, want %d", count, 6)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Ensure the pending staking calls are served in their own lane
+	stakings, pending, _ := pool.PendingLanes()
+	if txs := stakings[crypto.PubkeyToAddress(keys[2].PublicKey)]; len(txs) != len(staking) {
+		t.Fatalf("staking lane transaction count mismatch: have %d, want %d", len(txs), len(staking))
+	}
+	if len(stakings) != 1 || len(pending) != 2 {
+		t.Fatalf("lane account count mismatch: have %d/%d, want %d/%d", len(stakings), len(pending), 1, 2)
+	}
+}
+
+// Tests that a full staking lane evicts its cheapest call for a pricier one, and
+// that the calls over the lane are priced along the normal transactions.
+func TestTransactionStakingLaneEviction(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the lane eviction with
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(etruedb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+	config.StakingSlots = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 6)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(10000000000000))
+	}
+	// Fill up the lane with cheap staking calls
+	cheap := types.Transactions{stakingTransaction(0, big.NewInt(1000000), keys[0]), stakingTransaction(0, big.NewInt(2000000), keys[1])}
+	for i, tx := range cheap {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add staking transaction %d: %v", i, err)
+		}
+	}
+	// Ensure a pricier staking call evicts the cheapest one
+	pricy := stakingTransaction(0, big.NewInt(3000000), keys[2])
+	if err := pool.AddRemote(pricy); err != nil {
+		t.Fatalf("failed to add pricier staking transaction: %v", err)
+	}
+	if pool.Get(cheap[0].Hash()) != nil {
+		t.Errorf("cheapest staking transaction not evicted")
+	}
+	if pool.Get(cheap[1].Hash()) == nil || pool.Get(pricy.Hash()) == nil {
+		t.Errorf("staking transactions in the lane dropped")
+	}
+	// Ensure a cheaper staking call goes over the lane, priced as a normal one
+	over := stakingTransaction(0, big.NewInt(1000000), keys[3])
+	if err := pool.AddRemote(over); err != nil {
+		t.Fatalf("failed to add staking transaction over the lane: %v", err)
+	}
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := pool.AddRemote(chainTransaction(nonce, common.Address{}, big.NewInt(5000000), keys[4])); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	if count := pool.all.Count(); count != 6 {
+		t.Fatalf("transaction count mismatch: have %d, want %d", count, 6)
+	}
+	// Ensure a pricier normal transaction pushes out the call over the lane only
+	if err := pool.AddRemote(chainTransaction(0, common.Address{}, big.NewInt(6000000), keys[5])); err != nil {
+		t.Fatalf("failed to add well priced transaction: %v", err)
+	}
+	if pool.Get(over.Hash()) != nil {
+		t.Errorf("staking transaction over the lane not discarded")
+	}
+	if pool.Get(cheap[1].Hash()) == nil || pool.Get(pricy.Hash()) == nil {
+		t.Errorf("staking transactions in the lane discarded")
+	}
+	if count := pool.all.Count(); count != 6 {
+		t.Fatalf("transaction count mismatch: have %d, want %d", count, 6)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that a remote account can't hold more transactions than its slot quota,
+// unless replacing one of them, whereas local accounts are exempt.
+func TestTransactionSenderQuota(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the quota enforcement with
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(etruedb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.SenderSlots = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	remote, _ := crypto.GenerateKey()
+	local, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(10000000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(10000000000000))
+
+	// Fill the quota of the remote account with a pending and a queued transaction
+	if err := pool.AddRemote(chainTransaction(0, common.Address{}, big.NewInt(1000000), remote)); err != nil {
+		t.Fatalf("failed to add pending transaction: %v", err)
+	}
+	if err := pool.AddRemote(chainTransaction(2, common.Address{}, big.NewInt(1000000), remote)); err != nil {
+		t.Fatalf("failed to add queued transaction: %v", err)
+	}
+	if err := pool.AddRemote(chainTransaction(1, common.Address{}, big.NewInt(1000000), remote)); err != ErrSenderQuotaExceeded {
+		t.Fatalf("adding quota-exceeding transaction error mismatch: have %v, want %v", err, ErrSenderQuotaExceeded)
+	}
+	// Ensure replacements are still accepted
+	if err := pool.AddRemote(chainTransaction(2, common.Address{}, big.NewInt(2000000), remote)); err != nil {
+		t.Fatalf("failed to replace queued transaction: %v", err)
+	}
+	// Ensure local accounts go around the quota
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		if err := pool.AddLocal(chainTransaction(nonce, common.Address{}, big.NewInt(1000000), local)); err != nil {
+			t.Fatalf("failed to add local transaction %d: %v", nonce, err)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that the pool rejects replacement transactions that don't meet the minimum
 // price bump required.
 func TestTransactionReplacement(t *testing.T) {
@@ -163,6 +163,11 @@ type Header struct {
 	GasUsed       uint64         `json:"gasUsed"          gencodec:"required"`
 	Time          *big.Int       `json:"timestamp"        gencodec:"required"`
 	Extra         []byte         `json:"extraData"        gencodec:"required"`
+
+	// EvidenceHash holds the root of the double sign evidences of the block.
+	// It is empty unless the block carries evidences, so the rlp encoding and
+	// the hash of the headers without evidences are unchanged.
+	EvidenceHash []common.Hash `json:"evidenceRoot,omitempty" rlp:"tail"`
 }
 
 // field type overrides for gencodec
@@ -182,6 +187,15 @@ func (h *Header) Hash() common.Hash {
 	return rlpHash(h)
 }
 
+// EvidenceRoot returns the root of the double sign evidences committed by
+// the header, EmptyRootHash if there are none.
+func (h *Header) EvidenceRoot() common.Hash {
+	if len(h.EvidenceHash) == 0 {
+		return EmptyRootHash
+	}
+	return h.EvidenceHash[0]
+}
+
 // Size returns the approximate memory used by all internal contents. It is used
 // to approximate and limit the memory consumption of various caches.
 func (h *Header) Size() common.StorageSize {
@@ -196,12 +210,23 @@ func rlpHash(x interface{}) (h common.Hash) {
 	return h
 }
 
+// prefixedRlpHash writes the prefix into the hasher before rlp-encoding x.
+// It's used for typed transactions.
+func prefixedRlpHash(prefix byte, x interface{}) (h common.Hash) {
+	hw := sha3.NewLegacyKeccak256()
+	hw.Write([]byte{prefix})
+	rlp.Encode(hw, x)
+	hw.Sum(h[:0])
+	return h
+}
+
 // Body is a simple (mutable, non-safe) data container for storing and moving
 // a block's data contents (transactions and uncles) together.
 type Body struct {
 	Transactions []*Transaction
 	Signs        []*PbftSign
 	Infos        []*CommitteeMember
+	Evidences    []*DuplicateVoteEvidence `rlp:"tail"`
 }
 
 // BlockReward
@@ -217,8 +242,9 @@ type Block struct {
 	header       *Header
 	transactions Transactions
 
-	signs PbftSigns
-	infos CommitteeMembers
+	signs     PbftSigns
+	infos     CommitteeMembers
+	evidences DuplicateVoteEvidences
 	// caches
 	hash atomic.Value
 	size atomic.Value
@@ -322,15 +348,20 @@ func CopyHeader(h *Header) *Header {
 		cpy.Extra = make([]byte, len(h.Extra))
 		copy(cpy.Extra, h.Extra)
 	}
+	if len(h.EvidenceHash) > 0 {
+		cpy.EvidenceHash = make([]common.Hash, len(h.EvidenceHash))
+		copy(cpy.EvidenceHash, h.EvidenceHash)
+	}
 	return &cpy
 }
 
 // "external" block encoding. used for etrue protocol, etc.
 type extblock struct {
-	Header *Header
-	Txs    []*Transaction
-	Signs  []*PbftSign
-	Infos  []*CommitteeMember
+	Header    *Header
+	Txs       []*Transaction
+	Signs     []*PbftSign
+	Infos     []*CommitteeMember
+	Evidences []*DuplicateVoteEvidence `rlp:"tail"`
 }
 
 // DecodeRLP decodes the truechain
@@ -340,7 +371,7 @@ func (b *Block) DecodeRLP(s *rlp.Stream) error {
 	if err := s.Decode(&eb); err != nil {
 		return err
 	}
-	b.header, b.transactions, b.signs, b.infos = eb.Header, eb.Txs, eb.Signs, eb.Infos
+	b.header, b.transactions, b.signs, b.infos, b.evidences = eb.Header, eb.Txs, eb.Signs, eb.Infos, eb.Evidences
 	b.size.Store(common.StorageSize(rlp.ListSize(size)))
 	return nil
 }
@@ -348,10 +379,11 @@ func (b *Block) DecodeRLP(s *rlp.Stream) error {
 // EncodeRLP serializes b into the truechain RLP block format.
 func (b *Block) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, extblock{
-		Header: b.header,
-		Txs:    b.transactions,
-		Signs:  b.signs,
-		Infos:  b.infos,
+		Header:    b.header,
+		Txs:       b.transactions,
+		Signs:     b.signs,
+		Infos:     b.infos,
+		Evidences: b.evidences,
 	})
 }
 
@@ -387,8 +419,17 @@ func (b *Block) Header() *Header                 { return CopyHeader(b.header) }
 func (b *Block) CommitteeHash() common.Hash      { return b.header.CommitteeHash }
 func (b *Block) SwitchInfos() []*CommitteeMember { return b.infos }
 
+// Evidences returns the equivocation evidences included in the block.
+func (b *Block) Evidences() []*DuplicateVoteEvidence { return b.evidences }
+
 // Body returns the non-header content of the block.
-func (b *Block) Body() *Body { return &Body{b.transactions, b.signs, b.infos} }
+func (b *Block) Body() *Body { return &Body{b.transactions, b.signs, b.infos, b.evidences} }
+
+// SetEvidences sets the equivocation evidences of the block, they are
+// committed by the EvidenceHash of the header.
+func (b *Block) SetEvidences(evidences []*DuplicateVoteEvidence) {
+	b.evidences = append(make([]*DuplicateVoteEvidence, 0), evidences...)
+}
 
 func (b *Block) AppendSign(sign *PbftSign) {
 	signP := CopyPbftSign(sign)
@@ -470,7 +511,7 @@ func (b *Block) WithSeal(header *Header) *Block {
 }
 
 // WithBody returns a new block with the given transaction contents.
-func (b *Block) WithBody(transactions []*Transaction, signs []*PbftSign, infos []*CommitteeMember) *Block {
+func (b *Block) WithBody(transactions []*Transaction, signs []*PbftSign, infos []*CommitteeMember, evidences []*DuplicateVoteEvidence) *Block {
 	block := &Block{
 		header:       CopyHeader(b.header),
 		transactions: make([]*Transaction, len(transactions)),
@@ -481,6 +522,10 @@ func (b *Block) WithBody(transactions []*Transaction, signs []*PbftSign, infos [
 	copy(block.transactions, transactions)
 	copy(block.signs, signs)
 	copy(block.infos, infos)
+	if len(evidences) != 0 {
+		block.evidences = make([]*DuplicateVoteEvidence, len(evidences))
+		copy(block.evidences, evidences)
+	}
 	b.header.CommitteeHash = rlpHash(b.infos)
 
 	return block
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	"truechain/discovery/rlp"
+)
+
+func TestHeaderEvidenceHash(t *testing.T) {
+	header := &Header{
+		ParentHash:  common.HexToHash("0x01"),
+		SnailNumber: big.NewInt(1),
+		Number:      big.NewInt(10),
+		Time:        big.NewInt(1000),
+		Extra:       []byte{},
+	}
+	evidences := []*DuplicateVoteEvidence{
+		{VoteA: &EvidenceVote{Data: []byte{1}, Sign: []byte{2}}, VoteB: &EvidenceVote{Data: []byte{3}, Sign: []byte{4}}},
+	}
+	legacy := header.Hash()
+
+	tests := []struct {
+		evidences []*DuplicateVoteEvidence
+		sameHash  bool
+	}{
+		{nil, true},
+		{[]*DuplicateVoteEvidence{}, true},
+		{evidences, false},
+	}
+	for i, tt := range tests {
+		h := CopyHeader(header)
+		h.EvidenceHash = CalcEvidenceHash(tt.evidences)
+		if (h.Hash() == legacy) != tt.sameHash {
+			t.Errorf("test %d: header hash changed %v, want %v", i, h.Hash() != legacy, !tt.sameHash)
+		}
+		if want := DeriveSha(DuplicateVoteEvidences(tt.evidences)); h.EvidenceRoot() != want {
+			t.Errorf("test %d: evidence root mismatch: have %x, want %x", i, h.EvidenceRoot(), want)
+		}
+
+		enc, err := rlp.EncodeToBytes(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dec Header
+		if err := rlp.DecodeBytes(enc, &dec); err != nil {
+			t.Fatalf("test %d: decode failed: %v", i, err)
+		}
+		if dec.Hash() != h.Hash() || dec.EvidenceRoot() != h.EvidenceRoot() {
+			t.Errorf("test %d: decoded header mismatch", i)
+		}
+	}
+
+	// swapping the evidences of a block is detected by the header
+	other := []*DuplicateVoteEvidence{
+		{VoteA: &EvidenceVote{Data: []byte{5}, Sign: []byte{6}}, VoteB: &EvidenceVote{Data: []byte{7}, Sign: []byte{8}}},
+	}
+	if CalcEvidenceHash(evidences)[0] == CalcEvidenceHash(other)[0] {
+		t.Fatal("different evidences have the same root")
+	}
+}
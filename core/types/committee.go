@@ -225,6 +225,7 @@ type PbftAgentProxy interface {
 	GetCurrentHeight() *big.Int
 	GetSeedMember() []*CommitteeMember
 	GetFastLastProposer() common.Address
+	AddEvidence(ev *DuplicateVoteEvidence) error
 }
 
 type PbftServerProxy interface {
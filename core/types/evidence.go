@@ -0,0 +1,149 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+	"truechain/discovery/crypto"
+	"truechain/discovery/rlp"
+)
+
+// MaxEvidenceVoteSize is the maximum size of the canonical encoding of a vote
+// carried in an evidence.
+const MaxEvidenceVoteSize = 1024
+
+var (
+	ErrInvalidEvidence     = errors.New("invalid evidence")
+	ErrEvidenceNotConflict = errors.New("evidence votes do not conflict")
+	ErrEvidenceSigner      = errors.New("evidence votes signed by different members")
+	ErrEvidenceSlashed     = errors.New("offence was already slashed")
+)
+
+// EvidenceVote is a committee vote as it was signed by the committee member:
+// the canonical encoding of the vote and the signature over its hash.
+type EvidenceVote struct {
+	Data hexutil.Bytes `json:"data"`
+	Sign hexutil.Bytes `json:"sign"`
+}
+
+// canonicalVote holds the fields of the canonical vote encoding of tbft
+// which are relevant to detect an equivocation.
+type canonicalVote struct {
+	ChainID  string          `json:"@chain_id"`
+	Type     string          `json:"@type"`
+	BlockID  json.RawMessage `json:"block_id"`
+	Height   uint64          `json:"height,string"`
+	Round    uint64          `json:"round,string"`
+	VoteType byte            `json:"type"`
+}
+
+func (v *EvidenceVote) decode() (*canonicalVote, []byte, error) {
+	if v == nil || len(v.Data) == 0 || len(v.Data) > MaxEvidenceVoteSize {
+		return nil, nil, ErrInvalidEvidence
+	}
+	var cv canonicalVote
+	if err := json.Unmarshal(v.Data, &cv); err != nil {
+		return nil, nil, fmt.Errorf("%v: %v", ErrInvalidEvidence, err)
+	}
+	if cv.Type != "vote" {
+		return nil, nil, ErrInvalidEvidence
+	}
+	// the same hash as the one signed by the tbft validator
+	hash := RlpHash([]interface{}{v.Data})
+	pubkey, err := crypto.SigToPub(hash.Bytes(), v.Sign)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v: %v", ErrInvalidEvidence, err)
+	}
+	return &cv, crypto.FromECDSAPub(pubkey), nil
+}
+
+// DuplicateVoteEvidence proves that a committee member signed two conflicting
+// votes for the same height, round and vote type. The evidence is self
+// contained, the signer is recovered from the signatures of the votes.
+type DuplicateVoteEvidence struct {
+	VoteA *EvidenceVote `json:"voteA"`
+	VoteB *EvidenceVote `json:"voteB"`
+}
+
+// NewDuplicateVoteEvidence returns the evidence of two conflicting votes with
+// the votes in canonical order.
+func NewDuplicateVoteEvidence(voteA, voteB *EvidenceVote) (*DuplicateVoteEvidence, error) {
+	a, _, err := voteA.decode()
+	if err != nil {
+		return nil, err
+	}
+	b, _, err := voteB.decode()
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Compare(a.BlockID, b.BlockID) > 0 {
+		voteA, voteB = voteB, voteA
+	}
+	ev := &DuplicateVoteEvidence{VoteA: voteA, VoteB: voteB}
+	if _, _, err := ev.Verify(); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// Hash returns the keccak256 hash of the evidence's RLP encoding.
+func (ev *DuplicateVoteEvidence) Hash() common.Hash {
+	return rlpHash(ev)
+}
+
+// Verify checks the two votes conflict and were signed by the same key, it
+// returns the public key of the offender and the height of the offence.
+func (ev *DuplicateVoteEvidence) Verify() ([]byte, uint64, error) {
+	a, pubA, err := ev.VoteA.decode()
+	if err != nil {
+		return nil, 0, err
+	}
+	b, pubB, err := ev.VoteB.decode()
+	if err != nil {
+		return nil, 0, err
+	}
+	if a.ChainID != b.ChainID || a.Height != b.Height || a.Round != b.Round || a.VoteType != b.VoteType {
+		return nil, 0, ErrEvidenceNotConflict
+	}
+	// votes are ordered so the same equivocation always has the same hash
+	if bytes.Compare(a.BlockID, b.BlockID) >= 0 {
+		return nil, 0, ErrEvidenceNotConflict
+	}
+	if !bytes.Equal(pubA, pubB) {
+		return nil, 0, ErrEvidenceSigner
+	}
+	return pubA, a.Height, nil
+}
+
+func (ev *DuplicateVoteEvidence) String() string {
+	pubkey, height, err := ev.Verify()
+	if err != nil {
+		return fmt.Sprintf("DuplicateVoteEvidence{invalid: %v}", err)
+	}
+	return fmt.Sprintf("DuplicateVoteEvidence{H:%d,PK:%s,Hash:%s}", height, common.ToHex(pubkey), ev.Hash().String())
+}
+
+// DuplicateVoteEvidences is a slice of evidences.
+type DuplicateVoteEvidences []*DuplicateVoteEvidence
+
+// Len returns the length of s.
+func (s DuplicateVoteEvidences) Len() int { return len(s) }
+
+// GetRlp implements Rlpable and returns the i'th element of s in rlp.
+func (s DuplicateVoteEvidences) GetRlp(i int) []byte {
+	enc, _ := rlp.EncodeToBytes(s[i])
+	return enc
+}
+
+// CalcEvidenceHash returns the header field committing to the evidences, it
+// is empty for a block without evidences.
+func CalcEvidenceHash(evidences []*DuplicateVoteEvidence) []common.Hash {
+	if len(evidences) == 0 {
+		return nil
+	}
+	return []common.Hash{DeriveSha(DuplicateVoteEvidences(evidences))}
+}
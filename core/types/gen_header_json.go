@@ -30,6 +30,7 @@ func (h Header) MarshalJSON() ([]byte, error) {
 		GasUsed       hexutil.Uint64 `json:"gasUsed"          gencodec:"required"`
 		Time          *hexutil.Big   `json:"timestamp"        gencodec:"required"`
 		Extra         hexutil.Bytes  `json:"extraData"        gencodec:"required"`
+		EvidenceHash  []common.Hash  `json:"evidenceRoot,omitempty" rlp:"tail"`
 		Hash          common.Hash    `json:"hash"`
 	}
 	var enc Header
@@ -47,6 +48,7 @@ func (h Header) MarshalJSON() ([]byte, error) {
 	enc.GasUsed = hexutil.Uint64(h.GasUsed)
 	enc.Time = (*hexutil.Big)(h.Time)
 	enc.Extra = h.Extra
+	enc.EvidenceHash = h.EvidenceHash
 	enc.Hash = h.Hash()
 	return json.Marshal(&enc)
 }
@@ -68,6 +70,7 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 		GasUsed       *hexutil.Uint64 `json:"gasUsed"          gencodec:"required"`
 		Time          *hexutil.Big    `json:"timestamp"        gencodec:"required"`
 		Extra         *hexutil.Bytes  `json:"extraData"        gencodec:"required"`
+		EvidenceHash  []common.Hash   `json:"evidenceRoot,omitempty" rlp:"tail"`
 	}
 	var dec Header
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -129,5 +132,8 @@ func (h *Header) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'extraData' for Header")
 	}
 	h.Extra = *dec.Extra
+	if dec.EvidenceHash != nil {
+		h.EvidenceHash = dec.EvidenceHash
+	}
 	return nil
 }
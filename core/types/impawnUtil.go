@@ -42,6 +42,11 @@ var (
 	ErrRedeemAmount      = errors.New("wrong redeem amount")
 	ErrForbidAddress     = errors.New("Forbidding Address")
 	ErrRepeatPk          = errors.New("repeat PK on staking tx")
+	ErrFeePolicySet      = errors.New("the fee policy can't be changed")
+	ErrFeeOverMax        = errors.New("the fee more than the max fee")
+	ErrFeeChangeOverMax  = errors.New("the fee change more than the max fee change")
+	ErrRedelegateSame    = errors.New("redelegate to the same staking account")
+	ErrRedelegateChained = errors.New("redelegate from the staking account redelegated to recently")
 )
 
 const (
@@ -195,6 +200,85 @@ func CloneChainReward(reward *ChainReward) *ChainReward {
 	return &res
 }
 
+// RewardLedgerItem is the staking reward of an address from a validator.
+type RewardLedgerItem struct {
+	Validator common.Address `json:"validator"`
+	Amount    *big.Int       `json:"amount"`
+	Staking   *big.Int       `json:"staking"`
+}
+
+// RewardLedgerEntry is the staking rewards of an address in a snail block,
+// split by validator.
+type RewardLedgerEntry struct {
+	SnailNumber uint64              `json:"snailNumber"`
+	Time        uint64              `json:"time"`
+	Items       []*RewardLedgerItem `json:"items"`
+}
+
+// RewardHistory is a page of the reward ledger of an address.
+type RewardHistory struct {
+	Address common.Address       `json:"address"`
+	Total   int                  `json:"total"` // the entries in the snail range
+	Rewards []*RewardLedgerEntry `json:"rewards"`
+}
+
+// LedgerEntries splits the committee rewards by the addresses rewarded.
+func (r *ChainReward) LedgerEntries() map[common.Address]*RewardLedgerEntry {
+	entries := make(map[common.Address]*RewardLedgerEntry)
+	for _, sa := range r.CommitteeBase {
+		validator := sa.getSaAddress()
+		for _, v := range sa.Items {
+			if v.Amount == nil || v.Amount.Sign() <= 0 {
+				continue
+			}
+			entry, ok := entries[v.Address]
+			if !ok {
+				entry = &RewardLedgerEntry{SnailNumber: r.Height, Time: r.St}
+				entries[v.Address] = entry
+			}
+			item := &RewardLedgerItem{
+				Validator: validator,
+				Amount:    new(big.Int).Set(v.Amount),
+				Staking:   big.NewInt(0),
+			}
+			if v.Staking != nil {
+				item.Staking.Set(v.Staking)
+			}
+			entry.Items = append(entry.Items, item)
+		}
+	}
+	return entries
+}
+
+// ElectionStake is a hypothetical deposit or delegation applied to the state
+// before simulating an election, a deposit if Validator is nil.
+type ElectionStake struct {
+	Address   common.Address  `json:"address"`
+	Validator *common.Address `json:"validator,omitempty"`
+	Amount    *big.Int        `json:"amount"`
+}
+
+// ElectionCandidate is a staking account ranked by an election simulation.
+type ElectionCandidate struct {
+	Address      common.Address `json:"address"`
+	Rank         int            `json:"rank"`         // 1-based among the eligible accounts, 0 if not eligible
+	Staking      *big.Int       `json:"staking"`      // the valid staking of the account itself
+	ValidStaking *big.Int       `json:"validStaking"` // the valid staking with the delegations
+	Elected      bool           `json:"elected"`
+	Excluded     bool           `json:"excluded"`            // slashed and excluded from the election
+	Shortfall    *big.Int       `json:"shortfall,omitempty"` // the staking needed to be elected
+}
+
+// ElectionSimulation is the projected result of the next committee election.
+type ElectionSimulation struct {
+	EpochID    uint64               `json:"epochID"`
+	Height     uint64               `json:"height"`     // the height the valid staking counted at
+	Candidates int                  `json:"candidates"` // the accounts eligible for the committee
+	Committee  []*ElectionCandidate `json:"committee"`
+	MinStake   *big.Int             `json:"minStake"` // the valid staking needed to take a seat
+	Target     *ElectionCandidate   `json:"target,omitempty"`
+}
+
 type BalanceInfo struct {
 	Address common.Address `json:"address"`
 	Valid   *big.Int       `json:"valid"`
@@ -0,0 +1,95 @@
+package types
+
+import (
+	"math/big"
+
+	"truechain/discovery/common"
+)
+
+// PaymentTx is the transaction data of the payment transactions, whose gas is
+// paid by the payer, who signs the transaction after the sender.
+type PaymentTx struct {
+	ChainID    *big.Int        // destination chain ID
+	Nonce      uint64          // nonce of sender account
+	GasPrice   *big.Int        // wei per gas
+	Gas        uint64          // gas limit
+	To         *common.Address `rlp:"nil"` // nil means contract creation
+	Value      *big.Int        // wei amount
+	Data       []byte          // contract invocation input data
+	AccessList AccessList      // EIP-2930 access list
+	Payer      common.Address  // account paying the gas
+	Fee        *big.Int        // fee paid to the recipient
+	V, R, S    *big.Int        // sender signature values
+	Policy     *SponsorPolicy  `rlp:"nil"` // sponsor policy of the payer, nil means none
+	PV, PR, PS *big.Int        // payer signature values
+}
+
+// copy creates a deep copy of the transaction data and initializes all fields.
+func (tx *PaymentTx) copy() TxData {
+	cpy := &PaymentTx{
+		Nonce:  tx.Nonce,
+		To:     copyAddressPtr(tx.To),
+		Data:   common.CopyBytes(tx.Data),
+		Gas:    tx.Gas,
+		Payer:  tx.Payer,
+		Policy: tx.Policy.copy(),
+		// These are copied below.
+		AccessList: make(AccessList, len(tx.AccessList)),
+		ChainID:    new(big.Int),
+		GasPrice:   new(big.Int),
+		Value:      new(big.Int),
+		Fee:        new(big.Int),
+		V:          new(big.Int),
+		R:          new(big.Int),
+		S:          new(big.Int),
+		PV:         new(big.Int),
+		PR:         new(big.Int),
+		PS:         new(big.Int),
+	}
+	copy(cpy.AccessList, tx.AccessList)
+	for _, v := range []struct{ dst, src *big.Int }{
+		{cpy.ChainID, tx.ChainID}, {cpy.GasPrice, tx.GasPrice}, {cpy.Value, tx.Value}, {cpy.Fee, tx.Fee},
+		{cpy.V, tx.V}, {cpy.R, tx.R}, {cpy.S, tx.S}, {cpy.PV, tx.PV}, {cpy.PR, tx.PR}, {cpy.PS, tx.PS},
+	} {
+		if v.src != nil {
+			v.dst.Set(v.src)
+		}
+	}
+	return cpy
+}
+
+// accessors for innerTx.
+func (tx *PaymentTx) txType() byte           { return PaymentTxType }
+func (tx *PaymentTx) chainID() *big.Int      { return tx.ChainID }
+func (tx *PaymentTx) accessList() AccessList { return tx.AccessList }
+func (tx *PaymentTx) data() []byte           { return tx.Data }
+func (tx *PaymentTx) gas() uint64            { return tx.Gas }
+func (tx *PaymentTx) gasPrice() *big.Int     { return tx.GasPrice }
+func (tx *PaymentTx) value() *big.Int        { return tx.Value }
+func (tx *PaymentTx) nonce() uint64          { return tx.Nonce }
+func (tx *PaymentTx) to() *common.Address    { return tx.To }
+func (tx *PaymentTx) payer() *common.Address { return &tx.Payer }
+
+// fee returns nil for no fee, as the legacy payment transactions do.
+func (tx *PaymentTx) fee() *big.Int {
+	if tx.Fee == nil || tx.Fee.Sign() == 0 {
+		return nil
+	}
+	return tx.Fee
+}
+
+func (tx *PaymentTx) rawSignatureValues() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *PaymentTx) rawPayerSignatureValues() (v, r, s *big.Int) {
+	return tx.PV, tx.PR, tx.PS
+}
+
+func (tx *PaymentTx) setSignatureValues(v, r, s *big.Int) {
+	tx.V, tx.R, tx.S = v, r, s
+}
+
+func (tx *PaymentTx) setPayerSignatureValues(v, r, s *big.Int) {
+	tx.PV, tx.PR, tx.PS = v, r, s
+}
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"errors"
+	"math/big"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+)
+
+//go:generate gencodec -type SponsorPolicy -field-override sponsorPolicyMarshaling -out gen_sponsor_policy_json.go
+
+// SponsorAddress is defined as Address('truesponsor'), the system account
+// keeping the gas fee spent by the payers under their sponsor policies.
+var SponsorAddress = common.BytesToAddress([]byte("truesponsor"))
+
+var (
+	ErrSponsorPolicyExpired       = errors.New("sponsor policy expired")
+	ErrSponsorGasPriceTooHigh     = errors.New("gas price above the sponsor policy limit")
+	ErrSponsorRecipientNotAllowed = errors.New("recipient not sponsored by the policy")
+	ErrSponsorSenderNotAllowed    = errors.New("sender not sponsored by the policy")
+	ErrSponsorFeeCapExceeded      = errors.New("sponsor policy total fee exceeded")
+	ErrSponsorPolicyNotSupported  = errors.New("sponsor policy needs a payment transaction")
+)
+
+// SponsorPolicy limits what the payer of a payment transaction sponsors. It's
+// attached to the transaction by the payer and covered by the payer signature.
+//
+// The fee the payer spends on gas is tracked in the state per payer and policy,
+// so the transactions sponsored under the same policy share MaxTotalFee.
+type SponsorPolicy struct {
+	MaxGasPrice *big.Int         `json:"maxGasPrice"` // Highest gas price sponsored, any if zero
+	MaxTotalFee *big.Int         `json:"maxTotalFee"` // Most gas fee spent under the policy, no cap if zero
+	Recipients  []common.Address `json:"recipients"`  // Recipients sponsored, any if empty
+	Senders     []common.Address `json:"senders"`     // Senders sponsored, any if empty
+	Expiry      uint64           `json:"expiry"`      // Last block number the policy is valid at, none if zero
+}
+
+type sponsorPolicyMarshaling struct {
+	MaxGasPrice *hexutil.Big
+	MaxTotalFee *hexutil.Big
+	Expiry      hexutil.Uint64
+}
+
+// Hash returns the hash identifying the policy, the key its spend is tracked at.
+func (p *SponsorPolicy) Hash() common.Hash {
+	return rlpHash(p)
+}
+
+// Check returns an error if the policy doesn't sponsor a transaction from the
+// sender to the recipient, nil for a contract creation, at the gas price in
+// the block of the number. The total fee is checked against the state.
+func (p *SponsorPolicy) Check(from common.Address, to *common.Address, gasPrice *big.Int, number *big.Int) error {
+	if p.Expiry != 0 && number.Uint64() > p.Expiry {
+		return ErrSponsorPolicyExpired
+	}
+	if p.MaxGasPrice != nil && p.MaxGasPrice.Sign() > 0 && gasPrice.Cmp(p.MaxGasPrice) > 0 {
+		return ErrSponsorGasPriceTooHigh
+	}
+	if len(p.Recipients) > 0 && (to == nil || !containsAddress(p.Recipients, *to)) {
+		return ErrSponsorRecipientNotAllowed
+	}
+	if len(p.Senders) > 0 && !containsAddress(p.Senders, from) {
+		return ErrSponsorSenderNotAllowed
+	}
+	return nil
+}
+
+// CheckFee returns an error if spending the fee on top of the one spent already
+// under the policy exceeds its total fee.
+func (p *SponsorPolicy) CheckFee(spent, fee *big.Int) error {
+	if p.MaxTotalFee == nil || p.MaxTotalFee.Sign() == 0 {
+		return nil
+	}
+	if new(big.Int).Add(spent, fee).Cmp(p.MaxTotalFee) > 0 {
+		return ErrSponsorFeeCapExceeded
+	}
+	return nil
+}
+
+// copy creates a deep copy of the policy.
+func (p *SponsorPolicy) copy() *SponsorPolicy {
+	if p == nil {
+		return nil
+	}
+	cpy := &SponsorPolicy{
+		MaxGasPrice: copyBigPtr(p.MaxGasPrice),
+		MaxTotalFee: copyBigPtr(p.MaxTotalFee),
+		Expiry:      p.Expiry,
+	}
+	if p.Recipients != nil {
+		cpy.Recipients = append([]common.Address{}, p.Recipients...)
+	}
+	if p.Senders != nil {
+		cpy.Senders = append([]common.Address{}, p.Senders...)
+	}
+	return cpy
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
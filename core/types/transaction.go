@@ -17,6 +17,7 @@
 package types
 
 import (
+	"bytes"
 	"container/heap"
 	"errors"
 	"io"
@@ -27,19 +28,29 @@ import (
 	"strconv"
 
 	"truechain/discovery/common"
-	"truechain/discovery/common/hexutil"
 	"truechain/discovery/crypto"
 	"truechain/discovery/rlp"
 )
 
-//go:generate gencodec -type txdata -field-override txdataMarshaling -out gen_tx_json.go
-
 var (
-	ErrInvalidSig = errors.New("invalid transaction v, r, s values")
+	ErrInvalidSig           = errors.New("invalid transaction v, r, s values")
+	ErrTxTypeNotSupported   = errors.New("transaction type not supported")
+	ErrUnexpectedProtection = errors.New("transaction type does not supported EIP-155 protected signatures")
+	errEmptyTypedTx         = errors.New("empty typed transaction bytes")
+	errShortTypedTx         = errors.New("typed transaction too short")
+)
+
+// Transaction types.
+const (
+	LegacyTxType     = 0x00
+	AccessListTxType = 0x01
+	PaymentTxType    = 0x70 // clear of the types assigned by Ethereum
 )
 
+// Transaction is a legacy transaction, or an EIP-2718 typed transaction of the
+// types above.
 type Transaction struct {
-	data txdata
+	inner TxData // Consensus contents of a transaction
 	// caches
 	hash    atomic.Value
 	size    atomic.Value
@@ -47,6 +58,37 @@ type Transaction struct {
 	payment atomic.Value
 }
 
+// NewTx creates a new transaction.
+func NewTx(inner TxData) *Transaction {
+	tx := new(Transaction)
+	tx.setDecoded(inner.copy(), 0)
+	return tx
+}
+
+// TxData is the underlying data of a transaction.
+//
+// This is implemented by LegacyTx, AccessListTx and PaymentTx.
+type TxData interface {
+	txType() byte // returns the type ID
+	copy() TxData // creates a deep copy and initializes all fields
+
+	chainID() *big.Int
+	accessList() AccessList
+	data() []byte
+	gas() uint64
+	gasPrice() *big.Int
+	value() *big.Int
+	nonce() uint64
+	to() *common.Address
+	payer() *common.Address
+	fee() *big.Int
+
+	rawSignatureValues() (v, r, s *big.Int)
+	setSignatureValues(v, r, s *big.Int)
+	rawPayerSignatureValues() (v, r, s *big.Int)
+	setPayerSignatureValues(v, r, s *big.Int)
+}
+
 type RawTransaction struct {
 	data raw_txdata
 	// caches
@@ -55,30 +97,6 @@ type RawTransaction struct {
 	from atomic.Value
 }
 
-type txdata struct {
-	AccountNonce uint64          `json:"nonce"    gencodec:"required"`
-	Price        *big.Int        `json:"gasPrice" gencodec:"required"`
-	GasLimit     uint64          `json:"gas"      gencodec:"required"`
-	Recipient    *common.Address `json:"to"       rlp:"nil"` // nil means contract creation
-	Amount       *big.Int        `json:"value"    gencodec:"required"`
-	Payload      []byte          `json:"input"    gencodec:"required"`
-	Payer        *common.Address `json:"payer"    rlp:"nil"`
-	Fee          *big.Int        `json:"fee"   rlp:"nil"`
-
-	// Signature values
-	V *big.Int `json:"v" gencodec:"required"`
-	R *big.Int `json:"r" gencodec:"required"`
-	S *big.Int `json:"s" gencodec:"required"`
-
-	// Paied Signature values
-	PV *big.Int `json:"pv" rlp:"nil"` // nil means donnot have payment
-	PR *big.Int `json:"pr" rlp:"nil"`
-	PS *big.Int `json:"ps" rlp:"nil"`
-
-	// This is only used when marshaling to JSON.
-	Hash *common.Hash `json:"hash" rlp:"-"`
-}
-
 type raw_txdata struct {
 	AccountNonce uint64          `json:"nonce"    gencodec:"required"`
 	Price        *big.Int        `json:"gasPrice" gencodec:"required"`
@@ -107,39 +125,23 @@ func (rawTransaction *RawTransaction) ConvertTransaction() *Transaction {
 	} else {
 		tx = NewTransaction(cpy_data.AccountNonce, *cpy_data.Recipient, cpy_data.Amount, cpy_data.GasLimit, cpy_data.Price, cpy_data.Payload)
 	}
-	tx.data.V = cpy_data.V
-	tx.data.R = cpy_data.R
-	tx.data.S = cpy_data.S
+	tx.inner.setSignatureValues(cpy_data.V, cpy_data.R, cpy_data.S)
 	return tx
 }
 
+// ConvertRawTransaction converts a legacy transaction without payer to the
+// Ethereum encoding.
 func (tx *Transaction) ConvertRawTransaction() *RawTransaction {
-	cpy := &Transaction{data: tx.data}
-	cpy_data := cpy.data
-
 	raw_tx := new(RawTransaction)
-	if cpy_data.Recipient == nil {
-		raw_tx = NewRawTransactionContract(cpy_data.AccountNonce, cpy_data.Amount, cpy_data.GasLimit, cpy_data.Price, cpy_data.Payload)
+	if tx.To() == nil {
+		raw_tx = NewRawTransactionContract(tx.Nonce(), tx.inner.value(), tx.Gas(), tx.inner.gasPrice(), tx.inner.data())
 	} else {
-		raw_tx = NewRawTransaction(cpy_data.AccountNonce, *cpy_data.Recipient, cpy_data.Amount, cpy_data.GasLimit, cpy_data.Price, cpy_data.Payload)
+		raw_tx = NewRawTransaction(tx.Nonce(), *tx.To(), tx.inner.value(), tx.Gas(), tx.inner.gasPrice(), tx.inner.data())
 	}
-	raw_tx.data.V = cpy_data.V
-	raw_tx.data.R = cpy_data.R
-	raw_tx.data.S = cpy_data.S
+	raw_tx.data.V, raw_tx.data.R, raw_tx.data.S = tx.inner.rawSignatureValues()
 	return raw_tx
 }
 
-type txdataMarshaling struct {
-	AccountNonce hexutil.Uint64
-	Price        *hexutil.Big
-	GasLimit     hexutil.Uint64
-	Amount       *hexutil.Big
-	Payload      hexutil.Bytes
-	V            *hexutil.Big
-	R            *hexutil.Big
-	S            *hexutil.Big
-}
-
 func NewTransaction(nonce uint64, to common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) *Transaction {
 	return NewTransaction_Payment(nonce, to, amount, nil, gasLimit, gasPrice, data, common.Address{})
 }
@@ -166,33 +168,33 @@ func newTransaction(nonce uint64, to *common.Address, payer *common.Address, amo
 	if len(data) > 0 {
 		data = common.CopyBytes(data)
 	}
-	d := txdata{
-		AccountNonce: nonce,
-		Recipient:    to,
-		Payer:        payer,
-		Payload:      data,
-		Amount:       new(big.Int),
-		GasLimit:     gasLimit,
-		Price:        new(big.Int),
-		V:            new(big.Int),
-		R:            new(big.Int),
-		S:            new(big.Int),
-		PV:           new(big.Int),
-		PR:           new(big.Int),
-		PS:           new(big.Int),
+	d := &LegacyTx{
+		Nonce:    nonce,
+		To:       to,
+		Payer:    payer,
+		Data:     data,
+		Value:    new(big.Int),
+		Gas:      gasLimit,
+		GasPrice: new(big.Int),
+		V:        new(big.Int),
+		R:        new(big.Int),
+		S:        new(big.Int),
+		PV:       new(big.Int),
+		PR:       new(big.Int),
+		PS:       new(big.Int),
 	}
 	if amount != nil {
-		d.Amount.Set(amount)
+		d.Value.Set(amount)
 	}
 	if fee != nil {
 		d.Fee = new(big.Int)
 		d.Fee.Set(fee)
 	}
 	if gasPrice != nil {
-		d.Price.Set(gasPrice)
+		d.GasPrice.Set(gasPrice)
 	}
 
-	return &Transaction{data: d}
+	return &Transaction{inner: d}
 }
 
 func NewRawTransaction(nonce uint64, to common.Address, amount *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) *RawTransaction {
@@ -229,49 +231,179 @@ func newRawTransaction(nonce uint64, to *common.Address, amount *big.Int, gasLim
 
 // ChainId returns which chain id this transaction was signed for (if at all)
 func (tx *Transaction) ChainId() *big.Int {
-	return deriveChainId(tx.data.V)
+	return tx.inner.chainID()
 }
 
 // Protected returns whether the transaction is protected from replay protection.
 func (tx *Transaction) Protected() bool {
-	return isProtectedV(tx.data.V)
+	switch tx := tx.inner.(type) {
+	case *LegacyTx:
+		return tx.V != nil && isProtectedV(tx.V)
+	default:
+		return true
+	}
 }
 
 func (tx *Transaction) Protected_Payment() bool {
-	return isProtectedV(tx.data.PV)
+	switch tx := tx.inner.(type) {
+	case *LegacyTx:
+		return tx.PV != nil && isProtectedV(tx.PV)
+	default:
+		return true
+	}
 }
 
 func isProtectedV(V *big.Int) bool {
 	if V.BitLen() <= 8 {
 		v := V.Uint64()
-		return v != 27 && v != 28
+		return v != 27 && v != 28 && v != 1 && v != 0
 	}
 	// anything not 27 or 28 are considered unprotected
 	return true
 }
 
+// Type returns the transaction type.
+func (tx *Transaction) Type() uint8 {
+	return tx.inner.txType()
+}
+
 // EncodeRLP implements rlp.Encoder
 func (tx *Transaction) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, &tx.data)
+	if tx.Type() == LegacyTxType {
+		return rlp.Encode(w, tx.inner)
+	}
+	// It's an EIP-2718 typed TX envelope.
+	buf := new(bytes.Buffer)
+	if err := tx.encodeTyped(buf); err != nil {
+		return err
+	}
+	return rlp.Encode(w, buf.Bytes())
+}
+
+// encodeTyped writes the canonical encoding of a typed transaction to w.
+func (tx *Transaction) encodeTyped(w *bytes.Buffer) error {
+	w.WriteByte(tx.Type())
+	return rlp.Encode(w, tx.inner)
+}
+
+// MarshalBinary returns the canonical encoding of the transaction.
+// For legacy transactions, it returns the RLP encoding. For EIP-2718 typed
+// transactions, it returns the type and payload.
+func (tx *Transaction) MarshalBinary() ([]byte, error) {
+	if tx.Type() == LegacyTxType {
+		return rlp.EncodeToBytes(tx.inner)
+	}
+	var buf bytes.Buffer
+	err := tx.encodeTyped(&buf)
+	return buf.Bytes(), err
 }
 
 // DecodeRLP implements rlp.Decoder
 func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
-	_, size, _ := s.Kind()
-	err := s.Decode(&tx.data)
-	if err == nil {
-		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+	kind, size, err := s.Kind()
+	switch {
+	case err != nil:
+		return err
+	case kind == rlp.List:
+		// It's a legacy transaction.
+		var inner LegacyTx
+		err := s.Decode(&inner)
+		if err == nil {
+			tx.setDecoded(&inner, int(rlp.ListSize(size)))
+		}
+		return err
+	case kind == rlp.String:
+		// It's an EIP-2718 typed TX envelope.
+		var b []byte
+		if b, err = s.Bytes(); err != nil {
+			return err
+		}
+		inner, err := tx.decodeTyped(b)
+		if err == nil {
+			tx.setDecoded(inner, len(b))
+		}
+		return err
+	default:
+		return rlp.ErrExpectedList
 	}
+}
 
-	return err
+// UnmarshalBinary decodes the canonical encoding of transactions.
+// It supports legacy RLP transactions and EIP-2718 typed transactions.
+func (tx *Transaction) UnmarshalBinary(b []byte) error {
+	if len(b) > 0 && b[0] > 0x7f {
+		// It's a legacy transaction.
+		var data LegacyTx
+		err := rlp.DecodeBytes(b, &data)
+		if err != nil {
+			return err
+		}
+		tx.setDecoded(&data, len(b))
+		return nil
+	}
+	// It's an EIP2718 typed transaction envelope.
+	inner, err := tx.decodeTyped(b)
+	if err != nil {
+		return err
+	}
+	tx.setDecoded(inner, len(b))
+	return nil
 }
 
-// MarshalJSON encodes the web3 RPC transaction format.
-func (tx *Transaction) MarshalJSON() ([]byte, error) {
-	hash := tx.Hash()
-	data := tx.data
-	data.Hash = &hash
-	return data.MarshalJSON()
+// decodeTyped decodes a typed transaction from the canonical format.
+func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
+	if len(b) == 0 {
+		return nil, errEmptyTypedTx
+	}
+	if len(b) <= 1 {
+		return nil, errShortTypedTx
+	}
+	switch b[0] {
+	case AccessListTxType:
+		var inner AccessListTx
+		err := rlp.DecodeBytes(b[1:], &inner)
+		return &inner, err
+	case PaymentTxType:
+		var inner PaymentTx
+		err := rlp.DecodeBytes(b[1:], &inner)
+		return &inner, err
+	default:
+		return nil, ErrTxTypeNotSupported
+	}
+}
+
+// setDecoded sets the inner transaction and size after decoding.
+func (tx *Transaction) setDecoded(inner TxData, size int) {
+	tx.inner = inner
+	if size > 0 {
+		tx.size.Store(common.StorageSize(size))
+	}
+}
+
+func sanityCheckSignature(v *big.Int, r *big.Int, s *big.Int, maybeProtected bool) error {
+	if isProtectedV(v) && !maybeProtected {
+		return ErrUnexpectedProtection
+	}
+
+	var plainV byte
+	if isProtectedV(v) {
+		chainID := deriveChainId(v).Uint64()
+		plainV = byte(v.Uint64() - 35 - 2*chainID)
+	} else if maybeProtected {
+		// Only EIP-155 signatures can be optionally protected. Since
+		// we determined this v value is not protected, it must be a
+		// raw 27 or 28.
+		plainV = byte(v.Uint64() - 27)
+	} else {
+		// If the signature is not optionally protected, we assume it
+		// must already be equal to the recovery id.
+		plainV = byte(v.Uint64())
+	}
+	if !crypto.ValidateSignatureValues(plainV, r, s, false) {
+		return ErrInvalidSig
+	}
+
+	return nil
 }
 
 func (tx *Transaction) Info() string {
@@ -280,22 +412,23 @@ func (tx *Transaction) Info() string {
 	recipient := ""
 	fee := ""
 	payload := ""
-	if tx.data.Fee != nil {
-		fee = strconv.Itoa(int(tx.data.Fee.Int64()))
+	if f := tx.inner.fee(); f != nil {
+		fee = strconv.Itoa(int(f.Int64()))
 	}
-	if tx.data.Payer != nil {
-		payer = common.Bytes2Hex(tx.data.Payer[:])
+	if p := tx.inner.payer(); p != nil {
+		payer = common.Bytes2Hex(p[:])
 	}
-	if tx.data.Recipient != nil {
-		recipient = common.Bytes2Hex(tx.data.Recipient[:])
+	if to := tx.inner.to(); to != nil {
+		recipient = common.Bytes2Hex(to[:])
 	}
-	if tx.data.Payload != nil {
-		payload = common.Bytes2Hex(tx.data.Payload[:])
+	if data := tx.inner.data(); data != nil {
+		payload = common.Bytes2Hex(data)
 	}
+	v, r, s := tx.inner.rawSignatureValues()
 
-	str += fmt.Sprintf("nonce=%v,price=%v, gaslimit=%v,Recipient=%v,Amount=%v,Payload=%v,chainId=%v,fee=%v,payment=%v, v=%v,r=%v,s=%v,",
-		tx.data.AccountNonce, tx.data.Price, tx.data.GasLimit, recipient, tx.data.Amount, payload, tx.ChainId(),
-		fee, payer, tx.data.V, tx.data.R, tx.data.S)
+	str += fmt.Sprintf("type=%v,nonce=%v,price=%v, gaslimit=%v,Recipient=%v,Amount=%v,Payload=%v,chainId=%v,fee=%v,payment=%v, v=%v,r=%v,s=%v,",
+		tx.Type(), tx.Nonce(), tx.inner.gasPrice(), tx.Gas(), recipient, tx.inner.value(), payload, tx.ChainId(),
+		fee, payer, v, r, s)
 	return str
 }
 
@@ -336,55 +469,51 @@ func (tx *RawTransaction) DecodeRLP(s *rlp.Stream) error {
 	return data.MarshalJSON()
 }*/
 
-// UnmarshalJSON decodes the web3 RPC transaction format.
-func (tx *Transaction) UnmarshalJSON(input []byte) error {
-	var dec txdata
-	if err := dec.UnmarshalJSON(input); err != nil {
-		return err
-	}
-	var V byte
-	if isProtectedV(dec.V) {
-		chainID := deriveChainId(dec.V).Uint64()
-		V = byte(dec.V.Uint64() - 35 - 2*chainID)
-	} else {
-		V = byte(dec.V.Uint64() - 27)
-	}
-	if !crypto.ValidateSignatureValues(V, dec.R, dec.S, false) {
-		return ErrInvalidSig
-	}
-	*tx = Transaction{data: dec}
-	return nil
-}
+// AccessList returns the access list of the transaction.
+func (tx *Transaction) AccessList() AccessList { return tx.inner.accessList() }
 
-func (tx *Transaction) Data() []byte       { return common.CopyBytes(tx.data.Payload) }
-func (tx *Transaction) Gas() uint64        { return tx.data.GasLimit }
-func (tx *Transaction) GasPrice() *big.Int { return new(big.Int).Set(tx.data.Price) }
-func (tx *Transaction) Value() *big.Int    { return new(big.Int).Set(tx.data.Amount) }
+func (tx *Transaction) Data() []byte       { return common.CopyBytes(tx.inner.data()) }
+func (tx *Transaction) Gas() uint64        { return tx.inner.gas() }
+func (tx *Transaction) GasPrice() *big.Int { return new(big.Int).Set(tx.inner.gasPrice()) }
+func (tx *Transaction) Value() *big.Int    { return new(big.Int).Set(tx.inner.value()) }
 func (tx *Transaction) Fee() *big.Int {
-	if tx.data.Fee == nil {
+	if tx.inner.fee() == nil {
 		return nil
 	}
-	return new(big.Int).Set(tx.data.Fee)
+	return new(big.Int).Set(tx.inner.fee())
 }
-func (tx *Transaction) Nonce() uint64    { return tx.data.AccountNonce }
+func (tx *Transaction) Nonce() uint64    { return tx.inner.nonce() }
 func (tx *Transaction) CheckNonce() bool { return true }
 
 // To returns the recipient address of the transaction.
 // It returns nil if the transaction is a contract creation.
 func (tx *Transaction) To() *common.Address {
-	if tx.data.Recipient == nil {
-		return nil
-	}
-	to := *tx.data.Recipient
-	return &to
+	return copyAddressPtr(tx.inner.to())
 }
 
 func (tx *Transaction) Payer() *common.Address {
-	if tx.data.Payer == nil {
-		return nil
+	return copyAddressPtr(tx.inner.payer())
+}
+
+// SponsorPolicy returns the sponsor policy the payer attached to a payment
+// transaction, nil if there's none.
+func (tx *Transaction) SponsorPolicy() *SponsorPolicy {
+	if ptx, ok := tx.inner.(*PaymentTx); ok {
+		return ptx.Policy.copy()
 	}
-	payer := *tx.data.Payer
-	return &payer
+	return nil
+}
+
+// WithSponsorPolicy returns a copy of the payment transaction with the sponsor
+// policy attached, to be signed by the payer. The signature of the sender is
+// kept as it doesn't cover the policy.
+func (tx *Transaction) WithSponsorPolicy(policy *SponsorPolicy) (*Transaction, error) {
+	if _, ok := tx.inner.(*PaymentTx); !ok {
+		return nil, ErrSponsorPolicyNotSupported
+	}
+	cpy := tx.inner.copy().(*PaymentTx)
+	cpy.Policy = policy.copy()
+	return &Transaction{inner: cpy}, nil
 }
 
 // Hash hashes the RLP encoding of tx.
@@ -393,9 +522,14 @@ func (tx *Transaction) Hash() common.Hash {
 	if hash := tx.hash.Load(); hash != nil {
 		return hash.(common.Hash)
 	}
-	v := rlpHash(tx)
-	tx.hash.Store(v)
-	return v
+	var h common.Hash
+	if tx.Type() == LegacyTxType {
+		h = rlpHash(tx.inner)
+	} else {
+		h = prefixedRlpHash(tx.Type(), tx.inner)
+	}
+	tx.hash.Store(h)
+	return h
 }
 
 // Size returns the true RLP encoded storage size of the transaction, either by
@@ -405,7 +539,10 @@ func (tx *Transaction) Size() common.StorageSize {
 		return size.(common.StorageSize)
 	}
 	c := writeCounter(0)
-	rlp.Encode(&c, &tx.data)
+	rlp.Encode(&c, tx.inner)
+	if tx.Type() != LegacyTxType {
+		c += 1 // the type byte
+	}
 	tx.size.Store(common.StorageSize(c))
 	return common.StorageSize(c)
 }
@@ -417,13 +554,15 @@ func (tx *Transaction) Size() common.StorageSize {
 // XXX Rename message to something less arbitrary?
 func (tx *Transaction) AsMessage(s Signer) (Message, error) {
 	msg := Message{
-		nonce:      tx.data.AccountNonce,
-		gasLimit:   tx.data.GasLimit,
-		gasPrice:   new(big.Int).Set(tx.data.Price),
-		to:         tx.data.Recipient,
-		amount:     tx.data.Amount,
-		fee:        tx.data.Fee,
-		data:       tx.data.Payload,
+		nonce:      tx.Nonce(),
+		gasLimit:   tx.Gas(),
+		gasPrice:   new(big.Int).Set(tx.inner.gasPrice()),
+		to:         tx.To(),
+		amount:     tx.inner.value(),
+		fee:        tx.inner.fee(),
+		data:       tx.inner.data(),
+		accessList: tx.AccessList(),
+		policy:     tx.SponsorPolicy(),
 		checkNonce: true,
 	}
 
@@ -443,9 +582,9 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	if err != nil {
 		return nil, err
 	}
-	cpy := &Transaction{data: tx.data}
-	cpy.data.R, cpy.data.S, cpy.data.V = r, s, v
-	return cpy, nil
+	cpy := tx.inner.copy()
+	cpy.setSignatureValues(v, r, s)
+	return &Transaction{inner: cpy}, nil
 }
 
 func (tx *Transaction) WithSignature_Payment(signer Signer, sig []byte) (*Transaction, error) {
@@ -453,17 +592,17 @@ func (tx *Transaction) WithSignature_Payment(signer Signer, sig []byte) (*Transa
 	if err != nil {
 		return nil, err
 	}
-	cpy := &Transaction{data: tx.data}
-	cpy.data.PR, cpy.data.PS, cpy.data.PV = pr, ps, pv
-	return cpy, nil
+	cpy := tx.inner.copy()
+	cpy.setPayerSignatureValues(pv, pr, ps)
+	return &Transaction{inner: cpy}, nil
 }
 
 // Cost returns amount + gasprice * gaslimit.
 func (tx *Transaction) Cost() *big.Int {
-	total := new(big.Int).Mul(tx.data.Price, new(big.Int).SetUint64(tx.data.GasLimit))
-	total.Add(total, tx.data.Amount)
-	if tx.data.Fee != nil {
-		total.Add(total, tx.data.Fee)
+	total := new(big.Int).Mul(tx.inner.gasPrice(), new(big.Int).SetUint64(tx.Gas()))
+	total.Add(total, tx.inner.value())
+	if fee := tx.inner.fee(); fee != nil {
+		total.Add(total, fee)
 	}
 	return total
 }
@@ -471,25 +610,25 @@ func (tx *Transaction) Cost() *big.Int {
 // AmountCost returns amount+Fee.
 func (tx *Transaction) AmountCost() *big.Int {
 	total := big.NewInt(0)
-	total.Add(total, tx.data.Amount)
-	if tx.data.Fee != nil {
-		total.Add(total, tx.data.Fee)
+	total.Add(total, tx.inner.value())
+	if fee := tx.inner.fee(); fee != nil {
+		total.Add(total, fee)
 	}
 	return total
 }
 
 // GasCost returns gasprice * gaslimit.
 func (tx *Transaction) GasCost() *big.Int {
-	gas := new(big.Int).Mul(tx.data.Price, new(big.Int).SetUint64(tx.data.GasLimit))
+	gas := new(big.Int).Mul(tx.inner.gasPrice(), new(big.Int).SetUint64(tx.Gas()))
 	return gas
 }
 
 func (tx *Transaction) RawSignatureValues() (*big.Int, *big.Int, *big.Int) {
-	return tx.data.V, tx.data.R, tx.data.S
+	return tx.inner.rawSignatureValues()
 }
 
 func (tx *Transaction) TrueRawSignatureValues() (*big.Int, *big.Int, *big.Int) {
-	return tx.data.PV, tx.data.PR, tx.data.PS
+	return tx.inner.rawPayerSignatureValues()
 }
 
 // Transactions is a Transaction slice type for basic sorting.
@@ -501,9 +640,10 @@ func (s Transactions) Len() int { return len(s) }
 // Swap swaps the i'th and the j'th element in s.
 func (s Transactions) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 
-// GetRlp implements Rlpable and returns the i'th element of s in rlp.
+// GetRlp implements Rlpable and returns the i'th element of s in rlp, or the
+// EIP-2718 envelope for the typed transactions.
 func (s Transactions) GetRlp(i int) []byte {
-	enc, _ := rlp.EncodeToBytes(s[i])
+	enc, _ := s[i].MarshalBinary()
 	return enc
 }
 
@@ -531,7 +671,7 @@ func TxDifference(a, b Transactions) (keep Transactions) {
 type TxByNonce Transactions
 
 func (s TxByNonce) Len() int           { return len(s) }
-func (s TxByNonce) Less(i, j int) bool { return s[i].data.AccountNonce < s[j].data.AccountNonce }
+func (s TxByNonce) Less(i, j int) bool { return s[i].Nonce() < s[j].Nonce() }
 func (s TxByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
 // TxByPrice implements both the sort and the heap interface, making it useful
@@ -539,7 +679,7 @@ func (s TxByNonce) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 type TxByPrice Transactions
 
 func (s TxByPrice) Len() int           { return len(s) }
-func (s TxByPrice) Less(i, j int) bool { return s[i].data.Price.Cmp(s[j].data.Price) > 0 }
+func (s TxByPrice) Less(i, j int) bool { return s[i].inner.gasPrice().Cmp(s[j].inner.gasPrice()) > 0 }
 func (s TxByPrice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 
 func (s *TxByPrice) Push(x interface{}) {
@@ -629,10 +769,12 @@ type Message struct {
 	gasLimit   uint64
 	gasPrice   *big.Int
 	data       []byte
+	accessList AccessList
+	policy     *SponsorPolicy
 	checkNonce bool
 }
 
-func NewMessage(from common.Address, to *common.Address, payment common.Address, nonce uint64, amount *big.Int, fee *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, checkNonce bool) Message {
+func NewMessage(from common.Address, to *common.Address, payment common.Address, nonce uint64, amount *big.Int, fee *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte, accessList AccessList, checkNonce bool) Message {
 	return Message{
 		from:       from,
 		to:         to,
@@ -641,6 +783,7 @@ func NewMessage(from common.Address, to *common.Address, payment common.Address,
 		gasLimit:   gasLimit,
 		gasPrice:   gasPrice,
 		data:       data,
+		accessList: accessList,
 		checkNonce: checkNonce,
 		payment:    payment,
 		fee:        fee,
@@ -659,3 +802,26 @@ func (m Message) Gas() uint64      { return m.gasLimit }
 func (m Message) Nonce() uint64    { return m.nonce }
 func (m Message) Data() []byte     { return m.data }
 func (m Message) CheckNonce() bool { return m.checkNonce }
+
+// AccessList returns the access list of the message.
+func (m Message) AccessList() AccessList { return m.accessList }
+
+// SponsorPolicy returns the sponsor policy of the payer, nil if there's none.
+func (m Message) SponsorPolicy() *SponsorPolicy { return m.policy }
+
+// copyAddressPtr copies an address.
+func copyAddressPtr(a *common.Address) *common.Address {
+	if a == nil {
+		return nil
+	}
+	cpy := *a
+	return &cpy
+}
+
+// copyBigPtr copies a big integer, keeping nil if it's unset.
+func copyBigPtr(b *big.Int) *big.Int {
+	if b == nil {
+		return nil
+	}
+	return new(big.Int).Set(b)
+}
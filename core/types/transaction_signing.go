@@ -46,7 +46,13 @@ type sigCache_payment struct {
 
 // MakeSigner returns a Signer based on the given chain config and block number.
 func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
-	signer := NewTIP1Signer(config.ChainID)
+	var signer Signer
+	switch {
+	case config.IsTIP13(blockNumber):
+		signer = NewTIP13Signer(config.ChainID)
+	default:
+		signer = NewTIP1Signer(config.ChainID)
+	}
 	return signer
 }
 
@@ -79,15 +85,16 @@ func Payer(signer Signer, tx *Transaction) (common.Address, error) {
 			return sigCache_payment.payment, nil
 		}
 	}
-	if tx.data.Payer == nil {
+	payer := tx.inner.payer()
+	if payer == nil {
 		return params.EmptyAddress, nil
 	}
 	addr, err := signer.Payer(tx)
 	if err != nil {
 		return params.EmptyAddress, err
 	}
-	if addr != *tx.data.Payer {
-		log.Error("Payer err,signed_addr !=tx.data.Payer ", "signed_payer", addr, "tx_payer", *tx.data.Payer)
+	if addr != *payer {
+		log.Error("Payer err,signed_addr !=tx.data.Payer ", "signed_payer", addr, "tx_payer", *payer)
 		return params.EmptyAddress, ErrPayersign
 	}
 	tx.payment.Store(sigCache_payment{signer: signer, payment: addr})
@@ -160,26 +167,37 @@ func (s TIP1Signer) Equal(s2 Signer) bool {
 var big8 = big.NewInt(8)
 
 func (s TIP1Signer) Sender(tx *Transaction) (common.Address, error) {
+	if tx.Type() != LegacyTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
 	if tx.ChainId().Cmp(s.chainId) != 0 {
 		return common.Address{}, ErrInvalidChainId
 	}
-	V := new(big.Int).Sub(tx.data.V, s.chainIdMul)
+	v, r, sig := tx.RawSignatureValues()
+	V := new(big.Int).Sub(v, s.chainIdMul)
 	V.Sub(V, big8)
-	return recoverPlain(s.Hash(tx), tx.data.R, tx.data.S, V, true)
+	return recoverPlain(s.Hash(tx), r, sig, V, true)
 }
 
 func (s TIP1Signer) Payer(tx *Transaction) (common.Address, error) {
+	if tx.Type() != LegacyTxType {
+		return common.Address{}, ErrTxTypeNotSupported
+	}
 	if tx.ChainId().Cmp(s.chainId) != 0 {
 		return common.Address{}, ErrInvalidChainId
 	}
-	PV := new(big.Int).Sub(tx.data.PV, s.chainIdMul)
+	pv, pr, ps := tx.TrueRawSignatureValues()
+	PV := new(big.Int).Sub(pv, s.chainIdMul)
 	PV.Sub(PV, big8)
-	return recoverPlain(s.Hash_Payment(tx), tx.data.PR, tx.data.PS, PV, true)
+	return recoverPlain(s.Hash_Payment(tx), pr, ps, PV, true)
 }
 
 // WithSignature returns a new transaction with the given signature. This signature
 // needs to be in the [R || S || V] format where V is 0 or 1.
 func (s TIP1Signer) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	if tx.Type() != LegacyTxType {
+		return nil, nil, nil, ErrTxTypeNotSupported
+	}
 	R, S, V, err = SignatureValues(tx, sig)
 	if err != nil {
 		return nil, nil, nil, err
@@ -197,29 +215,34 @@ func (s TIP1Signer) Hash(tx *Transaction) common.Hash {
 	//fmt.Println("Hash method,tx.data.Payer", tx.data.Payer)
 	var hash common.Hash
 	//payer and fee is nil or default value
-	if tx.data.Fee != nil && tx.data.Fee.Uint64() == 0 {
-		tx.data.Fee = nil
+	data, ok := tx.inner.(*LegacyTx)
+	if !ok {
+		// Typed transactions can't be signed by the signer
+		return hash
+	}
+	if data.Fee != nil && data.Fee.Uint64() == 0 {
+		data.Fee = nil
 	}
-	if (tx.data.Payer == nil || *tx.data.Payer == (common.Address{})) && tx.data.Fee == nil {
+	if (data.Payer == nil || *data.Payer == (common.Address{})) && data.Fee == nil {
 		hash = rlpHash([]interface{}{
-			tx.data.AccountNonce,
-			tx.data.Price,
-			tx.data.GasLimit,
-			tx.data.Recipient,
-			tx.data.Amount,
-			tx.data.Payload,
+			data.Nonce,
+			data.GasPrice,
+			data.Gas,
+			data.To,
+			data.Value,
+			data.Data,
 			s.chainId, uint(0), uint(0),
 		})
 	} else { //payer is not nil
 		hash = rlpHash([]interface{}{
-			tx.data.AccountNonce,
-			tx.data.Price,
-			tx.data.GasLimit,
-			tx.data.Recipient,
-			tx.data.Amount,
-			tx.data.Payload,
-			tx.data.Payer,
-			tx.data.Fee,
+			data.Nonce,
+			data.GasPrice,
+			data.Gas,
+			data.To,
+			data.Value,
+			data.Data,
+			data.Payer,
+			data.Fee,
 			s.chainId, uint(0), uint(0),
 		})
 	}
@@ -227,22 +250,159 @@ func (s TIP1Signer) Hash(tx *Transaction) common.Hash {
 }
 
 func (s TIP1Signer) Hash_Payment(tx *Transaction) common.Hash {
+	data, ok := tx.inner.(*LegacyTx)
+	if !ok {
+		return common.Hash{}
+	}
 	return rlpHash([]interface{}{
-		tx.data.AccountNonce,
-		tx.data.Price,
-		tx.data.GasLimit,
-		tx.data.Recipient,
-		tx.data.Amount,
-		tx.data.Payload,
-		tx.data.Payer,
-		tx.data.Fee,
-		tx.data.V,
-		tx.data.R,
-		tx.data.S,
+		data.Nonce,
+		data.GasPrice,
+		data.Gas,
+		data.To,
+		data.Value,
+		data.Data,
+		data.Payer,
+		data.Fee,
+		data.V,
+		data.R,
+		data.S,
 		s.chainId, uint(0), uint(0),
 	})
 }
 
+// TIP13Signer implements Signer for the typed transactions of the TIP13 upgrade,
+// access list and payment ones, and the legacy ones as TIP1Signer does.
+type TIP13Signer struct{ TIP1Signer }
+
+// NewTIP13Signer returns a signer that accepts the typed transactions, as well
+// as the legacy ones.
+func NewTIP13Signer(chainId *big.Int) TIP13Signer {
+	return TIP13Signer{NewTIP1Signer(chainId)}
+}
+
+func (s TIP13Signer) Equal(s2 Signer) bool {
+	tip13, ok := s2.(TIP13Signer)
+	return ok && tip13.chainId.Cmp(s.chainId) == 0
+}
+
+func (s TIP13Signer) Sender(tx *Transaction) (common.Address, error) {
+	switch tx.Type() {
+	case LegacyTxType:
+		return s.TIP1Signer.Sender(tx)
+	case AccessListTxType, PaymentTxType:
+	default:
+		return common.Address{}, ErrTxTypeNotSupported
+	}
+	if tx.ChainId().Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	// The typed transactions use 0 and 1 as their recovery id
+	V, R, S := tx.RawSignatureValues()
+	V = new(big.Int).Add(V, big.NewInt(27))
+	return recoverPlain(s.Hash(tx), R, S, V, true)
+}
+
+func (s TIP13Signer) Payer(tx *Transaction) (common.Address, error) {
+	switch tx.Type() {
+	case LegacyTxType:
+		return s.TIP1Signer.Payer(tx)
+	case PaymentTxType:
+	default:
+		return common.Address{}, ErrTxTypeNotSupported
+	}
+	if tx.ChainId().Cmp(s.chainId) != 0 {
+		return common.Address{}, ErrInvalidChainId
+	}
+	PV, PR, PS := tx.TrueRawSignatureValues()
+	PV = new(big.Int).Add(PV, big.NewInt(27))
+	return recoverPlain(s.Hash_Payment(tx), PR, PS, PV, true)
+}
+
+// SignatureValues returns the signature values, with V as the recovery id for
+// the typed transactions.
+func (s TIP13Signer) SignatureValues(tx *Transaction, sig []byte) (R, S, V *big.Int, err error) {
+	switch tx.Type() {
+	case LegacyTxType:
+		return s.TIP1Signer.SignatureValues(tx, sig)
+	case AccessListTxType, PaymentTxType:
+	default:
+		return nil, nil, nil, ErrTxTypeNotSupported
+	}
+	// Check that chain ID of tx matches the signer. We also accept ID zero here,
+	// because it indicates that the chain ID was not specified in the tx.
+	if chainID := tx.ChainId(); chainID.Sign() != 0 && chainID.Cmp(s.chainId) != 0 {
+		return nil, nil, nil, ErrInvalidChainId
+	}
+	R, S, _, err = SignatureValues(tx, sig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	V = big.NewInt(int64(sig[64]))
+	return R, S, V, nil
+}
+
+// Hash returns the hash to be signed by the sender.
+// It does not uniquely identify the transaction.
+func (s TIP13Signer) Hash(tx *Transaction) common.Hash {
+	switch data := tx.inner.(type) {
+	case *AccessListTx:
+		return prefixedRlpHash(
+			tx.Type(),
+			[]interface{}{
+				s.chainId,
+				data.Nonce,
+				data.GasPrice,
+				data.Gas,
+				data.To,
+				data.Value,
+				data.Data,
+				data.AccessList,
+			})
+	case *PaymentTx:
+		return prefixedRlpHash(
+			tx.Type(),
+			[]interface{}{
+				s.chainId,
+				data.Nonce,
+				data.GasPrice,
+				data.Gas,
+				data.To,
+				data.Value,
+				data.Data,
+				data.AccessList,
+				data.Payer,
+				data.Fee,
+			})
+	}
+	return s.TIP1Signer.Hash(tx)
+}
+
+// Hash_Payment returns the hash to be signed by the payer, which covers the
+// signature of the sender and the sponsor policy of the payer.
+func (s TIP13Signer) Hash_Payment(tx *Transaction) common.Hash {
+	if data, ok := tx.inner.(*PaymentTx); ok {
+		return prefixedRlpHash(
+			tx.Type(),
+			[]interface{}{
+				s.chainId,
+				data.Nonce,
+				data.GasPrice,
+				data.Gas,
+				data.To,
+				data.Value,
+				data.Data,
+				data.AccessList,
+				data.Payer,
+				data.Fee,
+				data.V,
+				data.R,
+				data.S,
+				data.Policy,
+			})
+	}
+	return s.TIP1Signer.Hash_Payment(tx)
+}
+
 /*
 // EIP155Transaction implements Signer using the EIP155 rules.
 type EIP155Signer struct {
@@ -101,7 +101,8 @@ func TestEIP155ChainId(t *testing.T) {
 	signer := NewTIP1Signer(big.NewInt(19330))
 	tx, err := SignTx(NewTransaction(0, addr, new(big.Int), 0, new(big.Int), nil), signer, key)
 
-	fmt.Println("R", hex.EncodeToString(tx.data.R.Bytes()), "S", hex.EncodeToString(tx.data.S.Bytes()), "V", tx.data.V)
+	v, r, s := tx.RawSignatureValues()
+	fmt.Println("R", hex.EncodeToString(r.Bytes()), "S", hex.EncodeToString(s.Bytes()), "V", v)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,330 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	"truechain/discovery/crypto"
+	"truechain/discovery/rlp"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	payerKey, _ = crypto.HexToECDSA("7631a11e9d28563cdbcf96d581e4b9a19e53ad433a53c25a9f18c74ddf492f75")
+	payerAddr   = crypto.PubkeyToAddress(payerKey.PublicKey)
+
+	testChainID = big.NewInt(19330)
+	testTo      = common.HexToAddress("0x095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	testList    = AccessList{{
+		Address:     testTo,
+		StorageKeys: []common.Hash{{0x01}, {0x02}},
+	}}
+)
+
+func newAccessListTx() *Transaction {
+	return NewTx(&AccessListTx{
+		ChainID:    testChainID,
+		Nonce:      3,
+		GasPrice:   big.NewInt(10),
+		Gas:        50000,
+		To:         &testTo,
+		Value:      big.NewInt(1),
+		Data:       common.FromHex("5544"),
+		AccessList: testList,
+	})
+}
+
+func newPaymentTx() *Transaction {
+	return NewTx(&PaymentTx{
+		ChainID:    testChainID,
+		Nonce:      3,
+		GasPrice:   big.NewInt(10),
+		Gas:        50000,
+		To:         &testTo,
+		Value:      big.NewInt(1),
+		AccessList: testList,
+		Payer:      payerAddr,
+		Fee:        big.NewInt(5),
+	})
+}
+
+func signTypedTx(t *testing.T, tx *Transaction) *Transaction {
+	signer := NewTIP13Signer(testChainID)
+	tx, err := SignTx(tx, signer, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Payer() != nil {
+		if tx, err = SignTx_Payment(tx, signer, payerKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tx
+}
+
+func TestTypedTxEncoding(t *testing.T) {
+	for _, tx := range []*Transaction{signTypedTx(t, newAccessListTx()), signTypedTx(t, newPaymentTx())} {
+		bin, err := tx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bin[0] != tx.Type() {
+			t.Fatalf("type %d: wrong envelope type %d", tx.Type(), bin[0])
+		}
+		if tx.Hash() != crypto.Keccak256Hash(bin) {
+			t.Errorf("type %d: hash mismatch", tx.Type())
+		}
+		if tx.Size() != common.StorageSize(len(bin)) {
+			t.Errorf("type %d: size mismatch: have %v, want %d", tx.Type(), tx.Size(), len(bin))
+		}
+		dec := new(Transaction)
+		if err := dec.UnmarshalBinary(bin); err != nil {
+			t.Fatal(err)
+		}
+		if dec.Hash() != tx.Hash() {
+			t.Errorf("type %d: binary round trip changed the hash", tx.Type())
+		}
+		// Within blocks the envelope is wrapped in an RLP string
+		enc, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec = new(Transaction)
+		if err := rlp.DecodeBytes(enc, dec); err != nil {
+			t.Fatal(err)
+		}
+		if dec.Hash() != tx.Hash() || dec.Type() != tx.Type() {
+			t.Errorf("type %d: rlp round trip changed the transaction", tx.Type())
+		}
+		if len(dec.AccessList()) != 1 || dec.AccessList().StorageKeys() != 2 {
+			t.Errorf("type %d: access list lost: %v", tx.Type(), dec.AccessList())
+		}
+		// And in JSON
+		js, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec = new(Transaction)
+		if err := json.Unmarshal(js, dec); err != nil {
+			t.Fatal(err)
+		}
+		if dec.Hash() != tx.Hash() {
+			t.Errorf("type %d: json round trip changed the hash", tx.Type())
+		}
+	}
+}
+
+func TestLegacyTxEncodingUnchanged(t *testing.T) {
+	tx, err := SignTx(NewTransaction(3, testTo, big.NewInt(1), 50000, big.NewInt(10), nil), NewTIP1Signer(testChainID), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, bin) {
+		t.Errorf("legacy binary encoding isn't the rlp one")
+	}
+	if tx.Hash() != crypto.Keccak256Hash(enc) {
+		t.Errorf("legacy hash isn't the hash of the rlp encoding")
+	}
+	// The TIP13 signer recovers the legacy transactions as the TIP1 one
+	from, err := Sender(NewTIP13Signer(testChainID), tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != testAddr {
+		t.Errorf("sender mismatch: have %x, want %x", from, testAddr)
+	}
+}
+
+func TestTypedTxSigner(t *testing.T) {
+	signer := NewTIP13Signer(testChainID)
+
+	tx := signTypedTx(t, newAccessListTx())
+	from, err := Sender(signer, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != testAddr {
+		t.Errorf("sender mismatch: have %x, want %x", from, testAddr)
+	}
+	if _, err := Sender(NewTIP1Signer(testChainID), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("TIP1 signer accepted a typed transaction: %v", err)
+	}
+	if _, err := Sender(NewTIP13Signer(big.NewInt(1)), tx); err != ErrInvalidChainId {
+		t.Errorf("wrong chain id not rejected: %v", err)
+	}
+
+	tx = signTypedTx(t, newPaymentTx())
+	if from, err = Sender(signer, tx); err != nil {
+		t.Fatal(err)
+	}
+	if from != testAddr {
+		t.Errorf("sender mismatch: have %x, want %x", from, testAddr)
+	}
+	payer, err := Payer(signer, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payer != payerAddr {
+		t.Errorf("payer mismatch: have %x, want %x", payer, payerAddr)
+	}
+	// The payer signs over the signature of the sender
+	resigned, err := SignTx(tx, signer, payerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Payer(signer, resigned); err != ErrPayersign {
+		t.Errorf("payer signature valid over another sender signature: %v", err)
+	}
+}
+
+func TestTypedReceiptEncoding(t *testing.T) {
+	receipt := &Receipt{
+		Type:              AccessListTxType,
+		Status:            ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000,
+		Logs:              []*Log{{Address: testTo, Topics: []common.Hash{{0x01}}, Data: []byte{0x02}}},
+		TxHash:            common.Hash{0x03},
+		GasUsed:           21000,
+	}
+	bin, err := receipt.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin[0] != AccessListTxType {
+		t.Fatalf("wrong envelope type %d", bin[0])
+	}
+	if !bytes.Equal(Receipts{receipt}.GetRlp(0), bin) {
+		t.Errorf("derived sha encoding isn't the envelope")
+	}
+	enc, err := rlp.EncodeToBytes(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(Receipt)
+	if err := rlp.DecodeBytes(enc, dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Type != receipt.Type || dec.Status != receipt.Status || len(dec.Logs) != 1 {
+		t.Errorf("consensus round trip mismatch: %+v", dec)
+	}
+	// Stored receipts keep the type, the legacy ones are stored as before
+	for _, typ := range []uint8{LegacyTxType, AccessListTxType} {
+		receipt.Type = typ
+		enc, err := rlp.EncodeToBytes((*ReceiptForStorage)(receipt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored := new(ReceiptForStorage)
+		if err := rlp.DecodeBytes(enc, stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Type != typ || stored.TxHash != receipt.TxHash || stored.GasUsed != receipt.GasUsed {
+			t.Errorf("type %d: storage round trip mismatch: %+v", typ, stored)
+		}
+	}
+}
+
+func TestSponsorPolicy(t *testing.T) {
+	policy := &SponsorPolicy{
+		MaxGasPrice: big.NewInt(10),
+		MaxTotalFee: big.NewInt(1000000),
+		Recipients:  []common.Address{testTo},
+		Expiry:      100,
+	}
+	signer := NewTIP13Signer(testChainID)
+	tx, err := SignTx(newPaymentTx(), signer, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The payer attaches the policy after the sender signed
+	if tx, err = tx.WithSponsorPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = SignTx_Payment(tx, signer, payerKey); err != nil {
+		t.Fatal(err)
+	}
+	if from, err := Sender(signer, tx); err != nil || from != testAddr {
+		t.Fatalf("sender mismatch: have %x, %v", from, err)
+	}
+	if payer, err := Payer(signer, tx); err != nil || payer != payerAddr {
+		t.Fatalf("payer mismatch: have %x, %v", payer, err)
+	}
+	bin, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := new(Transaction)
+	if err := dec.UnmarshalBinary(bin); err != nil {
+		t.Fatal(err)
+	}
+	if dec.SponsorPolicy() == nil || dec.SponsorPolicy().Hash() != policy.Hash() {
+		t.Fatalf("policy lost in encoding: %+v", dec.SponsorPolicy())
+	}
+	js, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec = new(Transaction)
+	if err := json.Unmarshal(js, dec); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Hash() != tx.Hash() {
+		t.Errorf("json round trip changed the hash")
+	}
+	// The payer signature covers the policy
+	loosened := *policy
+	loosened.MaxGasPrice = big.NewInt(1000)
+	forged, err := tx.WithSponsorPolicy(&loosened)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Payer(signer, forged); err != ErrPayersign {
+		t.Errorf("payer signature valid over another policy: %v", err)
+	}
+	// The legacy transactions can't carry a policy
+	if _, err := NewTransaction(0, testTo, nil, 0, nil, nil).WithSponsorPolicy(policy); err != ErrSponsorPolicyNotSupported {
+		t.Errorf("policy attached to a legacy transaction: %v", err)
+	}
+
+	other := common.Address{0xff}
+	tests := []struct {
+		from     common.Address
+		to       *common.Address
+		gasPrice int64
+		number   int64
+		err      error
+	}{
+		{testAddr, &testTo, 10, 100, nil},
+		{testAddr, &testTo, 10, 101, ErrSponsorPolicyExpired},
+		{testAddr, &testTo, 11, 1, ErrSponsorGasPriceTooHigh},
+		{testAddr, &other, 1, 1, ErrSponsorRecipientNotAllowed},
+		{testAddr, nil, 1, 1, ErrSponsorRecipientNotAllowed},
+	}
+	for i, tt := range tests {
+		if err := policy.Check(tt.from, tt.to, big.NewInt(tt.gasPrice), big.NewInt(tt.number)); err != tt.err {
+			t.Errorf("test %d: have %v, want %v", i, err, tt.err)
+		}
+	}
+	policy.Senders = []common.Address{payerAddr}
+	if err := policy.Check(testAddr, &testTo, big.NewInt(1), big.NewInt(1)); err != ErrSponsorSenderNotAllowed {
+		t.Errorf("sender not in the policy sponsored: %v", err)
+	}
+	if err := policy.CheckFee(big.NewInt(999990), big.NewInt(10)); err != nil {
+		t.Errorf("fee within the cap rejected: %v", err)
+	}
+	if err := policy.CheckFee(big.NewInt(999990), big.NewInt(11)); err != ErrSponsorFeeCapExceeded {
+		t.Errorf("fee above the cap accepted: %v", err)
+	}
+}
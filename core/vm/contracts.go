@@ -25,7 +25,6 @@ import (
 	"truechain/discovery/crypto/bls12381"
 
 	"golang.org/x/crypto/ripemd160"
-	"truechain/discovery/accounts/abi"
 	"truechain/discovery/common"
 	"truechain/discovery/common/math"
 	"truechain/discovery/core/types"
@@ -126,6 +125,30 @@ var PrecompiledContractsBerlin = map[common.Address]PrecompiledContract{
 	types.StakingAddress:              &staking{},
 }
 
+// precompiledContracts returns the precompiled contracts active under the rules.
+func precompiledContracts(rules params.Rules) map[common.Address]PrecompiledContract {
+	switch {
+	case rules.IsTIP7:
+		return PrecompiledContractsPoS
+	case rules.IsTIP11:
+		return PrecompiledContractsYoloPos
+	default:
+		return PrecompiledContractsByzantium
+	}
+}
+
+// ActivePrecompiles returns the addresses of the precompiled contracts active
+// under the rules, which are in the access list of every transaction from the
+// TIP13 upgrade.
+func ActivePrecompiles(rules params.Rules) []common.Address {
+	precompiles := precompiledContracts(rules)
+	addrs := make([]common.Address, 0, len(precompiles))
+	for addr := range precompiles {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 // It returns
 // - the returned bytes,
@@ -1029,18 +1052,9 @@ func (c *bls12381MapG2) Run(evm *EVM, contract *Contract, input []byte) ([]byte,
 type staking struct{}
 
 func (c *staking) RequiredGas(evm *EVM, input []byte) uint64 {
-	var (
-		baseGas uint64 = 21000
-		method  *abi.Method
-		err     error
-	)
-
-	if evm.chainConfig.IsTIP10(evm.Context.BlockNumber) {
-		method, err = abiStaking.MethodById(input)
-	} else {
-		method, err = abiPre10.MethodById(input)
-	}
+	var baseGas uint64 = 21000
 
+	method, err := stakingMethodById(evm, input)
 	if err != nil {
 		return baseGas
 	}
@@ -43,16 +43,7 @@ type (
 )
 
 func (evm *EVM) precompile(addr common.Address) (PrecompiledContract, bool) {
-	var precompiles map[common.Address]PrecompiledContract
-	switch {
-	case evm.chainRules.IsTIP7:
-		precompiles = PrecompiledContractsPoS
-	case evm.chainRules.IsTIP11:
-		precompiles = PrecompiledContractsYoloPos
-	default:
-		precompiles = PrecompiledContractsByzantium
-	}
-	p, ok := precompiles[addr]
+	p, ok := precompiledContracts(evm.chainRules)[addr]
 	return p, ok
 }
 
@@ -95,6 +86,7 @@ type BlockContext struct {
 	BlockNumber *big.Int       // Provides information for NUMBER
 	Time        *big.Int       // Provides information for TIME
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
+	BaseFee     *big.Int       // Provides information for BASEFEE, zero if nil as there's no fee market
 }
 
 // TxContext provides the EVM with information about a transaction.
@@ -0,0 +1,51 @@
+package vm
+
+import "fmt"
+
+// ExecutionMode is the way the transactions of a block are executed.
+type ExecutionMode int
+
+const (
+	ParallelExecution   ExecutionMode = iota // Group the transactions by the touched addresses and execute the groups concurrently
+	SequentialExecution                      // Execute the transactions one by one
+	ShadowExecution                          // Execute in parallel, cross check with a sequential execution
+)
+
+func (mode ExecutionMode) IsValid() bool {
+	return mode >= ParallelExecution && mode <= ShadowExecution
+}
+
+// String implements the stringer interface.
+func (mode ExecutionMode) String() string {
+	switch mode {
+	case ParallelExecution:
+		return "parallel"
+	case SequentialExecution:
+		return "sequential"
+	case ShadowExecution:
+		return "shadow"
+	default:
+		return "unknown"
+	}
+}
+
+func (mode ExecutionMode) MarshalText() ([]byte, error) {
+	if !mode.IsValid() {
+		return nil, fmt.Errorf("unknown execution mode %d", mode)
+	}
+	return []byte(mode.String()), nil
+}
+
+func (mode *ExecutionMode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "parallel":
+		*mode = ParallelExecution
+	case "sequential":
+		*mode = SequentialExecution
+	case "shadow":
+		*mode = ShadowExecution
+	default:
+		return fmt.Errorf(`unknown execution mode %q, want "parallel", "sequential" or "shadow"`, text)
+	}
+	return nil
+}
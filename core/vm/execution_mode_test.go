@@ -0,0 +1,38 @@
+package vm
+
+import "testing"
+
+func TestExecutionModeText(t *testing.T) {
+	tests := []struct {
+		text string
+		mode ExecutionMode
+		ok   bool
+	}{
+		{"parallel", ParallelExecution, true},
+		{"sequential", SequentialExecution, true},
+		{"shadow", ShadowExecution, true},
+		{"Parallel", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		var mode ExecutionMode
+		err := mode.UnmarshalText([]byte(tt.text))
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: unmarshal error mismatch: %v", tt.text, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if mode != tt.mode {
+			t.Errorf("%q: mode mismatch: have %v, want %v", tt.text, mode, tt.mode)
+		}
+		text, err := mode.MarshalText()
+		if err != nil || string(text) != tt.text {
+			t.Errorf("%q: marshal mismatch: have %q, %v", tt.text, text, err)
+		}
+	}
+	if _, err := ExecutionMode(ShadowExecution + 1).MarshalText(); err == nil {
+		t.Error("unknown execution mode marshalled")
+	}
+}
@@ -18,6 +18,7 @@ type extImpawnImpl struct {
 	CurEpochID uint64
 	Array      []uint64
 	LastReward uint64
+	Slashes    []*SlashRecord `rlp:"tail"`
 }
 
 func (i *ImpawnImpl) DecodeRLP(s *rlp.Stream) error {
@@ -31,6 +32,7 @@ func (i *ImpawnImpl) DecodeRLP(s *rlp.Stream) error {
 	}
 
 	i.curEpochID, i.accounts, i.lastReward = ei.CurEpochID, accounts, ei.LastReward
+	i.slashes = ei.Slashes
 	return nil
 }
 
@@ -56,6 +58,74 @@ func (i *ImpawnImpl) EncodeRLP(w io.Writer) error {
 		Accounts:   accounts,
 		Array:      order,
 		LastReward: i.lastReward,
+		Slashes:    i.slashes,
+	})
+}
+
+// "external" StakingAccount encoding, the profile is a tail so the accounts
+// without a profile keep the encoding of the older states.
+type extStakingAccount struct {
+	Unit       *impawnUnit
+	Votepubkey []byte
+	Fee        *big.Int
+	Committee  bool
+	Delegation []*DelegationAccount
+	Modify     *AlterableInfo
+	Profile    []*ValidatorProfile `rlp:"tail"`
+}
+
+func (s *StakingAccount) DecodeRLP(st *rlp.Stream) error {
+	var es extStakingAccount
+	if err := st.Decode(&es); err != nil {
+		return err
+	}
+	s.Unit, s.Votepubkey, s.Fee, s.Committee = es.Unit, es.Votepubkey, es.Fee, es.Committee
+	s.Delegation, s.Modify, s.Profile = es.Delegation, es.Modify, nil
+	if len(es.Profile) > 0 {
+		s.Profile = es.Profile[0]
+	}
+	return nil
+}
+
+// EncodeRLP serializes s into the truechain RLP StakingAccount format.
+func (s *StakingAccount) EncodeRLP(w io.Writer) error {
+	es := extStakingAccount{
+		Unit:       s.Unit,
+		Votepubkey: s.Votepubkey,
+		Fee:        s.Fee,
+		Committee:  s.Committee,
+		Delegation: s.Delegation,
+		Modify:     s.Modify,
+	}
+	if s.Profile != nil {
+		es.Profile = []*ValidatorProfile{s.Profile}
+	}
+	return rlp.Encode(w, es)
+}
+
+// "external" DelegationAccount encoding, the redelegations are a tail so the
+// accounts without any keep the encoding of the older states.
+type extDelegationAccount struct {
+	SaAddress     common.Address
+	Unit          *impawnUnit
+	Redelegations []*Redelegation `rlp:"tail"`
+}
+
+func (d *DelegationAccount) DecodeRLP(s *rlp.Stream) error {
+	var ed extDelegationAccount
+	if err := s.Decode(&ed); err != nil {
+		return err
+	}
+	d.SaAddress, d.Unit, d.Redelegations = ed.SaAddress, ed.Unit, ed.Redelegations
+	return nil
+}
+
+// EncodeRLP serializes d into the truechain RLP DelegationAccount format.
+func (d *DelegationAccount) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, extDelegationAccount{
+		SaAddress:     d.SaAddress,
+		Unit:          d.Unit,
+		Redelegations: d.Redelegations,
 	})
 }
 
@@ -88,6 +158,9 @@ func (i *ImpawnImpl) GetAllStakingAccountRPC(height uint64) map[string]interface
 			}
 			attr["modify"] = ai
 		}
+		if sa.Profile != nil {
+			attr["profile"] = profileDisplay(sa.Profile)
+		}
 		attr["staking"] = weiToTrue(sa.getAllStaking(height))
 		attr["validStaking"] = weiToTrue(sa.getValidStaking(height))
 		attrs = append(attrs, attr)
@@ -355,11 +428,63 @@ func (i *ImpawnImpl) GetStakingAccountRPC(height uint64, address common.Address)
 		}
 		attr["modify"] = ai
 	}
+	if sa.Profile != nil {
+		attr["profile"] = profileDisplay(sa.Profile)
+	}
 	attr["staking"] = weiToTrue(sa.getAllStaking(height))
 	attr["validStaking"] = weiToTrue(sa.getValidStaking(height))
 	return attr
 }
 
+// GetValidatorProfileRPC returns the profile and the fee policy of the staking
+// account, nil if it isn't staking.
+func (i *ImpawnImpl) GetValidatorProfileRPC(address common.Address) map[string]interface{} {
+	sas := i.GetAllStakingAccount()
+	sa := sas.getSA(address)
+	if sa == nil {
+		return nil
+	}
+	attr := map[string]interface{}{
+		"address":      sa.Unit.Address,
+		"fee":          sa.Fee.Uint64(),
+		"maxFee":       types.Base.Uint64(),
+		"maxFeeChange": types.Base.Uint64(),
+		"feePolicy":    false,
+	}
+	if fee := sa.pendingFee(); fee != nil {
+		attr["nextFee"] = fee.Uint64()
+	}
+	if sa.Profile != nil {
+		for k, v := range profileDisplay(sa.Profile) {
+			attr[k] = v
+		}
+	}
+	return attr
+}
+
+// GetRedelegationsRPC returns the redelegations of the delegator in flight at
+// the height, both the pending and the not matured ones.
+func (i *ImpawnImpl) GetRedelegationsRPC(height uint64, address common.Address) []map[string]interface{} {
+	attrs := redelegationsDisplay(address, i.getRedelegations(address, height))
+	for _, attr := range attrs {
+		attr["pending"] = attr["effectHeight"].(uint64) > height
+	}
+	return attrs
+}
+
+func profileDisplay(p *ValidatorProfile) map[string]interface{} {
+	attr := make(map[string]interface{})
+	attr["moniker"] = p.Moniker
+	attr["website"] = p.Website
+	attr["contactHash"] = p.ContactHash
+	attr["feePolicy"] = p.FeePolicy
+	if p.FeePolicy {
+		attr["maxFee"] = p.MaxFee.Uint64()
+		attr["maxFeeChange"] = p.MaxFeeChange.Uint64()
+	}
+	return attr
+}
+
 func isCommitteeMember(i *ImpawnImpl, address common.Address) bool {
 	sas := i.getElections3(i.curEpochID)
 	if sas == nil {
@@ -381,6 +506,26 @@ func daSDisplay(das []*DelegationAccount, height uint64) []map[string]interface{
 		attr["delegate"] = weiToTrue(da.getAllStaking(height))
 		attr["validDelegate"] = weiToTrue(da.getValidStaking(height))
 		attr["unit"] = unitDisplay(da.Unit)
+		if len(da.Redelegations) > 0 {
+			attr["redelegations"] = redelegationsDisplay(da.Unit.Address, da.Redelegations)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs
+}
+
+func redelegationsDisplay(addr common.Address, rs []*Redelegation) []map[string]interface{} {
+	var attrs []map[string]interface{}
+	for _, r := range rs {
+		attr := make(map[string]interface{})
+		attr["delegator"] = addr
+		attr["from"] = r.From
+		attr["to"] = r.To
+		attr["amount"] = weiToTrue(r.Amount)
+		attr["height"] = r.Height
+		attr["epochID"] = r.EpochID
+		attr["effectHeight"] = r.effectHeight()
+		attr["matureHeight"] = r.effectHeight() + params.MaxRedeemHeight
 		attrs = append(attrs, attr)
 	}
 	return attrs
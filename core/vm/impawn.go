@@ -286,6 +286,62 @@ func (s *impawnUnit) clone() *impawnUnit {
 	}
 	return tmp
 }
+
+// slash burns the fraction (in percent) of the staking in the epoch and
+// returns the burned amount. The redeem items of the previous epochs are not
+// part of the staking values anymore and are burned as well.
+func (s *impawnUnit) slash(epochid, fraction uint64) *big.Int {
+	burned, all := big.NewInt(0), big.NewInt(0)
+	cut := func(amount *big.Int) *big.Int {
+		c := new(big.Int).Mul(amount, new(big.Int).SetUint64(fraction))
+		c.Div(c, big.NewInt(100))
+		amount.Sub(amount, c)
+		return c
+	}
+	for _, v := range s.Value {
+		burned.Add(burned, cut(v.Amount))
+		all.Add(all, v.Amount)
+	}
+	for _, v := range s.RedeemInof {
+		if v.EpochID < epochid {
+			burned.Add(burned, cut(v.Amount))
+		} else if v.EpochID == epochid {
+			// canceled from the staking values, which are burned already
+			cut(v.Amount)
+			if v.Amount.Cmp(all) > 0 {
+				v.Amount.Set(all)
+			}
+		}
+	}
+	return burned
+}
+
+// sub takes at most amount from the staking values, the latest first, and
+// returns the amount taken. The redeem item of the epoch is cut to what's left.
+func (s *impawnUnit) sub(epochid uint64, amount *big.Int) *big.Int {
+	taken, left := big.NewInt(0), new(big.Int).Set(amount)
+	for k := len(s.Value) - 1; k >= 0 && left.Sign() > 0; k-- {
+		v := s.Value[k]
+		c := left
+		if v.Amount.Cmp(left) < 0 {
+			c = v.Amount
+		}
+		c = new(big.Int).Set(c)
+		v.Amount.Sub(v.Amount, c)
+		left.Sub(left, c)
+		taken.Add(taken, c)
+	}
+	if r := s.getRedeemItem(epochid); r != nil {
+		all := big.NewInt(0)
+		for _, v := range s.Value {
+			all.Add(all, v.Amount)
+		}
+		if r.Amount.Cmp(all) > 0 {
+			r.Amount.Set(all)
+		}
+	}
+	return taken
+}
 func (s *impawnUnit) sort() {
 	sort.Sort(valuesByHeight(s.Value))
 	s.sortRedeemItems()
@@ -321,12 +377,52 @@ func (s *impawnUnit) redeemToMap() map[uint64]*big.Int {
 /////////////////////////////////////////////////////////////////////////////////
 
 type DelegationAccount struct {
-	SaAddress common.Address
-	Unit      *impawnUnit
+	SaAddress     common.Address
+	Unit          *impawnUnit
+	Redelegations []*Redelegation // the redelegations out of it pending and into it in flight
+}
+
+// Redelegation moves the delegation from a staking account to another at the
+// beginning of the epoch, the stake stays in flight for MaxRedeemHeight after
+// it and can't be redelegated again or escape the slashing of the source.
+type Redelegation struct {
+	From    common.Address // the staking account the delegation leaves
+	To      common.Address // the staking account the delegation joins
+	Amount  *big.Int
+	Height  uint64 // the height of the request
+	EpochID uint64 // the epoch it takes effect
+}
+
+func (r *Redelegation) clone() *Redelegation {
+	rr := *r
+	rr.Amount = new(big.Int).Set(r.Amount)
+	return &rr
+}
+func (r *Redelegation) effectHeight() uint64 {
+	return types.GetEpochFromID(r.EpochID).BeginHeight
+}
+
+// isInFlight returns whether the redelegation is pending or not matured at the height.
+func (r *Redelegation) isInFlight(hh uint64) bool {
+	return hh <= r.effectHeight()+params.MaxRedeemHeight
 }
 
 func (d *DelegationAccount) update(da *DelegationAccount, move bool) {
 	d.Unit.update(da.Unit, move)
+	if move {
+		d.Redelegations = append(d.Redelegations, da.Redelegations...)
+	}
+}
+
+// redelegating returns the amount of the pending redelegations out of the account.
+func (d *DelegationAccount) redelegating(epochid uint64) *big.Int {
+	all := big.NewInt(0)
+	for _, v := range d.Redelegations {
+		if v.From == d.SaAddress && v.EpochID == epochid {
+			all.Add(all, v.Amount)
+		}
+	}
+	return all
 }
 func (s *DelegationAccount) getAllStaking(hh uint64) *big.Int {
 	return s.Unit.getAllStaking(hh)
@@ -335,6 +431,13 @@ func (s *DelegationAccount) getValidStaking(hh uint64) *big.Int {
 	return s.Unit.getValidStaking(hh)
 }
 func (s *DelegationAccount) stopStakingInfo(amount, lastHeight *big.Int) error {
+	// the stake redelegated in the next epoch can't be canceled
+	e := types.GetEpochFromHeight(lastHeight.Uint64())
+	if pending := s.redelegating(e.EpochID + 1); pending.Sign() > 0 {
+		if new(big.Int).Add(amount, pending).Cmp(s.getValidStaking(lastHeight.Uint64())) > 0 {
+			return types.ErrAmountOver
+		}
+	}
 	return s.Unit.stopStakingInfo(amount, lastHeight)
 }
 func (s *DelegationAccount) redeeming(hh uint64, amount *big.Int) (common.Address, *big.Int, error) {
@@ -347,10 +450,14 @@ func (s *DelegationAccount) merge(epochid, hh uint64) {
 	s.Unit.merge(epochid, hh)
 }
 func (s *DelegationAccount) clone() *DelegationAccount {
-	return &DelegationAccount{
+	da := &DelegationAccount{
 		SaAddress: s.SaAddress,
 		Unit:      s.Unit.clone(),
 	}
+	for _, v := range s.Redelegations {
+		da.Redelegations = append(da.Redelegations, v.clone())
+	}
+	return da
 }
 func (s *DelegationAccount) isValid() bool {
 	return s.Unit.isValid()
@@ -363,12 +470,56 @@ type StakingAccount struct {
 	Committee  bool
 	Delegation []*DelegationAccount
 	Modify     *AlterableInfo
+	Profile    *ValidatorProfile // nil until set by the staking account
 }
 type AlterableInfo struct {
 	Fee        *big.Int
 	VotePubkey []byte
 }
 
+const (
+	// MaxMonikerLength is the max length of the moniker of a validator profile
+	MaxMonikerLength = 64
+	// MaxWebsiteLength is the max length of the website of a validator profile
+	MaxWebsiteLength = 140
+)
+
+// ValidatorProfile is the on-chain identity and the fee policy of a staking
+// account, the fee policy can be set only once.
+type ValidatorProfile struct {
+	Moniker      string
+	Website      string
+	ContactHash  common.Hash // hash of the contact info kept off the chain
+	FeePolicy    bool        // whether MaxFee and MaxFeeChange are set
+	MaxFee       *big.Int    // the fee can't be set above it
+	MaxFeeChange *big.Int    // the fee can't change more than it in an epoch
+}
+
+func (p *ValidatorProfile) clone() *ValidatorProfile {
+	pp := *p
+	if p.MaxFee != nil {
+		pp.MaxFee = new(big.Int).Set(p.MaxFee)
+	}
+	if p.MaxFeeChange != nil {
+		pp.MaxFeeChange = new(big.Int).Set(p.MaxFeeChange)
+	}
+	return &pp
+}
+
+// checkFee returns an error if the fee policy forbids to change cur to fee.
+func (p *ValidatorProfile) checkFee(cur, fee *big.Int) error {
+	if !p.FeePolicy {
+		return nil
+	}
+	if fee.Cmp(p.MaxFee) > 0 {
+		return types.ErrFeeOverMax
+	}
+	if new(big.Int).Abs(new(big.Int).Sub(fee, cur)).Cmp(p.MaxFeeChange) > 0 {
+		return types.ErrFeeChangeOverMax
+	}
+	return nil
+}
+
 func (s *StakingAccount) isInCommittee() bool {
 	return s.Committee
 }
@@ -394,6 +545,20 @@ func (s *StakingAccount) updatePk(height uint64, pk []byte) {
 		s.Modify.VotePubkey = types.CopyVotePk(pk)
 	}
 }
+func (s *StakingAccount) getProfile() *ValidatorProfile {
+	if s.Profile == nil {
+		s.Profile = &ValidatorProfile{MaxFee: big.NewInt(0), MaxFeeChange: big.NewInt(0)}
+	}
+	return s.Profile
+}
+
+// pendingFee returns the fee applied in the next epoch, nil if it's not changed.
+func (s *StakingAccount) pendingFee() *big.Int {
+	if s.Modify == nil || s.Modify.Fee == nil || s.Modify.Fee.Cmp(types.InvalidFee) == 0 {
+		return nil
+	}
+	return s.Modify.Fee
+}
 func (s *StakingAccount) update(sa *StakingAccount, hh uint64, next, move bool) {
 	s.Unit.update(sa.Unit, move)
 	dirty := false
@@ -415,6 +580,9 @@ func (s *StakingAccount) update(sa *StakingAccount, hh uint64, next, move bool)
 			s.Modify.VotePubkey = types.CopyVotePk(sa.Modify.VotePubkey)
 		}
 	}
+	if sa.Profile != nil {
+		s.Profile = sa.Profile.clone()
+	}
 	if next {
 		s.changeAlterableInfo()
 	}
@@ -517,6 +685,9 @@ func (s *StakingAccount) clone() *StakingAccount {
 			ss.Modify.VotePubkey = types.CopyVotePk(s.Modify.VotePubkey)
 		}
 	}
+	if s.Profile != nil {
+		ss.Profile = s.Profile.clone()
+	}
 	return ss
 }
 func (s *StakingAccount) isvalid() bool {
@@ -596,6 +767,16 @@ type ImpawnImpl struct {
 	accounts   map[uint64]SAImpawns // key is epoch id,value is SA set
 	curEpochID uint64               // the new epochid of the current state
 	lastReward uint64               // the curnent reward height block
+	slashes    []*SlashRecord       // the double sign offences slashed recently
+}
+
+// SlashRecord is the record of a double sign offence slashed in the state.
+type SlashRecord struct {
+	Offender     common.Address // the committee base of the offender
+	Owner        common.Address // the staking account of the offender, empty if it isn't staking
+	Height       uint64         // the height of the offence
+	Amount       *big.Int       // the amount burned from the staking account and its delegations
+	ExcludeEpoch uint64         // the epoch the staking account is excluded from
 }
 
 func NewImpawnImpl() *ImpawnImpl {
@@ -615,6 +796,11 @@ func CloneImpawnImpl(ori *ImpawnImpl) *ImpawnImpl {
 		lastReward: ori.lastReward,
 		accounts:   make(map[uint64]SAImpawns),
 	}
+	for _, v := range ori.slashes {
+		vv := *v
+		vv.Amount = new(big.Int).Set(v.Amount)
+		tmp.slashes = append(tmp.slashes, &vv)
+	}
 	for k, val := range ori.accounts {
 		items := SAImpawns{}
 		for _, v := range val {
@@ -900,10 +1086,75 @@ func (i *ImpawnImpl) move(prev, next, effectHeight uint64) error {
 			nextInfos.update(vv, nextEpoch.BeginHeight, true, true, effectHeight)
 		}
 	}
+	i.applyRedelegations(nextInfos, next)
 	i.accounts[next] = nextInfos
 	return nil
 }
 
+// applyRedelegations moves the delegations redelegated in the next epoch to
+// their new staking accounts and drops the redelegations matured, called by
+// move after the accounts merged.
+func (i *ImpawnImpl) applyRedelegations(infos SAImpawns, next uint64) {
+	type pending struct {
+		sa *StakingAccount
+		da *DelegationAccount
+		r  *Redelegation
+	}
+	var items []pending
+	begin := types.GetEpochFromID(next).BeginHeight
+	for _, sa := range infos {
+		for _, da := range sa.Delegation {
+			var rs []*Redelegation
+			for _, v := range da.Redelegations {
+				if v.From == da.SaAddress && v.EpochID == next {
+					items = append(items, pending{sa, da, v})
+				} else if v.To == da.SaAddress && v.EpochID < next && v.isInFlight(begin) {
+					rs = append(rs, v)
+				}
+			}
+			da.Redelegations = rs
+		}
+	}
+	for _, item := range items {
+		r, addr := item.r, item.da.Unit.Address
+		to := infos.getSA(r.To)
+		if to == nil {
+			log.Warn("Redelegate to the staking account left", "from", r.From, "to", r.To, "delegator", addr, "amount", r.Amount)
+			continue
+		}
+		r.Amount = item.da.Unit.sub(next, r.Amount)
+		unit := &impawnUnit{
+			Address: addr,
+			Value: []*PairstakingValue{&PairstakingValue{
+				Amount: new(big.Int).Set(r.Amount),
+				Height: new(big.Int).SetUint64(begin),
+				State:  types.StateStakingAuto,
+			}},
+			RedeemInof: make([]*RedeemItem, 0),
+		}
+		if da := to.getDA(addr); da == nil {
+			to.Delegation = append(to.Delegation, &DelegationAccount{
+				SaAddress:     r.To,
+				Unit:          unit,
+				Redelegations: []*Redelegation{r},
+			})
+		} else {
+			da.Unit.update(unit, false)
+			da.Redelegations = append(da.Redelegations, r)
+		}
+		if !item.da.isValid() {
+			das := make([]*DelegationAccount, 0, len(item.sa.Delegation))
+			for _, da := range item.sa.Delegation {
+				if da != item.da {
+					das = append(das, da)
+				}
+			}
+			item.sa.Delegation = das
+		}
+		log.Info("Apply redelegation", "from", r.From, "to", r.To, "delegator", addr, "amount", r.Amount, "epoch", next)
+	}
+}
+
 /////////////////////////////////////////////////////////////////////////////////
 ////////////// external function //////////////////////////////////////////
 
@@ -922,18 +1173,9 @@ func (i *ImpawnImpl) DoElections(epochid, height uint64) ([]*StakingAccount, err
 		eid = eid - 1
 	}
 	if val, ok := i.accounts[eid]; ok {
-		val.sort(height, true)
-		var ee []*StakingAccount
-		for _, v := range val {
-			validStaking := v.getValidStakingOnly(height)
-			if validStaking.Cmp(params.ElectionMinLimitForStaking) < 0 {
-				continue
-			}
+		ee := i.electCandidates(val, epochid, height, params.CountInEpoch)
+		for _, v := range ee {
 			v.Committee = true
-			ee = append(ee, v)
-			if len(ee) >= params.CountInEpoch {
-				break
-			}
 		}
 		return ee, nil
 	} else {
@@ -941,6 +1183,149 @@ func (i *ImpawnImpl) DoElections(epochid, height uint64) ([]*StakingAccount, err
 	}
 }
 
+// electCandidates ranks the staking accounts eligible for the committee of the
+// epoch by the valid staking at the height, at most limit accounts are returned.
+func (i *ImpawnImpl) electCandidates(val SAImpawns, epochid, height uint64, limit int) []*StakingAccount {
+	val.sort(height, true)
+	var ee []*StakingAccount
+	for _, v := range val {
+		validStaking := v.getValidStakingOnly(height)
+		if validStaking.Cmp(params.ElectionMinLimitForStaking) < 0 {
+			continue
+		}
+		if i.isExcluded(v.Unit.Address, epochid) {
+			log.Info("Exclude slashed staking account from election", "address", v.Unit.Address, "epoch", epochid)
+			continue
+		}
+		ee = append(ee, v)
+		if len(ee) >= limit {
+			break
+		}
+	}
+	return ee
+}
+
+// SimulateElection projects the election of the next committee on a copy of
+// the state, with the hypothetical stakes applied at the height. The valid
+// staking is counted at the election point of the current epoch, or at the
+// height if it's passed. The rank of the target and the staking it needs to
+// be elected are reported too if it's set.
+func (i *ImpawnImpl) SimulateElection(height uint64, stakes []*types.ElectionStake, target common.Address) (*types.ElectionSimulation, error) {
+	tmp := CloneImpawnImpl(i)
+	for _, v := range stakes {
+		if err := tmp.applyElectionStake(height, v); err != nil {
+			return nil, err
+		}
+	}
+	cur := types.GetEpochFromID(tmp.curEpochID)
+	electHeight := height
+	if cur.EndHeight >= electHeight+params.ElectionPoint {
+		electHeight = cur.EndHeight - params.ElectionPoint
+	}
+	epochid := tmp.curEpochID + 1
+	eid := epochid
+	if eid >= params.FirstNewEpochID {
+		eid = eid - 1
+	}
+	val, ok := tmp.accounts[eid]
+	if !ok {
+		return nil, types.ErrMatchEpochID
+	}
+	ee := tmp.electCandidates(val, epochid, electHeight, len(val))
+
+	res := &types.ElectionSimulation{
+		EpochID:    epochid,
+		Height:     electHeight,
+		Candidates: len(ee),
+		MinStake:   new(big.Int).Set(params.ElectionMinLimitForStaking),
+	}
+	var tc *types.ElectionCandidate
+	for k, v := range ee {
+		item := &types.ElectionCandidate{
+			Address:      v.Unit.Address,
+			Rank:         k + 1,
+			Staking:      v.getValidStakingOnly(electHeight),
+			ValidStaking: v.getValidStaking(electHeight),
+			Elected:      k < params.CountInEpoch,
+		}
+		if item.Elected {
+			res.Committee = append(res.Committee, item)
+		}
+		if v.Unit.Address == target {
+			tc = item
+		}
+	}
+	if len(res.Committee) >= params.CountInEpoch {
+		last := res.Committee[len(res.Committee)-1]
+		res.MinStake = new(big.Int).Add(last.ValidStaking, big.NewInt(1))
+	}
+	if target == (common.Address{}) {
+		return res, nil
+	}
+	if tc == nil {
+		tc = &types.ElectionCandidate{
+			Address:      target,
+			Staking:      big.NewInt(0),
+			ValidStaking: big.NewInt(0),
+			Excluded:     tmp.isExcluded(target, epochid),
+		}
+		if sa := val.getSA(target); sa != nil {
+			tc.Staking = sa.getValidStakingOnly(electHeight)
+			tc.ValidStaking = sa.getValidStaking(electHeight)
+		}
+	}
+	if !tc.Excluded {
+		tc.Shortfall = electionShortfall(tc, res.MinStake)
+	}
+	res.Target = tc
+	return res, nil
+}
+
+// electionShortfall returns the staking the candidate has to append to take a
+// seat, appending to its own staking meets the limit of the staking itself too.
+func electionShortfall(c *types.ElectionCandidate, minStake *big.Int) *big.Int {
+	if c.Elected {
+		return big.NewInt(0)
+	}
+	own := new(big.Int).Sub(params.ElectionMinLimitForStaking, c.Staking)
+	if own.Sign() < 0 {
+		own.SetInt64(0)
+	}
+	all := new(big.Int).Sub(minStake, c.ValidStaking)
+	if all.Cmp(own) < 0 {
+		return own
+	}
+	return all
+}
+
+// applyElectionStake applies a hypothetical stake of an election simulation,
+// a deposit of an account not staking yet needs no vote public key.
+func (i *ImpawnImpl) applyElectionStake(height uint64, s *types.ElectionStake) error {
+	if s.Amount == nil || s.Amount.Sign() <= 0 {
+		return types.ErrInvalidParam
+	}
+	if s.Validator != nil {
+		return i.InsertDAccount2(height, *s.Validator, s.Address, s.Amount)
+	}
+	if _, err := i.GetStakingAccount(i.curEpochID, s.Address); err == nil {
+		return i.AppendSAAmount(height, s.Address, s.Amount)
+	}
+	sa := &StakingAccount{
+		Fee: big.NewInt(0),
+		Unit: &impawnUnit{
+			Address: s.Address,
+			Value: []*PairstakingValue{&PairstakingValue{
+				Amount: new(big.Int).Set(s.Amount),
+				Height: new(big.Int).SetUint64(height),
+				State:  types.StateStakingAuto,
+			}},
+			RedeemInof: make([]*RedeemItem, 0),
+		},
+		Modify: &AlterableInfo{},
+	}
+	return i.insertSAccount(height, sa)
+}
+
 // Shift will move the staking account which has election flag to the next epoch
 // it will be save the whole state in the current epoch end block after it called by consensus
 func (i *ImpawnImpl) Shift(epochid, effectHeight uint64) error {
@@ -956,11 +1341,143 @@ func (i *ImpawnImpl) Shift(epochid, effectHeight uint64) error {
 	if epochid != i.getCurrentEpoch()+1 {
 		return types.ErrOverEpochID
 	}
+	i.pruneSlashes(epochid)
 	i.SetCurrentEpoch(epochid)
 	prev := epochid - 1
 	return i.move(prev, epochid, effectHeight)
 }
 
+// Slash burns SlashFractionDoubleSign percent of the staking of the committee
+// member who signed conflicting votes at the height and of its delegations,
+// the staking account is excluded from the next election. It returns the
+// burned amount of every address, the caller must take it from the locked
+// balance of the address.
+func (i *ImpawnImpl) Slash(height uint64, pk []byte) (map[common.Address]*big.Int, error) {
+	pubkey, err := crypto.UnmarshalPubkey(pk)
+	if err != nil {
+		return nil, err
+	}
+	offender := crypto.PubkeyToAddress(*pubkey)
+	if i.IsSlashed(pk, height) {
+		return nil, types.ErrEvidenceSlashed
+	}
+	record := &SlashRecord{
+		Offender:     offender,
+		Height:       height,
+		Amount:       big.NewInt(0),
+		ExcludeEpoch: i.curEpochID + 1,
+	}
+	if cur := types.GetEpochFromID(i.curEpochID); cur != nil && height > cur.EndHeight-params.ElectionPoint {
+		// the election of the next epoch is done already
+		record.ExcludeEpoch++
+	}
+	amounts := make(map[common.Address]*big.Int)
+	for _, sa := range i.accounts[i.curEpochID] {
+		if !bytes.Equal(sa.Votepubkey, pk) {
+			continue
+		}
+		record.Owner = sa.Unit.Address
+		units := []*impawnUnit{sa.Unit}
+		for _, da := range sa.Delegation {
+			units = append(units, da.Unit)
+		}
+		for _, unit := range units {
+			amount := unit.slash(i.curEpochID, params.SlashFractionDoubleSign)
+			record.add(amounts, unit.Address, amount)
+		}
+		// the pending redelegations were cut with the delegations
+		for _, da := range sa.Delegation {
+			for _, v := range da.Redelegations {
+				if v.From == sa.Unit.Address && v.EpochID > i.curEpochID {
+					v.Amount.Sub(v.Amount, slashFraction(v.Amount))
+				}
+			}
+		}
+		break
+	}
+	if record.Owner != (common.Address{}) {
+		// the stake redelegated after the offence is slashed in its new staking account
+		for _, sa := range i.accounts[i.curEpochID] {
+			for _, da := range sa.Delegation {
+				for _, v := range da.Redelegations {
+					if v.From == record.Owner && v.To == da.SaAddress && height < v.effectHeight() {
+						amount := da.Unit.sub(i.curEpochID, slashFraction(v.Amount))
+						v.Amount.Sub(v.Amount, amount)
+						record.add(amounts, da.Unit.Address, amount)
+					}
+				}
+			}
+		}
+	}
+	i.slashes = append(i.slashes, record)
+	log.Info("Slash double sign offender", "offender", offender, "owner", record.Owner, "height", height,
+		"amount", record.Amount, "exclude", record.ExcludeEpoch)
+	return amounts, nil
+}
+
+func (r *SlashRecord) add(amounts map[common.Address]*big.Int, addr common.Address, amount *big.Int) {
+	if amount.Sign() == 0 {
+		return
+	}
+	if v, ok := amounts[addr]; ok {
+		v.Add(v, amount)
+	} else {
+		amounts[addr] = amount
+	}
+	r.Amount.Add(r.Amount, amount)
+}
+
+func slashFraction(amount *big.Int) *big.Int {
+	c := new(big.Int).Mul(amount, new(big.Int).SetUint64(params.SlashFractionDoubleSign))
+	return c.Div(c, big.NewInt(100))
+}
+
+// IsSlashed returns whether the double sign of the committee member at the
+// height was slashed already.
+func (i *ImpawnImpl) IsSlashed(pk []byte, height uint64) bool {
+	pubkey, err := crypto.UnmarshalPubkey(pk)
+	if err != nil {
+		return false
+	}
+	offender := crypto.PubkeyToAddress(*pubkey)
+	for _, v := range i.slashes {
+		if v.Offender == offender && v.Height == height {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSlashRecords returns the double sign offences slashed recently.
+func (i *ImpawnImpl) GetSlashRecords() []*SlashRecord {
+	return i.slashes
+}
+
+func (i *ImpawnImpl) isExcluded(addr common.Address, epochid uint64) bool {
+	for _, v := range i.slashes {
+		if v.Owner == addr && v.ExcludeEpoch == epochid {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneSlashes drops the records which can't exclude an account or be matched
+// by an evidence anymore.
+func (i *ImpawnImpl) pruneSlashes(epochid uint64) {
+	e := types.GetEpochFromID(epochid)
+	if e == nil {
+		return
+	}
+	var slashes []*SlashRecord
+	for _, v := range i.slashes {
+		if v.ExcludeEpoch >= epochid || v.Height+params.MaxEvidenceAge >= e.BeginHeight {
+			slashes = append(slashes, v)
+		}
+	}
+	i.slashes = slashes
+}
+
 // CancelSAccount cancel amount of asset for staking account,it will be work in next epoch
 func (i *ImpawnImpl) CancelSAccount(curHeight uint64, addr common.Address, amount *big.Int) error {
 	if amount.Sign() <= 0 || curHeight <= 0 {
@@ -1045,6 +1562,69 @@ func (i *ImpawnImpl) RedeemDAccount(curHeight uint64, addrSA, addrDA common.Addr
 	return i.redeemByDa(da, curHeight, amount)
 }
 
+// RedelegateDAccount moves amount of the delegation from the staking account
+// addrFrom to addrTo in the next epoch, without the redeem lock. The stake
+// redelegated to addrFrom recently can't be redelegated again.
+func (i *ImpawnImpl) RedelegateDAccount(curHeight uint64, addrFrom, addrTo, addrDA common.Address, amount *big.Int) error {
+	if amount.Sign() <= 0 || curHeight <= 0 {
+		return types.ErrInvalidParam
+	}
+	if bytes.Equal(addrFrom.Bytes(), addrTo.Bytes()) {
+		return types.ErrRedelegateSame
+	}
+	if bytes.Equal(addrTo.Bytes(), addrDA.Bytes()) {
+		return types.ErrDelegationSelf
+	}
+	curEpoch := types.GetEpochFromHeight(curHeight)
+	if curEpoch == nil || curEpoch.EpochID != i.curEpochID {
+		return types.ErrInvalidParam
+	}
+	sa, err := i.GetStakingAccount(curEpoch.EpochID, addrFrom)
+	if err != nil {
+		return err
+	}
+	if _, err := i.GetStakingAccount(curEpoch.EpochID, addrTo); err != nil {
+		return err
+	}
+	da := sa.getDA(addrDA)
+	if da == nil {
+		log.Error("RedelegateDAccount error", "height", curHeight, "SA", addrFrom.String(), "DA", addrDA.String())
+		return types.ErrNotDelegation
+	}
+	for _, r := range i.getRedelegations(addrDA, curHeight) {
+		if r.To == addrFrom {
+			return types.ErrRedelegateChained
+		}
+	}
+	left := new(big.Int).Sub(da.getValidStaking(curHeight), da.redelegating(curEpoch.EpochID+1))
+	if left.Cmp(amount) < 0 {
+		return types.ErrAmountOver
+	}
+	da.Redelegations = append(da.Redelegations, &Redelegation{
+		From:    addrFrom,
+		To:      addrTo,
+		Amount:  new(big.Int).Set(amount),
+		Height:  curHeight,
+		EpochID: curEpoch.EpochID + 1,
+	})
+	return nil
+}
+
+// getRedelegations returns the redelegations of the delegator in flight at the height.
+func (i *ImpawnImpl) getRedelegations(addrDA common.Address, height uint64) []*Redelegation {
+	var rs []*Redelegation
+	for _, sa := range i.accounts[i.curEpochID] {
+		if da := sa.getDA(addrDA); da != nil {
+			for _, r := range da.Redelegations {
+				if r.isInFlight(height) {
+					rs = append(rs, r)
+				}
+			}
+		}
+	}
+	return rs
+}
+
 func (i *ImpawnImpl) insertDAccount(height uint64, da *DelegationAccount) error {
 	if da == nil {
 		return types.ErrInvalidParam
@@ -1186,9 +1766,68 @@ func (i *ImpawnImpl) UpdateSAFee(height uint64, addr common.Address, fee *big.In
 	if err != nil {
 		return err
 	}
+	if sa.Profile != nil {
+		if err := sa.Profile.checkFee(sa.Fee, fee); err != nil {
+			return err
+		}
+	}
 	sa.updateFee(height, fee)
 	return nil
 }
+
+// SetSAProfile sets the moniker, website and contact hash of the staking account.
+func (i *ImpawnImpl) SetSAProfile(height uint64, addr common.Address, moniker, website string, contactHash common.Hash) error {
+	if len(moniker) > MaxMonikerLength || len(website) > MaxWebsiteLength {
+		return types.ErrInvalidParam
+	}
+	epochInfo := types.GetEpochFromHeight(height)
+	if epochInfo.EpochID > i.getCurrentEpoch() {
+		log.Info("SetSAProfile", "eid", epochInfo.EpochID, "height", height, "eid2", i.getCurrentEpoch())
+		return types.ErrOverEpochID
+	}
+	sa, err := i.GetStakingAccount(epochInfo.EpochID, addr)
+	if err != nil {
+		return err
+	}
+	profile := sa.getProfile()
+	profile.Moniker, profile.Website, profile.ContactHash = moniker, website, contactHash
+	return nil
+}
+
+// SetSAFeePolicy sets the max fee and the max fee change per epoch of the
+// staking account. The policy can't be changed once set, the current fee and
+// the fee of the next epoch must conform to it.
+func (i *ImpawnImpl) SetSAFeePolicy(height uint64, addr common.Address, maxFee, maxFeeChange *big.Int) error {
+	if maxFee.Sign() < 0 || maxFee.Cmp(types.Base) > 0 || maxFeeChange.Sign() < 0 || maxFeeChange.Cmp(maxFee) > 0 {
+		return types.ErrInvalidParam
+	}
+	epochInfo := types.GetEpochFromHeight(height)
+	if epochInfo.EpochID > i.getCurrentEpoch() {
+		log.Info("SetSAFeePolicy", "eid", epochInfo.EpochID, "height", height, "eid2", i.getCurrentEpoch())
+		return types.ErrOverEpochID
+	}
+	sa, err := i.GetStakingAccount(epochInfo.EpochID, addr)
+	if err != nil {
+		return err
+	}
+	if sa.Profile != nil && sa.Profile.FeePolicy {
+		return types.ErrFeePolicySet
+	}
+	policy := &ValidatorProfile{FeePolicy: true, MaxFee: maxFee, MaxFeeChange: maxFeeChange}
+	if sa.Fee.Cmp(maxFee) > 0 {
+		return types.ErrFeeOverMax
+	}
+	if fee := sa.pendingFee(); fee != nil {
+		if err := policy.checkFee(sa.Fee, fee); err != nil {
+			return err
+		}
+	}
+	profile := sa.getProfile()
+	profile.FeePolicy = true
+	profile.MaxFee = new(big.Int).Set(maxFee)
+	profile.MaxFeeChange = new(big.Int).Set(maxFeeChange)
+	return nil
+}
 func (i *ImpawnImpl) UpdateSAPK(height uint64, addr common.Address, pk []byte) error {
 	if height < 0 {
 		return types.ErrInvalidParam
@@ -1392,6 +2031,7 @@ func (i *ImpawnImpl) Load(state StateDB, preAddress common.Address) error {
 	}
 	// log.Info("-----Load impawn---","len:",lenght,"count:",temp.Counts(),"cache",cache)
 	i.curEpochID, i.accounts, i.lastReward = temp.curEpochID, temp.accounts, temp.lastReward
+	i.slashes = temp.slashes
 	return nil
 }
 
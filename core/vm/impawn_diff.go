@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"bytes"
+	"sort"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+	"truechain/discovery/rlp"
+)
+
+// StakingState is a staking account of the impawn state at a height.
+type StakingState struct {
+	Staking      *hexutil.Big                    `json:"staking"`
+	ValidStaking *hexutil.Big                    `json:"validStaking"`
+	Fee          *hexutil.Big                    `json:"fee"`
+	VotePubkey   hexutil.Bytes                   `json:"votePubKey"`
+	Delegations  map[common.Address]*hexutil.Big `json:"delegations,omitempty"` // the staking of the delegation accounts
+}
+
+// StakingAccountDiff is a staking account of an epoch changed between two
+// impawn states, Pre is nil if it was added and Post is nil if it was removed.
+type StakingAccountDiff struct {
+	EpochID uint64         `json:"epochID"`
+	Address common.Address `json:"address"`
+	Pre     *StakingState  `json:"pre"`
+	Post    *StakingState  `json:"post"`
+}
+
+// DiffImpawn returns the staking accounts changed from the pre impawn state to
+// the post one, read at the height, in the order of the epochs and of the
+// accounts in them.
+func DiffImpawn(pre, post *ImpawnImpl, height uint64) []*StakingAccountDiff {
+	var epochs []uint64
+	for epochid := range post.accounts {
+		epochs = append(epochs, epochid)
+	}
+	for epochid := range pre.accounts {
+		if _, ok := post.accounts[epochid]; !ok {
+			epochs = append(epochs, epochid)
+		}
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+
+	var diffs []*StakingAccountDiff
+	for _, epochid := range epochs {
+		before := make(map[common.Address]*StakingAccount)
+		for _, sa := range pre.accounts[epochid] {
+			before[sa.Unit.Address] = sa
+		}
+		for _, sa := range post.accounts[epochid] {
+			addr := sa.Unit.Address
+			prev := before[addr]
+			delete(before, addr)
+			if prev != nil && sameStakingAccount(prev, sa) {
+				continue
+			}
+			diff := &StakingAccountDiff{EpochID: epochid, Address: addr, Post: stakingState(sa, height)}
+			if prev != nil {
+				diff.Pre = stakingState(prev, height)
+			}
+			diffs = append(diffs, diff)
+		}
+		// The accounts removed, in the order they were in
+		for _, sa := range pre.accounts[epochid] {
+			if addr := sa.Unit.Address; before[addr] != nil {
+				diffs = append(diffs, &StakingAccountDiff{EpochID: epochid, Address: addr, Pre: stakingState(sa, height)})
+			}
+		}
+	}
+	return diffs
+}
+
+// sameStakingAccount returns whether the staking accounts are encoded the same.
+func sameStakingAccount(a, b *StakingAccount) bool {
+	enca, erra := rlp.EncodeToBytes(a)
+	encb, errb := rlp.EncodeToBytes(b)
+	return erra == nil && errb == nil && bytes.Equal(enca, encb)
+}
+
+func stakingState(sa *StakingAccount, height uint64) *StakingState {
+	state := &StakingState{
+		Staking:      (*hexutil.Big)(sa.getAllStaking(height)),
+		ValidStaking: (*hexutil.Big)(sa.getValidStaking(height)),
+		Fee:          (*hexutil.Big)(sa.Fee),
+		VotePubkey:   sa.Votepubkey,
+	}
+	if len(sa.Delegation) > 0 {
+		state.Delegations = make(map[common.Address]*hexutil.Big, len(sa.Delegation))
+		for _, da := range sa.Delegation {
+			state.Delegations[da.Unit.Address] = (*hexutil.Big)(da.getAllStaking(height))
+		}
+	}
+	return state
+}
@@ -1378,3 +1378,339 @@ func TestModify(t *testing.T) {
 	print_sas(impawn.GetAllStakingAccount())
 	fmt.Println()
 }
+
+func TestImpawnImplSlash(t *testing.T) {
+	impl := NewImpawnImpl()
+	priKey, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priKey.PublicKey)
+	pub := crypto.FromECDSAPub(&priKey.PublicKey)
+	daKey, _ := crypto.GenerateKey()
+	daAddress := crypto.PubkeyToAddress(daKey.PublicKey)
+
+	amount := new(big.Int).Mul(big.NewInt(40000), big.NewInt(1e18))
+	impl.InsertSAccount2(0, 0, from, pub, amount, big.NewInt(50), true)
+	impl.InsertDAccount2(0, from, daAddress, big.NewInt(1000))
+	impl.DoElections(1, 0)
+	impl.Shift(1, 0)
+
+	amounts, err := impl.Slash(10, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := new(big.Int).Mul(big.NewInt(2000), big.NewInt(1e18))
+	if amounts[from].Cmp(want) != 0 || amounts[daAddress].Cmp(big.NewInt(50)) != 0 {
+		t.Fatalf("slashed amount mismatch: have %v %v", amounts[from], amounts[daAddress])
+	}
+	if _, err := impl.Slash(10, pub); err != types.ErrEvidenceSlashed {
+		t.Fatalf("slashed the same offence twice: %v", err)
+	}
+	if !impl.IsSlashed(pub, 10) || impl.IsSlashed(pub, 11) {
+		t.Fatal("slash record mismatch")
+	}
+
+	// the record survives the encoding and excludes the account from the election
+	data, err := rlp.EncodeToBytes(impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmp ImpawnImpl
+	if err := rlp.DecodeBytes(data, &tmp); err != nil {
+		t.Fatal(err)
+	}
+	if !tmp.IsSlashed(pub, 10) {
+		t.Fatal("slash record lost in encoding")
+	}
+	e := types.GetEpochFromID(1)
+	committee, err := tmp.DoElections(2, e.EndHeight-params.ElectionPoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(committee) != 0 {
+		t.Fatal("slashed account elected")
+	}
+}
+
+func TestValidatorProfile(t *testing.T) {
+	impl := NewImpawnImpl()
+	priKey, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(priKey.PublicKey)
+	pub := crypto.FromECDSAPub(&priKey.PublicKey)
+	value := new(big.Int).Mul(big.NewInt(20000), big.NewInt(1e18))
+	if err := impl.InsertSAccount2(0, 0, from, pub, value, big.NewInt(500), true); err != nil {
+		t.Fatal(err)
+	}
+	sa, _ := impl.GetStakingAccount(impl.getCurrentEpoch(), from)
+
+	// the accounts without a profile keep the old encoding
+	old := struct {
+		Unit       *impawnUnit
+		Votepubkey []byte
+		Fee        *big.Int
+		Committee  bool
+		Delegation []*DelegationAccount
+		Modify     *AlterableInfo
+	}{sa.Unit, sa.Votepubkey, sa.Fee, sa.Committee, sa.Delegation, sa.Modify}
+	want, _ := rlp.EncodeToBytes(old)
+	have, _ := rlp.EncodeToBytes(sa)
+	if !bytes.Equal(want, have) {
+		t.Fatalf("encoding without profile mismatch: have %x, want %x", have, want)
+	}
+
+	if err := impl.SetSAProfile(0, from, strings.Repeat("m", MaxMonikerLength+1), "", common.Hash{}); err != types.ErrInvalidParam {
+		t.Fatalf("long moniker: have %v, want %v", err, types.ErrInvalidParam)
+	}
+	if err := impl.SetSAProfile(0, from, "node", "https://truechain.pro", common.Hash{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := impl.SetSAFeePolicy(0, from, big.NewInt(400), big.NewInt(100)); err != types.ErrFeeOverMax {
+		t.Fatalf("policy below the fee: have %v, want %v", err, types.ErrFeeOverMax)
+	}
+	if err := impl.SetSAFeePolicy(0, from, big.NewInt(1000), big.NewInt(100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := impl.SetSAFeePolicy(0, from, big.NewInt(2000), big.NewInt(100)); err != types.ErrFeePolicySet {
+		t.Fatalf("policy reset: have %v, want %v", err, types.ErrFeePolicySet)
+	}
+
+	tests := []struct {
+		fee int64
+		err error
+	}{
+		{600, nil},
+		{400, nil},
+		{601, types.ErrFeeChangeOverMax},
+		{399, types.ErrFeeChangeOverMax},
+		{1100, types.ErrFeeOverMax},
+	}
+	for i, tt := range tests {
+		if err := impl.UpdateSAFee(0, from, big.NewInt(tt.fee)); err != tt.err {
+			t.Errorf("test %d: fee %d: have %v, want %v", i, tt.fee, err, tt.err)
+		}
+	}
+
+	bzs, err := rlp.EncodeToBytes(impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmp ImpawnImpl
+	if err := rlp.DecodeBytes(bzs, &tmp); err != nil {
+		t.Fatal(err)
+	}
+	sa, _ = tmp.GetStakingAccount(tmp.getCurrentEpoch(), from)
+	p := sa.Profile
+	if p == nil || p.Moniker != "node" || p.Website != "https://truechain.pro" || p.ContactHash != (common.Hash{1}) ||
+		!p.FeePolicy || p.MaxFee.Int64() != 1000 || p.MaxFeeChange.Int64() != 100 {
+		t.Fatalf("profile mismatch after decoding: %+v", p)
+	}
+}
+
+func TestRedelegation(t *testing.T) {
+	impl := NewImpawnImpl()
+	var sas []common.Address
+	var pks [][]byte
+	for i := 0; i < 3; i++ {
+		priKey, _ := crypto.GenerateKey()
+		sas = append(sas, crypto.PubkeyToAddress(priKey.PublicKey))
+		pks = append(pks, crypto.FromECDSAPub(&priKey.PublicKey))
+		if err := impl.InsertSAccount2(0, 0, sas[i], pks[i], big.NewInt(10000), big.NewInt(50), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b, c, d := sas[0], sas[1], sas[2], common.Address{'d'}
+	if err := impl.InsertDAccount2(0, a, d, big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := impl.Shift(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := impl.InsertDAccount2(5, b, d, big.NewInt(500)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to common.Address
+		amount   int64
+		err      error
+	}{
+		{a, a, 100, types.ErrRedelegateSame},
+		{a, d, 100, types.ErrDelegationSelf},
+		{c, a, 100, types.ErrNotDelegation},
+		{a, b, 1100, types.ErrAmountOver},
+		{a, b, 600, nil},
+		{b, c, 100, types.ErrRedelegateChained},
+	}
+	for i, tt := range tests {
+		if err := impl.RedelegateDAccount(10, tt.from, tt.to, d, big.NewInt(tt.amount)); err != tt.err {
+			t.Errorf("test %d: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// the stake redelegated can't be canceled
+	if err := impl.CancelDAccount(10, a, d, big.NewInt(500)); err != types.ErrAmountOver {
+		t.Fatalf("cancel the redelegated: have %v, want %v", err, types.ErrAmountOver)
+	}
+	if err := impl.CancelDAccount(10, a, d, big.NewInt(300)); err != nil {
+		t.Fatal(err)
+	}
+	if err := impl.RedelegateDAccount(10, a, c, d, big.NewInt(200)); err != types.ErrAmountOver {
+		t.Fatalf("redelegate the canceled: have %v, want %v", err, types.ErrAmountOver)
+	}
+
+	if err := impl.Shift(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	begin := types.GetEpochFromID(2).BeginHeight
+	sa, _ := impl.GetStakingAccount(2, a)
+	if have := sa.getDA(d).getAllStaking(begin); have.Int64() != 100 {
+		t.Fatalf("delegation left in %x: have %v, want 100", a, have)
+	}
+	sa, _ = impl.GetStakingAccount(2, b)
+	if have := sa.getDA(d).getAllStaking(begin); have.Int64() != 1100 {
+		t.Fatalf("delegation redelegated to %x: have %v, want 1100", b, have)
+	}
+	if err := impl.RedelegateDAccount(begin+10, b, c, d, big.NewInt(100)); err != types.ErrRedelegateChained {
+		t.Fatalf("chained redelegation: have %v, want %v", err, types.ErrRedelegateChained)
+	}
+	rs := impl.GetRedelegationsRPC(begin+10, d)
+	if len(rs) != 1 || rs[0]["to"] != b || rs[0]["pending"] != false {
+		t.Fatalf("redelegations in flight mismatch: %v", rs)
+	}
+
+	// the offence before the redelegation slashes the stake moved
+	amounts, err := impl.Slash(20, pks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5 of 100 left in a, 15 of 300 canceled and 30 of 600 redelegated
+	if have := amounts[d]; have == nil || have.Int64() != 50 {
+		t.Fatalf("slashed amount of the delegator: have %v, want 50", have)
+	}
+	if have := sa.getDA(d).getAllStaking(begin); have.Int64() != 1070 {
+		t.Fatalf("delegation redelegated after slashing: have %v, want 1070", have)
+	}
+
+	bzs, err := rlp.EncodeToBytes(impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmp ImpawnImpl
+	if err := rlp.DecodeBytes(bzs, &tmp); err != nil {
+		t.Fatal(err)
+	}
+	sa, _ = tmp.GetStakingAccount(2, b)
+	if rs := sa.getDA(d).Redelegations; len(rs) != 1 || rs[0].From != a || rs[0].Amount.Int64() != 570 {
+		t.Fatalf("redelegations mismatch after decoding: %v", rs)
+	}
+}
+
+func TestSimulateElection(t *testing.T) {
+	impl := NewImpawnImpl()
+	unit := big.NewInt(1e18)
+	var sas []common.Address
+	for i := 0; i <= params.CountInEpoch; i++ {
+		priKey, _ := crypto.GenerateKey()
+		sas = append(sas, crypto.PubkeyToAddress(priKey.PublicKey))
+		amount := new(big.Int).Add(params.ElectionMinLimitForStaking, new(big.Int).Mul(big.NewInt(int64(i)), unit))
+		if err := impl.InsertSAccount2(0, 0, sas[i], crypto.FromECDSAPub(&priKey.PublicKey), amount, big.NewInt(50), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := impl.Shift(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	last := new(big.Int).Add(params.ElectionMinLimitForStaking, unit)
+	minStake := new(big.Int).Add(last, big.NewInt(1))
+	shortfall := new(big.Int).Add(unit, big.NewInt(1))
+
+	res, err := impl.SimulateElection(10, nil, sas[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EpochID != 2 || res.Height != types.GetEpochFromID(1).EndHeight-params.ElectionPoint {
+		t.Fatalf("election: have epoch %d height %d", res.EpochID, res.Height)
+	}
+	if res.Candidates != params.CountInEpoch+1 || len(res.Committee) != params.CountInEpoch {
+		t.Fatalf("candidates: have %d/%d, want %d/%d", res.Candidates, len(res.Committee), params.CountInEpoch+1, params.CountInEpoch)
+	}
+	if res.MinStake.Cmp(minStake) != 0 {
+		t.Fatalf("min stake: have %v, want %v", res.MinStake, minStake)
+	}
+	if res.Target.Rank != params.CountInEpoch+1 || res.Target.Elected || res.Target.Shortfall.Cmp(shortfall) != 0 {
+		t.Fatalf("target: have rank %d elected %v shortfall %v", res.Target.Rank, res.Target.Elected, res.Target.Shortfall)
+	}
+
+	d, e := common.Address{'d'}, common.Address{'e'}
+	tests := []struct {
+		stake     *types.ElectionStake
+		target    common.Address
+		rank      int
+		elected   bool
+		shortfall *big.Int
+	}{
+		// append the shortfall to the own staking
+		{&types.ElectionStake{Address: sas[0], Amount: shortfall}, sas[0], params.CountInEpoch, true, big.NewInt(0)},
+		// delegate the shortfall to the account
+		{&types.ElectionStake{Address: d, Validator: &sas[0], Amount: shortfall}, sas[0], params.CountInEpoch, true, big.NewInt(0)},
+		// a new account below the limit of the staking itself
+		{&types.ElectionStake{Address: e, Amount: new(big.Int).Sub(params.ElectionMinLimitForStaking, big.NewInt(1))}, e, 0, false, new(big.Int).Add(shortfall, big.NewInt(1))},
+	}
+	for i, tt := range tests {
+		res, err := impl.SimulateElection(10, []*types.ElectionStake{tt.stake}, tt.target)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if res.Target.Rank != tt.rank || res.Target.Elected != tt.elected || res.Target.Shortfall.Cmp(tt.shortfall) != 0 {
+			t.Errorf("test %d: have rank %d elected %v shortfall %v, want %d %v %v", i, res.Target.Rank, res.Target.Elected, res.Target.Shortfall, tt.rank, tt.elected, tt.shortfall)
+		}
+	}
+	// the simulations don't touch the state
+	sa, _ := impl.GetStakingAccount(1, sas[0])
+	if have := sa.getValidStaking(10); have.Cmp(params.ElectionMinLimitForStaking) != 0 {
+		t.Fatalf("staking changed: have %v, want %v", have, params.ElectionMinLimitForStaking)
+	}
+	if _, err := impl.GetStakingAccount(1, e); err != types.ErrInvalidStaking {
+		t.Fatalf("staking account inserted: %v", err)
+	}
+	if res, _ := impl.SimulateElection(10, nil, common.Address{}); res.Target != nil {
+		t.Fatalf("target reported without an address")
+	}
+}
+
+func TestDiffImpawn(t *testing.T) {
+	params.NewEpochLength = 5
+	params.MaxRedeemHeight = 0
+	params.DposForkPoint = 20
+	impl := NewImpawnImpl()
+
+	var (
+		addrs []common.Address
+		pubs  [][]byte
+	)
+	for i := 0; i < 3; i++ {
+		priKey, _ := crypto.GenerateKey()
+		addrs = append(addrs, crypto.PubkeyToAddress(priKey.PublicKey))
+		pubs = append(pubs, crypto.FromECDSAPub(&priKey.PublicKey))
+	}
+	impl.InsertSAccount2(10, 0, addrs[0], pubs[0], big.NewInt(100), big.NewInt(0), true)
+	impl.InsertSAccount2(10, 0, addrs[1], pubs[1], big.NewInt(100), big.NewInt(0), true)
+
+	pre := CloneImpawnImpl(impl)
+	if diffs := DiffImpawn(pre, impl, 15); len(diffs) != 0 {
+		t.Fatalf("unchanged impawn diffs: %v", diffs)
+	}
+	if err := impl.AppendSAAmount(15, addrs[0], big.NewInt(50)); err != nil {
+		t.Fatal(err)
+	}
+	if err := impl.InsertSAccount2(15, 0, addrs[2], pubs[2], big.NewInt(10), big.NewInt(0), true); err != nil {
+		t.Fatal(err)
+	}
+	diffs := DiffImpawn(pre, impl, 15)
+	if len(diffs) != 2 {
+		t.Fatalf("impawn diff count mismatch: have %d, want 2", len(diffs))
+	}
+	if d := diffs[0]; d.Address != addrs[0] || d.Pre.Staking.ToInt().Int64() != 100 || d.Post.Staking.ToInt().Int64() != 150 {
+		t.Errorf("appended account mismatch: %+v, pre %+v, post %+v", d, d.Pre, d.Post)
+	}
+	if d := diffs[1]; d.Address != addrs[2] || d.Pre != nil || d.Post.Staking.ToInt().Int64() != 10 {
+		t.Errorf("inserted account mismatch: %+v", d)
+	}
+}
@@ -34,6 +34,8 @@ type StateDB interface {
 	GetUnlockedBalance(addr common.Address) *big.Int
 	GetPOSLocked(addr common.Address) *big.Int
 	SetPOSLocked(addr common.Address, value *big.Int)
+	GetSponsorSpend(payer common.Address, policy common.Hash) *big.Int
+	AddSponsorSpend(payer common.Address, policy common.Hash, amount *big.Int)
 
 	GetNonce(common.Address) uint64
 	SetNonce(common.Address, uint64)
@@ -64,6 +66,16 @@ type StateDB interface {
 	// is defined according to EIP161 (balance = nonce = code = 0).
 	Empty(common.Address) bool
 
+	PrepareAccessList(sender common.Address, dest *common.Address, precompiles []common.Address, txAccesses types.AccessList)
+	AddressInAccessList(addr common.Address) bool
+	SlotInAccessList(addr common.Address, slot common.Hash) (addressOk bool, slotOk bool)
+	// AddAddressToAccessList adds the given address to the access list. This operation is safe to perform
+	// even if the feature/fork is not active yet
+	AddAddressToAccessList(addr common.Address)
+	// AddSlotToAccessList adds the given (address,slot) to the access list. This operation is safe to perform
+	// even if the feature/fork is not active yet
+	AddSlotToAccessList(addr common.Address, slot common.Hash)
+
 	RevertToSnapshot(int)
 	Snapshot() int
 
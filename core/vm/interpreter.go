@@ -38,6 +38,9 @@ type Config struct {
 	EVMInterpreter   string // External EVM interpreter options
 
 	ExtraEips []int // Additional EIPS that are to be enabled
+
+	ExecutionMode    ExecutionMode // The way the transactions of a block are executed
+	AddressConflicts bool          // Detects the conflicts of the parallel execution by account instead of storage slot
 }
 
 // Interpreter is used to run Ethereum based contracts and will utilise the
@@ -99,6 +102,8 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	if cfg.JumpTable[STOP] == nil {
 		var jt JumpTable
 		switch {
+		case evm.chainRules.IsTIP13:
+			jt = tip13InstructionSet
 		case evm.chainRules.IsTIP11:
 			jt = yoloV1InstructionSet
 		default:
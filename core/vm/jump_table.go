@@ -51,11 +51,24 @@ type operation struct {
 var (
 	constantinopleInstructionSet = newConstantinopleInstructionSet()
 	yoloV1InstructionSet         = newYoloV1InstructionSet()
+	tip13InstructionSet          = newTIP13InstructionSet()
 )
 
 // JumpTable contains the EVM opcodes supported at a given fork.
 type JumpTable [256]*operation
 
+// newTIP13InstructionSet returns the yoloV1 instructions with the access list
+// gas, BASEFEE and PUSH0 added by the TIP13 upgrade.
+func newTIP13InstructionSet() JumpTable {
+	instructionSet := newYoloV1InstructionSet()
+
+	enable2929(&instructionSet) // Access lists for trie accesses https://eips.ethereum.org/EIPS/eip-2929
+	enable3198(&instructionSet) // BASEFEE opcode - https://eips.ethereum.org/EIPS/eip-3198
+	enable3855(&instructionSet) // PUSH0 opcode - https://eips.ethereum.org/EIPS/eip-3855
+
+	return instructionSet
+}
+
 func newYoloV1InstructionSet() JumpTable {
 	instructionSet := newIstanbulInstructionSet()
 
@@ -103,6 +103,7 @@ const (
 	GASLIMIT
 	CHAINID     OpCode = 0x46
 	SELFBALANCE OpCode = 0x47
+	BASEFEE     OpCode = 0x48
 )
 
 // 0x50 range - 'storage' and execution.
@@ -122,6 +123,7 @@ const (
 	BEGINSUB  OpCode = 0x5c
 	RETURNSUB OpCode = 0x5d
 	JUMPSUB   OpCode = 0x5e
+	PUSH0     OpCode = 0x5f
 )
 
 // 0x60 range.
@@ -283,6 +285,7 @@ var opCodeToString = map[OpCode]string{
 	GASLIMIT:    "GASLIMIT",
 	CHAINID:     "CHAINID",
 	SELFBALANCE: "SELFBALANCE",
+	BASEFEE:     "BASEFEE",
 
 	// 0x50 range - 'storage' and execution.
 	POP: "POP",
@@ -303,6 +306,7 @@ var opCodeToString = map[OpCode]string{
 	BEGINSUB:  "BEGINSUB",
 	JUMPSUB:   "JUMPSUB",
 	RETURNSUB: "RETURNSUB",
+	PUSH0:     "PUSH0",
 
 	// 0x60 range - push.
 	PUSH1:  "PUSH1",
@@ -456,6 +460,7 @@ var stringToOp = map[string]OpCode{
 	"DIFFICULTY":     DIFFICULTY,
 	"GASLIMIT":       GASLIMIT,
 	"SELFBALANCE":    SELFBALANCE,
+	"BASEFEE":        BASEFEE,
 	"POP":            POP,
 	"MLOAD":          MLOAD,
 	"MSTORE":         MSTORE,
@@ -471,6 +476,7 @@ var stringToOp = map[string]OpCode{
 	"BEGINSUB":       BEGINSUB,
 	"RETURNSUB":      RETURNSUB,
 	"JUMPSUB":        JUMPSUB,
+	"PUSH0":          PUSH0,
 	"PUSH1":          PUSH1,
 	"PUSH2":          PUSH2,
 	"PUSH3":          PUSH3,
@@ -413,6 +413,65 @@ func TestReturnSubShallow(t *testing.T) {
 	}
 }
 
+// Tests that PUSH0, BASEFEE and the access list gas are enabled from the TIP13
+// upgrade on.
+func TestTIP13Fork(t *testing.T) {
+	config := *params.AllMinervaProtocolChanges
+	config.TIP13 = &params.BlockConfig{FastNumber: big.NewInt(10)}
+
+	// Returns PUSH0 + BASEFEE
+	opcodes := []byte{
+		byte(vm.PUSH0),
+		byte(vm.BASEFEE),
+		byte(vm.ADD),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	}
+	// Loads the same slot twice, the second load is warm from the fork
+	sloads := []byte{
+		byte(vm.PUSH1), 0,
+		byte(vm.SLOAD),
+		byte(vm.POP),
+		byte(vm.PUSH1), 0,
+		byte(vm.SLOAD),
+		byte(vm.POP),
+	}
+	address := common.HexToAddress("0x0a")
+	for i, tt := range []struct {
+		number   int64
+		fail     bool
+		sloadGas uint64
+	}{
+		{number: 9, fail: true, sloadGas: 2 * (3 + params.SloadGasEIP2200 + 2)},
+		{number: 10, sloadGas: (3 + vm.ColdSloadCostEIP2929 + 2) + (3 + vm.WarmStorageReadCostEIP2929 + 2)},
+	} {
+		cfg := &Config{ChainConfig: &config, BlockNumber: big.NewInt(tt.number), BaseFee: big.NewInt(7), GasLimit: 100000}
+		ret, _, err := Execute(opcodes, nil, cfg)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: new opcodes executed before the fork", i)
+			}
+		} else if err != nil {
+			t.Errorf("test %d: new opcodes failed: %v", i, err)
+		} else if got := new(big.Int).SetBytes(ret); got.Int64() != 7 {
+			t.Errorf("test %d: return mismatch: have %v, want 7", i, got)
+		}
+
+		cfg.State, _ = state.New(common.Hash{}, state.NewDatabase(etruedb.NewMemDatabase()))
+		cfg.State.SetCode(address, sloads)
+		_, leftOverGas, err := Call(address, nil, cfg)
+		if err != nil {
+			t.Fatalf("test %d: sloads failed: %v", i, err)
+		}
+		if used := cfg.GasLimit - leftOverGas; used != tt.sloadGas {
+			t.Errorf("test %d: sload gas mismatch: have %d, want %d", i, used, tt.sloadGas)
+		}
+	}
+}
+
 // disabled -- only used for generating markdown
 func DisabledTestReturnCases(t *testing.T) {
 	cfg := &Config{
@@ -40,29 +40,53 @@ var StakingGas = map[string]uint64{
 	"delegate":         1500000,
 	"undelegate":       1500000,
 	"withdrawDelegate": 1620000,
+	"getProfile":       360000,
+	"setProfile":       2400000,
+	"setFeePolicy":     2400000,
+	"redelegate":       1500000,
 }
 
 // Staking contract ABI
 var abiPre10 abi.ABI
 var abiStaking abi.ABI
+var abiProfile abi.ABI
 
 type StakeContract struct{}
 
 func init() {
 	abiPre10, _ = abi.JSON(strings.NewReader(StakeABIJSON))
 	abiStaking, _ = abi.JSON(strings.NewReader(TIP10StakeABIJSON))
+	abiProfile, _ = abi.JSON(strings.NewReader(StakeProfileABIJSON))
 }
 
-// RunStaking execute truechain staking contract
-func RunStaking(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
-	var method *abi.Method
-
+// stakingMethodById looks up the staking contract method active at the block.
+func stakingMethodById(evm *EVM, input []byte) (*abi.Method, error) {
+	if evm.chainConfig.IsTIPStake(evm.Context.BlockNumber) {
+		if method, err := abiProfile.MethodById(input); err == nil {
+			return method, nil
+		}
+	}
 	if evm.chainConfig.IsTIP10(evm.Context.BlockNumber) {
-		method, err = abiStaking.MethodById(input)
-	} else {
-		method, err = abiPre10.MethodById(input)
+		return abiStaking.MethodById(input)
 	}
+	return abiPre10.MethodById(input)
+}
+
+// StakingMethodName returns the name of the staking contract method called by
+// the input, looked up in all the versions of the contract, or an empty string
+// if there's none.
+func StakingMethodName(input []byte) string {
+	for _, contract := range []abi.ABI{abiProfile, abiStaking, abiPre10} {
+		if method, err := contract.MethodById(input); err == nil {
+			return method.Name
+		}
+	}
+	return ""
+}
 
+// RunStaking execute truechain staking contract
+func RunStaking(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
+	method, err := stakingMethodById(evm, input)
 	if err != nil {
 		log.Error("No method found")
 		return nil, ErrExecutionReverted
@@ -100,6 +124,14 @@ func RunStaking(evm *EVM, contract *Contract, input []byte) (ret []byte, err err
 		ret, err = undelegate(evm, contract, data)
 	case "withdrawDelegate":
 		ret, err = withdrawDelegate(evm, contract, data)
+	case "getProfile":
+		ret, err = getProfile(evm, contract, data)
+	case "setProfile":
+		ret, err = setProfile(evm, contract, data)
+	case "setFeePolicy":
+		ret, err = setFeePolicy(evm, contract, data)
+	case "redelegate":
+		ret, err = redelegate(evm, contract, data)
 	default:
 		log.Warn("Staking call fallback function")
 		err = ErrStakingInvalidInput
@@ -343,6 +375,103 @@ func setPubkey(evm *EVM, contract *Contract, input []byte) (ret []byte, err erro
 	return nil, nil
 }
 
+func setProfile(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
+	args := struct {
+		Moniker     string
+		Website     string
+		ContactHash [32]byte
+	}{}
+	method, _ := abiProfile.Methods["setProfile"]
+	err = method.Inputs.Unpack(&args, input)
+	if err != nil {
+		log.Error("Unpack set profile error", "err", err)
+		return nil, ErrStakingInvalidInput
+	}
+
+	from := contract.caller.Address()
+
+	log.Info("Staking set profile", "number", evm.Context.BlockNumber.Uint64(), "address", from, "moniker", args.Moniker, "website", args.Website)
+	impawn := NewImpawnImpl()
+	err = impawn.Load(evm.StateDB, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking load error", "error", err)
+		return nil, err
+	}
+
+	err = impawn.SetSAProfile(evm.Context.BlockNumber.Uint64(), from, args.Moniker, args.Website, common.Hash(args.ContactHash))
+	if err != nil {
+		log.Error("Staking profile", "address", from, "error", err)
+		return nil, err
+	}
+
+	err = impawn.Save(evm.StateDB, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking save state error", "error", err)
+		return nil, err
+	}
+
+	event := abiProfile.Events["SetProfile"]
+	logData, err := event.Inputs.PackNonIndexed(args.Moniker, args.Website, args.ContactHash)
+	if err != nil {
+		log.Error("Pack staking log error", "error", err)
+		return nil, err
+	}
+	topics := []common.Hash{
+		event.ID,
+		common.BytesToHash(from[:]),
+	}
+	logN(evm, contract, topics, logData)
+	return nil, nil
+}
+
+func setFeePolicy(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
+	args := struct {
+		MaxFee       *big.Int
+		MaxFeeChange *big.Int
+	}{}
+	method, _ := abiProfile.Methods["setFeePolicy"]
+	err = method.Inputs.Unpack(&args, input)
+	if err != nil {
+		log.Error("Unpack set fee policy error", "err", err)
+		return nil, ErrStakingInvalidInput
+	}
+
+	from := contract.caller.Address()
+
+	log.Info("Staking set fee policy", "number", evm.Context.BlockNumber.Uint64(), "address", from, "maxFee", args.MaxFee, "maxFeeChange", args.MaxFeeChange)
+	impawn := NewImpawnImpl()
+	err = impawn.Load(evm.StateDB, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking load error", "error", err)
+		return nil, err
+	}
+
+	err = impawn.SetSAFeePolicy(evm.Context.BlockNumber.Uint64(), from, args.MaxFee, args.MaxFeeChange)
+	if err != nil {
+		log.Error("Staking fee policy", "address", from, "error", err)
+		return nil, err
+	}
+
+	err = impawn.Save(evm.StateDB, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking save state error", "error", err)
+		return nil, err
+	}
+
+	event := abiProfile.Events["SetFeePolicy"]
+	logData, err := event.Inputs.PackNonIndexed(args.MaxFee, args.MaxFeeChange)
+	if err != nil {
+		log.Error("Pack staking log error", "error", err)
+		return nil, err
+	}
+	topics := []common.Hash{
+		event.ID,
+		common.BytesToHash(from[:]),
+	}
+	logN(evm, contract, topics, logData)
+	return nil, nil
+}
+
 // delegate
 func delegate(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
 	args := struct {
@@ -457,6 +586,57 @@ func undelegate(evm *EVM, contract *Contract, input []byte) (ret []byte, err err
 	return nil, nil
 }
 
+// redelegate moves the delegation to another staking account in the next epoch
+func redelegate(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
+	args := struct {
+		Holder common.Address
+		To     common.Address
+		Value  *big.Int
+	}{}
+
+	method, _ := abiProfile.Methods["redelegate"]
+	err = method.Inputs.Unpack(&args, input)
+	if err != nil {
+		log.Error("Unpack redelegate error", "err", err)
+		return nil, ErrStakingInvalidInput
+	}
+	from := contract.caller.Address()
+
+	log.Info("Staking redelegate", "number", evm.Context.BlockNumber.Uint64(), "address", from, "holder", args.Holder, "to", args.To, "value", args.Value)
+	impawn := NewImpawnImpl()
+	err = impawn.Load(evm.StateDB, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking load error", "error", err)
+		return nil, err
+	}
+	err = impawn.RedelegateDAccount(evm.Context.BlockNumber.Uint64(), args.Holder, args.To, from, args.Value)
+	if err != nil {
+		log.Error("Staking redelegate", "address", from, "value", args.Value, "error", err)
+		return nil, err
+	}
+
+	err = impawn.Save(evm.StateDB, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking save state error", "error", err)
+		return nil, err
+	}
+
+	event := abiProfile.Events["Redelegate"]
+	logData, err := event.Inputs.PackNonIndexed(args.Value)
+	if err != nil {
+		log.Error("Pack staking log error", "error", err)
+		return nil, err
+	}
+	topics := []common.Hash{
+		event.ID,
+		common.BytesToHash(from[:]),
+		common.BytesToHash(args.Holder[:]),
+		common.BytesToHash(args.To[:]),
+	}
+	logN(evm, contract, topics, logData)
+	return nil, nil
+}
+
 // cancel
 func cancel(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
 	from := contract.caller.Address()
@@ -718,6 +898,47 @@ func getDelegate(evm *EVM, contract *Contract, input []byte) (ret []byte, err er
 	return ret, err
 }
 
+// getProfile returns the profile and the fee policy of a staking account
+func getProfile(evm *EVM, contract *Contract, input []byte) (ret []byte, err error) {
+	var holder common.Address
+	method, _ := abiProfile.Methods["getProfile"]
+	var (
+		moniker, website string
+		contactHash      [32]byte
+		fee              = big.NewInt(0)
+		maxFee           = new(big.Int).Set(types.Base)
+		maxFeeChange     = new(big.Int).Set(types.Base)
+	)
+
+	err = method.Inputs.Unpack(&holder, input)
+	if err != nil {
+		log.Error("Unpack get_profile input error")
+		return nil, ErrStakingInvalidInput
+	}
+
+	impawn := NewImpawnImpl()
+	err = impawn.Load(evm.StateDB, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking load error", "error", err)
+		return nil, err
+	}
+
+	sas := impawn.GetAllStakingAccount()
+	if sa := sas.getSA(holder); sa != nil {
+		fee.Set(sa.Fee)
+		if p := sa.Profile; p != nil {
+			moniker, website, contactHash = p.Moniker, p.Website, p.ContactHash
+			if p.FeePolicy {
+				maxFee.Set(p.MaxFee)
+				maxFeeChange.Set(p.MaxFeeChange)
+			}
+		}
+	}
+
+	ret, err = method.Outputs.Pack(moniker, website, contactHash, fee, maxFee, maxFeeChange)
+	return ret, err
+}
+
 // StakeABIJSON Staking Contract json abi of pre TIP10
 const StakeABIJSON = `
 [
@@ -1496,3 +1717,183 @@ const TIP10StakeABIJSON = `
   }
 ]
 `
+
+// StakeProfileABIJSON Staking Contract json abi of the validator profile
+// and redelegation methods added in TIPStake
+const StakeProfileABIJSON = `
+[
+  {
+    "name": "SetProfile",
+    "inputs": [
+      {
+        "type": "address",
+        "name": "from",
+        "indexed": true
+      },
+      {
+        "type": "string",
+        "name": "moniker",
+        "indexed": false
+      },
+      {
+        "type": "string",
+        "name": "website",
+        "indexed": false
+      },
+      {
+        "type": "bytes32",
+        "name": "contactHash",
+        "indexed": false
+      }
+    ],
+    "anonymous": false,
+    "type": "event"
+  },
+  {
+    "name": "SetFeePolicy",
+    "inputs": [
+      {
+        "type": "address",
+        "name": "from",
+        "indexed": true
+      },
+      {
+        "type": "uint256",
+        "name": "maxFee",
+        "indexed": false
+      },
+      {
+        "type": "uint256",
+        "name": "maxFeeChange",
+        "indexed": false
+      }
+    ],
+    "anonymous": false,
+    "type": "event"
+  },
+  {
+    "name": "Redelegate",
+    "inputs": [
+      {
+        "type": "address",
+        "name": "from",
+        "indexed": true
+      },
+      {
+        "type": "address",
+        "name": "holder",
+        "indexed": true
+      },
+      {
+        "type": "address",
+        "name": "to",
+        "indexed": true
+      },
+      {
+        "type": "uint256",
+        "name": "value",
+        "indexed": false
+      }
+    ],
+    "anonymous": false,
+    "type": "event"
+  },
+  {
+    "name": "redelegate",
+    "outputs": [],
+    "inputs": [
+      {
+        "type": "address",
+        "name": "holder"
+      },
+      {
+        "type": "address",
+        "name": "to"
+      },
+      {
+        "type": "uint256",
+        "name": "value"
+      }
+    ],
+    "constant": false,
+    "payable": false,
+    "type": "function"
+  },
+  {
+    "name": "setProfile",
+    "outputs": [],
+    "inputs": [
+      {
+        "type": "string",
+        "name": "moniker"
+      },
+      {
+        "type": "string",
+        "name": "website"
+      },
+      {
+        "type": "bytes32",
+        "name": "contactHash"
+      }
+    ],
+    "constant": false,
+    "payable": false,
+    "type": "function"
+  },
+  {
+    "name": "setFeePolicy",
+    "outputs": [],
+    "inputs": [
+      {
+        "type": "uint256",
+        "name": "maxFee"
+      },
+      {
+        "type": "uint256",
+        "name": "maxFeeChange"
+      }
+    ],
+    "constant": false,
+    "payable": false,
+    "type": "function"
+  },
+  {
+    "name": "getProfile",
+    "outputs": [
+      {
+        "type": "string",
+        "name": "moniker"
+      },
+      {
+        "type": "string",
+        "name": "website"
+      },
+      {
+        "type": "bytes32",
+        "name": "contactHash"
+      },
+      {
+        "type": "uint256",
+        "name": "fee"
+      },
+      {
+        "type": "uint256",
+        "name": "maxFee"
+      },
+      {
+        "type": "uint256",
+        "name": "maxFeeChange"
+      }
+    ],
+    "inputs": [
+      {
+        "type": "address",
+        "name": "holder"
+      }
+    ],
+    "constant": true,
+    "payable": false,
+    "type": "function"
+  }
+]
+`
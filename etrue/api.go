@@ -17,6 +17,7 @@
 package etrue
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
@@ -32,6 +33,7 @@ import (
 	"truechain/discovery/core/rawdb"
 	"truechain/discovery/core/state"
 	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
 	"truechain/discovery/crypto"
 	"truechain/discovery/internal/trueapi"
 	"truechain/discovery/log"
@@ -312,6 +314,14 @@ func (api *PrivateMinerAPI) GetHashRate() uint64 {
 	return uint64(api.e.Miner().HashRate())
 }
 
+// StratumWorkers returns the shares of the workers of the stratum server.
+func (api *PrivateMinerAPI) StratumWorkers() ([]miner.StratumWorker, error) {
+	if api.e.stratum == nil {
+		return nil, errors.New("stratum server not enabled")
+	}
+	return api.e.stratum.Workers(), nil
+}
+
 // PrivateAdminAPI is the collection of Truechain full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -493,6 +503,157 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs,
 	return results, nil
 }
 
+// ExecutionModeResult is the outcome of a block replayed in an execution mode.
+type ExecutionModeResult struct {
+	Mode         string       `json:"mode"`
+	Error        string       `json:"error,omitempty"`
+	GasUsed      uint64       `json:"gasUsed"`
+	Fee          *hexutil.Big `json:"fee"`
+	ReceiptsRoot common.Hash  `json:"receiptsRoot"`
+	Root         common.Hash  `json:"root"` // the state root before the block is finalized
+	Elapsed      string       `json:"elapsed"`
+	Groups       int          `json:"groups"`
+	Regroups     int          `json:"regroups"`
+	Conflicts    int          `json:"conflicts"`
+}
+
+// ReplayBlockResult is the result of a debug_replayBlock API call.
+type ReplayBlockResult struct {
+	Number       uint64                 `json:"number"`
+	Hash         common.Hash            `json:"hash"`
+	ReceiptsRoot common.Hash            `json:"receiptsRoot"` // the receipts root in the header
+	Results      []*ExecutionModeResult `json:"results"`
+	Match        bool                   `json:"match"`
+	Diffs        []string               `json:"diffs"`
+}
+
+// ReplayBlock executes the transactions of the block in the parallel and the
+// sequential modes on the state of its parent and diffs the outputs.
+func (api *PrivateDebugAPI) ReplayBlock(ctx context.Context, number rpc.BlockNumber) (*ReplayBlockResult, error) {
+	var block *types.Block
+	if number == rpc.LatestBlockNumber {
+		block = api.etrue.blockchain.CurrentBlock()
+	} else {
+		block = api.etrue.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	parent := api.etrue.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	statedb, err := api.computeStateDB(parent, defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	processor := core.NewStateProcessor(api.config, api.etrue.blockchain, api.etrue.engine)
+
+	replay := &ReplayBlockResult{
+		Number:       block.NumberU64(),
+		Hash:         block.Hash(),
+		ReceiptsRoot: block.ReceiptHash(),
+		Diffs:        []string{},
+	}
+	var executions []*core.BlockExecution
+	for _, mode := range []vm.ExecutionMode{vm.ParallelExecution, vm.SequentialExecution} {
+		db := statedb.Copy()
+		res, err := processor.Execute(block, db, vm.Config{ExecutionMode: mode})
+		if err != nil {
+			replay.Results = append(replay.Results, &ExecutionModeResult{Mode: mode.String(), Error: err.Error()})
+			replay.Diffs = append(replay.Diffs, fmt.Sprintf("%v execution failed: %v", mode, err))
+			continue
+		}
+		res.Root = db.IntermediateRoot(true)
+		executions = append(executions, res)
+
+		result := &ExecutionModeResult{
+			Mode:         mode.String(),
+			GasUsed:      res.UsedGas,
+			Fee:          (*hexutil.Big)(res.FeeAmount),
+			ReceiptsRoot: types.DeriveSha(res.Receipts),
+			Root:         res.Root,
+			Elapsed:      res.Elapsed.String(),
+			Groups:       res.Groups,
+			Regroups:     res.Regroups,
+			Conflicts:    res.Conflicts,
+		}
+		if result.ReceiptsRoot != block.ReceiptHash() {
+			replay.Diffs = append(replay.Diffs, fmt.Sprintf("%v receipts root %x != header %x", mode, result.ReceiptsRoot, block.ReceiptHash()))
+		}
+		replay.Results = append(replay.Results, result)
+	}
+	if len(executions) == 2 {
+		replay.Diffs = append(replay.Diffs, core.DiffExecutions(executions[0], executions[1])...)
+	}
+	replay.Match = len(replay.Diffs) == 0
+	return replay, nil
+}
+
+// StateDiffResult is the result of a debug_getStateDiff API call.
+type StateDiffResult struct {
+	Number   uint64                                `json:"number"`
+	Hash     common.Hash                           `json:"hash"`
+	Root     common.Hash                           `json:"root"`
+	Accounts map[common.Address]*state.AccountDiff `json:"accounts"`
+	Impawn   []*vm.StakingAccountDiff              `json:"impawn"`
+}
+
+// GetStateDiff executes the block sequentially on the state of its parent and
+// returns the accounts, the storage slots and the staking accounts it changed,
+// the rewards included, before and after it.
+func (api *PrivateDebugAPI) GetStateDiff(ctx context.Context, number rpc.BlockNumber) (*StateDiffResult, error) {
+	var block *types.Block
+	if number == rpc.LatestBlockNumber {
+		block = api.etrue.blockchain.CurrentBlock()
+	} else {
+		block = api.etrue.blockchain.GetBlockByNumber(uint64(number))
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	parent := api.etrue.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	statedb, err := api.computeStateDB(parent, defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	// Execute on a fresh copy recording the changes, the block processing
+	// elsewhere doesn't pay for the tracking
+	pre, post := statedb.Copy(), statedb.Copy()
+	post.SetTrackChanges(true)
+	processor := core.NewStateProcessor(api.config, api.etrue.blockchain, api.etrue.engine)
+	if _, _, _, _, err := processor.Process(block, post, vm.Config{ExecutionMode: vm.SequentialExecution}); err != nil {
+		return nil, err
+	}
+	if root := post.IntermediateRoot(true); root != block.Root() {
+		return nil, fmt.Errorf("block #%d state root %x != header %x", block.NumberU64(), root, block.Root())
+	}
+	result := &StateDiffResult{
+		Number:   block.NumberU64(),
+		Hash:     block.Hash(),
+		Root:     block.Root(),
+		Accounts: post.Diff(pre),
+		Impawn:   []*vm.StakingAccountDiff{},
+	}
+	// The impawn state is kept whole in the staking contract, diff it by account
+	key := common.BytesToHash(types.StakingAddress[:])
+	if bytes.Equal(pre.GetPOSState(types.StakingAddress, key), post.GetPOSState(types.StakingAddress, key)) {
+		return result, nil
+	}
+	before, after := vm.NewImpawnImpl(), vm.NewImpawnImpl()
+	if err := before.Load(pre, types.StakingAddress); err != nil {
+		return nil, err
+	}
+	if err := after.Load(post, types.StakingAddress); err != nil {
+		return nil, err
+	}
+	result.Impawn = vm.DiffImpawn(before, after, block.NumberU64())
+	return result, nil
+}
+
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
 type StorageRangeResult struct {
 	Storage storageMap   `json:"storage"`
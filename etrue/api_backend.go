@@ -26,6 +26,7 @@ import (
 	"truechain/discovery/core"
 	"truechain/discovery/core/bloombits"
 	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/snailchain"
 	"truechain/discovery/core/state"
 	"truechain/discovery/core/types"
 	"truechain/discovery/core/vm"
@@ -386,6 +387,11 @@ func (b *TrueAPIBackend) SnailPoolStats() (pending int, unVerified int) {
 	return b.etrue.SnailPool().Stats()
 }
 
+// SnailPoolDropped returns the fruits lately dropped from the snail pool
+func (b *TrueAPIBackend) SnailPoolDropped() []*snailchain.DroppedFruit {
+	return b.etrue.SnailPool().Dropped()
+}
+
 // BloomStatus returns Bloom Status
 func (b *TrueAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.etrue.bloomIndexer.Sections()
@@ -18,6 +18,7 @@
 package etrue
 
 import (
+	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"math/big"
@@ -97,6 +98,7 @@ type Truechain struct {
 	APIBackend *TrueAPIBackend
 
 	miner     *miner.Miner
+	stratum   *miner.StratumServer
 	gasPrice  *big.Int
 	etherbase common.Address
 
@@ -104,6 +106,7 @@ type Truechain struct {
 	netRPCService *trueapi.PublicNetAPI
 
 	pbftServer *tbft.Node
+	pbftDir    string // Root directory of the pbft server data (e.g. the consensus WAL)
 
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
@@ -119,9 +122,9 @@ func New(ctx *node.ServiceContext, config *Config) (*Truechain, error) {
 	if config.SyncMode == downloader.LightSync {
 		return nil, errors.New("can't run etrue.Truechain in light sync mode, use les.LightTruechain")
 	}
-	//if config.SyncMode == downloader.SnapShotSync {
-	//	return nil, errors.New("can't run etrue.Truechain in SnapShotSync sync mode, use les.LightTruechain")
-	//}
+	if config.SyncMode == downloader.SnapShotSync && config.NoPruning {
+		return nil, errors.New("can't run etrue.Truechain in SnapShotSync sync mode as an archive node, use fast sync")
+	}
 
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
@@ -157,6 +160,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Truechain, error) {
 		etherbase:      config.Etherbase,
 		bloomRequests:  make(chan chan *bloombits.Retrieval),
 		bloomIndexer:   NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms),
+		pbftDir:        ctx.ResolvePath(params.DefaultTBFTDir),
 	}
 
 	log.Info("Initialising Truechain protocol", "versions", ProtocolVersions, "network", config.NetworkId, "syncmode", config.SyncMode)
@@ -169,7 +173,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Truechain, error) {
 		rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
 	}
 	var (
-		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording, ExecutionMode: config.ExecutionMode}
 		cacheConfig = &core.CacheConfig{Deleted: config.DeletedState, Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
 	)
 
@@ -236,6 +240,11 @@ func New(ctx *node.ServiceContext, config *Config) (*Truechain, error) {
 
 	etrue.miner = miner.New(etrue, etrue.chainConfig, etrue.EventMux(), etrue.engine, etrue.election, etrue.Config().MineFruit, etrue.Config().NodeType, etrue.Config().RemoteMine, etrue.Config().Mine)
 	etrue.miner.SetExtra(makeExtraData(config.ExtraData))
+	if config.StratumAddr != "" {
+		agent := miner.NewRemoteAgent(etrue.blockchain, etrue.snailblockchain, etrue.engine)
+		etrue.miner.Register(agent)
+		etrue.stratum = miner.NewStratumServer(agent, new(big.Int).SetUint64(config.StratumDifficulty))
+	}
 
 	committeeKey, err := crypto.ToECDSA(etrue.config.CommitteeKey)
 	if err == nil {
@@ -512,6 +521,7 @@ func (s *Truechain) Start(srvr *p2p.Server) error {
 		return errors.New("start pbft server failed.")
 	}
 	s.agent.server = s.pbftServer
+	s.agent.discovery = newCommitteeDiscovery(s.agent, srvr)
 	log.Info("", "server", s.agent.server)
 	s.agent.Start()
 
@@ -520,6 +530,12 @@ func (s *Truechain) Start(srvr *p2p.Server) error {
 	//start fruit journal
 	s.snailPool.Start()
 
+	if s.stratum != nil {
+		if err := s.stratum.Start(s.config.StratumAddr); err != nil {
+			return err
+		}
+	}
+
 	// Start the networking layer and the light server if requested
 	s.protocolManager.Start2(maxPeers)
 	if s.lesServer != nil {
@@ -542,6 +558,9 @@ func (s *Truechain) Stop() error {
 	}
 	s.txPool.Stop()
 	s.snailPool.Stop()
+	if s.stratum != nil {
+		s.stratum.Stop()
+	}
 	s.miner.Stop()
 	s.eventMux.Stop()
 
@@ -552,19 +571,27 @@ func (s *Truechain) Stop() error {
 }
 
 func (s *Truechain) startPbftServer() error {
-	priv, err := crypto.ToECDSA(s.config.CommitteeKey)
-	if err != nil {
-		return err
+	// the remote signer replaces the committee key of the tbft node
+	var priv *ecdsa.PrivateKey
+	if s.agent.voteSigner == nil {
+		var err error
+		if priv, err = crypto.ToECDSA(s.config.CommitteeKey); err != nil {
+			return err
+		}
 	}
 
 	cfg := config.DefaultConfig()
 	cfg.P2P.ListenAddress1 = "tcp://0.0.0.0:" + strconv.Itoa(s.config.Port)
 	cfg.P2P.ListenAddress2 = "tcp://0.0.0.0:" + strconv.Itoa(s.config.StandbyPort)
+	cfg.Consensus.RootDir = s.pbftDir
 
 	n1, err := tbft.NewNode(cfg, "1", priv, s.agent)
 	if err != nil {
 		return err
 	}
+	if s.agent.voteSigner != nil {
+		n1.SetSigner(s.agent.voteSigner)
+	}
 	s.pbftServer = n1
 	return n1.Start()
 }
@@ -573,5 +600,8 @@ func (s *Truechain) stopPbftServer() error {
 	if s.pbftServer != nil {
 		s.pbftServer.Stop()
 	}
+	if s.agent.voteSigner != nil {
+		s.agent.voteSigner.Close()
+	}
 	return nil
 }
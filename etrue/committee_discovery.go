@@ -0,0 +1,326 @@
+package etrue
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/consensus/tbft/help"
+	"truechain/discovery/core/types"
+	"truechain/discovery/crypto"
+	"truechain/discovery/log"
+	"truechain/discovery/p2p"
+	"truechain/discovery/p2p/discv5"
+	"truechain/discovery/p2p/enode"
+	"truechain/discovery/p2p/enr"
+	"truechain/discovery/rlp"
+)
+
+const (
+	committeeSearchPeriod  = 10 * time.Second // interval of the committee topic lookups
+	committeeResolveDelay  = time.Minute      // min delay between two resolves of a node
+	committeeFallbackDelay = 30 * time.Second // delay of the first node info broadcast
+	committeeFoundSize     = 32
+)
+
+var (
+	errNoTbftEntry        = errors.New("no tbft entry in node record")
+	errNoCommitteeEntry   = errors.New("committee not in tbft entry")
+	errNotCommitteeMember = errors.New("tbft entry not signed by a committee member")
+	errNoTbftIP           = errors.New("no IP in node record")
+)
+
+// tbftEntry is the "tbft" ENR entry. It's set for the committee members and
+// holds their tbft endpoints, one per committee.
+type tbftEntry struct {
+	Committees []tbftCommittee
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e tbftEntry) ENRKey() string {
+	return "tbft"
+}
+
+// tbftCommittee is the tbft endpoint of a member in a committee. It's signed
+// with the committee key over the node ID, other nodes can't advertise it.
+type tbftCommittee struct {
+	ID    *big.Int
+	Port  uint32
+	Port2 uint32
+	Sign  []byte
+}
+
+func (c *tbftCommittee) sigHash(id enode.ID) common.Hash {
+	return types.RlpHash([]interface{}{id, c.ID, c.Port, c.Port2})
+}
+
+func (c *tbftCommittee) sign(id enode.ID, privateKey *ecdsa.PrivateKey) (err error) {
+	c.Sign, err = crypto.Sign(c.sigHash(id).Bytes(), privateKey)
+	return err
+}
+
+// signer returns the public key which signed the endpoint for the node.
+func (c *tbftCommittee) signer(id enode.ID) ([]byte, error) {
+	pubKey, err := crypto.SigToPub(c.sigHash(id).Bytes(), c.Sign)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.FromECDSAPub(pubKey), nil
+}
+
+// committeeTopic is the discv5 topic registered by the members of a committee.
+func committeeTopic(genesisHash common.Hash, committeeID *big.Int) discv5.Topic {
+	return discv5.Topic("TBFT@" + committeeID.String() + "@" + common.Bytes2Hex(genesisHash.Bytes()[0:8]))
+}
+
+// committeeNodeFromRecord returns the tbft endpoint of the committee in the
+// node record, if it's signed by a used or unused member of the committee.
+func committeeNodeFromRecord(committeeInfo *types.CommitteeInfo, n *enode.Node) (*types.CommitteeNode, error) {
+	var entry tbftEntry
+	if err := n.Load(&entry); err != nil {
+		return nil, errNoTbftEntry
+	}
+	if n.IP() == nil {
+		return nil, errNoTbftIP
+	}
+	for _, c := range entry.Committees {
+		if c.ID == nil || c.ID.Cmp(committeeInfo.Id) != 0 {
+			continue
+		}
+		pubKey, err := c.signer(n.ID())
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range committeeInfo.GetAllMembers() {
+			if !bytes.Equal(member.Publickey, pubKey) {
+				continue
+			}
+			if member.Flag != types.StateUsedFlag && member.Flag != types.StateUnusedFlag {
+				break
+			}
+			return &types.CommitteeNode{
+				IP:        n.IP().String(),
+				Port:      c.Port,
+				Port2:     c.Port2,
+				Coinbase:  member.Coinbase,
+				Publickey: pubKey,
+			}, nil
+		}
+		return nil, errNotCommitteeMember
+	}
+	return nil, errNoCommitteeEntry
+}
+
+// committeeDiscovery lets the committee members find the tbft endpoints of
+// each other. The members advertise their endpoints in the tbft ENR entry and
+// register the discv5 topic of the committee, the nodes found on the topic are
+// resolved to their records and handed to the pbft server.
+type committeeDiscovery struct {
+	agent       *PbftAgent
+	srv         *p2p.Server
+	genesisHash common.Hash
+
+	mu         sync.Mutex
+	committees map[uint64]*discoveryWork
+}
+
+type discoveryWork struct {
+	committeeInfo *types.CommitteeInfo
+	endpoint      tbftCommittee
+	found         map[string]bool // members found, by public key
+	resolved      map[enode.ID]time.Time
+	quit          chan struct{}
+}
+
+func newCommitteeDiscovery(agent *PbftAgent, srv *p2p.Server) *committeeDiscovery {
+	return &committeeDiscovery{
+		agent:       agent,
+		srv:         srv,
+		genesisHash: agent.fastChain.Genesis().Hash(),
+		committees:  make(map[uint64]*discoveryWork),
+	}
+}
+
+// start advertises the endpoint of the node in the committee and searches the
+// other members.
+func (d *committeeDiscovery) start(committeeInfo *types.CommitteeInfo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := committeeInfo.Id.Uint64()
+	if work, ok := d.committees[id]; ok {
+		work.committeeInfo = committeeInfo
+		return
+	}
+	work := &discoveryWork{
+		committeeInfo: committeeInfo,
+		endpoint: tbftCommittee{
+			ID:    new(big.Int).Set(committeeInfo.Id),
+			Port:  d.agent.committeeNode.Port,
+			Port2: d.agent.committeeNode.Port2,
+		},
+		found:    make(map[string]bool),
+		resolved: make(map[enode.ID]time.Time),
+		quit:     make(chan struct{}),
+	}
+	if err := work.endpoint.sign(d.srv.LocalNode().ID(), d.agent.privateKey); err != nil {
+		log.Error("Failed to sign tbft entry", "committeeId", committeeInfo.Id, "err", err)
+		return
+	}
+	d.committees[id] = work
+	d.updateEntry()
+
+	if d.srv.DiscV5 != nil {
+		topic := committeeTopic(d.genesisHash, committeeInfo.Id)
+		go d.srv.DiscV5.RegisterTopic(topic, work.quit)
+		go d.search(work, topic)
+	}
+	log.Info("Committee discovery started", "committeeId", committeeInfo.Id, "topics", d.srv.DiscV5 != nil)
+}
+
+// stop removes the endpoint of the committee and stops searching its members.
+func (d *committeeDiscovery) stop(committeeID *big.Int) {
+	if committeeID == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	work, ok := d.committees[committeeID.Uint64()]
+	if !ok {
+		return
+	}
+	delete(d.committees, committeeID.Uint64())
+	close(work.quit)
+	d.updateEntry()
+	log.Info("Committee discovery stopped", "committeeId", committeeID)
+}
+
+// complete reports whether all used members of the committee have been found,
+// the encrypted node info broadcast isn't needed then.
+func (d *committeeDiscovery) complete(committeeInfo *types.CommitteeInfo) bool {
+	if committeeInfo.Id == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	work, ok := d.committees[committeeInfo.Id.Uint64()]
+	if !ok {
+		return false
+	}
+	for _, member := range committeeInfo.Members {
+		if member.Flag != types.StateUsedFlag || bytes.Equal(member.Publickey, d.agent.committeeNode.Publickey) {
+			continue
+		}
+		if !work.found[string(member.Publickey)] {
+			return false
+		}
+	}
+	return true
+}
+
+// searching reports whether the members are searched on the discv5 topics,
+// otherwise they're only advertised in the node record.
+func (d *committeeDiscovery) searching() bool {
+	return d.srv.DiscV5 != nil
+}
+
+// updateEntry sets the tbft entry of the local node record.
+// The caller must hold d.mu.
+func (d *committeeDiscovery) updateEntry() {
+	if len(d.committees) == 0 {
+		d.srv.LocalNode().Delete(tbftEntry{})
+		return
+	}
+	var entry tbftEntry
+	for _, work := range d.committees {
+		entry.Committees = append(entry.Committees, work.endpoint)
+	}
+	sort.Slice(entry.Committees, func(i, j int) bool {
+		return entry.Committees[i].ID.Cmp(entry.Committees[j].ID) < 0
+	})
+	entry, err := fitEntry(d.srv.LocalNode().Node().Record(), d.srv.PrivateKey, entry)
+	if err != nil {
+		log.Error("Failed to set tbft entry", "committees", len(d.committees), "err", err)
+		d.srv.LocalNode().Delete(tbftEntry{})
+		return
+	}
+	if dropped := len(d.committees) - len(entry.Committees); dropped > 0 {
+		log.Warn("Dropped old committees from tbft entry", "dropped", dropped, "committeeId", entry.Committees[0].ID)
+	}
+	d.srv.LocalNode().Set(entry)
+}
+
+// fitEntry drops the oldest committees from the entry until the record signed
+// with it at the next sequence number stays within enr.SizeLimit, the local
+// node can't sign it otherwise.
+func fitEntry(r *enr.Record, key *ecdsa.PrivateKey, entry tbftEntry) (tbftEntry, error) {
+	for {
+		cpy := *r
+		cpy.Set(entry)
+		cpy.SetSeq(r.Seq() + 1)
+		err := enode.SignV4(&cpy, key)
+		if err == nil || len(entry.Committees) <= 1 {
+			return entry, err
+		}
+		entry.Committees = entry.Committees[1:]
+	}
+}
+
+func (d *committeeDiscovery) search(work *discoveryWork, topic discv5.Topic) {
+	setPeriod := make(chan time.Duration, 1)
+	setPeriod <- committeeSearchPeriod
+	found := make(chan *discv5.Node, committeeFoundSize)
+	go d.srv.DiscV5.SearchTopic(topic, setPeriod, found, nil)
+
+	for {
+		select {
+		case n := <-found:
+			d.resolve(work, n)
+		case <-work.quit:
+			close(setPeriod)
+			return
+		}
+	}
+}
+
+// resolve fetches the record of a node found on the committee topic and puts
+// its tbft endpoint to the pbft server.
+func (d *committeeDiscovery) resolve(work *discoveryWork, dn *discv5.Node) {
+	pubKey, err := dn.ID.Pubkey()
+	if err != nil {
+		return
+	}
+	n := enode.NewV4(pubKey, dn.IP, int(dn.TCP), int(dn.UDP))
+	if n.ID() == d.srv.LocalNode().ID() {
+		return
+	}
+	d.mu.Lock()
+	if time.Since(work.resolved[n.ID()]) < committeeResolveDelay {
+		d.mu.Unlock()
+		return
+	}
+	work.resolved[n.ID()] = time.Now()
+	committeeInfo := work.committeeInfo
+	d.mu.Unlock()
+
+	committeeNode, err := committeeNodeFromRecord(committeeInfo, d.srv.Resolve(n))
+	if err != nil {
+		log.Debug("Discarded committee node", "committeeId", committeeInfo.Id, "node", n.ID(), "err", err)
+		return
+	}
+	d.mu.Lock()
+	work.found[string(committeeNode.Publickey)] = true
+	d.mu.Unlock()
+
+	log.Debug("Discovered committee node", "committeeId", committeeInfo.Id, "node", n.ID(), "ip", committeeNode.IP, "port", committeeNode.Port)
+	help.CheckAndPrintError(d.agent.server.PutNodes(committeeInfo.Id, []*types.CommitteeNode{committeeNode}))
+}
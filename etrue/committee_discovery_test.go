@@ -0,0 +1,123 @@
+package etrue
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"net"
+	"testing"
+
+	"truechain/discovery/core/types"
+	"truechain/discovery/crypto"
+	"truechain/discovery/p2p/enode"
+	"truechain/discovery/p2p/enr"
+)
+
+// newTbftRecord makes a node record advertising the endpoints.
+func newTbftRecord(t *testing.T, nodeKey *ecdsa.PrivateKey, endpoints ...tbftCommittee) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IP(net.IP{10, 0, 0, 1}))
+	r.Set(tbftEntry{Committees: endpoints})
+	if err := enode.SignV4(&r, nodeKey); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCommitteeNodeFromRecord(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	otherNodeKey, _ := crypto.GenerateKey()
+	memberKey, _ := crypto.GenerateKey()
+	outsiderKey, _ := crypto.GenerateKey()
+	removedKey, _ := crypto.GenerateKey()
+
+	committeeInfo := &types.CommitteeInfo{
+		Id: big.NewInt(3),
+		Members: []*types.CommitteeMember{
+			{Coinbase: crypto.PubkeyToAddress(memberKey.PublicKey), Publickey: crypto.FromECDSAPub(&memberKey.PublicKey), Flag: types.StateUsedFlag},
+			{Coinbase: crypto.PubkeyToAddress(removedKey.PublicKey), Publickey: crypto.FromECDSAPub(&removedKey.PublicKey), Flag: types.StateRemovedFlag},
+		},
+	}
+	nodeID := enode.PubkeyToIDV4(&nodeKey.PublicKey)
+	endpoint := func(id int64, key *ecdsa.PrivateKey, signedID enode.ID) tbftCommittee {
+		c := tbftCommittee{ID: big.NewInt(id), Port: 10310, Port2: 10311}
+		if err := c.sign(signedID, key); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	n := newTbftRecord(t, nodeKey, endpoint(2, memberKey, nodeID), endpoint(3, memberKey, nodeID))
+	node, err := committeeNodeFromRecord(committeeInfo, n)
+	if err != nil {
+		t.Fatalf("valid record rejected: %v", err)
+	}
+	if node.IP != "10.0.0.1" || node.Port != 10310 || node.Port2 != 10311 ||
+		node.Coinbase != committeeInfo.Members[0].Coinbase || !bytes.Equal(node.Publickey, committeeInfo.Members[0].Publickey) {
+		t.Errorf("committee node mismatch: %+v", node)
+	}
+
+	tests := []struct {
+		n   *enode.Node
+		err error
+	}{
+		{newTbftRecord(t, nodeKey, endpoint(2, memberKey, nodeID)), errNoCommitteeEntry},
+		{newTbftRecord(t, nodeKey, endpoint(3, outsiderKey, nodeID)), errNotCommitteeMember},
+		{newTbftRecord(t, nodeKey, endpoint(3, removedKey, nodeID)), errNotCommitteeMember},
+		// The endpoint of the member replayed by another node
+		{newTbftRecord(t, otherNodeKey, endpoint(3, memberKey, nodeID)), errNotCommitteeMember},
+		{enode.NewV4(&nodeKey.PublicKey, net.IP{10, 0, 0, 1}, 30310, 30310), errNoTbftEntry},
+	}
+	for i, tt := range tests {
+		if _, err := committeeNodeFromRecord(committeeInfo, tt.n); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that the oldest committees are dropped from the tbft entry when the
+// record would exceed the size limit, and that the entry fails if even the
+// newest committee doesn't fit.
+func TestFitEntry(t *testing.T) {
+	nodeKey, _ := crypto.GenerateKey()
+	memberKey, _ := crypto.GenerateKey()
+	nodeID := enode.PubkeyToIDV4(&nodeKey.PublicKey)
+
+	var entry tbftEntry
+	for i := int64(1); i <= 4; i++ {
+		c := tbftCommittee{ID: big.NewInt(i), Port: 10310, Port2: 10311}
+		if err := c.sign(nodeID, memberKey); err != nil {
+			t.Fatal(err)
+		}
+		entry.Committees = append(entry.Committees, c)
+	}
+	var r enr.Record
+	r.Set(enr.IP(net.IP{10, 0, 0, 1}))
+	r.Set(enr.TCP(30313))
+	r.Set(enr.UDP(30313))
+
+	fit, err := fitEntry(&r, nodeKey, entry)
+	if err != nil {
+		t.Fatalf("entry not fitted: %v", err)
+	}
+	if len(fit.Committees) == 0 || len(fit.Committees) == len(entry.Committees) {
+		t.Fatalf("committees mismatch: have %d of %d", len(fit.Committees), len(entry.Committees))
+	}
+	if last := fit.Committees[len(fit.Committees)-1]; last.ID.Int64() != 4 {
+		t.Errorf("newest committee dropped: have %d, want %d", last.ID, 4)
+	}
+	cpy := r
+	cpy.Set(fit)
+	if err := enode.SignV4(&cpy, nodeKey); err != nil {
+		t.Errorf("fitted record not signed: %v", err)
+	}
+	// A record with no room left for any committee
+	r.Set(enr.WithEntry("pad", make([]byte, enr.SizeLimit)))
+	if _, err := fitEntry(&r, nodeKey, entry); err == nil {
+		t.Errorf("oversized entry accepted")
+	}
+}
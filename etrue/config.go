@@ -31,6 +31,7 @@ import (
 	"truechain/discovery/consensus/minerva"
 	"truechain/discovery/core"
 	"truechain/discovery/core/snailchain"
+	"truechain/discovery/core/vm"
 	"truechain/discovery/etrue/downloader"
 	"truechain/discovery/etrue/gasprice"
 	"truechain/discovery/params"
@@ -38,7 +39,8 @@ import (
 
 // DefaultConfig contains default settings for use on the Truechain main net.
 var DefaultConfig = Config{
-	SyncMode: downloader.FullSync,
+	SyncMode:      downloader.FullSync,
+	ExecutionMode: vm.ParallelExecution,
 	MinervaHash: minerva.Config{
 		CacheDir:       "minerva",
 		CachesInMem:    2,
@@ -111,6 +113,11 @@ type Config struct {
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 
+	// LightCommittee makes the light client verify every fast header against
+	// the committee signs, tracking the committee through the election switch
+	// points, instead of trusting the CHT and bloom trie checkpoints.
+	LightCommittee bool `toml:",omitempty"`
+
 	// election options
 
 	EnableElection bool `toml:",omitempty"`
@@ -130,6 +137,17 @@ type Config struct {
 	// StandByPort is the TCP port number on which to start the pbft server.
 	StandbyPort int `toml:",omitempty"`
 
+	// RemoteSigner is the address (tcp://host:port or unix:///path) of the
+	// remote signer holding the committee key. If set the tbft votes and
+	// proposals, the PbftSigns and the committee transport handshakes are
+	// signed by it, the committee key is still needed for the node info
+	// encryption.
+	RemoteSigner string `toml:",omitempty"`
+
+	// RemoteSignerKey authenticates the node to the remote signer, which only
+	// serves the allowed keys.
+	RemoteSignerKey *ecdsa.PrivateKey `toml:"-"`
+
 	// Ultra Light client options
 	ULC *ULCConfig `toml:",omitempty"`
 
@@ -148,6 +166,11 @@ type Config struct {
 	MinerGasCeil  uint64
 	GasPrice      *big.Int
 
+	// StratumAddr is the TCP address of the stratum server serving the works
+	// to the remote miners, the share difficulty is the fruit's if zero.
+	StratumAddr       string `toml:",omitempty"`
+	StratumDifficulty uint64 `toml:",omitempty"`
+
 	// MinervaHash options
 	MinervaHash minerva.Config
 
@@ -163,6 +186,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// The way the transactions of a block are executed
+	ExecutionMode vm.ExecutionMode
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
@@ -142,6 +142,9 @@ type Downloader struct {
 	stateSyncStart chan *stateSync
 	trackStateReq  chan *stateReq
 	stateCh        chan etrue.DataPack // [eth/63] Channel receiving inbound node state data
+	snapSyncStart  chan *snapSync
+	snapCh         chan etrue.DataPack // [etrue/64] Channel receiving inbound state ranges
+	snapProgress   *snapProgress       // Progress of the last snapshot sync, to resume it
 
 	// Cancellation and termination
 	cancelPeer string         // Identifier of the peer currently being used as the master (cancel on drop)
@@ -233,6 +236,8 @@ func New(mode SyncMode, checkpoint uint64, stateDb etruedb.Database, mux *event.
 		fastDown:       fdown,
 		stateCh:        make(chan etrue.DataPack),
 		stateSyncStart: make(chan *stateSync),
+		snapCh:         make(chan etrue.DataPack),
+		snapSyncStart:  make(chan *snapSync),
 		syncStatsState: stateSyncStats{
 			processed: rawdb.ReadFastTrieProgress(stateDb),
 		},
@@ -1405,11 +1410,11 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 func (d *Downloader) processFullSyncContent(p etrue.PeerConnection, hash common.Hash, td *big.Int, remoteHeader *types.SnailHeader) error {
 
 	var (
-		stateSync *stateSync
+		stateSync etrue.StateSyncInter
 	)
 
 	if d.mode == FastSync || d.mode == SnapShotSync {
-		stateSync = d.SyncState(d.remoteHeader.Root)
+		stateSync = d.SyncStateFd(d.remoteHeader.Root)
 		d.fastDown.SetSync(stateSync)
 		defer stateSync.Cancel()
 		go func() {
@@ -1584,10 +1589,8 @@ func (d *Downloader) SyncFast(peer string, head common.Hash, fbLastNumber uint64
 		currentNumber = d.fastDown.GetLightChain().CurrentHeader().Number.Uint64()
 	} else {
 		currentNumber = d.fastDown.GetBlockChain().CurrentBlock().NumberU64()
-		if mode == FastSync {
+		if mode == FastSync || mode == SnapShotSync {
 			currentNumber = d.fastDown.GetBlockChain().CurrentFastBlock().NumberU64()
-		} else if mode == SnapShotSync {
-			currentNumber = d.fastDown.GetBlockChain().CurrentHeader().Number.Uint64()
 		}
 	}
 
@@ -1597,7 +1600,9 @@ func (d *Downloader) SyncFast(peer string, head common.Hash, fbLastNumber uint64
 
 	if fbLastNumber > currentNumber {
 		log.Debug("Run fast downloader ", "fbNumLast", fbLastNumber, "currentNum", currentNumber, "mode", mode)
-		if mode == SnapShotSync && fbLastNumber > d.remoteHeader.Number.Uint64() {
+		// The fast blocks are downloaded as in fast sync, only the state of the
+		// pivot is downloaded in ranges instead of trie nodes.
+		if mode == SnapShotSync {
 			mode = FastSync
 		}
 
@@ -1627,6 +1632,23 @@ func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
 	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverAccountRange injects a new range of accounts received from a remote node.
+func (d *Downloader) DeliverAccountRange(id string, accounts []*etrue.AccountData, proof [][]byte) (err error) {
+	return d.deliver(id, d.snapCh, &accountPack{id, accounts, proof}, snapInMeter, snapDropMeter)
+}
+
+// DeliverStorageRanges injects a new batch of storage ranges received from a
+// remote node.
+func (d *Downloader) DeliverStorageRanges(id string, slots [][]*etrue.StorageData, proof [][]byte) (err error) {
+	return d.deliver(id, d.snapCh, &storagePack{id, slots, proof}, snapInMeter, snapDropMeter)
+}
+
+// DeliverByteCodes injects a new batch of contract codes received from a remote
+// node.
+func (d *Downloader) DeliverByteCodes(id string, codes [][]byte) (err error) {
+	return d.deliver(id, d.snapCh, &codePack{id, codes}, snapInMeter, snapDropMeter)
+}
+
 // deliver injects a new batch of data received from a remote node.
 func (d *Downloader) deliver(id string, destCh chan etrue.DataPack, packet etrue.DataPack, inMeter, dropMeter metrics.Meter) (err error) {
 	// Update the delivery metrics for both good and failed deliveries
@@ -35,4 +35,7 @@ var (
 
 	stateInMeter   = metrics.NewRegisteredMeter("etrue/downloader/states/in", nil)
 	stateDropMeter = metrics.NewRegisteredMeter("etrue/downloader/states/drop", nil)
+
+	snapInMeter   = metrics.NewRegisteredMeter("etrue/downloader/snap/in", nil)
+	snapDropMeter = metrics.NewRegisteredMeter("etrue/downloader/snap/drop", nil)
 )
@@ -25,7 +25,7 @@ const (
 	FullSync     SyncMode = iota // Synchronise the entire blockchain history from full blocks
 	FastSync                     // Quickly download the headers, full sync only at the chain head
 	LightSync                    // Download only the headers and terminate afterwards
-	SnapShotSync                 // Download only the headers and terminate afterwards
+	SnapShotSync                 // Quickly download the headers, the pivot state in ranges of its snapshot
 )
 
 func (mode SyncMode) IsValid() bool {
@@ -0,0 +1,530 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/state/snapshot"
+	"truechain/discovery/core/types"
+	"truechain/discovery/crypto"
+	"truechain/discovery/log"
+
+	etrue "truechain/discovery/etrue/types"
+)
+
+const (
+	snapAccountTasks  = 16         // Number of account ranges downloaded concurrently
+	snapRequestBytes  = 512 * 1024 // Soft limit of the size of a range response
+	snapStorageFetch  = 128        // Maximum number of accounts to fetch the storage of at once
+	snapCodeFetch     = 64         // Maximum number of contract codes to fetch at once
+	snapStatelessTime = time.Minute
+)
+
+var (
+	errSnapResponse  = errors.New("unexpected state range response")
+	errSnapStateless = errors.New("peer doesn't serve the state")
+)
+
+// snapProgress is the download progress of the state of a root, kept across the
+// restarts of the sync at the same root to not download it from scratch again.
+type snapProgress struct {
+	root     common.Hash
+	accounts []*accountTask           // Account ranges still to download
+	storages []*storageTask           // Accounts still to download the storage of
+	codes    []common.Hash            // Contract codes still to download
+	known    map[common.Hash]struct{} // Contract codes scheduled, shared by many accounts
+
+	accountsDone uint64
+	slotsDone    uint64
+	codesDone    uint64
+}
+
+// accountTask is a range of accounts to download, from next to last.
+type accountTask struct {
+	next common.Hash
+	last common.Hash
+}
+
+// storageTask is the storage of an account to download, from the origin.
+type storageTask struct {
+	account common.Hash
+	root    common.Hash
+	origin  common.Hash
+}
+
+// snapReq is a state range request in flight to a peer.
+type snapReq struct {
+	peer     etrue.PeerConnection
+	account  *accountTask
+	storages []*storageTask
+	codes    []common.Hash
+	timer    *time.Timer
+}
+
+// snapSync downloads the state of a root as the ranges of its flat snapshot,
+// and rebuilds its tries from them once complete.
+type snapSync struct {
+	d    *Downloader
+	root common.Hash
+
+	cancel     chan struct{} // Channel to signal a termination request
+	cancelOnce sync.Once     // Ensures cancel only ever gets called once
+	done       chan struct{} // Channel to signal termination completion
+	err        error         // Any error hit during sync (set before completion)
+}
+
+// syncSnapshot starts downloading the state of the root in ranges.
+func (d *Downloader) syncSnapshot(root common.Hash) *snapSync {
+	s := &snapSync{
+		d:      d,
+		root:   root,
+		cancel: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	select {
+	case d.snapSyncStart <- s:
+	case <-d.quitCh:
+		s.err = etrue.ErrCancelStateFetch
+		close(s.done)
+	}
+	return s
+}
+
+// Wait blocks until the sync is done or canceled.
+func (s *snapSync) Wait() error {
+	<-s.done
+	return s.err
+}
+
+// Cancel cancels the sync and waits until it has shut down.
+func (s *snapSync) Cancel() error {
+	s.cancelOnce.Do(func() { close(s.cancel) })
+	return s.Wait()
+}
+
+// Done returns the channel closed once the sync is done or canceled.
+func (s *snapSync) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns any error hit during the sync.
+func (s *snapSync) Err() error {
+	return s.err
+}
+
+// newSnapProgress wipes the flat snapshot and splits the account space of the
+// root into ranges to download.
+func (d *Downloader) newSnapProgress(root common.Hash) (*snapProgress, error) {
+	if err := snapshot.Wipe(d.stateDB); err != nil {
+		return nil, err
+	}
+	var (
+		progress = &snapProgress{root: root, known: make(map[common.Hash]struct{})}
+		step     = new(big.Int).Div(new(big.Int).Lsh(common.Big1, 256), big.NewInt(snapAccountTasks))
+		next     = new(big.Int)
+	)
+	for i := 0; i < snapAccountTasks; i++ {
+		last := new(big.Int).Sub(new(big.Int).Add(next, step), common.Big1)
+		progress.accounts = append(progress.accounts, &accountTask{
+			next: common.BigToHash(next),
+			last: common.BigToHash(last),
+		})
+		next = new(big.Int).Add(last, common.Big1)
+	}
+	return progress, nil
+}
+
+// runSnapSync runs a snapshot sync until it completes or another root is
+// requested to be switched over to.
+func (d *Downloader) runSnapSync(s *snapSync) *snapSync {
+	var next *snapSync
+
+	s.err = func() error {
+		if d.snapProgress == nil || d.snapProgress.root != s.root {
+			progress, err := d.newSnapProgress(s.root)
+			if err != nil {
+				return err
+			}
+			d.snapProgress = progress
+		}
+		var err error
+		if next, err = d.loopSnapSync(s, d.snapProgress); err != nil || next != nil {
+			return err
+		}
+		log.Info("Rebuilding state tries from snapshot", "root", s.root)
+		if err := snapshot.CommitTrie(d.stateDB, s.root); err != nil {
+			// The ranges are all proven, the downloaded state is only wrong
+			// if the local database is, download it again from scratch
+			log.Error("Failed to rebuild state tries from snapshot", "root", s.root, "err", err)
+			d.snapProgress = nil
+			return err
+		}
+		log.Info("Snapshot sync completed", "root", s.root, "accounts", d.snapProgress.accountsDone, "slots", d.snapProgress.slotsDone, "codes", d.snapProgress.codesDone)
+		return nil
+	}()
+	if next != nil && s.err == nil {
+		s.err = etrue.ErrCancelStateFetch
+	}
+	close(s.done)
+	return next
+}
+
+// loopSnapSync assigns the ranges to download to the idle peers and processes
+// their responses, until the state is downloaded.
+func (d *Downloader) loopSnapSync(s *snapSync, progress *snapProgress) (*snapSync, error) {
+	var (
+		active    = make(map[string]*snapReq) // Currently in-flight requests
+		stateless = make(map[string]struct{}) // Peers not serving the state of the root
+		timeout   = make(chan *snapReq)       // Timed out active requests
+		expire    = time.NewTicker(snapStatelessTime)
+		report    = time.NewTicker(8 * time.Second)
+	)
+	defer func() {
+		for _, req := range active {
+			req.timer.Stop()
+			d.revertSnapReq(progress, req)
+		}
+		expire.Stop()
+		report.Stop()
+	}()
+	newPeer := make(chan etrue.PeerConnection, 1024)
+	peerSub := d.peers.SubscribeNewPeers(newPeer)
+	defer peerSub.Unsubscribe()
+
+	peerDrop := make(chan etrue.PeerConnection, 1024)
+	dropSub := d.peers.SubscribePeerDrops(peerDrop)
+	defer dropSub.Unsubscribe()
+
+	for {
+		d.assignSnapTasks(s, progress, active, stateless, timeout)
+		if len(active) == 0 && len(progress.accounts) == 0 && len(progress.storages) == 0 && len(progress.codes) == 0 {
+			return nil, nil
+		}
+		select {
+		case next := <-d.snapSyncStart:
+			return next, nil
+
+		case <-s.cancel:
+			return nil, etrue.ErrCancelStateFetch
+
+		case <-d.cancelCh:
+			return nil, etrue.ErrCancelStateFetch
+
+		case <-newPeer:
+			// New peer arrived, try to assign it download tasks
+
+		case <-expire.C:
+			// Retry the peers which didn't serve the state, they might have
+			// caught up with the root meanwhile
+			stateless = make(map[string]struct{})
+
+		case <-report.C:
+			log.Info("Downloading state snapshot", "root", s.root, "accounts", progress.accountsDone, "slots", progress.slotsDone, "codes", progress.codesDone,
+				"ranges", len(progress.accounts), "storages", len(progress.storages), "queued", len(progress.codes))
+
+		case pack := <-d.snapCh:
+			req := active[pack.PeerId()]
+			if req == nil {
+				log.Debug("Unrequested state range", "peer", pack.PeerId(), "len", pack.Items())
+				continue
+			}
+			req.timer.Stop()
+			delete(active, pack.PeerId())
+
+			if err := d.processSnapResponse(s.root, progress, req, pack); err != nil {
+				d.revertSnapReq(progress, req)
+				if _, ok := err.(snapWriteError); ok {
+					return nil, err
+				}
+				stateless[pack.PeerId()] = struct{}{}
+				if err == errSnapStateless {
+					log.Debug("Peer doesn't serve the state", "peer", pack.PeerId())
+					continue
+				}
+				// The ranges are proven, a bad one is the fault of the peer
+				log.Warn("Bad state range, dropping peer", "peer", pack.PeerId(), "err", err)
+				if d.dropPeer == nil {
+					log.Warn("Downloader wants to drop peer, but peerdrop-function is not set", "peer", pack.PeerId())
+				} else {
+					d.dropPeer(pack.PeerId(), types.SDownloaderCall)
+				}
+			}
+
+		case p := <-peerDrop:
+			if req := active[p.GetID()]; req != nil {
+				req.timer.Stop()
+				delete(active, p.GetID())
+				d.revertSnapReq(progress, req)
+			}
+
+		case req := <-timeout:
+			if active[req.peer.GetID()] != req {
+				continue
+			}
+			log.Debug("State range request timed out", "peer", req.peer.GetID())
+			delete(active, req.peer.GetID())
+			d.revertSnapReq(progress, req)
+			stateless[req.peer.GetID()] = struct{}{}
+		}
+	}
+}
+
+// snapWriteError is an error writing the downloaded state to disk.
+type snapWriteError struct{ error }
+
+// assignSnapTasks sends the next ranges to download to the idle peers serving
+// the state ranges, the contract codes first, then the storage and accounts.
+func (d *Downloader) assignSnapTasks(s *snapSync, progress *snapProgress, active map[string]*snapReq, stateless map[string]struct{}, timeout chan *snapReq) {
+	for _, p := range d.peers.AllPeers() {
+		if len(progress.accounts) == 0 && len(progress.storages) == 0 && len(progress.codes) == 0 {
+			return
+		}
+		id := p.GetID()
+		if _, busy := active[id]; busy {
+			continue
+		}
+		if _, ok := stateless[id]; ok || p.GetVersion() < 64 {
+			continue
+		}
+		peer, ok := p.GetPeer().(etrue.SnapPeer)
+		if !ok {
+			continue
+		}
+		req := &snapReq{peer: p}
+		var err error
+		switch {
+		case len(progress.codes) > 0:
+			n := len(progress.codes)
+			if n > snapCodeFetch {
+				n = snapCodeFetch
+			}
+			req.codes, progress.codes = progress.codes[:n:n], progress.codes[n:]
+			err = peer.RequestByteCodes(req.codes)
+
+		case len(progress.storages) > 0:
+			n := len(progress.storages)
+			if n > snapStorageFetch {
+				n = snapStorageFetch
+			}
+			req.storages, progress.storages = progress.storages[:n:n], progress.storages[n:]
+			accounts := make([]common.Hash, len(req.storages))
+			for i, task := range req.storages {
+				accounts[i] = task.account
+			}
+			err = peer.RequestStorageRanges(s.root, accounts, req.storages[0].origin, snapRequestBytes)
+
+		default:
+			req.account, progress.accounts = progress.accounts[0], progress.accounts[1:]
+			err = peer.RequestAccountRange(s.root, req.account.next, req.account.last, snapRequestBytes)
+		}
+		if err != nil {
+			d.revertSnapReq(progress, req)
+			stateless[id] = struct{}{}
+			continue
+		}
+		req.timer = time.AfterFunc(d.requestTTL(), func() {
+			select {
+			case timeout <- req:
+			case <-s.done:
+			}
+		})
+		active[id] = req
+	}
+}
+
+// revertSnapReq puts the tasks of a failed request back to be downloaded again.
+func (d *Downloader) revertSnapReq(progress *snapProgress, req *snapReq) {
+	if req.account != nil {
+		progress.accounts = append(progress.accounts, req.account)
+	}
+	progress.storages = append(req.storages, progress.storages...)
+	progress.codes = append(progress.codes, req.codes...)
+}
+
+// processSnapResponse checks a response against the proofs and the requested
+// hashes, and writes it to the flat snapshot. The remainder of partially served
+// tasks is scheduled again. An empty response means the peer doesn't serve the
+// state of the root, any other bad one is rejected and nothing is written.
+func (d *Downloader) processSnapResponse(root common.Hash, progress *snapProgress, req *snapReq, pack etrue.DataPack) error {
+	switch pack := pack.(type) {
+	case *accountPack:
+		if req.account == nil {
+			return errSnapResponse
+		}
+		return d.processAccountRange(root, progress, req.account, pack)
+	case *storagePack:
+		if req.storages == nil {
+			return errSnapResponse
+		}
+		return d.processStorageRanges(progress, req.storages, pack)
+	case *codePack:
+		if req.codes == nil {
+			return errSnapResponse
+		}
+		return d.processByteCodes(progress, req.codes, pack)
+	}
+	return errSnapResponse
+}
+
+func (d *Downloader) processAccountRange(root common.Hash, progress *snapProgress, task *accountTask, pack *accountPack) error {
+	if len(pack.accounts) == 0 && len(pack.proof) == 0 {
+		return errSnapStateless
+	}
+	keys := make([]common.Hash, len(pack.accounts))
+	values := make([][]byte, len(pack.accounts))
+	for i, account := range pack.accounts {
+		keys[i], values[i] = account.Hash, account.Body
+	}
+	more, err := snapshot.VerifyRange(root, task.next, keys, values, pack.proof)
+	if err != nil {
+		return err
+	}
+	// The range is contiguous from the origin, the accounts past the task are
+	// left to the task covering them, they prove the task is complete
+	done := !more
+	for len(keys) > 0 && bytes.Compare(keys[len(keys)-1][:], task.last[:]) > 0 {
+		keys, values = keys[:len(keys)-1], values[:len(values)-1]
+		done = true
+	}
+	if len(keys) > 0 && keys[len(keys)-1] == task.last {
+		done = true
+	}
+	accounts := make([]*snapshot.Account, len(keys))
+	for i := range keys {
+		account, err := snapshot.DecodeAccount(values[i])
+		if err != nil {
+			return errSnapResponse
+		}
+		accounts[i] = account
+	}
+	batch := d.stateDB.NewBatch()
+	for i, key := range keys {
+		rawdb.WriteAccountSnapshot(batch, key, values[i])
+	}
+	if err := batch.Write(); err != nil {
+		return snapWriteError{err}
+	}
+	progress.accountsDone += uint64(len(keys))
+
+	// Schedule the storage and the codes not known yet of the accounts
+	for i, account := range accounts {
+		if account.HasStorage() {
+			progress.storages = append(progress.storages, &storageTask{account: keys[i], root: account.Root})
+		}
+		if account.HasCode() {
+			hash := common.BytesToHash(account.CodeHash)
+			if _, ok := progress.known[hash]; ok {
+				continue
+			}
+			progress.known[hash] = struct{}{}
+			if ok, _ := d.stateDB.Has(hash[:]); !ok {
+				progress.codes = append(progress.codes, hash)
+			}
+		}
+	}
+
+	// The rest of the range is downloaded again unless it's proven empty
+	if !done {
+		next := incHash(keys[len(keys)-1])
+		progress.accounts = append(progress.accounts, &accountTask{next: next, last: task.last})
+	}
+	return nil
+}
+
+func (d *Downloader) processStorageRanges(progress *snapProgress, tasks []*storageTask, pack *storagePack) error {
+	if len(pack.slots) == 0 {
+		return errSnapStateless
+	}
+	if len(pack.slots) > len(tasks) {
+		return errSnapResponse
+	}
+	var (
+		batch   = d.stateDB.NewBatch()
+		partial *storageTask
+		count   uint64
+	)
+	for i, slots := range pack.slots {
+		task := tasks[i]
+		keys := make([]common.Hash, len(slots))
+		values := make([][]byte, len(slots))
+		for j, slot := range slots {
+			keys[j], values[j] = slot.Hash, slot.Body
+		}
+		// Only the last range may be partial, proven by the proof, the others
+		// are whole storage tries
+		if i == len(pack.slots)-1 && pack.proof != nil {
+			more, err := snapshot.VerifyRange(task.root, task.origin, keys, values, pack.proof)
+			if err != nil {
+				return err
+			}
+			if more {
+				partial = &storageTask{account: task.account, root: task.root, origin: incHash(keys[len(keys)-1])}
+			}
+		} else if task.origin != (common.Hash{}) {
+			return errSnapResponse
+		} else if err := snapshot.VerifyTrie(task.root, keys, values); err != nil {
+			return err
+		}
+		for j, key := range keys {
+			rawdb.WriteStorageSnapshot(batch, task.account, key, values[j])
+		}
+		count += uint64(len(keys))
+	}
+	if err := batch.Write(); err != nil {
+		return snapWriteError{err}
+	}
+	progress.slotsDone += count
+	var rest []*storageTask
+	if partial != nil {
+		rest = append(rest, partial)
+	}
+	rest = append(rest, tasks[len(pack.slots):]...)
+	progress.storages = append(rest, progress.storages...)
+	return nil
+}
+
+func (d *Downloader) processByteCodes(progress *snapProgress, hashes []common.Hash, pack *codePack) error {
+	if len(pack.codes) == 0 {
+		return errSnapStateless
+	}
+	requested := make(map[common.Hash]struct{}, len(hashes))
+	for _, hash := range hashes {
+		requested[hash] = struct{}{}
+	}
+	batch := d.stateDB.NewBatch()
+	for _, code := range pack.codes {
+		hash := crypto.Keccak256Hash(code)
+		if _, ok := requested[hash]; !ok {
+			return errSnapResponse
+		}
+		batch.Put(hash[:], code)
+		delete(requested, hash)
+	}
+	if err := batch.Write(); err != nil {
+		return snapWriteError{err}
+	}
+	progress.codesDone += uint64(len(pack.codes))
+	for _, hash := range hashes {
+		if _, ok := requested[hash]; ok {
+			progress.codes = append(progress.codes, hash)
+		}
+	}
+	return nil
+}
+
+// incHash returns the hash following the given one, which mustn't be the last
+// hash of the key space.
+func incHash(hash common.Hash) common.Hash {
+	for i := len(hash) - 1; i >= 0; i-- {
+		hash[i]++
+		if hash[i] != 0 {
+			break
+		}
+	}
+	return hash
+}
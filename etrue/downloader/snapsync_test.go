@@ -0,0 +1,243 @@
+package downloader
+
+import (
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/state"
+	"truechain/discovery/core/state/snapshot"
+	"truechain/discovery/crypto"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/trie"
+
+	etrue "truechain/discovery/etrue/types"
+)
+
+var snapLastHash = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+
+// newSnapTestState creates a state of 100 accounts, the account 7 has a code
+// and 50 storage slots. It returns the root and the account hashes in order.
+func newSnapTestState(t *testing.T) (*trie.Database, common.Hash, []common.Hash) {
+	sdb := state.NewDatabase(etruedb.NewMemDatabase())
+	statedb, _ := state.New(common.Hash{}, sdb)
+	for i := int64(1); i <= 100; i++ {
+		statedb.AddBalance(common.BigToAddress(big.NewInt(i)), big.NewInt(i))
+	}
+	contract := common.BigToAddress(big.NewInt(7))
+	statedb.SetCode(contract, []byte{0x60, 0x00})
+	for i := int64(1); i <= 50; i++ {
+		statedb.SetState(contract, common.BigToHash(big.NewInt(i)), common.BigToHash(big.NewInt(i)))
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := sdb.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to commit tries: %v", err)
+	}
+	keys, _, _, err := snapshot.AccountRange(nil, sdb.TrieDB(), root, common.Hash{}, snapLastHash, 1<<20)
+	if err != nil || len(keys) != 100 {
+		t.Fatalf("failed to list accounts: %d, %v", len(keys), err)
+	}
+	return sdb.TrieDB(), root, keys
+}
+
+// serveSnapAccounts returns the response of a peer to an account range request.
+func serveSnapAccounts(t *testing.T, triedb *trie.Database, root, origin, limit common.Hash, bytes uint64) *accountPack {
+	keys, values, proof, err := snapshot.AccountRange(nil, triedb, root, origin, limit, bytes)
+	if err != nil {
+		t.Fatalf("failed to serve account range: %v", err)
+	}
+	pack := &accountPack{peerID: "peer", proof: proof}
+	for i, key := range keys {
+		pack.accounts = append(pack.accounts, &etrue.AccountData{Hash: key, Body: values[i]})
+	}
+	return pack
+}
+
+// Tests that account ranges are written and the rest of their tasks scheduled
+// again, and that bad ranges are rejected without writing anything.
+func TestSnapAccountRange(t *testing.T) {
+	triedb, root, keys := newSnapTestState(t)
+	between := incHash(keys[49]) // not an account, keys[50] is the next one
+
+	tests := []struct {
+		task    accountTask
+		pack    func() *accountPack
+		err     bool
+		done    int  // accounts written
+		partial bool // the rest of the task is scheduled again
+		codes   int
+		stores  int
+	}{
+		// the whole task is served
+		{
+			task: accountTask{last: snapLastHash},
+			pack: func() *accountPack { return serveSnapAccounts(t, triedb, root, common.Hash{}, snapLastHash, 1<<20) },
+			done: 100, codes: 1, stores: 1,
+		},
+		// a part of the task is served, the rest is scheduled again
+		{
+			task: accountTask{last: snapLastHash},
+			pack: func() *accountPack { return serveSnapAccounts(t, triedb, root, common.Hash{}, snapLastHash, 1000) },
+			done: -1, partial: true, codes: -1, stores: -1,
+		},
+		// the account past the task proves it's complete
+		{
+			task: accountTask{last: between},
+			pack: func() *accountPack { return serveSnapAccounts(t, triedb, root, common.Hash{}, between, 1<<20) },
+			done: 50, codes: -1, stores: -1,
+		},
+		// an account is missing in the middle
+		{
+			task: accountTask{last: snapLastHash},
+			pack: func() *accountPack {
+				pack := serveSnapAccounts(t, triedb, root, common.Hash{}, snapLastHash, 1<<20)
+				pack.accounts = append(pack.accounts[:5:5], pack.accounts[6:]...)
+				return pack
+			},
+			err: true,
+		},
+		// an account is forged
+		{
+			task: accountTask{last: snapLastHash},
+			pack: func() *accountPack {
+				pack := serveSnapAccounts(t, triedb, root, common.Hash{}, snapLastHash, 1<<20)
+				pack.accounts[5] = &etrue.AccountData{Hash: pack.accounts[5].Hash, Body: pack.accounts[6].Body}
+				return pack
+			},
+			err: true,
+		},
+		// an empty range although the accounts follow the origin
+		{
+			task: accountTask{next: keys[10], last: snapLastHash},
+			pack: func() *accountPack {
+				pack := serveSnapAccounts(t, triedb, root, keys[10], snapLastHash, 1<<20)
+				pack.accounts = nil
+				return pack
+			},
+			err: true,
+		},
+	}
+	for i, tt := range tests {
+		var (
+			d        = &Downloader{stateDB: etruedb.NewMemDatabase()}
+			progress = &snapProgress{known: make(map[common.Hash]struct{})}
+			task     = tt.task
+		)
+		pack := tt.pack()
+		err := d.processSnapResponse(root, progress, &snapReq{account: &task}, pack)
+		if (err != nil) != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want error %v", i, err, tt.err)
+			continue
+		}
+		if tt.err && (progress.accountsDone != 0 || len(progress.accounts) != 0 || len(progress.storages) != 0) {
+			t.Errorf("test %d: bad range processed: %+v", i, progress)
+		}
+		done := tt.done
+		if tt.partial {
+			done = len(pack.accounts)
+		}
+		if have := countSnapAccounts(d.stateDB); have != done || progress.accountsDone != uint64(done) {
+			t.Errorf("test %d: accounts written mismatch: have %d/%d, want %d", i, have, progress.accountsDone, done)
+		}
+		switch {
+		case !tt.partial && len(progress.accounts) != 0:
+			t.Errorf("test %d: complete task scheduled again: %+v", i, progress.accounts[0])
+		case tt.partial:
+			next := accountTask{next: incHash(pack.accounts[len(pack.accounts)-1].Hash), last: snapLastHash}
+			if len(progress.accounts) != 1 || *progress.accounts[0] != next {
+				t.Errorf("test %d: rest of the task mismatch: have %v, want %+v", i, progress.accounts, next)
+			}
+		}
+		if tt.codes >= 0 && (len(progress.codes) != tt.codes || len(progress.storages) != tt.stores) {
+			t.Errorf("test %d: scheduled mismatch: have %d codes %d storages, want %d %d", i, len(progress.codes), len(progress.storages), tt.codes, tt.stores)
+		}
+	}
+	// A peer not serving the state answers empty
+	d := &Downloader{stateDB: etruedb.NewMemDatabase()}
+	progress := &snapProgress{known: make(map[common.Hash]struct{})}
+	if err := d.processSnapResponse(root, progress, &snapReq{account: &accountTask{last: snapLastHash}}, &accountPack{peerID: "peer"}); err != errSnapStateless {
+		t.Errorf("empty response error mismatch: have %v, want %v", err, errSnapStateless)
+	}
+}
+
+// countSnapAccounts returns the number of accounts in the flat snapshot.
+func countSnapAccounts(db etruedb.Database) int {
+	it := db.(etruedb.Iteratee).NewIteratorWithStart(rawdb.SnapshotAccountPrefix, nil)
+	defer it.Release()
+
+	count := 0
+	for it.Next() {
+		if len(it.Key()) == len(rawdb.SnapshotAccountPrefix)+common.HashLength {
+			count++
+		}
+	}
+	return count
+}
+
+// Tests that a partial storage range schedules the rest of the storage, and
+// that a storage range with a gap is rejected.
+func TestSnapStorageRanges(t *testing.T) {
+	triedb, root, _ := newSnapTestState(t)
+	account := crypto.Keccak256Hash(common.BigToAddress(big.NewInt(7)).Bytes())
+	tr, _ := trie.New(root, triedb)
+	blob, _ := tr.TryGet(account[:])
+	data, err := snapshot.DecodeAccount(blob)
+	if err != nil {
+		t.Fatalf("failed to decode account: %v", err)
+	}
+	task := &storageTask{account: account, root: data.Root}
+
+	serve := func(origin common.Hash, bytes uint64) *storagePack {
+		keys, values, proof, err := snapshot.StorageRange(nil, triedb, root, account, origin, bytes)
+		if err != nil {
+			t.Fatalf("failed to serve storage range: %v", err)
+		}
+		slots := make([]*etrue.StorageData, len(keys))
+		for i, key := range keys {
+			slots[i] = &etrue.StorageData{Hash: key, Body: values[i]}
+		}
+		return &storagePack{peerID: "peer", slots: [][]*etrue.StorageData{slots}, proof: proof}
+	}
+	// The whole storage at once
+	d := &Downloader{stateDB: etruedb.NewMemDatabase()}
+	progress := &snapProgress{known: make(map[common.Hash]struct{})}
+	if err := d.processSnapResponse(root, progress, &snapReq{storages: []*storageTask{task}}, serve(common.Hash{}, 1<<20)); err != nil {
+		t.Fatalf("failed to process storage: %v", err)
+	}
+	if progress.slotsDone != 50 || len(progress.storages) != 0 {
+		t.Errorf("whole storage mismatch: have %d slots, %d tasks", progress.slotsDone, len(progress.storages))
+	}
+	// The storage in parts
+	d = &Downloader{stateDB: etruedb.NewMemDatabase()}
+	progress = &snapProgress{known: make(map[common.Hash]struct{})}
+	pending := []*storageTask{task}
+	for rounds := 0; len(pending) > 0; rounds++ {
+		if rounds > 50 {
+			t.Fatalf("storage download not progressing")
+		}
+		pack := serve(pending[0].origin, 500)
+		if err := d.processSnapResponse(root, progress, &snapReq{storages: pending}, pack); err != nil {
+			t.Fatalf("failed to process storage range: %v", err)
+		}
+		pending, progress.storages = progress.storages, nil
+	}
+	if progress.slotsDone != 50 {
+		t.Errorf("partial storage slots mismatch: have %d, want 50", progress.slotsDone)
+	}
+	// A slot missing in the middle of a partial range
+	pack := serve(incHash(common.Hash{}), 500)
+	slots := pack.slots[0]
+	pack.slots[0] = append(slots[:2:2], slots[3:]...)
+	progress = &snapProgress{known: make(map[common.Hash]struct{})}
+	gapTask := &storageTask{account: account, root: data.Root, origin: incHash(common.Hash{})}
+	if err := d.processSnapResponse(root, progress, &snapReq{storages: []*storageTask{gapTask}}, pack); err == nil {
+		t.Errorf("storage range with a gap accepted")
+	}
+	if progress.slotsDone != 0 || len(progress.storages) != 0 {
+		t.Errorf("bad storage range processed: %+v", progress)
+	}
+}
@@ -72,7 +72,12 @@ func (d *Downloader) SyncState(root common.Hash) *stateSync {
 	return s
 }
 
+// SyncStateFd starts downloading the state of the fast sync pivot, in ranges of
+// the flat snapshot if snapshot syncing.
 func (d *Downloader) SyncStateFd(root common.Hash) etrue.StateSyncInter {
+	if d.mode == SnapShotSync {
+		return d.syncSnapshot(root)
+	}
 	s := newStateSync(d, root)
 	select {
 	case d.stateSyncStart <- s:
@@ -92,8 +97,13 @@ func (d *Downloader) stateFetcher() {
 			for next := s; next != nil; {
 				next = d.runStateSync(next)
 			}
+		case s := <-d.snapSyncStart:
+			for next := s; next != nil; {
+				next = d.runSnapSync(next)
+			}
 		case <-d.stateCh:
 			// Ignore state responses while no sync is running.
+		case <-d.snapCh:
 		case <-d.quitCh:
 			return
 		}
@@ -19,6 +19,7 @@ package downloader
 import (
 	"fmt"
 	"truechain/discovery/core/types"
+	etrue "truechain/discovery/etrue/types"
 )
 
 // headerPack is a batch of block headers returned by a peer.
@@ -56,3 +57,35 @@ type statePack struct {
 func (p *statePack) PeerId() string { return p.peerID }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("Snail %d", len(p.states)) }
+
+// accountPack is a range of accounts returned by a peer.
+type accountPack struct {
+	peerID   string
+	accounts []*etrue.AccountData
+	proof    [][]byte
+}
+
+func (p *accountPack) PeerId() string { return p.peerID }
+func (p *accountPack) Items() int     { return len(p.accounts) }
+func (p *accountPack) Stats() string  { return fmt.Sprintf("Accounts %d", len(p.accounts)) }
+
+// storagePack is a batch of storage ranges returned by a peer.
+type storagePack struct {
+	peerID string
+	slots  [][]*etrue.StorageData
+	proof  [][]byte
+}
+
+func (p *storagePack) PeerId() string { return p.peerID }
+func (p *storagePack) Items() int     { return len(p.slots) }
+func (p *storagePack) Stats() string  { return fmt.Sprintf("Storages %d", len(p.slots)) }
+
+// codePack is a batch of contract codes returned by a peer.
+type codePack struct {
+	peerID string
+	codes  [][]byte
+}
+
+func (p *codePack) PeerId() string { return p.peerID }
+func (p *codePack) Items() int     { return len(p.codes) }
+func (p *codePack) Stats() string  { return fmt.Sprintf("Codes %d", len(p.codes)) }
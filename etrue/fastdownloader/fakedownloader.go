@@ -443,6 +443,7 @@ func (dlp *DownloadTesterPeer) RequestBodies(hashes []common.Hash, isFastchain b
 	transactions := make([][]*types.Transaction, 0, len(hashes))
 	signs := make([][]*types.PbftSign, 0, len(hashes))
 	infos := make([][]*types.CommitteeMember, 0, len(hashes))
+	evidences := make([][]*types.DuplicateVoteEvidence, 0, len(hashes))
 
 	//[]*types.PbftSign
 
@@ -451,9 +452,10 @@ func (dlp *DownloadTesterPeer) RequestBodies(hashes []common.Hash, isFastchain b
 			transactions = append(transactions, block.Transactions())
 			signs = append(signs, block.Signs())
 			infos = append(infos, block.SwitchInfos())
+			evidences = append(evidences, block.Evidences())
 		}
 	}
-	go dlp.dl.downloader.DeliverBodies(dlp.id, transactions, signs, infos, types.DownloaderCall)
+	go dlp.dl.downloader.DeliverBodies(dlp.id, transactions, signs, infos, evidences, types.DownloaderCall)
 
 	return nil
 }
@@ -123,18 +123,20 @@ func (p *FakePeer) RequestHeadersByNumber(number uint64, amount int, skip int, r
 // corresponding to the specified block hashes.
 func (p *FakePeer) RequestBodies(hashes []common.Hash, isFastchain bool) error {
 	var (
-		txs   [][]*types.Transaction
-		signs [][]*types.PbftSign
-		infos [][]*types.CommitteeMember
+		txs       [][]*types.Transaction
+		signs     [][]*types.PbftSign
+		infos     [][]*types.CommitteeMember
+		evidences [][]*types.DuplicateVoteEvidence
 	)
 	for _, hash := range hashes {
 		block := rawdb.ReadBlock(p.db, hash, *p.hc.GetBlockNumber(hash))
 		signs = append(signs, block.Signs())
 		txs = append(txs, block.Transactions())
 		infos = append(infos, block.SwitchInfos())
+		evidences = append(evidences, block.Evidences())
 	}
 
-	p.dl.DeliverBodies(p.id, txs, signs, infos, types.DownloaderCall)
+	p.dl.DeliverBodies(p.id, txs, signs, infos, evidences, types.DownloaderCall)
 	return nil
 }
 
@@ -746,7 +746,7 @@ func (q *queue) DeliverHeaders(id string, headers []*types.Header, headerProcCh
 // DeliverBodies injects a block body retrieval response into the results queue.
 // The method returns the number of blocks bodies accepted from the delivery and
 // also wakes any threads waiting for data delivery.
-func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, signs [][]*types.PbftSign, infos [][]*types.CommitteeMember) (int, error) {
+func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, signs [][]*types.PbftSign, infos [][]*types.CommitteeMember, evidences [][]*types.DuplicateVoteEvidence) (int, error) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
@@ -758,6 +758,9 @@ func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, signs [
 		if types.RlpHash(infos[index]) != header.CommitteeHash {
 			return errInvalidChain
 		}
+		if types.DeriveSha(types.DuplicateVoteEvidences(evidences[index])) != header.EvidenceRoot() {
+			return errInvalidChain
+		}
 
 		for _, sign := range signs[index] {
 			if sign.FastHeight.Cmp(header.Number) != 0 || sign.FastHash != header.Hash() {
@@ -768,6 +771,7 @@ func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, signs [
 		result.Transactions = txLists[index]
 		result.Signs = signs[index]
 		result.Infos = infos[index]
+		result.Evidences = evidences[index]
 		return nil
 	}
 	return q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, bodyReqTimer, len(txLists), reconstruct)
@@ -38,6 +38,7 @@ type bodyPack struct {
 	transactions [][]*types.Transaction
 	signs        [][]*types.PbftSign
 	infos        [][]*types.CommitteeMember
+	evidences    [][]*types.DuplicateVoteEvidence
 }
 
 func (p *bodyPack) PeerId() string { return p.peerID }
@@ -110,11 +110,12 @@ type PbftAgentFetcher interface {
 // bodyFilterTask represents a batch of block bodies (transactions and uncles)
 // needing fetcher filtering.
 type bodyFilterTask struct {
-	peer         string                     // The source peer of block bodies
-	transactions [][]*types.Transaction     // Collection of transactions per block bodies
-	signs        [][]*types.PbftSign        // Collection of sign per block bodies
-	infos        [][]*types.CommitteeMember // committee change
-	time         time.Time                  // Arrival time of the blocks' contents
+	peer         string                           // The source peer of block bodies
+	transactions [][]*types.Transaction           // Collection of transactions per block bodies
+	signs        [][]*types.PbftSign              // Collection of sign per block bodies
+	infos        [][]*types.CommitteeMember       // committee change
+	evidences    [][]*types.DuplicateVoteEvidence // Collection of evidences per block bodies
+	time         time.Time                        // Arrival time of the blocks' contents
 }
 
 // inject represents a schedules import operation.
@@ -290,7 +291,7 @@ func (f *Fetcher) FilterHeaders(peer string, headers []*types.Header, time time.
 
 // FilterBodies extracts all the block bodies that were explicitly requested by
 // the fetcher, returning those that should be handled differently.
-func (f *Fetcher) FilterBodies(peer string, transactions [][]*types.Transaction, signs [][]*types.PbftSign, infos [][]*types.CommitteeMember, time time.Time) ([][]*types.Transaction, [][]*types.PbftSign, [][]*types.CommitteeMember) {
+func (f *Fetcher) FilterBodies(peer string, transactions [][]*types.Transaction, signs [][]*types.PbftSign, infos [][]*types.CommitteeMember, evidences [][]*types.DuplicateVoteEvidence, time time.Time) ([][]*types.Transaction, [][]*types.PbftSign, [][]*types.CommitteeMember, [][]*types.DuplicateVoteEvidence) {
 	log.Debug("Filtering fast bodies", "peer", peer, "txs", len(transactions), "signs", len(signs))
 
 	// Send the filter channel to the fetcher
@@ -299,20 +300,20 @@ func (f *Fetcher) FilterBodies(peer string, transactions [][]*types.Transaction,
 	select {
 	case f.bodyFilter <- filter:
 	case <-f.quit:
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 	// Request the filtering of the body list
 	select {
-	case filter <- &bodyFilterTask{peer: peer, transactions: transactions, signs: signs, infos: infos, time: time}:
+	case filter <- &bodyFilterTask{peer: peer, transactions: transactions, signs: signs, infos: infos, evidences: evidences, time: time}:
 	case <-f.quit:
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 	// Retrieve the bodies remaining after filtering
 	select {
 	case task := <-filter:
-		return task.transactions, task.signs, task.infos
+		return task.transactions, task.signs, task.infos, task.evidences
 	case <-f.quit:
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 }
 
@@ -596,14 +597,15 @@ func (f *Fetcher) loop() {
 				for hash, announce := range f.completing {
 					if f.getPendingBlock(hash) == nil {
 						txnHash := types.DeriveSha(types.Transactions(task.transactions[i]))
+						evidenceHash := types.DeriveSha(types.DuplicateVoteEvidences(task.evidences[i]))
 
-						if txnHash == announce.header.TxHash && announce.origin == task.peer && len(task.signs[i]) > 0 && task.signs[i][0].FastHash == hash {
+						if txnHash == announce.header.TxHash && evidenceHash == announce.header.EvidenceRoot() && announce.origin == task.peer && len(task.signs[i]) > 0 && task.signs[i][0].FastHash == hash {
 							// Mark the body matched, reassemble if still unknown
 							matched = true
 
 							if f.getBlock(hash) == nil {
 								// mecMark
-								block := types.NewBlockWithHeader(announce.header).WithBody(task.transactions[i], task.signs[i], task.infos[i])
+								block := types.NewBlockWithHeader(announce.header).WithBody(task.transactions[i], task.signs[i], task.infos[i], task.evidences[i])
 								block.ReceivedAt = task.time
 
 								blocks = append(blocks, block)
@@ -617,6 +619,7 @@ func (f *Fetcher) loop() {
 					task.transactions = append(task.transactions[:i], task.transactions[i+1:]...)
 					task.signs = append(task.signs[:i], task.signs[i+1:]...)
 					task.infos = append(task.infos[:i], task.infos[i+1:]...)
+					task.evidences = append(task.evidences[:i], task.evidences[i+1:]...)
 					i--
 					continue
 				}
@@ -783,7 +786,7 @@ func (f *Fetcher) verifyBlockBroadcast(peer string, block *types.Block) bool {
 func (f *Fetcher) verifyComeAgreement(peer string, block *types.Block, signs []*types.PbftSign) {
 	go func() {
 		height := block.Number()
-		inBlock := types.NewBlockWithHeader(block.Header()).WithBody(block.Transactions(), signs, block.SwitchInfos())
+		inBlock := types.NewBlockWithHeader(block.Header()).WithBody(block.Transactions(), signs, block.SwitchInfos(), block.Evidences())
 		find := f.insert(peer, inBlock)
 		if !find {
 			log.Debug("Failed insert fast block", "number", height, "sign number", len(signs), "result", find, "hash", block.Hash(), "peer", peer)
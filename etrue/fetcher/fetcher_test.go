@@ -214,16 +214,18 @@ func (f *fetcherTester) makeBodyFetcher(peer string, blocks map[common.Hash]*typ
 		transactions := make([][]*types.Transaction, 0, len(hashes))
 		signs := make([][]*types.PbftSign, 0, len(hashes))
 		infos := make([][]*types.CommitteeMember, 0, len(hashes))
+		evidences := make([][]*types.DuplicateVoteEvidence, 0, len(hashes))
 
 		for _, hash := range hashes {
 			if block, ok := closure[hash]; ok {
 				transactions = append(transactions, block.Transactions())
 				signs = append(signs, block.Signs())
 				infos = append(infos, block.SwitchInfos())
+				evidences = append(evidences, block.Evidences())
 			}
 		}
 		// Return on a new thread
-		go f.fetcher.FilterBodies(peer, transactions, signs, infos, time.Now().Add(drift))
+		go f.fetcher.FilterBodies(peer, transactions, signs, infos, evidences, time.Now().Add(drift))
 
 		return nil
 	}
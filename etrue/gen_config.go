@@ -9,6 +9,7 @@ import (
 	"truechain/discovery/common/hexutil"
 	"truechain/discovery/consensus/minerva"
 	"truechain/discovery/core"
+	"truechain/discovery/core/vm"
 	"truechain/discovery/etrue/downloader"
 	"truechain/discovery/etrue/gasprice"
 )
@@ -22,11 +23,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SyncMode                downloader.SyncMode
 		LightServ               int           `toml:",omitempty"`
 		LightPeers              int           `toml:",omitempty"`
+		LightCommittee          bool          `toml:",omitempty"`
 		EnableElection          bool          `toml:",omitempty"`
 		CommitteeKey            hexutil.Bytes `toml:",omitempty"`
 		Host                    string        `toml:",omitempty"`
 		Port                    int           `toml:",omitempty"`
 		StandbyPort             int           `toml:",omitempty"`
+		RemoteSigner            string        `toml:",omitempty"`
 		SkipBcVersionCheck      bool          `toml:"-"`
 		DatabaseHandles         int           `toml:"-"`
 		DatabaseCache           int
@@ -34,10 +37,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		StratumAddr             string `toml:",omitempty"`
+		StratumDifficulty       uint64 `toml:",omitempty"`
 		MinervaHash             minerva.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
+		ExecutionMode           vm.ExecutionMode
 		DocRoot                 string `toml:"-"`
 	}
 	var enc Config
@@ -46,11 +52,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SyncMode = c.SyncMode
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
+	enc.LightCommittee = c.LightCommittee
 	enc.EnableElection = c.EnableElection
 	enc.CommitteeKey = c.CommitteeKey
 	enc.Host = c.Host
 	enc.Port = c.Port
 	enc.StandbyPort = c.StandbyPort
+	enc.RemoteSigner = c.RemoteSigner
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
@@ -58,10 +66,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.StratumAddr = c.StratumAddr
+	enc.StratumDifficulty = c.StratumDifficulty
 	enc.MinervaHash = c.MinervaHash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.ExecutionMode = c.ExecutionMode
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
@@ -76,8 +87,10 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Host                    *string        `toml:",omitempty"`
 		Port                    *int           `toml:",omitempty"`
 		StandbyPort             *int           `toml:",omitempty"`
+		RemoteSigner            *string        `toml:",omitempty"`
 		LightServ               *int           `toml:",omitempty"`
 		LightPeers              *int           `toml:",omitempty"`
+		LightCommittee          *bool          `toml:",omitempty"`
 		SkipBcVersionCheck      *bool          `toml:"-"`
 		DatabaseHandles         *int           `toml:"-"`
 		DatabaseCache           *int
@@ -85,10 +98,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		StratumAddr             *string `toml:",omitempty"`
+		StratumDifficulty       *uint64 `toml:",omitempty"`
 		MinervaHash             *minerva.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
+		ExecutionMode           *vm.ExecutionMode
 		DocRoot                 *string `toml:"-"`
 	}
 	var dec Config
@@ -119,12 +135,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.StandbyPort != nil {
 		c.StandbyPort = *dec.StandbyPort
 	}
+	if dec.RemoteSigner != nil {
+		c.RemoteSigner = *dec.RemoteSigner
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
 	if dec.LightPeers != nil {
 		c.LightPeers = *dec.LightPeers
 	}
+	if dec.LightCommittee != nil {
+		c.LightCommittee = *dec.LightCommittee
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -146,6 +168,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.StratumAddr != nil {
+		c.StratumAddr = *dec.StratumAddr
+	}
+	if dec.StratumDifficulty != nil {
+		c.StratumDifficulty = *dec.StratumDifficulty
+	}
 	if dec.MinervaHash != nil {
 		c.MinervaHash = *dec.MinervaHash
 	}
@@ -158,6 +186,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.ExecutionMode != nil {
+		c.ExecutionMode = *dec.ExecutionMode
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -32,11 +32,13 @@ import (
 	"truechain/discovery/consensus"
 	"truechain/discovery/core"
 	"truechain/discovery/core/snailchain"
+	"truechain/discovery/core/state/snapshot"
 	"truechain/discovery/core/types"
 	"truechain/discovery/etrue/downloader"
 	"truechain/discovery/etrue/fastdownloader"
 	"truechain/discovery/etrue/fetcher"
 	"truechain/discovery/etrue/fetcher/snail"
+	dtype "truechain/discovery/etrue/types"
 	"truechain/discovery/etruedb"
 	"truechain/discovery/event"
 	"truechain/discovery/log"
@@ -164,6 +166,10 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
+	if mode == downloader.SnapShotSync && blockchain.CurrentBlock().NumberU64() > 0 {
+		log.Warn("Blockchain not empty, snapshot sync disabled")
+		mode = downloader.FullSync
+	}
 
 	if mode == downloader.FastSync {
 		manager.fastSync = uint32(1)
@@ -766,11 +772,13 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		transactions := make([][]*types.Transaction, len(request.BodiesData))
 		signs := make([][]*types.PbftSign, len(request.BodiesData))
 		infos := make([][]*types.CommitteeMember, len(request.BodiesData))
+		evidences := make([][]*types.DuplicateVoteEvidence, len(request.BodiesData))
 
 		for i, body := range request.BodiesData {
 			transactions[i] = body.Transactions
 			signs[i] = body.Signs
 			infos[i] = body.Infos
+			evidences[i] = body.Evidences
 			if len(body.Signs) == 0 {
 				log.Warn("FastBlockBodiesMsg", "transactions", len(body.Transactions), "signs", len(body.Signs), "infos", len(body.Infos))
 			}
@@ -778,13 +786,13 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		// Filter out any explicitly requested bodies, deliver the rest to the downloader
 		filter := len(transactions) > 0 || len(signs) > 0 || len(infos) > 0
 		if filter {
-			transactions, signs, infos = pm.fetcherFast.FilterBodies(p.id, transactions, signs, infos, time.Now())
+			transactions, signs, infos, evidences = pm.fetcherFast.FilterBodies(p.id, transactions, signs, infos, evidences, time.Now())
 		}
 		// mecMark
 		if len(transactions) > 0 || len(signs) > 0 || len(infos) > 0 || !filter {
 			if request.Call == types.DownloaderCall {
 				log.Debug("FastBlockBodiesMsg", "transactions", len(transactions), "signs", len(signs), "infos", len(infos), "filter", filter)
-				err := pm.fdownloader.DeliverBodies(p.id, transactions, signs, infos, request.Call)
+				err := pm.fdownloader.DeliverBodies(p.id, transactions, signs, infos, evidences, request.Call)
 				if err != nil {
 					log.Debug("Failed to deliver bodies", "err", err)
 				}
@@ -887,6 +895,125 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			log.Debug("Failed to deliver node state data", "err", err)
 		}
 
+	case msg.Code == GetAccountRangeMsg:
+		// Decode the account range query and serve it from the snapshot or the trie
+		var query getAccountRangeData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if query.Bytes > softResponseLimit {
+			query.Bytes = softResponseLimit
+		}
+		keys, values, proof, err := snapshot.AccountRange(pm.blockchain.Snapshot(), pm.blockchain.StateCache().TrieDB(), query.Root, query.Origin, query.Limit, query.Bytes)
+		if err != nil {
+			// The state is unavailable, reply with an empty range
+			log.Debug("Failed to serve account range", "root", query.Root, "err", err)
+			return p.SendAccountRange(&accountRangeData{})
+		}
+		accounts := make([]*dtype.AccountData, len(keys))
+		for i, key := range keys {
+			accounts[i] = &dtype.AccountData{Hash: key, Body: values[i]}
+		}
+		return p.SendAccountRange(&accountRangeData{Accounts: accounts, Proof: proof})
+
+	case msg.Code == AccountRangeMsg:
+		// A range of accounts arrived to one of our previous requests
+		var data accountRangeData
+		if err := msg.Decode(&data); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverAccountRange(p.id, data.Accounts, data.Proof); err != nil {
+			log.Debug("Failed to deliver account range", "err", err)
+		}
+
+	case msg.Code == GetStorageRangesMsg:
+		// Decode the storage ranges query and serve the accounts in order until
+		// the size limit is reached, the last range may be partial
+		var query getStorageRangesData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if query.Bytes > softResponseLimit {
+			query.Bytes = softResponseLimit
+		}
+		var (
+			snaps  = pm.blockchain.Snapshot()
+			triedb = pm.blockchain.StateCache().TrieDB()
+			bytes  uint64
+			slots  [][]*dtype.StorageData
+			proof  [][]byte
+		)
+		for i, account := range query.Accounts {
+			if bytes >= query.Bytes {
+				break
+			}
+			var origin common.Hash
+			if i == 0 {
+				origin = query.Origin
+			}
+			keys, values, accProof, err := snapshot.StorageRange(snaps, triedb, query.Root, account, origin, query.Bytes-bytes)
+			if err != nil {
+				log.Debug("Failed to serve storage range", "root", query.Root, "account", account, "err", err)
+				break
+			}
+			storage := make([]*dtype.StorageData, len(keys))
+			for j, key := range keys {
+				storage[j] = &dtype.StorageData{Hash: key, Body: values[j]}
+				bytes += uint64(common.HashLength + len(values[j]))
+			}
+			slots = append(slots, storage)
+			if accProof != nil {
+				proof = accProof
+				break
+			}
+		}
+		return p.SendStorageRanges(&storageRangesData{Slots: slots, Proof: proof})
+
+	case msg.Code == StorageRangesMsg:
+		// A batch of storage ranges arrived to one of our previous requests
+		var data storageRangesData
+		if err := msg.Decode(&data); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverStorageRanges(p.id, data.Slots, data.Proof); err != nil {
+			log.Debug("Failed to deliver storage ranges", "err", err)
+		}
+
+	case msg.Code == GetByteCodesMsg:
+		// Decode the retrieval message
+		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
+		if _, err := msgStream.List(); err != nil {
+			return err
+		}
+		// Gather contract codes until the fetch or network limits is reached
+		var (
+			hash  common.Hash
+			bytes int
+			codes [][]byte
+		)
+		for bytes < softResponseLimit && len(codes) < fastdownloader.MaxStateFetch {
+			if err := msgStream.Decode(&hash); err == rlp.EOL {
+				break
+			} else if err != nil {
+				return errResp(ErrDecode, "msg %v: %v", msg, err)
+			}
+			if code, err := pm.blockchain.TrieNode(hash); err == nil {
+				codes = append(codes, code)
+				bytes += len(code)
+			}
+		}
+		return p.SendByteCodes(codes)
+
+	case msg.Code == ByteCodesMsg:
+		// A batch of contract codes arrived to one of our previous requests
+		var codes [][]byte
+		if err := msg.Decode(&codes); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if err := pm.downloader.DeliverByteCodes(p.id, codes); err != nil {
+			log.Debug("Failed to deliver byte codes", "err", err)
+		}
+
 	case msg.Code == GetReceiptsMsg:
 		// Decode the retrieval message
 		msgStream := rlp.NewStream(msg.Payload, uint64(msg.Size))
@@ -84,6 +84,10 @@ var (
 	reqReceiptInTrafficMeter  = metrics.NewRegisteredMeter("etrue/req/receipts/in/traffic", nil)
 	reqReceiptOutPacketsMeter = metrics.NewRegisteredMeter("etrue/req/receipts/out/packets", nil)
 	reqReceiptOutTrafficMeter = metrics.NewRegisteredMeter("etrue/req/receipts/out/traffic", nil)
+	reqSnapInPacketsMeter     = metrics.NewRegisteredMeter("etrue/req/snap/in/packets", nil)
+	reqSnapInTrafficMeter     = metrics.NewRegisteredMeter("etrue/req/snap/in/traffic", nil)
+	reqSnapOutPacketsMeter    = metrics.NewRegisteredMeter("etrue/req/snap/out/packets", nil)
+	reqSnapOutTrafficMeter    = metrics.NewRegisteredMeter("etrue/req/snap/out/traffic", nil)
 
 	getHeadInPacketsMeter  = metrics.NewRegisteredMeter("etrue/get/head/in/packets", nil)
 	getHeadInTrafficMeter  = metrics.NewRegisteredMeter("etrue/get/head/in/traffic", nil)
@@ -149,6 +153,8 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		packets, traffic = reqStateInPacketsMeter, reqStateInTrafficMeter
 	case msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptInPacketsMeter, reqReceiptInTrafficMeter
+	case msg.Code == AccountRangeMsg || msg.Code == StorageRangesMsg || msg.Code == ByteCodesMsg:
+		packets, traffic = reqSnapInPacketsMeter, reqSnapInTrafficMeter
 
 	case msg.Code == NewFastBlockHashesMsg:
 		packets, traffic = propFHashInPacketsMeter, propFHashInTrafficMeter
@@ -200,6 +206,8 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		packets, traffic = reqStateOutPacketsMeter, reqStateOutTrafficMeter
 	case msg.Code == ReceiptsMsg:
 		packets, traffic = reqReceiptOutPacketsMeter, reqReceiptOutTrafficMeter
+	case msg.Code == AccountRangeMsg || msg.Code == StorageRangesMsg || msg.Code == ByteCodesMsg:
+		packets, traffic = reqSnapOutPacketsMeter, reqSnapOutTrafficMeter
 
 	case msg.Code == NewFastBlockHashesMsg:
 		packets, traffic = propFHashOutPacketsMeter, propFHashOutTrafficMeter
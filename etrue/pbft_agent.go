@@ -26,6 +26,8 @@ import (
 	"time"
 
 	"truechain/discovery/consensus/tbft/help"
+	"truechain/discovery/consensus/tbft/privval"
+	ttypes "truechain/discovery/consensus/tbft/types"
 	"truechain/discovery/utils"
 
 	"fmt"
@@ -59,6 +61,12 @@ const (
 	sendNodeTime        = 3 * time.Minute
 	maxKnownNodes       = 512
 	fetchBlockTime      = 2
+	maxPendingEvidences = 256
+)
+
+var (
+	errKnownEvidence    = errors.New("known evidence")
+	errEvidencePoolFull = errors.New("evidence pool is full")
 )
 
 var (
@@ -81,6 +89,14 @@ type Backend interface {
 	Etherbase() (etherbase common.Address, err error)
 }
 
+// committeeSigner signs the tbft votes and the PbftSigns with the committee
+// key held outside of the node.
+type committeeSigner interface {
+	ttypes.Signer
+	SignPbftSign(sign *types.PbftSign) error
+	Close() error
+}
+
 // PbftAgent receive events from election and communicate with pbftServer
 type PbftAgent struct {
 	config     *params.ChainConfig
@@ -97,8 +113,9 @@ type PbftAgent struct {
 	committeeIds             []*big.Int
 	endFastNumber            map[uint64]*big.Int
 
-	server   types.PbftServerProxy
-	election *elect.Election
+	server    types.PbftServerProxy
+	election  *elect.Election
+	discovery *committeeDiscovery // finds the committee nodes, nil if not started
 
 	mu           *sync.Mutex //generateBlock mutex
 	cacheBlockMu *sync.Mutex //PbftAgent.cacheBlock mutex
@@ -117,6 +134,7 @@ type PbftAgent struct {
 
 	committeeNode *types.CommitteeNode
 	privateKey    *ecdsa.PrivateKey
+	voteSigner    committeeSigner // signs the votes instead of privateKey if set
 	vmConfig      vm.Config
 
 	cacheBlock map[*big.Int]*types.Block //prevent receive same block
@@ -130,6 +148,9 @@ type PbftAgent struct {
 	broadcastNodeTag *utils.OrderedMap
 	gasFloor         uint64
 	gasCeil          uint64
+
+	evidenceMu *sync.Mutex                                  //PbftAgent.evidences mutex
+	evidences  map[common.Hash]*types.DuplicateVoteEvidence //double sign evidences to be included
 }
 
 // AgentWork is the leader current environment and holds
@@ -169,13 +190,15 @@ func NewPbftAgent(etrue Backend, config *params.ChainConfig, engine consensus.En
 		mu:                   new(sync.Mutex),
 		cacheBlockMu:         new(sync.Mutex),
 		cacheBlock:           make(map[*big.Int]*types.Block),
-		vmConfig:             vm.Config{EnablePreimageRecording: etrue.Config().EnablePreimageRecording},
+		vmConfig:             vm.Config{EnablePreimageRecording: etrue.Config().EnablePreimageRecording, ExecutionMode: etrue.Config().ExecutionMode},
 		gasFloor:             gasFloor,
 		gasCeil:              gasCeil,
 		knownRecievedNodes:   utils.NewOrderedMap(),
 		committeeNodeTag:     utils.NewOrderedMap(),
 		markNodeMu:           new(sync.Mutex),
 		broadcastNodeTag:     utils.NewOrderedMap(),
+		evidenceMu:           new(sync.Mutex),
+		evidences:            make(map[common.Hash]*types.DuplicateVoteEvidence),
 	}
 
 	agent.initNodeInfo(etrue)
@@ -194,6 +217,9 @@ func (agent *PbftAgent) initNodeInfo(etrue Backend) {
 	agent.initNodeWork()
 	agent.singleNode = config.NodeType
 	agent.privateKey = config.PrivateKey
+	if config.RemoteSigner != "" {
+		agent.voteSigner = privval.NewSignerClient(config.RemoteSigner, config.RemoteSignerKey)
+	}
 	agent.committeeNode = &types.CommitteeNode{
 		IP:        config.Host,
 		Port:      uint32(config.Port),
@@ -257,6 +283,7 @@ type nodeInfoWork struct {
 	tag               int
 	committeeInfo     *types.CommitteeInfo
 	ticker            *time.Ticker
+	fallback          *time.Timer // delayed first node info broadcast
 	isCommitteeMember bool
 	isCurrent         bool
 }
@@ -305,13 +332,21 @@ func (agent *PbftAgent) startSend(receivedCommitteeInfo *types.CommitteeInfo, is
 	nodeWork.loadNodeWork(receivedCommitteeInfo, isCommitteeMember)
 	if nodeWork.isCommitteeMember { //if node is current CommitteeMember
 		log.Info("node is committee member", "committeeId", receivedCommitteeInfo.Id)
-		agent.sendPbftNode(nodeWork)
+		if agent.discovery != nil {
+			agent.discovery.start(receivedCommitteeInfo)
+		}
+		if agent.discovery != nil && agent.discovery.searching() {
+			// Give the discovery a chance before falling back to the broadcast
+			nodeWork.fallback = time.AfterFunc(committeeFallbackDelay, func() { agent.sendPbftNodeFallback(nodeWork) })
+		} else {
+			agent.sendPbftNode(nodeWork)
+		}
 		nodeWork.ticker = time.NewTicker(sendNodeTime)
 		go func() {
 			for {
 				select {
 				case <-nodeWork.ticker.C:
-					agent.sendPbftNode(nodeWork)
+					agent.sendPbftNodeFallback(nodeWork)
 				}
 			}
 		}()
@@ -326,6 +361,13 @@ func (agent *PbftAgent) stopSend() {
 	if nodeWork.isCommitteeMember {
 		log.Info("nodeWork ticker stop", "committeeId", nodeWork.committeeInfo.Id)
 		nodeWork.ticker.Stop()
+		if nodeWork.fallback != nil {
+			nodeWork.fallback.Stop()
+			nodeWork.fallback = nil
+		}
+		if agent.discovery != nil {
+			agent.discovery.stop(nodeWork.committeeInfo.Id)
+		}
 	}
 	nodeWork.loadNodeWork(new(types.CommitteeInfo), false)
 }
@@ -832,6 +874,17 @@ func (agent *PbftAgent) sendPbftNode(nodeWork *nodeInfoWork) {
 	agent.sendAndMarkNode(cryNodeInfo)
 }
 
+//send committeeNode to p2p unless the committee discovery found all the members
+func (agent *PbftAgent) sendPbftNodeFallback(nodeWork *nodeInfoWork) {
+	if !nodeWork.isCommitteeMember {
+		return
+	}
+	if agent.discovery != nil && agent.discovery.complete(nodeWork.committeeInfo) {
+		return
+	}
+	agent.sendPbftNode(nodeWork)
+}
+
 func (agent *PbftAgent) sendAndMarkNode(cryptoNodeInfo *types.EncryptNodeMessage) {
 	new_cryptoNodeInfo := &cryptoNodeInfo
 	agent.MarkBroadcastNodeTag(*new_cryptoNodeInfo)
@@ -974,16 +1027,21 @@ func (agent *PbftAgent) FetchFastBlock(committeeID *big.Int, infos []*types.Comm
 			return fastBlock, err
 		}
 		work := agent.current
-		pending, _ := agent.eth.TxPool().Pending()
-		if len(pending) != 0 {
-			log.Info("has transaction...")
+		stakings, pending, _ := agent.eth.TxPool().PendingLanes()
+		if len(stakings) != 0 || len(pending) != 0 {
+			log.Info("has transaction...", "stakings", len(stakings))
 		}
-		txs := types.NewTransactionsByPriceAndNonce(work.signer, pending)
+		// Commit the calls to the staking contract in their lane ahead of the rest
+		// so that spam can't delay the committee changes.
+		txs := types.NewTransactionsByPriceAndNonce(work.signer, stakings)
+		work.commitTransactions(agent.mux, txs, agent.fastChain, feeAmount)
+		txs = types.NewTransactionsByPriceAndNonce(work.signer, pending)
 		work.commitTransactions(agent.mux, txs, agent.fastChain, feeAmount)
 		//calculate snailBlock reward
 		agent.rewardSnailBlock(header)
+		evidences := agent.pendingEvidences(header.Number, work.state)
 		//padding Header.Root, TxHash, ReceiptHash.  Create the new block to seal with the consensus engine
-		if fastBlock, _, err = agent.engine.Finalize(agent.fastChain, header, work.state, work.txs, work.receipts, feeAmount, true); err != nil {
+		if fastBlock, _, err = agent.engine.Finalize(agent.fastChain, header, work.state, work.txs, work.receipts, evidences, feeAmount, true); err != nil {
 			log.Error("Failed to finalize block for sealing", "err", err)
 			return fastBlock, err
 		}
@@ -998,6 +1056,60 @@ func (agent *PbftAgent) FetchFastBlock(committeeID *big.Int, infos []*types.Comm
 	return fastBlock, err
 }
 
+//AddEvidence keeps the double sign evidence until it's included in a fast block
+func (agent *PbftAgent) AddEvidence(ev *types.DuplicateVoteEvidence) error {
+	agent.evidenceMu.Lock()
+	defer agent.evidenceMu.Unlock()
+	hash := ev.Hash()
+	if _, ok := agent.evidences[hash]; ok {
+		return errKnownEvidence
+	}
+	if len(agent.evidences) >= maxPendingEvidences {
+		return errEvidencePoolFull
+	}
+	next := new(big.Int).Add(agent.fastChain.CurrentBlock().Number(), common.Big1)
+	if err := agent.engine.VerifyEvidences(next, []*types.DuplicateVoteEvidence{ev}); err != nil {
+		return err
+	}
+	agent.evidences[hash] = ev
+	log.Info("AddEvidence", "hash", hash, "pending", len(agent.evidences))
+	return nil
+}
+
+//pendingEvidences returns the evidences to be included in the fast block and
+//drops the ones which are stale or slashed already
+func (agent *PbftAgent) pendingEvidences(number *big.Int, statedb *state.StateDB) []*types.DuplicateVoteEvidence {
+	agent.evidenceMu.Lock()
+	defer agent.evidenceMu.Unlock()
+	if len(agent.evidences) == 0 || !agent.config.IsTIPSlash(number) {
+		return nil
+	}
+	impawn := vm.NewImpawnImpl()
+	if err := impawn.Load(statedb, types.StakingAddress); err != nil {
+		log.Warn("pendingEvidences load impawn failed", "number", number, "err", err)
+		return nil
+	}
+	var evidences []*types.DuplicateVoteEvidence
+	for hash, ev := range agent.evidences {
+		pubkey, height, err := ev.Verify()
+		if err != nil || impawn.IsSlashed(pubkey, height) {
+			delete(agent.evidences, hash)
+			continue
+		}
+		if len(evidences) >= params.MaxEvidencesPerBlock {
+			continue
+		}
+		if err := agent.engine.VerifyEvidences(number, append(evidences, ev)); err != nil {
+			if err != consensus.ErrDuplicateEvidence {
+				delete(agent.evidences, hash)
+			}
+			continue
+		}
+		evidences = append(evidences, ev)
+	}
+	return evidences
+}
+
 //GetCurrentHeight return  current fastBlock number
 func (agent *PbftAgent) GetCurrentHeight() *big.Int {
 	num := new(big.Int).Set(agent.fastChain.CurrentBlock().Number())
@@ -1081,8 +1193,12 @@ func (agent *PbftAgent) GenerateSignWithVote(fb *types.Block, vote uint32, resul
 		log.Warn("vote AgreeAgainst", "number", fb.Number(), "hash", fb.Hash(), "vote", vote, "result", result)
 	}
 	var err error
-	signHash := voteSign.HashWithNoSign().Bytes()
-	voteSign.Sign, err = crypto.Sign(signHash, agent.privateKey)
+	if agent.voteSigner != nil {
+		err = agent.voteSigner.SignPbftSign(voteSign)
+	} else {
+		signHash := voteSign.HashWithNoSign().Bytes()
+		voteSign.Sign, err = crypto.Sign(signHash, agent.privateKey)
+	}
 	if err != nil {
 		log.Error("fb GenerateSign error ", "err", err)
 	}
@@ -1234,7 +1350,7 @@ func (agent *PbftAgent) makeCurrent(parent *types.Block, header *types.Header) e
 	}
 	work := &AgentWork{
 		config:    agent.config,
-		signer:    types.NewTIP1Signer(agent.config.ChainID),
+		signer:    types.MakeSigner(agent.config, header.Number),
 		state:     state,
 		header:    header,
 		createdAt: time.Now(),
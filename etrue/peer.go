@@ -663,6 +663,43 @@ func (p *peer) RequestReceipts(hashes []common.Hash, isFastchain bool) error {
 	return p.Send(GetReceiptsMsg, hashes)
 }
 
+// RequestAccountRange fetches a range of accounts of the state of the root,
+// from the origin to the limit.
+func (p *peer) RequestAccountRange(root, origin, limit common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching range of accounts  GetAccountRangeMsg", "root", root, "origin", origin, "limit", limit, "bytes", common.StorageSize(bytes))
+	return p.Send(GetAccountRangeMsg, &getAccountRangeData{Root: root, Origin: origin, Limit: limit, Bytes: bytes})
+}
+
+// RequestStorageRanges fetches the storage slots of accounts of the state of
+// the root, from the origin for the first one.
+func (p *peer) RequestStorageRanges(root common.Hash, accounts []common.Hash, origin common.Hash, bytes uint64) error {
+	p.Log().Debug("Fetching ranges of storage slots  GetStorageRangesMsg", "root", root, "accounts", len(accounts), "origin", origin, "bytes", common.StorageSize(bytes))
+	return p.Send(GetStorageRangesMsg, &getStorageRangesData{Root: root, Accounts: accounts, Origin: origin, Bytes: bytes})
+}
+
+// RequestByteCodes fetches a batch of contract codes by their hashes.
+func (p *peer) RequestByteCodes(hashes []common.Hash) error {
+	p.Log().Debug("Fetching batch of byte codes  GetByteCodesMsg", "count", len(hashes))
+	return p.Send(GetByteCodesMsg, hashes)
+}
+
+// SendAccountRange sends a range of accounts with its proof.
+func (p *peer) SendAccountRange(data *accountRangeData) error {
+	return p.Send(AccountRangeMsg, data)
+}
+
+// SendStorageRanges sends the storage slots of accounts with the proof of the
+// last range.
+func (p *peer) SendStorageRanges(data *storageRangesData) error {
+	return p.Send(StorageRangesMsg, data)
+}
+
+// SendByteCodes sends a batch of contract codes, corresponding to the hashes
+// requested.
+func (p *peer) SendByteCodes(codes [][]byte) error {
+	return p.Send(ByteCodesMsg, codes)
+}
+
 func (p *peer) Send(msgcode uint64, data interface{}) error {
 	err := p2p.Send(p.rw, msgcode, data)
 
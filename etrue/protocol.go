@@ -25,6 +25,7 @@ import (
 
 	"truechain/discovery/common"
 	"truechain/discovery/core/types"
+	dtype "truechain/discovery/etrue/types"
 	"truechain/discovery/event"
 	"truechain/discovery/rlp"
 )
@@ -75,6 +76,14 @@ const (
 
 	TbftNodeInfoHashMsg = 0x15
 	GetTbftNodeInfoMsg  = 0x16
+
+	// Protocol messages belonging to etrue/64, to download the state in ranges
+	GetAccountRangeMsg  = 0x17
+	AccountRangeMsg     = 0x18
+	GetStorageRangesMsg = 0x19
+	StorageRangesMsg    = 0x1a
+	GetByteCodesMsg     = 0x1b
+	ByteCodesMsg        = 0x1c
 )
 
 type errCode int
@@ -260,9 +269,10 @@ type BlockBodiesRawData struct {
 
 // blockBody represents the data content of a single block.
 type blockBody struct {
-	Transactions []*types.Transaction     // Transactions contained within a block
-	Signs        []*types.PbftSign        // Signs contained within a block
-	Infos        []*types.CommitteeMember //change info
+	Transactions []*types.Transaction           // Transactions contained within a block
+	Signs        []*types.PbftSign              // Signs contained within a block
+	Infos        []*types.CommitteeMember       //change info
+	Evidences    []*types.DuplicateVoteEvidence `rlp:"tail"` // Evidences contained within a block
 }
 
 // blockBodiesData is the network packet for block content distribution.
@@ -282,3 +292,34 @@ type snailBlockBodiesData struct {
 	BodiesData []*snailBlockBody
 	Call       uint32 // Distinguish fetcher and downloader
 }
+
+// getAccountRangeData represents an account range query.
+type getAccountRangeData struct {
+	Root   common.Hash // Root of the state to serve the accounts of
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the last account to retrieve
+	Bytes  uint64      // Soft limit of the response size
+}
+
+// accountRangeData is the network packet of the accounts in a range, with the
+// proofs of the first and last ones (of the origin if none).
+type accountRangeData struct {
+	Accounts []*dtype.AccountData
+	Proof    [][]byte
+}
+
+// getStorageRangesData represents a query of the storage ranges of accounts.
+type getStorageRangesData struct {
+	Root     common.Hash   // Root of the state to serve the storage of
+	Accounts []common.Hash // Hashes of the accounts to retrieve the storage of
+	Origin   common.Hash   // Hash of the first storage slot of the first account
+	Bytes    uint64        // Soft limit of the response size
+}
+
+// storageRangesData is the network packet of the storage slots of accounts.
+// The proof is of the first and last slots of the last account, if its range
+// isn't its whole storage.
+type storageRangesData struct {
+	Slots [][]*dtype.StorageData
+	Proof [][]byte
+}
@@ -318,10 +318,9 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 	if atomic.LoadUint32(&pm.fastSync) == 1 {
 		// Fast sync was explicitly requested, and explicitly granted
 		mode = downloader.FastSync
-
-		//else if atomic.LoadUint32(&pm.snapSync) == 1 {
-		//	mode = downloader.SnapShotSync
-		//}
+	} else if atomic.LoadUint32(&pm.snapSync) == 1 {
+		// Snapshot sync was explicitly requested, and explicitly granted
+		mode = downloader.SnapShotSync
 	} else if pm.blockchain.CurrentBlock().NumberU64() == 0 && pm.blockchain.CurrentFastBlock().NumberU64() > 0 {
 		// The database  seems empty as the current block is the genesis. Yet the fast
 		// block is ahead, so fast sync was enabled for this node at a certain point.
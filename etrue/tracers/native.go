@@ -0,0 +1,58 @@
+package tracers
+
+import (
+	"sync/atomic"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/state"
+	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
+	"truechain/discovery/log"
+)
+
+// Context is the environment a transaction is traced in, given to the native
+// tracers.
+type Context struct {
+	StateDB *state.StateDB // State the transaction is run on, before it runs
+}
+
+// stopper implements the interruption of the native tracers.
+type stopper struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// Stop terminates the tracing at the first opportune moment.
+func (s *stopper) Stop(err error) {
+	s.reason = err
+	atomic.StoreUint32(&s.interrupt, 1)
+}
+
+// stopped returns whether the tracing was interrupted.
+func (s *stopper) stopped() bool {
+	return atomic.LoadUint32(&s.interrupt) > 0
+}
+
+// isPrecompiled returns whether the address is a precompiled contract skipped
+// by the tracers, the same ones as the JavaScript tracers skip. The staking
+// contract isn't one of them, its calls are reported.
+func isPrecompiled(addr common.Address) bool {
+	if addr == types.StakingAddress {
+		return false
+	}
+	_, ok := vm.PrecompiledContractsByzantium[addr]
+	return ok
+}
+
+// memorySlice returns a copy of the memory in the range, or nil if it's out of
+// bounds.
+func memorySlice(memory *vm.Memory, offset, size uint64) []byte {
+	if size == 0 {
+		return nil
+	}
+	if end := offset + size; end < offset || uint64(memory.Len()) < end {
+		log.Warn("Tracer accessed out of bound memory", "available", memory.Len(), "offset", offset, "size", size)
+		return nil
+	}
+	return memory.GetCopy(int64(offset), int64(size))
+}
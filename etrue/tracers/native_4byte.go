@@ -0,0 +1,85 @@
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/core/vm"
+)
+
+// fourByteTracer is the native 4byteTracer, collecting the method identifiers
+// of the calls made by a transaction along with the size of the data passed,
+// for the signatures to be matched against them.
+type fourByteTracer struct {
+	stopper
+
+	ids map[string]int // Calls made by identifier and data size
+}
+
+// newFourByteTracer creates a native 4byteTracer.
+func newFourByteTracer(ctx *Context, config json.RawMessage) (Tracer, error) {
+	return &fourByteTracer{ids: make(map[string]int)}, nil
+}
+
+// store counts a call of the identifier with the data size.
+func (t *fourByteTracer) store(id []byte, size uint64) {
+	t.ids[fmt.Sprintf("0x%x-%d", id, size)]++
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *fourByteTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	// Save the outer calldata also
+	if len(input) >= 4 {
+		t.store(input[:4], uint64(len(input)-4))
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rdata []byte, contract *vm.Contract, depth int, err error) error {
+	if t.stopped() || err != nil {
+		return nil
+	}
+	// Skip any opcodes that are not internal calls, and the precompiles
+	var off int
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		off = 3 // gas, addr, value, in offset, in size, out offset, out size
+	case vm.DELEGATECALL, vm.STATICCALL:
+		off = 2 // gas, addr, in offset, in size, out offset, out size
+	default:
+		return nil
+	}
+	if isPrecompiled(common.Address(stack.Back(1).Bytes20())) {
+		return nil
+	}
+	if size := stack.Back(off + 1).Uint64(); size >= 4 {
+		if id := memorySlice(memory, stack.Back(off).Uint64(), 4); id != nil {
+			t.store(id, size-4)
+		}
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *fourByteTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the identifiers collected, or the reason the tracing was
+// interrupted.
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	if t.stopped() {
+		return nil, t.reason
+	}
+	return json.Marshal(t.ids)
+}
@@ -0,0 +1,260 @@
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
+)
+
+// callFrame is a call made by a transaction, in the format of the JavaScript
+// callTracer. The calls to the staking contract carry the name of the method
+// called.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Input   hexutil.Bytes   `json:"input"`
+	Method  string          `json:"method,omitempty"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Time    string          `json:"time,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+
+	gasIn   uint64 // Gas left before the call opcode ran
+	gasCost uint64 // Cost of the call opcode
+	outOff  uint64 // Memory offset of the call output
+	outLen  uint64 // Memory size of the call output
+}
+
+// callTracer is the native callTracer, reporting the internal calls made by a
+// transaction the same way the JavaScript one does, without its overhead.
+type callTracer struct {
+	stopper
+
+	callstack []*callFrame // Current call stack, the transaction first
+	descended bool         // Whether a call was just descended into
+}
+
+// newCallTracer creates a native callTracer.
+func newCallTracer(ctx *Context, config json.RawMessage) (Tracer, error) {
+	return &callTracer{callstack: []*callFrame{{}}}, nil
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *callTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	call := t.callstack[0]
+	call.Type = "CALL"
+	if create {
+		call.Type = "CREATE"
+	}
+	call.From, call.To = from, &to
+	call.Input = common.CopyBytes(input)
+	call.Gas = (*hexutil.Uint64)(&gas)
+	if value != nil {
+		call.Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+	if !create && to == types.StakingAddress {
+		call.Method = vm.StakingMethodName(input)
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rdata []byte, contract *vm.Contract, depth int, err error) error {
+	if t.stopped() {
+		return nil
+	}
+	// Capture any errors immediately
+	if err != nil {
+		t.fault(err)
+		return nil
+	}
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		// A new contract is being created, add it to the call stack
+		inOff, inLen := stack.Back(1).Uint64(), stack.Back(2).Uint64()
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			Input:   memorySlice(memory, inOff, inLen),
+			Value:   (*hexutil.Big)(stack.Back(0).ToBig()),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return nil
+
+	case vm.SELFDESTRUCT:
+		// A contract is being self destructed, gather it as a subcall too
+		to := common.Address(stack.Back(0).Bytes20())
+		t.push(&callFrame{
+			Type:  op.String(),
+			From:  contract.Address(),
+			To:    &to,
+			Value: (*hexutil.Big)(env.StateDB.GetBalance(contract.Address())),
+		})
+		return nil
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// A new method is being invoked, skipping the precompiled contracts as
+		// those are just fancy opcodes
+		to := common.Address(stack.Back(1).Bytes20())
+		if isPrecompiled(to) {
+			return nil
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		inOff, inLen := stack.Back(2+off).Uint64(), stack.Back(3+off).Uint64()
+		call := &callFrame{
+			Type:    op.String(),
+			From:    contract.Address(),
+			To:      &to,
+			Input:   memorySlice(memory, inOff, inLen),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  stack.Back(4 + off).Uint64(),
+			outLen:  stack.Back(5 + off).Uint64(),
+		}
+		if off == 1 {
+			call.Value = (*hexutil.Big)(stack.Back(2).ToBig())
+		}
+		if to == types.StakingAddress {
+			call.Method = vm.StakingMethodName(call.Input)
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return nil
+	}
+	// If an inner call was just descended into, retrieve its true allowance from
+	// within it, as the gas given may differ from the gas requested (2300
+	// stipend, 63/64 rule). The calls of plain accounts and of the staking
+	// contract run no code, their allowance isn't known.
+	if t.descended {
+		if depth >= len(t.callstack) {
+			allowance := gas
+			t.callstack[len(t.callstack)-1].Gas = (*hexutil.Uint64)(&allowance)
+		}
+		t.descended = false
+	}
+	// If the current call is reverting, mark it so
+	if op == vm.REVERT {
+		t.callstack[len(t.callstack)-1].Error = "execution reverted"
+		return nil
+	}
+	// If an inner call returned, pop it off the call stack
+	if depth == len(t.callstack)-1 {
+		call := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		ret := stack.Back(0)
+		switch {
+		case call.Type == vm.CREATE.String() || call.Type == vm.CREATE2.String():
+			// Retrieve the address and the code of the created contract
+			used := call.gasIn - call.gasCost - gas
+			call.GasUsed = (*hexutil.Uint64)(&used)
+
+			if !ret.IsZero() {
+				to := common.Address(ret.Bytes20())
+				code := hexutil.Bytes(env.StateDB.GetCode(to))
+				call.To, call.Output = &to, &code
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+
+		case call.Gas != nil:
+			// Retrieve the gas used and the output of the contract call
+			used := call.gasIn - call.gasCost + uint64(*call.Gas) - gas
+			call.GasUsed = (*hexutil.Uint64)(&used)
+
+			if !ret.IsZero() {
+				output := hexutil.Bytes(memorySlice(memory, call.outOff, call.outLen))
+				call.Output = &output
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+
+		case *call.To == types.StakingAddress:
+			// The staking contract reverts on any failure
+			if !ret.IsZero() {
+				output := hexutil.Bytes(memorySlice(memory, call.outOff, call.outLen))
+				call.Output = &output
+			} else {
+				call.Error = vm.ErrExecutionReverted.Error()
+			}
+		}
+		t.push(call)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	if !t.stopped() {
+		t.fault(err)
+	}
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	call := t.callstack[0]
+	call.GasUsed = (*hexutil.Uint64)(&gasUsed)
+	call.Time = d.String()
+
+	if call.Error == "" && err != nil {
+		call.Error = err.Error()
+	}
+	if call.Error == "" {
+		out := hexutil.Bytes(common.CopyBytes(output))
+		call.Output = &out
+	}
+	return nil
+}
+
+// GetResult returns the calls of the transaction, or the reason the tracing was
+// interrupted.
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if t.stopped() {
+		return nil, t.reason
+	}
+	return json.Marshal(t.callstack[0])
+}
+
+// fault pops off the call failed and flattens it into its parent, leaving it
+// in the stack if it's the transaction.
+func (t *callTracer) fault(err error) {
+	// If the current call already reverted, don't handle the additional fault
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	// Consume all the gas available
+	call.Error = err.Error()
+	if call.Gas != nil {
+		call.GasUsed = call.Gas
+	}
+	if len(t.callstack) > 0 {
+		t.push(call)
+		return
+	}
+	t.callstack = append(t.callstack, call)
+}
+
+// push adds the call to the ones made by the current call.
+func (t *callTracer) push(call *callFrame) {
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, call)
+}
@@ -0,0 +1,228 @@
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+	"truechain/discovery/core/state"
+	"truechain/discovery/core/vm"
+	"truechain/discovery/crypto"
+)
+
+// errNoTraceState is returned if a tracer reading the state isn't given it.
+var errNoTraceState = errors.New("tracer needs the state of the transaction")
+
+// prestateAccount is an account in the state before a transaction, or the
+// changes the transaction made to it in diff mode.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// prestateTracerConfig is the config of the native prestateTracer.
+type prestateTracerConfig struct {
+	DiffMode bool `json:"diffMode"` // Report the state before and after the transaction, changed only
+}
+
+// prestateTracer is the native prestateTracer, reporting the accounts and the
+// storage slots a transaction accessed as they were before it, enough to run
+// it again from a custom genesis. In diff mode, the ones the transaction
+// changed are reported as they were before and after it.
+//
+// The state before the transaction is read from a copy of it taken when the
+// tracer is created, rather than by reverting the changes made to the accounts
+// by the time they're first accessed as the JavaScript tracer does.
+type prestateTracer struct {
+	stopper
+
+	config prestateTracerConfig
+	pre    *state.StateDB                              // Copy of the state before the transaction
+	post   *state.StateDB                              // State the transaction is run on
+	access map[common.Address]map[common.Hash]struct{} // Accounts and storage slots accessed
+}
+
+// newPrestateTracer creates a native prestateTracer, the context must hold the
+// state the transaction is run on.
+func newPrestateTracer(ctx *Context, config json.RawMessage) (Tracer, error) {
+	if ctx == nil || ctx.StateDB == nil {
+		return nil, errNoTraceState
+	}
+	t := &prestateTracer{
+		pre:    ctx.StateDB.Copy(),
+		post:   ctx.StateDB,
+		access: make(map[common.Address]map[common.Hash]struct{}),
+	}
+	if len(config) > 0 {
+		if err := json.Unmarshal(config, &t.config); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.lookupAccount(from)
+	t.lookupAccount(to)
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, rdata []byte, contract *vm.Contract, depth int, err error) error {
+	if t.stopped() || err != nil {
+		return nil
+	}
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.EXTCODEHASH, vm.BALANCE, vm.SELFDESTRUCT:
+		t.lookupAccount(common.Address(stack.Back(0).Bytes20()))
+
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+
+	case vm.CREATE2:
+		offset, size := stack.Back(1).Uint64(), stack.Back(2).Uint64()
+		code := memorySlice(memory, offset, size)
+		t.lookupAccount(crypto.CreateAddress2(contract.Address(), stack.Back(3).Bytes32(), crypto.Keccak256(code)))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.Address(stack.Back(1).Bytes20()))
+
+	case vm.SLOAD, vm.SSTORE:
+		t.lookupStorage(contract.Address(), common.Hash(stack.Back(0).Bytes32()))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rStack *vm.ReturnStack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the accounts accessed by the transaction as they were
+// before it, or the ones it changed before and after it in diff mode.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.stopped() {
+		return nil, t.reason
+	}
+	if !t.config.DiffMode {
+		prestate := make(map[common.Address]*prestateAccount)
+		for addr, slots := range t.access {
+			if t.pre.Exist(addr) {
+				prestate[addr] = readAccount(t.pre, addr, slots)
+			}
+		}
+		return json.Marshal(prestate)
+	}
+	diff := struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}{
+		Pre:  make(map[common.Address]*prestateAccount),
+		Post: make(map[common.Address]*prestateAccount),
+	}
+	for addr, slots := range t.access {
+		var pre, post *prestateAccount
+		if t.pre.Exist(addr) {
+			pre = readAccount(t.pre, addr, slots)
+		}
+		if t.post.Exist(addr) && !t.post.HasSuicided(addr) && !t.post.Empty(addr) {
+			post = readAccount(t.post, addr, slots)
+		}
+		switch {
+		case pre == nil && post == nil:
+			continue
+
+		case pre == nil:
+			// Created, report the account as a whole
+			diff.Post[addr] = post
+			continue
+
+		case post == nil:
+			// Deleted, report the account as a whole
+			diff.Pre[addr] = pre
+			continue
+		}
+		// Keep the changed fields only in the post state, and the changed slots
+		// only in both
+		var (
+			changed  = new(prestateAccount)
+			prestore = make(map[common.Hash]common.Hash)
+			modified bool
+		)
+		if pre.Balance.ToInt().Cmp(post.Balance.ToInt()) != 0 {
+			changed.Balance, modified = post.Balance, true
+		}
+		if pre.Nonce != post.Nonce {
+			changed.Nonce, modified = post.Nonce, true
+		}
+		if string(pre.Code) != string(post.Code) {
+			changed.Code, modified = post.Code, true
+		}
+		for slot := range slots {
+			before, after := pre.Storage[slot], post.Storage[slot]
+			if before == after {
+				continue
+			}
+			if before != (common.Hash{}) {
+				prestore[slot] = before
+			}
+			if after != (common.Hash{}) {
+				if changed.Storage == nil {
+					changed.Storage = make(map[common.Hash]common.Hash)
+				}
+				changed.Storage[slot] = after
+			}
+			modified = true
+		}
+		if !modified {
+			continue
+		}
+		pre.Storage = prestore
+		diff.Pre[addr] = pre
+		diff.Post[addr] = changed
+	}
+	return json.Marshal(diff)
+}
+
+// lookupAccount marks the account as accessed.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.access[addr]; !ok {
+		t.access[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+// lookupStorage marks the storage slot of the account as accessed.
+func (t *prestateTracer) lookupStorage(addr common.Address, slot common.Hash) {
+	t.lookupAccount(addr)
+	t.access[addr][slot] = struct{}{}
+}
+
+// readAccount reads the account and the non-empty storage slots of it from the
+// state.
+func readAccount(statedb *state.StateDB, addr common.Address, slots map[common.Hash]struct{}) *prestateAccount {
+	account := &prestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(statedb.GetBalance(addr))),
+		Nonce:   statedb.GetNonce(addr),
+		Code:    statedb.GetCode(addr),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	for slot := range slots {
+		if value := statedb.GetState(addr, slot); value != (common.Hash{}) {
+			account.Storage[slot] = value
+		}
+	}
+	return account
+}
@@ -14,19 +14,41 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-// Package tracers is a collection of JavaScript transaction tracers.
+// Package tracers is a collection of JavaScript and native transaction tracers.
 package tracers
 
 import (
+	"encoding/json"
 	"strings"
 	"unicode"
 
+	"truechain/discovery/core/vm"
 	"truechain/discovery/etrue/tracers/internal/tracers"
 )
 
+// Tracer is a transaction tracer run by the tracing API, reporting its result
+// once the execution is done.
+type Tracer interface {
+	vm.Tracer
+
+	// GetResult returns the result of the tracing, or any error that occurred.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates the tracing at the first opportune moment.
+	Stop(err error)
+}
+
 // all contains all the built in JavaScript tracers by name.
 var all = make(map[string]string)
 
+// natives contains the constructors of the native tracers by name, they take
+// precedence over the JavaScript tracers of the same name.
+var natives = map[string]func(ctx *Context, config json.RawMessage) (Tracer, error){
+	"callTracer":     newCallTracer,
+	"prestateTracer": newPrestateTracer,
+	"4byteTracer":    newFourByteTracer,
+}
+
 // camel converts a snake cased input string into a camel cased output.
 func camel(str string) string {
 	pieces := strings.Split(str, "_")
@@ -51,3 +73,13 @@ func tracer(name string) (string, bool) {
 	}
 	return "", false
 }
+
+// NewTracer creates the native tracer of the name if there is one, and the
+// JavaScript tracer of the code otherwise. The context and the config are
+// passed to the native tracers only.
+func NewTracer(code string, ctx *Context, config json.RawMessage) (Tracer, error) {
+	if constructor, ok := natives[code]; ok {
+		return constructor(ctx, config)
+	}
+	return New(code)
+}
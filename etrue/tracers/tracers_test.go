@@ -0,0 +1,419 @@
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"truechain/discovery/accounts/abi"
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+	"truechain/discovery/common/math"
+	"truechain/discovery/core"
+	"truechain/discovery/core/state"
+	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/params"
+	"truechain/discovery/rlp"
+	"truechain/discovery/tests"
+)
+
+// errTestStop is the reason the tracers are stopped for in the tests.
+var errTestStop = errors.New("stopped by test")
+
+// callContext is the block context of a tracer test.
+type callContext struct {
+	Number     math.HexOrDecimal64   `json:"number"`
+	Difficulty *math.HexOrDecimal256 `json:"difficulty"`
+	Time       math.HexOrDecimal64   `json:"timestamp"`
+	GasLimit   math.HexOrDecimal64   `json:"gasLimit"`
+	Miner      common.Address        `json:"miner"`
+}
+
+// callTracerTest is a transaction run on a prestate, along with the calls it
+// made as reported by the callTracer.
+type callTracerTest struct {
+	Genesis struct {
+		Alloc types.GenesisAlloc `json:"alloc"`
+	} `json:"genesis"`
+	Context *callContext `json:"context"`
+	Input   string       `json:"input"`
+	Result  *callFrame   `json:"result"`
+}
+
+// run executes the transaction of the test on a fresh prestate with the tracer
+// of the name, native or JavaScript.
+func (test *callTracerTest) run(t *testing.T, name string, native bool, config json.RawMessage) (json.RawMessage, *state.StateDB) {
+	raw := new(types.RawTransaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), raw); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	tx := raw.ConvertTransaction()
+	msg := types.NewMessage(test.Result.From, tx.To(), common.Address{}, tx.Nonce(), tx.Value(), tx.Fee(), tx.Gas(), tx.GasPrice(), tx.Data(), tx.AccessList(), false)
+
+	return runMessage(t, test.Genesis.Alloc, test.Context, msg, name, native, config)
+}
+
+// runMessage executes the message on the prestate of the alloc with the tracer
+// of the name, native or JavaScript.
+func runMessage(t *testing.T, alloc types.GenesisAlloc, ctx *callContext, msg types.Message, name string, native bool, config json.RawMessage) (json.RawMessage, *state.StateDB) {
+	var (
+		statedb = tests.MakePreState(etruedb.NewMemDatabase(), alloc)
+		tracer  Tracer
+		err     error
+	)
+	if native {
+		tracer, err = NewTracer(name, &Context{StateDB: statedb}, config)
+	} else {
+		tracer, err = New(name)
+	}
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", name, err)
+	}
+	blockCtx := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Coinbase:    ctx.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(ctx.Number)),
+		Time:        new(big.Int).SetUint64(uint64(ctx.Time)),
+		Difficulty:  (*big.Int)(ctx.Difficulty),
+		GasLimit:    uint64(ctx.GasLimit),
+	}
+	txCtx := vm.TxContext{
+		Origin:   msg.From(),
+		GasPrice: msg.GasPrice(),
+	}
+	evm := vm.NewEVM(blockCtx, txCtx, statedb, params.TestChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	if _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	res, err := tracer.GetResult()
+	if err != nil {
+		t.Fatalf("failed to retrieve trace result: %v", err)
+	}
+	return res, statedb
+}
+
+// loadCallTracerTests loads the callTracer testcases by name.
+func loadCallTracerTests(t *testing.T) map[string]*callTracerTest {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	cases := make(map[string]*callTracerTest)
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), "call_tracer_") {
+			continue
+		}
+		blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+		if err != nil {
+			t.Fatalf("failed to read testcase: %v", err)
+		}
+		test := new(callTracerTest)
+		if err := json.Unmarshal(blob, test); err != nil {
+			t.Fatalf("failed to parse testcase: %v", err)
+		}
+		cases[camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json"))] = test
+	}
+	return cases
+}
+
+// decodeCalls decodes a callTracer result, dropping the timing.
+func decodeCalls(t *testing.T, blob json.RawMessage) *callFrame {
+	call := new(callFrame)
+	if err := json.Unmarshal(blob, call); err != nil {
+		t.Fatalf("failed to unmarshal trace result: %v", err)
+	}
+	call.Time = ""
+	return call
+}
+
+// sameCalls checks that the calls were made between the same accounts with the
+// same values, gas, inputs, outputs and failures.
+func sameCalls(have, want *callFrame) bool {
+	if have.Type != want.Type || have.From != want.From || !reflect.DeepEqual(have.To, want.To) {
+		return false
+	}
+	if !sameBig(have.Value, want.Value) || !sameUint64(have.Gas, want.Gas) || !sameUint64(have.GasUsed, want.GasUsed) {
+		return false
+	}
+	if string(have.Input) != string(want.Input) || !sameBytes(have.Output, want.Output) {
+		return false
+	}
+	if have.Error != want.Error || len(have.Calls) != len(want.Calls) {
+		return false
+	}
+	for i := range have.Calls {
+		if !sameCalls(have.Calls[i], want.Calls[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameBig, sameUint64 and sameBytes compare the optional fields of the calls, a
+// zero value is the same as a missing one.
+func sameBig(have, want *hexutil.Big) bool {
+	h, w := new(big.Int), new(big.Int)
+	if have != nil {
+		h = have.ToInt()
+	}
+	if want != nil {
+		w = want.ToInt()
+	}
+	return h.Cmp(w) == 0
+}
+
+func sameUint64(have, want *hexutil.Uint64) bool {
+	var h, w hexutil.Uint64
+	if have != nil {
+		h = *have
+	}
+	if want != nil {
+		w = *want
+	}
+	return h == w
+}
+
+func sameBytes(have, want *hexutil.Bytes) bool {
+	var h, w hexutil.Bytes
+	if have != nil {
+		h = *have
+	}
+	if want != nil {
+		w = *want
+	}
+	return bytes.Equal(h, w)
+}
+
+// Tests that the native callTracer reports the same calls as the JavaScript one.
+func TestCallTracerParity(t *testing.T) {
+	for name, test := range loadCallTracerTests(t) {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jsRes, _ := test.run(t, "callTracer", false, nil)
+			nativeRes, _ := test.run(t, "callTracer", true, nil)
+
+			have, want := decodeCalls(t, nativeRes), decodeCalls(t, jsRes)
+			if !reflect.DeepEqual(have, want) {
+				haveBlob, _ := json.MarshalIndent(have, "", "  ")
+				wantBlob, _ := json.MarshalIndent(want, "", "  ")
+				t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", string(haveBlob), string(wantBlob))
+			}
+			if !sameCalls(have, test.Result) {
+				t.Fatalf("calls mismatch the expected ones")
+			}
+		})
+	}
+}
+
+// Tests that the native 4byteTracer collects the same identifiers as the
+// JavaScript one.
+func TestFourByteTracerParity(t *testing.T) {
+	for name, test := range loadCallTracerTests(t) {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jsRes, _ := test.run(t, "4byteTracer", false, nil)
+			nativeRes, _ := test.run(t, "4byteTracer", true, nil)
+
+			var have, want map[string]int
+			if err := json.Unmarshal(nativeRes, &have); err != nil {
+				t.Fatalf("failed to unmarshal native result: %v", err)
+			}
+			if err := json.Unmarshal(jsRes, &want); err != nil {
+				t.Fatalf("failed to unmarshal JavaScript result: %v", err)
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Fatalf("identifiers mismatch: have %v, want %v", have, want)
+			}
+		})
+	}
+}
+
+// checkPrestate checks that the accounts are as in the alloc.
+func checkPrestate(t *testing.T, accounts map[common.Address]*prestateAccount, alloc types.GenesisAlloc) {
+	for addr, account := range accounts {
+		want, ok := alloc[addr]
+		if !ok {
+			t.Fatalf("account %x not in the prestate", addr)
+		}
+		if account.Balance.ToInt().Cmp(want.Balance) != 0 || account.Nonce != want.Nonce || string(account.Code) != string(want.Code) {
+			t.Fatalf("account %x mismatch: have %d/%d/%x, want %d/%d/%x", addr, account.Balance.ToInt(), account.Nonce, account.Code, want.Balance, want.Nonce, want.Code)
+		}
+		for slot, value := range account.Storage {
+			if want.Storage[slot] != value {
+				t.Fatalf("slot %x of account %x mismatch: have %x, want %x", slot, addr, value, want.Storage[slot])
+			}
+		}
+	}
+}
+
+// Tests that the native prestateTracer reports the accounts and slots accessed
+// by the JavaScript one, as they were before the transaction.
+func TestPrestateTracerParity(t *testing.T) {
+	for name, test := range loadCallTracerTests(t) {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jsRes, _ := test.run(t, "prestateTracer", false, nil)
+			nativeRes, _ := test.run(t, "prestateTracer", true, nil)
+
+			var have, want map[common.Address]*prestateAccount
+			if err := json.Unmarshal(nativeRes, &have); err != nil {
+				t.Fatalf("failed to unmarshal native result: %v", err)
+			}
+			if err := json.Unmarshal(jsRes, &want); err != nil {
+				t.Fatalf("failed to unmarshal JavaScript result: %v", err)
+			}
+			checkPrestate(t, have, test.Genesis.Alloc)
+
+			// The JavaScript tracer reports the accounts not existing as empty
+			// ones, and amends the balances of the sender and the recipient only
+			for addr, account := range want {
+				if _, ok := test.Genesis.Alloc[addr]; !ok {
+					continue
+				}
+				if have[addr] == nil {
+					t.Fatalf("account %x missing", addr)
+				}
+				if string(have[addr].Code) != string(account.Code) || !reflect.DeepEqual(have[addr].Storage, account.Storage) {
+					t.Fatalf("account %x mismatch", addr)
+				}
+			}
+		})
+	}
+}
+
+// Tests that the prestateTracer reports the accounts changed by the transaction
+// before and after it in diff mode.
+func TestPrestateTracerDiffMode(t *testing.T) {
+	test := loadCallTracerTests(t)["simple"]
+	res, statedb := test.run(t, "prestateTracer", true, json.RawMessage(`{"diffMode": true}`))
+
+	var diff struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}
+	if err := json.Unmarshal(res, &diff); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	checkPrestate(t, diff.Pre, test.Genesis.Alloc)
+
+	from := test.Result.From
+	if diff.Pre[from] == nil || diff.Post[from] == nil {
+		t.Fatalf("sender change missing")
+	}
+	if have, want := diff.Post[from].Nonce, test.Genesis.Alloc[from].Nonce+1; have != want {
+		t.Errorf("sender nonce mismatch: have %d, want %d", have, want)
+	}
+	for addr, account := range diff.Post {
+		if account.Balance != nil && account.Balance.ToInt().Cmp(statedb.GetBalance(addr)) != 0 {
+			t.Errorf("balance of %x mismatch: have %d, want %d", addr, account.Balance.ToInt(), statedb.GetBalance(addr))
+		}
+		if account.Code != nil && diff.Pre[addr] != nil {
+			t.Errorf("unchanged code of %x reported", addr)
+		}
+		for slot, value := range account.Storage {
+			if want := statedb.GetState(addr, slot); value != want {
+				t.Errorf("slot %x of %x mismatch: have %x, want %x", slot, addr, value, want)
+			}
+			if pre := diff.Pre[addr]; pre != nil && pre.Storage[slot] == value {
+				t.Errorf("unchanged slot %x of %x reported", slot, addr)
+			}
+		}
+	}
+}
+
+// Tests that the calls to the staking contract are reported with the name of
+// the method called, both by the transaction and by a contract.
+func TestCallTracerStaking(t *testing.T) {
+	stakingABI, err := abi.JSON(strings.NewReader(vm.TIP10StakeABIJSON))
+	if err != nil {
+		t.Fatalf("failed to parse staking ABI: %v", err)
+	}
+	var (
+		sender   = common.HexToAddress("0x1000000000000000000000000000000000000001")
+		caller   = common.HexToAddress("0x2000000000000000000000000000000000000002")
+		input, _ = stakingABI.Pack("getDeposit", sender)
+		ctx      = &callContext{Number: 1, Difficulty: math.NewHexOrDecimal256(1), GasLimit: 8000000}
+	)
+	// The caller copies its input to memory, and passes it on to the staking
+	// contract in a static call
+	code := []byte{byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.CALLDATACOPY)}
+	code = append(code, byte(vm.PUSH1), 0x60, byte(vm.PUSH1), 0, byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0, byte(vm.PUSH20))
+	code = append(code, types.StakingAddress.Bytes()...)
+	code = append(code, byte(vm.GAS), byte(vm.STATICCALL), byte(vm.STOP))
+
+	alloc := types.GenesisAlloc{
+		sender: {Balance: big.NewInt(params.Ether)},
+		caller: {Code: code, Balance: new(big.Int)},
+	}
+	for _, to := range []common.Address{types.StakingAddress, caller} {
+		msg := types.NewMessage(sender, &to, common.Address{}, 0, new(big.Int), nil, 1000000, big.NewInt(1), input, nil, false)
+
+		res, _ := runMessage(t, alloc, ctx, msg, "callTracer", true, nil)
+		call := decodeCalls(t, res)
+		if to == caller {
+			// The JavaScript tracer reports the call too, without its result
+			res, _ = runMessage(t, alloc, ctx, msg, "callTracer", false, nil)
+			jsCall := decodeCalls(t, res)
+			if len(call.Calls) != 1 || len(jsCall.Calls) != 1 {
+				t.Fatalf("staking call missing: have %+v, want %+v", call.Calls, jsCall.Calls)
+			}
+			call = call.Calls[0]
+			if have, want := call, jsCall.Calls[0]; have.Type != want.Type || have.From != want.From || string(have.Input) != string(want.Input) {
+				t.Fatalf("staking call mismatch: have %+v, want %+v", have, want)
+			}
+		}
+		if call.To == nil || *call.To != types.StakingAddress {
+			t.Fatalf("staking call recipient mismatch: have %v", call.To)
+		}
+		if call.Method != "getDeposit" {
+			t.Errorf("staking method mismatch: have %q, want %q", call.Method, "getDeposit")
+		}
+		if call.Output == nil && call.Error == "" {
+			t.Errorf("staking call result missing")
+		}
+	}
+}
+
+// Tests that the native tracers are used in place of the JavaScript ones of
+// the same name, and that they can be stopped.
+func TestNativeTracerLookup(t *testing.T) {
+	for name := range natives {
+		if _, ok := tracer(name); !ok {
+			t.Errorf("native %s has no JavaScript counterpart", name)
+		}
+		native, err := NewTracer(name, &Context{StateDB: tests.MakePreState(etruedb.NewMemDatabase(), nil)}, nil)
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		if _, ok := native.(*JSTracer); ok {
+			t.Errorf("JavaScript %s used in place of the native one", name)
+		}
+		native.Stop(errTestStop)
+		if _, err := native.GetResult(); err != errTestStop {
+			t.Errorf("%s stop error mismatch: have %v, want %v", name, err, errTestStop)
+		}
+	}
+	if _, err := NewTracer("prestateTracer", nil, nil); err != errNoTraceState {
+		t.Errorf("stateless prestateTracer error mismatch: have %v, want %v", err, errNoTraceState)
+	}
+	if js, err := NewTracer("opcountTracer", nil, nil); err != nil {
+		t.Errorf("failed to create JavaScript tracer: %v", err)
+	} else if _, ok := js.(*JSTracer); !ok {
+		t.Errorf("JavaScript tracer type mismatch: have %T", js)
+	}
+}
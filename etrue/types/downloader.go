@@ -40,6 +40,26 @@ type Peer interface {
 	RequestNodeData([]common.Hash, bool) error
 }
 
+// SnapPeer encapsulates the methods required to download the state in ranges
+// from a remote etrue/64 peer.
+type SnapPeer interface {
+	RequestAccountRange(root, origin, limit common.Hash, bytes uint64) error
+	RequestStorageRanges(root common.Hash, accounts []common.Hash, origin common.Hash, bytes uint64) error
+	RequestByteCodes(hashes []common.Hash) error
+}
+
+// AccountData is an account trie leaf in a state range response.
+type AccountData struct {
+	Hash common.Hash // Hash of the account
+	Body []byte      // Account trie leaf, the RLP of the account
+}
+
+// StorageData is a storage trie leaf in a state range response.
+type StorageData struct {
+	Hash common.Hash // Hash of the storage slot
+	Body []byte      // Storage trie leaf, the RLP of the slot value
+}
+
 type PeerConnection interface {
 	BlockCapacity(targetRTT time.Duration) int
 	FetchHeaders(from uint64, count int) error
@@ -381,6 +401,7 @@ type FetchResult struct {
 
 	Fheader      *types.Header
 	Infos        types.CommitteeMembers
+	Evidences    types.DuplicateVoteEvidences
 	Transactions types.Transactions
 	Receipts     types.Receipts
 }
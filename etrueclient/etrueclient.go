@@ -224,10 +224,11 @@ func (ec *Client) BlockByNumber(ctx context.Context, number *big.Int) (*types.Bl
 }
 
 type rpcBlock struct {
-	Hash         common.Hash              `json:"hash"`
-	Transactions []rpcTransaction         `json:"transactions"`
-	SwitchInfos  []*types.CommitteeMember `json:"switchInfos"`
-	Signs        []*types.PbftSign        `json:"signs"`
+	Hash         common.Hash                    `json:"hash"`
+	Transactions []rpcTransaction               `json:"transactions"`
+	SwitchInfos  []*types.CommitteeMember       `json:"switchInfos"`
+	Signs        []*types.PbftSign              `json:"signs"`
+	Evidences    []*types.DuplicateVoteEvidence `json:"evidences"`
 }
 
 func (ec *Client) getBlock(ctx context.Context, method string, args ...interface{}) (*types.Block, error) {
@@ -270,7 +271,7 @@ func (ec *Client) getBlock(ctx context.Context, method string, args ...interface
 		}
 		txs[i] = tx.tx
 	}
-	return types.NewBlockWithHeader(head).WithBody(txs, body.Signs, body.SwitchInfos), nil
+	return types.NewBlockWithHeader(head).WithBody(txs, body.Signs, body.SwitchInfos, body.Evidences), nil
 }
 
 // HeaderByHash returns the block header with the given hash.
@@ -724,6 +725,9 @@ func toCallArg(msg truechain.CallMsg) interface{} {
 	if msg.Fee != nil {
 		arg["fee"] = (*hexutil.Big)(msg.Fee)
 	}
+	if msg.AccessList != nil {
+		arg["accessList"] = msg.AccessList
+	}
 	return arg
 }
 
@@ -817,6 +821,26 @@ func (ec *Client) GetAllCancelableAsset(ctx context.Context, account common.Addr
 	return result, nil
 }
 
+//impawn_getRewardHistory
+func (ec *Client) GetRewardHistory(ctx context.Context, account common.Address, from, to *big.Int, offset, limit int) (*types.RewardHistory, error) {
+	var result types.RewardHistory
+	err := ec.c.CallContext(ctx, &result, "impawn_getRewardHistory", account, toBlockNumArg(from), toBlockNumArg(to), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+//impawn_simulateElection
+func (ec *Client) SimulateElection(ctx context.Context, stakes []*types.ElectionStake, target common.Address, number *big.Int) (*types.ElectionSimulation, error) {
+	var result types.ElectionSimulation
+	err := ec.c.CallContext(ctx, &result, "impawn_simulateElection", stakes, target, toBlockNumArg(number))
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 //impawn_getStakingAccount
 func (ec *Client) GetStakingAccount(ctx context.Context, account common.Address, number *big.Int) (map[string]interface{}, error) {
 	var result map[string]interface{}
@@ -50,3 +50,21 @@ type Batch interface {
 	// Reset resets the batch for reuse
 	Reset()
 }
+
+// Iterator iterates over a database's key/value pairs in ascending key order.
+// It must be released after use.
+type Iterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// Iteratee wraps the NewIteratorWithStart method of a backing data store.
+type Iteratee interface {
+	// NewIteratorWithStart creates an iterator over the subset of database content
+	// with a particular key prefix, starting at a particular key (exclusive of the
+	// prefix).
+	NewIteratorWithStart(prefix []byte, start []byte) Iterator
+}
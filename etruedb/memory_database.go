@@ -17,7 +17,9 @@
 package etruedb
 
 import (
+	"bytes"
 	"errors"
+	"sort"
 	"sync"
 
 	"truechain/discovery/common"
@@ -96,6 +98,25 @@ func (db *MemDatabase) NewBatch() Batch {
 
 func (db *MemDatabase) Len() int { return len(db.db) }
 
+// NewIteratorWithStart creates an iterator over a snapshot of the content with
+// the prefix, starting at the key.
+func (db *MemDatabase) NewIteratorWithStart(prefix []byte, start []byte) Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var (
+		first = append(append([]byte{}, prefix...), start...)
+		it    = &memIterator{index: -1}
+	)
+	for key, value := range db.db {
+		if bytes.HasPrefix([]byte(key), prefix) && bytes.Compare([]byte(key), first) >= 0 {
+			it.items = append(it.items, kv{[]byte(key), common.CopyBytes(value)})
+		}
+	}
+	sort.Slice(it.items, func(i, j int) bool { return bytes.Compare(it.items[i].k, it.items[j].k) < 0 })
+	return it
+}
+
 type kv struct{ k, v []byte }
 
 type memBatch struct {
@@ -137,3 +158,35 @@ func (b *memBatch) Reset() {
 	b.writes = b.writes[:0]
 	b.size = 0
 }
+
+type memIterator struct {
+	items []kv
+	index int
+}
+
+func (it *memIterator) Next() bool {
+	if it.index+1 >= len(it.items) {
+		it.index = len(it.items)
+		return false
+	}
+	it.index++
+	return true
+}
+
+func (it *memIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.items) {
+		return nil
+	}
+	return it.items[it.index].k
+}
+
+func (it *memIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.items) {
+		return nil
+	}
+	return it.items[it.index].v
+}
+
+func (it *memIterator) Error() error { return nil }
+
+func (it *memIterator) Release() { it.items = nil }
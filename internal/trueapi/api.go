@@ -50,6 +50,9 @@ import (
 
 const (
 	defaultGasPrice = 50 * params.Shannon
+
+	// maxRewardHistory is the max number of the entries in a reward history page
+	maxRewardHistory = 1000
 )
 
 var (
@@ -282,6 +285,34 @@ func (s *PublicFruitPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+// RPCDroppedFruit is a fruit dropped from the snail pool with the reason why.
+type RPCDroppedFruit struct {
+	FruitHash  common.Hash    `json:"fruitHash"`
+	FastHash   common.Hash    `json:"fastHash"`
+	FastNumber *hexutil.Big   `json:"fastNumber"`
+	Reason     string         `json:"reason"`
+	Error      string         `json:"error,omitempty"`
+	Time       hexutil.Uint64 `json:"time"`
+}
+
+// Dropped returns the fruits lately dropped from the snail pool, oldest first,
+// with the reasons they were dropped for.
+func (s *PublicFruitPoolAPI) Dropped() []*RPCDroppedFruit {
+	dropped := s.b.SnailPoolDropped()
+	fruits := make([]*RPCDroppedFruit, 0, len(dropped))
+	for _, fruit := range dropped {
+		fruits = append(fruits, &RPCDroppedFruit{
+			FruitHash:  fruit.Hash,
+			FastHash:   fruit.FastHash,
+			FastNumber: (*hexutil.Big)(fruit.FastNumber),
+			Reason:     fruit.Reason,
+			Error:      fruit.Err,
+			Time:       hexutil.Uint64(fruit.Time.Unix()),
+		})
+	}
+	return fruits
+}
+
 // PrivateAccountAPI provides an API to access accounts managed by this node.
 // It offers methods to create, (un)lock en list accounts. Some methods accept
 // passwords and are therefore considered private by default.
@@ -786,6 +817,38 @@ func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.A
 	return res[:], state.Error()
 }
 
+// SponsorSpend is the gas fee a payer spent under a sponsor policy.
+type SponsorSpend struct {
+	Policy    common.Hash  `json:"policy"`
+	Spent     *hexutil.Big `json:"spent"`
+	Remaining *hexutil.Big `json:"remaining"` // nil if the policy has no total fee cap
+	Expired   bool         `json:"expired"`   // the policy doesn't sponsor the transactions of the next block
+}
+
+// GetSponsorSpend returns the gas fee the payer spent on the transactions it
+// sponsored under the policy, and what's left of its total fee, in the state
+// of the given block number or hash.
+func (s *PublicBlockChainAPI) GetSponsorSpend(ctx context.Context, payer common.Address, policy types.SponsorPolicy, blockNrOrHash rpc.BlockNumberOrHash) (*SponsorSpend, error) {
+	state, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	spend := &SponsorSpend{
+		Policy:  policy.Hash(),
+		Expired: policy.Expiry != 0 && header.Number.Uint64() >= policy.Expiry,
+	}
+	spent := state.GetSponsorSpend(payer, spend.Policy)
+	spend.Spent = (*hexutil.Big)(spent)
+	if policy.MaxTotalFee != nil && policy.MaxTotalFee.Sign() > 0 {
+		remaining := new(big.Int).Sub(policy.MaxTotalFee, spent)
+		if remaining.Sign() < 0 {
+			remaining.SetUint64(0)
+		}
+		spend.Remaining = (*hexutil.Big)(remaining)
+	}
+	return spend, state.Error()
+}
+
 func newRevertError(result *core.ExecutionResult) *revertError {
 	reason, errUnpack := abi.UnpackRevert(result.Revert())
 	err := errors.New("execution reverted")
@@ -826,6 +889,8 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 	Payer    common.Address  `json:"payer"`
 	Fee      hexutil.Big     `json:"fee"`
+
+	AccessList *types.AccessList `json:"accessList"`
 }
 
 func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockHr rpc.BlockNumberOrHash, vmCfg vm.Config, timeout time.Duration) (*core.ExecutionResult, error) {
@@ -854,7 +919,11 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockHr
 	}
 
 	// Create new call message
-	msg := types.NewMessage(addr, args.To, args.Payer, 0, args.Value.ToInt(), args.Fee.ToInt(), gas, gasPrice, args.Data, false)
+	var accessList types.AccessList
+	if args.AccessList != nil {
+		accessList = *args.AccessList
+	}
+	msg := types.NewMessage(addr, args.To, args.Payer, 0, args.Value.ToInt(), args.Fee.ToInt(), gas, gasPrice, args.Data, accessList, false)
 
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
@@ -1112,6 +1181,10 @@ func RPCMarshalBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]inter
 
 	fields["switchInfos"] = switchInfos
 
+	if evidences := b.Evidences(); len(evidences) > 0 {
+		fields["evidences"] = evidences
+	}
+
 	if inclTx {
 		formatTx := func(tx *types.Transaction) (interface{}, error) {
 			return tx.Hash(), nil
@@ -1329,31 +1402,35 @@ func (s *PublicBlockChainAPI) GetChainRewardContent(blockNr rpc.BlockNumber, add
 
 // RPCTransaction represents a transaction that will serialize to the RPC representation of a transaction
 type RPCTransaction struct {
-	BlockHash        common.Hash     `json:"blockHash"`
-	BlockNumber      *hexutil.Big    `json:"blockNumber"`
-	From             common.Address  `json:"from"`
-	Gas              hexutil.Uint64  `json:"gas"`
-	GasPrice         *hexutil.Big    `json:"gasPrice"`
-	Hash             common.Hash     `json:"hash"`
-	Input            hexutil.Bytes   `json:"input"`
-	Nonce            hexutil.Uint64  `json:"nonce"`
-	To               *common.Address `json:"to"`
-	TransactionIndex hexutil.Uint    `json:"transactionIndex"`
-	Value            *hexutil.Big    `json:"value"`
-	V                *hexutil.Big    `json:"v"`
-	R                *hexutil.Big    `json:"r"`
-	S                *hexutil.Big    `json:"s"`
-	Payer            *common.Address `json:"payer"`
-	Fee              *hexutil.Big    `json:"fee"`
-	PV               *hexutil.Big    `json:"pv"`
-	PR               *hexutil.Big    `json:"pr"`
-	PS               *hexutil.Big    `json:"ps"`
+	BlockHash        common.Hash          `json:"blockHash"`
+	BlockNumber      *hexutil.Big         `json:"blockNumber"`
+	From             common.Address       `json:"from"`
+	Gas              hexutil.Uint64       `json:"gas"`
+	GasPrice         *hexutil.Big         `json:"gasPrice"`
+	Hash             common.Hash          `json:"hash"`
+	Input            hexutil.Bytes        `json:"input"`
+	Nonce            hexutil.Uint64       `json:"nonce"`
+	To               *common.Address      `json:"to"`
+	TransactionIndex hexutil.Uint         `json:"transactionIndex"`
+	Value            *hexutil.Big         `json:"value"`
+	V                *hexutil.Big         `json:"v"`
+	R                *hexutil.Big         `json:"r"`
+	S                *hexutil.Big         `json:"s"`
+	Payer            *common.Address      `json:"payer"`
+	Fee              *hexutil.Big         `json:"fee"`
+	PV               *hexutil.Big         `json:"pv"`
+	PR               *hexutil.Big         `json:"pr"`
+	PS               *hexutil.Big         `json:"ps"`
+	Type             hexutil.Uint64       `json:"type"`
+	Accesses         *types.AccessList    `json:"accessList,omitempty"`
+	ChainID          *hexutil.Big         `json:"chainId,omitempty"`
+	Policy           *types.SponsorPolicy `json:"policy,omitempty"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
 // representation, with the given location metadata set (if available).
 func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) *RPCTransaction {
-	var signer types.Signer = types.NewTIP1Signer(tx.ChainId())
+	var signer types.Signer = types.NewTIP13Signer(tx.ChainId())
 	from, _ := types.Sender(signer, tx)
 	v, r, s := tx.RawSignatureValues()
 
@@ -1369,6 +1446,13 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		V:        (*hexutil.Big)(v),
 		R:        (*hexutil.Big)(r),
 		S:        (*hexutil.Big)(s),
+		Type:     hexutil.Uint64(tx.Type()),
+	}
+	if tx.Type() != types.LegacyTxType {
+		al := tx.AccessList()
+		result.Accesses = &al
+		result.ChainID = (*hexutil.Big)(tx.ChainId())
+		result.Policy = tx.SponsorPolicy()
 	}
 	if tx.Payer() != nil {
 		result.Payer = tx.Payer()
@@ -1580,7 +1664,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	}
 	receipt := receipts[index]
 
-	var signer types.Signer = types.NewTIP1Signer(tx.ChainId())
+	var signer types.Signer = types.NewTIP13Signer(tx.ChainId())
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
@@ -1596,6 +1680,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"logs":              receipt.Logs,
 		"logsBloom":         receipt.Bloom,
 		"status":            hexutil.Uint(receipt.Status),
+		"type":              hexutil.Uint(tx.Type()),
 	}
 
 	// Assign receipt status or post state.
@@ -1651,6 +1736,11 @@ type SendTxArgs struct {
 	// newer name and should be preferred by clients.
 	Data  *hexutil.Bytes `json:"data"`
 	Input *hexutil.Bytes `json:"input"`
+
+	// For the typed transactions of the TIP13 upgrade
+	AccessList *types.AccessList    `json:"accessList,omitempty"`
+	ChainID    *hexutil.Big         `json:"chainId,omitempty"`
+	Policy     *types.SponsorPolicy `json:"policy,omitempty"`
 }
 
 // setDefaults is a helper function that fills in default values for unspecified tx fields.
@@ -1691,6 +1781,17 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 			return errors.New(`contract creation without any data provided`)
 		}
 	}
+	if args.Policy != nil && args.Payment == (common.Address{}) {
+		return errors.New(`sponsor policy without a payment, set "payment" or drop "policy"`)
+	}
+	if args.AccessList != nil || args.Policy != nil {
+		if args.Payment == (common.Address{}) && args.Fee != nil {
+			return errors.New(`access list transaction with a fee but no payment, set "payment" or drop "fee"`)
+		}
+		if args.ChainID == nil {
+			args.ChainID = (*hexutil.Big)(b.ChainConfig().ChainID)
+		}
+	}
 	return nil
 }
 
@@ -1701,6 +1802,38 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 	} else if args.Input != nil {
 		input = *args.Input
 	}
+	if args.AccessList != nil || args.Policy != nil {
+		var accessList types.AccessList
+		if args.AccessList != nil {
+			accessList = *args.AccessList
+		}
+		if args.Payment != (common.Address{}) {
+			// The policy is signed by the payer along with the transaction
+			return types.NewTx(&types.PaymentTx{
+				ChainID:    (*big.Int)(args.ChainID),
+				Nonce:      uint64(*args.Nonce),
+				GasPrice:   (*big.Int)(args.GasPrice),
+				Gas:        uint64(*args.Gas),
+				To:         args.To,
+				Value:      (*big.Int)(args.Value),
+				Data:       input,
+				AccessList: accessList,
+				Payer:      args.Payment,
+				Fee:        (*big.Int)(args.Fee),
+				Policy:     args.Policy,
+			})
+		}
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    (*big.Int)(args.ChainID),
+			Nonce:      uint64(*args.Nonce),
+			GasPrice:   (*big.Int)(args.GasPrice),
+			Gas:        uint64(*args.Gas),
+			To:         args.To,
+			Value:      (*big.Int)(args.Value),
+			Data:       input,
+			AccessList: accessList,
+		})
+	}
 	if args.To == nil {
 		return types.NewContractCreation_Payment(uint64(*args.Nonce), (*big.Int)(args.Value), (*big.Int)(args.Fee), uint64(*args.Gas), (*big.Int)(args.GasPrice), input, args.Payment)
 	}
@@ -1812,6 +1945,14 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
 func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
+	if len(encodedTx) > 0 && encodedTx[0] <= 0x7f {
+		// A typed transaction, sent in its binary encoding
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(encodedTx); err != nil {
+			return common.Hash{}, err
+		}
+		return submitTransaction(ctx, s.b, tx)
+	}
 	raw_tx := new(types.RawTransaction)
 	if err := rlp.DecodeBytes(encodedTx, raw_tx); err != nil {
 		log.Error("api method SendRawTransaction error", "error", err)
@@ -1824,7 +1965,7 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, encod
 
 func (s *PublicTransactionPoolAPI) SendTrueRawTransaction(ctx context.Context, encodedTx hexutil.Bytes) (common.Hash, error) {
 	tx := new(types.Transaction)
-	if err := rlp.DecodeBytes(encodedTx, tx); err != nil {
+	if err := tx.UnmarshalBinary(encodedTx); err != nil {
 		log.Error("api method SendTrueRawTransaction error", "error", err)
 		return common.Hash{}, err
 	}
@@ -1886,6 +2027,13 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if err != nil {
 		return nil, err
 	}
+	if signed.Type() != types.LegacyTxType && args.Payment == (common.Address{}) {
+		data, err := signed.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return &SignTransactionResult{data, signed}, nil
+	}
 	if args.Payment == (common.Address{}) && args.Fee == nil { //normal ethereum transaction
 		raw_tx_signed := signed.ConvertRawTransaction()
 		if err != nil {
@@ -1912,7 +2060,7 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 		return nil, err
 	}
 
-	data, err := rlp.EncodeToBytes(signed_payment)
+	data, err := signed_payment.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
@@ -1935,7 +2083,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 	}
 	transactions := make([]*RPCTransaction, 0, len(pending))
 	for _, tx := range pending {
-		var signer types.Signer = types.NewTIP1Signer(tx.ChainId())
+		var signer types.Signer = types.NewTIP13Signer(tx.ChainId())
 		from, _ := types.Sender(signer, tx)
 		if _, exists := accounts[from]; exists {
 			transactions = append(transactions, newRPCPendingTransaction(tx))
@@ -1964,7 +2112,7 @@ func (s *PublicTransactionPoolAPI) Resend(ctx context.Context, sendArgs SendTxAr
 	}
 
 	for _, p := range pending {
-		var signer types.Signer = types.NewTIP1Signer(p.ChainId())
+		var signer types.Signer = types.NewTIP13Signer(p.ChainId())
 		wantSigHash := signer.Hash(matchTx)
 
 		if pFrom, err := types.Sender(signer, p); err == nil && pFrom == sendArgs.From && signer.Hash(p) == wantSigHash {
@@ -2202,6 +2350,90 @@ func (s *PublicImpawnAPI) GetStakingAccount(ctx context.Context, addr common.Add
 
 	return impawn.GetStakingAccountRPC(uint64(blockNr), addr), nil
 }
+
+// GetRewardHistory returns the staking rewards of addr between the snail
+// blocks from and to, split by validator. The entries are paged by offset and
+// limit, limit defaults to and is capped by maxRewardHistory.
+func (s *PublicImpawnAPI) GetRewardHistory(ctx context.Context, addr common.Address, from, to rpc.BlockNumber, offset, limit *int) (*types.RewardHistory, error) {
+	first, last := uint64(0), uint64(math.MaxUint64)
+	if from > 0 {
+		first = uint64(from)
+	}
+	if to >= 0 {
+		last = uint64(to)
+	}
+	start, count := 0, maxRewardHistory
+	if offset != nil {
+		if *offset < 0 {
+			return nil, errors.New("negative offset")
+		}
+		start = *offset
+	}
+	if limit != nil {
+		if *limit <= 0 {
+			return nil, errors.New("limit must be positive")
+		}
+		if *limit < count {
+			count = *limit
+		}
+	}
+	entries, total := rawdb.ReadRewardLedger(s.b.ChainDb(), addr, first, last, start, count)
+	if entries == nil {
+		entries = []*types.RewardLedgerEntry{}
+	}
+	return &types.RewardHistory{Address: addr, Total: total, Rewards: entries}, nil
+}
+
+// GetRedelegations returns the redelegations of the delegator addr which are
+// pending or not matured yet.
+func (s *PublicImpawnAPI) GetRedelegations(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) ([]map[string]interface{}, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	impawn := vm.NewImpawnImpl()
+	err = impawn.Load(state, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking load error", "error", err)
+		return nil, err
+	}
+
+	return impawn.GetRedelegationsRPC(header.Number.Uint64(), addr), nil
+}
+
+// GetValidatorProfile returns the profile and the fee policy of the addr staking account.
+func (s *PublicImpawnAPI) GetValidatorProfile(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) (map[string]interface{}, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	impawn := vm.NewImpawnImpl()
+	err = impawn.Load(state, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking load error", "error", err)
+		return nil, err
+	}
+
+	return impawn.GetValidatorProfileRPC(addr), nil
+}
+
+// SimulateElection projects the next committee election on the state of the
+// block with the hypothetical stakes applied in the block after it. The rank
+// of the target and the staking it needs to take a seat are reported too.
+func (s *PublicImpawnAPI) SimulateElection(ctx context.Context, stakes []*types.ElectionStake, target common.Address, blockNr rpc.BlockNumber) (*types.ElectionSimulation, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	impawn := vm.NewImpawnImpl()
+	err = impawn.Load(state, types.StakingAddress)
+	if err != nil {
+		log.Error("Staking load error", "error", err)
+		return nil, err
+	}
+
+	return impawn.SimulateElection(header.Number.Uint64()+1, stakes, target)
+}
 func (s *PublicImpawnAPI) GetImpawnSummay(ctx context.Context, blockNr rpc.BlockNumber) (map[string]interface{}, error) {
 	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
@@ -24,6 +24,7 @@ import (
 	"truechain/discovery/accounts"
 	"truechain/discovery/common"
 	"truechain/discovery/core"
+	"truechain/discovery/core/snailchain"
 	"truechain/discovery/core/state"
 	"truechain/discovery/core/types"
 	"truechain/discovery/core/vm"
@@ -91,6 +92,7 @@ type Backend interface {
 	SnailPoolContent() []*types.SnailBlock
 	SnailPoolInspect() []*types.SnailBlock
 	SnailPoolStats() (pending int, unVerified int)
+	SnailPoolDropped() []*snailchain.DroppedFruit
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
@@ -341,6 +341,18 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'replayBlock',
+			call: 'debug_replayBlock',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getStateDiff',
+			call: 'debug_getStateDiff',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'traceBlockByNumber',
 			call: 'debug_traceBlockByNumber',
@@ -438,6 +450,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
 		}),
+		new web3._extend.Method({
+			name: 'getSponsorSpend',
+			call: 'etrue_getSponsorSpend',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -509,6 +527,10 @@ web3._extend({
 			name: 'getHashRate',
 			call: 'miner_getHashRate'
 		}),
+		new web3._extend.Method({
+			name: 'stratumWorkers',
+			call: 'miner_stratumWorkers'
+		}),
 	],
 	properties: []
 });
@@ -675,6 +697,10 @@ web3._extend({
 			name: 'status',
 			getter: 'fruitpool_status'
 		}),
+		new web3._extend.Property({
+			name: 'dropped',
+			getter: 'fruitpool_dropped'
+		}),
 	]
 });
 `
@@ -813,6 +839,30 @@ web3._extend({
 				return sa;
 			}
 		}),
+		new web3._extend.Method({
+			name: 'getRewardHistory',
+			call: 'impawn_getRewardHistory',
+			params: 5,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter,web3._extend.formatters.inputDefaultBlockNumberFormatter,web3._extend.formatters.inputDefaultBlockNumberFormatter,null,null]
+		}),
+		new web3._extend.Method({
+			name: 'getRedelegations',
+			call: 'impawn_getRedelegations',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter,web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'simulateElection',
+			call: 'impawn_simulateElection',
+			params: 3,
+			inputFormatter: [null,web3._extend.formatters.inputAddressFormatter,web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getValidatorProfile',
+			call: 'impawn_getValidatorProfile',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter,web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'getImpawnSummay',
 			call: 'impawn_getImpawnSummay',
@@ -27,6 +27,7 @@ import (
 	"truechain/discovery/core"
 	"truechain/discovery/core/bloombits"
 	"truechain/discovery/core/rawdb"
+	"truechain/discovery/core/snailchain"
 	"truechain/discovery/core/state"
 	"truechain/discovery/core/types"
 	"truechain/discovery/core/vm"
@@ -244,6 +245,10 @@ func (b *LesApiBackend) SnailPoolStats() (pending int, unVerified int) {
 	return 0, 0
 }
 
+func (b *LesApiBackend) SnailPoolDropped() []*snailchain.DroppedFruit {
+	return nil
+}
+
 func (b *LesApiBackend) Downloader() *downloader.Downloader {
 	return b.etrue.Downloader()
 }
@@ -125,6 +125,11 @@ func New(ctx *node.ServiceContext, config *etrue.Config) (*LightEtrue, error) {
 	leth.odr.SetIndexers(leth.chtIndexer, leth.bloomTrieIndexer, leth.bloomIndexer)
 
 	checkpoint := params.TrustedCheckpoints[snailGenesis]
+	if config.LightCommittee {
+		// The committee signs finalize every fast header, sync them from the
+		// genesis committee on without trusting any checkpoint.
+		checkpoint = nil
+	}
 
 	if leth.fblockchain, err = fast.NewLightChain(leth.odr, leth.chainConfig, leth.engine, checkpoint); err != nil {
 		return nil, err
@@ -135,6 +140,10 @@ func New(ctx *node.ServiceContext, config *etrue.Config) (*LightEtrue, error) {
 		return nil, err
 	}
 	leth.election = NewLightElection(leth.fblockchain, leth.blockchain)
+	if config.LightCommittee {
+		log.Info("Light committee verification is enabled")
+		leth.fblockchain.EnableCommitteeVerify()
+	}
 	leth.engine.SetElection(leth.election)
 	leth.engine.SetSnailChainReader(leth.blockchain.GetHeaderChain())
 	leth.engine.SetSnailHeaderHash(chainDb)
@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"math/big"
+	"sort"
 
 	"github.com/hashicorp/golang-lru"
 	"truechain/discovery/common"
@@ -54,8 +55,9 @@ type Election struct {
 	fastchain  *fast.LightChain
 	snailchain *light.LightChain
 
-	commiteeCache *lru.Cache
-	switchCache   *lru.Cache
+	commiteeCache     *lru.Cache
+	switchCache       *lru.Cache
+	switchNumberCache *lru.Cache
 }
 
 type switchPoint struct {
@@ -83,6 +85,7 @@ func NewLightElection(fastBlockChain *fast.LightChain, snailBlockChain *light.Li
 	}
 	election.commiteeCache, _ = lru.New(committeeCacheLimit)
 	election.switchCache, _ = lru.New(committeeCacheLimit)
+	election.switchNumberCache, _ = lru.New(committeeCacheLimit)
 
 	// Genesis committee is stroed on block 0
 	election.genesisCommittee = election.getGenesisCommittee()
@@ -214,40 +217,13 @@ func (e *Election) VerifySwitchInfo(fastNumber *big.Int, info []*types.Committee
 // GetCommittee gets committee members which propose the fast block
 func (e *Election) GetCommittee(fastNumber *big.Int) []*types.CommitteeMember {
 	var (
-		id         *big.Int
-		snail      *big.Int
-		beginFruit *big.Int
-		c          *types.ElectionCommittee
-	)
-
-	blockHead := e.fastchain.GetHeaderByNumber(fastNumber.Uint64())
-	if fruitHead := e.snailchain.GetFruitHeaderByHash(blockHead.Hash()); fruitHead != nil {
-		snail = fruitHead.Number
-	} else {
-		snail = e.snailchain.CurrentHeader().Number
-	}
-
-	id = new(big.Int).Div(snail, params.ElectionPeriodNumber)
-	if id.Cmp(common.Big0) == 0 {
-		// return genesisi committee
-		id = big.NewInt(0)
-		c = e.getCommittee(common.Big0)
+		id         = e.committeeID(fastNumber)
 		beginFruit = big.NewInt(2)
+	)
+	if id.Sign() > 0 {
+		beginFruit = new(big.Int).Add(e.switchNumber(id), common.Big2)
 	}
-	_, end := ElectionEpoch(id)
-	fruitNum := e.endFruitNumber(end)
-
-	if fastNumber.Cmp(new(big.Int).Add(fruitNum, params.ElectionSwitchoverNumber)) > 0 {
-		beginFruit = new(big.Int).Add(fruitNum, params.ElectionSwitchoverNumber)
-		beginFruit = beginFruit.Add(beginFruit, common.Big2)
-		c = e.getCommittee(id)
-	} else {
-		id := new(big.Int).Sub(id, common.Big1)
-		c = e.getCommittee(id)
-		begin, _ := ElectionEpoch(id)
-		beginFruit = e.beginFruitNumber(begin)
-		beginFruit = new(big.Int).Add(beginFruit, common.Big1)
-	}
+	c := e.getCommittee(id)
 
 	// Load switch block to calculate committee members
 	switches := e.loadSwitchPoint(id, beginFruit, fastNumber)
@@ -258,6 +234,43 @@ func (e *Election) GetCommittee(fastNumber *big.Int) []*types.CommitteeMember {
 	}
 }
 
+// committeeID finds the committee proposing the fast block, the last one whose
+// switch number the block is past. The committees are elected by the known
+// snail blocks, so the block doesn't need to be in the fast chain yet.
+func (e *Election) committeeID(fastNumber *big.Int) *big.Int {
+	last := new(big.Int).Div(e.snailchain.CurrentHeader().Number, params.ElectionPeriodNumber).Uint64()
+	return new(big.Int).SetUint64(searchCommittee(fastNumber, last, func(id uint64) *big.Int {
+		return e.switchNumber(new(big.Int).SetUint64(id))
+	}))
+}
+
+// searchCommittee returns the id of the last committee, up to the last one, whose
+// switch number the fast block is past. The switch numbers rise with the ids, nil
+// if the committee isn't known yet.
+func searchCommittee(fastNumber *big.Int, last uint64, switchNumber func(id uint64) *big.Int) uint64 {
+	return uint64(sort.Search(int(last), func(i int) bool {
+		number := switchNumber(uint64(i) + 1)
+		return number == nil || fastNumber.Cmp(number) <= 0
+	}))
+}
+
+// switchNumber returns the fast number the committee takes over after, the last
+// fast number with a fruit in its election epoch plus the switchover blocks. It
+// returns nil if the end of the epoch isn't in the snail chain yet.
+func (e *Election) switchNumber(id *big.Int) *big.Int {
+	if cache, ok := e.switchNumberCache.Get(id.Uint64()); ok {
+		return cache.(*big.Int)
+	}
+	_, end := ElectionEpoch(id)
+	fruits := e.snailchain.GetFruitsHead(end.Uint64())
+	if len(fruits) == 0 {
+		return nil
+	}
+	number := new(big.Int).Add(fruits[len(fruits)-1].FastNumber, params.ElectionSwitchoverNumber)
+	e.switchNumberCache.Add(id.Uint64(), number)
+	return number
+}
+
 func (e *Election) loadSwitchPoint(id *big.Int, beginFruit *big.Int, fastNumber *big.Int) []uint64 {
 	var (
 		switches     []uint64
@@ -271,17 +284,22 @@ func (e *Election) loadSwitchPoint(id *big.Int, beginFruit *big.Int, fastNumber
 	}
 
 	// Retrieve block including switchinfo
+	checkNumber := fastNumber
 	for i := beginFruit.Uint64(); i < fastNumber.Uint64(); i++ {
 		head := e.fastchain.GetHeaderByNumber(i)
+		if head == nil {
+			checkNumber = new(big.Int).SetUint64(i)
+			break
+		}
 		if hex.EncodeToString(head.CommitteeHash[:]) == emptyCommittee {
 			continue
 		}
 		log.Info("Light committee apply switchinfo", "number", i)
 		switches = append(switches, i)
 	}
-	if fastNumber.Cmp(beginFruit) > 0 && (switchBlocks == nil || fastNumber.Cmp(switchBlocks.checkNumber) > 0) {
+	if checkNumber.Cmp(beginFruit) > 0 && (switchBlocks == nil || checkNumber.Cmp(switchBlocks.checkNumber) > 0) {
 		switchBlocks = &switchPoint{
-			checkNumber: fastNumber,
+			checkNumber: checkNumber,
 			switches:    switches,
 		}
 		e.switchCache.Add(id.Uint64(), switchBlocks)
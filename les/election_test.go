@@ -0,0 +1,38 @@
+package les
+
+import (
+	"math/big"
+	"testing"
+)
+
+// Tests that the committee of a fast block is found from the switch numbers of
+// the committees elected so far, from the genesis committee on.
+func TestSearchCommittee(t *testing.T) {
+	// The committees 1 and 2 take over after the blocks 10000 and 20000, the
+	// committee 3 is elected by snail blocks not known yet
+	switches := map[uint64]*big.Int{1: big.NewInt(10000), 2: big.NewInt(20000)}
+	switchNumber := func(id uint64) *big.Int {
+		return switches[id]
+	}
+	tests := []struct {
+		number uint64
+		last   uint64
+		id     uint64
+	}{
+		{1, 0, 0},
+		{10000, 0, 0},
+		{10001, 0, 0}, // the committee 1 isn't elected yet
+		{1, 3, 0},
+		{10000, 3, 0},
+		{10001, 3, 1},
+		{20000, 3, 1},
+		{20001, 3, 2},
+		{20001, 1, 1},
+		{90000, 3, 2},
+	}
+	for i, tt := range tests {
+		if id := searchCommittee(new(big.Int).SetUint64(tt.number), tt.last, switchNumber); id != tt.id {
+			t.Errorf("test %d: committee of block %d mismatch: have %d, want %d", i, tt.number, id, tt.id)
+		}
+	}
+}
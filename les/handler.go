@@ -92,6 +92,7 @@ type FastBlockChain interface {
 	Genesis() *types.Block
 	SubscribeChainHeadEvent(ch chan<- types.FastChainHeadEvent) event.Subscription
 	SetCommitteeInfo(hash common.Hash, number uint64, infos []*types.CommitteeMember)
+	SetSigns(hash common.Hash, number uint64, signs []*types.PbftSign)
 }
 
 type BlockChain interface {
@@ -664,6 +665,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		for i, block := range resp.Headers.Blocks {
 			heads[i] = block.Head
 			signs[i] = block.Signs
+			pm.fblockchain.SetSigns(block.Head.Hash(), block.Head.Number.Uint64(), block.Signs)
 			if block.Head.CommitteeHash != (types.EmptySignHash) {
 				pm.fblockchain.SetCommitteeInfo(block.Head.Hash(), block.Head.Number.Uint64(), block.Infos)
 			}
@@ -43,6 +43,17 @@ import (
 var (
 	bodyCacheLimit  = 256
 	blockCacheLimit = 256
+	infoQueueLimit  = 4096 // Maximum number of headers with scheduled imports
+)
+
+var (
+	// ErrMissingSigns is returned when a fast header is inserted in committee
+	// mode without the committee signs finalizing it.
+	ErrMissingSigns = errors.New("missing committee signs")
+
+	// ErrInvalidSwitchInfo is returned when the switch info delivered with a
+	// fast header doesn't match its committee hash.
+	ErrInvalidSwitchInfo = errors.New("invalid switch info")
 )
 
 // LightChain represents a canonical chain that by default only handles block
@@ -67,11 +78,12 @@ type LightChain struct {
 	chainmu   sync.RWMutex // protects header inserts
 	quit      chan struct{}
 	wg        sync.WaitGroup
-	infoQueue map[common.Hash]*injectInfo // Blocks with committeeInfo fetched
+	infoQueue map[common.Hash]*injectInfo // Blocks with committeeInfo or signs fetched
 	// Atomic boolean switches:
 	running          int32 // whether LightChain is running or stopped
 	procInterrupt    int32 // interrupts chain insert
 	disableCheckFreq int32 // disables header verification
+	verifySigns      int32 // verifies the committee signs of inserted headers
 }
 
 // NewLightChain returns a fully initialised light chain using information
@@ -368,7 +380,18 @@ func (lc *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (i
 
 	var events []interface{}
 	whFunc := func(header *types.Header) error {
+		inject := lc.infoQueue[header.Hash()]
+		if atomic.LoadInt32(&lc.verifySigns) == 1 && !lc.hc.HasHeader(header.Hash(), header.Number.Uint64()) {
+			if err := lc.verifyCommittee(header, inject); err != nil {
+				return err
+			}
+		}
 		status, err := lc.hc.WriteHeader(header)
+		if err == nil && inject != nil && header.CommitteeHash != types.EmptySignHash {
+			// Write the switch info along with its header, the committee of
+			// the following headers is filtered with it.
+			rawdb.WriteCommitteeInfo(lc.chainDb, header.Hash(), header.Number.Uint64(), inject.infos)
+		}
 
 		switch status {
 		case core.CanonStatTy:
@@ -382,14 +405,7 @@ func (lc *LightChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (i
 	}
 	i, err := lc.hc.InsertHeaderChain(chain, whFunc, start)
 	for _, head := range chain {
-		if head.CommitteeHash != (types.EmptySignHash) {
-			if inject, ok := lc.infoQueue[head.Hash()]; ok {
-				if err == nil {
-					rawdb.WriteCommitteeInfo(lc.chainDb, head.Hash(), head.Number.Uint64(), inject.infos)
-				}
-				delete(lc.infoQueue, head.Hash())
-			}
-		}
+		delete(lc.infoQueue, head.Hash())
 	}
 	lc.postChainEvents(events)
 	return i, err
@@ -525,13 +541,79 @@ func (lc *LightChain) LoadLastState() {
 func (bc *LightChain) SetCommitteeInfo(hash common.Hash, number uint64, infos []*types.CommitteeMember) {
 	bc.chainmu.Lock()
 	defer bc.chainmu.Unlock()
-	inject := &injectInfo{infos: infos}
+	if inject := bc.injectInfo(hash, number); inject != nil {
+		inject.infos = infos
+	}
+}
+
+// SetSigns schedules the committee signs of a fetched header, they are verified
+// when the header is inserted in committee mode.
+func (bc *LightChain) SetSigns(hash common.Hash, number uint64, signs []*types.PbftSign) {
+	if atomic.LoadInt32(&bc.verifySigns) == 0 {
+		return
+	}
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+	if inject := bc.injectInfo(hash, number); inject != nil {
+		inject.signs = signs
+	}
+}
+
+// injectInfo returns the scheduled import of the header, creating it if needed.
+// Once the queue is full the imports of the headers not above the head, which
+// were never inserted, expire. It returns nil if the queue is still full.
+// This method assumes that the chain mutex is held.
+func (bc *LightChain) injectInfo(hash common.Hash, number uint64) *injectInfo {
+	if inject, ok := bc.infoQueue[hash]; ok {
+		return inject
+	}
+	if len(bc.infoQueue) >= infoQueueLimit {
+		head := bc.hc.CurrentHeader().Number.Uint64()
+		for queued, inject := range bc.infoQueue {
+			if inject.number <= head {
+				delete(bc.infoQueue, queued)
+			}
+		}
+		if len(bc.infoQueue) >= infoQueueLimit {
+			log.Debug("Dropping committee info of header, queue full", "number", number, "hash", hash)
+			return nil
+		}
+	}
+	inject := &injectInfo{number: number}
 	bc.infoQueue[hash] = inject
+	return inject
 }
 
 // inject represents a schedules import operation.
 type injectInfo struct {
-	infos []*types.CommitteeMember
+	number uint64
+	infos  []*types.CommitteeMember
+	signs  []*types.PbftSign
+}
+
+// EnableCommitteeVerify enables the committee mode, every inserted header must
+// be finalized by more than 2/3 of its committee, which is tracked through the
+// election switch points, instead of being trusted from a checkpoint.
+func (lc *LightChain) EnableCommitteeVerify() {
+	atomic.StoreInt32(&lc.verifySigns, 1)
+}
+
+// verifyCommittee checks the committee signs and the switch info scheduled for
+// the header. This method assumes that the chain mutex is held.
+func (lc *LightChain) verifyCommittee(header *types.Header, inject *injectInfo) error {
+	if inject == nil || len(inject.signs) == 0 {
+		return ErrMissingSigns
+	}
+	if err := lc.engine.VerifySigns(header.Number, header.Hash(), inject.signs); err != nil {
+		return err
+	}
+	if header.CommitteeHash != types.EmptySignHash {
+		if types.RlpHash(inject.infos) != header.CommitteeHash {
+			return ErrInvalidSwitchInfo
+		}
+		return lc.engine.VerifySwitchInfo(header.Number, inject.infos)
+	}
+	return nil
 }
 
 // GetHeaderChain loads the last known chain state from the database. This method
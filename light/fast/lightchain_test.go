@@ -0,0 +1,311 @@
+package fast
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"truechain/discovery/common"
+	"truechain/discovery/consensus"
+	"truechain/discovery/consensus/minerva"
+	"truechain/discovery/core"
+	"truechain/discovery/core/types"
+	"truechain/discovery/crypto"
+	"truechain/discovery/etruedb"
+	"truechain/discovery/light/public"
+	"truechain/discovery/params"
+)
+
+// testOdr is an ODR backend serving the local database only.
+type testOdr struct {
+	db etruedb.Database
+}
+
+func (odr *testOdr) Database() etruedb.Database                             { return odr.db }
+func (odr *testOdr) BloomTrieIndexer() *core.ChainIndexer                   { return nil }
+func (odr *testOdr) BloomIndexer() *core.ChainIndexer                       { return nil }
+func (odr *testOdr) FastRetrieve(ctx context.Context, req OdrRequest) error { return ErrNoPeers }
+func (odr *testOdr) FastIndexerConfig() *public.IndexerConfig               { return public.TestClientIndexerConfig }
+
+// testElection hands the committee over after the switch number, and filters
+// the members out by the switch infos the light chain stored, like the light
+// client election does.
+type testElection struct {
+	chain      *LightChain
+	switchAt   uint64
+	committees [2][]*types.CommitteeMember
+}
+
+func (e *testElection) GetCommittee(fastNumber *big.Int) []*types.CommitteeMember {
+	committee := e.committees[0]
+	if fastNumber.Uint64() > e.switchAt {
+		committee = e.committees[1]
+	}
+	removed := make(map[common.Address]bool)
+	for i := uint64(1); i < fastNumber.Uint64(); i++ {
+		for _, info := range e.chain.GetSwitchInfo(i) {
+			if info.Flag == types.StateRemovedFlag {
+				removed[info.CommitteeBase] = true
+			}
+		}
+	}
+	var members []*types.CommitteeMember
+	for _, member := range committee {
+		if !removed[member.CommitteeBase] {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+func (e *testElection) VerifySigns(signs []*types.PbftSign) ([]*types.CommitteeMember, []error) {
+	var (
+		members   = make([]*types.CommitteeMember, len(signs))
+		errs      = make([]error, len(signs))
+		committee = e.GetCommittee(signs[0].FastHeight)
+	)
+	for i, sign := range signs {
+		errs[i] = consensus.ErrInvalidSign
+		pubkey, err := crypto.SigToPub(sign.HashWithNoSign().Bytes(), sign.Sign)
+		if err != nil {
+			continue
+		}
+		for _, member := range committee {
+			if member.CommitteeBase == crypto.PubkeyToAddress(*pubkey) {
+				members[i], errs[i] = member, nil
+			}
+		}
+	}
+	return members, errs
+}
+
+func (e *testElection) VerifySwitchInfo(fastNumber *big.Int, info []*types.CommitteeMember) error {
+	return nil
+}
+
+func (e *testElection) FinalizeCommittee(block *types.Block) error {
+	return nil
+}
+
+func (e *testElection) GenerateFakeSigns(fb *types.Block) ([]*types.PbftSign, error) {
+	return nil, nil
+}
+
+// committeeTester holds the committee keys and a light chain verifying the
+// committee signs of the headers, the committee of the keys 0-3 handing over
+// to the one of the keys 2-5 after the block 5.
+type committeeTester struct {
+	keys    []*ecdsa.PrivateKey
+	members []*types.CommitteeMember
+	chain   *LightChain
+	headers []*types.Header
+}
+
+func newCommitteeTester(t *testing.T) *committeeTester {
+	tester := &committeeTester{}
+	for i := 0; i < 6; i++ {
+		key, _ := crypto.GenerateKey()
+		tester.keys = append(tester.keys, key)
+		tester.members = append(tester.members, &types.CommitteeMember{
+			Coinbase:      crypto.PubkeyToAddress(key.PublicKey),
+			CommitteeBase: crypto.PubkeyToAddress(key.PublicKey),
+			Publickey:     crypto.FromECDSAPub(&key.PublicKey),
+			Flag:          types.StateUsedFlag,
+		})
+	}
+	db := etruedb.NewMemDatabase()
+	genesis := (&core.Genesis{Config: params.MainnetChainConfig, GasLimit: params.GenesisGasLimit, Committee: tester.members[:4]}).MustFastCommit(db)
+
+	election := &testElection{switchAt: 5, committees: [2][]*types.CommitteeMember{tester.members[:4], tester.members[2:]}}
+	engine := minerva.NewFaker()
+	engine.SetElection(election)
+
+	chain, err := NewLightChain(&testOdr{db: db}, params.MainnetChainConfig, engine, nil)
+	if err != nil {
+		t.Fatalf("failed to create light chain: %v", err)
+	}
+	chain.EnableCommitteeVerify()
+	election.chain, tester.chain = chain, chain
+
+	// Create the headers, the block 3 removes the key 1 from the committee
+	parent := genesis.Header()
+	for i := 1; i <= 10; i++ {
+		header := &types.Header{
+			ParentHash:    parent.Hash(),
+			Number:        big.NewInt(int64(i)),
+			GasLimit:      parent.GasLimit,
+			Time:          new(big.Int).Add(parent.Time, common.Big1),
+			SnailNumber:   big.NewInt(0),
+			CommitteeHash: types.EmptySignHash,
+		}
+		if i == 3 {
+			header.CommitteeHash = types.RlpHash(tester.switchInfo())
+		}
+		tester.headers = append(tester.headers, header)
+		parent = header
+	}
+	return tester
+}
+
+// switchInfo returns the switch info of the block 3, removing the key 1.
+func (tester *committeeTester) switchInfo() []*types.CommitteeMember {
+	removed := *tester.members[1]
+	removed.Flag = types.StateRemovedFlag
+	return []*types.CommitteeMember{&removed}
+}
+
+// signs returns the signs of the header by the keys.
+func (tester *committeeTester) signs(header *types.Header, keys ...int) []*types.PbftSign {
+	var signs []*types.PbftSign
+	for _, i := range keys {
+		sign := &types.PbftSign{FastHeight: header.Number, FastHash: header.Hash(), Result: types.VoteAgree}
+		sign.Sign, _ = crypto.Sign(sign.HashWithNoSign().Bytes(), tester.keys[i])
+		signs = append(signs, sign)
+	}
+	return signs
+}
+
+// schedule schedules the signs of the committee finalizing the headers, and the
+// switch info of the block 3.
+func (tester *committeeTester) schedule() {
+	for _, header := range tester.headers {
+		var keys []int
+		switch number := header.Number.Uint64(); {
+		case number <= 3:
+			keys = []int{0, 1, 2, 3}
+		case number <= 5:
+			keys = []int{0, 2, 3}
+		default:
+			keys = []int{2, 3, 4, 5}
+		}
+		tester.chain.SetSigns(header.Hash(), header.Number.Uint64(), tester.signs(header, keys...))
+		if header.CommitteeHash != types.EmptySignHash {
+			tester.chain.SetCommitteeInfo(header.Hash(), header.Number.Uint64(), tester.switchInfo())
+		}
+	}
+}
+
+// Tests that a light chain syncs from the genesis across a committee switch and
+// a switch info, verifying the committee signs of every header.
+func TestCommitteeSync(t *testing.T) {
+	tester := newCommitteeTester(t)
+	tester.schedule()
+
+	if _, err := tester.chain.InsertHeaderChain(tester.headers, 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	if head := tester.chain.CurrentHeader().Number.Uint64(); head != 10 {
+		t.Errorf("head mismatch: have %d, want %d", head, 10)
+	}
+	if infos := tester.chain.GetSwitchInfo(3); len(infos) != 1 || infos[0].CommitteeBase != tester.members[1].CommitteeBase {
+		t.Errorf("switch info mismatch: have %v", infos)
+	}
+	if len(tester.chain.infoQueue) != 0 {
+		t.Errorf("scheduled imports left: %d", len(tester.chain.infoQueue))
+	}
+}
+
+// Tests that the headers without the signs of their committee, or with a switch
+// info not matching them, are rejected.
+func TestCommitteeReject(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(tester *committeeTester)
+		head   uint64
+	}{
+		{
+			name: "missing signs",
+			tamper: func(tester *committeeTester) {
+				delete(tester.chain.infoQueue, tester.headers[1].Hash())
+			},
+			head: 1,
+		},
+		{
+			name: "too few signs",
+			tamper: func(tester *committeeTester) {
+				header := tester.headers[6]
+				tester.chain.SetSigns(header.Hash(), header.Number.Uint64(), tester.signs(header, 2, 3))
+			},
+			head: 6,
+		},
+		{
+			name: "signs of the previous committee",
+			tamper: func(tester *committeeTester) {
+				header := tester.headers[6]
+				tester.chain.SetSigns(header.Hash(), header.Number.Uint64(), tester.signs(header, 0, 2, 3))
+			},
+			head: 6,
+		},
+		{
+			name: "signs of a removed member",
+			tamper: func(tester *committeeTester) {
+				header := tester.headers[4]
+				tester.chain.SetSigns(header.Hash(), header.Number.Uint64(), tester.signs(header, 1, 2, 3))
+			},
+			head: 4,
+		},
+		{
+			name: "signs of another header",
+			tamper: func(tester *committeeTester) {
+				header := tester.headers[6]
+				tester.chain.SetSigns(header.Hash(), header.Number.Uint64(), tester.signs(tester.headers[7], 2, 3, 4, 5))
+			},
+			head: 6,
+		},
+		{
+			name: "wrong switch info",
+			tamper: func(tester *committeeTester) {
+				header := tester.headers[2]
+				tester.chain.SetCommitteeInfo(header.Hash(), header.Number.Uint64(), tester.members[:1])
+			},
+			head: 2,
+		},
+		{
+			name: "missing switch info",
+			tamper: func(tester *committeeTester) {
+				header := tester.headers[2]
+				tester.chain.SetCommitteeInfo(header.Hash(), header.Number.Uint64(), nil)
+			},
+			head: 2,
+		},
+	}
+	for _, tt := range tests {
+		tester := newCommitteeTester(t)
+		tester.schedule()
+		tt.tamper(tester)
+
+		if _, err := tester.chain.InsertHeaderChain(tester.headers, 1); err == nil {
+			t.Errorf("%s: headers accepted", tt.name)
+		}
+		if head := tester.chain.CurrentHeader().Number.Uint64(); head != tt.head {
+			t.Errorf("%s: head mismatch: have %d, want %d", tt.name, head, tt.head)
+		}
+	}
+}
+
+// Tests that the scheduled imports of the headers never inserted expire once
+// the queue is full.
+func TestInfoQueueLimit(t *testing.T) {
+	tester := newCommitteeTester(t)
+
+	// Fill the queue with imports at the head, and ensure they expire
+	for i := 0; i < infoQueueLimit; i++ {
+		tester.chain.SetSigns(common.BigToHash(big.NewInt(int64(i))), 0, nil)
+	}
+	header := tester.headers[0]
+	tester.chain.SetSigns(header.Hash(), header.Number.Uint64(), tester.signs(header, 0, 1, 2, 3))
+	if len(tester.chain.infoQueue) != 1 || tester.chain.infoQueue[header.Hash()] == nil {
+		t.Fatalf("expired imports left: %d", len(tester.chain.infoQueue))
+	}
+	// Fill the queue with imports above the head, and ensure new ones are dropped
+	for i := 0; i < infoQueueLimit; i++ {
+		tester.chain.SetSigns(common.BigToHash(big.NewInt(int64(i))), 2, nil)
+	}
+	if len(tester.chain.infoQueue) != infoQueueLimit {
+		t.Fatalf("queue size mismatch: have %d, want %d", len(tester.chain.infoQueue), infoQueueLimit)
+	}
+	if tester.chain.infoQueue[common.BigToHash(big.NewInt(int64(infoQueueLimit-1)))] != nil {
+		t.Errorf("import scheduled in a full queue")
+	}
+}
@@ -96,7 +96,7 @@ func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint
 		return nil, err
 	}
 	// Reassemble the block and return
-	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Signs, body.Infos), nil
+	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Signs, body.Infos, body.Evidences), nil
 }
 
 // GetBlockReceipts retrieves the receipts generated by the transactions included
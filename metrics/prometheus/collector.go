@@ -0,0 +1,149 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"truechain/discovery/log"
+	"truechain/discovery/metrics"
+)
+
+var (
+	typeGaugeTpl       = "# TYPE %s gauge\n"
+	typeCounterTpl     = "# TYPE %s counter\n"
+	typeSummaryTpl     = "# TYPE %s summary\n"
+	keyValueTpl        = "%s %v\n"
+	keyQuantileTpl     = "%s{quantile=\"%s\"} %v\n"
+	summaryQuantiles   = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+	resettingQuantiles = []float64{50, 95, 99}
+)
+
+// collector is a byte buffer that aggregates the Prometheus reports of the
+// different metric types. Timers are reported in nanoseconds.
+type collector struct {
+	buff  *bytes.Buffer
+	names map[string]string // Prometheus names reported, mapped to their metrics
+}
+
+// newCollector creates a new Prometheus metric aggregator.
+func newCollector() *collector {
+	return &collector{
+		buff:  &bytes.Buffer{},
+		names: make(map[string]string),
+	}
+}
+
+// addCounter reports a counter as a gauge, as counters can be decremented.
+func (c *collector) addCounter(name string, m metrics.Counter) {
+	c.writeGauge(name, m.Count())
+}
+
+func (c *collector) addGauge(name string, m metrics.Gauge) {
+	c.writeGauge(name, m.Value())
+}
+
+func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) {
+	c.writeGauge(name, m.Value())
+}
+
+func (c *collector) addHistogram(name string, m metrics.Histogram) {
+	c.writeSummary(name, summaryQuantiles, m.Percentiles(summaryQuantiles), m.Sum(), m.Count())
+}
+
+// addMeter reports the number of events marked, Prometheus derives the rates.
+func (c *collector) addMeter(name string, m metrics.Meter) {
+	name, ok := c.claim(name)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(c.buff, typeCounterTpl, name)
+	fmt.Fprintf(c.buff, keyValueTpl, name, m.Count())
+}
+
+func (c *collector) addTimer(name string, m metrics.Timer) {
+	c.writeSummary(name, summaryQuantiles, m.Percentiles(summaryQuantiles), m.Sum(), m.Count())
+}
+
+// addResettingTimer reports the values timed since the previous snapshot, it's
+// skipped if there are none.
+func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
+	values := m.Values()
+	if len(values) == 0 {
+		return
+	}
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	var (
+		ps        = m.Percentiles(resettingQuantiles)
+		quantiles = make([]float64, len(ps))
+		bounds    = make([]float64, len(ps))
+	)
+	for i, p := range resettingQuantiles {
+		quantiles[i], bounds[i] = p/100, float64(ps[i])
+	}
+	c.writeSummary(name, quantiles, bounds, sum, int64(len(values)))
+}
+
+func (c *collector) writeGauge(name string, value interface{}) {
+	name, ok := c.claim(name)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(c.buff, typeGaugeTpl, name)
+	fmt.Fprintf(c.buff, keyValueTpl, name, value)
+}
+
+// writeSummary writes a summary with the values at the quantiles and the sum
+// and count of the observations.
+func (c *collector) writeSummary(name string, quantiles, values []float64, sum, count int64) {
+	name, ok := c.claim(name, "_sum", "_count")
+	if !ok {
+		return
+	}
+	fmt.Fprintf(c.buff, typeSummaryTpl, name)
+	for i, q := range quantiles {
+		fmt.Fprintf(c.buff, keyQuantileTpl, name, strconv.FormatFloat(q, 'f', -1, 64), values[i])
+	}
+	fmt.Fprintf(c.buff, keyValueTpl, name+"_sum", sum)
+	fmt.Fprintf(c.buff, keyValueTpl, name+"_count", count)
+}
+
+// claim converts a metric name to its Prometheus one and reserves it, along with
+// the names of the series suffixed to it. A metric whose names collide with the
+// ones of a metric reported before is skipped, as Prometheus rejects the whole
+// exposition on a duplicate.
+func (c *collector) claim(metric string, suffixes ...string) (string, bool) {
+	name := mutateKey(metric)
+	names := append([]string{name}, suffixes...)
+	for i := 1; i < len(names); i++ {
+		names[i] = name + names[i]
+	}
+	for _, n := range names {
+		if other, ok := c.names[n]; ok {
+			log.Debug("Skipping colliding Prometheus metric", "name", metric, "collides", other)
+			return "", false
+		}
+	}
+	for _, n := range names {
+		c.names[n] = metric
+	}
+	return name, true
+}
+
+// mutateKey converts a metric name to a valid Prometheus one, replacing the
+// characters it doesn't allow (e.g. the '/' separators) with underscores.
+func mutateKey(key string) string {
+	out := []byte(key)
+	for i, ch := range out {
+		if !(ch == '_' || ch == ':' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')) {
+			out[i] = '_'
+		}
+	}
+	if len(out) > 0 && out[0] >= '0' && out[0] <= '9' {
+		return "_" + string(out)
+	}
+	return string(out)
+}
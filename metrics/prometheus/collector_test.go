@@ -0,0 +1,138 @@
+package prometheus
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"truechain/discovery/metrics"
+)
+
+func TestMain(m *testing.M) {
+	metrics.Enabled = true
+	os.Exit(m.Run())
+}
+
+func TestHandler(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	counter := metrics.NewRegisteredCounter("txpool/quota", reg)
+	counter.Inc(12345)
+
+	gauge := metrics.NewRegisteredGauge("txpool/lane/staking/pending", reg)
+	gauge.Update(23456)
+
+	gaugeFloat64 := metrics.NewRegisteredGaugeFloat64("test/gauge_float64", reg)
+	gaugeFloat64.Update(34567.89)
+
+	meter := metrics.NewRegisteredMeter("consensus/tbft/p2p/in/packets", reg)
+	meter.Mark(9999999)
+	defer meter.Stop()
+
+	histogram := metrics.NewRegisteredHistogram("test/histogram", reg, metrics.NewUniformSample(100))
+	for i := int64(1); i <= 4; i++ {
+		histogram.Update(i)
+	}
+	timer := metrics.NewRegisteredTimer("etrue/downloader/headers/req", reg)
+	timer.Update(20 * time.Millisecond)
+	timer.Update(40 * time.Millisecond)
+	defer timer.Stop()
+
+	resetting := metrics.NewRegisteredResettingTimer("test/resetting_timer", reg)
+	resetting.Update(10 * time.Millisecond)
+	resetting.Update(30 * time.Millisecond)
+
+	metrics.NewRegisteredResettingTimer("test/empty_resetting_timer", reg)
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/metrics/prometheus", nil))
+
+	const expected = `# TYPE consensus_tbft_p2p_in_packets counter
+consensus_tbft_p2p_in_packets 9999999
+# TYPE etrue_downloader_headers_req summary
+etrue_downloader_headers_req{quantile="0.5"} 3e+07
+etrue_downloader_headers_req{quantile="0.75"} 4e+07
+etrue_downloader_headers_req{quantile="0.95"} 4e+07
+etrue_downloader_headers_req{quantile="0.99"} 4e+07
+etrue_downloader_headers_req{quantile="0.999"} 4e+07
+etrue_downloader_headers_req{quantile="0.9999"} 4e+07
+etrue_downloader_headers_req_sum 60000000
+etrue_downloader_headers_req_count 2
+# TYPE test_gauge_float64 gauge
+test_gauge_float64 34567.89
+# TYPE test_histogram summary
+test_histogram{quantile="0.5"} 2.5
+test_histogram{quantile="0.75"} 3.75
+test_histogram{quantile="0.95"} 4
+test_histogram{quantile="0.99"} 4
+test_histogram{quantile="0.999"} 4
+test_histogram{quantile="0.9999"} 4
+test_histogram_sum 10
+test_histogram_count 4
+# TYPE test_resetting_timer summary
+test_resetting_timer{quantile="0.5"} 1e+07
+test_resetting_timer{quantile="0.95"} 3e+07
+test_resetting_timer{quantile="0.99"} 3e+07
+test_resetting_timer_sum 40000000
+test_resetting_timer_count 2
+# TYPE txpool_lane_staking_pending gauge
+txpool_lane_staking_pending 23456
+# TYPE txpool_quota gauge
+txpool_quota 12345
+`
+	if have := rec.Body.String(); have != expected {
+		t.Errorf("output mismatch:\nhave:\n%s\nwant:\n%s", have, expected)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Errorf("content type mismatch: have %q", ct)
+	}
+}
+
+// Tests that the metrics whose Prometheus names collide with the ones reported
+// before are skipped.
+func TestHandlerCollisions(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	metrics.NewRegisteredGauge("p2p/peers", reg).Update(1)
+	metrics.NewRegisteredGauge("p2p_peers", reg).Update(2)
+	metrics.NewRegisteredCounter("p2p.peers", reg).Inc(3)
+
+	metrics.NewRegisteredHistogram("test/histogram", reg, metrics.NewUniformSample(100)).Update(4)
+	metrics.NewRegisteredGauge("test/histogram_count", reg).Update(5)
+	metrics.NewRegisteredGauge("test/histogram/sum", reg).Update(6)
+
+	rec := httptest.NewRecorder()
+	Handler(reg).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/metrics/prometheus", nil))
+
+	const expected = `# TYPE p2p_peers gauge
+p2p_peers 3
+# TYPE test_histogram summary
+test_histogram{quantile="0.5"} 4
+test_histogram{quantile="0.75"} 4
+test_histogram{quantile="0.95"} 4
+test_histogram{quantile="0.99"} 4
+test_histogram{quantile="0.999"} 4
+test_histogram{quantile="0.9999"} 4
+test_histogram_sum 4
+test_histogram_count 1
+`
+	if have := rec.Body.String(); have != expected {
+		t.Errorf("output mismatch:\nhave:\n%s\nwant:\n%s", have, expected)
+	}
+}
+
+func TestMutateKey(t *testing.T) {
+	tests := map[string]string{
+		"consensus/tbft/time/PreVote": "consensus_tbft_time_PreVote",
+		"p2p/InboundTraffic":          "p2p_InboundTraffic",
+		"system/cpu/sysload.total":    "system_cpu_sysload_total",
+		"fruitpool/dropped/stale":     "fruitpool_dropped_stale",
+		"99-problems":                 "_99_problems",
+	}
+	for key, want := range tests {
+		if have := mutateKey(key); have != want {
+			t.Errorf("%q: have %q, want %q", key, have, want)
+		}
+	}
+}
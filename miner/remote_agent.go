@@ -28,6 +28,7 @@ import (
 	"truechain/discovery/common"
 	"truechain/discovery/consensus"
 	"truechain/discovery/core/types"
+	"truechain/discovery/event"
 	"truechain/discovery/log"
 )
 
@@ -54,6 +55,7 @@ type RemoteAgent struct {
 	engine      consensus.Engine
 	currentWork *Work
 	work        map[common.Hash]*Work
+	workFeed    event.Feed
 
 	hashrateMu sync.RWMutex
 	hashrate   map[common.Hash]hashrate
@@ -147,22 +149,7 @@ func (a *RemoteAgent) GetWork() ([4]string, error) {
 		//res[1] = "0x" + hex.EncodeToString(DatasetHash)
 		res[1] = a.engine.DataSetHash(epoch)
 		// Calculate the "target" to be returned to the external miner
-		block.Fruits()
-		if block.IsFruit() {
-			// is fruit  so the block target set zore
-			fruitTarget = new(big.Int).Div(maxUint128, block.FruitDifficulty())
-			blockTarget = new(big.Int).SetInt64(0)
-		} else {
-
-			if block.FastNumber().Cmp(big.NewInt(0)) == 0 {
-				// only block
-				fruitTarget = new(big.Int).SetInt64(0)
-				blockTarget = new(big.Int).Div(maxUint128, block.BlockDifficulty())
-			} else {
-				fruitTarget = new(big.Int).Div(maxUint128, block.FruitDifficulty())
-				blockTarget = new(big.Int).Div(maxUint128, block.BlockDifficulty())
-			}
-		}
+		fruitTarget, blockTarget = workTargets(block)
 		res[2] = a.CompletionHexString(32, hex.EncodeToString(fruitTarget.Bytes()))
 		res[3] = a.CompletionHexString(32, hex.EncodeToString(blockTarget.Bytes()))
 		a.work[block.HashNoNonce()] = a.currentWork
@@ -171,6 +158,49 @@ func (a *RemoteAgent) GetWork() ([4]string, error) {
 	return res, errors.New("No work available yet, Don't panic.")
 }
 
+// workTargets returns the fruit and block targets of the work, the target is
+// zero if the fruit or the block isn't mined.
+func workTargets(block *types.SnailBlock) (fruitTarget *big.Int, blockTarget *big.Int) {
+	if block.IsFruit() {
+		// is fruit  so the block target set zore
+		fruitTarget = new(big.Int).Div(maxUint128, block.FruitDifficulty())
+		blockTarget = new(big.Int).SetInt64(0)
+	} else {
+
+		if block.FastNumber().Cmp(big.NewInt(0)) == 0 {
+			// only block
+			fruitTarget = new(big.Int).SetInt64(0)
+			blockTarget = new(big.Int).Div(maxUint128, block.BlockDifficulty())
+		} else {
+			fruitTarget = new(big.Int).Div(maxUint128, block.FruitDifficulty())
+			blockTarget = new(big.Int).Div(maxUint128, block.BlockDifficulty())
+		}
+	}
+	return fruitTarget, blockTarget
+}
+
+// SubscribeWork registers a subscription of the works pushed to the agent, the
+// work package of GetWork is the new one once received.
+func (a *RemoteAgent) SubscribeWork(ch chan<- *Work) event.Subscription {
+	return a.workFeed.Subscribe(ch)
+}
+
+// sealedWork returns the work handed out of the hash and a copy of its header
+// sealed with the solution, nil if the work isn't pending.
+func (a *RemoteAgent) sealedWork(nonce types.BlockNonce, mixDigest, hash common.Hash) (*Work, *types.SnailHeader) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	work := a.work[hash]
+	if work == nil {
+		return nil, nil
+	}
+	header := work.Block.Header()
+	header.Nonce = nonce
+	header.MixDigest = mixDigest
+	return work, header
+}
+
 func (a *RemoteAgent) CompletionHexString(n int, src string) string {
 	var res string
 	if n <= 0 || len(src) > n {
@@ -361,6 +391,7 @@ func (a *RemoteAgent) loop(workCh chan *Work, quitCh chan struct{}) {
 			a.mu.Lock()
 			a.currentWork = work
 			a.mu.Unlock()
+			a.workFeed.Send(work)
 		case <-ticker.C:
 			// cleanup
 			a.mu.Lock()
@@ -0,0 +1,539 @@
+package miner
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+	"truechain/discovery/core/types"
+	"truechain/discovery/event"
+	"truechain/discovery/log"
+	"truechain/discovery/metrics"
+)
+
+const (
+	stratumReadLimit    = 4096             // Maximum size of a request line
+	stratumWriteTimeout = 10 * time.Second // Timeout of a response or a work pushed to a worker
+	stratumSeenWorks    = 16               // Number of works whose nonces are kept to reject the duplicated shares
+	stratumMaxWorkers   = 1024             // Maximum number of workers logged in at once
+)
+
+var (
+	stratumSessionGauge  = metrics.NewRegisteredGauge("miner/stratum/sessions", nil)
+	stratumSolutionMeter = metrics.NewRegisteredMeter("miner/stratum/solutions", nil)
+
+	stratumWorkerName = regexp.MustCompile(`^[0-9A-Za-z_.\-]{1,64}$`)
+
+	errStratumUnauthorized   = errors.New("worker not logged in")
+	errStratumInvalidWorker  = errors.New("invalid worker name")
+	errStratumTooManyWorkers = errors.New("too many workers")
+	errStratumInvalidParams  = errors.New("invalid params")
+	errStratumNoWork         = errors.New("no work available yet")
+	errStratumStaleShare     = errors.New("stale share")
+	errStratumDuplicate      = errors.New("duplicate share")
+	errStratumLowDifficulty  = errors.New("low difficulty share")
+)
+
+// digestVerifier is implemented by the engines able to return the PoW result of
+// a seal, the shares below the fruit difficulty are checked by it.
+type digestVerifier interface {
+	VerifySnailDigest(header *types.SnailHeader) ([]byte, error)
+}
+
+// StratumWorker is the shares submitted by a worker of the stratum server.
+type StratumWorker struct {
+	Name      string    `json:"name"`
+	Shares    uint64    `json:"shares"`
+	Invalid   uint64    `json:"invalid"`
+	Stale     uint64    `json:"stale"`
+	Solutions uint64    `json:"solutions"`
+	Hashrate  uint64    `json:"hashrate"`
+	LastShare time.Time `json:"lastShare"`
+
+	sessions int // Sessions logged in as the worker
+
+	// The meters are registered once the worker proved its work with an
+	// accepted share, the logins alone don't grow the metrics registry
+	shareMeter   metrics.Meter
+	invalidMeter metrics.Meter
+	staleMeter   metrics.Meter
+}
+
+func (w *StratumWorker) meterName(kind string) string {
+	return "miner/stratum/workers/" + w.Name + "/" + kind
+}
+
+// registerMeters registers the meters of the worker if not done yet.
+func (w *StratumWorker) registerMeters() {
+	if w.shareMeter != nil {
+		return
+	}
+	w.shareMeter = metrics.GetOrRegisterMeter(w.meterName("shares"), nil)
+	w.invalidMeter = metrics.GetOrRegisterMeter(w.meterName("invalid"), nil)
+	w.staleMeter = metrics.GetOrRegisterMeter(w.meterName("stale"), nil)
+}
+
+// unregisterMeters drops the meters of the worker from the registry.
+func (w *StratumWorker) unregisterMeters() {
+	if w.shareMeter == nil {
+		return
+	}
+	for _, kind := range []string{"shares", "invalid", "stale"} {
+		metrics.Unregister(w.meterName(kind))
+	}
+	w.shareMeter, w.invalidMeter, w.staleMeter = nil, nil, nil
+}
+
+// markMeter marks the meter of a worker if it's registered.
+func markMeter(meter metrics.Meter) {
+	if meter != nil {
+		meter.Mark(1)
+	}
+}
+
+// StratumServer serves the works of the remote agent to the miners over the
+// stratum protocol, i.e. newline delimited JSON-RPC over TCP in the dialect of
+// the eth proxies (eth_submitLogin, eth_getWork, eth_submitWork and
+// eth_submitHashrate). The new works are pushed to the workers logged in.
+//
+// The fruit target handed out is the share target if it's easier, the shares
+// are counted per worker and the solutions are submitted to the agent.
+type StratumServer struct {
+	agent      *RemoteAgent
+	target     *big.Int // Share target, 2^128 / share difficulty
+	maxWorkers int      // Maximum number of workers logged in at once
+
+	mu        sync.Mutex
+	listener  net.Listener
+	sessions  map[*stratumSession]struct{}
+	workers   map[string]*StratumWorker
+	work      [4]string                                     // Work package pushed to the workers
+	seen      map[common.Hash]map[types.BlockNonce]struct{} // Nonces submitted of the recent works
+	seenOrder []common.Hash
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewStratumServer creates a stratum server for the works of the agent, the
+// share difficulty is the one of the fruit if zero.
+func NewStratumServer(agent *RemoteAgent, difficulty *big.Int) *StratumServer {
+	target := new(big.Int)
+	if difficulty != nil && difficulty.Sign() > 0 {
+		target.Div(maxUint128, difficulty)
+	}
+	return &StratumServer{
+		agent:      agent,
+		target:     target,
+		maxWorkers: stratumMaxWorkers,
+		sessions:   make(map[*stratumSession]struct{}),
+		workers:    make(map[string]*StratumWorker),
+		seen:       make(map[common.Hash]map[types.BlockNonce]struct{}),
+	}
+}
+
+// Start listens for the workers on the TCP address.
+func (s *StratumServer) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.quit = make(chan struct{})
+	s.mu.Unlock()
+
+	workCh := make(chan *Work, 1)
+	workSub := s.agent.SubscribeWork(workCh)
+
+	s.wg.Add(2)
+	go s.acceptLoop(listener)
+	go s.workLoop(workCh, workSub)
+
+	log.Info("Stratum server started", "addr", listener.Addr())
+	return nil
+}
+
+// Stop closes the listener and the sessions of the workers.
+func (s *StratumServer) Stop() {
+	s.mu.Lock()
+	if s.listener == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.listener.Close()
+	s.listener = nil
+	close(s.quit)
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	log.Info("Stratum server stopped")
+}
+
+// Addr returns the address the server is listening on, nil if not started.
+func (s *StratumServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Workers returns a copy of the shares of the workers logged in.
+func (s *StratumServer) Workers() []StratumWorker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workers := make([]StratumWorker, 0, len(s.workers))
+	for _, worker := range s.workers {
+		workers = append(workers, *worker)
+	}
+	return workers
+}
+
+func (s *StratumServer) acceptLoop(listener net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+			default:
+				log.Warn("Stratum accept failed", "err", err)
+			}
+			return
+		}
+		session := &stratumSession{conn: conn, enc: json.NewEncoder(conn)}
+
+		s.mu.Lock()
+		s.sessions[session] = struct{}{}
+		s.mu.Unlock()
+		stratumSessionGauge.Inc(1)
+
+		s.wg.Add(1)
+		go s.handle(session)
+	}
+}
+
+// workLoop pushes the new works of the agent to the workers.
+func (s *StratumServer) workLoop(workCh chan *Work, workSub event.Subscription) {
+	defer s.wg.Done()
+	defer workSub.Unsubscribe()
+
+	for {
+		select {
+		case newWork := <-workCh:
+			work, err := s.agent.GetWork()
+			if err != nil {
+				log.Debug("Stratum work not available", "err", err)
+				continue
+			}
+			if fruitTarget, _ := workTargets(newWork.Block); fruitTarget.Cmp(s.target) < 0 {
+				work[2] = s.agent.CompletionHexString(32, hex.EncodeToString(s.target.Bytes()))
+			}
+			s.mu.Lock()
+			s.work = work
+			sessions := make([]*stratumSession, 0, len(s.sessions))
+			for session := range s.sessions {
+				if session.worker != nil {
+					sessions = append(sessions, session)
+				}
+			}
+			s.mu.Unlock()
+
+			for _, session := range sessions {
+				if err := session.send(&stratumResponse{Id: stratumPushId, Version: "2.0", Result: work}); err != nil {
+					log.Debug("Stratum work push failed", "remote", session.conn.RemoteAddr(), "err", err)
+				}
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *StratumServer) handle(session *stratumSession) {
+	defer s.wg.Done()
+	defer func() {
+		session.conn.Close()
+		s.mu.Lock()
+		delete(s.sessions, session)
+		s.logout(session)
+		s.mu.Unlock()
+		stratumSessionGauge.Dec(1)
+	}()
+
+	reader := bufio.NewReaderSize(session.conn, stratumReadLimit)
+	for {
+		line, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		if isPrefix {
+			log.Debug("Stratum request too large", "remote", session.conn.RemoteAddr())
+			return
+		}
+		if len(line) == 0 {
+			continue
+		}
+		var req stratumRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			log.Debug("Stratum malformed request", "remote", session.conn.RemoteAddr(), "err", err)
+			return
+		}
+		result, err := s.dispatch(session, &req)
+		res := &stratumResponse{Id: req.Id, Version: "2.0", Result: result}
+		if err != nil {
+			res.Result, res.Error = nil, &stratumError{Code: -1, Message: err.Error()}
+		}
+		if err := session.send(res); err != nil {
+			return
+		}
+	}
+}
+
+func (s *StratumServer) dispatch(session *stratumSession, req *stratumRequest) (interface{}, error) {
+	if req.Method == "eth_submitLogin" {
+		return s.login(session, req)
+	}
+	if session.worker == nil {
+		return nil, errStratumUnauthorized
+	}
+	switch req.Method {
+	case "eth_getWork":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.work[0] == "" {
+			return nil, errStratumNoWork
+		}
+		return s.work, nil
+
+	case "eth_submitWork":
+		if len(req.Params) != 3 {
+			return nil, errStratumInvalidParams
+		}
+		var nonce types.BlockNonce
+		if err := nonce.UnmarshalText([]byte(req.Params[0])); err != nil {
+			return nil, errStratumInvalidParams
+		}
+		if err := s.submitShare(session.worker, nonce, common.HexToHash(req.Params[1]), common.HexToHash(req.Params[2])); err != nil {
+			return false, err
+		}
+		return true, nil
+
+	case "eth_submitHashrate":
+		if len(req.Params) != 2 {
+			return nil, errStratumInvalidParams
+		}
+		rate, err := hexutil.DecodeUint64(req.Params[0])
+		if err != nil {
+			return nil, errStratumInvalidParams
+		}
+		s.agent.SubmitHashrate(common.HexToHash(req.Params[1]), rate)
+		s.mu.Lock()
+		session.worker.Hashrate = rate
+		s.mu.Unlock()
+		return true, nil
+	}
+	return nil, errors.New("method not found: " + req.Method)
+}
+
+// login authorizes the session for the worker, the name is the login followed
+// by the worker field if any.
+func (s *StratumServer) login(session *stratumSession, req *stratumRequest) (interface{}, error) {
+	if len(req.Params) == 0 {
+		return nil, errStratumInvalidParams
+	}
+	name := req.Params[0]
+	if req.Worker != "" {
+		name += "." + req.Worker
+	}
+	if !stratumWorkerName.MatchString(name) {
+		return nil, errStratumInvalidWorker
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	worker := s.workers[name]
+	if worker == nil {
+		if len(s.workers) >= s.maxWorkers {
+			return nil, errStratumTooManyWorkers
+		}
+		worker = &StratumWorker{Name: name}
+		s.workers[name] = worker
+	}
+	if session.worker != worker {
+		s.logout(session)
+		worker.sessions++
+		session.worker = worker
+	}
+	log.Debug("Stratum worker logged in", "worker", name, "remote", session.conn.RemoteAddr())
+	return true, nil
+}
+
+// logout detaches the session from its worker, the worker and its meters are
+// dropped with its last session. The lock must be held.
+func (s *StratumServer) logout(session *stratumSession) {
+	worker := session.worker
+	if worker == nil {
+		return
+	}
+	session.worker = nil
+	worker.sessions--
+	if worker.sessions == 0 {
+		delete(s.workers, worker.Name)
+		worker.unregisterMeters()
+	}
+}
+
+// submitShare checks the share against the share target and submits it to the
+// agent if it's a solution of the work.
+func (s *StratumServer) submitShare(worker *StratumWorker, nonce types.BlockNonce, hash, mixDigest common.Hash) error {
+	work, header := s.agent.sealedWork(nonce, mixDigest, hash)
+	if work == nil {
+		s.mu.Lock()
+		worker.Stale++
+		markMeter(worker.staleMeter)
+		s.mu.Unlock()
+		return errStratumStaleShare
+	}
+	if !s.markSeen(hash, nonce) {
+		return s.rejectShare(worker, errStratumDuplicate)
+	}
+	fruitTarget, blockTarget := workTargets(work.Block)
+
+	verifier, ok := s.agent.engine.(digestVerifier)
+	if !ok {
+		// Only the solutions can be checked by the engine
+		if !s.agent.SubmitWork(nonce, mixDigest, hash) {
+			return s.rejectShare(worker, errStratumLowDifficulty)
+		}
+		s.acceptShare(worker, true)
+		return nil
+	}
+	result, err := verifier.VerifySnailDigest(header)
+	if err != nil {
+		return s.rejectShare(worker, err)
+	}
+	var (
+		fruitResult = new(big.Int).SetBytes(result[16:])
+		blockResult = new(big.Int).SetBytes(result[:16])
+		shareTarget = s.target
+	)
+	if fruitTarget.Cmp(shareTarget) > 0 {
+		shareTarget = fruitTarget
+	}
+	isBlock := blockTarget.Sign() > 0 && blockResult.Cmp(blockTarget) <= 0
+	isFruit := fruitTarget.Sign() > 0 && fruitResult.Cmp(fruitTarget) <= 0
+	if !isBlock && fruitResult.Cmp(shareTarget) > 0 {
+		return s.rejectShare(worker, errStratumLowDifficulty)
+	}
+	solution := (isBlock || isFruit) && s.agent.SubmitWork(nonce, mixDigest, hash)
+	s.acceptShare(worker, solution)
+	return nil
+}
+
+// markSeen records the nonce submitted for the work, it returns false if the
+// nonce is submitted already.
+func (s *StratumServer) markSeen(hash common.Hash, nonce types.BlockNonce) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nonces, ok := s.seen[hash]
+	if !ok {
+		nonces = make(map[types.BlockNonce]struct{})
+		s.seen[hash] = nonces
+		s.seenOrder = append(s.seenOrder, hash)
+		if len(s.seenOrder) > stratumSeenWorks {
+			delete(s.seen, s.seenOrder[0])
+			s.seenOrder = s.seenOrder[1:]
+		}
+	}
+	if _, dup := nonces[nonce]; dup {
+		return false
+	}
+	nonces[nonce] = struct{}{}
+	return true
+}
+
+func (s *StratumServer) acceptShare(worker *StratumWorker, solution bool) {
+	s.mu.Lock()
+	worker.Shares++
+	worker.LastShare = time.Now()
+	if solution {
+		worker.Solutions++
+	}
+	// Logged out workers are dropped already, don't register them again
+	if worker.sessions > 0 {
+		worker.registerMeters()
+	}
+	markMeter(worker.shareMeter)
+	s.mu.Unlock()
+
+	if solution {
+		stratumSolutionMeter.Mark(1)
+		log.Info("Stratum solution submitted", "worker", worker.Name)
+	}
+}
+
+func (s *StratumServer) rejectShare(worker *StratumWorker, err error) error {
+	s.mu.Lock()
+	worker.Invalid++
+	markMeter(worker.invalidMeter)
+	s.mu.Unlock()
+
+	log.Debug("Stratum share rejected", "worker", worker.Name, "err", err)
+	return err
+}
+
+// stratumPushId is the id of the works pushed to the workers.
+var stratumPushId = json.RawMessage("0")
+
+type stratumRequest struct {
+	Id     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []string        `json:"params"`
+	Worker string          `json:"worker,omitempty"`
+}
+
+type stratumResponse struct {
+	Id      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *stratumError   `json:"error,omitempty"`
+}
+
+type stratumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type stratumSession struct {
+	conn   net.Conn
+	enc    *json.Encoder
+	mu     sync.Mutex     // Protects the writes to the connection
+	worker *StratumWorker // Worker logged in, guarded by the server lock
+}
+
+func (session *stratumSession) send(res *stratumResponse) error {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	session.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	return session.enc.Encode(res)
+}
@@ -0,0 +1,158 @@
+package miner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/common/hexutil"
+	"truechain/discovery/core/types"
+)
+
+var errStratumClientClosed = errors.New("stratum client closed")
+
+// StratumClient is a worker of a stratum server, it is meant to check a server
+// locally instead of running a real miner.
+type StratumClient struct {
+	conn    net.Conn
+	enc     *json.Encoder
+	timeout time.Duration
+
+	mu      sync.Mutex
+	nextId  uint64
+	pending map[string]chan *stratumClientResponse
+
+	works  chan [4]string
+	closed chan struct{}
+}
+
+type stratumClientResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+// DialStratum connects to the stratum server at the TCP address.
+func DialStratum(addr string) (*StratumClient, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &StratumClient{
+		conn:    conn,
+		enc:     json.NewEncoder(conn),
+		timeout: 10 * time.Second,
+		pending: make(map[string]chan *stratumClientResponse),
+		works:   make(chan [4]string, 16),
+		closed:  make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Close disconnects from the server.
+func (c *StratumClient) Close() error {
+	return c.conn.Close()
+}
+
+// Works returns the channel of the works pushed by the server.
+func (c *StratumClient) Works() <-chan [4]string {
+	return c.works
+}
+
+// Login logs the worker in, the worker name is appended to the login if set.
+func (c *StratumClient) Login(login, worker string) error {
+	var ok bool
+	return c.call(&ok, "eth_submitLogin", worker, login)
+}
+
+// GetWork returns the work package of the server.
+func (c *StratumClient) GetWork() ([4]string, error) {
+	var work [4]string
+	err := c.call(&work, "eth_getWork", "")
+	return work, err
+}
+
+// SubmitWork submits a share of the work of the hash.
+func (c *StratumClient) SubmitWork(nonce types.BlockNonce, hash, mixDigest common.Hash) (bool, error) {
+	var ok bool
+	err := c.call(&ok, "eth_submitWork", "", hexutil.Encode(nonce[:]), hash.Hex(), mixDigest.Hex())
+	return ok, err
+}
+
+// SubmitHashrate reports the hashrate of the worker.
+func (c *StratumClient) SubmitHashrate(rate uint64, id common.Hash) error {
+	var ok bool
+	return c.call(&ok, "eth_submitHashrate", "", hexutil.EncodeUint64(rate), id.Hex())
+}
+
+func (c *StratumClient) call(result interface{}, method string, worker string, params ...string) error {
+	c.mu.Lock()
+	c.nextId++
+	id := strconv.FormatUint(c.nextId, 10)
+	ch := make(chan *stratumClientResponse, 1)
+	c.pending[id] = ch
+	if params == nil {
+		params = []string{}
+	}
+	err := c.enc.Encode(&stratumRequest{Id: json.RawMessage(id), Method: method, Params: params, Worker: worker})
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+	select {
+	case res := <-ch:
+		if res.Error != nil {
+			return errors.New(res.Error.Message)
+		}
+		return json.Unmarshal(res.Result, result)
+	case <-c.closed:
+		return errStratumClientClosed
+	case <-time.After(c.timeout):
+		return errors.New("stratum request timeout")
+	}
+}
+
+func (c *StratumClient) readLoop() {
+	defer close(c.closed)
+
+	reader := bufio.NewReader(c.conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var res stratumClientResponse
+		if err := json.Unmarshal(line, &res); err != nil {
+			return
+		}
+		if bytes.Equal(res.Id, stratumPushId) {
+			var work [4]string
+			if err := json.Unmarshal(res.Result, &work); err == nil {
+				select {
+				case c.works <- work:
+				default:
+				}
+			}
+			continue
+		}
+		c.mu.Lock()
+		ch := c.pending[string(res.Id)]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- &res
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package miner
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"truechain/discovery/common"
+	"truechain/discovery/consensus"
+	"truechain/discovery/core/types"
+	"truechain/discovery/metrics"
+	"truechain/discovery/params"
+)
+
+// stratumTestChain is the chain of the remote agent, only the config is read.
+type stratumTestChain struct {
+	consensus.ChainReader
+	config *params.ChainConfig
+}
+
+func (c stratumTestChain) Config() *params.ChainConfig { return c.config }
+
+// stratumTestEngine returns the PoW results of the nonces instead of hashing.
+type stratumTestEngine struct {
+	consensus.Engine
+	results map[types.BlockNonce][]byte
+}
+
+func (e *stratumTestEngine) DataSetHash(epoch uint64) string { return common.Hash{}.Hex() }
+
+func (e *stratumTestEngine) VerifySnailDigest(header *types.SnailHeader) ([]byte, error) {
+	result, ok := e.results[header.Nonce]
+	if !ok {
+		return nil, errors.New("invalid mix digest")
+	}
+	return result, nil
+}
+
+func (e *stratumTestEngine) VerifySnailSeal(chain consensus.SnailChainReader, header *types.SnailHeader, isFruit bool) error {
+	result, err := e.VerifySnailDigest(header)
+	if err != nil {
+		return err
+	}
+	if !isFruit || new(big.Int).SetBytes(result[16:]).Cmp(new(big.Int).Div(maxUint128, header.FruitDifficulty)) > 0 {
+		return errors.New("invalid proof-of-work")
+	}
+	return nil
+}
+
+// stratumTestResult returns a PoW result whose fruit part is the value.
+func stratumTestResult(fruit *big.Int) []byte {
+	result := make([]byte, 32)
+	copy(result[32-len(fruit.Bytes()):], fruit.Bytes())
+	return result
+}
+
+// newStratumTestServer starts a stratum server on a remote agent whose engine
+// knows the PoW results of the nonces 1 (share), 2 (below the share difficulty)
+// and 3 (fruit).
+func newStratumTestServer(t *testing.T) (*RemoteAgent, *StratumServer, chan *Result) {
+	engine := &stratumTestEngine{results: map[types.BlockNonce][]byte{
+		types.EncodeNonce(1): stratumTestResult(new(big.Int).Lsh(common.Big1, 112)), // share
+		types.EncodeNonce(2): stratumTestResult(new(big.Int).Lsh(common.Big1, 120)), // below the share difficulty
+		types.EncodeNonce(3): stratumTestResult(common.Big1),                        // fruit
+	}}
+	config := *params.TestChainConfig
+	config.TIP9 = &params.BlockConfig{SnailNumber: big.NewInt(20)}
+	agent := NewRemoteAgent(stratumTestChain{config: &config}, nil, engine)
+	returnCh := make(chan *Result, 1)
+	agent.SetReturnCh(returnCh)
+	agent.Start()
+
+	server := NewStratumServer(agent, new(big.Int).Lsh(common.Big1, 10))
+	if err := server.Start("127.0.0.1:0"); err != nil {
+		agent.Stop()
+		t.Fatalf("failed to start server: %v", err)
+	}
+	return agent, server, returnCh
+}
+
+// pushStratumTestWork hands a work to the agent and returns the hash pushed
+// to the client.
+func pushStratumTestWork(t *testing.T, agent *RemoteAgent, client *StratumClient) ([4]string, common.Hash) {
+	block := types.NewSnailBlockWithHeader(&types.SnailHeader{
+		Number:          big.NewInt(1),
+		FastNumber:      big.NewInt(1),
+		Difficulty:      new(big.Int).Lsh(common.Big1, 30),
+		FruitDifficulty: new(big.Int).Lsh(common.Big1, 20),
+	})
+	agent.Work() <- &Work{Block: block, createdAt: time.Now()}
+
+	select {
+	case work := <-client.Works():
+		return work, common.HexToHash(work[0])
+	case <-time.After(5 * time.Second):
+		t.Fatalf("work not pushed")
+	}
+	return [4]string{}, common.Hash{}
+}
+
+func TestStratumShares(t *testing.T) {
+	agent, server, returnCh := newStratumTestServer(t)
+	defer agent.Stop()
+	defer server.Stop()
+
+	client, err := DialStratum(server.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.GetWork(); err == nil {
+		t.Fatalf("work handed out before login")
+	}
+	if err := client.Login("0x0000000000000000000000000000000000000001", "rig0"); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+	work, hash := pushStratumTestWork(t, agent, client)
+	if want := agent.CompletionHexString(32, common.Bytes2Hex(new(big.Int).Lsh(common.Big1, 118).Bytes())); work[2] != want {
+		t.Errorf("share target mismatch: have %s, want %s", work[2], want)
+	}
+
+	if ok, err := client.SubmitWork(types.EncodeNonce(1), hash, common.Hash{}); !ok || err != nil {
+		t.Errorf("share rejected: %v", err)
+	}
+	if _, err := client.SubmitWork(types.EncodeNonce(1), hash, common.Hash{}); err == nil || err.Error() != errStratumDuplicate.Error() {
+		t.Errorf("duplicate share error mismatch: have %v, want %v", err, errStratumDuplicate)
+	}
+	if _, err := client.SubmitWork(types.EncodeNonce(2), hash, common.Hash{}); err == nil || err.Error() != errStratumLowDifficulty.Error() {
+		t.Errorf("low share error mismatch: have %v, want %v", err, errStratumLowDifficulty)
+	}
+	if _, err := client.SubmitWork(types.EncodeNonce(1), common.Hash{1}, common.Hash{}); err == nil || err.Error() != errStratumStaleShare.Error() {
+		t.Errorf("stale share error mismatch: have %v, want %v", err, errStratumStaleShare)
+	}
+	select {
+	case <-returnCh:
+		t.Fatalf("share submitted as a solution")
+	default:
+	}
+	if ok, err := client.SubmitWork(types.EncodeNonce(3), hash, common.Hash{}); !ok || err != nil {
+		t.Errorf("solution rejected: %v", err)
+	}
+	select {
+	case result := <-returnCh:
+		if nonce := result.Block.Nonce(); nonce != 3 {
+			t.Errorf("solution nonce mismatch: have %d, want 3", nonce)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("solution not submitted")
+	}
+	if err := client.SubmitHashrate(100, common.Hash{1}); err != nil {
+		t.Errorf("failed to submit hashrate: %v", err)
+	}
+
+	workers := server.Workers()
+	if len(workers) != 1 {
+		t.Fatalf("worker count mismatch: have %d, want 1", len(workers))
+	}
+	worker := workers[0]
+	if worker.Name != "0x0000000000000000000000000000000000000001.rig0" {
+		t.Errorf("worker name mismatch: have %s", worker.Name)
+	}
+	if worker.Shares != 2 || worker.Invalid != 2 || worker.Stale != 1 || worker.Solutions != 1 || worker.Hashrate != 100 {
+		t.Errorf("worker shares mismatch: have %+v", worker)
+	}
+}
+
+// Tests that the workers are capped, their meters are only registered once a
+// share is accepted and dropped with their last session.
+func TestStratumWorkerLimits(t *testing.T) {
+	agent, server, _ := newStratumTestServer(t)
+	defer agent.Stop()
+	defer server.Stop()
+	server.maxWorkers = 2
+
+	const login = "0x0000000000000000000000000000000000000001"
+	clients := make([]*StratumClient, 3)
+	for i := range clients {
+		client, err := DialStratum(server.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to dial server: %v", err)
+		}
+		defer client.Close()
+		clients[i] = client
+	}
+	tests := []struct {
+		client int
+		worker string
+		err    error
+	}{
+		{0, "rig0", nil},
+		{1, "rig1", nil},
+		{2, "rig2", errStratumTooManyWorkers},
+		{2, "rig1", nil}, // the worker is logged in already
+	}
+	for i, tt := range tests {
+		err := clients[tt.client].Login(login, tt.worker)
+		if (err == nil) != (tt.err == nil) || (err != nil && err.Error() != tt.err.Error()) {
+			t.Errorf("test %d: login error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	meter := "miner/stratum/workers/" + login + ".rig0/shares"
+	if metrics.DefaultRegistry.Get(meter) != nil {
+		t.Fatalf("meter registered on login")
+	}
+	_, hash := pushStratumTestWork(t, agent, clients[0])
+	if _, err := clients[0].SubmitWork(types.EncodeNonce(2), hash, common.Hash{}); err == nil {
+		t.Fatalf("low share accepted")
+	}
+	if metrics.DefaultRegistry.Get(meter) != nil {
+		t.Fatalf("meter registered on an invalid share")
+	}
+	if ok, err := clients[0].SubmitWork(types.EncodeNonce(1), hash, common.Hash{}); !ok || err != nil {
+		t.Fatalf("share rejected: %v", err)
+	}
+	if metrics.DefaultRegistry.Get(meter) == nil {
+		t.Fatalf("meter not registered on an accepted share")
+	}
+
+	clients[0].Close()
+	for start := time.Now(); len(server.Workers()) != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("worker not dropped on disconnect: %v", server.Workers())
+		}
+	}
+	if metrics.DefaultRegistry.Get(meter) != nil {
+		t.Errorf("meter not unregistered on disconnect")
+	}
+	if err := clients[2].Login(login, "rig2"); err != nil {
+		t.Errorf("login refused after a worker left: %v", err)
+	}
+}
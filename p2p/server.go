@@ -296,6 +296,19 @@ func (srv *Server) LocalNode() *enode.LocalNode {
 	return srv.localnode
 }
 
+// Resolve asks the discovery table for the most recent record of the node. It
+// returns n if the node could not be resolved or discovery is not running.
+func (srv *Server) Resolve(n *enode.Node) *enode.Node {
+	srv.lock.Lock()
+	ntab := srv.ntab
+	srv.lock.Unlock()
+
+	if ntab == nil {
+		return n
+	}
+	return ntab.Resolve(n)
+}
+
 // Peers returns all connected peers.
 func (srv *Server) Peers() []*Peer {
 	var ps []*Peer
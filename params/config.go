@@ -148,13 +148,16 @@ var (
 			MinimumFruitDifficulty: big.NewInt(2),
 			DurationLimit:          big.NewInt(120),
 		}),
-		TIP3:  &BlockConfig{FastNumber: big.NewInt(380000)},
-		TIP5:  &BlockConfig{SnailNumber: big.NewInt(5000)},
-		TIP7:  &BlockConfig{FastNumber: big.NewInt(0)},
-		TIP8:  &BlockConfig{FastNumber: big.NewInt(100), CID: big.NewInt(-1)},
-		TIP9:  &BlockConfig{SnailNumber: big.NewInt(20)},
-		TIP10: &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(1)},
-		TIP11: &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP3:     &BlockConfig{FastNumber: big.NewInt(380000)},
+		TIP5:     &BlockConfig{SnailNumber: big.NewInt(5000)},
+		TIP7:     &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP8:     &BlockConfig{FastNumber: big.NewInt(100), CID: big.NewInt(-1)},
+		TIP9:     &BlockConfig{SnailNumber: big.NewInt(20)},
+		TIP10:    &BlockConfig{FastNumber: big.NewInt(0), CID: big.NewInt(1)},
+		TIP11:    &BlockConfig{FastNumber: big.NewInt(0)},
+		TIPSlash: &BlockConfig{FastNumber: big.NewInt(0)},
+		TIPStake: &BlockConfig{FastNumber: big.NewInt(0)},
+		TIP13:    &BlockConfig{FastNumber: big.NewInt(0)},
 	}
 
 	// TestnetTrustedCheckpoint contains the light client trusted checkpoint for the Ropsten test network.
@@ -260,8 +263,19 @@ type ChainConfig struct {
 	// TIP11 is use newYoloV1InstructionSet eip-2315 in it.
 	TIP11 *BlockConfig `json:"tip11"`
 
+	// TIPSlash commits the double sign evidences in the fast header and
+	// slashes the offenders.
+	TIPSlash *BlockConfig `json:"tipslash"`
+
+	// TIPStake adds the validator profile and the fee policy to the staking contract.
+	// It isn't scheduled on mainnet and testnet yet, the activation height is set
+	// there once a release carrying it is deployed by the committee members, a
+	// private chain enables it in its genesis config.
 	TIPStake *BlockConfig `json:"tipstake"`
 
+	// TIP13 upgrades the EVM with PUSH0, BASEFEE and the EIP-2929 access list gas.
+	TIP13 *BlockConfig `json:"tip13"`
+
 	// truechain 2.0
 	TIP21 *BlockConfig `json:"tip12"`
 }
@@ -456,8 +470,8 @@ func (err *ConfigCompatError) Error() string {
 // Rules is a one time interface meaning that it shouldn't be used in between transition
 // phases.
 type Rules struct {
-	ChainID                 *big.Int
-	IsTIP3, IsTIP7, IsTIP11 bool
+	ChainID                          *big.Int
+	IsTIP3, IsTIP7, IsTIP11, IsTIP13 bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -471,6 +485,7 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsTIP3:  c.IsTIP3(num),
 		IsTIP7:  c.IsTIP7(num),
 		IsTIP11: c.IsTIP11(num),
+		IsTIP13: c.IsTIP13(num),
 	}
 }
 
@@ -526,3 +541,24 @@ func (c *ChainConfig) IsTIP11(num *big.Int) bool {
 	}
 	return isForked(c.TIP11.FastNumber, num)
 }
+
+func (c *ChainConfig) IsTIPSlash(num *big.Int) bool {
+	if c.TIPSlash == nil {
+		return false
+	}
+	return isForked(c.TIPSlash.FastNumber, num)
+}
+
+func (c *ChainConfig) IsTIPStake(num *big.Int) bool {
+	if c.TIPStake == nil {
+		return false
+	}
+	return isForked(c.TIPStake.FastNumber, num)
+}
+
+func (c *ChainConfig) IsTIP13(num *big.Int) bool {
+	if c.TIP13 == nil {
+		return false
+	}
+	return isForked(c.TIP13.FastNumber, num)
+}
@@ -48,3 +48,16 @@ func TestFork(t *testing.T) {
 	forked := isForked(Tip, cur)
 	fmt.Println("fork:", forked)
 }
+
+func TestTIP13Rules(t *testing.T) {
+	config := &ChainConfig{ChainID: big.NewInt(1), TIP11: &BlockConfig{FastNumber: big.NewInt(0)}, TIP13: &BlockConfig{FastNumber: big.NewInt(10)}}
+	if rules := config.Rules(big.NewInt(9)); rules.IsTIP13 || !rules.IsTIP11 {
+		t.Errorf("rules before the fork mismatch: %+v", rules)
+	}
+	if rules := config.Rules(big.NewInt(10)); !rules.IsTIP13 {
+		t.Errorf("rules at the fork mismatch: %+v", rules)
+	}
+	if TestChainConfig.IsTIP13(big.NewInt(1 << 40)) {
+		t.Errorf("fork enabled while not scheduled")
+	}
+}
@@ -3,6 +3,7 @@ package params
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
@@ -141,6 +142,13 @@ type P2PConfig struct {
 	HandshakeTimeout time.Duration `mapstructure:"handshake_timeout"`
 	DialTimeout      time.Duration `mapstructure:"dial_timeout"`
 
+	// Transports the connections are encrypted with ("noise", "secret"), the
+	// best one supported by both peers is negotiated. Without any the legacy
+	// secret connection is made without negotiation, which is the default.
+	// The legacy peers are dialed again with it if "secret" is listed, but
+	// their dials are only accepted by the nodes without any transport.
+	Transports []string `mapstructure:"transports"`
+
 	// Testing params.
 	// Force dial to fail
 	TestDialFail bool `mapstructure:"test_dial_fail"`
@@ -217,6 +225,9 @@ type ConsensusConfig struct {
 	WalPath string `mapstructure:"wal_file"`
 	walFile string // overrides WalPath if set
 
+	// Directory of the last signed state of the validator in each committee
+	PrivValidatorStatePath string `mapstructure:"priv_validator_state_dir"`
+
 	// All timeouts are in milliseconds
 	TimeoutPropose        int `mapstructure:"timeout_propose"`
 	TimeoutProposeDelta   int `mapstructure:"timeout_propose_delta"`
@@ -243,6 +254,7 @@ type ConsensusConfig struct {
 func DefaultConsensusConfig() *ConsensusConfig {
 	return &ConsensusConfig{
 		WalPath:                     filepath.Join(defaultDataDir, "cs.wal", "wal"),
+		PrivValidatorStatePath:      filepath.Join(defaultDataDir, "priv_validator_state"),
 		TimeoutPropose:              30000,
 		TimeoutProposeDelta:         5000,
 		TimeoutPrevote:              3000,
@@ -356,6 +368,22 @@ func (cfg *ConsensusConfig) WalFile() string {
 	return rootify(cfg.WalPath, cfg.RootDir)
 }
 
+// CommitteeWalFile returns the full path to the write-ahead log file of the committee
+func (cfg *ConsensusConfig) CommitteeWalFile(cid uint64) string {
+	walFile := cfg.WalFile()
+	return filepath.Join(filepath.Dir(walFile), strconv.FormatUint(cid, 10), filepath.Base(walFile))
+}
+
+// PrivValidatorStateDir returns the full path to the directory of the last signed states
+func (cfg *ConsensusConfig) PrivValidatorStateDir() string {
+	return rootify(cfg.PrivValidatorStatePath, cfg.RootDir)
+}
+
+// CommitteePrivValidatorStateFile returns the full path to the last signed state of the committee
+func (cfg *ConsensusConfig) CommitteePrivValidatorStateFile(cid uint64) string {
+	return filepath.Join(cfg.PrivValidatorStateDir(), strconv.FormatUint(cid, 10)+".json")
+}
+
 // SetWalFile sets the path to the write-ahead log file
 func (cfg *ConsensusConfig) SetWalFile(walFile string) {
 	cfg.walFile = walFile
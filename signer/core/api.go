@@ -30,12 +30,22 @@ import (
 	"truechain/discovery/accounts/usbwallet"
 	"truechain/discovery/common"
 	"truechain/discovery/common/hexutil"
+	"truechain/discovery/core/types"
 	"truechain/discovery/crypto"
 	"truechain/discovery/internal/trueapi"
 	"truechain/discovery/log"
 	"truechain/discovery/rlp"
 )
 
+const (
+	// ExternalAPIVersion is the version of the account namespace served to the callers.
+	// 2.1.0 added account_signPayment and account_version.
+	ExternalAPIVersion = "2.1.0"
+	// InternalAPIVersion is the version of the api a UI implements.
+	// 2.1.0 added ApprovePayment.
+	InternalAPIVersion = "2.1.0"
+)
+
 // ExternalAPI defines the external API through which signing requests are made.
 type ExternalAPI interface {
 	// List available accounts
@@ -44,6 +54,8 @@ type ExternalAPI interface {
 	New(ctx context.Context) (accounts.Account, error)
 	// SignTransaction request to sign the specified transaction
 	SignTransaction(ctx context.Context, args SendTxArgs, methodSelector *string) (*trueapi.SignTransactionResult, error)
+	// SignPayment request to sign the specified payment transaction as its payer
+	SignPayment(ctx context.Context, addr common.MixedcaseAddress, tx hexutil.Bytes) (*trueapi.SignTransactionResult, error)
 	// Sign - request to sign the given data (plus prefix)
 	Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error)
 	// EcRecover - request to perform ecrecover
@@ -52,12 +64,16 @@ type ExternalAPI interface {
 	Export(ctx context.Context, addr common.Address) (json.RawMessage, error)
 	// Import - request to import an account
 	Import(ctx context.Context, keyJSON json.RawMessage) (Account, error)
+	// Version - returns the version of the external API
+	Version(ctx context.Context) (string, error)
 }
 
 // SignerUI specifies what method a UI needs to implement to be able to be used as a UI for the signer
 type SignerUI interface {
 	// ApproveTx prompt the user for confirmation to request to sign Transaction
 	ApproveTx(request *SignTxRequest) (SignTxResponse, error)
+	// ApprovePayment prompt the user for confirmation to pay for a Transaction signed by its sender
+	ApprovePayment(request *SignPaymentRequest) (SignPaymentResponse, error)
 	// ApproveSignData prompt the user for confirmation to request to sign data
 	ApproveSignData(request *SignDataRequest) (SignDataResponse, error)
 	// ApproveExport prompt the user for confirmation to export encrypted Account json
@@ -136,6 +152,20 @@ type (
 		Approved    bool       `json:"approved"`
 		Password    string     `json:"password"`
 	}
+	// SignPaymentRequest contains info about a Transaction to pay for. The
+	// sender signed it already, so the UI can't make changes to it.
+	SignPaymentRequest struct {
+		Payer       common.MixedcaseAddress `json:"payer"`
+		Transaction SendTxArgs              `json:"transaction"`
+		Policy      *types.SponsorPolicy    `json:"policy,omitempty"`
+		Callinfo    []ValidationInfo        `json:"call_info"`
+		Meta        Metadata                `json:"meta"`
+	}
+	// SignPaymentResponse result from SignPaymentRequest
+	SignPaymentResponse struct {
+		Approved bool   `json:"approved"`
+		Password string `json:"password"`
+	}
 	// ExportRequest info about query to export accounts
 	ExportRequest struct {
 		Address common.Address `json:"address"`
@@ -308,6 +338,14 @@ func logDiff(original *SignTxRequest, new *SignTxResponse) bool {
 		modified = true
 		log.Info("Nonce changed by UI", "was", n0, "is", n1)
 	}
+	if p0, p1 := original.Transaction.Payment, new.Transaction.Payment; !reflect.DeepEqual(p0, p1) {
+		modified = true
+		log.Info("Payer changed by UI", "was", p0, "is", p1)
+	}
+	if f0, f1 := original.Transaction.Fee, new.Transaction.Fee; !reflect.DeepEqual(f0, f1) {
+		modified = true
+		log.Info("Fee changed by UI", "was", f0, "is", f1)
+	}
 	return modified
 }
 
@@ -366,6 +404,68 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args SendTxArgs, meth
 
 }
 
+// SignPayment signs the given payment transaction, signed by its sender already,
+// with the key of the payer and returns it both as json and rlp-encoded form.
+// The sponsor policy attached to the transaction, if any, is covered by the
+// payer signature.
+func (api *SignerAPI) SignPayment(ctx context.Context, addr common.MixedcaseAddress, rawTx hexutil.Bytes) (*trueapi.SignTransactionResult, error) {
+	tx, err := decodeTransaction(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	if payer := tx.Payer(); payer == nil || *payer != addr.Address() {
+		return nil, fmt.Errorf("transaction is not paid for by %v", addr.Address().Hex())
+	}
+	signer := types.NewTIP13Signer(api.chainID)
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender signature: %v", err)
+	}
+	args := txArgsFromTransaction(tx, from)
+	msgs, err := api.validator.ValidateTransaction(&args, nil)
+	if err != nil {
+		return nil, err
+	}
+	req := SignPaymentRequest{
+		Payer:       addr,
+		Transaction: args,
+		Policy:      tx.SponsorPolicy(),
+		Callinfo:    msgs.Messages,
+		Meta:        MetadataFromContext(ctx),
+	}
+	// Process approval
+	result, err := api.UI.ApprovePayment(&req)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Approved {
+		return nil, ErrRequestDenied
+	}
+	account := accounts.Account{Address: addr.Address()}
+	wallet, err := api.am.Find(account)
+	if err != nil {
+		return nil, err
+	}
+	// The payer signs the payment hash, wallets only sign it with an unlocked key
+	signature, err := wallet.SignHashWithPassphrase(account, result.Password, signer.Hash_Payment(tx).Bytes())
+	if err != nil {
+		api.UI.ShowError(err.Error())
+		return nil, err
+	}
+	signedTx, err := tx.WithSignature_Payment(signer, signature)
+	if err != nil {
+		return nil, err
+	}
+	rlpdata, err := rlp.EncodeToBytes(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	response := trueapi.SignTransactionResult{Raw: rlpdata, Tx: signedTx}
+
+	api.UI.OnApprovedTx(response)
+	return &response, nil
+}
+
 // Sign calculates an Ethereum ECDSA signature for:
 // keccack256("\x19Ethereum Signed Message:\n" + len(message) + message))
 //
@@ -404,6 +504,21 @@ func (api *SignerAPI) Sign(ctx context.Context, addr common.MixedcaseAddress, da
 	return signature, nil
 }
 
+// decodeTransaction decodes a transaction in the rlp form returned by
+// SignTransaction or in its canonical binary encoding.
+func decodeTransaction(rawTx []byte) (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if len(rawTx) > 0 && rawTx[0] > 0x7f {
+		return tx, rlp.DecodeBytes(rawTx, tx)
+	}
+	return tx, tx.UnmarshalBinary(rawTx)
+}
+
+// Version returns the version of the external API.
+func (api *SignerAPI) Version(ctx context.Context) (string, error) {
+	return ExternalAPIVersion, nil
+}
+
 // EcRecover returns the address for the Account that was used to create the signature.
 // Note, this function is compatible with etrue_sign and personal_sign. As such it recovers
 // the address of:
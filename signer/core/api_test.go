@@ -62,6 +62,12 @@ func (ui *HeadlessUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error)
 		return SignTxResponse{request.Transaction, false, ""}, nil
 	}
 }
+func (ui *HeadlessUI) ApprovePayment(request *SignPaymentRequest) (SignPaymentResponse, error) {
+	if "Y" == <-ui.controller {
+		return SignPaymentResponse{true, <-ui.controller}, nil
+	}
+	return SignPaymentResponse{false, ""}, nil
+}
 func (ui *HeadlessUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
 	if "Y" == <-ui.controller {
 		return SignDataResponse{true, <-ui.controller}, nil
@@ -357,6 +363,64 @@ func TestSignTx(t *testing.T) {
 
 }
 
+func TestSignPayment(t *testing.T) {
+	api, control := setup(t)
+	createAccount(control, api, t)
+	createAccount(control, api, t)
+	control <- "A"
+	list, err := api.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		from  = common.NewMixedcaseAddress(list[0].Address)
+		payer = common.NewMixedcaseAddress(list[1].Address)
+		fee   = (hexutil.Big)(*big.NewInt(1000))
+	)
+	tx := mkTestTx(from)
+	tx.Payment, tx.Fee = &payer, &fee
+
+	control <- "Y"
+	control <- "apassword"
+	res, err := api.SignTransaction(context.Background(), tx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the payer named in the transaction may pay for it
+	if _, err := api.SignPayment(context.Background(), from, res.Raw); err == nil {
+		t.Error("Expected payment by another account to fail")
+	}
+	control <- "No way"
+	if _, err := api.SignPayment(context.Background(), payer, res.Raw); err != ErrRequestDenied {
+		t.Errorf("Expected ErrRequestDenied! %v", err)
+	}
+	control <- "Y"
+	control <- "wrongpassword"
+	if _, err := api.SignPayment(context.Background(), payer, res.Raw); err != keystore.ErrDecrypt {
+		t.Errorf("Expected ErrDecrypt! %v", err)
+	}
+	control <- "Y"
+	control <- "apassword"
+	paid, err := api.SignPayment(context.Background(), payer, res.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedTx := &types.Transaction{}
+	if err := rlp.Decode(bytes.NewReader(paid.Raw), parsedTx); err != nil {
+		t.Fatal(err)
+	}
+	signer := types.NewTIP13Signer(big.NewInt(1))
+	if addr, err := types.Sender(signer, parsedTx); err != nil || addr != from.Address() {
+		t.Errorf("sender mismatch: have %x, want %x, err %v", addr, from.Address(), err)
+	}
+	if addr, err := types.Payer(signer, parsedTx); err != nil || addr != payer.Address() {
+		t.Errorf("payer mismatch: have %x, want %x, err %v", addr, payer.Address(), err)
+	}
+	if parsedTx.Fee().Cmp(fee.ToInt()) != 0 {
+		t.Errorf("fee mismatch: have %v, want %v", parsedTx.Fee(), fee.ToInt())
+	}
+}
+
 /*
 func TestAsyncronousResponses(t *testing.T){
 
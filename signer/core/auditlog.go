@@ -64,6 +64,19 @@ func (l *AuditLogger) SignTransaction(ctx context.Context, args SendTxArgs, meth
 	return res, e
 }
 
+func (l *AuditLogger) SignPayment(ctx context.Context, addr common.MixedcaseAddress, tx hexutil.Bytes) (*trueapi.SignTransactionResult, error) {
+	l.log.Info("SignPayment", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"addr", addr.String(), "tx", common.Bytes2Hex(tx))
+
+	res, e := l.api.SignPayment(ctx, addr, tx)
+	if res != nil {
+		l.log.Info("SignPayment", "type", "response", "data", common.Bytes2Hex(res.Raw), "error", e)
+	} else {
+		l.log.Info("SignPayment", "type", "response", "data", res, "error", e)
+	}
+	return res, e
+}
+
 func (l *AuditLogger) Sign(ctx context.Context, addr common.MixedcaseAddress, data hexutil.Bytes) (hexutil.Bytes, error) {
 	l.log.Info("Sign", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"addr", addr.String(), "data", common.Bytes2Hex(data))
@@ -98,6 +111,13 @@ func (l *AuditLogger) Import(ctx context.Context, keyJSON json.RawMessage) (Acco
 	return a, e
 }
 
+func (l *AuditLogger) Version(ctx context.Context) (string, error) {
+	l.log.Info("Version", "type", "request", "metadata", MetadataFromContext(ctx).String())
+	data, err := l.api.Version(ctx)
+	l.log.Info("Version", "type", "response", "data", data, "error", err)
+	return data, err
+}
+
 func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
 	l := log.New("api", "signer")
 	handler, err := log.FileHandler(path, log.LogfmtFormat())
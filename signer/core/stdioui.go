@@ -55,6 +55,12 @@ func (ui *StdIOUI) ApproveTx(request *SignTxRequest) (SignTxResponse, error) {
 	return result, err
 }
 
+func (ui *StdIOUI) ApprovePayment(request *SignPaymentRequest) (SignPaymentResponse, error) {
+	var result SignPaymentResponse
+	err := ui.dispatch("ApprovePayment", request, &result)
+	return result, err
+}
+
 func (ui *StdIOUI) ApproveSignData(request *SignDataRequest) (SignDataResponse, error) {
 	var result SignDataResponse
 	err := ui.dispatch("ApproveSignData", request, &result)
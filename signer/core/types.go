@@ -71,6 +71,10 @@ type SendTxArgs struct {
 	// We accept "data" and "input" for backwards-compatibility reasons.
 	Data  *hexutil.Bytes `json:"data"`
 	Input *hexutil.Bytes `json:"input"`
+
+	// The payer and fee of a payment transaction, the payer signs separately
+	Payment *common.MixedcaseAddress `json:"payment,omitempty"`
+	Fee     *hexutil.Big             `json:"fee,omitempty"`
 }
 
 func (args SendTxArgs) String() string {
@@ -88,8 +92,38 @@ func (args *SendTxArgs) toTransaction() *types.Transaction {
 	} else if args.Input != nil {
 		input = *args.Input
 	}
+	var payer common.Address
+	if args.Payment != nil {
+		payer = args.Payment.Address()
+	}
 	if args.To == nil {
-		return types.NewContractCreation(uint64(args.Nonce), (*big.Int)(&args.Value), uint64(args.Gas), (*big.Int)(&args.GasPrice), input)
+		return types.NewContractCreation_Payment(uint64(args.Nonce), (*big.Int)(&args.Value), (*big.Int)(args.Fee), uint64(args.Gas), (*big.Int)(&args.GasPrice), input, payer)
+	}
+	return types.NewTransaction_Payment(uint64(args.Nonce), args.To.Address(), (*big.Int)(&args.Value), (*big.Int)(args.Fee), (uint64)(args.Gas), (*big.Int)(&args.GasPrice), input, payer)
+}
+
+// txArgsFromTransaction returns the arguments of a transaction signed by the
+// sender, for the payer to approve.
+func txArgsFromTransaction(tx *types.Transaction, from common.Address) SendTxArgs {
+	data := hexutil.Bytes(tx.Data())
+	args := SendTxArgs{
+		From:     common.NewMixedcaseAddress(from),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     &data,
+	}
+	if to := tx.To(); to != nil {
+		addr := common.NewMixedcaseAddress(*to)
+		args.To = &addr
+	}
+	if payer := tx.Payer(); payer != nil {
+		addr := common.NewMixedcaseAddress(*payer)
+		args.Payment = &addr
+	}
+	if fee := tx.Fee(); fee != nil {
+		args.Fee = (*hexutil.Big)(fee)
 	}
-	return types.NewTransaction(uint64(args.Nonce), args.To.Address(), (*big.Int)(&args.Value), (uint64)(args.Gas), (*big.Int)(&args.GasPrice), input)
+	return args
 }
@@ -23,6 +23,8 @@ import (
 	"math/big"
 
 	"truechain/discovery/common"
+	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
 )
 
 // The validation package contains validation checks for transactions
@@ -102,6 +104,27 @@ func (v *Validator) validateCallData(msgs *ValidationMessages, data []byte, meth
 	}
 }
 
+// stakingABIs are the versions of the staking contract ABI, the latest first.
+var stakingABIs = []string{vm.StakeProfileABIJSON, vm.TIP10StakeABIJSON, vm.StakeABIJSON}
+
+// validateStakingCall decodes a call to the staking precompile against its ABI
+// instead of the 4byte database, which doesn't know the staking methods.
+func (v *Validator) validateStakingCall(msgs *ValidationMessages, data []byte) {
+	if len(data) == 0 {
+		msgs.crit("Tx calls the staking contract without a method")
+		return
+	}
+	var err error
+	for _, abidata := range stakingABIs {
+		var info *decodedCallData
+		if info, err = parseCallData(data, abidata); err == nil {
+			msgs.info(fmt.Sprintf("Staking call: %v", info))
+			return
+		}
+	}
+	msgs.crit(fmt.Sprintf("Tx calls the staking contract, but the call could not be decoded: %v", err))
+}
+
 // validateSemantics checks if the transactions 'makes sense', and generate warnings for a couple of typical scenarios
 func (v *Validator) validate(msgs *ValidationMessages, txargs *SendTxArgs, methodSelector *string) error {
 	// Prevent accidental erroneous usage of both 'input' and 'data'
@@ -150,7 +173,20 @@ func (v *Validator) validate(msgs *ValidationMessages, txargs *SendTxArgs, metho
 			msgs.crit("Tx destination is the zero address!")
 		}
 		// Validate calldata
-		v.validateCallData(msgs, data, methodSelector)
+		if txargs.To.Address() == types.StakingAddress {
+			v.validateStakingCall(msgs, data)
+		} else {
+			v.validateCallData(msgs, data, methodSelector)
+		}
+	}
+	if txargs.Payment != nil {
+		if txargs.Payment.Address() == txargs.From.Address() {
+			msgs.warn("Tx is paid for by its own sender")
+		} else {
+			msgs.info(fmt.Sprintf("Tx gas is paid for by %v", txargs.Payment.Address().Hex()))
+		}
+	} else if txargs.Fee != nil && txargs.Fee.ToInt().Sign() > 0 {
+		msgs.warn("Tx carries a fee but no payer")
 	}
 	return nil
 }
@@ -19,10 +19,14 @@ package core
 import (
 	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 
+	"truechain/discovery/accounts/abi"
 	"truechain/discovery/common"
 	"truechain/discovery/common/hexutil"
+	"truechain/discovery/core/types"
+	"truechain/discovery/core/vm"
 )
 
 func hexAddr(a string) common.Address { return common.BytesToAddress(common.FromHex(a)) }
@@ -137,3 +141,42 @@ func TestValidator(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateStakingCall(t *testing.T) {
+	var (
+		db, _ = NewEmptyAbiDB()
+		v     = NewValidator(db)
+		from  = common.NewMixedcaseAddress(common.HexToAddress("0xdead"))
+		to    = common.NewMixedcaseAddress(types.StakingAddress)
+	)
+	staking, err := abi.JSON(strings.NewReader(vm.TIP10StakeABIJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit, err := staking.Pack("deposit", common.FromHex("0x04abcd"), big.NewInt(50), big.NewInt(1e18))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		data hexutil.Bytes
+		typ  string
+		msg  string
+	}{
+		{deposit, "Info", "Staking call: deposit("},
+		{hexutil.Bytes{0xde, 0xad, 0xbe, 0xef}, "CRITICAL", "could not be decoded"},
+		{nil, "CRITICAL", "without a method"},
+	}
+	for i, tt := range tests {
+		args := &SendTxArgs{From: from, To: &to, Gas: 100000, Data: &tests[i].data}
+		msgs, err := v.ValidateTransaction(args, nil)
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if len(msgs.Messages) != 1 {
+			t.Fatalf("test %d: expected 1 message, got %v", i, msgs.Messages)
+		}
+		if msg := msgs.Messages[0]; msg.Typ != tt.typ || !strings.Contains(msg.Message, tt.msg) {
+			t.Errorf("test %d: message mismatch: have %s: %s, want %s: %s", i, msg.Typ, msg.Message, tt.typ, tt.msg)
+		}
+	}
+}
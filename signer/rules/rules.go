@@ -158,6 +158,19 @@ func (r *rulesetUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse,
 	return core.SignTxResponse{Approved: false}, err
 }
 
+func (r *rulesetUI) ApprovePayment(request *core.SignPaymentRequest) (core.SignPaymentResponse, error) {
+	jsonreq, err := json.Marshal(request)
+	approved, err := r.checkApproval("ApprovePayment", jsonreq, err)
+	if err != nil {
+		log.Info("Rule-based approval error, going to manual", "error", err)
+		return r.next.ApprovePayment(request)
+	}
+	if approved {
+		return core.SignPaymentResponse{Approved: true, Password: r.lookupPassword(request.Payer.Address())}, nil
+	}
+	return core.SignPaymentResponse{Approved: false}, err
+}
+
 func (r *rulesetUI) lookupPassword(address common.Address) string {
 	return r.credentials.Get(strings.ToLower(address.String()))
 }
@@ -81,6 +81,10 @@ func (alwaysDenyUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse,
 	return core.SignTxResponse{Transaction: request.Transaction, Approved: false, Password: ""}, nil
 }
 
+func (alwaysDenyUI) ApprovePayment(request *core.SignPaymentRequest) (core.SignPaymentResponse, error) {
+	return core.SignPaymentResponse{Approved: false, Password: ""}, nil
+}
+
 func (alwaysDenyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
 	return core.SignDataResponse{Approved: false, Password: ""}, nil
 }
@@ -196,6 +200,50 @@ func TestSignTxRequest(t *testing.T) {
 	}
 }
 
+func TestSignPaymentRequest(t *testing.T) {
+
+	js := `
+	function ApprovePayment(r){
+		if(r.payer.toLowerCase()!="0x0000000000000000000000000000000000001337"){ return "Reject"}
+		if(r.transaction.fee && parseInt(r.transaction.fee) > 1000){ return "Reject"}
+		return "Approve"
+	}`
+
+	r, err := initRuleEngine(js)
+	if err != nil {
+		t.Errorf("Couldn't create evaluator %v", err)
+		return
+	}
+	from, _ := mixAddr("000000000000000000000000000000000000dead")
+	payer, _ := mixAddr("0000000000000000000000000000000000001337")
+
+	tests := []struct {
+		fee      int64
+		approved bool
+	}{
+		{1000, true},
+		{1001, false},
+	}
+	for i, tt := range tests {
+		fee := hexutil.Big(*big.NewInt(tt.fee))
+		resp, err := r.ApprovePayment(&core.SignPaymentRequest{
+			Payer: *payer,
+			Transaction: core.SendTxArgs{
+				From:    *from,
+				To:      from,
+				Payment: payer,
+				Fee:     &fee},
+			Meta: core.Metadata{Remote: "remoteip", Local: "localip", Scheme: "inproc"},
+		})
+		if err != nil {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+		if resp.Approved != tt.approved {
+			t.Errorf("test %d: approved mismatch: have %v, want %v", i, resp.Approved, tt.approved)
+		}
+	}
+}
+
 type dummyUI struct {
 	calls []string
 }
@@ -205,6 +253,11 @@ func (d *dummyUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, e
 	return core.SignTxResponse{}, core.ErrRequestDenied
 }
 
+func (d *dummyUI) ApprovePayment(request *core.SignPaymentRequest) (core.SignPaymentResponse, error) {
+	d.calls = append(d.calls, "ApprovePayment")
+	return core.SignPaymentResponse{}, core.ErrRequestDenied
+}
+
 func (d *dummyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
 	d.calls = append(d.calls, "ApproveSignData")
 	return core.SignDataResponse{}, core.ErrRequestDenied
@@ -260,6 +313,7 @@ func TestForwarding(t *testing.T) {
 	}
 	r.ApproveSignData(nil)
 	r.ApproveTx(nil)
+	r.ApprovePayment(nil)
 	r.ApproveImport(nil)
 	r.ApproveNewAccount(nil)
 	r.ApproveListing(nil)
@@ -270,7 +324,7 @@ func TestForwarding(t *testing.T) {
 	//This one is not forwarded
 	r.OnApprovedTx(trueapi.SignTransactionResult{})
 
-	expCalls := 8
+	expCalls := 9
 	if len(ui.calls) != expCalls {
 
 		t.Errorf("Expected %d forwarded calls, got %d: %s", expCalls, len(ui.calls), strings.Join(ui.calls, ","))
@@ -517,6 +571,11 @@ func (d *dontCallMe) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse
 	return core.SignTxResponse{}, core.ErrRequestDenied
 }
 
+func (d *dontCallMe) ApprovePayment(request *core.SignPaymentRequest) (core.SignPaymentResponse, error) {
+	d.t.Fatalf("Did not expect next-handler to be called")
+	return core.SignPaymentResponse{}, core.ErrRequestDenied
+}
+
 func (d *dontCallMe) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
 	d.t.Fatalf("Did not expect next-handler to be called")
 	return core.SignDataResponse{}, core.ErrRequestDenied
@@ -244,7 +244,7 @@ func (tx *stTransaction) toMessage(ps stPostState) (core.Message, error) {
 		return nil, fmt.Errorf("invalid tx data %q", dataHex)
 	}
 
-	msg := types.NewMessage(from, to, common.Address{}, tx.Nonce, value, nil, gasLimit, tx.GasPrice, data, true)
+	msg := types.NewMessage(from, to, common.Address{}, tx.Nonce, value, nil, gasLimit, tx.GasPrice, data, nil, true)
 	return msg, nil
 }
 
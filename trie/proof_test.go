@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -143,6 +144,168 @@ func TestMissingKeyProof(t *testing.T) {
 	}
 }
 
+// sortedEntries returns the leaves of the trie ordered by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	entries := make([]*kv, 0, len(vals))
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return entries
+}
+
+// proveRange returns the proofs of both edge keys.
+func proveRange(trie *Trie, first, last []byte) *etruedb.MemDatabase {
+	proof := etruedb.NewMemDatabase()
+	trie.Prove(first, 0, proof)
+	trie.Prove(last, 0, proof)
+	return proof
+}
+
+// increaseKey returns the key plus one, decreaseKey the key minus one.
+func increaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i]++; key[i] != 0 {
+			break
+		}
+	}
+	return key
+}
+
+func decreaseKey(key []byte) []byte {
+	key = common.CopyBytes(key)
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i]--; key[i] != 0xff {
+			break
+		}
+	}
+	return key
+}
+
+// Tests that ranges are proven by their edge proofs, whether the edge keys are
+// in the trie or not, and that the leaves right of the range are reported.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	root, entries := trie.Hash(), sortedEntries(vals)
+
+	for i := 0; i < 100; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + mrand.Intn(len(entries)-start) + 1
+
+		var keys, values [][]byte
+		for _, kv := range entries[start:end] {
+			keys = append(keys, kv.k)
+			values = append(values, kv.v)
+		}
+		first, last := keys[0], keys[len(keys)-1]
+		if start > 0 && mrand.Intn(2) == 0 && !bytes.Equal(decreaseKey(first), entries[start-1].k) {
+			first = decreaseKey(first) // absent left edge
+		}
+		if end < len(entries) && mrand.Intn(2) == 0 && !bytes.Equal(increaseKey(last), entries[end].k) {
+			last = increaseKey(last) // absent right edge
+		}
+		more, err := VerifyRangeProof(root, first, last, keys, values, proveRange(trie, first, last))
+		if err != nil {
+			t.Fatalf("range %d-%d: failed to verify: %v", start, end, err)
+		}
+		if want := end < len(entries); more != want {
+			t.Fatalf("range %d-%d: more mismatch: have %v, want %v", start, end, more, want)
+		}
+	}
+}
+
+// Tests that ranges with a missing, forged or added leaf are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	root, entries := trie.Hash(), sortedEntries(vals)
+
+	for i := 0; i < 100; i++ {
+		start := mrand.Intn(len(entries) - 3)
+		end := start + 3 + mrand.Intn(len(entries)-start-3)
+
+		var keys, values [][]byte
+		for _, kv := range entries[start:end] {
+			keys = append(keys, common.CopyBytes(kv.k))
+			values = append(values, common.CopyBytes(kv.v))
+		}
+		first, last := keys[0], keys[len(keys)-1]
+		index := 1 + mrand.Intn(len(keys)-2)
+
+		switch mrand.Intn(3) {
+		case 0: // gap in the middle
+			keys = append(keys[:index:index], keys[index+1:]...)
+			values = append(values[:index:index], values[index+1:]...)
+		case 1: // forged value
+			mutateByte(values[index])
+		case 2: // added leaf
+			key := increaseKey(keys[index])
+			if bytes.Equal(key, keys[index+1]) {
+				continue
+			}
+			keys = append(keys[:index+1:index+1], append([][]byte{key}, keys[index+1:]...)...)
+			values = append(values[:index+1:index+1], append([][]byte{{0x01}}, values[index+1:]...)...)
+		}
+		if _, err := VerifyRangeProof(root, first, last, keys, values, proveRange(trie, first, last)); err == nil {
+			t.Fatalf("range %d-%d: bad range accepted", start, end)
+		}
+	}
+}
+
+// Tests that an empty range is only accepted if no leaf follows its key.
+func TestEmptyRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	root, entries := trie.Hash(), sortedEntries(vals)
+
+	tests := []struct {
+		key []byte
+		ok  bool
+	}{
+		{entries[len(entries)-1].k, false}, // the key is in the trie
+		{increaseKey(entries[len(entries)-1].k), true},
+		{increaseKey(entries[len(entries)/2].k), false},
+		{common.LeftPadBytes(nil, 32), false},
+	}
+	for i, tt := range tests {
+		proof := etruedb.NewMemDatabase()
+		trie.Prove(tt.key, 0, proof)
+		if _, err := VerifyRangeProof(root, tt.key, nil, nil, nil, proof); (err == nil) != tt.ok {
+			t.Errorf("test %d: result mismatch: have %v, want ok %v", i, err, tt.ok)
+		}
+	}
+}
+
+// Tests ranges of a single leaf, and ranges covering the whole trie.
+func TestEdgeRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	root, entries := trie.Hash(), sortedEntries(vals)
+
+	// A single leaf proven by itself
+	kv := entries[len(entries)/2]
+	more, err := VerifyRangeProof(root, kv.k, kv.k, [][]byte{kv.k}, [][]byte{kv.v}, proveRange(trie, kv.k, kv.k))
+	if err != nil || !more {
+		t.Errorf("single leaf: have %v/%v, want true/nil", more, err)
+	}
+	if _, err := VerifyRangeProof(root, kv.k, kv.k, [][]byte{kv.k}, [][]byte{{0x01}}, proveRange(trie, kv.k, kv.k)); err == nil {
+		t.Errorf("forged single leaf accepted")
+	}
+	// The whole trie between the smallest and largest keys
+	var keys, values [][]byte
+	for _, kv := range entries {
+		keys = append(keys, kv.k)
+		values = append(values, kv.v)
+	}
+	first, last := common.LeftPadBytes(nil, 32), bytes.Repeat([]byte{0xff}, 32)
+	more, err = VerifyRangeProof(root, first, last, keys, values, proveRange(trie, first, last))
+	if err != nil || more {
+		t.Errorf("whole trie: have %v/%v, want false/nil", more, err)
+	}
+	// Leaves out of the edge keys are rejected
+	if _, err := VerifyRangeProof(root, keys[1], last, keys, values, proveRange(trie, keys[1], last)); err == nil {
+		t.Errorf("leaf left of the range accepted")
+	}
+}
+
 // mutateByte changes one byte in b.
 func mutateByte(b []byte) {
 	for r := mrand.Intn(len(b)); ; {